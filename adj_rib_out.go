@@ -0,0 +1,164 @@
+//go:build go1.18
+// +build go1.18
+
+package corebgp
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+type adjRIBOutFamily struct {
+	afi  uint16
+	safi uint8
+}
+
+type adjRIBOutKey struct {
+	pathID uint32
+	prefix netip.Prefix
+}
+
+// AdjRIBOut tracks the prefixes advertised to a peer, so that they may be
+// withdrawn at once via Flush, e.g. when the peer is administratively
+// isolated or policy no longer permits any route towards it. Plugins record
+// the prefixes of the UPDATE messages they send via Advertise and Withdraw.
+//
+// The routes of a peer are implicitly withdrawn once its session is closed
+// (RFC4271 section 8.2.2), so nothing is sent upon closing. Instead an
+// AdjRIBOut should be Reset when Plugin.OnClose fires, and repopulated as
+// routes are advertised on the next session. An AdjRIBOut is safe for
+// concurrent use.
+type AdjRIBOut struct {
+	mu     sync.Mutex
+	routes map[adjRIBOutFamily]map[adjRIBOutKey]PrefixNLRI
+}
+
+// NewAdjRIBOut returns an empty AdjRIBOut.
+func NewAdjRIBOut() *AdjRIBOut {
+	return &AdjRIBOut{
+		routes: make(map[adjRIBOutFamily]map[adjRIBOutKey]PrefixNLRI),
+	}
+}
+
+// Advertise records prefixes of afi/safi as advertised, replacing previous
+// advertisements of the same prefix and path identifier.
+func (r *AdjRIBOut) Advertise(afi uint16, safi uint8,
+	prefixes ...PrefixNLRI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family := adjRIBOutFamily{afi: afi, safi: safi}
+	routes, ok := r.routes[family]
+	if !ok {
+		routes = make(map[adjRIBOutKey]PrefixNLRI)
+		r.routes[family] = routes
+	}
+	for _, p := range prefixes {
+		routes[adjRIBOutKey{pathID: p.PathID, prefix: p.Prefix}] = p
+	}
+}
+
+// Withdraw records prefixes of afi/safi as withdrawn.
+func (r *AdjRIBOut) Withdraw(afi uint16, safi uint8,
+	prefixes ...PrefixNLRI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family := adjRIBOutFamily{afi: afi, safi: safi}
+	routes := r.routes[family]
+	for _, p := range prefixes {
+		key := adjRIBOutKey{pathID: p.PathID, prefix: p.Prefix}
+		delete(routes, key)
+	}
+	if len(routes) == 0 {
+		delete(r.routes, family)
+	}
+}
+
+// Prefixes returns the prefixes of afi/safi recorded as advertised, ordered
+// by prefix and path identifier.
+func (r *AdjRIBOut) Prefixes(afi uint16, safi uint8) []PrefixNLRI {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prefixes(adjRIBOutFamily{afi: afi, safi: safi})
+}
+
+func (r *AdjRIBOut) prefixes(family adjRIBOutFamily) []PrefixNLRI {
+	routes := r.routes[family]
+	prefixes := make([]PrefixNLRI, 0, len(routes))
+	for _, p := range routes {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i], prefixes[j]
+		if a.Prefix.Addr() != b.Prefix.Addr() {
+			return a.Prefix.Addr().Less(b.Prefix.Addr())
+		}
+		if a.Prefix.Bits() != b.Prefix.Bits() {
+			return a.Prefix.Bits() < b.Prefix.Bits()
+		}
+		return a.PathID < b.PathID
+	})
+	return prefixes
+}
+
+// Len returns the number of prefixes recorded as advertised.
+func (r *AdjRIBOut) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int
+	for _, routes := range r.routes {
+		n += len(routes)
+	}
+	return n
+}
+
+// Reset forgets all prefixes without withdrawing them, see AdjRIBOut.
+func (r *AdjRIBOut) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = make(map[adjRIBOutFamily]map[adjRIBOutKey]PrefixNLRI)
+}
+
+// Flush withdraws all prefixes recorded as advertised by writing the
+// UPDATE messages returned by NewWithdrawals for each AFI/SAFI to w, whose
+// session has the given codec. Prefixes are withdrawn without labels.
+// Families are flushed in ascending AFI/SAFI order and forgotten once their
+// messages were written. The session remains established, so an End-of-RIB
+// marker or further advertisements may follow.
+//
+// If writing fails the remaining prefixes are retained, and the error is
+// returned. Should the session have closed in the meantime, its prefixes are
+// implicitly withdrawn, and the AdjRIBOut should be Reset.
+func (r *AdjRIBOut) Flush(w UpdateMessageWriter, codec *Codec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	families := make([]adjRIBOutFamily, 0, len(r.routes))
+	for family := range r.routes {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		if families[i].afi != families[j].afi {
+			return families[i].afi < families[j].afi
+		}
+		return families[i].safi < families[j].safi
+	})
+	for _, family := range families {
+		prefixes := r.prefixes(family)
+		for i := range prefixes {
+			prefixes[i].Labels = nil
+		}
+		messages, err := NewWithdrawals(family.afi, family.safi, codec,
+			prefixes...)
+		if err != nil {
+			return err
+		}
+		for _, m := range messages {
+			err = w.WriteUpdate(m)
+			if err != nil {
+				return err
+			}
+		}
+		delete(r.routes, family)
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+// Package aggregate computes aggregate routes (RFC4271 section 9.2.2.2) from
+// contributing routes, deriving the ORIGIN, AS_PATH, ATOMIC_AGGREGATE and
+// AGGREGATOR attributes of each aggregate:
+//
+//	a := aggregate.New(65000, routerID)
+//	err := a.Add(prefix, aggregate.ASSet(), aggregate.SummaryOnly())
+//	for _, agg := range a.Compute(routes) {
+//		attrs, err := agg.Attrs(fourOctetAS)
+//		...
+//	}
+//
+// Aggregates added with SummaryOnly suppress their contributing routes, which
+// must then not be advertised, see Suppressed.
+package aggregate
+
+import (
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+// asTrans is the two-octet AS number substituted for four-octet AS numbers.
+// https://tools.ietf.org/html/rfc6793#section-9
+const asTrans = 23456
+
+// Route is a route that may contribute to aggregates.
+type Route struct {
+	Prefix *net.IPNet
+	// Origin is the value of the ORIGIN attribute, e.g.
+	// corebgp.OriginIGP.
+	Origin uint8
+	ASPath corebgp.ASPath
+	// AtomicAggregate is true if the route carries the ATOMIC_AGGREGATE
+	// attribute.
+	AtomicAggregate bool
+}
+
+type options struct {
+	asSet       bool
+	summaryOnly bool
+}
+
+// Option is an option for an aggregate added via Aggregator.Add.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// ASSet generates an AS_SET of the AS numbers of the contributing routes not
+// part of their common leading AS_SEQUENCE, instead of setting the
+// ATOMIC_AGGREGATE attribute. Note that RFC6472 recommends against AS_SETs.
+func ASSet() Option {
+	return newFuncOption(func(o *options) {
+		o.asSet = true
+	})
+}
+
+// SummaryOnly suppresses the advertisement of the contributing routes of the
+// aggregate.
+func SummaryOnly() Option {
+	return newFuncOption(func(o *options) {
+		o.summaryOnly = true
+	})
+}
+
+// Aggregator computes aggregates on behalf of a BGP speaker. It is safe for
+// concurrent use.
+type Aggregator struct {
+	localAS  uint32
+	routerID net.IP
+
+	mu sync.Mutex
+	// aggregates holds *options keyed by aggregate prefix
+	aggregates *prefixtrie.Trie
+}
+
+// New returns an Aggregator for a speaker of localAS and routerID, which are
+// carried by the AGGREGATOR attribute of aggregates.
+func New(localAS uint32, routerID net.IP) *Aggregator {
+	return &Aggregator{
+		localAS:    localAS,
+		routerID:   routerID.To4(),
+		aggregates: prefixtrie.New(),
+	}
+}
+
+// Add adds or replaces the aggregate prefix.
+func (a *Aggregator) Add(prefix *net.IPNet, opts ...Option) error {
+	o := &options{}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.aggregates.Insert(prefix, o)
+}
+
+// Remove removes the aggregate prefix. It returns false if it was not added.
+func (a *Aggregator) Remove(prefix *net.IPNet) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.aggregates.Delete(prefix)
+}
+
+// Aggregate is an aggregate route computed by Aggregator.Compute.
+type Aggregate struct {
+	Prefix          *net.IPNet
+	Origin          uint8
+	ASPath          corebgp.ASPath
+	AtomicAggregate bool
+	// AggregatorAS and AggregatorID are the values of the AGGREGATOR
+	// attribute.
+	AggregatorAS uint32
+	AggregatorID net.IP
+	// Contributors contains the prefixes of the contributing routes, i.e.
+	// those more specific than Prefix.
+	Contributors []*net.IPNet
+	// SummaryOnly is true if the aggregate was added with SummaryOnly, in
+	// which case the Contributors must not be advertised.
+	SummaryOnly bool
+}
+
+// Compute returns the aggregates with at least one contributing route among
+// routes, in address order with shorter prefixes first. A route contributes
+// to each aggregate it is more specific than. Routes should be those
+// selected for advertisement, and only one per prefix.
+//
+// Following RFC4271 section 9.2.2.2, the ORIGIN of an aggregate is
+// INCOMPLETE if that of any contributing route is, otherwise EGP if that of
+// any is, otherwise IGP. The AS_PATH is that of the contributing routes if
+// identical, otherwise their common leading AS_SEQUENCE, followed by an
+// AS_SET if ASSet was set. ATOMIC_AGGREGATE is set if any contributing route
+// carries it, or if AS_PATH information is lost.
+func (a *Aggregator) Compute(routes []Route) []Aggregate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	contributors := make(map[string][]*Route)
+	for i := range routes {
+		r := &routes[i]
+		bits, _ := r.Prefix.Mask.Size()
+		a.aggregates.Covering(r.Prefix, func(p *net.IPNet,
+			_ interface{}) bool {
+			if aggBits, _ := p.Mask.Size(); aggBits < bits {
+				contributors[p.String()] = append(contributors[p.String()], r)
+			}
+			return true
+		})
+	}
+	var aggregates []Aggregate
+	a.aggregates.Walk(func(p *net.IPNet, v interface{}) bool {
+		routes := contributors[p.String()]
+		if len(routes) == 0 {
+			return true
+		}
+		aggregates = append(aggregates, a.aggregate(p, v.(*options), routes))
+		return true
+	})
+	return aggregates
+}
+
+func (a *Aggregator) aggregate(prefix *net.IPNet, o *options,
+	routes []*Route) Aggregate {
+	agg := Aggregate{
+		Prefix:       prefix,
+		AggregatorAS: a.localAS,
+		AggregatorID: a.routerID,
+		SummaryOnly:  o.summaryOnly,
+	}
+	identical := true
+	for _, r := range routes {
+		agg.Contributors = append(agg.Contributors, r.Prefix)
+		if r.Origin > agg.Origin {
+			agg.Origin = r.Origin
+		}
+		if r.AtomicAggregate {
+			agg.AtomicAggregate = true
+		}
+		if !equalPaths(r.ASPath, routes[0].ASPath) {
+			identical = false
+		}
+	}
+	if identical {
+		agg.ASPath = routes[0].ASPath.Clone()
+		return agg
+	}
+
+	// the common leading AS_SEQUENCE of the contributing routes
+	common := leadingSequence(routes[0].ASPath)
+	for _, r := range routes[1:] {
+		seq := leadingSequence(r.ASPath)
+		n := 0
+		for n < len(common) && n < len(seq) && common[n] == seq[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) > 0 {
+		agg.ASPath = corebgp.ASPath{{
+			Type: corebgp.ASPathSegmentSequence,
+			ASNs: append([]uint32(nil), common...),
+		}}
+	}
+	if !o.asSet {
+		agg.AtomicAggregate = true
+		return agg
+	}
+	seen := make(map[uint32]bool)
+	var set []uint32
+	for _, r := range routes {
+		skip := len(common)
+		for _, seg := range r.ASPath {
+			if seg.Type == corebgp.ASPathSegmentConfedSequence ||
+				seg.Type == corebgp.ASPathSegmentConfedSet {
+				continue
+			}
+			for _, asn := range seg.ASNs {
+				if skip > 0 {
+					skip--
+					continue
+				}
+				if !seen[asn] {
+					seen[asn] = true
+					set = append(set, asn)
+				}
+			}
+		}
+	}
+	if len(set) > 0 {
+		sort.Slice(set, func(i, j int) bool {
+			return set[i] < set[j]
+		})
+		agg.ASPath = append(agg.ASPath, corebgp.ASPathSegment{
+			Type: corebgp.ASPathSegmentSet,
+			ASNs: set,
+		})
+	}
+	return agg
+}
+
+// leadingSequence returns the ASNs of the AS_SEQUENCE segments leading p.
+func leadingSequence(p corebgp.ASPath) []uint32 {
+	var asns []uint32
+	for _, seg := range p {
+		if seg.Type != corebgp.ASPathSegmentSequence {
+			break
+		}
+		asns = append(asns, seg.ASNs...)
+	}
+	return asns
+}
+
+func equalPaths(a, b corebgp.ASPath) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || len(a[i].ASNs) != len(b[i].ASNs) {
+			return false
+		}
+		for j := range a[i].ASNs {
+			if a[i].ASNs[j] != b[i].ASNs[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Attrs returns the encoded ORIGIN, AS_PATH, ATOMIC_AGGREGATE and AGGREGATOR
+// attributes of the aggregate, in ascending order of type, for a session
+// that did or did not negotiate four-octet AS numbers. In the latter case
+// AS4_PATH and AS4_AGGREGATOR are included as required by RFC6793. The
+// NEXT_HOP, MP_REACH_NLRI and any further attributes are up to the caller.
+func (agg *Aggregate) Attrs(fourOctetAS bool) ([]byte, error) {
+	if agg.AggregatorID == nil {
+		return nil, errors.New("aggregator id is not an IPv4 address")
+	}
+	b, err := corebgp.AppendPathAttr(nil, corebgp.AttrFlagTransitive,
+		corebgp.AttrTypeOrigin, []byte{agg.Origin})
+	if err != nil {
+		return nil, err
+	}
+	path, err := agg.ASPath.Encode(fourOctetAS)
+	if err != nil {
+		return nil, err
+	}
+	b, err = corebgp.AppendPathAttr(b, corebgp.AttrFlagTransitive,
+		corebgp.AttrTypeASPath, path)
+	if err != nil {
+		return nil, err
+	}
+	if agg.AtomicAggregate {
+		b, err = corebgp.AppendPathAttr(b, corebgp.AttrFlagTransitive,
+			corebgp.AttrTypeAtomicAggregate, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	aggregator := make([]byte, 0, 8)
+	as := agg.AggregatorAS
+	if fourOctetAS {
+		aggregator = append(aggregator, uint8(as>>24), uint8(as>>16))
+	} else if as > math.MaxUint16 {
+		as = asTrans
+	}
+	aggregator = append(aggregator, uint8(as>>8), uint8(as))
+	aggregator = append(aggregator, agg.AggregatorID...)
+	b, err = corebgp.AppendPathAttr(b,
+		corebgp.AttrFlagOptional|corebgp.AttrFlagTransitive,
+		corebgp.AttrTypeAggregator, aggregator)
+	if err != nil || fourOctetAS {
+		return b, err
+	}
+
+	// https://tools.ietf.org/html/rfc6793#section-4.2.2
+	if hasFourOctetASN(agg.ASPath) {
+		as4Path, err := agg.ASPath.Encode(true)
+		if err != nil {
+			return nil, err
+		}
+		b, err = corebgp.AppendPathAttr(b,
+			corebgp.AttrFlagOptional|corebgp.AttrFlagTransitive,
+			corebgp.AttrTypeAS4Path, as4Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if agg.AggregatorAS > math.MaxUint16 {
+		as := agg.AggregatorAS
+		as4Aggregator := []byte{uint8(as >> 24), uint8(as >> 16),
+			uint8(as >> 8), uint8(as)}
+		b, err = corebgp.AppendPathAttr(b,
+			corebgp.AttrFlagOptional|corebgp.AttrFlagTransitive,
+			corebgp.AttrTypeAS4Aggregator,
+			append(as4Aggregator, agg.AggregatorID...))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func hasFourOctetASN(p corebgp.ASPath) bool {
+	for _, seg := range p {
+		for _, asn := range seg.ASNs {
+			if asn > math.MaxUint16 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Suppressed returns true if prefix is a contributing route of an aggregate
+// of aggregates added with SummaryOnly, in which case it must not be
+// advertised, or must be withdrawn if it was.
+func Suppressed(aggregates []Aggregate, prefix *net.IPNet) bool {
+	for _, agg := range aggregates {
+		if !agg.SummaryOnly {
+			continue
+		}
+		for _, c := range agg.Contributors {
+			if c.String() == prefix.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
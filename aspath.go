@@ -0,0 +1,255 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// AS_PATH segment type values
+// https://tools.ietf.org/html/rfc4271#section-4.3
+// https://tools.ietf.org/html/rfc5065#section-3
+const (
+	ASPathSegmentSet            uint8 = 1
+	ASPathSegmentSequence       uint8 = 2
+	ASPathSegmentConfedSequence uint8 = 3
+	ASPathSegmentConfedSet      uint8 = 4
+)
+
+// ASPathSegment is a segment of an AS_PATH.
+type ASPathSegment struct {
+	Type uint8
+	ASNs []uint32
+}
+
+// ASPath is the decoded value of an AS_PATH or AS4_PATH attribute.
+type ASPath []ASPathSegment
+
+// DecodeASPath decodes the value of an AS_PATH attribute. fourOctet should be
+// true if the four-octet AS number capability was negotiated, or if decoding
+// an AS4_PATH attribute.
+func DecodeASPath(b []byte, fourOctet bool) (ASPath, error) {
+	asnLen := 2
+	if fourOctet {
+		asnLen = 4
+	}
+	path := make(ASPath, 0)
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, errors.New("malformed as path segment header")
+		}
+		segType, segLen := b[0], int(b[1])
+		if segType < ASPathSegmentSet || segType > ASPathSegmentConfedSet {
+			return nil, fmt.Errorf("invalid as path segment type: %d",
+				segType)
+		}
+		if segLen == 0 || len(b) < 2+segLen*asnLen {
+			return nil, errors.New("malformed as path segment")
+		}
+		seg := ASPathSegment{
+			Type: segType,
+			ASNs: make([]uint32, segLen),
+		}
+		for i := range seg.ASNs {
+			if fourOctet {
+				seg.ASNs[i] = binary.BigEndian.Uint32(b[2+i*4:])
+			} else {
+				seg.ASNs[i] = uint32(binary.BigEndian.Uint16(b[2+i*2:]))
+			}
+		}
+		path = append(path, seg)
+		b = b[2+segLen*asnLen:]
+	}
+	return path, nil
+}
+
+// Encode encodes the path as the value of an AS_PATH attribute. When
+// fourOctet is false ASNs that do not fit in two octets are replaced with
+// AS_TRANS. Segments longer than 255 ASNs are split.
+func (p ASPath) Encode(fourOctet bool) ([]byte, error) {
+	b := make([]byte, 0)
+	for _, seg := range p {
+		if len(seg.ASNs) == 0 {
+			return nil, errors.New("empty as path segment")
+		}
+		asns := seg.ASNs
+		for len(asns) > 0 {
+			n := len(asns)
+			if n > math.MaxUint8 {
+				n = math.MaxUint8
+			}
+			b = append(b, seg.Type, uint8(n))
+			for _, asn := range asns[:n] {
+				if fourOctet {
+					b = append(b, uint8(asn>>24), uint8(asn>>16),
+						uint8(asn>>8), uint8(asn))
+					continue
+				}
+				if asn > math.MaxUint16 {
+					asn = uint32(asTrans)
+				}
+				b = append(b, uint8(asn>>8), uint8(asn))
+			}
+			asns = asns[n:]
+		}
+	}
+	return b, nil
+}
+
+// String returns the path in the conventional format, e.g.
+// "65001 {65002 65003} (65004)".
+func (p ASPath) String() string {
+	var sb strings.Builder
+	for i, seg := range p {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		var openDelim, closeDelim string
+		switch seg.Type {
+		case ASPathSegmentSet:
+			openDelim, closeDelim = "{", "}"
+		case ASPathSegmentConfedSequence:
+			openDelim, closeDelim = "(", ")"
+		case ASPathSegmentConfedSet:
+			openDelim, closeDelim = "[", "]"
+		}
+		sb.WriteString(openDelim)
+		for j, asn := range seg.ASNs {
+			if j > 0 {
+				sb.WriteByte(' ')
+			}
+			fmt.Fprintf(&sb, "%d", asn)
+		}
+		sb.WriteString(closeDelim)
+	}
+	return sb.String()
+}
+
+// Clone returns a deep copy of the path.
+func (p ASPath) Clone() ASPath {
+	c := make(ASPath, len(p))
+	for i, seg := range p {
+		c[i] = ASPathSegment{
+			Type: seg.Type,
+			ASNs: append([]uint32(nil), seg.ASNs...),
+		}
+	}
+	return c
+}
+
+// Prepend returns a copy of the path with asn prepended count times.
+func (p ASPath) Prepend(asn uint32, count int) ASPath {
+	c := p.Clone()
+	if count <= 0 {
+		return c
+	}
+	prepend := make([]uint32, count)
+	for i := range prepend {
+		prepend[i] = asn
+	}
+	if len(c) > 0 && c[0].Type == ASPathSegmentSequence {
+		c[0].ASNs = append(prepend, c[0].ASNs...)
+		return c
+	}
+	return append(ASPath{{
+		Type: ASPathSegmentSequence,
+		ASNs: prepend,
+	}}, c...)
+}
+
+// Length returns the path length used in route selection: an AS_SET counts
+// as 1 and confederation segments are not counted.
+// https://tools.ietf.org/html/rfc4271#section-9.1.2.2
+// https://tools.ietf.org/html/rfc5065#section-5.3
+func (p ASPath) Length() int {
+	length := 0
+	for _, seg := range p {
+		switch seg.Type {
+		case ASPathSegmentSequence:
+			length += len(seg.ASNs)
+		case ASPathSegmentSet:
+			length++
+		}
+	}
+	return length
+}
+
+// CountASN returns the number of occurrences of asn in the path.
+func (p ASPath) CountASN(asn uint32) int {
+	count := 0
+	for _, seg := range p {
+		for _, a := range seg.ASNs {
+			if a == asn {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// HasLoop returns true if localAS occurs in the path more than allowASIn
+// times. An allowASIn of zero rejects any occurrence.
+func (p ASPath) HasLoop(localAS uint32, allowASIn int) bool {
+	return p.CountASN(localAS) > allowASIn
+}
+
+// OriginASN returns the origin AS of the path, i.e. the last ASN of the
+// final AS_SEQUENCE segment. ok is false if the path is empty or ends with an
+// AS_SET.
+func (p ASPath) OriginASN() (asn uint32, ok bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		switch p[i].Type {
+		case ASPathSegmentSequence:
+			return p[i].ASNs[len(p[i].ASNs)-1], true
+		case ASPathSegmentSet:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// IsPrivateASN returns true if asn is reserved for private use.
+// https://tools.ietf.org/html/rfc6996#section-5
+func IsPrivateASN(asn uint32) bool {
+	return (asn >= 64512 && asn <= 65534) ||
+		(asn >= 4200000000 && asn <= 4294967294)
+}
+
+// PrivateASMode determines how RemovePrivateASNs handles private ASNs.
+type PrivateASMode uint8
+
+// PrivateASMode values
+const (
+	// PrivateASStrip removes private ASNs.
+	PrivateASStrip PrivateASMode = iota
+	// PrivateASReplace replaces private ASNs with another ASN, preserving
+	// path length.
+	PrivateASReplace
+)
+
+// RemovePrivateASNs returns a copy of the path with private ASNs removed or
+// replaced with replaceWith according to mode. Segments left empty are
+// removed.
+func (p ASPath) RemovePrivateASNs(mode PrivateASMode,
+	replaceWith uint32) ASPath {
+	c := make(ASPath, 0, len(p))
+	for _, seg := range p {
+		asns := make([]uint32, 0, len(seg.ASNs))
+		for _, asn := range seg.ASNs {
+			if !IsPrivateASN(asn) {
+				asns = append(asns, asn)
+			} else if mode == PrivateASReplace {
+				asns = append(asns, replaceWith)
+			}
+		}
+		if len(asns) > 0 {
+			c = append(c, ASPathSegment{
+				Type: seg.Type,
+				ASNs: asns,
+			})
+		}
+	}
+	return c
+}
@@ -0,0 +1,134 @@
+package corebgp
+
+import (
+	"errors"
+)
+
+// ReplaceASN returns a copy of the path with all occurrences of oldASN
+// replaced with newASN.
+func (p ASPath) ReplaceASN(oldASN, newASN uint32) ASPath {
+	c := p.Clone()
+	for _, seg := range c {
+		for i, asn := range seg.ASNs {
+			if asn == oldASN {
+				seg.ASNs[i] = newASN
+			}
+		}
+	}
+	return c
+}
+
+// asOverride returns a copy of update with occurrences of peerAS in the
+// AS_PATH and AS4_PATH attributes replaced with localAS. update is returned
+// unmodified if it contains no such occurrences.
+func asOverride(update []byte, fourOctetAS bool, peerAS,
+	localAS uint32) ([]byte, error) {
+	withdrawn, attrs, nlri, err := splitUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	newAttrs := make([]byte, 0, len(attrs))
+	modified := false
+	var pathErr error
+	err = rangePathAttrs(attrs, func(flags AttrFlags, attrType uint8,
+		value, raw []byte) bool {
+		if attrType != AttrTypeASPath && attrType != AttrTypeAS4Path {
+			newAttrs = append(newAttrs, raw...)
+			return true
+		}
+		pathFourOctet := fourOctetAS || attrType == AttrTypeAS4Path
+		path, err := DecodeASPath(value, pathFourOctet)
+		if err != nil {
+			pathErr = err
+			return false
+		}
+		if path.CountASN(peerAS) == 0 {
+			newAttrs = append(newAttrs, raw...)
+			return true
+		}
+		modified = true
+		encoded, err := path.ReplaceASN(peerAS, localAS).Encode(pathFourOctet)
+		if err == nil {
+			newAttrs, err = AppendPathAttr(newAttrs, flags, attrType, encoded)
+		}
+		if err != nil {
+			pathErr = err
+			return false
+		}
+		return true
+	})
+	if err == nil {
+		err = pathErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !modified {
+		return update, nil
+	}
+	return joinUpdate(withdrawn, newAttrs, nlri), nil
+}
+
+// asLoopCheck checks the AS_PATH and AS4_PATH attributes of a received update
+// for more than allowASIn occurrences of localAS. If the limit is exceeded the
+// update is treated as a withdrawal (RFC7606) of the NLRI it carries, which
+// may require more than one update. Otherwise update is returned as is.
+//
+// An update with a malformed AS_PATH attribute is treated as a withdrawal as
+// well, in which case malformed is true, and no updates are returned if it
+// neither carries NLRI nor withdraws routes. A malformed AS4_PATH attribute
+// is ignored.
+// https://tools.ietf.org/html/rfc7606#section-7.2
+// https://tools.ietf.org/html/rfc6793#section-6
+func asLoopCheck(update []byte, fourOctetAS bool, localAS uint32,
+	allowASIn int) (updates [][]byte, malformed bool, err error) {
+	_, attrs, _, err := splitUpdate(update)
+	if err != nil {
+		return nil, false, err
+	}
+	var loop bool
+	err = rangePathAttrs(attrs, func(flags AttrFlags, attrType uint8,
+		value, raw []byte) bool {
+		switch attrType {
+		case AttrTypeASPath, AttrTypeAS4Path:
+			path, err := DecodeASPath(value,
+				fourOctetAS || attrType == AttrTypeAS4Path)
+			if err != nil {
+				malformed = attrType == AttrTypeASPath
+				return !malformed
+			}
+			if path.HasLoop(localAS, allowASIn) {
+				loop = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if !loop && !malformed {
+		return [][]byte{update}, false, nil
+	}
+	withdrawals, err := treatAsWithdraw(update)
+	if err != nil {
+		return nil, false, err
+	}
+	if withdrawals == nil && !malformed {
+		return [][]byte{update}, false, nil
+	}
+	return withdrawals, malformed, nil
+}
+
+// validateASPathOptions validates allowas-in and as-override options against
+// the peer config.
+func validateASPathOptions(config *PeerConfig, o *peerOptions) error {
+	if o.allowASIn < 0 {
+		return errors.New("allowas-in count must be >= 0")
+	}
+	// a RemoteAS of zero (AnyRemoteAS) is checked once the session is
+	// established
+	if o.asOverride && config.LocalAS == config.RemoteAS {
+		return errors.New("as-override requires an eBGP peer")
+	}
+	return nil
+}
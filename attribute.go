@@ -0,0 +1,188 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Path attribute type code values
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#bgp-parameters-2
+const (
+	AttrTypeOrigin                uint8 = 1
+	AttrTypeASPath                uint8 = 2
+	AttrTypeNextHop               uint8 = 3
+	AttrTypeMED                   uint8 = 4
+	AttrTypeLocalPref             uint8 = 5
+	AttrTypeAtomicAggregate       uint8 = 6
+	AttrTypeAggregator            uint8 = 7
+	AttrTypeCommunities           uint8 = 8
+	AttrTypeOriginatorID          uint8 = 9
+	AttrTypeClusterList           uint8 = 10
+	AttrTypeMPReachNLRI           uint8 = 14
+	AttrTypeMPUnreachNLRI         uint8 = 15
+	AttrTypeExtendedCommunities   uint8 = 16
+	AttrTypeAS4Path               uint8 = 17
+	AttrTypeAS4Aggregator         uint8 = 18
+	AttrTypePMSITunnel            uint8 = 22
+	AttrTypeTunnelEncap           uint8 = 23
+	AttrTypeTrafficEngineering    uint8 = 24
+	AttrTypeIPv6ExtCommunities    uint8 = 25
+	AttrTypeAIGP                  uint8 = 26
+	AttrTypePEDistinguisherLabels uint8 = 27
+	AttrTypeBGPLS                 uint8 = 29
+	AttrTypeLargeCommunities      uint8 = 32
+	AttrTypeBGPsecPath            uint8 = 33
+	AttrTypeOnlyToCustomer        uint8 = 35
+	AttrTypeSFP                   uint8 = 37
+	AttrTypeBFDDiscriminator      uint8 = 38
+	AttrTypePrefixSID             uint8 = 40
+	AttrTypeAttrSet               uint8 = 128
+)
+
+// ORIGIN attribute values
+// https://tools.ietf.org/html/rfc4271#section-5.1.1
+const (
+	OriginIGP        uint8 = 0
+	OriginEGP        uint8 = 1
+	OriginIncomplete uint8 = 2
+)
+
+// AttrFlags is a path attribute flags octet.
+type AttrFlags uint8
+
+// AttrFlags bit values
+const (
+	AttrFlagOptional       AttrFlags = 0x80
+	AttrFlagTransitive     AttrFlags = 0x40
+	AttrFlagPartial        AttrFlags = 0x20
+	AttrFlagExtendedLength AttrFlags = 0x10
+)
+
+// Optional returns true if the Optional bit is set.
+func (f AttrFlags) Optional() bool {
+	return f&AttrFlagOptional != 0
+}
+
+// Transitive returns true if the Transitive bit is set.
+func (f AttrFlags) Transitive() bool {
+	return f&AttrFlagTransitive != 0
+}
+
+// Partial returns true if the Partial bit is set.
+func (f AttrFlags) Partial() bool {
+	return f&AttrFlagPartial != 0
+}
+
+// ExtendedLength returns true if the Extended Length bit is set.
+func (f AttrFlags) ExtendedLength() bool {
+	return f&AttrFlagExtendedLength != 0
+}
+
+// String returns the set flags, e.g. "optional|transitive".
+func (f AttrFlags) String() string {
+	var s []string
+	if f.Optional() {
+		s = append(s, "optional")
+	} else {
+		s = append(s, "well-known")
+	}
+	if f.Transitive() {
+		s = append(s, "transitive")
+	}
+	if f.Partial() {
+		s = append(s, "partial")
+	}
+	if f.ExtendedLength() {
+		s = append(s, "extended-length")
+	}
+	return strings.Join(s, "|")
+}
+
+var (
+	// the Optional and Transitive bits for known attribute types
+	attrTypeFlags = map[uint8]AttrFlags{
+		AttrTypeOrigin:                AttrFlagTransitive,
+		AttrTypeASPath:                AttrFlagTransitive,
+		AttrTypeNextHop:               AttrFlagTransitive,
+		AttrTypeMED:                   AttrFlagOptional,
+		AttrTypeLocalPref:             AttrFlagTransitive,
+		AttrTypeAtomicAggregate:       AttrFlagTransitive,
+		AttrTypeAggregator:            AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeCommunities:           AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeOriginatorID:          AttrFlagOptional,
+		AttrTypeClusterList:           AttrFlagOptional,
+		AttrTypeMPReachNLRI:           AttrFlagOptional,
+		AttrTypeMPUnreachNLRI:         AttrFlagOptional,
+		AttrTypeExtendedCommunities:   AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeAS4Path:               AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeAS4Aggregator:         AttrFlagOptional | AttrFlagTransitive,
+		AttrTypePMSITunnel:            AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeTunnelEncap:           AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeTrafficEngineering:    AttrFlagOptional,
+		AttrTypeIPv6ExtCommunities:    AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeAIGP:                  AttrFlagOptional,
+		AttrTypePEDistinguisherLabels: AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeBGPLS:                 AttrFlagOptional,
+		AttrTypeLargeCommunities:      AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeBGPsecPath:            AttrFlagOptional,
+		AttrTypeOnlyToCustomer:        AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeSFP:                   AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeBFDDiscriminator:      AttrFlagOptional,
+		AttrTypePrefixSID:             AttrFlagOptional | AttrFlagTransitive,
+		AttrTypeAttrSet:               AttrFlagOptional | AttrFlagTransitive,
+	}
+)
+
+// DefaultAttrFlags returns the Optional and Transitive bits defined for a
+// known attribute type. ok is false if the attribute type is unknown.
+func DefaultAttrFlags(attrType uint8) (flags AttrFlags, ok bool) {
+	flags, ok = attrTypeFlags[attrType]
+	return flags, ok
+}
+
+// ValidateAttrFlags checks the flags of a received attribute against
+// RFC4271 section 5 and, for known attribute types, against the Optional and
+// Transitive bits defined for the type. The lower four unused bits are
+// ignored as required by RFC4271.
+func ValidateAttrFlags(attrType uint8, flags AttrFlags) error {
+	if !flags.Optional() && !flags.Transitive() {
+		return errors.New("well-known attribute must be transitive")
+	}
+	if flags.Partial() && (!flags.Optional() || !flags.Transitive()) {
+		return errors.New("partial bit must only be set for optional " +
+			"transitive attributes")
+	}
+	want, ok := attrTypeFlags[attrType]
+	if !ok {
+		return nil
+	}
+	mask := AttrFlagOptional | AttrFlagTransitive
+	if flags&mask != want {
+		return fmt.Errorf("attribute type %d flags %s, expected %s", attrType,
+			flags&mask, want)
+	}
+	return nil
+}
+
+// AppendPathAttr appends an encoded path attribute to b and returns the
+// extended buffer. The Extended Length bit is set if, and only if, value
+// does not fit in a single length octet; the lower four unused bits of flags
+// are cleared.
+func AppendPathAttr(b []byte, flags AttrFlags, attrType uint8,
+	value []byte) ([]byte, error) {
+	if len(value) > math.MaxUint16 {
+		return b, fmt.Errorf("attribute type %d value too long: %d",
+			attrType, len(value))
+	}
+	flags &= AttrFlagOptional | AttrFlagTransitive | AttrFlagPartial
+	if len(value) > math.MaxUint8 {
+		flags |= AttrFlagExtendedLength
+		b = append(b, uint8(flags), attrType, uint8(len(value)>>8),
+			uint8(len(value)))
+	} else {
+		b = append(b, uint8(flags), attrType, uint8(len(value)))
+	}
+	return append(b, value...), nil
+}
@@ -0,0 +1,106 @@
+package corebgp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// AttrErrorHandling is the RFC7606 error handling of a malformed path
+// attribute.
+type AttrErrorHandling uint8
+
+// AttrErrorHandling values
+// https://tools.ietf.org/html/rfc7606#section-2
+const (
+	// AttrErrorTreatAsWithdraw treats the UPDATE as a withdrawal of its NLRI.
+	AttrErrorTreatAsWithdraw AttrErrorHandling = iota
+	// AttrErrorDiscard discards the attribute.
+	AttrErrorDiscard
+	// AttrErrorSessionReset resets the session with an Optional Attribute
+	// Error NOTIFICATION.
+	AttrErrorSessionReset
+)
+
+func (h AttrErrorHandling) String() string {
+	switch h {
+	case AttrErrorTreatAsWithdraw:
+		return "treat-as-withdraw"
+	case AttrErrorDiscard:
+		return "attribute-discard"
+	case AttrErrorSessionReset:
+		return "session-reset"
+	}
+	return "unknown"
+}
+
+// PathAttrType describes a vendor or draft path attribute registered via
+// RegisterPathAttr.
+type PathAttrType struct {
+	// Flags are the Optional and Transitive bits of the attribute, validated
+	// as by ValidateAttrFlags. They are ignored for attribute types known to
+	// DefaultAttrFlags.
+	Flags AttrFlags
+	// Decode decodes the value of the attribute received from a peer with
+	// codec, returning an error if it is malformed. The value must not be
+	// retained unless copied.
+	Decode func(value []byte, codec *Codec) (interface{}, error)
+	// ErrorHandling is the handling of attributes with invalid flags or a
+	// value Decode fails on.
+	ErrorHandling AttrErrorHandling
+}
+
+var (
+	attrRegistryMu sync.RWMutex
+	attrRegistry   = make(map[uint8]PathAttrType)
+)
+
+// RegisterPathAttr registers the path attribute type of attrType, so that a
+// DecodedUpdate carries its decoded value in PathAttr.Decoded and malformed
+// attributes are handled according to t.ErrorHandling. Attribute types
+// validated by corebgp itself, and types already registered, cannot be
+// registered. It is typically called from an init function.
+func RegisterPathAttr(attrType uint8, t PathAttrType) error {
+	if t.Decode == nil {
+		return fmt.Errorf("attribute type %d decoder must not be nil",
+			attrType)
+	}
+	if validatedAttrTypes[attrType] {
+		return fmt.Errorf("attribute type %d is validated by corebgp",
+			attrType)
+	}
+	attrRegistryMu.Lock()
+	defer attrRegistryMu.Unlock()
+	if _, exists := attrRegistry[attrType]; exists {
+		return fmt.Errorf("attribute type %d already registered", attrType)
+	}
+	attrRegistry[attrType] = t
+	return nil
+}
+
+func lookupPathAttrType(attrType uint8) (PathAttrType, bool) {
+	attrRegistryMu.RLock()
+	defer attrRegistryMu.RUnlock()
+	t, ok := attrRegistry[attrType]
+	return t, ok
+}
+
+// validatedAttrTypes are the attribute types checked by
+// updateDecoder.checkAttr, or otherwise decoded by corebgp.
+var validatedAttrTypes = map[uint8]bool{
+	AttrTypeOrigin:              true,
+	AttrTypeASPath:              true,
+	AttrTypeNextHop:             true,
+	AttrTypeMED:                 true,
+	AttrTypeLocalPref:           true,
+	AttrTypeAtomicAggregate:     true,
+	AttrTypeAggregator:          true,
+	AttrTypeCommunities:         true,
+	AttrTypeOriginatorID:        true,
+	AttrTypeClusterList:         true,
+	AttrTypeMPReachNLRI:         true,
+	AttrTypeMPUnreachNLRI:       true,
+	AttrTypeExtendedCommunities: true,
+	AttrTypeAS4Path:             true,
+	AttrTypeAS4Aggregator:       true,
+	AttrTypeLargeCommunities:    true,
+}
@@ -0,0 +1,276 @@
+// Package attrset interns the path attributes of routes so that routes
+// sharing the same attributes share a single decoded Set. A RIB holding
+// millions of routes typically sees far fewer distinct attribute sets, so
+// storing a *Set per route rather than a copy of its attributes reduces
+// memory use substantially.
+//
+//	t := attrset.NewTable()
+//	s, err := t.InternUpdate(update)
+//	...
+//	// once no route references s any longer
+//	t.Release(s)
+//
+// Sets are keyed by a canonical encoding of their attributes, so sets
+// differing only in attribute order or the use of the Extended Length flag
+// are interned as the same Set.
+package attrset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/jwhited/corebgp"
+)
+
+// shards is the number of independently locked shards of a Table.
+const shards = 64
+
+// Set is an interned, immutable set of path attributes. It must not be
+// modified.
+type Set struct {
+	attrs    []corebgp.PathAttr
+	encoding []byte
+	hash     uint64
+	refs     int
+}
+
+// Attrs returns the path attributes of the Set, ordered by type.
+func (s *Set) Attrs() []corebgp.PathAttr {
+	return s.attrs
+}
+
+// Attr returns the path attribute of type attrType.
+func (s *Set) Attr(attrType uint8) (corebgp.PathAttr, bool) {
+	i := sort.Search(len(s.attrs), func(i int) bool {
+		return s.attrs[i].Type >= attrType
+	})
+	if i < len(s.attrs) && s.attrs[i].Type == attrType {
+		return s.attrs[i], true
+	}
+	return corebgp.PathAttr{}, false
+}
+
+// Encoding returns the canonical encoding of the Set, which is a valid path
+// attributes field of an UPDATE message.
+func (s *Set) Encoding() []byte {
+	return s.encoding
+}
+
+// shard holds the Sets whose hash maps to it, keyed by hash. Sets with
+// colliding hashes share a bucket.
+type shard struct {
+	mu   sync.Mutex
+	sets map[uint64][]*Set
+	len  int
+	refs int
+}
+
+// Table interns Sets. It is safe for concurrent use.
+type Table struct {
+	shards [shards]shard
+}
+
+// NewTable returns a new, empty Table.
+func NewTable() *Table {
+	t := &Table{}
+	for i := range t.shards {
+		t.shards[i].sets = make(map[uint64][]*Set)
+	}
+	return t
+}
+
+// Stats are counters of a Table.
+type Stats struct {
+	// Sets is the number of distinct Sets interned.
+	Sets int
+	// Refs is the number of references held to Sets, i.e. the number of
+	// Intern calls not yet matched by a Release.
+	Refs int
+}
+
+// Stats returns the counters of the Table.
+func (t *Table) Stats() Stats {
+	var s Stats
+	for i := range t.shards {
+		sh := &t.shards[i]
+		sh.mu.Lock()
+		s.Sets += sh.len
+		s.Refs += sh.refs
+		sh.mu.Unlock()
+	}
+	return s
+}
+
+// canonicalize returns the canonical encoding of the path attributes field
+// attrs. Attributes are ordered by type, with flags normalized by
+// corebgp.AppendPathAttr. The NLRI of MP_REACH_NLRI is removed, leaving its
+// AFI/SAFI and next hop, and MP_UNREACH_NLRI is omitted, as they carry per
+// route information. An error is returned for malformed or duplicate
+// attributes.
+func canonicalize(attrs []byte) ([]byte, error) {
+	if len(attrs) > math.MaxUint16 {
+		return nil, errors.New("path attributes too long")
+	}
+	var sorted []corebgp.PathAttr
+	it := corebgp.NewPathAttrIterator(wrapAttrs(attrs))
+	for it.Next() {
+		a := corebgp.PathAttr{
+			Flags: it.Flags(),
+			Type:  it.Type(),
+			Value: it.Value(),
+		}
+		switch a.Type {
+		case corebgp.AttrTypeMPUnreachNLRI:
+			continue
+		case corebgp.AttrTypeMPReachNLRI:
+			v := a.Value
+			// https://tools.ietf.org/html/rfc4760#section-3
+			if len(v) < 5 || len(v) < 5+int(v[3]) {
+				return nil, errors.New("malformed MP_REACH_NLRI attribute")
+			}
+			a.Value = v[:5+int(v[3])]
+		}
+		sorted = append(sorted, a)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Type < sorted[j].Type
+	})
+	b := make([]byte, 0, len(attrs))
+	for i, a := range sorted {
+		if i > 0 && sorted[i-1].Type == a.Type {
+			return nil, errors.New("duplicate path attribute")
+		}
+		var err error
+		b, err = corebgp.AppendPathAttr(b, a.Flags, a.Type, a.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// wrapAttrs returns an otherwise empty UPDATE message body carrying the path
+// attributes field attrs, for iteration via corebgp.PathAttrIterator.
+func wrapAttrs(attrs []byte) []byte {
+	b := make([]byte, 4, 4+len(attrs))
+	binary.BigEndian.PutUint16(b[2:], uint16(len(attrs)))
+	return append(b, attrs...)
+}
+
+func newSet(encoding []byte, hash uint64) *Set {
+	// the attribute values reference the wrapped copy of encoding, which is
+	// retained in its place
+	b := wrapAttrs(encoding)
+	s := &Set{
+		encoding: b[4:],
+		hash:     hash,
+	}
+	it := corebgp.NewPathAttrIterator(b)
+	for it.Next() {
+		s.attrs = append(s.attrs, corebgp.PathAttr{
+			Flags: it.Flags(),
+			Type:  it.Type(),
+			Value: it.Value(),
+		})
+	}
+	return s
+}
+
+func hashEncoding(encoding []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(encoding)
+	return h.Sum64()
+}
+
+// Intern returns the Set for the path attributes field attrs of an UPDATE
+// message, adding a reference to it. attrs is not retained.
+func (t *Table) Intern(attrs []byte) (*Set, error) {
+	encoding, err := canonicalize(attrs)
+	if err != nil {
+		return nil, err
+	}
+	hash := hashEncoding(encoding)
+	sh := &t.shards[hash%shards]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for _, s := range sh.sets[hash] {
+		if bytes.Equal(s.encoding, encoding) {
+			s.refs++
+			sh.refs++
+			return s, nil
+		}
+	}
+	s := newSet(encoding, hash)
+	s.refs++
+	sh.refs++
+	sh.sets[hash] = append(sh.sets[hash], s)
+	sh.len++
+	return s, nil
+}
+
+// InternUpdate returns the Set for the path attributes of the UPDATE message
+// body update, adding a reference to it. update is not retained.
+func (t *Table) InternUpdate(update []byte) (*Set, error) {
+	if len(update) < 4 {
+		return nil, errors.New("update message too short")
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(update))
+	if len(update) < 4+withdrawnLen {
+		return nil, errors.New("invalid withdrawn routes length")
+	}
+	b := update[2+withdrawnLen:]
+	attrsLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+attrsLen {
+		return nil, errors.New("invalid total path attribute length")
+	}
+	return t.Intern(b[2 : 2+attrsLen])
+}
+
+// Retain adds a reference to s, e.g. when a route holding s is copied.
+func (t *Table) Retain(s *Set) {
+	sh := &t.shards[s.hash%shards]
+	sh.mu.Lock()
+	s.refs++
+	sh.refs++
+	sh.mu.Unlock()
+}
+
+// Release removes a reference to s previously added by Intern,
+// InternUpdate or Retain. s is removed from the Table once no references
+// remain, after which it remains valid for holders of it.
+func (t *Table) Release(s *Set) {
+	sh := &t.shards[s.hash%shards]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if s.refs == 0 {
+		return
+	}
+	s.refs--
+	sh.refs--
+	if s.refs > 0 {
+		return
+	}
+	bucket := sh.sets[s.hash]
+	for i, c := range bucket {
+		if c == s {
+			bucket[i] = bucket[len(bucket)-1]
+			bucket[len(bucket)-1] = nil
+			bucket = bucket[:len(bucket)-1]
+			sh.len--
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(sh.sets, s.hash)
+	} else {
+		sh.sets[s.hash] = bucket
+	}
+}
@@ -0,0 +1,195 @@
+//go:build go1.18
+// +build go1.18
+
+// Package bench provides workloads measuring the performance of corebgp's
+// UPDATE message codec and write paths over realistic corpora, so that
+// regressions are measurable from release to release.
+//
+// Each workload processes a whole Corpus, by default a synthetic full table
+// or the MRT file named by the COREBGP_BENCH_MRT environment variable, see
+// Default. The workloads are run by the benchmarks of the package:
+//
+//	go test -bench . -benchmem ./bench
+//	COREBGP_BENCH_MRT=rib.20240101.0000.gz go test -bench . ./bench
+//
+// and by Profile, which runs a workload for a duration while writing CPU and
+// heap profiles, e.g. as done by cmd/corebgpbench.
+package bench
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Workload processes a corpus once, returning the number of items, e.g.
+// prefixes or messages, it processed.
+type Workload func(c *Corpus) (int, error)
+
+// Workloads are the workloads of the package by name.
+var Workloads = map[string]Workload{
+	"decode": Decode,
+	"encode": Encode,
+	"pack":   Pack,
+	"write":  Write,
+}
+
+// Decode decodes the updates of c via corebgp.DecodeUpdate, returning the
+// number of prefixes decoded.
+func Decode(c *Corpus) (int, error) {
+	var n int
+	for _, b := range c.Updates {
+		u, err := corebgp.DecodeUpdate(b, c.Codec)
+		if err != nil {
+			return n, err
+		}
+		n += countPrefixes(u)
+	}
+	return n, nil
+}
+
+// countPrefixes returns the number of prefixes advertised and withdrawn by u.
+func countPrefixes(u *corebgp.DecodedUpdate) int {
+	n := len(u.Withdrawn) + len(u.NLRI)
+	if u.MPReach != nil {
+		n += len(u.MPReach.NLRI)
+	}
+	for _, m := range u.MPUnreach {
+		n += len(m.NLRI)
+	}
+	return n
+}
+
+// Encode encodes the decoded updates of c via DecodedUpdate.Encode,
+// returning the number of updates encoded. Updates are decoded once per
+// corpus, beforehand.
+func Encode(c *Corpus) (int, error) {
+	if err := c.prepare(); err != nil {
+		return 0, err
+	}
+	for i, u := range c.decoded {
+		if _, err := u.Encode(c.Codec); err != nil {
+			return i, err
+		}
+	}
+	return len(c.decoded), nil
+}
+
+// Pack packs the prefixes of c into as few UPDATE messages per AFI/SAFI as
+// possible via corebgp.NewWithdrawals, returning the number of prefixes
+// packed.
+func Pack(c *Corpus) (int, error) {
+	if err := c.prepare(); err != nil {
+		return 0, err
+	}
+	var n int
+	for f, prefixes := range c.prefixes {
+		_, err := corebgp.NewWithdrawals(f.afi, f.safi, c.Codec, prefixes...)
+		if err != nil {
+			return n, err
+		}
+		n += len(prefixes)
+	}
+	return n, nil
+}
+
+// Write writes the updates of c to a loopback TCP connection via a
+// corebgp.MessageWriter, returning the number of messages written. The
+// connection is drained by a separate goroutine.
+func Write(c *Corpus) (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	doneCh := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			doneCh <- err
+			return
+		}
+		defer conn.Close()
+		_, err = io.Copy(io.Discard, conn)
+		doneCh <- err
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+	return writeTo(c, conn, doneCh)
+}
+
+// writeTo writes the updates of c to conn, closing it once done, and waits
+// for the error of the reader draining conn on doneCh.
+func writeTo(c *Corpus, conn io.WriteCloser, doneCh <-chan error) (int,
+	error) {
+	w := corebgp.NewMessageWriter(conn)
+	if c.Codec.ExtendedMessage {
+		w.SetMaxMessageLength(corebgp.ExtendedMaxMessageLength)
+	}
+	for i, b := range c.Updates {
+		if err := w.WriteMessage(corebgp.UpdateMessageType, b); err != nil {
+			conn.Close()
+			return i, err
+		}
+	}
+	if err := conn.Close(); err != nil {
+		return len(c.Updates), err
+	}
+	return len(c.Updates), <-doneCh
+}
+
+var errTreatAsWithdraw = errors.New("update treated as withdraw")
+
+// prepare decodes the updates of c and collects their prefixes, once.
+func (c *Corpus) prepare() error {
+	c.once.Do(func() {
+		c.prefixes = make(map[family][]corebgp.PrefixNLRI)
+		for _, b := range c.Updates {
+			u, err := corebgp.DecodeUpdate(b, c.Codec)
+			if err == nil && u.TreatAsWithdraw {
+				err = errTreatAsWithdraw
+			}
+			if err != nil {
+				c.err = err
+				return
+			}
+			c.decoded = append(c.decoded, u)
+			ipv4 := family{corebgp.AFIIPv4, corebgp.SAFIUnicast}
+			c.addPrefixes(ipv4, u.NLRI)
+			if u.MPReach != nil {
+				c.addPrefixes(family{u.MPReach.AFI, u.MPReach.SAFI},
+					u.MPReach.NLRI)
+			}
+		}
+		for _, prefixes := range c.prefixes {
+			sort.Slice(prefixes, func(i, j int) bool {
+				return prefixes[i].Prefix.Addr().Less(prefixes[j].Prefix.Addr())
+			})
+		}
+	})
+	return c.err
+}
+
+// addPrefixes adds the prefixes of nlri of f to c.prefixes.
+func (c *Corpus) addPrefixes(f family, nlri []corebgp.NLRI) {
+	for _, p := range nlri {
+		addr, ok := netip.AddrFromSlice(p.Prefix.IP)
+		if !ok {
+			continue
+		}
+		if f.afi == corebgp.AFIIPv4 {
+			addr = addr.Unmap()
+		}
+		bits, _ := p.Prefix.Mask.Size()
+		c.prefixes[f] = append(c.prefixes[f], corebgp.PrefixNLRI{
+			PathID: p.PathID,
+			Prefix: netip.PrefixFrom(addr, bits),
+		})
+	}
+}
@@ -0,0 +1,56 @@
+//go:build go1.18
+// +build go1.18
+
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+// benchmarkWorkload runs w over the default corpus b.N times.
+func benchmarkWorkload(b *testing.B, w Workload) {
+	c, err := Default()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err = c.prepare(); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(c.Bytes()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	var n int
+	for i := 0; i < b.N; i++ {
+		if n, err = w(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	b.ReportMetric(float64(n)*float64(b.N)/elapsed.Seconds(), "items/s")
+}
+
+func BenchmarkDecode(b *testing.B) { benchmarkWorkload(b, Decode) }
+
+func BenchmarkEncode(b *testing.B) { benchmarkWorkload(b, Encode) }
+
+func BenchmarkPack(b *testing.B) { benchmarkWorkload(b, Pack) }
+
+func BenchmarkWrite(b *testing.B) { benchmarkWorkload(b, Write) }
+
+func TestSynthetic(t *testing.T) {
+	c := Synthetic(10000, 1)
+	for name, w := range Workloads {
+		n, err := w(c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n == 0 {
+			t.Errorf("%s: no items processed", name)
+		}
+	}
+	if len(c.prefixes) != 2 {
+		t.Errorf("got %d families, want 2", len(c.prefixes))
+	}
+}
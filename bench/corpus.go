@@ -0,0 +1,401 @@
+//go:build go1.18
+// +build go1.18
+
+package bench
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Corpus is a set of UPDATE messages the workloads of the package process.
+type Corpus struct {
+	// Updates contains the UPDATE message bodies, excluding the header.
+	Updates [][]byte
+	// Codec is the Codec the updates are decoded with.
+	Codec *corebgp.Codec
+
+	once     sync.Once
+	decoded  []*corebgp.DecodedUpdate
+	prefixes map[family][]corebgp.PrefixNLRI
+	err      error
+}
+
+// family is an AFI/SAFI.
+type family struct {
+	afi  uint16
+	safi uint8
+}
+
+// Bytes returns the total length of the updates of c, including headers.
+func (c *Corpus) Bytes() int {
+	var n int
+	for _, u := range c.Updates {
+		n += corebgp.HeaderLength + len(u)
+	}
+	return n
+}
+
+// FullTableSize is the number of prefixes of a synthetic full table, roughly
+// that of the IPv4 and IPv6 default-free zone.
+const FullTableSize = 1200000
+
+// corpusEnv is the environment variable naming the MRT file Default loads.
+const corpusEnv = "COREBGP_BENCH_MRT"
+
+var (
+	defaultOnce   sync.Once
+	defaultCorpus *Corpus
+	defaultErr    error
+)
+
+// Default returns the corpus of the MRT file named by the COREBGP_BENCH_MRT
+// environment variable, e.g. a full-table RIB dump of a route collector, or
+// a synthetic full table of FullTableSize prefixes if it is unset. It is
+// loaded once and shared by callers.
+func Default() (*Corpus, error) {
+	defaultOnce.Do(func() {
+		if path := os.Getenv(corpusEnv); path != "" {
+			defaultCorpus, defaultErr = LoadMRTFile(path)
+			return
+		}
+		defaultCorpus = Synthetic(FullTableSize, 1)
+	})
+	return defaultCorpus, defaultErr
+}
+
+// weighted is a discrete distribution of values with relative weights.
+type weighted []struct{ value, weight int }
+
+func (w weighted) sample(r *rand.Rand) int {
+	var total int
+	for _, p := range w {
+		total += p.weight
+	}
+	n := r.Intn(total)
+	for _, p := range w {
+		if n < p.weight {
+			return p.value
+		}
+		n -= p.weight
+	}
+	return w[len(w)-1].value
+}
+
+var (
+	// distributions approximate those observed in full tables
+	ipv4PrefixLenDist = weighted{{24, 60}, {23, 8}, {22, 10}, {21, 4},
+		{20, 4}, {19, 3}, {18, 2}, {17, 1}, {16, 5}, {15, 1}, {14, 1}}
+	ipv6PrefixLenDist = weighted{{48, 50}, {44, 8}, {40, 8}, {36, 4},
+		{32, 25}, {29, 3}, {28, 2}}
+	asPathLenDist = weighted{{1, 3}, {2, 15}, {3, 30}, {4, 25}, {5, 14},
+		{6, 7}, {7, 3}, {8, 2}, {10, 1}}
+	communitiesDist = weighted{{0, 40}, {1, 15}, {2, 15}, {3, 10}, {4, 8},
+		{6, 7}, {10, 5}}
+	prefixesPerUpdateDist = weighted{{1, 50}, {2, 15}, {3, 10}, {5, 10},
+		{10, 10}, {40, 5}}
+)
+
+// Synthetic returns a corpus of a synthetic full table of n prefixes, a fifth
+// of them IPv6, generated deterministically from seed. Prefixes sharing path
+// attributes are packed into the same update, with prefix lengths, AS path
+// lengths and community counts distributed as in a full table.
+func Synthetic(n int, seed int64) *Corpus {
+	r := rand.New(rand.NewSource(seed))
+	asns := make([]uint32, 1024)
+	for i := range asns {
+		if r.Intn(4) == 0 {
+			asns[i] = 131072 + uint32(r.Intn(270000))
+		} else {
+			asns[i] = 1 + uint32(r.Intn(64000))
+		}
+	}
+	c := &Corpus{
+		Codec: &corebgp.Codec{FourOctetAS: true},
+	}
+	for n > 0 {
+		count := prefixesPerUpdateDist.sample(r)
+		if count > n {
+			count = n
+		}
+		n -= count
+		ipv6 := r.Intn(5) == 0
+		var nlri []byte
+		for i := 0; i < count; i++ {
+			nlri = appendRandomPrefix(nlri, r, ipv6)
+		}
+		attrs := syntheticAttrs(r, asns, ipv6, nlri)
+		b := make([]byte, 4, 4+len(attrs)+len(nlri))
+		binary.BigEndian.PutUint16(b[2:], uint16(len(attrs)))
+		b = append(b, attrs...)
+		if !ipv6 {
+			b = append(b, nlri...)
+		}
+		c.Updates = append(c.Updates, b)
+	}
+	return c
+}
+
+// appendRandomPrefix appends a random global unicast prefix to b.
+func appendRandomPrefix(b []byte, r *rand.Rand, ipv6 bool) []byte {
+	var addr [16]byte
+	r.Read(addr[:])
+	var bits int
+	if ipv6 {
+		bits = ipv6PrefixLenDist.sample(r)
+		// 2000::/3
+		addr[0] = 0x20 | addr[0]&0x1f
+	} else {
+		bits = ipv4PrefixLenDist.sample(r)
+		// 1.0.0.0 - 223.255.255.255
+		addr[0] = 1 + addr[0]%223
+	}
+	n := (bits + 7) / 8
+	if bits%8 != 0 {
+		addr[n-1] &= 0xff << (8 - bits%8)
+	}
+	b = append(b, uint8(bits))
+	return append(b, addr[:n]...)
+}
+
+// appendAttr appends an attribute of a known type with its default flags.
+func appendAttr(b []byte, attrType uint8, value []byte) []byte {
+	flags, _ := corebgp.DefaultAttrFlags(attrType)
+	b, _ = corebgp.AppendPathAttr(b, flags, attrType, value)
+	return b
+}
+
+// syntheticAttrs returns random path attributes, including an MP_REACH_NLRI
+// attribute carrying nlri if ipv6 is true.
+func syntheticAttrs(r *rand.Rand, asns []uint32, ipv6 bool,
+	nlri []byte) []byte {
+	var b []byte
+	b = appendAttr(b, corebgp.AttrTypeOrigin, []byte{uint8(r.Intn(3))})
+	path := corebgp.ASPath{{Type: corebgp.ASPathSegmentSequence}}
+	for i := asPathLenDist.sample(r); i > 0; i-- {
+		path[0].ASNs = append(path[0].ASNs, asns[r.Intn(len(asns))])
+	}
+	value, _ := path.Encode(true)
+	b = appendAttr(b, corebgp.AttrTypeASPath, value)
+	if !ipv6 {
+		b = appendAttr(b, corebgp.AttrTypeNextHop, []byte{192, 0, 2, 1})
+	}
+	if r.Intn(3) == 0 {
+		med := make([]byte, 4)
+		binary.BigEndian.PutUint32(med, uint32(r.Intn(1000)))
+		b = appendAttr(b, corebgp.AttrTypeMED, med)
+	}
+	if n := communitiesDist.sample(r); n > 0 {
+		value = make([]byte, 0, n*4)
+		for i := 0; i < n; i++ {
+			asn := uint16(asns[r.Intn(len(asns))])
+			value = append(value, uint8(asn>>8), uint8(asn),
+				uint8(r.Intn(256)), uint8(r.Intn(256)))
+		}
+		b = appendAttr(b, corebgp.AttrTypeCommunities, value)
+	}
+	if ipv6 {
+		// 2001:db8::1
+		nextHop := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 1}
+		b = appendAttr(b, corebgp.AttrTypeMPReachNLRI,
+			mpReach(corebgp.AFIIPv6, nextHop, nlri))
+	}
+	return b
+}
+
+// mpReach returns the value of a unicast MP_REACH_NLRI attribute.
+func mpReach(afi uint16, nextHop, nlri []byte) []byte {
+	b := make([]byte, 0, 5+len(nextHop)+len(nlri))
+	b = append(b, uint8(afi>>8), uint8(afi), corebgp.SAFIUnicast,
+		uint8(len(nextHop)))
+	b = append(b, nextHop...)
+	// reserved
+	b = append(b, 0)
+	return append(b, nlri...)
+}
+
+// https://tools.ietf.org/html/rfc6396#section-4
+const (
+	mrtHeaderLength = 12
+
+	mrtTypeTableDumpV2    = 13
+	mrtSubtypeRIBIPv4     = 2
+	mrtSubtypeRIBIPv6     = 4
+	mrtTypeBGP4MP         = 16
+	mrtTypeBGP4MPET       = 17
+	mrtSubtypeMessageAS4  = 4
+	mrtSubtypeMessageAS4L = 7
+)
+
+var errMRTTruncated = errors.New("truncated MRT record")
+
+// LoadMRTFile returns the corpus of the MRT file at path, see LoadMRT. Files
+// compressed with gzip are decompressed.
+func LoadMRTFile(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil &&
+		magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return LoadMRT(r)
+}
+
+// LoadMRT returns the corpus of the MRT records (RFC6396) read from r. Each
+// entry of TABLE_DUMP_V2 IPv4 and IPv6 unicast RIB records, as in the
+// full-table dumps of route collectors, becomes an update advertising its
+// prefix, and the UPDATE messages of BGP4MP records with four-octet AS numbers
+// are included as received. Other records are skipped.
+func LoadMRT(r io.Reader) (*Corpus, error) {
+	c := &Corpus{
+		Codec: &corebgp.Codec{
+			FourOctetAS:     true,
+			ExtendedMessage: true,
+		},
+	}
+	var header [mrtHeaderLength]byte
+	for {
+		_, err := io.ReadFull(r, header[:])
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err = io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+		recordType := binary.BigEndian.Uint16(header[4:])
+		subtype := binary.BigEndian.Uint16(header[6:])
+		switch {
+		case recordType == mrtTypeTableDumpV2 &&
+			subtype == mrtSubtypeRIBIPv4:
+			err = c.addRIB(body, corebgp.AFIIPv4)
+		case recordType == mrtTypeTableDumpV2 &&
+			subtype == mrtSubtypeRIBIPv6:
+			err = c.addRIB(body, corebgp.AFIIPv6)
+		case recordType == mrtTypeBGP4MP || recordType == mrtTypeBGP4MPET:
+			if recordType == mrtTypeBGP4MPET {
+				// microsecond timestamp
+				if len(body) < 4 {
+					return nil, errMRTTruncated
+				}
+				body = body[4:]
+			}
+			if subtype == mrtSubtypeMessageAS4 ||
+				subtype == mrtSubtypeMessageAS4L {
+				err = c.addBGP4MPMessage(body)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// addRIB adds the entries of the RIB record body of afi.
+// https://tools.ietf.org/html/rfc6396#section-4.3.2
+func (c *Corpus) addRIB(body []byte, afi uint16) error {
+	if len(body) < 5 {
+		return errMRTTruncated
+	}
+	n := 5 + (int(body[4])+7)/8
+	if len(body) < n+2 {
+		return errMRTTruncated
+	}
+	prefix := body[4:n]
+	entries := binary.BigEndian.Uint16(body[n:])
+	body = body[n+2:]
+	for i := 0; i < int(entries); i++ {
+		if len(body) < 8 {
+			return errMRTTruncated
+		}
+		attrsLen := int(binary.BigEndian.Uint16(body[6:]))
+		if len(body) < 8+attrsLen {
+			return errMRTTruncated
+		}
+		u, err := ribEntryUpdate(afi, body[8:8+attrsLen], prefix)
+		if err != nil {
+			return err
+		}
+		c.Updates = append(c.Updates, u)
+		body = body[8+attrsLen:]
+	}
+	return nil
+}
+
+// ribEntryUpdate returns an update advertising prefix of afi with the path
+// attributes attrs of a RIB entry, whose MP_REACH_NLRI attribute, if any,
+// only holds the next hop.
+// https://tools.ietf.org/html/rfc6396#section-4.3.4
+func ribEntryUpdate(afi uint16, attrs, prefix []byte) ([]byte, error) {
+	b := make([]byte, 4, 4+len(attrs)+len(prefix)+8)
+	it := corebgp.NewPathAttrIterator(append([]byte{0, 0, uint8(
+		len(attrs) >> 8), uint8(len(attrs))}, attrs...))
+	for it.Next() {
+		if it.Type() != corebgp.AttrTypeMPReachNLRI {
+			b = append(b, it.Raw()...)
+			continue
+		}
+		v := it.Value()
+		if len(v) < 1 || len(v) < 1+int(v[0]) {
+			return nil, errMRTTruncated
+		}
+		var err error
+		b, err = corebgp.AppendPathAttr(b, it.Flags(),
+			corebgp.AttrTypeMPReachNLRI, mpReach(afi, v[1:1+v[0]], prefix))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("invalid RIB entry attributes: %w", err)
+	}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)-4))
+	if afi == corebgp.AFIIPv4 {
+		b = append(b, prefix...)
+	}
+	return b, nil
+}
+
+// addBGP4MPMessage adds the UPDATE message of the BGP4MP_MESSAGE_AS4 record
+// body, if any.
+// https://tools.ietf.org/html/rfc6396#section-4.4.3
+func (c *Corpus) addBGP4MPMessage(body []byte) error {
+	if len(body) < 12 {
+		return errMRTTruncated
+	}
+	n := 12 + 2*4
+	if binary.BigEndian.Uint16(body[10:]) == corebgp.AFIIPv6 {
+		n = 12 + 2*16
+	}
+	if len(body) < n+corebgp.HeaderLength {
+		return errMRTTruncated
+	}
+	msg := body[n:]
+	if msg[18] == corebgp.UpdateMessageType {
+		c.Updates = append(c.Updates, msg[corebgp.HeaderLength:])
+	}
+	return nil
+}
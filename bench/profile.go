@@ -0,0 +1,93 @@
+//go:build go1.18
+// +build go1.18
+
+package bench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// Result is the result of running a workload via Profile.
+type Result struct {
+	// Runs is the number of times the corpus was processed.
+	Runs int
+	// Items is the number of items processed over all runs.
+	Items   int
+	Elapsed time.Duration
+}
+
+func (r Result) String() string {
+	if r.Runs == 0 || r.Elapsed <= 0 {
+		return "no runs"
+	}
+	return fmt.Sprintf("%d runs in %s, %s/run, %.0f items/s", r.Runs,
+		r.Elapsed.Round(time.Millisecond),
+		(r.Elapsed / time.Duration(r.Runs)).Round(time.Microsecond),
+		float64(r.Items)/r.Elapsed.Seconds())
+}
+
+// Profile runs the workload of the given name over c repeatedly for at least
+// d. If cpuProfile is not empty a CPU profile of the runs is written to it,
+// and if memProfile is not empty a heap profile is written to it once done.
+// Samples are labeled with the workload name (pprof label "workload"), and
+// the corpus is prepared beforehand, so that profiles only cover the
+// workload.
+func Profile(name string, c *Corpus, d time.Duration, cpuProfile,
+	memProfile string) (Result, error) {
+	w, ok := Workloads[name]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown workload %q", name)
+	}
+	if err := c.prepare(); err != nil {
+		return Result{}, err
+	}
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return Result{}, err
+		}
+		defer f.Close()
+		if err = pprof.StartCPUProfile(f); err != nil {
+			return Result{}, err
+		}
+		defer pprof.StopCPUProfile()
+	}
+	var (
+		r   Result
+		err error
+	)
+	pprof.Do(context.Background(), pprof.Labels("workload", name),
+		func(context.Context) {
+			start := time.Now()
+			for r.Elapsed < d {
+				var n int
+				n, err = w(c)
+				if err != nil {
+					return
+				}
+				r.Runs++
+				r.Items += n
+				r.Elapsed = time.Since(start)
+			}
+		})
+	if err != nil {
+		return r, err
+	}
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err != nil {
+			return r, err
+		}
+		defer f.Close()
+		runtime.GC()
+		if err = pprof.WriteHeapProfile(f); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,190 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// BGPsecVersion is the BGPsec protocol version of RFC8205.
+const BGPsecVersion uint8 = 0
+
+// BGPsec is the value of a BGPsec capability.
+// https://tools.ietf.org/html/rfc8205#section-2.1
+type BGPsec struct {
+	Version uint8
+	// Send is the Direction bit, true if the speaker is capable of sending
+	// BGPsec update messages, false if it is capable of receiving them.
+	Send bool
+	AFI  uint16
+}
+
+// NewBGPsecCap returns a BGPsec capability (RFC8205). A speaker capable of
+// sending and receiving BGPsec update messages for an AFI advertises a
+// capability for each direction. A collector recording BGPsec announcements
+// advertises the receive direction, e.g. along with the Four-octet AS Number
+// capability RFC8205 requires.
+func NewBGPsecCap(b BGPsec) *Capability {
+	value := make([]byte, 3)
+	value[0] = b.Version << 4
+	if b.Send {
+		value[0] |= 0x08
+	}
+	binary.BigEndian.PutUint16(value[1:], b.AFI)
+	return &Capability{
+		Code:  CapCodeBGPsec,
+		Value: value,
+	}
+}
+
+// DecodeBGPsecCap decodes a BGPsec capability.
+func DecodeBGPsecCap(c *Capability) (BGPsec, error) {
+	if err := checkCapCode(c, CapCodeBGPsec); err != nil {
+		return BGPsec{}, err
+	}
+	if err := checkCapLen(c, 3); err != nil {
+		return BGPsec{}, err
+	}
+	return BGPsec{
+		Version: c.Value[0] >> 4,
+		Send:    c.Value[0]&0x08 != 0,
+		AFI:     binary.BigEndian.Uint16(c.Value[1:]),
+	}, nil
+}
+
+// BGPsec secure path segment flag values
+const (
+	// BGPsecFlagConfedSegment is the Confed_Segment flag, set if the segment
+	// was added within a confederation.
+	BGPsecFlagConfedSegment uint8 = 0x80
+)
+
+// SecurePathSegment is a Secure_Path segment of a BGPsec_PATH attribute.
+type SecurePathSegment struct {
+	// PCount is the number of times ASN appears in the equivalent AS_PATH,
+	// zero for a transparent route server.
+	PCount uint8
+	Flags  uint8
+	ASN    uint32
+}
+
+// SignatureSegment is a Signature_Segment of a BGPsec_PATH attribute.
+type SignatureSegment struct {
+	// SKI is the Subject Key Identifier of the signing router certificate.
+	SKI       [20]byte
+	Signature []byte
+}
+
+// SignatureBlock is a Signature_Block of a BGPsec_PATH attribute.
+type SignatureBlock struct {
+	AlgorithmSuite uint8
+	// Signatures contains a Signature_Segment for each Secure_Path segment,
+	// the most recently added first.
+	Signatures []SignatureSegment
+}
+
+// BGPsecPath is the value of a BGPsec_PATH attribute, which replaces the
+// AS_PATH attribute of BGPsec update messages. Its signatures are decoded
+// but not validated.
+// https://tools.ietf.org/html/rfc8205#section-3
+type BGPsecPath struct {
+	// SecurePath contains the Secure_Path segments, the most recently added
+	// first.
+	SecurePath []SecurePathSegment
+	// SignatureBlocks contains one or two Signature_Blocks, of different
+	// algorithm suites.
+	SignatureBlocks []SignatureBlock
+}
+
+var errMalformedBGPsecPath = errors.New("malformed BGPsec_PATH attribute")
+
+// Decode decodes a BGPsec_PATH attribute value. Values are copied from b.
+func (p *BGPsecPath) Decode(b []byte) error {
+	if len(b) < 2 {
+		return errMalformedBGPsecPath
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if n < 2+6 || (n-2)%6 != 0 || n > len(b) {
+		return fmt.Errorf("invalid BGPsec_PATH secure path length: %d", n)
+	}
+	p.SecurePath = make([]SecurePathSegment, 0, (n-2)/6)
+	for i := 2; i < n; i += 6 {
+		p.SecurePath = append(p.SecurePath, SecurePathSegment{
+			PCount: b[i],
+			Flags:  b[i+1],
+			ASN:    binary.BigEndian.Uint32(b[i+2:]),
+		})
+	}
+	b = b[n:]
+	p.SignatureBlocks = nil
+	for len(b) > 0 {
+		if len(p.SignatureBlocks) == 2 {
+			return errors.New("more than two BGPsec_PATH signature blocks")
+		}
+		if len(b) < 3 {
+			return errMalformedBGPsecPath
+		}
+		n = int(binary.BigEndian.Uint16(b))
+		if n < 3 || n > len(b) {
+			return fmt.Errorf("invalid BGPsec_PATH signature block "+
+				"length: %d", n)
+		}
+		block := SignatureBlock{
+			AlgorithmSuite: b[2],
+		}
+		segments := b[3:n]
+		for len(segments) > 0 {
+			if len(segments) < 22 {
+				return errMalformedBGPsecPath
+			}
+			s := SignatureSegment{}
+			copy(s.SKI[:], segments)
+			sigLen := int(binary.BigEndian.Uint16(segments[20:]))
+			if len(segments) < 22+sigLen {
+				return fmt.Errorf("invalid BGPsec_PATH signature length: "+
+					"%d", sigLen)
+			}
+			s.Signature = append([]byte(nil), segments[22:22+sigLen]...)
+			block.Signatures = append(block.Signatures, s)
+			segments = segments[22+sigLen:]
+		}
+		if len(block.Signatures) != len(p.SecurePath) {
+			return fmt.Errorf("BGPsec_PATH signature block has %d "+
+				"signatures for %d secure path segments",
+				len(block.Signatures), len(p.SecurePath))
+		}
+		p.SignatureBlocks = append(p.SignatureBlocks, block)
+		b = b[n:]
+	}
+	if len(p.SignatureBlocks) == 0 {
+		return errors.New("BGPsec_PATH without signature blocks")
+	}
+	return nil
+}
+
+// ASPath returns the AS_PATH equivalent to the Secure_Path of p, i.e. the
+// AS_PATH a BGPsec speaker sends to a peer not supporting BGPsec. Each ASN
+// appears PCount times, within an AS_CONFED_SEQUENCE if its segment has
+// BGPsecFlagConfedSegment set.
+// https://tools.ietf.org/html/rfc8205#section-4.4
+func (p *BGPsecPath) ASPath() ASPath {
+	var path ASPath
+	for _, s := range p.SecurePath {
+		segType := ASPathSegmentSequence
+		if s.Flags&BGPsecFlagConfedSegment != 0 {
+			segType = ASPathSegmentConfedSequence
+		}
+		if len(path) == 0 || path[len(path)-1].Type != segType ||
+			len(path[len(path)-1].ASNs)+int(s.PCount) > 255 {
+			if s.PCount == 0 {
+				continue
+			}
+			path = append(path, ASPathSegment{Type: segType})
+		}
+		last := &path[len(path)-1]
+		for i := 0; i < int(s.PCount); i++ {
+			last.ASNs = append(last.ASNs, s.ASN)
+		}
+	}
+	return path
+}
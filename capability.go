@@ -0,0 +1,455 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Capability code values of the IANA registry, covering codes 1-9 and 64-75
+// and the pre-standard codes deprecated by RFC8810. Code 66 is deprecated
+// without a name, and codes 239-254 are reserved for experimental use.
+// https://www.iana.org/assignments/capability-codes/capability-codes.xhtml
+// https://tools.ietf.org/html/rfc8810#section-3
+const (
+	CapCodeMPExtensions              uint8 = 1
+	CapCodeRouteRefresh              uint8 = 2
+	CapCodeOutboundRouteFiltering    uint8 = 3
+	CapCodeMultipleRoutes            uint8 = 4
+	CapCodeExtendedNextHop           uint8 = 5
+	CapCodeExtendedMessage           uint8 = 6
+	CapCodeBGPsec                    uint8 = 7
+	CapCodeMultipleLabels            uint8 = 8
+	CapCodeRole                      uint8 = 9
+	CapCodeGracefulRestart           uint8 = 64
+	CapCodeFourOctetAS               uint8 = 65
+	CapCodeDynamicCapability         uint8 = 67
+	CapCodeMultisession              uint8 = 68
+	CapCodeAddPath                   uint8 = 69
+	CapCodeEnhancedRouteRefresh      uint8 = 70
+	CapCodeLongLivedGracefulRestart  uint8 = 71
+	CapCodeRoutingPolicyDistribution uint8 = 72
+	CapCodeFQDN                      uint8 = 73
+	CapCodeBFD                       uint8 = 74
+	CapCodeSoftwareVersion           uint8 = 75
+	CapCodePrestandardRouteRefresh   uint8 = 128
+	CapCodePrestandardORF            uint8 = 129
+	CapCodePrestandardORF2           uint8 = 130
+	CapCodePrestandardMultisession   uint8 = 131
+	CapCodePrestandardFQDN           uint8 = 184
+	CapCodePrestandardOperational    uint8 = 185
+)
+
+// Address Family Identifier values
+// https://www.iana.org/assignments/address-family-numbers/address-family-numbers.xhtml
+const (
+	AFIIPv4  uint16 = 1
+	AFIIPv6  uint16 = 2
+	AFIL2VPN uint16 = 25
+	AFIBGPLS uint16 = 16388
+)
+
+// Subsequent Address Family Identifier values
+// https://www.iana.org/assignments/safi-namespace/safi-namespace.xhtml
+const (
+	SAFIUnicast      uint8 = 1
+	SAFIMulticast    uint8 = 2
+	SAFIMPLSLabel    uint8 = 4
+	SAFIMulticastVPN uint8 = 5
+	SAFIVPLS         uint8 = 65
+	SAFIEVPN         uint8 = 70
+	SAFIBGPLS        uint8 = 71
+	SAFISRPolicy     uint8 = 73
+	SAFIMPLSVPN      uint8 = 128
+	SAFIFlowSpec     uint8 = 133
+	SAFIFlowSpecVPN  uint8 = 134
+)
+
+// Capability is a BGP capability as defined by RFC5492.
+type Capability struct {
+	Code  uint8
+	Value []byte
+}
+
+// String returns a human-readable representation of the Capability including
+// its IANA name, e.g. "Multiprotocol Extensions (1) value: 00010001". The
+// value of a capability registered via RegisterCapability is decoded.
+func (c *Capability) String() string {
+	s := fmt.Sprintf("%s (%d)", lookupCapName(c.Code), c.Code)
+	if t, ok := lookupCapType(c.Code); ok && t.Decode != nil {
+		if v, err := t.Decode(c.Value); err == nil {
+			return fmt.Sprintf("%s value: %v", s, v)
+		}
+	}
+	if len(c.Value) > 0 {
+		s += " value: " + hex.EncodeToString(c.Value)
+	}
+	return s
+}
+
+type capabilityJSON struct {
+	Code  uint8  `json:"code"`
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// MarshalJSON marshals the Capability as a JSON object including its IANA
+// name. Value is encoded as a hex string.
+func (c *Capability) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&capabilityJSON{
+		Code:  c.Code,
+		Name:  lookupCapName(c.Code),
+		Value: hex.EncodeToString(c.Value),
+	})
+}
+
+// UnmarshalJSON unmarshals a Capability previously marshaled with
+// MarshalJSON. The name is ignored.
+func (c *Capability) UnmarshalJSON(b []byte) error {
+	var j capabilityJSON
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+	value, err := hex.DecodeString(j.Value)
+	if err != nil {
+		return fmt.Errorf("invalid capability value: %w", err)
+	}
+	c.Code = j.Code
+	c.Value = value
+	return nil
+}
+
+func lookupCapName(code uint8) string {
+	if t, ok := lookupCapType(code); ok {
+		return t.Name
+	}
+	name, ok := capNames[code]
+	if ok {
+		return name
+	}
+	// https://tools.ietf.org/html/rfc8810#section-4
+	if code >= 239 && code <= 254 {
+		return "Experimental"
+	}
+	return "Unknown capability"
+}
+
+var (
+	// https://www.iana.org/assignments/capability-codes/capability-codes.xhtml
+	capNames = map[uint8]string{
+		CapCodeMPExtensions:              "Multiprotocol Extensions",
+		CapCodeRouteRefresh:              "Route Refresh",
+		CapCodeOutboundRouteFiltering:    "Outbound Route Filtering",
+		CapCodeMultipleRoutes:            "Multiple Routes to a Destination",
+		CapCodeExtendedNextHop:           "Extended Next Hop Encoding",
+		CapCodeExtendedMessage:           "Extended Message",
+		CapCodeBGPsec:                    "BGPsec",
+		CapCodeMultipleLabels:            "Multiple Labels",
+		CapCodeRole:                      "BGP Role",
+		CapCodeGracefulRestart:           "Graceful Restart",
+		CapCodeFourOctetAS:               "Four-octet AS Number",
+		CapCodeDynamicCapability:         "Dynamic Capability",
+		CapCodeMultisession:              "Multisession",
+		CapCodeAddPath:                   "ADD-PATH",
+		CapCodeEnhancedRouteRefresh:      "Enhanced Route Refresh",
+		CapCodeLongLivedGracefulRestart:  "Long-Lived Graceful Restart",
+		CapCodeRoutingPolicyDistribution: "Routing Policy Distribution",
+		CapCodeFQDN:                      "FQDN",
+		CapCodeBFD:                       "BFD",
+		CapCodeSoftwareVersion:           "Software Version",
+		CapCodePrestandardRouteRefresh:   "Route Refresh (pre-standard)",
+		CapCodePrestandardORF:            "Outbound Route Filtering (pre-standard)",
+		CapCodePrestandardORF2:           "Outbound Route Filtering (pre-standard)",
+		CapCodePrestandardMultisession:   "Multisession (pre-standard)",
+		CapCodePrestandardFQDN:           "FQDN (pre-standard)",
+		CapCodePrestandardOperational:    "Operational Message (pre-standard)",
+	}
+)
+
+func checkCapCode(c *Capability, code uint8) error {
+	if c.Code != code {
+		return fmt.Errorf("capability code %d is not %s (%d)", c.Code,
+			lookupCapName(code), code)
+	}
+	return nil
+}
+
+func checkCapLen(c *Capability, length int) error {
+	if len(c.Value) != length {
+		return fmt.Errorf("invalid %s capability length: %d",
+			lookupCapName(c.Code), len(c.Value))
+	}
+	return nil
+}
+
+// NewMPExtensionsCap returns a Multiprotocol Extensions capability (RFC4760)
+// for the provided AFI and SAFI.
+func NewMPExtensionsCap(afi uint16, safi uint8) *Capability {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint16(value, afi)
+	value[3] = safi
+	return &Capability{
+		Code:  CapCodeMPExtensions,
+		Value: value,
+	}
+}
+
+// DecodeMPExtensionsCap decodes a Multiprotocol Extensions capability,
+// returning its AFI and SAFI.
+func DecodeMPExtensionsCap(c *Capability) (uint16, uint8, error) {
+	if err := checkCapCode(c, CapCodeMPExtensions); err != nil {
+		return 0, 0, err
+	}
+	if err := checkCapLen(c, 4); err != nil {
+		return 0, 0, err
+	}
+	return binary.BigEndian.Uint16(c.Value), c.Value[3], nil
+}
+
+// NewRouteRefreshCap returns a Route Refresh capability (RFC2918).
+func NewRouteRefreshCap() *Capability {
+	return &Capability{
+		Code: CapCodeRouteRefresh,
+	}
+}
+
+// NewEnhancedRouteRefreshCap returns an Enhanced Route Refresh capability
+// (RFC7313).
+func NewEnhancedRouteRefreshCap() *Capability {
+	return &Capability{
+		Code: CapCodeEnhancedRouteRefresh,
+	}
+}
+
+// NewExtendedMessageCap returns an Extended Message capability (RFC8654).
+func NewExtendedMessageCap() *Capability {
+	return &Capability{
+		Code: CapCodeExtendedMessage,
+	}
+}
+
+// NewFourOctetASCap returns a Support for 4-octet AS Number capability
+// (RFC6793). corebgp includes this capability in all OPEN messages it sends.
+func NewFourOctetASCap(asn uint32) *Capability {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, asn)
+	return &Capability{
+		Code:  CapCodeFourOctetAS,
+		Value: value,
+	}
+}
+
+// DecodeFourOctetASCap decodes a Support for 4-octet AS Number capability,
+// returning the ASN.
+func DecodeFourOctetASCap(c *Capability) (uint32, error) {
+	if err := checkCapCode(c, CapCodeFourOctetAS); err != nil {
+		return 0, err
+	}
+	if err := checkCapLen(c, 4); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(c.Value), nil
+}
+
+// GracefulRestartTuple is an AFI/SAFI entry of a Graceful Restart capability.
+type GracefulRestartTuple struct {
+	AFI  uint16
+	SAFI uint8
+	// ForwardingPreserved is the Forwarding State (F) bit.
+	ForwardingPreserved bool
+}
+
+// GracefulRestart is the value of a Graceful Restart capability.
+type GracefulRestart struct {
+	// Restarting is the Restart State (R) bit.
+	Restarting bool
+	// NotificationSupported is the Graceful Notification (N) bit (RFC8538).
+	NotificationSupported bool
+	// RestartTime is the restart time in seconds. Only the lower 12 bits are
+	// encoded.
+	RestartTime uint16
+	Tuples      []GracefulRestartTuple
+}
+
+const (
+	grFlagRestarting   = 0x8
+	grFlagNotification = 0x4
+	grFlagForwarding   = 0x80
+	grMaxRestartTime   = 0x0FFF
+)
+
+// NewGracefulRestartCap returns a Graceful Restart capability (RFC4724).
+func NewGracefulRestartCap(gr *GracefulRestart) *Capability {
+	value := make([]byte, 2, 2+len(gr.Tuples)*4)
+	flagsAndTime := gr.RestartTime & grMaxRestartTime
+	if gr.Restarting {
+		flagsAndTime |= grFlagRestarting << 12
+	}
+	if gr.NotificationSupported {
+		flagsAndTime |= grFlagNotification << 12
+	}
+	binary.BigEndian.PutUint16(value, flagsAndTime)
+	for _, t := range gr.Tuples {
+		var flags uint8
+		if t.ForwardingPreserved {
+			flags = grFlagForwarding
+		}
+		value = append(value, uint8(t.AFI>>8), uint8(t.AFI), t.SAFI, flags)
+	}
+	return &Capability{
+		Code:  CapCodeGracefulRestart,
+		Value: value,
+	}
+}
+
+// DecodeGracefulRestartCap decodes a Graceful Restart capability.
+func DecodeGracefulRestartCap(c *Capability) (*GracefulRestart, error) {
+	if err := checkCapCode(c, CapCodeGracefulRestart); err != nil {
+		return nil, err
+	}
+	if len(c.Value) < 2 || (len(c.Value)-2)%4 != 0 {
+		return nil, fmt.Errorf("invalid %s capability length: %d",
+			lookupCapName(c.Code), len(c.Value))
+	}
+	flagsAndTime := binary.BigEndian.Uint16(c.Value)
+	flags := flagsAndTime >> 12
+	gr := &GracefulRestart{
+		Restarting:            flags&grFlagRestarting != 0,
+		NotificationSupported: flags&grFlagNotification != 0,
+		RestartTime:           flagsAndTime & grMaxRestartTime,
+	}
+	for b := c.Value[2:]; len(b) > 0; b = b[4:] {
+		gr.Tuples = append(gr.Tuples, GracefulRestartTuple{
+			AFI:                 binary.BigEndian.Uint16(b),
+			SAFI:                b[2],
+			ForwardingPreserved: b[3]&grFlagForwarding != 0,
+		})
+	}
+	return gr, nil
+}
+
+// AddPathDirection is the Send/Receive field of an ADD-PATH capability tuple.
+type AddPathDirection uint8
+
+// AddPathDirection values
+const (
+	AddPathReceive     AddPathDirection = 1
+	AddPathSend        AddPathDirection = 2
+	AddPathSendReceive AddPathDirection = 3
+)
+
+// AddPathTuple is an AFI/SAFI entry of an ADD-PATH capability.
+type AddPathTuple struct {
+	AFI       uint16
+	SAFI      uint8
+	Direction AddPathDirection
+}
+
+// NewAddPathCap returns an ADD-PATH capability (RFC7911).
+func NewAddPathCap(tuples ...AddPathTuple) *Capability {
+	value := make([]byte, 0, len(tuples)*4)
+	for _, t := range tuples {
+		value = append(value, uint8(t.AFI>>8), uint8(t.AFI), t.SAFI,
+			uint8(t.Direction))
+	}
+	return &Capability{
+		Code:  CapCodeAddPath,
+		Value: value,
+	}
+}
+
+// DecodeAddPathCap decodes an ADD-PATH capability.
+func DecodeAddPathCap(c *Capability) ([]AddPathTuple, error) {
+	if err := checkCapCode(c, CapCodeAddPath); err != nil {
+		return nil, err
+	}
+	if len(c.Value) == 0 || len(c.Value)%4 != 0 {
+		return nil, fmt.Errorf("invalid %s capability length: %d",
+			lookupCapName(c.Code), len(c.Value))
+	}
+	tuples := make([]AddPathTuple, 0, len(c.Value)/4)
+	for b := c.Value; len(b) > 0; b = b[4:] {
+		d := AddPathDirection(b[3])
+		if d < AddPathReceive || d > AddPathSendReceive {
+			return nil, fmt.Errorf("invalid ADD-PATH send/receive value: %d",
+				d)
+		}
+		tuples = append(tuples, AddPathTuple{
+			AFI:       binary.BigEndian.Uint16(b),
+			SAFI:      b[2],
+			Direction: d,
+		})
+	}
+	return tuples, nil
+}
+
+// NewFQDNCap returns an FQDN capability
+// (https://tools.ietf.org/html/draft-walton-bgp-hostname-capability-02).
+// hostname and domainName must not exceed 255 bytes.
+func NewFQDNCap(hostname, domainName string) (*Capability, error) {
+	if len(hostname) > math.MaxUint8 || len(domainName) > math.MaxUint8 {
+		return nil, errors.New("hostname and domain name must not exceed 255 bytes")
+	}
+	value := make([]byte, 0, 2+len(hostname)+len(domainName))
+	value = append(value, uint8(len(hostname)))
+	value = append(value, hostname...)
+	value = append(value, uint8(len(domainName)))
+	value = append(value, domainName...)
+	return &Capability{
+		Code:  CapCodeFQDN,
+		Value: value,
+	}, nil
+}
+
+// DecodeFQDNCap decodes an FQDN capability, returning the hostname and domain
+// name.
+func DecodeFQDNCap(c *Capability) (string, string, error) {
+	if err := checkCapCode(c, CapCodeFQDN); err != nil {
+		return "", "", err
+	}
+	b := c.Value
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return "", "", errors.New("invalid FQDN capability hostname length")
+	}
+	hostname := string(b[1 : 1+int(b[0])])
+	b = b[1+int(b[0]):]
+	if len(b) < 1 || len(b) != 1+int(b[0]) {
+		return "", "", errors.New("invalid FQDN capability domain name length")
+	}
+	return hostname, string(b[1:]), nil
+}
+
+// Role is the value of a BGP Role capability.
+type Role uint8
+
+// Role values (RFC9234)
+const (
+	RoleProvider Role = 0
+	RoleRS       Role = 1
+	RoleRSClient Role = 2
+	RoleCustomer Role = 3
+	RolePeer     Role = 4
+)
+
+// NewRoleCap returns a BGP Role capability (RFC9234).
+func NewRoleCap(role Role) *Capability {
+	return &Capability{
+		Code:  CapCodeRole,
+		Value: []byte{uint8(role)},
+	}
+}
+
+// DecodeRoleCap decodes a BGP Role capability.
+func DecodeRoleCap(c *Capability) (Role, error) {
+	if err := checkCapCode(c, CapCodeRole); err != nil {
+		return 0, err
+	}
+	if err := checkCapLen(c, 1); err != nil {
+		return 0, err
+	}
+	return Role(c.Value[0]), nil
+}
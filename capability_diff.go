@@ -0,0 +1,108 @@
+package corebgp
+
+import (
+	"bytes"
+	"sort"
+)
+
+// CapabilityMismatch is a capability sent and received with different
+// values.
+type CapabilityMismatch struct {
+	Local  *Capability
+	Remote *Capability
+}
+
+// CapabilityDiff is the difference between the capabilities sent to and
+// received from a peer, see DiffCapabilities.
+type CapabilityDiff struct {
+	// LocalOnly contains the capabilities sent but not received.
+	LocalOnly []*Capability
+	// RemoteOnly contains the capabilities received but not sent.
+	RemoteOnly []*Capability
+	// Mismatched contains the capabilities sent and received once each with
+	// different values, e.g. ADD-PATH capabilities of different AFI/SAFIs or
+	// directions. Some differ by design, such as the Four-octet AS Number
+	// capabilities of peers of different ASes.
+	Mismatched []CapabilityMismatch
+}
+
+// Empty returns true if the same capabilities were sent and received.
+func (d CapabilityDiff) Empty() bool {
+	return len(d.LocalOnly) == 0 && len(d.RemoteOnly) == 0 &&
+		len(d.Mismatched) == 0
+}
+
+// DiffCapabilities compares the capabilities sent to a peer (local) with
+// those received from it (remote), e.g. those of SessionInfo.SentOpen and
+// ReceivedOpen, to help troubleshoot their negotiation. Capabilities are
+// matched by code. A code sent and received once each is a mismatch if the
+// values differ. Otherwise, as for Multiprotocol Extensions capabilities
+// advertised once per AFI/SAFI, instances are matched by value. Results are
+// ordered by code.
+func DiffCapabilities(local, remote []*Capability) CapabilityDiff {
+	byCode := func(caps []*Capability) map[uint8][]*Capability {
+		m := make(map[uint8][]*Capability)
+		for _, c := range caps {
+			m[c.Code] = append(m[c.Code], c)
+		}
+		return m
+	}
+	localByCode, remoteByCode := byCode(local), byCode(remote)
+	codes := make([]int, 0, len(localByCode)+len(remoteByCode))
+	for code := range localByCode {
+		codes = append(codes, int(code))
+	}
+	for code := range remoteByCode {
+		if _, ok := localByCode[code]; !ok {
+			codes = append(codes, int(code))
+		}
+	}
+	sort.Ints(codes)
+
+	// without returns the capabilities of a not matching any in b by value
+	without := func(a, b []*Capability) []*Capability {
+		var d []*Capability
+		for _, c := range a {
+			found := false
+			for _, o := range b {
+				if bytes.Equal(c.Value, o.Value) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				d = append(d, c)
+			}
+		}
+		return d
+	}
+	var d CapabilityDiff
+	for _, code := range codes {
+		l, r := localByCode[uint8(code)], remoteByCode[uint8(code)]
+		if len(l) == 1 && len(r) == 1 {
+			if !bytes.Equal(l[0].Value, r[0].Value) {
+				d.Mismatched = append(d.Mismatched, CapabilityMismatch{
+					Local:  l[0],
+					Remote: r[0],
+				})
+			}
+			continue
+		}
+		d.LocalOnly = append(d.LocalOnly, without(l, r)...)
+		d.RemoteOnly = append(d.RemoteOnly, without(r, l)...)
+	}
+	return d
+}
+
+// DiffCapabilities compares the capabilities of the OPEN messages sent and
+// received for the session, see DiffCapabilities.
+func (s *SessionInfo) DiffCapabilities() CapabilityDiff {
+	var local, remote []*Capability
+	if s.SentOpen != nil {
+		local = s.SentOpen.Capabilities()
+	}
+	if s.ReceivedOpen != nil {
+		remote = s.ReceivedOpen.Capabilities()
+	}
+	return DiffCapabilities(local, remote)
+}
@@ -0,0 +1,104 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// CapabilityType describes a custom or experimental capability registered
+// via RegisterCapability.
+type CapabilityType struct {
+	// Name is the name of the capability, e.g. as returned by
+	// Capability.String().
+	Name string
+	// Decode decodes the value of the capability, returning an error if it is
+	// malformed. It may be nil.
+	Decode func(value []byte) (interface{}, error)
+	// Negotiate returns the result of negotiating the capability from the
+	// instances of it sent to (local) and received from (remote) a peer,
+	// either of which may be empty. ok is false if the capability was not
+	// negotiated. Results are available via Codec.Negotiated. It may be nil.
+	Negotiate func(local, remote []*Capability) (result interface{}, ok bool)
+}
+
+var (
+	capRegistryMu sync.RWMutex
+	capRegistry   = make(map[uint8]CapabilityType)
+)
+
+// RegisterCapability registers the capability type of code, typically a
+// private use (RFC5492) or experimental (RFC8810) code, or one of a draft.
+// Capabilities negotiated by corebgp itself, and codes already registered,
+// cannot be registered. It is typically called from an init function.
+func RegisterCapability(code uint8, t CapabilityType) error {
+	switch code {
+	case CapCodeMPExtensions, CapCodeExtendedMessage, CapCodeFourOctetAS,
+		CapCodeDynamicCapability, CapCodeAddPath:
+		return fmt.Errorf("capability %s (%d) is negotiated by corebgp",
+			lookupCapName(code), code)
+	}
+	if t.Name == "" {
+		return errors.New("capability name must not be empty")
+	}
+	capRegistryMu.Lock()
+	defer capRegistryMu.Unlock()
+	if _, exists := capRegistry[code]; exists {
+		return fmt.Errorf("capability %d already registered", code)
+	}
+	capRegistry[code] = t
+	return nil
+}
+
+func lookupCapType(code uint8) (CapabilityType, bool) {
+	capRegistryMu.RLock()
+	defer capRegistryMu.RUnlock()
+	t, ok := capRegistry[code]
+	return t, ok
+}
+
+// DecodeCapability decodes c via the Decode function of its registered
+// CapabilityType.
+func DecodeCapability(c *Capability) (interface{}, error) {
+	t, ok := lookupCapType(c.Code)
+	if !ok || t.Decode == nil {
+		return nil, fmt.Errorf("no decoder registered for capability %d",
+			c.Code)
+	}
+	return t.Decode(c.Value)
+}
+
+// negotiateCapabilities returns the results of negotiating the registered
+// capabilities sent to and received from a peer.
+func negotiateCapabilities(sent,
+	received []*Capability) map[uint8]interface{} {
+	capRegistryMu.RLock()
+	defer capRegistryMu.RUnlock()
+	if len(capRegistry) == 0 {
+		return nil
+	}
+	byCode := func(caps []*Capability, code uint8) []*Capability {
+		matching := make([]*Capability, 0)
+		for _, c := range caps {
+			if c.Code == code {
+				matching = append(matching, c)
+			}
+		}
+		return matching
+	}
+	var results map[uint8]interface{}
+	for code, t := range capRegistry {
+		if t.Negotiate == nil {
+			continue
+		}
+		result, ok := t.Negotiate(byCode(sent, code), byCode(received, code))
+		if !ok {
+			continue
+		}
+		if results == nil {
+			results = make(map[uint8]interface{})
+		}
+		results[code] = result
+	}
+	return results
+}
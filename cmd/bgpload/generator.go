@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+
+	"github.com/jwhited/corebgp"
+)
+
+// weighted is a discrete distribution of values with relative weights.
+type weighted struct {
+	values  []int
+	weights []int
+	total   int
+}
+
+func newWeighted(pairs ...[2]int) *weighted {
+	w := &weighted{}
+	for _, p := range pairs {
+		w.values = append(w.values, p[0])
+		w.weights = append(w.weights, p[1])
+		w.total += p[1]
+	}
+	return w
+}
+
+func (w *weighted) sample(r *rand.Rand) int {
+	n := r.Intn(w.total)
+	for i, weight := range w.weights {
+		if n < weight {
+			return w.values[i]
+		}
+		n -= weight
+	}
+	return w.values[len(w.values)-1]
+}
+
+var (
+	// distributions approximate those observed in a full IPv4 table
+	prefixLenDist = newWeighted(
+		[2]int{24, 60}, [2]int{23, 8}, [2]int{22, 10}, [2]int{21, 4},
+		[2]int{20, 4}, [2]int{19, 3}, [2]int{18, 2}, [2]int{17, 1},
+		[2]int{16, 5}, [2]int{15, 1}, [2]int{14, 1})
+	asPathLenDist = newWeighted(
+		[2]int{1, 3}, [2]int{2, 15}, [2]int{3, 30}, [2]int{4, 25},
+		[2]int{5, 14}, [2]int{6, 7}, [2]int{7, 3}, [2]int{8, 2},
+		[2]int{10, 1})
+	communitiesDist = newWeighted(
+		[2]int{0, 40}, [2]int{1, 15}, [2]int{2, 15}, [2]int{3, 10},
+		[2]int{4, 8}, [2]int{6, 7}, [2]int{10, 5})
+	prefixesPerUpdateDist = newWeighted(
+		[2]int{1, 50}, [2]int{2, 15}, [2]int{3, 10}, [2]int{5, 10},
+		[2]int{10, 10}, [2]int{40, 5})
+)
+
+// generator produces UPDATE message bodies for a single synthetic session.
+type generator struct {
+	r           *rand.Rand
+	localAS     uint32
+	nextHop     net.IP
+	fourOctetAS bool
+	originASNs  []uint32
+	transitASNs []uint32
+}
+
+func newGenerator(seed int64, localAS uint32, nextHop net.IP,
+	fourOctetAS bool) *generator {
+	r := rand.New(rand.NewSource(seed))
+	g := &generator{
+		r:           r,
+		localAS:     localAS,
+		nextHop:     nextHop.To4(),
+		fourOctetAS: fourOctetAS,
+	}
+	for i := 0; i < 512; i++ {
+		g.originASNs = append(g.originASNs, g.randomASN())
+	}
+	for i := 0; i < 32; i++ {
+		g.transitASNs = append(g.transitASNs, g.randomASN())
+	}
+	return g
+}
+
+func (g *generator) randomASN() uint32 {
+	if g.fourOctetAS && g.r.Intn(4) == 0 {
+		return 131072 + uint32(g.r.Intn(270000))
+	}
+	return 1 + uint32(g.r.Intn(64000))
+}
+
+func (g *generator) randomPrefix() (net.IP, int) {
+	length := prefixLenDist.sample(g.r)
+	ip := make(net.IP, 4)
+	// avoid 0/8, 10/8, 127/8 and multicast/reserved space
+	for {
+		binary.BigEndian.PutUint32(ip, g.r.Uint32())
+		if ip[0] != 0 && ip[0] != 10 && ip[0] != 127 && ip[0] < 224 {
+			break
+		}
+	}
+	return ip.Mask(net.CIDRMask(length, 32)), length
+}
+
+// next returns the next UPDATE message body and the number of prefixes it
+// contains. Prefixes within a message share the same path attributes.
+func (g *generator) next(maxPrefixes int) ([]byte, int) {
+	count := prefixesPerUpdateDist.sample(g.r)
+	if count > maxPrefixes {
+		count = maxPrefixes
+	}
+	nlri := make([]byte, 0, count*4)
+	for i := 0; i < count; i++ {
+		ip, length := g.randomPrefix()
+		nlri = append(nlri, uint8(length))
+		nlri = append(nlri, ip[:(length+7)/8]...)
+	}
+	attrs := g.attributes()
+	b := make([]byte, 4, 4+len(attrs)+len(nlri))
+	// withdrawn routes length is zero
+	binary.BigEndian.PutUint16(b[2:], uint16(len(attrs)))
+	b = append(b, attrs...)
+	b = append(b, nlri...)
+	return b, count
+}
+
+// endOfRIB returns an IPv4 unicast End-of-RIB marker.
+func endOfRIB() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
+// appendAttr appends an attribute of a known type using its default flags.
+// Generated values are always well under the maximum attribute length.
+func appendAttr(b []byte, attrType uint8, value []byte) []byte {
+	flags, _ := corebgp.DefaultAttrFlags(attrType)
+	b, _ = corebgp.AppendPathAttr(b, flags, attrType, value)
+	return b
+}
+
+func (g *generator) attributes() []byte {
+	b := make([]byte, 0, 64)
+
+	origin := uint8(0)
+	if g.r.Intn(10) == 0 {
+		origin = 2
+	}
+	b = appendAttr(b, corebgp.AttrTypeOrigin, []byte{origin})
+
+	pathLen := asPathLenDist.sample(g.r)
+	asns := []uint32{g.localAS}
+	for i := 1; i < pathLen-1; i++ {
+		asns = append(asns, g.transitASNs[g.r.Intn(len(g.transitASNs))])
+	}
+	if pathLen > 1 {
+		asns = append(asns, g.originASNs[g.r.Intn(len(g.originASNs))])
+	}
+	path, _ := corebgp.ASPath{{
+		Type: corebgp.ASPathSegmentSequence,
+		ASNs: asns,
+	}}.Encode(g.fourOctetAS)
+	b = appendAttr(b, corebgp.AttrTypeASPath, path)
+
+	b = appendAttr(b, corebgp.AttrTypeNextHop, g.nextHop)
+
+	if g.r.Intn(3) == 0 {
+		med := make([]byte, 4)
+		binary.BigEndian.PutUint32(med, uint32(g.r.Intn(1000)))
+		b = appendAttr(b, corebgp.AttrTypeMED, med)
+	}
+
+	if n := communitiesDist.sample(g.r); n > 0 {
+		comms := make([]byte, 0, n*4)
+		for i := 0; i < n; i++ {
+			asn := uint16(g.transitASNs[g.r.Intn(len(g.transitASNs))])
+			comms = append(comms, uint8(asn>>8), uint8(asn),
+				uint8(g.r.Intn(256)), uint8(g.r.Intn(256)))
+		}
+		b = appendAttr(b, corebgp.AttrTypeCommunities, comms)
+	}
+
+	return b
+}
@@ -0,0 +1,399 @@
+// Command bgpload establishes a number of synthetic BGP sessions against a
+// target speaker, streams UPDATE messages with realistic attribute
+// distributions over each session, and reports establishment, convergence and
+// throughput statistics.
+//
+// Each session is sourced from its own local address, starting at -src and
+// incrementing by one per session. On Linux any address within 127.0.0.0/8
+// can be used without additional configuration when testing locally.
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+var (
+	target      = flag.String("target", "", "target IP address")
+	port        = flag.Int("port", 179, "target TCP port")
+	srcAddr     = flag.String("src", "127.0.0.2", "first local source address")
+	routerID    = flag.String("id", "198.18.0.1", "router ID of the first session")
+	sessions    = flag.Int("sessions", 1, "number of synthetic sessions")
+	localAS     = flag.Uint("las", 65001, "local AS of the first session")
+	localASStep = flag.Uint("las-step", 0, "local AS increment per session")
+	remoteAS    = flag.Uint("ras", 0, "remote AS")
+	prefixes    = flag.Int("prefixes", 10000, "prefixes advertised per session")
+	rate        = flag.Int("rate", 0, "max UPDATE messages per second per session (0 = unlimited)")
+	seed        = flag.Int64("seed", 1, "random seed")
+	timeout     = flag.Duration("timeout", time.Minute*5, "maximum test duration")
+	waitEoR     = flag.Bool("wait-eor", false, "wait for End-of-RIB from the target on every session")
+	interval    = flag.Duration("interval", time.Second*5, "progress report interval (0 = disabled)")
+	verbose     = flag.Bool("v", false, "enable corebgp logging")
+)
+
+func main() {
+	flag.Parse()
+	targetIP := net.ParseIP(*target)
+	if targetIP == nil {
+		log.Fatal("invalid target address")
+	}
+	src := net.ParseIP(*srcAddr).To4()
+	if src == nil {
+		log.Fatal("invalid source address, must be IPv4")
+	}
+	id := net.ParseIP(*routerID).To4()
+	if id == nil {
+		log.Fatal("invalid router ID")
+	}
+	if *sessions < 1 {
+		log.Fatal("sessions must be > 0")
+	}
+	if *verbose {
+		corebgp.SetLogger(log.Print)
+	}
+
+	start := time.Now()
+	all := make([]*session, 0, *sessions)
+	servers := make([]*corebgp.Server, 0, *sessions)
+	srvErrCh := make(chan error, *sessions)
+	for i := 0; i < *sessions; i++ {
+		local := make(net.IP, 4)
+		binary.BigEndian.PutUint32(local, binary.BigEndian.Uint32(src)+uint32(i))
+		las := uint32(*localAS) + uint32(i)*uint32(*localASStep)
+		s := newSession(local, las, *seed+int64(i), start)
+		rid := make(net.IP, 4)
+		binary.BigEndian.PutUint32(rid, binary.BigEndian.Uint32(id)+uint32(i))
+		srv, err := corebgp.NewServer(rid)
+		if err != nil {
+			log.Fatalf("error constructing server: %v", err)
+		}
+		err = srv.AddPeer(&corebgp.PeerConfig{
+			IP:       targetIP,
+			LocalAS:  las,
+			RemoteAS: uint32(*remoteAS),
+		}, s, corebgp.LocalAddress(local), corebgp.Port(*port))
+		if err != nil {
+			log.Fatalf("error adding peer: %v", err)
+		}
+		go func() {
+			srvErrCh <- srv.Serve(nil)
+		}()
+		all = append(all, s)
+		servers = append(servers, srv)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	timeoutCh := time.After(*timeout)
+	var progressCh <-chan time.Time
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		progressCh = ticker.C
+	}
+	doneCh := make(chan struct{})
+	go func() {
+		for _, s := range all {
+			<-s.doneCh
+		}
+		close(doneCh)
+	}()
+
+wait:
+	for {
+		select {
+		case <-doneCh:
+			break wait
+		case <-sigCh:
+			log.Println("got signal, stopping")
+			break wait
+		case <-timeoutCh:
+			log.Println("timeout reached, stopping")
+			break wait
+		case err := <-srvErrCh:
+			log.Fatalf("serve error: %v", err)
+		case <-progressCh:
+			printProgress(all, start)
+		}
+	}
+
+	end := time.Now()
+	report(all, start, end)
+	for _, srv := range servers {
+		srv.Close()
+	}
+}
+
+// session is a synthetic peer implementing corebgp.Plugin.
+type session struct {
+	local   net.IP
+	localAS uint32
+	seed    int64
+	start   time.Time
+
+	mu          sync.Mutex
+	fourOctetAS bool
+	// firstEstablished is the time the session was first established, and
+	// established the time of its latest establishment, which the fields
+	// below are reset on. gen counts establishments.
+	firstEstablished   time.Time
+	established        time.Time
+	gen                int
+	sendDone           time.Time
+	eorReceived        time.Time
+	updatesSent        int
+	prefixesSent       int
+	bytesSent          int
+	updatesReceived    int
+	bytesReceived      int
+	lastUpdateReceived time.Time
+	closes             int
+
+	doneOnce sync.Once
+	doneCh   chan struct{}
+}
+
+func newSession(local net.IP, localAS uint32, seed int64,
+	start time.Time) *session {
+	return &session{
+		local:   local,
+		localAS: localAS,
+		seed:    seed,
+		start:   start,
+		doneCh:  make(chan struct{}),
+	}
+}
+
+func (s *session) done() {
+	s.doneOnce.Do(func() {
+		close(s.doneCh)
+	})
+}
+
+func (s *session) GetCapabilities(c *corebgp.PeerConfig) []*corebgp.Capability {
+	return []*corebgp.Capability{
+		corebgp.NewMPExtensionsCap(corebgp.AFIIPv4, corebgp.SAFIUnicast),
+	}
+}
+
+func (s *session) OnOpenMessage(peer *corebgp.PeerConfig,
+	capabilities []*corebgp.Capability) *corebgp.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fourOctetAS = false
+	for _, c := range capabilities {
+		if c.Code == corebgp.CapCodeFourOctetAS {
+			s.fourOctetAS = true
+		}
+	}
+	return nil
+}
+
+func (s *session) OnEstablished(peer *corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	s.mu.Lock()
+	now := time.Now()
+	if s.firstEstablished.IsZero() {
+		s.firstEstablished = now
+	}
+	s.established = now
+	s.gen++
+	s.sendDone = time.Time{}
+	s.eorReceived = time.Time{}
+	s.updatesSent, s.prefixesSent, s.bytesSent = 0, 0, 0
+	s.updatesReceived, s.bytesReceived = 0, 0
+	gen, fourOctetAS := s.gen, s.fourOctetAS
+	s.mu.Unlock()
+	go s.send(writer, gen, newGenerator(s.seed, s.localAS, s.local,
+		fourOctetAS))
+	return s.handleUpdate
+}
+
+func (s *session) OnClose(peer *corebgp.PeerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closes++
+}
+
+// send streams the configured number of prefixes followed by an End-of-RIB
+// marker for establishment gen. A session that closes mid-stream starts over
+// on re-establishment, and only the figures of the latest establishment are
+// reported.
+func (s *session) send(writer corebgp.UpdateMessageWriter, gen int,
+	g *generator) {
+	begin := time.Now()
+	sentUpdates := 0
+	remaining := *prefixes
+	for remaining > 0 {
+		if *rate > 0 {
+			due := begin.Add(time.Duration(sentUpdates) * time.Second /
+				time.Duration(*rate))
+			if d := time.Until(due); d > 0 {
+				time.Sleep(d)
+			}
+		}
+		b, n := g.next(remaining)
+		if err := writer.WriteUpdate(b); err != nil {
+			return
+		}
+		sentUpdates++
+		remaining -= n
+		s.mu.Lock()
+		if s.gen != gen {
+			s.mu.Unlock()
+			return
+		}
+		s.updatesSent++
+		s.prefixesSent += n
+		s.bytesSent += len(b)
+		s.mu.Unlock()
+	}
+	if err := writer.WriteUpdate(endOfRIB()); err != nil {
+		return
+	}
+	s.mu.Lock()
+	if s.gen != gen {
+		s.mu.Unlock()
+		return
+	}
+	s.sendDone = time.Now()
+	received := !s.eorReceived.IsZero()
+	s.mu.Unlock()
+	if !*waitEoR || received {
+		s.done()
+	}
+}
+
+func (s *session) handleUpdate(peer *corebgp.PeerConfig,
+	u []byte) *corebgp.Notification {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updatesReceived++
+	s.bytesReceived += len(u)
+	s.lastUpdateReceived = now
+	if len(u) == 4 && binary.BigEndian.Uint32(u) == 0 && s.eorReceived.IsZero() {
+		s.eorReceived = now
+		if *waitEoR && !s.sendDone.IsZero() {
+			s.done()
+		}
+	}
+	return nil
+}
+
+func printProgress(all []*session, start time.Time) {
+	var established, done, prefixesSent, updatesReceived int
+	for _, s := range all {
+		s.mu.Lock()
+		if !s.established.IsZero() {
+			established++
+		}
+		if !s.sendDone.IsZero() {
+			done++
+		}
+		prefixesSent += s.prefixesSent
+		updatesReceived += s.updatesReceived
+		s.mu.Unlock()
+	}
+	log.Printf("[%s] established: %d/%d, finished sending: %d, "+
+		"prefixes sent: %d, updates received: %d",
+		time.Since(start).Truncate(time.Millisecond), established, len(all),
+		done, prefixesSent, updatesReceived)
+}
+
+type durations []time.Duration
+
+func (d durations) percentile(p float64) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	i := int(float64(len(d)-1) * p)
+	return d[i]
+}
+
+func (d durations) String() string {
+	if len(d) == 0 {
+		return "n/a"
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return fmt.Sprintf("min %s avg %s p50 %s p95 %s max %s",
+		d[0], sum/time.Duration(len(d)), d.percentile(0.5),
+		d.percentile(0.95), d[len(d)-1])
+}
+
+func report(all []*session, start, end time.Time) {
+	var (
+		establish, converge, eor               durations
+		updatesSent, prefixesSent, bytesSent   int
+		updatesReceived, bytesReceived, closes int
+		lastSendDone, lastReceived             time.Time
+	)
+	for _, s := range all {
+		s.mu.Lock()
+		if !s.firstEstablished.IsZero() {
+			establish = append(establish, s.firstEstablished.Sub(s.start))
+		}
+		if !s.sendDone.IsZero() {
+			converge = append(converge, s.sendDone.Sub(s.established))
+			if s.sendDone.After(lastSendDone) {
+				lastSendDone = s.sendDone
+			}
+		}
+		if !s.eorReceived.IsZero() {
+			eor = append(eor, s.eorReceived.Sub(s.established))
+		}
+		if s.lastUpdateReceived.After(lastReceived) {
+			lastReceived = s.lastUpdateReceived
+		}
+		updatesSent += s.updatesSent
+		prefixesSent += s.prefixesSent
+		bytesSent += s.bytesSent
+		updatesReceived += s.updatesReceived
+		bytesReceived += s.bytesReceived
+		closes += s.closes
+		s.mu.Unlock()
+	}
+
+	elapsed := end.Sub(start)
+	fmt.Printf("sessions:            %d configured, %d established, %d closed\n",
+		len(all), len(establish), closes)
+	fmt.Printf("elapsed:             %s\n", elapsed.Truncate(time.Millisecond))
+	fmt.Printf("time to establish:   %s\n", establish)
+	fmt.Printf("time to send table:  %s\n", converge)
+	fmt.Printf("time to target EoR:  %s\n", eor)
+	if !lastSendDone.IsZero() {
+		fmt.Printf("send convergence:    %s after start\n",
+			lastSendDone.Sub(start).Truncate(time.Millisecond))
+	}
+	if !lastReceived.IsZero() {
+		fmt.Printf("last update rcvd:    %s after start\n",
+			lastReceived.Sub(start).Truncate(time.Millisecond))
+	}
+	seconds := elapsed.Seconds()
+	if !lastSendDone.IsZero() {
+		seconds = lastSendDone.Sub(start).Seconds()
+	}
+	if seconds > 0 {
+		fmt.Printf("sent:                %d updates, %d prefixes, %d bytes "+
+			"(%.0f updates/s, %.0f prefixes/s)\n", updatesSent, prefixesSent,
+			bytesSent, float64(updatesSent)/seconds,
+			float64(prefixesSent)/seconds)
+	}
+	fmt.Printf("received:            %d updates, %d bytes\n", updatesReceived,
+		bytesReceived)
+}
@@ -0,0 +1,76 @@
+//go:build go1.18
+// +build go1.18
+
+// Command corebgpbench runs the workloads of package bench for a duration,
+// reporting their throughput and optionally writing CPU and heap profiles for
+// inspection with go tool pprof, e.g.:
+//
+//	corebgpbench -workload decode -mrt rib.gz -cpuprofile cpu.pprof
+//	go tool pprof -http :8080 cpu.pprof
+//
+// The corpus is the MRT file given by -mrt, or a synthetic full table.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jwhited/corebgp/bench"
+)
+
+var (
+	workload   = flag.String("workload", "decode", "workload to run: "+workloadNames()+", or all")
+	mrt        = flag.String("mrt", "", "MRT file to load the corpus from (default synthetic full table)")
+	prefixes   = flag.Int("prefixes", bench.FullTableSize, "prefixes of the synthetic corpus")
+	seed       = flag.Int64("seed", 1, "random seed of the synthetic corpus")
+	duration   = flag.Duration("duration", time.Second*10, "minimum duration per workload")
+	cpuProfile = flag.String("cpuprofile", "", "write a CPU profile to this file")
+	memProfile = flag.String("memprofile", "", "write a heap profile to this file")
+)
+
+func workloadNames() string {
+	var names []string
+	for name := range bench.Workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func main() {
+	flag.Parse()
+	var (
+		c   *bench.Corpus
+		err error
+	)
+	start := time.Now()
+	if *mrt != "" {
+		c, err = bench.LoadMRTFile(*mrt)
+		if err != nil {
+			log.Fatalf("error loading corpus: %v", err)
+		}
+	} else {
+		c = bench.Synthetic(*prefixes, *seed)
+	}
+	log.Printf("loaded %d updates (%d bytes) in %s", len(c.Updates), c.Bytes(),
+		time.Since(start).Round(time.Millisecond))
+
+	names := []string{*workload}
+	if *workload == "all" {
+		if *cpuProfile != "" || *memProfile != "" {
+			log.Fatal("profiles require a single workload")
+		}
+		names = strings.Split(workloadNames(), ", ")
+	}
+	for _, name := range names {
+		r, err := bench.Profile(name, c, *duration, *cpuProfile, *memProfile)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		fmt.Printf("%s: %s\n", name, r)
+	}
+}
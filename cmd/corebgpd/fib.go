@@ -0,0 +1,129 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+
+	"github.com/jwhited/corebgp/rib"
+)
+
+// fib is a forwarding table programmed by a fibWriter.
+type fib interface {
+	// replace installs the route for prefix via nextHop, replacing any
+	// route for prefix installed earlier.
+	replace(prefix *net.IPNet, nextHop net.IP) error
+	// remove removes the route for prefix.
+	remove(prefix *net.IPNet) error
+}
+
+// installedRoute is a route installed in a fib.
+type installedRoute struct {
+	prefix  *net.IPNet
+	nextHop net.IP
+	// peer is the peer the route was received from
+	peer net.IP
+}
+
+// fibWriter programs the best routes of a RIB into a fib, following the
+// changes of the RIB's journal.
+type fibWriter struct {
+	rib *rib.RIB
+	fib fib
+	// installed contains the routes installed by prefix
+	installed map[string]installedRoute
+}
+
+func newFIBWriter(r *rib.RIB, f fib) *fibWriter {
+	return &fibWriter{
+		rib:       r,
+		fib:       f,
+		installed: make(map[string]installedRoute),
+	}
+}
+
+// run programs the fib until ctx is done.
+func (w *fibWriter) run(ctx context.Context) {
+	seq := w.sync()
+	for {
+		if w.rib.Wait(ctx, seq) != nil {
+			return
+		}
+		changes, err := w.rib.Changes(seq)
+		if err == rib.ErrJournalTruncated {
+			seq = w.sync()
+			continue
+		}
+		for _, c := range changes {
+			switch c.Type {
+			case rib.ChangeAdd:
+				w.update(c.Route.Prefix)
+			case rib.ChangeWithdraw:
+				w.update(c.Prefix)
+			case rib.ChangeClear:
+				for _, r := range w.installed {
+					if r.peer.Equal(c.Peer) {
+						w.update(r.prefix)
+					}
+				}
+			}
+			seq = c.Seq
+		}
+	}
+}
+
+// sync updates the routes of all prefixes of the RIB and those installed,
+// returning the sequence number of the RIB synced.
+func (w *fibWriter) sync() uint64 {
+	snap := w.rib.Snapshot()
+	seen := make(map[string]bool)
+	for _, r := range snap.Routes {
+		if !seen[r.Prefix.String()] {
+			seen[r.Prefix.String()] = true
+			w.update(r.Prefix)
+		}
+	}
+	for key, r := range w.installed {
+		if !seen[key] {
+			w.update(r.prefix)
+		}
+	}
+	return snap.Seq
+}
+
+// update installs the best route for prefix, or removes the route installed
+// if there is none.
+func (w *fibWriter) update(prefix *net.IPNet) {
+	key := prefix.String()
+	installed, ok := w.installed[key]
+	best, hasBest := w.rib.Best(prefix)
+	if !hasBest || best.NextHop == nil {
+		if !ok {
+			return
+		}
+		delete(w.installed, key)
+		if err := w.fib.remove(prefix); err != nil {
+			log.Printf("error removing route %s from fib: %v", prefix, err)
+		}
+		return
+	}
+	if ok && installed.nextHop.Equal(best.NextHop) {
+		installed.peer = best.Peer
+		w.installed[key] = installed
+		return
+	}
+	err := w.fib.replace(prefix, best.NextHop)
+	if err != nil {
+		log.Printf("error installing route %s via %s in fib: %v", prefix,
+			best.NextHop, err)
+		return
+	}
+	w.installed[key] = installedRoute{
+		prefix:  prefix,
+		nextHop: best.NextHop,
+		peer:    best.Peer,
+	}
+}
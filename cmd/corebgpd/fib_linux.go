@@ -0,0 +1,165 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// rtprotBGP is RTPROT_BGP of linux/rtnetlink.h, the protocol of routes
+	// installed by corebgpd.
+	rtprotBGP = 186
+	// rtaTable is RTA_TABLE of linux/rtnetlink.h.
+	rtaTable = 15
+)
+
+// netlinkFIB is a fib programming a kernel routing table via rtnetlink.
+type netlinkFIB struct {
+	mu    sync.Mutex
+	fd    int
+	table uint32
+	seq   uint32
+}
+
+// newFIB returns a fib programming the kernel routing table table.
+func newFIB(table int) (fib, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("error opening netlink socket: %v", err)
+	}
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("error binding netlink socket: %v", err)
+	}
+	return &netlinkFIB{
+		fd:    fd,
+		table: uint32(table),
+	}, nil
+}
+
+func (f *netlinkFIB) replace(prefix *net.IPNet, nextHop net.IP) error {
+	return f.request(syscall.RTM_NEWROUTE,
+		syscall.NLM_F_CREATE|syscall.NLM_F_REPLACE, prefix, nextHop)
+}
+
+func (f *netlinkFIB) remove(prefix *net.IPNet) error {
+	err := f.request(syscall.RTM_DELROUTE, 0, prefix, nil)
+	if err == syscall.ESRCH {
+		// removed by someone else
+		return nil
+	}
+	return err
+}
+
+// request sends a route message of msgType for prefix, returning the error
+// acknowledged by the kernel.
+func (f *netlinkFIB) request(msgType, flags uint16, prefix *net.IPNet,
+	nextHop net.IP) error {
+	family, dst := syscall.AF_INET6, prefix.IP.To16()
+	if len(prefix.Mask) == net.IPv4len {
+		family, dst = syscall.AF_INET, prefix.IP.To4()
+	}
+	if dst == nil {
+		return errors.New("invalid prefix")
+	}
+	var gateway net.IP
+	if nextHop != nil {
+		gateway = nextHop.To16()
+		if family == syscall.AF_INET {
+			gateway = nextHop.To4()
+		}
+		if gateway == nil {
+			return errors.New("next hop of a different address family")
+		}
+	}
+	ones, _ := prefix.Mask.Size()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	b := make([]byte, syscall.NLMSG_HDRLEN+syscall.SizeofRtMsg, 64)
+	*(*syscall.NlMsghdr)(unsafe.Pointer(&b[0])) = syscall.NlMsghdr{
+		Type:  msgType,
+		Flags: syscall.NLM_F_REQUEST | syscall.NLM_F_ACK | flags,
+		Seq:   f.seq,
+	}
+	*(*syscall.RtMsg)(unsafe.Pointer(&b[syscall.NLMSG_HDRLEN])) =
+		syscall.RtMsg{
+			Family:   uint8(family),
+			Dst_len:  uint8(ones),
+			Table:    syscall.RT_TABLE_UNSPEC,
+			Protocol: rtprotBGP,
+			Scope:    syscall.RT_SCOPE_UNIVERSE,
+			Type:     syscall.RTN_UNICAST,
+		}
+	b = appendRtAttr(b, syscall.RTA_DST, dst)
+	var table [4]byte
+	*(*uint32)(unsafe.Pointer(&table[0])) = f.table
+	b = appendRtAttr(b, rtaTable, table[:])
+	if gateway != nil {
+		b = appendRtAttr(b, syscall.RTA_GATEWAY, gateway)
+	}
+	(*syscall.NlMsghdr)(unsafe.Pointer(&b[0])).Len = uint32(len(b))
+
+	err := syscall.Sendto(f.fd, b, 0, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+	})
+	if err != nil {
+		return err
+	}
+	return f.ack()
+}
+
+// ack reads the acknowledgement of the latest request.
+func (f *netlinkFIB) ack() error {
+	b := make([]byte, syscall.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(f.fd, b, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(b[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != f.seq || m.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return errors.New("truncated netlink error message")
+			}
+			errno := *(*int32)(unsafe.Pointer(&m.Data[0]))
+			if errno != 0 {
+				return syscall.Errno(-errno)
+			}
+			return nil
+		}
+	}
+}
+
+// appendRtAttr appends a route attribute of attrType and value to b.
+func appendRtAttr(b []byte, attrType uint16, value []byte) []byte {
+	var attr [syscall.SizeofRtAttr]byte
+	*(*syscall.RtAttr)(unsafe.Pointer(&attr[0])) = syscall.RtAttr{
+		Len:  uint16(syscall.SizeofRtAttr + len(value)),
+		Type: attrType,
+	}
+	b = append(b, attr[:]...)
+	b = append(b, value...)
+	for len(b)%syscall.RTA_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
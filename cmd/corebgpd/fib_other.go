@@ -0,0 +1,13 @@
+//go:build go1.18 && !linux
+// +build go1.18,!linux
+
+package main
+
+import (
+	"errors"
+)
+
+// newFIB returns a fib programming the kernel routing table table.
+func newFIB(table int) (fib, error) {
+	return nil, errors.New("fib programming is only supported on linux")
+}
@@ -0,0 +1,80 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"net"
+	"sync"
+)
+
+// multiListener is a net.Listener accepting connections from multiple
+// listeners.
+type multiListener struct {
+	listeners []net.Listener
+	connCh    chan net.Conn
+	errCh     chan error
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	m := &multiListener{
+		listeners: listeners,
+		connCh:    make(chan net.Conn),
+		errCh:     make(chan error, len(listeners)),
+		closeCh:   make(chan struct{}),
+	}
+	for _, l := range listeners {
+		go m.accept(l)
+	}
+	return m
+}
+
+func (m *multiListener) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			m.errCh <- err
+			return
+		}
+		select {
+		case m.connCh <- conn:
+		case <-m.closeCh:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (m *multiListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-m.connCh:
+		return conn, nil
+	case err := <-m.errCh:
+		return nil, err
+	}
+}
+
+func (m *multiListener) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+		for _, l := range m.listeners {
+			if lerr := l.Close(); lerr != nil && err == nil {
+				err = lerr
+			}
+		}
+	})
+	return err
+}
+
+// Listeners returns the listeners of m, so that the corebgp.Server installs
+// TCP authentication keys on each.
+func (m *multiListener) Listeners() []net.Listener {
+	return m.listeners
+}
+
+func (m *multiListener) Addr() net.Addr {
+	return m.listeners[0].Addr()
+}
@@ -0,0 +1,184 @@
+//go:build go1.18
+// +build go1.18
+
+// Command corebgpd is a reference BGP daemon built on corebgp. It serves as
+// documentation-by-example of how the config, rpki, rib, httpapi and corebgp
+// packages fit together, and as a lightweight route collector.
+//
+// Peers are configured via a JSON file in the format of the config package,
+// which is re-applied on SIGHUP along with the policy. Routes received from
+// peers are filtered by the import policy, see policy.go, and held in an
+// Adj-RIB-In per peer. The import policy optionally validates the origin of
+// routes against a file of RPKI VRPs. The export policy applies to routes
+// advertised, of which corebgpd advertises none itself.
+//
+// With the -fib-table flag the best routes are installed in the given kernel
+// routing table via rtnetlink on Linux, see fib_linux.go. An HTTP server
+// provides:
+//
+//	/bgp/...      peer status and control, see package httpapi
+//	/lg/...       looking glass queries, see package lookingglass
+//	/debug/vars   expvar metrics, including per-peer counters and the
+//	              aggregate metrics of package expvarmetrics
+package main
+
+import (
+	"context"
+	"expvar"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/config"
+	"github.com/jwhited/corebgp/expvarmetrics"
+	"github.com/jwhited/corebgp/httpapi"
+	"github.com/jwhited/corebgp/lookingglass"
+	"github.com/jwhited/corebgp/rib"
+)
+
+var (
+	configPath = flag.String("config", "corebgpd.json", "path to config file")
+	httpAddr   = flag.String("http", "127.0.0.1:8179", "HTTP listen address (empty = disabled)")
+	verbose    = flag.Bool("v", false, "enable corebgp logging")
+	policyPath = flag.String("policy", "", "path to policy file (empty = accept all)")
+	fibTable   = flag.Int("fib-table", 0, "kernel routing table to install best routes in (0 = disabled)")
+)
+
+func publishMetrics(srv *corebgp.Server, r *rib.RIB) {
+	expvarmetrics.Publish("corebgp", srv)
+	expvar.Publish("corebgp_peers", expvar.Func(func() interface{} {
+		peers := make(map[string]interface{})
+		for _, p := range srv.ListPeers() {
+			peers[p.Config.IP.String()] = map[string]interface{}{
+				"state":    p.State.String(),
+				"uptime":   p.Uptime.Seconds(),
+				"counters": p.Counters,
+			}
+		}
+		return peers
+	}))
+	expvar.Publish("corebgp_rib_routes", expvar.Func(func() interface{} {
+		return r.Counts()
+	}))
+}
+
+// listen returns a listener accepting connections on all addrs.
+func listen(addrs []string) (net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 1 {
+		return listeners[0], nil
+	}
+	return newMultiListener(listeners), nil
+}
+
+func main() {
+	flag.Parse()
+	if *verbose {
+		corebgp.SetLogger(log.Print)
+	}
+
+	c, err := config.Load(*configPath, nil)
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+	srv, err := c.Server.NewServer()
+	if err != nil {
+		log.Fatalf("error constructing server: %v", err)
+	}
+	pc, err := loadPolicy(*policyPath)
+	if err != nil {
+		log.Fatalf("error loading policy: %v", err)
+	}
+	pol, err := newPolicy(pc)
+	if err != nil {
+		log.Fatalf("error loading policy: %v", err)
+	}
+	r := rib.New()
+	applier := config.NewApplier(srv, func(p *config.Peer) corebgp.Plugin {
+		return newPlugin(p, r)
+	})
+	applier.SetPeerOptions(pol.peerOptions)
+	result, err := applier.Apply(c)
+	if err != nil {
+		log.Fatalf("error applying config: %v", err)
+	}
+	log.Printf("added %d peers", len(result.Added))
+
+	if *httpAddr != "" {
+		publishMetrics(srv, r)
+		mux := http.NewServeMux()
+		mux.Handle("/bgp/", http.StripPrefix("/bgp",
+			httpapi.NewHandler(srv)))
+		mux.Handle("/lg/", http.StripPrefix("/lg", lookingglass.NewHandler(r)))
+		mux.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			log.Fatal(http.ListenAndServe(*httpAddr, mux))
+		}()
+	}
+
+	if *fibTable != 0 {
+		f, err := newFIB(*fibTable)
+		if err != nil {
+			log.Fatalf("error opening fib: %v", err)
+		}
+		go newFIBWriter(r, f).run(context.Background())
+	}
+
+	var lis net.Listener
+	if len(c.Server.Listen) > 0 {
+		lis, err = listen(c.Server.Listen)
+		if err != nil {
+			log.Fatalf("error listening: %v", err)
+		}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range sigCh {
+			if sig != syscall.SIGHUP {
+				srv.Close()
+				return
+			}
+			pc, err := loadPolicy(*policyPath)
+			if err == nil {
+				err = pol.reload(pc)
+			}
+			if err != nil {
+				log.Printf("error reloading policy: %v", err)
+			}
+			c, err := config.Load(*configPath, nil)
+			if err != nil {
+				log.Printf("error reloading config: %v", err)
+				continue
+			}
+			result, err := applier.Apply(c)
+			if err != nil {
+				log.Printf("error applying config: %v", err)
+			}
+			log.Printf("config reloaded: added %d, updated %d, removed %d "+
+				"peers", len(result.Added), len(result.Updated),
+				len(result.Removed))
+		}
+	}()
+
+	err = srv.Serve(lis)
+	if err != corebgp.ErrServerClosed {
+		log.Fatalf("error serving: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/config"
+	"github.com/jwhited/corebgp/rib"
+)
+
+// plugin is the corebgp.Plugin of a peer. It advertises the peer's
+// configured capabilities and maintains its Adj-RIB-In.
+type plugin struct {
+	config *config.Peer
+	rib    *rib.RIB
+	// set to 1 if the peer advertised the 4-octet AS capability, which
+	// corebgp always advertises
+	fourOctetAS int32
+}
+
+func newPlugin(config *config.Peer, r *rib.RIB) *plugin {
+	return &plugin{
+		config: config,
+		rib:    r,
+	}
+}
+
+func (p *plugin) GetCapabilities(peer *corebgp.PeerConfig) []*corebgp.Capability {
+	caps, err := p.config.Capabilities.List()
+	if err != nil {
+		// validated by config.Load
+		log.Printf("[%s] invalid capabilities: %v", peer.IP, err)
+	}
+	return caps
+}
+
+func (p *plugin) OnOpenMessage(peer *corebgp.PeerConfig,
+	capabilities []*corebgp.Capability) *corebgp.Notification {
+	var fourOctetAS int32
+	for _, c := range capabilities {
+		if c.Code == corebgp.CapCodeFourOctetAS {
+			fourOctetAS = 1
+		}
+	}
+	atomic.StoreInt32(&p.fourOctetAS, fourOctetAS)
+	return nil
+}
+
+func (p *plugin) OnEstablished(peer *corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	log.Printf("[%s] established", peer.IP)
+	// send End-of-RIB, corebgpd does not advertise routes
+	writer.WriteUpdate([]byte{0, 0, 0, 0})
+	return p.handleUpdate
+}
+
+func (p *plugin) OnClose(peer *corebgp.PeerConfig) {
+	log.Printf("[%s] closed", peer.IP)
+	p.rib.Clear(peer.IP)
+}
+
+func (p *plugin) handleUpdate(peer *corebgp.PeerConfig,
+	u []byte) *corebgp.Notification {
+	err := p.rib.Update(peer.IP, atomic.LoadInt32(&p.fourOctetAS) == 1, u)
+	if err != nil {
+		// corebgpd is passive, a route it fails to decode is logged rather
+		// than resetting the session
+		log.Printf("[%s] error handling update: %v", peer.IP, err)
+	}
+	return nil
+}
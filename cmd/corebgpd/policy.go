@@ -0,0 +1,289 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/config"
+	"github.com/jwhited/corebgp/rpki"
+)
+
+// policyConfig is the route policy of corebgpd, loaded from the JSON file of
+// the -policy flag.
+type policyConfig struct {
+	// Import is applied to routes received from peers, before they enter
+	// the Adj-RIB-In.
+	Import filterConfig `json:"import"`
+	// Export is applied to routes advertised to peers.
+	Export filterConfig `json:"export"`
+	// VRPs is the path of a JSON file of Validated ROA Payloads in the
+	// format exported by common RPKI validators, e.g.
+	//
+	//	{"roas": [{"prefix": "192.0.2.0/24", "maxLength": 24,
+	//		"asn": "AS64496"}]}
+	//
+	// Routes received are validated against them, see package rpki.
+	VRPs string `json:"vrps,omitempty"`
+	// RejectInvalid rejects routes received whose origin validation state
+	// is Invalid.
+	RejectInvalid bool `json:"reject_invalid,omitempty"`
+}
+
+// filterConfig rejects routes by prefix.
+type filterConfig struct {
+	// RejectPrefixes rejects routes for the listed prefixes and their more
+	// specifics, e.g. "10.0.0.0/8".
+	RejectPrefixes []string `json:"reject_prefixes,omitempty"`
+	// MaxLengthIPv4 and MaxLengthIPv6 reject routes for longer prefixes if
+	// non-zero.
+	MaxLengthIPv4 int `json:"max_length_ipv4,omitempty"`
+	MaxLengthIPv6 int `json:"max_length_ipv6,omitempty"`
+}
+
+func loadPolicy(path string) (*policyConfig, error) {
+	c := &policyConfig{}
+	if path == "" {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding policy: %v", err)
+	}
+	return c, nil
+}
+
+// prefixFilter is a compiled filterConfig.
+type prefixFilter struct {
+	rejected      []*net.IPNet
+	maxLengthIPv4 int
+	maxLengthIPv6 int
+}
+
+func newPrefixFilter(c filterConfig) (*prefixFilter, error) {
+	f := &prefixFilter{
+		maxLengthIPv4: c.MaxLengthIPv4,
+		maxLengthIPv6: c.MaxLengthIPv6,
+	}
+	for _, s := range c.RejectPrefixes {
+		_, p, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix: %q", s)
+		}
+		f.rejected = append(f.rejected, p)
+	}
+	return f, nil
+}
+
+// rejects returns true if f rejects routes for prefix.
+func (f *prefixFilter) rejects(prefix *net.IPNet) bool {
+	ones, bits := prefix.Mask.Size()
+	maxLength := f.maxLengthIPv6
+	if bits == 8*net.IPv4len {
+		maxLength = f.maxLengthIPv4
+	}
+	if maxLength > 0 && ones > maxLength {
+		return true
+	}
+	for _, r := range f.rejected {
+		rOnes, rBits := r.Mask.Size()
+		if rBits == bits && rOnes <= ones && r.Contains(prefix.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// policy applies a policyConfig to the routes received from and advertised
+// to peers.
+type policy struct {
+	vrps *rpki.Table
+	// rules holds the *policyRules of the policyConfig last loaded
+	rules atomic.Value
+}
+
+type policyRules struct {
+	in, out *prefixFilter
+	rpki    *rpki.Policy
+}
+
+func newPolicy(c *policyConfig) (*policy, error) {
+	p := &policy{
+		vrps: rpki.NewTable(),
+	}
+	return p, p.reload(c)
+}
+
+// reload replaces the rules and VRPs of p with those of c. Routes received
+// earlier are not re-evaluated.
+func (p *policy) reload(c *policyConfig) error {
+	in, err := newPrefixFilter(c.Import)
+	if err != nil {
+		return fmt.Errorf("invalid import policy: %v", err)
+	}
+	out, err := newPrefixFilter(c.Export)
+	if err != nil {
+		return fmt.Errorf("invalid export policy: %v", err)
+	}
+	var vrps []rpki.VRP
+	if c.VRPs != "" {
+		vrps, err = loadVRPs(c.VRPs)
+		if err != nil {
+			return fmt.Errorf("error loading vrps: %v", err)
+		}
+	}
+	err = p.vrps.Replace(vrps)
+	if err != nil {
+		return err
+	}
+	var terms []rpki.Term
+	if c.RejectInvalid {
+		terms = append(terms, rpki.RejectInvalid)
+	}
+	p.rules.Store(&policyRules{
+		in:   in,
+		out:  out,
+		rpki: rpki.NewPolicy(p.vrps, terms...),
+	})
+	return nil
+}
+
+// peerOptions returns the PeerOptions applying p to a peer, for use with
+// config.Applier.SetPeerOptions.
+func (p *policy) peerOptions(*config.Peer) []corebgp.PeerOption {
+	return []corebgp.PeerOption{
+		corebgp.InboundPolicy(p.inbound),
+		corebgp.OutboundPolicy(p.outbound),
+	}
+}
+
+func (p *policy) inbound(peer *corebgp.PeerConfig,
+	u *corebgp.DecodedUpdate) *corebgp.DecodedUpdate {
+	rules := p.rules.Load().(*policyRules)
+	origin := rpki.Origin(peer, u)
+	return filterUpdate(u, func(prefix *net.IPNet) bool {
+		return rules.in.rejects(prefix) ||
+			rules.rpki.Action(prefix, origin) == rpki.Reject
+	})
+}
+
+func (p *policy) outbound(peer *corebgp.PeerConfig,
+	u *corebgp.DecodedUpdate) (*corebgp.DecodedUpdate, error) {
+	rules := p.rules.Load().(*policyRules)
+	return filterUpdate(u, rules.out.rejects), nil
+}
+
+// filterUpdate withdraws the prefixes of u rejected by reject, replacing
+// routes for them that were accepted earlier. Rejected prefixes of an
+// MP_REACH_NLRI attribute are dropped if u carries an MP_UNREACH_NLRI
+// attribute of a different AFI/SAFI. Prefixes of SAFIs other than unicast
+// and multicast are accepted. nil is returned if nothing remains of u.
+func filterUpdate(u *corebgp.DecodedUpdate,
+	reject func(*net.IPNet) bool) *corebgp.DecodedUpdate {
+	if len(u.NLRI) == 0 && (u.MPReach == nil || len(u.MPReach.NLRI) == 0) {
+		return u
+	}
+	var rejected []corebgp.NLRI
+	u.NLRI, rejected = splitNLRI(u.NLRI, reject)
+	u.Withdrawn = append(u.Withdrawn, rejected...)
+	if m := u.MPReach; m != nil && len(m.NLRI) > 0 {
+		m.NLRI, rejected = splitNLRI(m.NLRI, reject)
+		switch {
+		case len(rejected) == 0:
+		case len(u.MPUnreach) == 0:
+			u.MPUnreach = []*corebgp.MPNLRI{{
+				AFI:  m.AFI,
+				SAFI: m.SAFI,
+				NLRI: rejected,
+			}}
+		case len(u.MPUnreach) == 1 && u.MPUnreach[0].AFI == m.AFI &&
+			u.MPUnreach[0].SAFI == m.SAFI:
+			w := u.MPUnreach[0]
+			w.NLRI = append(w.NLRI[:len(w.NLRI):len(w.NLRI)], rejected...)
+		}
+		if len(m.NLRI) == 0 {
+			u.MPReach = nil
+		}
+	}
+	if len(u.NLRI) == 0 && u.MPReach == nil {
+		u.Attrs = nil
+		if len(u.Withdrawn) == 0 && len(u.MPUnreach) == 0 {
+			// nothing left that would not be mistaken for an End-of-RIB
+			// marker
+			return nil
+		}
+	}
+	return u
+}
+
+// splitNLRI splits nlri into accepted and rejected prefixes.
+func splitNLRI(nlri []corebgp.NLRI,
+	reject func(*net.IPNet) bool) (accepted, rejected []corebgp.NLRI) {
+	accepted = nlri[:0:0]
+	for _, n := range nlri {
+		if n.Prefix != nil && reject(n.Prefix) {
+			rejected = append(rejected, n)
+			continue
+		}
+		accepted = append(accepted, n)
+	}
+	return accepted, rejected
+}
+
+// vrpASN is the AS of a VRP, encoded as a number or as a string with an
+// optional "AS" prefix.
+type vrpASN uint32
+
+func (a *vrpASN) UnmarshalJSON(b []byte) error {
+	s := strings.TrimPrefix(strings.Trim(string(b), `"`), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid asn: %s", b)
+	}
+	*a = vrpASN(n)
+	return nil
+}
+
+// loadVRPs reads the VRPs of a JSON file in the format of policyConfig.VRPs.
+func loadVRPs(path string) ([]rpki.VRP, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		ROAs []struct {
+			Prefix    string `json:"prefix"`
+			MaxLength int    `json:"maxLength"`
+			ASN       vrpASN `json:"asn"`
+		} `json:"roas"`
+	}
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, err
+	}
+	vrps := make([]rpki.VRP, 0, len(f.ROAs))
+	for _, r := range f.ROAs {
+		_, prefix, err := net.ParseCIDR(r.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vrp prefix: %q", r.Prefix)
+		}
+		vrps = append(vrps, rpki.VRP{
+			Prefix:    prefix,
+			MaxLength: r.MaxLength,
+			ASN:       uint32(r.ASN),
+		})
+	}
+	return vrps, nil
+}
@@ -0,0 +1,103 @@
+package corebgp
+
+// Codec describes the parameters negotiated with a peer that determine the
+// encoding of UPDATE messages, so that raw updates are parsed consistently
+// with the session. It must not be modified.
+type Codec struct {
+	// FourOctetAS is true if four-octet AS numbers were negotiated, in which
+	// case AS_PATH and AGGREGATOR carry four-octet AS numbers.
+	FourOctetAS bool
+	// ExtendedMessage is true if extended messages (RFC8654) were negotiated.
+	ExtendedMessage bool
+	// AddPath contains the AFI/SAFIs for which ADD-PATH (RFC7911) was
+	// negotiated. Direction is AddPathReceive if path identifiers are received
+	// from the peer, AddPathSend if they must be sent to the peer, or both.
+	AddPath []AddPathTuple
+	// negotiated contains the results of negotiating registered capabilities
+	negotiated map[uint8]interface{}
+}
+
+// newCodec returns the Codec of a session from the capabilities sent to and
+// received from the peer.
+func newCodec(fourOctetAS, extendedMessage bool, sent,
+	received []*Capability) *Codec {
+	c := &Codec{
+		FourOctetAS:     fourOctetAS,
+		ExtendedMessage: extendedMessage,
+		negotiated:      negotiateCapabilities(sent, received),
+	}
+	local := make(map[uint32]AddPathDirection)
+	for _, cap := range sent {
+		if cap.Code != CapCodeAddPath {
+			continue
+		}
+		tuples, err := DecodeAddPathCap(cap)
+		if err != nil {
+			continue
+		}
+		for _, t := range tuples {
+			local[addPathKey(t.AFI, t.SAFI)] |= t.Direction
+		}
+	}
+	for _, cap := range received {
+		if cap.Code != CapCodeAddPath {
+			continue
+		}
+		tuples, err := DecodeAddPathCap(cap)
+		if err != nil {
+			continue
+		}
+		for _, t := range tuples {
+			l := local[addPathKey(t.AFI, t.SAFI)]
+			var d AddPathDirection
+			if t.Direction&AddPathSend != 0 && l&AddPathReceive != 0 {
+				d |= AddPathReceive
+			}
+			if t.Direction&AddPathReceive != 0 && l&AddPathSend != 0 {
+				d |= AddPathSend
+			}
+			if d != 0 {
+				c.AddPath = append(c.AddPath, AddPathTuple{
+					AFI:       t.AFI,
+					SAFI:      t.SAFI,
+					Direction: d,
+				})
+			}
+		}
+	}
+	return c
+}
+
+func (c *Codec) addPath(afi uint16, safi uint8) AddPathDirection {
+	for _, t := range c.AddPath {
+		if t.AFI == afi && t.SAFI == safi {
+			return t.Direction
+		}
+	}
+	return 0
+}
+
+// AddPathReceived returns true if NLRI of afi/safi received from the peer
+// carry path identifiers.
+func (c *Codec) AddPathReceived(afi uint16, safi uint8) bool {
+	return c.addPath(afi, safi)&AddPathReceive != 0
+}
+
+// AddPathSent returns true if NLRI of afi/safi sent to the peer must carry
+// path identifiers.
+func (c *Codec) AddPathSent(afi uint16, safi uint8) bool {
+	return c.addPath(afi, safi)&AddPathSend != 0
+}
+
+// Negotiated returns the result of negotiating the capability of code, as
+// returned by the Negotiate function of its CapabilityType registered via
+// RegisterCapability. ok is false if it was not negotiated.
+func (c *Codec) Negotiated(code uint8) (result interface{}, ok bool) {
+	result, ok = c.negotiated[code]
+	return result, ok
+}
+
+// DecodeASPath decodes an AS_PATH attribute value received from the peer.
+func (c *Codec) DecodeASPath(b []byte) (ASPath, error) {
+	return DecodeASPath(b, c.FourOctetAS)
+}
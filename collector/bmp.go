@@ -0,0 +1,348 @@
+package collector
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// https://tools.ietf.org/html/rfc7854#section-4
+const (
+	bmpVersion              = 3
+	bmpMsgRouteMonitoring   = 0
+	bmpMsgPeerDown          = 2
+	bmpMsgPeerUp            = 3
+	bmpMsgInitiation        = 4
+	bmpMsgTermination       = 5
+	bmpInfoSysDescr         = 1
+	bmpInfoSysName          = 2
+	bmpTermReason           = 1
+	bmpTermAdminClose       = 0
+	bmpPeerDownLocalNoNotif = 2
+	bmpPeerFlagIPv6         = 0x80
+	bmpPeerFlagLegacyASPath = 0x20
+)
+
+// BMP sink defaults
+const (
+	DefaultBMPBufferSize    = 10000
+	DefaultBMPRetryInterval = 30 * time.Second
+	bmpDialTimeout          = 5 * time.Second
+	bmpWriteTimeout         = 10 * time.Second
+)
+
+// bmpMessage returns a BMP message of msgType with body.
+func bmpMessage(msgType uint8, body ...[]byte) []byte {
+	length := 6
+	for _, b := range body {
+		length += len(b)
+	}
+	m := make([]byte, 6, length)
+	m[0] = bmpVersion
+	binary.BigEndian.PutUint32(m[1:], uint32(length))
+	m[5] = msgType
+	for _, b := range body {
+		m = append(m, b...)
+	}
+	return m
+}
+
+func bmpTLV(infoType uint16, value []byte) []byte {
+	b := make([]byte, 4, 4+len(value))
+	binary.BigEndian.PutUint16(b, infoType)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(value)))
+	return append(b, value...)
+}
+
+// bmpIP returns ip as a 16 octet BMP address field.
+func bmpIP(ip net.IP) []byte {
+	b := make([]byte, 16)
+	if v4 := ip.To4(); v4 != nil {
+		copy(b[12:], v4)
+	} else if ip != nil {
+		copy(b, ip.To16())
+	}
+	return b
+}
+
+// bmpPeerHeader returns the per-peer header of s.
+func bmpPeerHeader(t time.Time, s *Session) []byte {
+	b := make([]byte, 42)
+	// b[0] is the peer type, a global instance peer
+	peerIP := s.PeerAddress()
+	if peerIP.To4() == nil {
+		b[1] |= bmpPeerFlagIPv6
+	}
+	if !s.FourOctetAS {
+		b[1] |= bmpPeerFlagLegacyASPath
+	}
+	// b[2:10] is the peer distinguisher, zero for global instance peers
+	copy(b[10:26], bmpIP(peerIP))
+	binary.BigEndian.PutUint32(b[26:], s.RemoteAS)
+	copy(b[30:34], s.RemoteID.To4())
+	binary.BigEndian.PutUint32(b[34:], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[38:], uint32(t.Nanosecond()/1000))
+	return b
+}
+
+func addrPort(addr net.Addr) uint16 {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return uint16(a.Port)
+	}
+	return 0
+}
+
+// sentOpen reconstructs the OPEN message sent to the peer of s.
+func sentOpen(s *Session) ([]byte, error) {
+	o, err := corebgp.NewOpenMessageBuilder().
+		ASN(s.LocalAS).
+		HoldTime(s.HoldTime).
+		BGPID(s.LocalID).
+		Capabilities(s.SentCapabilities...).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	return o.Encode()
+}
+
+// receivedOpen reconstructs the OPEN message received from the peer of s.
+func receivedOpen(s *Session) ([]byte, error) {
+	o := &corebgp.OpenMessage{
+		Version:  4,
+		ASN:      twoOctetAS(s.RemoteAS),
+		HoldTime: uint16(s.HoldTime / time.Second),
+	}
+	if v4 := s.RemoteID.To4(); v4 != nil {
+		o.BGPID = binary.BigEndian.Uint32(v4)
+	}
+	if len(s.Capabilities) > 0 {
+		o.OptionalParams = []corebgp.OptionalParam{
+			&corebgp.CapabilityOptionalParam{
+				Capabilities: s.Capabilities,
+			},
+		}
+	}
+	return o.Encode()
+}
+
+func bmpPeerUp(t time.Time, s *Session) ([]byte, error) {
+	sent, err := sentOpen(s)
+	if err != nil {
+		return nil, err
+	}
+	received, err := receivedOpen(s)
+	if err != nil {
+		return nil, err
+	}
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports, addrPort(s.LocalAddr))
+	binary.BigEndian.PutUint16(ports[2:], addrPort(s.RemoteAddr))
+	return bmpMessage(bmpMsgPeerUp, bmpPeerHeader(t, s),
+		bmpIP(s.LocalAddress()), ports, sent, received), nil
+}
+
+type bmpEvent struct {
+	msgType uint8
+	t       time.Time
+	session *Session
+	update  []byte
+}
+
+// BMPSink is a Sink mirroring sessions and UPDATE messages to a BMP
+// monitoring station (RFC7854), with the Collector acting as the monitored
+// router. The Peer Up messages sent contain OPEN messages reconstructed from
+// the established session.
+//
+// The connection to the station is established in the background and
+// re-established after failures, upon which Peer Up messages are sent for
+// all established sessions. UPDATE messages received while disconnected, or
+// while the buffer is full, are dropped.
+type BMPSink struct {
+	addr          string
+	sysName       string
+	sysDescr      string
+	retryInterval time.Duration
+
+	eventCh chan bmpEvent
+	closeCh chan struct{}
+	doneCh  chan struct{}
+	once    sync.Once
+	dropped uint64
+
+	// owned by run()
+	conn     net.Conn
+	lastDial time.Time
+	sessions map[*Session]time.Time
+}
+
+// NewBMPSink returns a BMPSink connecting to the monitoring station at addr,
+// identifying as sysName and sysDescr in its Initiation message.
+func NewBMPSink(addr, sysName, sysDescr string) *BMPSink {
+	b := &BMPSink{
+		addr:          addr,
+		sysName:       sysName,
+		sysDescr:      sysDescr,
+		retryInterval: DefaultBMPRetryInterval,
+		eventCh:       make(chan bmpEvent, DefaultBMPBufferSize),
+		closeCh:       make(chan struct{}),
+		doneCh:        make(chan struct{}),
+		sessions:      make(map[*Session]time.Time),
+	}
+	go b.run()
+	return b
+}
+
+// Dropped returns the number of UPDATE messages dropped.
+func (b *BMPSink) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// write writes m to the station, closing the connection on error.
+func (b *BMPSink) write(m []byte) {
+	if b.conn == nil {
+		return
+	}
+	b.conn.SetWriteDeadline(time.Now().Add(bmpWriteTimeout))
+	_, err := b.conn.Write(m)
+	if err != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+}
+
+// connect connects to the station if not connected and retryInterval has
+// elapsed since the last attempt, sending the Initiation message and Peer
+// Up messages for established sessions. It returns true if a new connection
+// was established.
+func (b *BMPSink) connect() bool {
+	if b.conn != nil || time.Since(b.lastDial) < b.retryInterval {
+		return false
+	}
+	b.lastDial = time.Now()
+	conn, err := net.DialTimeout("tcp", b.addr, bmpDialTimeout)
+	if err != nil {
+		return false
+	}
+	b.conn = conn
+	b.write(bmpMessage(bmpMsgInitiation,
+		bmpTLV(bmpInfoSysDescr, []byte(b.sysDescr)),
+		bmpTLV(bmpInfoSysName, []byte(b.sysName))))
+	for s, t := range b.sessions {
+		m, err := bmpPeerUp(t, s)
+		if err == nil {
+			b.write(m)
+		}
+	}
+	return b.conn != nil
+}
+
+func (b *BMPSink) handle(e bmpEvent) {
+	switch e.msgType {
+	case bmpMsgPeerUp:
+		b.sessions[e.session] = e.t
+	case bmpMsgPeerDown:
+		delete(b.sessions, e.session)
+	}
+	if b.connect() && e.msgType != bmpMsgRouteMonitoring {
+		// the session state was sent upon connecting
+		return
+	}
+	switch e.msgType {
+	case bmpMsgPeerUp:
+		m, err := bmpPeerUp(e.t, e.session)
+		if err == nil {
+			b.write(m)
+		}
+	case bmpMsgPeerDown:
+		b.write(bmpMessage(bmpMsgPeerDown, bmpPeerHeader(e.t, e.session),
+			[]byte{bmpPeerDownLocalNoNotif, 0, 0}))
+	case bmpMsgRouteMonitoring:
+		b.write(bmpMessage(bmpMsgRouteMonitoring,
+			bmpPeerHeader(e.t, e.session),
+			frame(corebgp.UpdateMessageType, e.update)))
+	}
+}
+
+func (b *BMPSink) run() {
+	defer close(b.doneCh)
+	ticker := time.NewTicker(b.retryInterval)
+	defer ticker.Stop()
+	b.connect()
+	for {
+		select {
+		case <-b.closeCh:
+			// handle remaining events before terminating
+		drain:
+			for {
+				select {
+				case e := <-b.eventCh:
+					b.handle(e)
+				default:
+					break drain
+				}
+			}
+			b.write(bmpMessage(bmpMsgTermination,
+				bmpTLV(bmpTermReason, []byte{0, bmpTermAdminClose})))
+			if b.conn != nil {
+				b.conn.Close()
+			}
+			return
+		case <-ticker.C:
+			b.connect()
+		case e := <-b.eventCh:
+			b.handle(e)
+		}
+	}
+}
+
+// send queues e, dropping UPDATE messages if the buffer is full.
+func (b *BMPSink) send(e bmpEvent, drop bool) error {
+	if drop {
+		select {
+		case <-b.closeCh:
+			return errSinkClosed
+		case b.eventCh <- e:
+		default:
+			atomic.AddUint64(&b.dropped, 1)
+		}
+		return nil
+	}
+	select {
+	case <-b.closeCh:
+		return errSinkClosed
+	case b.eventCh <- e:
+	}
+	return nil
+}
+
+func (b *BMPSink) PeerUp(t time.Time, s *Session) error {
+	return b.send(bmpEvent{msgType: bmpMsgPeerUp, t: t, session: s}, false)
+}
+
+func (b *BMPSink) Update(t time.Time, s *Session, update []byte) error {
+	return b.send(bmpEvent{
+		msgType: bmpMsgRouteMonitoring,
+		t:       t,
+		session: s,
+		update:  update,
+	}, true)
+}
+
+func (b *BMPSink) PeerDown(t time.Time, s *Session) error {
+	return b.send(bmpEvent{msgType: bmpMsgPeerDown, t: t, session: s}, false)
+}
+
+// Close sends a Termination message to the station and closes the
+// connection.
+func (b *BMPSink) Close() error {
+	b.once.Do(func() {
+		close(b.closeCh)
+	})
+	<-b.doneCh
+	return nil
+}
@@ -0,0 +1,291 @@
+// Package collector implements a route collector on top of corebgp.
+//
+// A Collector accepts sessions from any peer, or any peer within a set of
+// prefixes, regardless of its AS. It never dials out, advertises Graceful
+// Restart so that peers retain their routes across collector restarts, and
+// hands sessions and received UPDATE messages to Sinks. Sinks writing
+// rotated MRT files, mirroring to a BMP monitoring station, and tapping
+// messages via callbacks are provided:
+//
+//	c, err := collector.New(net.ParseIP("192.0.2.1"), 65000,
+//		collector.Sinks(
+//			collector.NewMRTFileSink("updates.20060102.1504.gz", 0),
+//			collector.NewBMPSink("bmp.example.com:5000", "rc01", "corebgp"),
+//		))
+//	if err != nil {
+//		...
+//	}
+//	lis, err := net.Listen("tcp", ":179")
+//	if err != nil {
+//		...
+//	}
+//	go c.Serve(lis)
+//	defer c.Close()
+package collector
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+// DefaultRestartTime is the default restart time advertised in the Graceful
+// Restart capability.
+const DefaultRestartTime = 120 * time.Second
+
+type options struct {
+	allowedPrefixes []*net.IPNet
+	capabilities    []*corebgp.Capability
+	restartTime     time.Duration
+	sinks           []Sink
+	peerOptions     []corebgp.PeerOption
+	errorHandler    func(error)
+}
+
+func (o *options) setDefaults() {
+	o.capabilities = []*corebgp.Capability{
+		corebgp.NewMPExtensionsCap(corebgp.AFIIPv4, corebgp.SAFIUnicast),
+		corebgp.NewMPExtensionsCap(corebgp.AFIIPv6, corebgp.SAFIUnicast),
+		corebgp.NewRouteRefreshCap(),
+	}
+	o.restartTime = DefaultRestartTime
+	o.errorHandler = func(error) {}
+}
+
+// Option is an option for a Collector.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// AllowedPrefixes restricts the peers accepted by the Collector to addresses
+// within prefixes. By default all peers are accepted.
+func AllowedPrefixes(prefixes ...*net.IPNet) Option {
+	return newFuncOption(func(o *options) {
+		o.allowedPrefixes = append(o.allowedPrefixes, prefixes...)
+	})
+}
+
+// Capabilities sets the capabilities advertised to peers, replacing the
+// defaults of multiprotocol IPv4 and IPv6 unicast and route refresh. The
+// Graceful Restart capability is added according to RestartTime.
+func Capabilities(caps ...*corebgp.Capability) Option {
+	return newFuncOption(func(o *options) {
+		o.capabilities = caps
+	})
+}
+
+// RestartTime sets the restart time advertised in the Graceful Restart
+// capability, which defaults to DefaultRestartTime. A restart time of zero
+// disables the advertisement of Graceful Restart.
+func RestartTime(d time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		o.restartTime = d
+	})
+}
+
+// Sinks adds Sinks to the Collector.
+func Sinks(sinks ...Sink) Option {
+	return newFuncOption(func(o *options) {
+		o.sinks = append(o.sinks, sinks...)
+	})
+}
+
+// PeerOptions sets additional PeerOptions applied to every peer.
+// corebgp.Passive and corebgp.AnyRemoteAS are always applied.
+func PeerOptions(opts ...corebgp.PeerOption) Option {
+	return newFuncOption(func(o *options) {
+		o.peerOptions = append(o.peerOptions, opts...)
+	})
+}
+
+// ErrorHandler sets a function called with errors returned by Sinks.
+func ErrorHandler(fn func(error)) Option {
+	return newFuncOption(func(o *options) {
+		o.errorHandler = fn
+	})
+}
+
+// Collector is a route collector.
+type Collector struct {
+	server       *corebgp.Server
+	routerID     net.IP
+	localAS      uint32
+	capabilities []*corebgp.Capability
+	options      options
+	// allowedPrefixes contains the AllowedPrefixes, it is nil if all peers
+	// are allowed
+	allowedPrefixes *prefixtrie.Trie
+}
+
+// New returns a Collector with BGP Identifier routerID in localAS.
+func New(routerID net.IP, localAS uint32, opts ...Option) (*Collector, error) {
+	if localAS == 0 {
+		return nil, fmt.Errorf("AS must be > 0")
+	}
+	server, err := corebgp.NewServer(routerID)
+	if err != nil {
+		return nil, err
+	}
+	c := &Collector{
+		server:   server,
+		routerID: routerID.To4(),
+		localAS:  localAS,
+	}
+	c.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&c.options)
+	}
+	if len(c.options.allowedPrefixes) > 0 {
+		c.allowedPrefixes = prefixtrie.New()
+		for _, p := range c.options.allowedPrefixes {
+			err = c.allowedPrefixes.Insert(p, nil)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed prefix %v: %w", p,
+					err)
+			}
+		}
+	}
+	c.capabilities = append([]*corebgp.Capability{},
+		c.options.capabilities...)
+	if c.options.restartTime > 0 {
+		c.capabilities = append(c.capabilities,
+			corebgp.NewGracefulRestartCap(&corebgp.GracefulRestart{
+				RestartTime: uint16(c.options.restartTime / time.Second),
+			}))
+	}
+	server.AcceptDynamicPeers(c.acceptPeer)
+	return c, nil
+}
+
+// Server returns the underlying Server, e.g. to subscribe to its events or
+// list its peers. Peers may also be added to it explicitly, e.g. to collect
+// from a peer requiring an active connection.
+func (c *Collector) Server() *corebgp.Server {
+	return c.server
+}
+
+// Serve accepts sessions on lis, blocking until Close is called or lis
+// fails. See corebgp.Server.Serve.
+func (c *Collector) Serve(lis net.Listener) error {
+	return c.server.Serve(lis)
+}
+
+// Close closes all sessions and then the Sinks, returning the first error
+// returned by a Sink.
+func (c *Collector) Close() error {
+	c.server.Close()
+	var err error
+	for _, s := range c.options.sinks {
+		if serr := s.Close(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+func (c *Collector) allowed(ip net.IP) bool {
+	if c.allowedPrefixes == nil {
+		return true
+	}
+	return c.allowedPrefixes.Contains(ip)
+}
+
+func (c *Collector) acceptPeer(ip net.IP) (*corebgp.PeerConfig,
+	corebgp.Plugin, []corebgp.PeerOption) {
+	if !c.allowed(ip) {
+		return nil, nil, nil
+	}
+	opts := append([]corebgp.PeerOption{corebgp.AnyRemoteAS()},
+		c.options.peerOptions...)
+	return &corebgp.PeerConfig{
+		LocalAS: c.localAS,
+	}, &plugin{c: c}, opts
+}
+
+func (c *Collector) sinkError(err error) {
+	if err != nil {
+		c.options.errorHandler(fmt.Errorf("sink error: %w", err))
+	}
+}
+
+// plugin is the corebgp.Plugin of a peer, handing its session to the Sinks.
+type plugin struct {
+	c       *Collector
+	mu      sync.Mutex
+	session *Session
+}
+
+func (p *plugin) GetCapabilities(peer *corebgp.PeerConfig) []*corebgp.Capability {
+	return p.c.capabilities
+}
+
+func (p *plugin) OnOpenMessage(peer *corebgp.PeerConfig,
+	capabilities []*corebgp.Capability) *corebgp.Notification {
+	return nil
+}
+
+func (p *plugin) OnEstablished(peer *corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	// not called as plugin implements corebgp.SessionPlugin
+	return p.OnEstablishedSession(peer, &corebgp.SessionInfo{
+		Peer:     peer.IP,
+		RemoteAS: peer.RemoteAS,
+	}, writer)
+}
+
+func (p *plugin) OnEstablishedSession(peer *corebgp.PeerConfig,
+	session *corebgp.SessionInfo,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	s := &Session{
+		SessionInfo:      session,
+		LocalAS:          p.c.localAS,
+		LocalID:          p.c.routerID,
+		SentCapabilities: p.c.capabilities,
+	}
+	p.mu.Lock()
+	p.session = s
+	p.mu.Unlock()
+	t := time.Now()
+	for _, sink := range p.c.options.sinks {
+		p.c.sinkError(sink.PeerUp(t, s))
+	}
+	return func(peer *corebgp.PeerConfig, update []byte) *corebgp.Notification {
+		t := time.Now()
+		for _, sink := range p.c.options.sinks {
+			p.c.sinkError(sink.Update(t, s, update))
+		}
+		return nil
+	}
+}
+
+func (p *plugin) OnClose(peer *corebgp.PeerConfig) {
+	p.mu.Lock()
+	s := p.session
+	p.session = nil
+	p.mu.Unlock()
+	if s == nil {
+		return
+	}
+	t := time.Now()
+	for _, sink := range p.c.options.sinks {
+		p.c.sinkError(sink.PeerDown(t, s))
+	}
+}
@@ -0,0 +1,271 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// https://tools.ietf.org/html/rfc6396#section-4.4
+const (
+	mrtTypeBGP4MP            = 16
+	mrtSubtypeMessage        = 1
+	mrtSubtypeMessageAS4     = 4
+	mrtSubtypeStateChangeAS4 = 5
+	mrtHeaderLength          = 12
+)
+
+const asTrans = 23456
+
+// MRTWriter writes BGP4MP MRT records (RFC6396) for sessions of a Collector.
+// Each record is written with a single call to the underlying io.Writer.
+type MRTWriter struct {
+	w io.Writer
+}
+
+// NewMRTWriter returns an MRTWriter writing to w.
+func NewMRTWriter(w io.Writer) *MRTWriter {
+	return &MRTWriter{
+		w: w,
+	}
+}
+
+// bgp4mpHeader appends the BGP4MP peer header of s to b, encoding AS
+// numbers in four octets if as4 is true.
+func bgp4mpHeader(b []byte, s *Session, as4 bool) []byte {
+	if as4 {
+		b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-8:], s.RemoteAS)
+		binary.BigEndian.PutUint32(b[len(b)-4:], s.LocalAS)
+	} else {
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint16(b[len(b)-4:], twoOctetAS(s.RemoteAS))
+		binary.BigEndian.PutUint16(b[len(b)-2:], twoOctetAS(s.LocalAS))
+	}
+	peerIP, localIP := s.PeerAddress(), s.LocalAddress()
+	afi := corebgp.AFIIPv4
+	if peerIP.To4() == nil || (localIP != nil && localIP.To4() == nil) {
+		afi = corebgp.AFIIPv6
+	}
+	// interface index
+	b = append(b, 0, 0, uint8(afi>>8), uint8(afi))
+	for _, ip := range []net.IP{peerIP, localIP} {
+		if afi == corebgp.AFIIPv4 {
+			if ip == nil {
+				ip = net.IPv4zero
+			}
+			b = append(b, ip.To4()...)
+		} else {
+			if ip == nil {
+				ip = net.IPv6zero
+			}
+			b = append(b, ip.To16()...)
+		}
+	}
+	return b
+}
+
+func twoOctetAS(asn uint32) uint16 {
+	if asn > math.MaxUint16 {
+		return asTrans
+	}
+	return uint16(asn)
+}
+
+func (m *MRTWriter) writeRecord(t time.Time, subtype uint16,
+	body []byte) error {
+	b := make([]byte, mrtHeaderLength, mrtHeaderLength+len(body))
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()))
+	binary.BigEndian.PutUint16(b[4:], mrtTypeBGP4MP)
+	binary.BigEndian.PutUint16(b[6:], subtype)
+	binary.BigEndian.PutUint32(b[8:], uint32(len(body)))
+	_, err := m.w.Write(append(b, body...))
+	return err
+}
+
+// mrtState returns the MRT encoding of state, which matches FSMState for all
+// but DisabledState.
+func mrtState(state corebgp.FSMState) uint16 {
+	if state == corebgp.DisabledState {
+		return uint16(corebgp.IdleState)
+	}
+	return uint16(state)
+}
+
+// WriteStateChange writes a BGP4MP_STATE_CHANGE_AS4 record for s.
+func (m *MRTWriter) WriteStateChange(t time.Time, s *Session, from,
+	to corebgp.FSMState) error {
+	b := bgp4mpHeader(make([]byte, 0, 44), s, true)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(b[len(b)-4:], mrtState(from))
+	binary.BigEndian.PutUint16(b[len(b)-2:], mrtState(to))
+	return m.writeRecord(t, mrtSubtypeStateChangeAS4, b)
+}
+
+// WriteMessage writes a BGP4MP_MESSAGE_AS4 record, or a BGP4MP_MESSAGE
+// record if four-octet AS numbers were not negotiated for s, for a message of
+// msgType received from the peer of s. body must not include the header.
+func (m *MRTWriter) WriteMessage(t time.Time, s *Session, msgType uint8,
+	body []byte) error {
+	subtype := uint16(mrtSubtypeMessage)
+	if s.FourOctetAS {
+		subtype = mrtSubtypeMessageAS4
+	}
+	b := bgp4mpHeader(make([]byte, 0, 40+corebgp.HeaderLength+len(body)), s,
+		s.FourOctetAS)
+	b = append(b, frame(msgType, body)...)
+	return m.writeRecord(t, subtype, b)
+}
+
+// DefaultMRTInterval is the default rotation interval of an MRTFileSink.
+const DefaultMRTInterval = 15 * time.Minute
+
+var errSinkClosed = errors.New("sink closed")
+
+// MRTFileSink is a Sink writing MRT records to files rotated at a fixed
+// interval, in the manner of the update dumps of RIPE RIS and RouteViews.
+// Session state changes are written as BGP4MP_STATE_CHANGE_AS4 records and
+// UPDATE messages as BGP4MP_MESSAGE_AS4 records.
+type MRTFileSink struct {
+	pattern  string
+	interval time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	start  time.Time
+	file   *os.File
+	buf    *bufio.Writer
+	gz     *gzip.Writer
+	w      *MRTWriter
+	timer  *time.Timer
+}
+
+// NewMRTFileSink returns an MRTFileSink writing to files named by formatting
+// the UTC start time of their interval with pattern, a time.Format layout,
+// e.g. "mrt/2006.01/updates.20060102.1504.gz". The entire pattern is a
+// layout, so directory names must not contain layout elements such as digits
+// unintentionally. Missing directories are created. Files with a ".gz" suffix
+// are gzip compressed. Files are rotated every interval, or
+// DefaultMRTInterval if interval is zero.
+func NewMRTFileSink(pattern string, interval time.Duration) *MRTFileSink {
+	if interval <= 0 {
+		interval = DefaultMRTInterval
+	}
+	return &MRTFileSink{
+		pattern:  pattern,
+		interval: interval,
+	}
+}
+
+// open opens the file for the interval starting at start, m.mu must be held.
+func (m *MRTFileSink) open(start time.Time) error {
+	name := start.UTC().Format(m.pattern)
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	m.start = start
+	m.file = f
+	m.buf = bufio.NewWriter(f)
+	var w io.Writer = m.buf
+	if strings.HasSuffix(name, ".gz") {
+		m.gz = gzip.NewWriter(m.buf)
+		w = m.gz
+	}
+	m.w = NewMRTWriter(w)
+	// close the file at the end of its interval even if no further records
+	// are written
+	m.timer = time.AfterFunc(time.Until(start.Add(m.interval)), func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.w != nil && m.start.Equal(start) {
+			m.closeFile()
+		}
+	})
+	return nil
+}
+
+// closeFile closes the current file, m.mu must be held.
+func (m *MRTFileSink) closeFile() error {
+	if m.w == nil {
+		return nil
+	}
+	m.timer.Stop()
+	var err error
+	if m.gz != nil {
+		err = m.gz.Close()
+	}
+	if ferr := m.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := m.file.Close(); err == nil {
+		err = cerr
+	}
+	m.file, m.buf, m.gz, m.w, m.timer = nil, nil, nil, nil, nil
+	return err
+}
+
+func (m *MRTFileSink) write(t time.Time, fn func(w *MRTWriter) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errSinkClosed
+	}
+	start := t.Truncate(m.interval)
+	if m.w != nil && start.After(m.start) {
+		err := m.closeFile()
+		if err != nil {
+			return err
+		}
+	}
+	if m.w == nil {
+		err := m.open(start)
+		if err != nil {
+			return err
+		}
+	}
+	return fn(m.w)
+}
+
+func (m *MRTFileSink) PeerUp(t time.Time, s *Session) error {
+	return m.write(t, func(w *MRTWriter) error {
+		return w.WriteStateChange(t, s, corebgp.OpenConfirmState,
+			corebgp.EstablishedState)
+	})
+}
+
+func (m *MRTFileSink) Update(t time.Time, s *Session, update []byte) error {
+	return m.write(t, func(w *MRTWriter) error {
+		return w.WriteMessage(t, s, corebgp.UpdateMessageType, update)
+	})
+}
+
+func (m *MRTFileSink) PeerDown(t time.Time, s *Session) error {
+	return m.write(t, func(w *MRTWriter) error {
+		return w.WriteStateChange(t, s, corebgp.EstablishedState,
+			corebgp.IdleState)
+	})
+}
+
+// Close closes the current file.
+func (m *MRTFileSink) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return m.closeFile()
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Session describes an established session of a Collector.
+type Session struct {
+	*corebgp.SessionInfo
+	// LocalAS and LocalID are the AS and BGP Identifier of the Collector.
+	LocalAS uint32
+	LocalID net.IP
+	// SentCapabilities are the capabilities advertised to the peer.
+	SentCapabilities []*corebgp.Capability
+}
+
+// PeerAddress returns the address of the peer.
+func (s *Session) PeerAddress() net.IP {
+	return addrIP(s.RemoteAddr, s.Peer)
+}
+
+// LocalAddress returns the local address of the session's connection.
+func (s *Session) LocalAddress() net.IP {
+	return addrIP(s.LocalAddr, nil)
+}
+
+func addrIP(addr net.Addr, fallback net.IP) net.IP {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return a.IP
+	}
+	return fallback
+}
+
+// Sink receives the sessions and UPDATE messages of a Collector. Methods are
+// called concurrently for different peers from the peers' FSMs and should
+// not block for long.
+type Sink interface {
+	// PeerUp is called when a session is established.
+	PeerUp(t time.Time, s *Session) error
+	// Update is called with the body of each UPDATE message received from
+	// the peer of s.
+	Update(t time.Time, s *Session, update []byte) error
+	// PeerDown is called when a session is closed.
+	PeerDown(t time.Time, s *Session) error
+	// Close is called when the Collector is closed, after all sessions have
+	// been closed.
+	Close() error
+}
+
+// Tap is a Sink calling its non-nil functions, e.g. to hand messages to an
+// application.
+type Tap struct {
+	OnPeerUp   func(t time.Time, s *Session)
+	OnUpdate   func(t time.Time, s *Session, update []byte)
+	OnPeerDown func(t time.Time, s *Session)
+}
+
+func (t Tap) PeerUp(ts time.Time, s *Session) error {
+	if t.OnPeerUp != nil {
+		t.OnPeerUp(ts, s)
+	}
+	return nil
+}
+
+func (t Tap) Update(ts time.Time, s *Session, update []byte) error {
+	if t.OnUpdate != nil {
+		t.OnUpdate(ts, s, update)
+	}
+	return nil
+}
+
+func (t Tap) PeerDown(ts time.Time, s *Session) error {
+	if t.OnPeerDown != nil {
+		t.OnPeerDown(ts, s)
+	}
+	return nil
+}
+
+func (t Tap) Close() error {
+	return nil
+}
+
+// frame returns the BGP message of msgType with body, including the header.
+func frame(msgType uint8, body []byte) []byte {
+	b := make([]byte, corebgp.HeaderLength, corebgp.HeaderLength+len(body))
+	for i := 0; i < 16; i++ {
+		b[i] = 0xff
+	}
+	binary.BigEndian.PutUint16(b[16:], uint16(corebgp.HeaderLength+len(body)))
+	b[18] = msgType
+	return append(b, body...)
+}
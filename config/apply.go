@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// PluginFactory returns the Plugin for a peer added by an Applier. The Plugin
+// should advertise the capabilities returned by peer.Capabilities.List().
+type PluginFactory func(peer *Peer) corebgp.Plugin
+
+// PeerOptionsFactory returns PeerOptions for a peer added by an Applier in
+// addition to those of its configuration, e.g. an InboundPolicy.
+type PeerOptionsFactory func(peer *Peer) []corebgp.PeerOption
+
+// ApplyResult lists the keys of peers changed by Applier.Apply, in the form of
+// corebgp.PeerKey.String().
+type ApplyResult struct {
+	Added   []string
+	Updated []string
+	Removed []string
+}
+
+// Applier applies Configs to a running corebgp.Server.
+type Applier struct {
+	server    *corebgp.Server
+	newPlugin PluginFactory
+	newOpts   PeerOptionsFactory
+	// the last applied Peer by key
+	applied map[string]Peer
+}
+
+// NewApplier returns an Applier for server. newPlugin provides the Plugin for
+// added peers.
+func NewApplier(server *corebgp.Server, newPlugin PluginFactory) *Applier {
+	return &Applier{
+		server:    server,
+		newPlugin: newPlugin,
+		applied:   make(map[string]Peer),
+	}
+}
+
+// SetPeerOptions sets the PeerOptionsFactory providing additional PeerOptions
+// for peers added by subsequent calls to Apply.
+func (a *Applier) SetPeerOptions(newOpts PeerOptionsFactory) {
+	a.newOpts = newOpts
+}
+
+// matches returns true if the running peer described by s matches p.
+func matches(s corebgp.PeerStatus, p *Peer) bool {
+	if s.Config.LocalAS != p.LocalAS || s.Config.RemoteAS != p.RemoteAS {
+		return false
+	}
+	o := s.Options
+	idleHoldTime := time.Duration(p.Timers.IdleHoldTime)
+	if idleHoldTime == 0 {
+		idleHoldTime = corebgp.DefaultIdleHoldTime
+	}
+	localAddress := ""
+	if o.LocalAddress != nil {
+		localAddress = o.LocalAddress.String()
+	}
+	port := p.Transport.Port
+	if port == 0 {
+		port = corebgp.DefaultPort
+	}
+	allowASIn := -1
+	if p.AllowASIn != nil {
+		allowASIn = *p.AllowASIn
+	}
+	runningAllowASIn := -1
+	if o.ASLoopCheck {
+		runningAllowASIn = o.AllowASIn
+	}
+	return o.IdleHoldTime == idleHoldTime &&
+		o.Passive == p.Transport.Passive &&
+		o.ActiveOnly == p.Transport.ActiveOnly &&
+		localAddress == normalizeIP(p.Transport.LocalAddress) &&
+		o.Port == port &&
+		o.FwMark == p.Transport.FwMark &&
+		o.TrafficClass == p.Transport.TrafficClass &&
+		o.FlowLabel == p.Transport.FlowLabel &&
+		o.VRF == p.Transport.VRF &&
+		o.DynamicCapability == p.Capabilities.DynamicCapability &&
+		runningAllowASIn == allowASIn &&
+		o.ASOverride == p.ASOverride &&
+		o.UpdateRateAlarm == p.UpdateRateAlarm
+}
+
+func (a *Applier) addPeer(p *Peer) error {
+	config, err := p.PeerConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := p.PeerOptions()
+	if err != nil {
+		return err
+	}
+	if a.newOpts != nil {
+		opts = append(opts, a.newOpts(p)...)
+	}
+	return a.server.AddPeer(config, a.newPlugin(p), opts...)
+}
+
+// Apply validates c and applies its peers to the Server. Peers present in
+// the Server but not in c are deleted, peers in c but not in the Server are
+// added, and peers whose configuration differs are deleted and re-added,
+// resetting any session. Peers are compared against the running Server as
+// well as the Config last applied, so capability changes also cause a peer to
+// be re-added. Dynamic peers accepted via Server.AcceptDynamicPeers are left
+// untouched. Server configuration is not applied.
+//
+// If an error occurs Apply stops, returning the changes made so far.
+func (a *Applier) Apply(c *Config) (*ApplyResult, error) {
+	result := &ApplyResult{}
+	err := c.Validate()
+	if err != nil {
+		return result, err
+	}
+	desired := make(map[string]*Peer, len(c.Peers))
+	for i := range c.Peers {
+		// validated above
+		k, _ := c.Peers[i].Key()
+		desired[k.String()] = &c.Peers[i]
+	}
+	running := make(map[string]corebgp.PeerStatus)
+	for _, s := range a.server.ListPeers() {
+		if s.Dynamic {
+			// added via AcceptDynamicPeers rather than configured
+			continue
+		}
+		running[s.Key().String()] = s
+	}
+
+	for key, s := range running {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		err = a.server.DeletePeerByKey(s.Key())
+		if err != nil {
+			return result, fmt.Errorf("error deleting peer %s: %v", key, err)
+		}
+		delete(a.applied, key)
+		result.Removed = append(result.Removed, key)
+	}
+
+	for i := range c.Peers {
+		p := &c.Peers[i]
+		k, _ := p.Key()
+		key := k.String()
+		s, exists := running[key]
+		if exists {
+			applied, known := a.applied[key]
+			if matches(s, p) && (!known || reflect.DeepEqual(&applied, p)) {
+				a.applied[key] = *p
+				continue
+			}
+			err = a.server.DeletePeerByKey(s.Key())
+			if err != nil {
+				return result, fmt.Errorf("error deleting peer %s: %v", key,
+					err)
+			}
+			delete(a.applied, key)
+		}
+		err = a.addPeer(p)
+		if err != nil {
+			return result, fmt.Errorf("error adding peer %s: %v", key, err)
+		}
+		a.applied[key] = *p
+		if exists {
+			result.Updated = append(result.Updated, key)
+		} else {
+			result.Added = append(result.Added, key)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,444 @@
+// Package openconfig converts between the OpenConfig BGP model
+// (openconfig-bgp) and corebgp configuration. The types in this package
+// represent the subset of the model relevant to corebgp in its RFC7951 JSON
+// encoding, so a /network-instances/network-instance/protocols/protocol/bgp
+// subtree can be decoded directly with encoding/json.
+package openconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/config"
+)
+
+// Decimal is a YANG decimal64 value. RFC7951 encodes decimal64 as a string,
+// though a JSON number is also accepted.
+type Decimal float64
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatFloat(float64(d), 'f', -1, 64))
+}
+
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid decimal64: %s", b)
+	}
+	*d = Decimal(f)
+	return nil
+}
+
+// BGP is the openconfig-bgp bgp container.
+type BGP struct {
+	Global     Global     `json:"global"`
+	Neighbors  Neighbors  `json:"neighbors"`
+	PeerGroups PeerGroups `json:"peer-groups"`
+}
+
+type Global struct {
+	Config   GlobalConfig `json:"config"`
+	AfiSafis AfiSafis     `json:"afi-safis"`
+}
+
+type GlobalConfig struct {
+	AS       uint32 `json:"as"`
+	RouterID string `json:"router-id,omitempty"`
+}
+
+type Neighbors struct {
+	Neighbor []Neighbor `json:"neighbor,omitempty"`
+}
+
+type Neighbor struct {
+	NeighborAddress string         `json:"neighbor-address"`
+	Config          NeighborConfig `json:"config"`
+	Timers          Timers         `json:"timers"`
+	Transport       Transport      `json:"transport"`
+	AfiSafis        AfiSafis       `json:"afi-safis"`
+	AsPathOptions   AsPathOptions  `json:"as-path-options"`
+}
+
+type NeighborConfig struct {
+	NeighborAddress string `json:"neighbor-address,omitempty"`
+	PeerGroup       string `json:"peer-group,omitempty"`
+	PeerAS          uint32 `json:"peer-as,omitempty"`
+	LocalAS         uint32 `json:"local-as,omitempty"`
+	Description     string `json:"description,omitempty"`
+	AuthPassword    string `json:"auth-password,omitempty"`
+	// Enabled defaults to true. Disabled neighbors are omitted by ToConfig.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+type PeerGroups struct {
+	PeerGroup []PeerGroup `json:"peer-group,omitempty"`
+}
+
+type PeerGroup struct {
+	PeerGroupName string          `json:"peer-group-name"`
+	Config        PeerGroupConfig `json:"config"`
+	Timers        Timers          `json:"timers"`
+	Transport     Transport       `json:"transport"`
+	AfiSafis      AfiSafis        `json:"afi-safis"`
+	AsPathOptions AsPathOptions   `json:"as-path-options"`
+}
+
+type PeerGroupConfig struct {
+	PeerGroupName string `json:"peer-group-name,omitempty"`
+	PeerAS        uint32 `json:"peer-as,omitempty"`
+	LocalAS       uint32 `json:"local-as,omitempty"`
+	Description   string `json:"description,omitempty"`
+	AuthPassword  string `json:"auth-password,omitempty"`
+}
+
+type Timers struct {
+	Config TimersConfig `json:"config"`
+}
+
+type TimersConfig struct {
+	ConnectRetry      *Decimal `json:"connect-retry,omitempty"`
+	HoldTime          *Decimal `json:"hold-time,omitempty"`
+	KeepaliveInterval *Decimal `json:"keepalive-interval,omitempty"`
+	// IdleHoldTimeAfterReset maps to corebgp's idle hold time.
+	IdleHoldTimeAfterReset *Decimal `json:"idle-hold-time-after-reset,omitempty"`
+}
+
+type Transport struct {
+	Config TransportConfig `json:"config"`
+}
+
+type TransportConfig struct {
+	LocalAddress string `json:"local-address,omitempty"`
+	PassiveMode  *bool  `json:"passive-mode,omitempty"`
+	RemotePort   uint16 `json:"remote-port,omitempty"`
+}
+
+type AfiSafis struct {
+	AfiSafi []AfiSafi `json:"afi-safi,omitempty"`
+}
+
+type AfiSafi struct {
+	AfiSafiName string        `json:"afi-safi-name"`
+	Config      AfiSafiConfig `json:"config"`
+}
+
+type AfiSafiConfig struct {
+	AfiSafiName string `json:"afi-safi-name,omitempty"`
+	// Enabled defaults to false per the model.
+	Enabled bool `json:"enabled"`
+}
+
+type AsPathOptions struct {
+	Config AsPathOptionsConfig `json:"config"`
+}
+
+type AsPathOptionsConfig struct {
+	AllowOwnAS    *uint8 `json:"allow-own-as,omitempty"`
+	ReplacePeerAS *bool  `json:"replace-peer-as,omitempty"`
+}
+
+// the openconfig-bgp-types AFI_SAFI_TYPE identities and their config address
+// family names
+var (
+	afiSafiNames = map[string]string{
+		"IPV4_UNICAST":         "ipv4-unicast",
+		"IPV6_UNICAST":         "ipv6-unicast",
+		"IPV4_LABELED_UNICAST": "ipv4-labeled",
+		"IPV6_LABELED_UNICAST": "ipv6-labeled",
+		"L3VPN_IPV4_UNICAST":   "ipv4-vpn",
+		"L3VPN_IPV6_UNICAST":   "ipv6-vpn",
+		"L3VPN_IPV4_MULTICAST": "ipv4-mvpn",
+		"L3VPN_IPV6_MULTICAST": "ipv6-mvpn",
+		"L2VPN_VPLS":           "l2vpn-vpls",
+		"L2VPN_EVPN":           "l2vpn-evpn",
+		"IPV4_FLOWSPEC":        "ipv4-flowspec",
+		"VPNV4_FLOWSPEC":       "ipv4-flowspec-vpn",
+		"LINKSTATE":            "link-state",
+		"SRTE_POLICY_IPV4":     "ipv4-sr-policy",
+		"SRTE_POLICY_IPV6":     "ipv6-sr-policy",
+	}
+)
+
+const (
+	afiSafiTypesPrefix = "openconfig-bgp-types:"
+	// corebgp's fixed ConnectRetryTimer value
+	connectRetryTime = time.Second * 5
+)
+
+// addressFamily returns the config address family name of an AFI_SAFI_TYPE
+// identity, which may be prefixed with its module name.
+func addressFamily(identity string) (string, error) {
+	name, ok := afiSafiNames[strings.TrimPrefix(identity, afiSafiTypesPrefix)]
+	if !ok {
+		return "", fmt.Errorf("unsupported afi-safi: %q", identity)
+	}
+	return name, nil
+}
+
+// afiSafiIdentity returns the AFI_SAFI_TYPE identity of a config address
+// family name.
+func afiSafiIdentity(addressFamily string) (string, error) {
+	for identity, name := range afiSafiNames {
+		if name == addressFamily {
+			return afiSafiTypesPrefix + identity, nil
+		}
+	}
+	return "", fmt.Errorf("address family %q has no openconfig afi-safi",
+		addressFamily)
+}
+
+// enabledAddressFamilies returns the config address family names of the
+// enabled entries of a, sorted.
+func enabledAddressFamilies(a AfiSafis) ([]string, error) {
+	families := make([]string, 0)
+	for _, as := range a.AfiSafi {
+		if !as.Config.Enabled {
+			continue
+		}
+		name, err := addressFamily(as.AfiSafiName)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, name)
+	}
+	sort.Strings(families)
+	return families, nil
+}
+
+func seconds(d *Decimal) time.Duration {
+	return time.Duration(float64(*d) * float64(time.Second))
+}
+
+// applyTimers applies t to p. corebgp does not support configuring the hold
+// time, keepalive interval or connect retry timer, so only their corebgp
+// default values are accepted.
+func applyTimers(p *config.Peer, t TimersConfig) error {
+	if t.HoldTime != nil && seconds(t.HoldTime) != corebgp.DefaultHoldTime {
+		return fmt.Errorf("unsupported hold-time: %v", float64(*t.HoldTime))
+	}
+	if t.KeepaliveInterval != nil &&
+		seconds(t.KeepaliveInterval) != corebgp.DefaultHoldTime/3 {
+		return fmt.Errorf("unsupported keepalive-interval: %v",
+			float64(*t.KeepaliveInterval))
+	}
+	if t.ConnectRetry != nil && seconds(t.ConnectRetry) != connectRetryTime {
+		return fmt.Errorf("unsupported connect-retry: %v",
+			float64(*t.ConnectRetry))
+	}
+	if t.IdleHoldTimeAfterReset != nil {
+		p.Timers.IdleHoldTime = config.Duration(
+			seconds(t.IdleHoldTimeAfterReset))
+	}
+	return nil
+}
+
+func applyTransport(p *config.Peer, t TransportConfig) {
+	if t.LocalAddress != "" {
+		p.Transport.LocalAddress = t.LocalAddress
+	}
+	if t.PassiveMode != nil {
+		p.Transport.Passive = *t.PassiveMode
+	}
+	if t.RemotePort != 0 {
+		p.Transport.Port = int(t.RemotePort)
+	}
+}
+
+func applyAsPathOptions(p *config.Peer, o AsPathOptionsConfig) {
+	if o.AllowOwnAS != nil {
+		allowASIn := int(*o.AllowOwnAS)
+		p.AllowASIn = &allowASIn
+	}
+	if o.ReplacePeerAS != nil {
+		p.ASOverride = *o.ReplacePeerAS
+	}
+}
+
+// ToConfig converts b to a config.Config. Neighbor values take precedence
+// over those of their peer-group, which take precedence over global values.
+// Disabled neighbors are omitted. An error is returned for values corebgp
+// does not support.
+func (b *BGP) ToConfig() (*config.Config, error) {
+	c := &config.Config{
+		Server: config.ServerConfig{
+			RouterID: b.Global.Config.RouterID,
+		},
+		Peers: make([]config.Peer, 0, len(b.Neighbors.Neighbor)),
+	}
+	groups := make(map[string]*PeerGroup, len(b.PeerGroups.PeerGroup))
+	for i := range b.PeerGroups.PeerGroup {
+		g := &b.PeerGroups.PeerGroup[i]
+		groups[g.PeerGroupName] = g
+	}
+	globalFamilies, err := enabledAddressFamilies(b.Global.AfiSafis)
+	if err != nil {
+		return nil, fmt.Errorf("global: %v", err)
+	}
+	for _, n := range b.Neighbors.Neighbor {
+		if n.Config.Enabled != nil && !*n.Config.Enabled {
+			continue
+		}
+		p, err := neighborToPeer(&n, groups, b.Global.Config.AS,
+			globalFamilies)
+		if err != nil {
+			return nil, fmt.Errorf("neighbor %s: %v", n.NeighborAddress, err)
+		}
+		c.Peers = append(c.Peers, p)
+	}
+	return c, nil
+}
+
+func neighborToPeer(n *Neighbor, groups map[string]*PeerGroup, globalAS uint32,
+	globalFamilies []string) (config.Peer, error) {
+	p := config.Peer{
+		Address: n.NeighborAddress,
+		LocalAS: globalAS,
+		Capabilities: config.Capabilities{
+			AddressFamilies: globalFamilies,
+		},
+	}
+	if n.Config.PeerGroup != "" {
+		g, ok := groups[n.Config.PeerGroup]
+		if !ok {
+			return p, fmt.Errorf("unknown peer-group: %q", n.Config.PeerGroup)
+		}
+		if g.Config.PeerAS != 0 {
+			p.RemoteAS = g.Config.PeerAS
+		}
+		if g.Config.LocalAS != 0 {
+			p.LocalAS = g.Config.LocalAS
+		}
+		p.Description = g.Config.Description
+		p.Transport.MD5Password = g.Config.AuthPassword
+		err := applyTimers(&p, g.Timers.Config)
+		if err != nil {
+			return p, err
+		}
+		applyTransport(&p, g.Transport.Config)
+		applyAsPathOptions(&p, g.AsPathOptions.Config)
+		if len(g.AfiSafis.AfiSafi) > 0 {
+			p.Capabilities.AddressFamilies, err =
+				enabledAddressFamilies(g.AfiSafis)
+			if err != nil {
+				return p, err
+			}
+		}
+	}
+	if n.Config.PeerAS != 0 {
+		p.RemoteAS = n.Config.PeerAS
+	}
+	if n.Config.LocalAS != 0 {
+		p.LocalAS = n.Config.LocalAS
+	}
+	if n.Config.Description != "" {
+		p.Description = n.Config.Description
+	}
+	if n.Config.AuthPassword != "" {
+		p.Transport.MD5Password = n.Config.AuthPassword
+	}
+	err := applyTimers(&p, n.Timers.Config)
+	if err != nil {
+		return p, err
+	}
+	applyTransport(&p, n.Transport.Config)
+	applyAsPathOptions(&p, n.AsPathOptions.Config)
+	if len(n.AfiSafis.AfiSafi) > 0 {
+		p.Capabilities.AddressFamilies, err = enabledAddressFamilies(n.AfiSafis)
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// FromConfig converts c to a BGP. The global AS is set to the local AS of the
+// peers if they all share one, otherwise local-as is set per neighbor. Peer
+// groups are not used. An error is returned for configuration the model
+// cannot represent.
+func FromConfig(c *config.Config) (*BGP, error) {
+	b := &BGP{
+		Global: Global{
+			Config: GlobalConfig{
+				RouterID: c.Server.RouterID,
+			},
+		},
+	}
+	sharedAS := len(c.Peers) > 0
+	for _, p := range c.Peers {
+		if p.LocalAS != c.Peers[0].LocalAS {
+			sharedAS = false
+			break
+		}
+	}
+	if sharedAS {
+		b.Global.Config.AS = c.Peers[0].LocalAS
+	}
+	for _, p := range c.Peers {
+		if p.Capabilities.RouteRefresh || p.Capabilities.EnhancedRouteRefresh ||
+			p.Capabilities.ExtendedMessage || p.Capabilities.DynamicCapability ||
+			p.UpdateRateAlarm != 0 {
+			return nil, fmt.Errorf("peer %s: capabilities and options "+
+				"other than address families have no openconfig equivalent",
+				p.Address)
+		}
+		n := Neighbor{
+			NeighborAddress: p.Address,
+			Config: NeighborConfig{
+				NeighborAddress: p.Address,
+				PeerAS:          p.RemoteAS,
+				Description:     p.Description,
+				AuthPassword:    p.Transport.MD5Password,
+			},
+			Transport: Transport{
+				Config: TransportConfig{
+					LocalAddress: p.Transport.LocalAddress,
+					RemotePort:   uint16(p.Transport.Port),
+				},
+			},
+		}
+		if !sharedAS {
+			n.Config.LocalAS = p.LocalAS
+		}
+		if p.Transport.Passive {
+			passive := true
+			n.Transport.Config.PassiveMode = &passive
+		}
+		if p.Timers.IdleHoldTime != 0 {
+			d := Decimal(time.Duration(p.Timers.IdleHoldTime).Seconds())
+			n.Timers.Config.IdleHoldTimeAfterReset = &d
+		}
+		if p.AllowASIn != nil {
+			if *p.AllowASIn > 255 {
+				return nil, fmt.Errorf("peer %s: allow_as_in exceeds 255",
+					p.Address)
+			}
+			allowOwnAS := uint8(*p.AllowASIn)
+			n.AsPathOptions.Config.AllowOwnAS = &allowOwnAS
+		}
+		if p.ASOverride {
+			replacePeerAS := true
+			n.AsPathOptions.Config.ReplacePeerAS = &replacePeerAS
+		}
+		for _, family := range p.Capabilities.AddressFamilies {
+			identity, err := afiSafiIdentity(strings.ToLower(family))
+			if err != nil {
+				return nil, fmt.Errorf("peer %s: %v", p.Address, err)
+			}
+			n.AfiSafis.AfiSafi = append(n.AfiSafis.AfiSafi, AfiSafi{
+				AfiSafiName: identity,
+				Config: AfiSafiConfig{
+					AfiSafiName: identity,
+					Enabled:     true,
+				},
+			})
+		}
+		b.Neighbors.Neighbor = append(b.Neighbors.Neighbor, n)
+	}
+	return b, nil
+}
@@ -0,0 +1,858 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// NLRI is a prefix carried by a DecodedUpdate.
+type NLRI struct {
+	// PathID is the path identifier of the prefix if ADD-PATH (RFC7911) was
+	// negotiated for receiving its AFI/SAFI, otherwise zero.
+	PathID uint32
+	Prefix *net.IPNet
+}
+
+// PathAttr is a path attribute of a DecodedUpdate. Value references the
+// UPDATE message.
+type PathAttr struct {
+	Flags AttrFlags
+	Type  uint8
+	Value []byte
+	// Decoded is the value decoded by the PathAttrType registered for Type
+	// via RegisterPathAttr, if any.
+	Decoded interface{}
+}
+
+// MPNLRI is the content of an MP_REACH_NLRI or MP_UNREACH_NLRI attribute
+// (RFC4760).
+type MPNLRI struct {
+	AFI  uint16
+	SAFI uint8
+	// NextHop is the network address of next hop field of an MP_REACH_NLRI
+	// attribute, e.g. a global IPv6 address optionally followed by a
+	// link-local IPv6 address.
+	NextHop []byte
+	// NLRI contains the decoded prefixes of the IPv4 and IPv6 unicast and
+	// multicast SAFIs. The NLRI of other SAFIs are left in RawNLRI.
+	NLRI    []NLRI
+	RawNLRI []byte
+	// Decoded is the NLRI decoded by the NLRIType registered for AFI/SAFI
+	// via RegisterNLRI, if any. It is nil for an MP_UNREACH_NLRI attribute
+	// the NLRI of MP_REACH_NLRI were merged into due to TreatAsWithdraw.
+	Decoded interface{}
+}
+
+// DecodedUpdate is an UPDATE message decoded by corebgp for a
+// DecodedUpdatePlugin. Slices reference the UPDATE message, which must not be
+// retained beyond the DecodedUpdateHandler call unless copied.
+type DecodedUpdate struct {
+	// Withdrawn contains the prefixes of the withdrawn routes field.
+	Withdrawn []NLRI
+	// Attrs contains the path attributes other than MP_REACH_NLRI and
+	// MP_UNREACH_NLRI, in the order received.
+	Attrs []PathAttr
+	// NLRI contains the prefixes of the NLRI field.
+	NLRI    []NLRI
+	MPReach *MPNLRI
+	// MPUnreach contains the MP_UNREACH_NLRI attribute, if any. If
+	// TreatAsWithdraw is true it also contains the NLRI of the MP_REACH_NLRI
+	// attribute, merged with the MP_UNREACH_NLRI attribute if of the same
+	// AFI/SAFI.
+	MPUnreach []*MPNLRI
+	// TreatAsWithdraw is true if the update is treated as a withdrawal
+	// (RFC7606) due to an error in its path attributes. The NLRI field is
+	// then moved to Withdrawn, MPReach to MPUnreach, and Attrs is empty.
+	TreatAsWithdraw bool
+	// DiscardedAttrs contains the types of path attributes discarded
+	// (RFC7606) due to errors.
+	DiscardedAttrs []uint8
+	// Codec contains the parameters negotiated with the peer that the update
+	// was decoded with.
+	Codec *Codec
+}
+
+// Attr returns the first path attribute of type attrType.
+func (u *DecodedUpdate) Attr(attrType uint8) (PathAttr, bool) {
+	for _, a := range u.Attrs {
+		if a.Type == attrType {
+			return a, true
+		}
+	}
+	return PathAttr{}, false
+}
+
+// EndOfRIB returns the AFI/SAFI of u if it is an End-of-RIB marker. See
+// DecodeEndOfRIB.
+func (u *DecodedUpdate) EndOfRIB() (afi uint16, safi uint8, ok bool) {
+	if len(u.Withdrawn) > 0 || len(u.Attrs) > 0 || len(u.NLRI) > 0 ||
+		u.MPReach != nil || u.TreatAsWithdraw || len(u.DiscardedAttrs) > 0 {
+		return 0, 0, false
+	}
+	switch len(u.MPUnreach) {
+	case 0:
+		return AFIIPv4, SAFIUnicast, true
+	case 1:
+		m := u.MPUnreach[0]
+		if len(m.NLRI) == 0 && len(m.RawNLRI) == 0 {
+			return m.AFI, m.SAFI, true
+		}
+	}
+	return 0, 0, false
+}
+
+// String returns a human-readable representation of the DecodedUpdate, e.g.
+// "withdrawn: [10.0.0.0/8] attrs: [1 (well-known|transitive) value: 00]
+// nlri: [192.0.2.0/24]" on a single line. Values decoded by a registered
+// PathAttrType or NLRIType are included in place of the raw value.
+func (u *DecodedUpdate) String() string {
+	if afi, safi, ok := u.EndOfRIB(); ok {
+		return fmt.Sprintf("End-of-RIB (%d/%d)", afi, safi)
+	}
+	var s []string
+	if len(u.Withdrawn) > 0 {
+		s = append(s, "withdrawn: "+nlriString(u.Withdrawn))
+	}
+	for _, m := range u.MPUnreach {
+		s = append(s, "mp_unreach: "+mpNLRIString(m))
+	}
+	if len(u.Attrs) > 0 {
+		attrs := make([]string, 0, len(u.Attrs))
+		for _, a := range u.Attrs {
+			attr := fmt.Sprintf("%d (%s)", a.Type, a.Flags)
+			if a.Decoded != nil {
+				attr += fmt.Sprintf(" value: %v", a.Decoded)
+			} else if len(a.Value) > 0 {
+				attr += " value: " + hex.EncodeToString(a.Value)
+			}
+			attrs = append(attrs, attr)
+		}
+		s = append(s, "attrs: ["+strings.Join(attrs, ", ")+"]")
+	}
+	if u.MPReach != nil {
+		s = append(s, "mp_reach: "+mpNLRIString(u.MPReach))
+	}
+	if len(u.NLRI) > 0 {
+		s = append(s, "nlri: "+nlriString(u.NLRI))
+	}
+	if u.TreatAsWithdraw {
+		s = append(s, "treat-as-withdraw")
+	}
+	if len(u.DiscardedAttrs) > 0 {
+		s = append(s, fmt.Sprintf("discarded attrs: %v", u.DiscardedAttrs))
+	}
+	return strings.Join(s, " ")
+}
+
+// nlriString returns the prefixes of nlri, e.g. "[192.0.2.0/24 path id 1]".
+func nlriString(nlri []NLRI) string {
+	s := make([]string, 0, len(nlri))
+	for _, n := range nlri {
+		s = append(s, nlriPrefixString(n))
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+func nlriPrefixString(n NLRI) string {
+	var s string
+	if n.Prefix != nil {
+		s = n.Prefix.String()
+	}
+	if n.PathID != 0 {
+		s += fmt.Sprintf(" path id %d", n.PathID)
+	}
+	return s
+}
+
+func mpNLRIString(m *MPNLRI) string {
+	s := fmt.Sprintf("(%d/%d)", m.AFI, m.SAFI)
+	if len(m.NextHop) > 0 {
+		s += " next hop: " + strings.Join(nextHopStrings(m.NextHop), " ")
+	}
+	switch {
+	case m.Decoded != nil:
+		s += fmt.Sprintf(" nlri: %v", m.Decoded)
+	case len(m.NLRI) > 0:
+		s += " nlri: " + nlriString(m.NLRI)
+	case len(m.RawNLRI) > 0:
+		s += " nlri: " + hex.EncodeToString(m.RawNLRI)
+	}
+	return s
+}
+
+// nextHopStrings returns the addresses of the next hop field of an
+// MP_REACH_NLRI attribute, which is returned in hex if not one or two
+// addresses.
+func nextHopStrings(b []byte) []string {
+	switch len(b) {
+	case net.IPv4len, net.IPv6len:
+		return []string{net.IP(b).String()}
+	case 2 * net.IPv6len:
+		return []string{net.IP(b[:net.IPv6len]).String(),
+			net.IP(b[net.IPv6len:]).String()}
+	}
+	return []string{hex.EncodeToString(b)}
+}
+
+type nlriJSON struct {
+	Prefix string `json:"prefix"`
+	PathID uint32 `json:"path_id,omitempty"`
+}
+
+type pathAttrJSON struct {
+	Flags AttrFlags `json:"flags"`
+	Type  uint8     `json:"type"`
+	Value string    `json:"value,omitempty"`
+}
+
+type mpNLRIJSON struct {
+	AFI     uint16     `json:"afi"`
+	SAFI    uint8      `json:"safi"`
+	NextHop []string   `json:"next_hop,omitempty"`
+	NLRI    []nlriJSON `json:"nlri,omitempty"`
+	RawNLRI string     `json:"raw_nlri,omitempty"`
+}
+
+type decodedUpdateJSON struct {
+	Withdrawn       []nlriJSON     `json:"withdrawn,omitempty"`
+	Attrs           []pathAttrJSON `json:"attrs,omitempty"`
+	NLRI            []nlriJSON     `json:"nlri,omitempty"`
+	MPReach         *mpNLRIJSON    `json:"mp_reach,omitempty"`
+	MPUnreach       []mpNLRIJSON   `json:"mp_unreach,omitempty"`
+	TreatAsWithdraw bool           `json:"treat_as_withdraw,omitempty"`
+	DiscardedAttrs  []int          `json:"discarded_attrs,omitempty"`
+}
+
+// MarshalJSON marshals the DecodedUpdate as a JSON object. Prefixes are
+// encoded in CIDR notation, next hops as addresses, and attribute values and
+// NLRI not decoded by corebgp as hex strings. Decoded values and the Codec
+// are not included. The JSON form is output-only, e.g. for logging, and can
+// not be unmarshaled back into a DecodedUpdate; use the UPDATE message
+// itself to retain updates.
+func (u *DecodedUpdate) MarshalJSON() ([]byte, error) {
+	j := &decodedUpdateJSON{
+		Withdrawn:       nlriToJSON(u.Withdrawn),
+		NLRI:            nlriToJSON(u.NLRI),
+		TreatAsWithdraw: u.TreatAsWithdraw,
+	}
+	for _, a := range u.Attrs {
+		j.Attrs = append(j.Attrs, pathAttrJSON{
+			Flags: a.Flags,
+			Type:  a.Type,
+			Value: hex.EncodeToString(a.Value),
+		})
+	}
+	if u.MPReach != nil {
+		m := mpNLRIToJSON(u.MPReach)
+		j.MPReach = &m
+	}
+	for _, m := range u.MPUnreach {
+		j.MPUnreach = append(j.MPUnreach, mpNLRIToJSON(m))
+	}
+	for _, t := range u.DiscardedAttrs {
+		j.DiscardedAttrs = append(j.DiscardedAttrs, int(t))
+	}
+	return json.Marshal(j)
+}
+
+func nlriToJSON(nlri []NLRI) []nlriJSON {
+	if len(nlri) == 0 {
+		return nil
+	}
+	j := make([]nlriJSON, 0, len(nlri))
+	for _, n := range nlri {
+		var prefix string
+		if n.Prefix != nil {
+			prefix = n.Prefix.String()
+		}
+		j = append(j, nlriJSON{
+			Prefix: prefix,
+			PathID: n.PathID,
+		})
+	}
+	return j
+}
+
+func mpNLRIToJSON(m *MPNLRI) mpNLRIJSON {
+	j := mpNLRIJSON{
+		AFI:     m.AFI,
+		SAFI:    m.SAFI,
+		NLRI:    nlriToJSON(m.NLRI),
+		RawNLRI: hex.EncodeToString(m.RawNLRI),
+	}
+	if len(m.NextHop) > 0 {
+		j.NextHop = nextHopStrings(m.NextHop)
+	}
+	return j
+}
+
+// DecodedUpdateHandler handles decoded Update messages. If a non-nil
+// Notification is returned it is handled like one returned by an
+// UpdateMessageHandler.
+type DecodedUpdateHandler func(peer *PeerConfig,
+	update *DecodedUpdate) *Notification
+
+// addPathKey returns the key of an AFI/SAFI in updateDecoder.addPath.
+func addPathKey(afi uint16, safi uint8) uint32 {
+	return uint32(afi)<<8 | uint32(safi)
+}
+
+// updateDecoder decodes UPDATE messages of a session.
+type updateDecoder struct {
+	codec       *Codec
+	fourOctetAS bool
+	// addPath contains the AFI/SAFIs for which path identifiers are present
+	addPath map[uint32]bool
+	// arena is the arena updates are allocated from if DecodeArena was set,
+	// in which case they must be released once handled
+	arena *updateArena
+}
+
+// newUpdateDecoder returns an updateDecoder for the Codec of a session, for
+// updates received from the peer if dir is AddPathReceive, or sent to it if
+// dir is AddPathSend.
+func newUpdateDecoder(c *Codec, dir AddPathDirection) *updateDecoder {
+	d := &updateDecoder{
+		codec:       c,
+		fourOctetAS: c.FourOctetAS,
+		addPath:     make(map[uint32]bool),
+	}
+	for _, t := range c.AddPath {
+		if t.Direction&dir != 0 {
+			d.addPath[addPathKey(t.AFI, t.SAFI)] = true
+		}
+	}
+	return d
+}
+
+var errMalformedNLRI = errors.New("malformed NLRI")
+
+func isPrefixSAFI(afi uint16, safi uint8) bool {
+	return (afi == AFIIPv4 || afi == AFIIPv6) &&
+		(safi == SAFIUnicast || safi == SAFIMulticast)
+}
+
+// decodeNLRI decodes a sequence of prefixes of the given AFI, each preceded by
+// a path identifier if addPath is true, allocating them from a.
+// https://tools.ietf.org/html/rfc4271#section-4.3
+// https://tools.ietf.org/html/rfc7911#section-3
+func decodeNLRI(b []byte, afi uint16, addPath bool,
+	a *updateArena) ([]NLRI, error) {
+	addrLen := net.IPv4len
+	if afi == AFIIPv6 {
+		addrLen = net.IPv6len
+	}
+	var nlri []NLRI
+	start := a.nlriStart()
+	for len(b) > 0 {
+		var pathID uint32
+		if addPath {
+			if len(b) < 4 {
+				return nil, errMalformedNLRI
+			}
+			pathID = binary.BigEndian.Uint32(b)
+			b = b[4:]
+		}
+		if len(b) < 1 {
+			return nil, errMalformedNLRI
+		}
+		bits := int(b[0])
+		octets := (bits + 7) / 8
+		if bits > addrLen*8 || len(b) < 1+octets {
+			return nil, errMalformedNLRI
+		}
+		prefix := a.newPrefix(addrLen)
+		copy(prefix.IP, b[1:1+octets])
+		for i := 0; i < octets; i++ {
+			prefix.Mask[i] = 0xff
+		}
+		if bits%8 != 0 {
+			prefix.Mask[octets-1] = 0xff << (8 - bits%8)
+			prefix.IP[octets-1] &= prefix.Mask[octets-1]
+		}
+		nlri = a.appendNLRI(nlri, start, NLRI{
+			PathID: pathID,
+			Prefix: prefix,
+		})
+		b = b[1+octets:]
+	}
+	return nlri, nil
+}
+
+// decodeMPNLRI decodes the value of an MP_REACH_NLRI or MP_UNREACH_NLRI
+// attribute.
+// https://tools.ietf.org/html/rfc4760#section-3
+// https://tools.ietf.org/html/rfc4760#section-4
+func (d *updateDecoder) decodeMPNLRI(value []byte,
+	reach bool) (*MPNLRI, error) {
+	if len(value) < 3 {
+		return nil, errors.New("malformed MP NLRI attribute")
+	}
+	m := d.arena.newMPNLRI()
+	m.AFI = binary.BigEndian.Uint16(value)
+	m.SAFI = value[2]
+	value = value[3:]
+	if reach {
+		if len(value) < 2 || len(value) < 2+int(value[0]) {
+			return nil, errors.New("malformed MP_REACH_NLRI next hop")
+		}
+		m.NextHop = value[1 : 1+int(value[0])]
+		// skip the next hop and the reserved octet
+		value = value[2+int(value[0]):]
+	}
+	m.RawNLRI = value
+	addPath := d.addPath[addPathKey(m.AFI, m.SAFI)]
+	if isPrefixSAFI(m.AFI, m.SAFI) {
+		nlri, err := decodeNLRI(value, m.AFI, addPath, d.arena)
+		if err != nil {
+			return nil, err
+		}
+		m.NLRI = nlri
+	} else if t, ok := lookupNLRIType(m.AFI, m.SAFI); ok {
+		decoded, err := t.Decode(value, addPath, d.codec)
+		if err != nil {
+			return nil, err
+		}
+		m.Decoded = decoded
+	}
+	return m, nil
+}
+
+// attrAction is the RFC7606 handling of an erroneous path attribute.
+type attrAction uint8
+
+const (
+	attrOK attrAction = iota
+	attrDiscard
+	attrTreatAsWithdraw
+)
+
+// checkAttr checks the value of a path attribute of a known type, returning
+// the RFC7606 handling of an error.
+// https://tools.ietf.org/html/rfc7606#section-7
+func (d *updateDecoder) checkAttr(attrType uint8, value []byte) attrAction {
+	switch attrType {
+	case AttrTypeOrigin:
+		if len(value) != 1 || value[0] > 2 {
+			return attrTreatAsWithdraw
+		}
+	case AttrTypeASPath:
+		if _, err := DecodeASPath(value, d.fourOctetAS); err != nil {
+			return attrTreatAsWithdraw
+		}
+	case AttrTypeAS4Path:
+		if _, err := DecodeASPath(value, true); err != nil {
+			return attrDiscard
+		}
+	case AttrTypeNextHop, AttrTypeMED, AttrTypeLocalPref,
+		AttrTypeOriginatorID:
+		if len(value) != 4 {
+			return attrTreatAsWithdraw
+		}
+	case AttrTypeAtomicAggregate:
+		if len(value) != 0 {
+			return attrDiscard
+		}
+	case AttrTypeAggregator:
+		if (d.fourOctetAS && len(value) != 8) ||
+			(!d.fourOctetAS && len(value) != 6) {
+			return attrDiscard
+		}
+	case AttrTypeAS4Aggregator:
+		if len(value) != 8 {
+			return attrDiscard
+		}
+	case AttrTypeCommunities, AttrTypeClusterList:
+		if len(value) == 0 || len(value)%4 != 0 {
+			return attrTreatAsWithdraw
+		}
+	case AttrTypeExtendedCommunities:
+		if len(value) == 0 || len(value)%8 != 0 {
+			return attrTreatAsWithdraw
+		}
+	case AttrTypeLargeCommunities:
+		if len(value) == 0 || len(value)%12 != 0 {
+			return attrTreatAsWithdraw
+		}
+	}
+	return attrOK
+}
+
+// decodeRegisteredAttr validates and decodes an attribute of a type
+// registered via RegisterPathAttr.
+func (d *updateDecoder) decodeRegisteredAttr(t PathAttrType, flags AttrFlags,
+	attrType uint8, value []byte) (PathAttr, error) {
+	err := ValidateAttrFlags(attrType, flags)
+	if err != nil {
+		return PathAttr{}, err
+	}
+	mask := AttrFlagOptional | AttrFlagTransitive
+	if _, known := DefaultAttrFlags(attrType); !known &&
+		flags&mask != t.Flags&mask {
+		return PathAttr{}, fmt.Errorf("attribute type %d flags %s, "+
+			"expected %s", attrType, flags&mask, t.Flags&mask)
+	}
+	decoded, err := t.Decode(value, d.codec)
+	if err != nil {
+		return PathAttr{}, err
+	}
+	return PathAttr{
+		Flags:   flags,
+		Type:    attrType,
+		Value:   value,
+		Decoded: decoded,
+	}, nil
+}
+
+// decode decodes the UPDATE message body b, applying RFC7606 error handling.
+// A non-nil Notification is returned if the error requires a session reset.
+func (d *updateDecoder) decode(b []byte) (*DecodedUpdate, *Notification) {
+	malformed := func() *Notification {
+		return newNotification(NotifCodeUpdateMessageErr,
+			NotifSubcodeMalformedAttr, nil)
+	}
+	withdrawn, attrs, nlri, err := splitUpdate(b)
+	if err != nil {
+		return nil, malformed()
+	}
+	u := d.arena.newUpdate(d.codec)
+	addPathIPv4 := d.addPath[addPathKey(AFIIPv4, SAFIUnicast)]
+	u.Withdrawn, err = decodeNLRI(withdrawn, AFIIPv4, addPathIPv4, d.arena)
+	if err != nil {
+		return nil, malformed()
+	}
+	u.NLRI, err = decodeNLRI(nlri, AFIIPv4, addPathIPv4, d.arena)
+	if err != nil {
+		return nil, newNotification(NotifCodeUpdateMessageErr,
+			NotifSubcodeInvalidNetworkField, nil)
+	}
+
+	var (
+		seen      [256]bool
+		notif     *Notification
+		hasMPAttr bool
+	)
+	err = rangePathAttrs(attrs, func(flags AttrFlags, attrType uint8,
+		value, raw []byte) bool {
+		if attrType == AttrTypeMPReachNLRI ||
+			attrType == AttrTypeMPUnreachNLRI {
+			// https://tools.ietf.org/html/rfc7606#section-3 (g)
+			if seen[attrType] {
+				notif = malformed()
+				return false
+			}
+			seen[attrType] = true
+			hasMPAttr = true
+			// the NLRI of an attribute with conflicting flags cannot be
+			// withdrawn reliably
+			// https://tools.ietf.org/html/rfc7606#section-3 (c)
+			if ValidateAttrFlags(attrType, flags) != nil {
+				notif = newNotification(NotifCodeUpdateMessageErr,
+					NotifSubcodeAttrFlagsError, raw)
+				return false
+			}
+			m, err := d.decodeMPNLRI(value, attrType == AttrTypeMPReachNLRI)
+			if err != nil {
+				notif = newNotification(NotifCodeUpdateMessageErr,
+					NotifSubcodeOptionalAttrError, nil)
+				return false
+			}
+			if attrType == AttrTypeMPReachNLRI {
+				u.MPReach = m
+			} else {
+				u.MPUnreach = append(u.MPUnreach, m)
+			}
+			return true
+		}
+		if seen[attrType] {
+			u.DiscardedAttrs = append(u.DiscardedAttrs, attrType)
+			return true
+		}
+		seen[attrType] = true
+		if t, ok := lookupPathAttrType(attrType); ok {
+			a, err := d.decodeRegisteredAttr(t, flags, attrType, value)
+			if err != nil {
+				switch t.ErrorHandling {
+				case AttrErrorDiscard:
+					u.DiscardedAttrs = append(u.DiscardedAttrs, attrType)
+				case AttrErrorSessionReset:
+					notif = newNotification(NotifCodeUpdateMessageErr,
+						NotifSubcodeOptionalAttrError, raw)
+					return false
+				default:
+					u.TreatAsWithdraw = true
+				}
+				return true
+			}
+			u.Attrs = append(u.Attrs, a)
+			return true
+		}
+		var action attrAction
+		if ValidateAttrFlags(attrType, flags) != nil {
+			action = attrTreatAsWithdraw
+		} else {
+			action = d.checkAttr(attrType, value)
+		}
+		switch action {
+		case attrDiscard:
+			u.DiscardedAttrs = append(u.DiscardedAttrs, attrType)
+		case attrTreatAsWithdraw:
+			u.TreatAsWithdraw = true
+		default:
+			u.Attrs = append(u.Attrs, PathAttr{
+				Flags: flags,
+				Type:  attrType,
+				Value: value,
+			})
+		}
+		return true
+	})
+	if notif != nil {
+		return nil, notif
+	}
+	if err != nil {
+		// the NLRI field can still be located via the total path attribute
+		// length
+		// https://tools.ietf.org/html/rfc7606#section-4
+		if hasMPAttr || len(u.NLRI) == 0 {
+			// MP_REACH_NLRI may have been missed
+			return nil, malformed()
+		}
+		u.TreatAsWithdraw = true
+	}
+
+	// https://tools.ietf.org/html/rfc7606#section-3 (d)
+	// BGPsec update messages carry BGPsec_PATH in place of AS_PATH
+	// https://tools.ietf.org/html/rfc8205#section-3
+	if !u.TreatAsWithdraw && (len(u.NLRI) > 0 || u.MPReach != nil) {
+		missing := !seen[AttrTypeOrigin] ||
+			(!seen[AttrTypeASPath] && !seen[AttrTypeBGPsecPath]) ||
+			(len(u.NLRI) > 0 && !seen[AttrTypeNextHop])
+		if missing {
+			u.TreatAsWithdraw = true
+		}
+	}
+	if u.TreatAsWithdraw {
+		u.treatAsWithdraw()
+	}
+	return u, nil
+}
+
+// Encode encodes u as an UPDATE message body to be sent to a peer whose
+// session was negotiated with codec, with path identifiers for the
+// AFI/SAFIs for which ADD-PATH was negotiated for sending. A nil codec is
+// equivalent to a session without ADD-PATH. Path attributes, including
+// MP_REACH_NLRI and MP_UNREACH_NLRI, are encoded in ascending order of type
+// from their Flags and Value, Decoded values are not encoded. The NLRI of
+// MPNLRI are encoded for the IPv4 and IPv6 unicast and multicast SAFIs,
+// Decoded for AFI/SAFIs registered via RegisterNLRI with an Encode function,
+// and RawNLRI for others.
+func (u *DecodedUpdate) Encode(codec *Codec) ([]byte, error) {
+	if codec == nil {
+		codec = &Codec{}
+	}
+	return newUpdateDecoder(codec, AddPathSend).encode(u)
+}
+
+// DecodeUpdate decodes the UPDATE message body b received from the peer of
+// the session with the given codec, as decoded for a DecodedUpdatePlugin, e.g.
+// for updates read from MRT files. A nil codec is treated as a session without
+// four-octet AS numbers, ADD-PATH and extended messages. An error preventing
+// the update from being treated as a withdrawal (RFC7606) wraps the
+// *Notification to send to the peer, which can be retrieved via errors.As().
+func DecodeUpdate(b []byte, codec *Codec) (*DecodedUpdate, error) {
+	if codec == nil {
+		codec = &Codec{}
+	}
+	u, n := newUpdateDecoder(codec, AddPathReceive).decode(b)
+	if n != nil {
+		return nil, newNotificationError(n, true)
+	}
+	return u, nil
+}
+
+// encode encodes u with path identifiers for the AFI/SAFIs of d.addPath.
+func (d *updateDecoder) encode(u *DecodedUpdate) ([]byte, error) {
+	if len(u.MPUnreach) > 1 {
+		return nil, errors.New("multiple MP_UNREACH_NLRI attributes")
+	}
+	addPathIPv4 := d.addPath[addPathKey(AFIIPv4, SAFIUnicast)]
+	withdrawn, err := appendNLRI(nil, u.Withdrawn, AFIIPv4, addPathIPv4)
+	if err != nil {
+		return nil, err
+	}
+	nlri, err := appendNLRI(nil, u.NLRI, AFIIPv4, addPathIPv4)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]PathAttr, 0, len(u.Attrs)+2)
+	attrs = append(attrs, u.Attrs...)
+	if u.MPReach != nil {
+		value, err := d.encodeMPNLRI(u.MPReach, true)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, PathAttr{
+			Flags: AttrFlagOptional,
+			Type:  AttrTypeMPReachNLRI,
+			Value: value,
+		})
+	}
+	if len(u.MPUnreach) > 0 {
+		value, err := d.encodeMPNLRI(u.MPUnreach[0], false)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, PathAttr{
+			Flags: AttrFlagOptional,
+			Type:  AttrTypeMPUnreachNLRI,
+			Value: value,
+		})
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return attrs[i].Type < attrs[j].Type
+	})
+	var b []byte
+	for _, a := range attrs {
+		b, err = AppendPathAttr(b, a.Flags, a.Type, a.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(withdrawn) > 0xffff || len(b) > 0xffff {
+		return nil, errors.New("update too long")
+	}
+	return joinUpdate(withdrawn, b, nlri), nil
+}
+
+// encodeMPNLRI returns the MP_REACH_NLRI or MP_UNREACH_NLRI attribute value
+// of m.
+func (d *updateDecoder) encodeMPNLRI(m *MPNLRI, reach bool) ([]byte,
+	error) {
+	b := []byte{uint8(m.AFI >> 8), uint8(m.AFI), m.SAFI}
+	if reach {
+		if len(m.NextHop) > 0xff {
+			return nil, errors.New("MP_REACH_NLRI next hop too long")
+		}
+		b = append(b, uint8(len(m.NextHop)))
+		b = append(b, m.NextHop...)
+		b = append(b, 0) // reserved
+	}
+	addPath := d.addPath[addPathKey(m.AFI, m.SAFI)]
+	if isPrefixSAFI(m.AFI, m.SAFI) {
+		return appendNLRI(b, m.NLRI, m.AFI, addPath)
+	}
+	if t, ok := lookupNLRIType(m.AFI, m.SAFI); ok && t.Encode != nil &&
+		m.Decoded != nil {
+		nlri, err := t.Encode(m.Decoded, addPath)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, nlri...), nil
+	}
+	return append(b, m.RawNLRI...), nil
+}
+
+// appendNLRI appends the encoding of nlri of the given AFI to b, each
+// preceded by a path identifier if addPath is true.
+func appendNLRI(b []byte, nlri []NLRI, afi uint16, addPath bool) ([]byte,
+	error) {
+	addrLen := net.IPv4len
+	if afi == AFIIPv6 {
+		addrLen = net.IPv6len
+	}
+	for _, n := range nlri {
+		if n.Prefix == nil {
+			return nil, errMalformedNLRI
+		}
+		ip := n.Prefix.IP.To16()
+		if addrLen == net.IPv4len {
+			ip = n.Prefix.IP.To4()
+		}
+		bits, size := n.Prefix.Mask.Size()
+		if ip == nil || size != addrLen*8 {
+			return nil, fmt.Errorf("%w: %s is not of AFI %d",
+				errMalformedNLRI, n.Prefix, afi)
+		}
+		if addPath {
+			b = append(b, uint8(n.PathID>>24), uint8(n.PathID>>16),
+				uint8(n.PathID>>8), uint8(n.PathID))
+		}
+		b = append(b, uint8(bits))
+		b = append(b, ip.Mask(n.Prefix.Mask)[:(bits+7)/8]...)
+	}
+	return b, nil
+}
+
+// treatAsWithdraw moves the NLRI of u to its withdrawals.
+func (u *DecodedUpdate) treatAsWithdraw() {
+	u.Withdrawn = append(u.Withdrawn, u.NLRI...)
+	u.NLRI = nil
+	u.Attrs = nil
+	if u.MPReach == nil {
+		return
+	}
+	reach := u.MPReach
+	u.MPReach = nil
+	for _, m := range u.MPUnreach {
+		if m.AFI == reach.AFI && m.SAFI == reach.SAFI {
+			m.NLRI = append(m.NLRI, reach.NLRI...)
+			m.RawNLRI = append(m.RawNLRI[:len(m.RawNLRI):len(m.RawNLRI)],
+				reach.RawNLRI...)
+			m.Decoded = nil
+			return
+		}
+	}
+	u.MPUnreach = append(u.MPUnreach, &MPNLRI{
+		AFI:     reach.AFI,
+		SAFI:    reach.SAFI,
+		NLRI:    reach.NLRI,
+		RawNLRI: reach.RawNLRI,
+		Decoded: reach.Decoded,
+	})
+}
+
+// decodingHandler returns an UpdateMessageHandler decoding updates for
+// handler.
+func (f *fsm) decodingHandler(
+	handler DecodedUpdateHandler) UpdateMessageHandler {
+	if handler == nil {
+		return nil
+	}
+	d := newUpdateDecoder(f.codec, AddPathReceive)
+	if f.peer.options.decodeArena {
+		d.arena = &updateArena{}
+	}
+	return func(peer *PeerConfig, b []byte) *Notification {
+		defer d.arena.release()
+		u, n := d.decode(b)
+		if n != nil {
+			logf("[%s] error decoding update: %s", peer.IP, n)
+			return n
+		}
+		if u.TreatAsWithdraw {
+			atomic.AddUint64(&f.peer.counters.updatesTreatedAsWithdraw, 1)
+			logf("[%s] treating update as withdraw due to malformed "+
+				"attributes", peer.IP)
+		}
+		if len(u.DiscardedAttrs) > 0 {
+			logf("[%s] discarded malformed update attributes %v", peer.IP,
+				u.DiscardedAttrs)
+		}
+		if policy := f.peer.options.inboundPolicy; policy != nil {
+			u = policy(peer, u)
+			if u == nil {
+				atomic.AddUint64(&f.peer.counters.updatesFiltered, 1)
+				return nil
+			}
+		}
+		return handler(peer, u)
+	}
+}
@@ -0,0 +1,214 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// DeduplicateUpdates returns a PeerOption that suppresses UPDATE messages
+// written to the peer that only repeat the latest advertisement or withdrawal
+// of each of their prefixes within the session, e.g. as written over and over
+// by a naive reconciliation loop. An advertisement is repeated if the prefix
+// was last advertised with byte-identical path attributes. Messages repeating
+// some of their prefixes only are written unmodified.
+//
+// The path attributes of each prefix advertised or withdrawn are tracked as a
+// hash, requiring memory proportional to the number of prefixes. Only IPv4
+// and IPv6 unicast and multicast prefixes are tracked, messages carrying
+// other NLRI and End-of-RIB markers are always written. Suppressed messages
+// are counted by PeerCounters.UpdatesDeduplicated.
+func DeduplicateUpdates() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.deduplicateUpdates = true
+	})
+}
+
+// dedupState is the state of a prefix last written to the peer.
+type dedupState struct {
+	// attrs is the hash of the path attributes the prefix was advertised
+	// with, zero if withdrawn is true
+	attrs     [16]byte
+	withdrawn bool
+}
+
+// updateDedup tracks the prefixes written to a peer in a session.
+type updateDedup struct {
+	// addPath contains the AFI/SAFIs for which path identifiers are sent
+	addPath map[uint32]bool
+
+	mu sync.Mutex
+	// prefixes is keyed by the AFI, SAFI and encoded NLRI of each prefix
+	prefixes map[string]dedupState
+	// key is a buffer for building keys of prefixes
+	key []byte
+	// pending contains the prefixes of the update being checked
+	pending []dedupPrefix
+}
+
+// dedupPrefix is a prefix of an update and its new state.
+type dedupPrefix struct {
+	afi   uint16
+	safi  uint8
+	nlri  []byte
+	state dedupState
+}
+
+func newUpdateDedup(c *Codec) *updateDedup {
+	d := &updateDedup{
+		addPath:  make(map[uint32]bool),
+		prefixes: make(map[string]dedupState),
+	}
+	for _, t := range c.AddPath {
+		if t.Direction&AddPathSend != 0 {
+			d.addPath[addPathKey(t.AFI, t.SAFI)] = true
+		}
+	}
+	return d
+}
+
+// duplicate returns true if the UPDATE message body b only repeats the
+// latest state of its prefixes, otherwise recording their new state.
+func (d *updateDedup) duplicate(b []byte) bool {
+	if _, _, ok := DecodeEndOfRIB(b); ok {
+		return false
+	}
+	withdrawn, attrs, nlri, err := splitUpdate(b)
+	if err != nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = d.pending[:0]
+	defer func() {
+		for i := range d.pending {
+			d.pending[i].nlri = nil
+		}
+	}()
+
+	h := fnv.New128a()
+	var (
+		reach, unreach []byte
+		ok             = true
+	)
+	err = rangePathAttrs(attrs, func(flags AttrFlags, attrType uint8,
+		value, raw []byte) bool {
+		switch attrType {
+		case AttrTypeMPReachNLRI:
+			// the next hop is hashed with the attributes, the NLRI are not
+			if len(value) < 5 || len(value) < 5+int(value[3]) {
+				ok = false
+				return false
+			}
+			n := 5 + int(value[3])
+			h.Write([]byte{attrType})
+			h.Write(value[:n])
+			reach = value
+		case AttrTypeMPUnreachNLRI:
+			unreach = value
+		default:
+			h.Write(raw)
+		}
+		return true
+	})
+	if err != nil || !ok {
+		return false
+	}
+	var announced dedupState
+	h.Sum(announced.attrs[:0])
+	withdraw := dedupState{withdrawn: true}
+
+	if !d.addPrefixes(AFIIPv4, SAFIUnicast, withdrawn, withdraw) ||
+		!d.addPrefixes(AFIIPv4, SAFIUnicast, nlri, announced) {
+		return false
+	}
+	if reach != nil {
+		afi, safi := binary.BigEndian.Uint16(reach), reach[2]
+		if !d.addPrefixes(afi, safi, reach[5+int(reach[3]):], announced) {
+			return false
+		}
+	}
+	if unreach != nil {
+		if len(unreach) < 3 {
+			return false
+		}
+		afi, safi := binary.BigEndian.Uint16(unreach), unreach[2]
+		if !d.addPrefixes(afi, safi, unreach[3:], withdraw) {
+			return false
+		}
+	}
+	if len(d.pending) == 0 {
+		return false
+	}
+
+	dup := true
+	for _, p := range d.pending {
+		state, ok := d.prefixes[string(d.prefixKey(p))]
+		if !ok || state != p.state {
+			dup = false
+			break
+		}
+	}
+	if dup {
+		return true
+	}
+	for _, p := range d.pending {
+		d.prefixes[string(d.prefixKey(p))] = p.state
+	}
+	return false
+}
+
+// addPrefixes adds the prefixes of the NLRI b of afi/safi to d.pending with
+// state, returning false if they are not tracked or malformed.
+func (d *updateDedup) addPrefixes(afi uint16, safi uint8, b []byte,
+	state dedupState) bool {
+	if len(b) == 0 {
+		return true
+	}
+	if !isPrefixSAFI(afi, safi) {
+		return false
+	}
+	addrLen := net.IPv4len
+	if afi == AFIIPv6 {
+		addrLen = net.IPv6len
+	}
+	addPath := d.addPath[addPathKey(afi, safi)]
+	for len(b) > 0 {
+		n := 0
+		if addPath {
+			n = 4
+		}
+		if len(b) < n+1 {
+			return false
+		}
+		bits := int(b[n])
+		octets := (bits + 7) / 8
+		if bits > addrLen*8 || len(b) < n+1+octets {
+			return false
+		}
+		n += 1 + octets
+		d.pending = append(d.pending, dedupPrefix{
+			afi:   afi,
+			safi:  safi,
+			nlri:  b[:n],
+			state: state,
+		})
+		b = b[n:]
+	}
+	return true
+}
+
+// prefixKey returns the key of p in d.prefixes, valid until the next call.
+func (d *updateDedup) prefixKey(p dedupPrefix) []byte {
+	d.key = append(d.key[:0], uint8(p.afi>>8), uint8(p.afi), p.safi)
+	d.key = append(d.key, p.nlri...)
+	return d.key
+}
+
+// updateDeduplicated counts an UPDATE message suppressed by
+// DeduplicateUpdates.
+func (c *peerCounters) updateDeduplicated() {
+	atomic.AddUint64(&c.updatesDeduplicated, 1)
+}
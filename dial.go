@@ -0,0 +1,162 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// DefaultDialTimeout is the default timeout of outbound connection attempts.
+const DefaultDialTimeout = connectRetryTime
+
+// ContextDialer dials outbound connections to peers. It is implemented by
+// net.Dialer, and by proxy dialers such as those returned by
+// golang.org/x/net/proxy.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn,
+		error)
+}
+
+// Dialer returns a PeerOption that sets the ContextDialer used to dial a
+// peer, e.g. to connect via a SOCKS5 or HTTP CONNECT proxy. The dial context
+// is done once the DialTimeout expires or the peer or Server is closed.
+func Dialer(d ContextDialer) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.dialer = d
+	})
+}
+
+// DialTimeout returns a PeerOption that sets the timeout of outbound
+// connection attempts to a peer, which defaults to DefaultDialTimeout. The
+// ConnectRetryTimer is extended to timeouts exceeding it.
+func DialTimeout(d time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if d > 0 {
+			o.dialTimeout = d
+		}
+	})
+}
+
+// DialFailureCause classifies the cause of a failed outbound connection
+// attempt. Errors returned by a ContextDialer, e.g. a proxy's, may implement
+// a DialFailureCause() DialFailureCause method to classify themselves.
+type DialFailureCause uint8
+
+// DialFailureCause values
+const (
+	// DialFailureOther is any cause not classified otherwise.
+	DialFailureOther DialFailureCause = iota
+	// DialFailureRefused indicates the peer refused the connection, e.g.
+	// as it is not listening or not configured for us.
+	DialFailureRefused
+	// DialFailureUnreachable indicates the network or host of the peer is
+	// unreachable.
+	DialFailureUnreachable
+	// DialFailureTimeout indicates the connection was not established within
+	// the ConnectRetryTimer, e.g. as packets are silently dropped.
+	DialFailureTimeout
+)
+
+func (c DialFailureCause) String() string {
+	switch c {
+	case DialFailureOther:
+		return "other"
+	case DialFailureRefused:
+		return "refused"
+	case DialFailureUnreachable:
+		return "unreachable"
+	case DialFailureTimeout:
+		return "timeout"
+	}
+	return "unknown"
+}
+
+func dialFailureCause(err error) DialFailureCause {
+	var c interface {
+		DialFailureCause() DialFailureCause
+	}
+	if errors.As(err, &c) {
+		return c.DialFailureCause()
+	}
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return DialFailureRefused
+	case errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTUNREACH):
+		return DialFailureUnreachable
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return DialFailureTimeout
+	}
+	return DialFailureOther
+}
+
+// DialFailure describes a failed outbound connection attempt.
+type DialFailure struct {
+	Time  time.Time
+	Cause DialFailureCause
+	Err   string
+}
+
+// DialStatus describes the outbound connection attempts of a peer.
+type DialStatus struct {
+	// Attempts is the number of outbound connection attempts, of which
+	// Failures failed.
+	Attempts uint64
+	Failures uint64
+	// LastAttempt is the time of the latest attempt.
+	LastAttempt time.Time
+	// LastFailure is the latest failed attempt, if any.
+	LastFailure *DialFailure
+	// NextAttempt is the time of the next scheduled attempt. It is zero while
+	// an attempt is in progress, or if no attempt is scheduled, e.g. as the
+	// peer is established or passive.
+	NextAttempt time.Time
+}
+
+// dialStarted records the start of an outbound connection attempt.
+func (p *peer) dialStarted() {
+	p.counters.connectAttempt()
+	p.statusMu.Lock()
+	p.dial.Attempts++
+	p.dial.LastAttempt = time.Now()
+	p.dial.NextAttempt = time.Time{}
+	p.statusMu.Unlock()
+}
+
+// dialScheduled records the time of the next outbound connection attempt,
+// zero if none is scheduled.
+func (p *peer) dialScheduled(next time.Time) {
+	p.statusMu.Lock()
+	p.dial.NextAttempt = next
+	p.statusMu.Unlock()
+}
+
+// dialFailed records a failed outbound connection attempt, to be retried at
+// next.
+func (p *peer) dialFailed(err error, cause DialFailureCause, next time.Time) {
+	logf("[%s] dial failed (%s): %v", p.config.IP, cause, err)
+	now := time.Now()
+	p.statusMu.Lock()
+	p.dial.Failures++
+	p.dial.LastFailure = &DialFailure{
+		Time:  now,
+		Cause: cause,
+		Err:   err.Error(),
+	}
+	p.dial.NextAttempt = next
+	p.statusMu.Unlock()
+	p.events.publish(&DialFailedEvent{
+		eventBase: eventBase{
+			Time: now,
+			Peer: p.config.IP,
+			Key:  p.peerKey(),
+		},
+		Cause:       cause,
+		Err:         err,
+		NextAttempt: next,
+	})
+}
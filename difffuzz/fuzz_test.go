@@ -0,0 +1,336 @@
+package difffuzz
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+)
+
+func seedOpenMessages(f *testing.F) {
+	f.Add([]byte{4, 0xFD, 0xE9, 0, 90, 192, 0, 2, 1, 0})
+	f.Add([]byte{4, 0xFD, 0xE9, 0, 90, 192, 0, 2, 1, 16, 2, 14, 1, 4, 0, 1,
+		0, 1, 2, 0, 65, 4, 0, 0, 0xFD, 0xE9})
+	f.Add([]byte{4, 0xFD, 0xE9, 0, 90, 192, 0, 2, 1, 4, 2, 2, 2, 0})
+	f.Add([]byte{4, 0xFD, 0xE9, 0, 90, 192, 0, 2, 1, 4, 1, 2, 0, 0})
+	f.Add([]byte{4, 0xFD, 0xE9, 0, 90, 192, 0, 2, 1, 3, 2, 255, 0})
+}
+
+func FuzzDecodeOpen(f *testing.F) {
+	seedOpenMessages(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if skipOpen(b) {
+			return
+		}
+		o := &corebgp.OpenMessage{}
+		err := o.Decode(b)
+		ref, refErr := fuzzReference.parseOpen(b)
+		var perr errReferencePanic
+		if errors.As(refErr, &perr) {
+			t.Skip(perr)
+		}
+		if (err == nil) != (refErr == nil) {
+			t.Fatalf("accept/reject divergence: corebgp err: %v reference "+
+				"err: %v", err, refErr)
+		}
+		if err != nil {
+			return
+		}
+		if o.Version != ref.version || o.ASN != ref.asn ||
+			o.HoldTime != ref.holdTime || o.BGPID != ref.bgpID {
+			t.Fatalf("field divergence: corebgp: %+v reference: %+v", o, ref)
+		}
+		if ref.capabilitiesInvalid {
+			return
+		}
+		caps := o.Capabilities()
+		if len(caps) != len(ref.capabilities) {
+			t.Fatalf("capability divergence: corebgp: %v reference: %v",
+				caps, ref.capabilities)
+		}
+		for i, c := range caps {
+			if int(c.Code) != ref.capabilities[i][0] ||
+				len(c.Value) != ref.capabilities[i][1] {
+				t.Fatalf("capability divergence: corebgp: %v reference: %v",
+					caps, ref.capabilities)
+			}
+		}
+	})
+}
+
+// skipOpen returns true for OPEN messages on which gobgp knowingly deviates
+// from the RFCs, which are not compared.
+func skipOpen(b []byte) bool {
+	if len(b) < 10 {
+		return false
+	}
+	params := b[10:]
+	// gobgp does not implement extended optional parameters (RFC9072), and
+	// ignores bytes following the optional parameters
+	if b[9] == 255 || len(params) != int(b[9]) {
+		return true
+	}
+	for len(params) >= 2 {
+		paramType, paramLen := params[0], int(params[1])
+		// gobgp rejects optional parameters of 254 or 255 bytes
+		if paramLen >= 254 || len(params) < 2+paramLen {
+			return paramLen >= 254
+		}
+		// gobgp ignores malformed capabilities, keeping those preceding
+		// them
+		if paramType == 2 && !wellFormedCapabilities(params[2:2+paramLen]) {
+			return true
+		}
+		params = params[2+paramLen:]
+	}
+	return false
+}
+
+func wellFormedCapabilities(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return false
+		}
+		b = b[2+int(b[1]):]
+	}
+	return true
+}
+
+func seedUpdateMessages(f *testing.F) {
+	v4 := &corebgp.DecodedUpdate{
+		Withdrawn: []corebgp.NLRI{{Prefix: mustPrefix("198.51.100.0/24")}},
+		Attrs: []corebgp.PathAttr{
+			{Flags: corebgp.AttrFlagTransitive, Type: corebgp.AttrTypeOrigin,
+				Value: []byte{0}},
+			{Flags: corebgp.AttrFlagTransitive, Type: corebgp.AttrTypeASPath,
+				Value: []byte{2, 1, 0, 0, 0xFD, 0xE9}},
+			{Flags: corebgp.AttrFlagTransitive, Type: corebgp.AttrTypeNextHop,
+				Value: []byte{192, 0, 2, 1}},
+		},
+		NLRI: []corebgp.NLRI{
+			{Prefix: mustPrefix("192.0.2.0/24")},
+			{Prefix: mustPrefix("10.0.0.0/8")},
+		},
+	}
+	v6 := &corebgp.DecodedUpdate{
+		Attrs: v4.Attrs[:2],
+		MPReach: &corebgp.MPNLRI{
+			AFI:     corebgp.AFIIPv6,
+			SAFI:    corebgp.SAFIUnicast,
+			NextHop: net.ParseIP("2001:db8::1"),
+			NLRI:    []corebgp.NLRI{{Prefix: mustPrefix("2001:db8:1::/48")}},
+		},
+		MPUnreach: []*corebgp.MPNLRI{{
+			AFI:  corebgp.AFIIPv6,
+			SAFI: corebgp.SAFIUnicast,
+			NLRI: []corebgp.NLRI{{Prefix: mustPrefix("2001:db8:2::/48")}},
+		}},
+	}
+	for _, u := range []*corebgp.DecodedUpdate{v4, v6} {
+		b, err := u.Encode(&corebgp.Codec{FourOctetAS: true})
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Add([]byte{0, 0, 0, 0})
+	f.Add([]byte{0, 0, 0, 6, 0x80, 15, 3, 0, 2, 1})
+}
+
+func mustPrefix(s string) *net.IPNet {
+	_, p, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func FuzzDecodeUpdate(f *testing.F) {
+	seedUpdateMessages(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		u, err := corebgp.DecodeUpdate(b, &corebgp.Codec{FourOctetAS: true})
+		ref, refErr := fuzzReference.parseUpdate(b)
+		if refErr != nil {
+			t.Skip(refErr)
+		}
+		verdict := verdictOK
+		switch {
+		case err != nil:
+			verdict = verdictReject
+		case u.TreatAsWithdraw || len(u.DiscardedAttrs) > 0:
+			verdict = verdictAttrError
+		}
+		if verdict != ref.verdict && !knownVerdictDivergence(err, verdict,
+			ref) {
+			t.Fatalf("verdict divergence: corebgp: %v (%v) reference: %v",
+				verdict, err, ref.verdict)
+		}
+		if verdict != verdictOK || ref.verdict != verdictOK {
+			return
+		}
+		compare := func(field string, got, want []string) {
+			if len(got) != len(want) {
+				t.Fatalf("%s divergence: corebgp: %v reference: %v", field,
+					got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("%s divergence: corebgp: %v reference: %v",
+						field, got, want)
+				}
+			}
+		}
+		compare("withdrawn", prefixes(u.Withdrawn), ref.withdrawn)
+		compare("nlri", prefixes(u.NLRI), ref.nlri)
+		var mpReach, mpUnreach []string
+		if u.MPReach != nil && isPrefixSAFI(u.MPReach.AFI, u.MPReach.SAFI) {
+			mpReach = prefixes(u.MPReach.NLRI)
+		}
+		for _, m := range u.MPUnreach {
+			if isPrefixSAFI(m.AFI, m.SAFI) {
+				mpUnreach = append(mpUnreach, prefixes(m.NLRI)...)
+			}
+		}
+		compare("mp_reach_nlri", mpReach, ref.mpReach)
+		compare("mp_unreach_nlri", mpUnreach, ref.mpUnreach)
+
+		var attrTypes []uint8
+		for _, a := range u.Attrs {
+			attrTypes = append(attrTypes, a.Type)
+		}
+		// corebgp separates MP_REACH_NLRI and MP_UNREACH_NLRI from Attrs
+		var refTypes []uint8
+		for _, t := range ref.attrTypes {
+			if t != corebgp.AttrTypeMPReachNLRI &&
+				t != corebgp.AttrTypeMPUnreachNLRI {
+				refTypes = append(refTypes, t)
+			}
+		}
+		if string(attrTypes) != string(refTypes) {
+			t.Fatalf("attribute divergence: corebgp: %v reference: %v",
+				attrTypes, refTypes)
+		}
+	})
+}
+
+// checkedAttrTypes contains the types of path attributes whose values are
+// checked by corebgp.
+var checkedAttrTypes = map[int]bool{
+	int(corebgp.AttrTypeOrigin):              true,
+	int(corebgp.AttrTypeASPath):              true,
+	int(corebgp.AttrTypeNextHop):             true,
+	int(corebgp.AttrTypeMED):                 true,
+	int(corebgp.AttrTypeLocalPref):           true,
+	int(corebgp.AttrTypeAtomicAggregate):     true,
+	int(corebgp.AttrTypeAggregator):          true,
+	int(corebgp.AttrTypeCommunities):         true,
+	int(corebgp.AttrTypeOriginatorID):        true,
+	int(corebgp.AttrTypeClusterList):         true,
+	int(corebgp.AttrTypeMPReachNLRI):         true,
+	int(corebgp.AttrTypeMPUnreachNLRI):       true,
+	int(corebgp.AttrTypeExtendedCommunities): true,
+	int(corebgp.AttrTypeAS4Path):             true,
+	int(corebgp.AttrTypeAS4Aggregator):       true,
+	int(corebgp.AttrTypeLargeCommunities):    true,
+}
+
+// knownVerdictDivergence returns true if corebgp and the reference parser
+// knowingly handle an UPDATE message differently.
+func knownVerdictDivergence(err error, verdict referenceVerdict,
+	ref *referenceUpdate) bool {
+	switch {
+	case verdict == verdictAttrError && ref.verdict == verdictOK:
+		// corebgp checks the presence of mandatory attributes while
+		// decoding, gobgp separately
+		return true
+	case verdict == verdictOK && ref.verdict == verdictAttrError:
+		// gobgp validates attributes of types corebgp passes on without
+		// interpreting them, e.g. PREFIX_SID
+		return ref.errAttrType >= 0 && !checkedAttrTypes[ref.errAttrType]
+	case ref.verdict == verdictReject && ref.unsupportedFamily:
+		// gobgp rejects NLRI of AFI/SAFIs it does not implement, which
+		// corebgp delivers in MPNLRI.RawNLRI
+		return true
+	case verdict == verdictAttrError && ref.verdict == verdictReject:
+		// gobgp resets the session on length errors of attributes of some
+		// types, including unknown ones, rather than treating the update as
+		// a withdrawal
+		// https://tools.ietf.org/html/rfc7606#section-4
+		return ref.subcode == corebgp.NotifSubcodeAttrLenError
+	case verdict == verdictReject && ref.verdict == verdictAttrError:
+		// corebgp resets the session if the path attributes cannot be
+		// parsed and an MP_REACH_NLRI attribute may have been missed, rather
+		// than withdrawing the NLRI field only
+		// https://tools.ietf.org/html/rfc7606#section-3
+		var n *corebgp.Notification
+		if !errors.As(err, &n) {
+			return false
+		}
+		// gobgp does not parse the NLRI field following an attribute
+		// overrunning the path attributes
+		if n.Subcode == corebgp.NotifSubcodeInvalidNetworkField {
+			return !ref.nlriParsed
+		}
+		// gobgp treats an MP_REACH_NLRI or MP_UNREACH_NLRI attribute with
+		// conflicting flags as a withdrawal, corebgp resets the session as
+		// its NLRI cannot be withdrawn reliably
+		// https://tools.ietf.org/html/rfc7606#section-7.11
+		if n.Subcode == corebgp.NotifSubcodeAttrFlagsError ||
+			n.Subcode == corebgp.NotifSubcodeOptionalAttrError {
+			return ref.subcode == corebgp.NotifSubcodeAttrFlagsError
+		}
+		if n.Subcode != corebgp.NotifSubcodeMalformedAttr {
+			return false
+		}
+		if len(ref.nlri) == 0 {
+			return true
+		}
+		for _, t := range ref.attrTypes {
+			if t == corebgp.AttrTypeMPReachNLRI ||
+				t == corebgp.AttrTypeMPUnreachNLRI {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func FuzzDecodeNotification(f *testing.F) {
+	f.Add([]byte{6, 2, 8, 's', 'h', 'u', 't', 'd', 'o', 'w', 'n'})
+	f.Add([]byte{3, 1})
+	f.Add([]byte{2, 7, 1, 4, 0, 1, 0, 1})
+	f.Add([]byte{1})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		n := &corebgp.Notification{}
+		err := n.Decode(b)
+		ref, refErr := fuzzReference.parseNotification(b)
+		var perr errReferencePanic
+		if errors.As(refErr, &perr) {
+			t.Skip(perr)
+		}
+		if (err == nil) != (refErr == nil) {
+			t.Fatalf("accept/reject divergence: corebgp err: %v reference "+
+				"err: %v", err, refErr)
+		}
+		if err != nil {
+			return
+		}
+		if n.Code != ref.code || n.Subcode != ref.subcode ||
+			!bytes.Equal(n.Data, ref.data) {
+			t.Fatalf("field divergence: corebgp: %+v reference: %+v", n, ref)
+		}
+	})
+}
+
+func prefixes(nlri []corebgp.NLRI) []string {
+	var s []string
+	for _, n := range nlri {
+		s = append(s, n.Prefix.String())
+	}
+	return s
+}
@@ -0,0 +1,10 @@
+module github.com/jwhited/corebgp/difffuzz
+
+go 1.23.0
+
+require (
+	github.com/jwhited/corebgp v0.0.0
+	github.com/osrg/gobgp/v3 v3.37.0
+)
+
+replace github.com/jwhited/corebgp => ../
@@ -0,0 +1,279 @@
+// Package difffuzz contains differential fuzz targets checking corebgp's
+// message decoding against gobgp's. It is a separate module so that corebgp
+// itself remains free of dependencies.
+//
+// Run a target with e.g.:
+//
+//	go test -fuzz FuzzDecodeUpdate
+package difffuzz
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/jwhited/corebgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+)
+
+// referenceOpen is the reference representation of a decoded OPEN message.
+type referenceOpen struct {
+	version  uint8
+	asn      uint16
+	holdTime uint16
+	bgpID    uint32
+	// capabilities contains the code and value length of each capability
+	capabilities [][2]int
+	// capabilitiesInvalid is true if a capability value is invalid, in which
+	// case capabilities may be incomplete
+	capabilitiesInvalid bool
+}
+
+// referenceNotification is the reference representation of a decoded
+// NOTIFICATION message.
+type referenceNotification struct {
+	code    uint8
+	subcode uint8
+	data    []byte
+}
+
+// referenceVerdict is the outcome of decoding an UPDATE message.
+type referenceVerdict int
+
+const (
+	// verdictOK is a message decoded without errors.
+	verdictOK referenceVerdict = iota
+	// verdictAttrError is a message with path attribute errors handled by
+	// treat-as-withdraw or attribute discard (RFC7606).
+	verdictAttrError
+	// verdictReject is a message resetting the session.
+	verdictReject
+)
+
+func (v referenceVerdict) String() string {
+	switch v {
+	case verdictOK:
+		return "ok"
+	case verdictAttrError:
+		return "attribute error"
+	case verdictReject:
+		return "reject"
+	}
+	return fmt.Sprintf("verdict(%d)", int(v))
+}
+
+// referenceUpdate is the reference representation of a decoded UPDATE
+// message. Prefixes are in their masked CIDR form.
+type referenceUpdate struct {
+	verdict referenceVerdict
+	// subcode is the UPDATE message error subcode of an error, and
+	// errAttrType the type of the attribute in error, if known
+	subcode     uint8
+	errAttrType int
+	// nlriParsed is false if the NLRI field was not parsed due to an
+	// attribute error
+	nlriParsed bool
+	// unsupportedFamily is true if the message carries an MP_REACH_NLRI or
+	// MP_UNREACH_NLRI attribute of an AFI/SAFI gobgp does not implement
+	unsupportedFamily bool
+	withdrawn         []string
+	nlri              []string
+	// attrTypes contains the path attribute types in the order received
+	attrTypes []uint8
+	// mpReach and mpUnreach contain the prefixes of the IPv4 and IPv6
+	// unicast and multicast MP_REACH_NLRI and MP_UNREACH_NLRI attributes
+	mpReach   []string
+	mpUnreach []string
+}
+
+// referenceParser is implemented by parsers that corebgp's codecs are checked
+// against.
+type referenceParser interface {
+	// parseOpen parses the body of an OPEN message.
+	parseOpen(b []byte) (*referenceOpen, error)
+	// parseUpdate parses the body of an UPDATE message of a session with
+	// four-octet AS numbers and without ADD-PATH.
+	parseUpdate(b []byte) (*referenceUpdate, error)
+	// parseNotification parses the body of a NOTIFICATION message.
+	parseNotification(b []byte) (*referenceNotification, error)
+}
+
+var fuzzReference referenceParser = gobgpParser{}
+
+// errReferencePanic is returned by gobgpParser if gobgp panicked decoding
+// the message, which is then not compared.
+type errReferencePanic struct {
+	v interface{}
+}
+
+func (e errReferencePanic) Error() string {
+	return fmt.Sprintf("reference parser panicked: %v", e.v)
+}
+
+// gobgpParser is a referenceParser backed by gobgp's packet/bgp package.
+type gobgpParser struct{}
+
+func recoverReference(err *error) {
+	if v := recover(); v != nil {
+		*err = errReferencePanic{v: v}
+	}
+}
+
+func (gobgpParser) parseOpen(b []byte) (o *referenceOpen, err error) {
+	defer recoverReference(&err)
+	m := &bgp.BGPOpen{}
+	if err := m.DecodeFromBytes(b); err != nil {
+		return nil, err
+	}
+	o = &referenceOpen{
+		version:  m.Version,
+		asn:      m.MyAS,
+		holdTime: m.HoldTime,
+		bgpID: uint32(b[5])<<24 | uint32(b[6])<<16 | uint32(b[7])<<8 |
+			uint32(b[8]),
+	}
+	// the capabilities of m are decoded again as gobgp ignores the errors of
+	// the capabilities it validates, along with the capabilities following
+	// an invalid one
+	for params := b[10:]; len(params) >= 2; {
+		paramType, paramLen := params[0], params[1]
+		value := params[2 : 2+int(paramLen)]
+		params = params[2+int(paramLen):]
+		if paramType != bgp.BGP_OPT_CAPABILITY {
+			continue
+		}
+		c := &bgp.OptionParameterCapability{
+			ParamType: paramType,
+			ParamLen:  paramLen,
+		}
+		if c.DecodeFromBytes(value) != nil {
+			o.capabilitiesInvalid = true
+		}
+		for _, cap := range c.Capability {
+			o.capabilities = append(o.capabilities,
+				[2]int{int(cap.Code()), cap.Len() - 2})
+		}
+	}
+	return o, nil
+}
+
+func (gobgpParser) parseUpdate(b []byte) (u *referenceUpdate, err error) {
+	defer recoverReference(&err)
+	m := &bgp.BGPUpdate{}
+	u = &referenceUpdate{
+		errAttrType:       -1,
+		unsupportedFamily: hasUnsupportedFamily(b),
+	}
+	if err := m.DecodeFromBytes(b); err != nil {
+		u.verdict = verdictReject
+		if merr, ok := err.(*bgp.MessageError); ok {
+			u.subcode = merr.SubTypeCode
+			if merr.ErrorAttribute != nil {
+				u.errAttrType = int((*merr.ErrorAttribute).GetType())
+			}
+			switch merr.ErrorHandling {
+			case bgp.ERROR_HANDLING_TREAT_AS_WITHDRAW,
+				bgp.ERROR_HANDLING_ATTRIBUTE_DISCARD:
+				u.verdict = verdictAttrError
+			}
+		}
+		if u.verdict == verdictReject {
+			return u, nil
+		}
+	}
+	for _, p := range m.WithdrawnRoutes {
+		u.withdrawn = append(u.withdrawn, maskedPrefix(p.String()))
+	}
+	u.nlriParsed = m.NLRI != nil
+	for _, p := range m.NLRI {
+		u.nlri = append(u.nlri, maskedPrefix(p.String()))
+	}
+	for _, a := range m.PathAttributes {
+		u.attrTypes = append(u.attrTypes, uint8(a.GetType()))
+		switch a := a.(type) {
+		case *bgp.PathAttributeMpReachNLRI:
+			if isPrefixSAFI(a.AFI, a.SAFI) {
+				for _, p := range a.Value {
+					u.mpReach = append(u.mpReach, maskedPrefix(p.String()))
+				}
+			}
+		case *bgp.PathAttributeMpUnreachNLRI:
+			if isPrefixSAFI(a.AFI, a.SAFI) {
+				for _, p := range a.Value {
+					u.mpUnreach = append(u.mpUnreach,
+						maskedPrefix(p.String()))
+				}
+			}
+		}
+	}
+	return u, nil
+}
+
+func (gobgpParser) parseNotification(b []byte) (n *referenceNotification,
+	err error) {
+	defer recoverReference(&err)
+	m := &bgp.BGPNotification{}
+	if err := m.DecodeFromBytes(b); err != nil {
+		return nil, err
+	}
+	return &referenceNotification{
+		code:    m.ErrorCode,
+		subcode: m.ErrorSubcode,
+		data:    m.Data,
+	}, nil
+}
+
+// hasUnsupportedFamily returns true if the UPDATE message body b carries an
+// MP_REACH_NLRI or MP_UNREACH_NLRI attribute of an AFI/SAFI gobgp does not
+// implement.
+func hasUnsupportedFamily(b []byte) bool {
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		return false
+	}
+	b = b[2+int(binary.BigEndian.Uint16(b)):]
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		return false
+	}
+	attrs := b[2 : 2+int(binary.BigEndian.Uint16(b))]
+	for len(attrs) >= 3 {
+		flags, attrType := attrs[0], attrs[1]
+		n, l := 3, int(attrs[2])
+		if flags&uint8(bgp.BGP_ATTR_FLAG_EXTENDED_LENGTH) != 0 {
+			if len(attrs) < 4 {
+				return false
+			}
+			n, l = 4, int(binary.BigEndian.Uint16(attrs[2:]))
+		}
+		if len(attrs) < n+l {
+			return false
+		}
+		value := attrs[n : n+l]
+		attrs = attrs[n+l:]
+		if (attrType == uint8(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI) ||
+			attrType == uint8(bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI)) &&
+			len(value) >= 3 {
+			_, err := bgp.NewPrefixFromRouteFamily(
+				binary.BigEndian.Uint16(value), value[2])
+			if err != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPrefixSAFI returns true for the IPv4 and IPv6 unicast and multicast
+// AFI/SAFIs, whose NLRI are decoded by corebgp.
+func isPrefixSAFI(afi uint16, safi uint8) bool {
+	return (afi == corebgp.AFIIPv4 || afi == corebgp.AFIIPv6) &&
+		(safi == corebgp.SAFIUnicast || safi == corebgp.SAFIMulticast)
+}
+
+// maskedPrefix returns the CIDR prefix s with its host bits cleared.
+func maskedPrefix(s string) string {
+	_, p, err := net.ParseCIDR(s)
+	if err != nil {
+		return s
+	}
+	return p.String()
+}
@@ -0,0 +1,105 @@
+package corebgp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultDualStackDelay is the default delay before racing a connection to
+// the alternate address of a dual-stack peer, as recommended by RFC8305.
+const DefaultDualStackDelay = 250 * time.Millisecond
+
+// DualStack returns a PeerOption that races outbound connections to the
+// peer's IP and alt, its address of the other address family, in the manner
+// of Happy Eyeballs (RFC8305). The preferred address is dialed first, and alt
+// once delay elapsed without a connection being established or the first
+// dial failed. The first connection established is used for the session and
+// the other is abandoned.
+//
+// The peer's IP is preferred initially. If a session fails before reaching
+// the OpenConfirm state on a connection to the preferred address, e.g. due to
+// a path dropping larger segments, the other address is preferred for the
+// following attempts. Incoming connections are only accepted from the peer's
+// IP. A LocalAddress only applies to the address of its family.
+func DualStack(alt net.IP, delay time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.dualStackAddress = alt
+		o.dualStackDelay = delay
+		if delay <= 0 {
+			o.dualStackDelay = DefaultDualStackDelay
+		}
+	})
+}
+
+// raceDial dials addrs in order, starting each dial once delay elapsed
+// since the previous one started or the previous one failed. It returns the
+// first connection established along with the index of its address, closing
+// any other connections established.
+func raceDial(ctx context.Context, dialers []ContextDialer, addrs []string,
+	delay time.Duration) (net.Conn, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		i    int
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	var (
+		started, pending int
+		timer            *time.Timer
+	)
+	// dialNext starts the next dial, if any, and restarts the timer
+	dialNext := func() {
+		if started == len(addrs) {
+			return
+		}
+		i := started
+		go func() {
+			conn, err := dialers[i].DialContext(ctx, "tcp", addrs[i])
+			results <- result{conn: conn, i: i, err: err}
+		}()
+		started++
+		pending++
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.NewTimer(delay)
+	}
+	dialNext()
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			dialNext()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// abandon the remaining dials
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, r.i, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			dialNext()
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("no addresses to dial")
+	}
+	return nil, 0, firstErr
+}
@@ -0,0 +1,205 @@
+package corebgp
+
+import (
+	"errors"
+	"math"
+)
+
+// This file implements the Dynamic Capability mechanism, which allows
+// capabilities to be advertised or removed on an established session via the
+// CAPABILITY message.
+// https://tools.ietf.org/html/draft-ietf-idr-dynamic-cap
+
+// CapabilityMessageType is the message type of a CAPABILITY message.
+const (
+	CapabilityMessageType uint8 = 6
+)
+
+// DynamicCapabilityAction is the action of a DynamicCapabilityChange.
+type DynamicCapabilityAction uint8
+
+// DynamicCapabilityAction values
+const (
+	DynamicCapabilitySet    DynamicCapabilityAction = 0
+	DynamicCapabilityRemove DynamicCapabilityAction = 1
+)
+
+// DynamicCapabilityChange is a single change carried by a CAPABILITY message.
+type DynamicCapabilityChange struct {
+	Action     DynamicCapabilityAction
+	Capability *Capability
+}
+
+// NewDynamicCapabilityCap returns a Dynamic Capability capability listing the
+// capability codes that may be changed via CAPABILITY messages.
+func NewDynamicCapabilityCap(codes ...uint8) *Capability {
+	value := make([]byte, len(codes))
+	copy(value, codes)
+	return &Capability{
+		Code:  CapCodeDynamicCapability,
+		Value: value,
+	}
+}
+
+// DecodeDynamicCapabilityCap decodes a Dynamic Capability capability,
+// returning the capability codes that may be changed via CAPABILITY messages.
+func DecodeDynamicCapabilityCap(c *Capability) ([]uint8, error) {
+	err := checkCapCode(c, CapCodeDynamicCapability)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]uint8, len(c.Value))
+	copy(codes, c.Value)
+	return codes, nil
+}
+
+type capabilityMessage []DynamicCapabilityChange
+
+func (c capabilityMessage) messageType() uint8 {
+	return CapabilityMessageType
+}
+
+// capabilityMessageError returns a CAPABILITY Message Error with subcode,
+// carrying the offending change encoded in data.
+func capabilityMessageError(subcode uint8, data []byte) error {
+	n := newNotification(NotifCodeCapabilityMessageErr, subcode, data)
+	return newNotificationError(n, true)
+}
+
+func (c *capabilityMessage) decode(b []byte) error {
+	changes := make([]DynamicCapabilityChange, 0)
+	for len(b) > 0 {
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return capabilityMessageError(NotifSubcodeInvalidCapabilityLength,
+				b)
+		}
+		raw := b[:3+int(b[2])]
+		action := DynamicCapabilityAction(b[0])
+		if action != DynamicCapabilitySet && action != DynamicCapabilityRemove {
+			return capabilityMessageError(NotifSubcodeInvalidActionValue, raw)
+		}
+		value := make([]byte, b[2])
+		copy(value, b[3:3+int(b[2])])
+		change := DynamicCapabilityChange{
+			Action: action,
+			Capability: &Capability{
+				Code:  b[1],
+				Value: value,
+			},
+		}
+		if !change.wellFormed() {
+			return capabilityMessageError(
+				NotifSubcodeMalformedCapabilityValue, raw)
+		}
+		changes = append(changes, change)
+		b = b[3+int(b[2]):]
+	}
+	if len(changes) == 0 {
+		return capabilityMessageError(NotifSubcodeInvalidCapabilityLength,
+			nil)
+	}
+	*c = changes
+	return nil
+}
+
+// wellFormed returns false if the value of the capability of c is malformed.
+// The value of a removed capability is checked only if present, as it may
+// be omitted.
+func (c *DynamicCapabilityChange) wellFormed() bool {
+	capability := c.Capability
+	if c.Action == DynamicCapabilityRemove && len(capability.Value) == 0 {
+		return true
+	}
+	var err error
+	switch capability.Code {
+	case CapCodeMPExtensions:
+		_, _, err = DecodeMPExtensionsCap(capability)
+	case CapCodeFourOctetAS:
+		_, err = DecodeFourOctetASCap(capability)
+	case CapCodeGracefulRestart:
+		_, err = DecodeGracefulRestartCap(capability)
+	case CapCodeAddPath:
+		_, err = DecodeAddPathCap(capability)
+	case CapCodeRouteRefresh, CapCodeEnhancedRouteRefresh,
+		CapCodeExtendedMessage:
+		if len(capability.Value) != 0 {
+			return false
+		}
+	default:
+		if t, ok := lookupCapType(capability.Code); ok && t.Decode != nil {
+			_, err = t.Decode(capability.Value)
+		}
+	}
+	return err == nil
+}
+
+// checkCapabilityCodes returns a CAPABILITY Message Error if c changes a
+// capability whose code is not among codes, those the peer listed in its
+// Dynamic Capability capability.
+func (c capabilityMessage) checkCapabilityCodes(codes []uint8) error {
+	for _, change := range c {
+		if !containsCode(codes, change.Capability.Code) {
+			raw := []byte{uint8(change.Action), change.Capability.Code,
+				uint8(len(change.Capability.Value))}
+			raw = append(raw, change.Capability.Value...)
+			return capabilityMessageError(
+				NotifSubcodeUnsupportedCapabilityCode, raw)
+		}
+	}
+	return nil
+}
+
+func containsCode(codes []uint8, code uint8) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// encode encodes the message body, excluding the header.
+func (c capabilityMessage) encode() ([]byte, error) {
+	if len(c) == 0 {
+		return nil, errors.New("empty capability message")
+	}
+	b := make([]byte, 0)
+	for _, change := range c {
+		if change.Capability == nil {
+			return nil, errors.New("nil capability in capability message")
+		}
+		if len(change.Capability.Value) > math.MaxUint8 {
+			return nil, errors.New("capability value too long")
+		}
+		b = append(b, uint8(change.Action), change.Capability.Code,
+			uint8(len(change.Capability.Value)))
+		b = append(b, change.Capability.Value...)
+	}
+	return b, nil
+}
+
+// DynamicCapabilityPlugin may optionally be implemented by a Plugin in order
+// to receive CAPABILITY messages from peers with which Dynamic Capability has
+// been negotiated. See the DynamicCapability PeerOption.
+type DynamicCapabilityPlugin interface {
+	// OnCapabilityMessage is fired when a CAPABILITY message is received from
+	// a peer in the Established state. Returning a non-nil Notification will
+	// cause it to be sent to the peer and the FSM will transition to the Idle
+	// state.
+	OnCapabilityMessage(peer *PeerConfig,
+		changes []DynamicCapabilityChange) *Notification
+}
+
+// CapabilityMessageWriter is implemented by the UpdateMessageWriter passed to
+// Plugin.OnEstablished().
+type CapabilityMessageWriter interface {
+	// WriteCapability sends a CAPABILITY message to the remote peer. An error
+	// is returned if Dynamic Capability has not been negotiated, the write
+	// fails and/or the FSM is no longer in an established state.
+	WriteCapability(changes ...DynamicCapabilityChange) error
+}
+
+var (
+	errDynamicCapabilityNotNegotiated = errors.New("dynamic capability not " +
+		"negotiated")
+)
@@ -0,0 +1,152 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// Entropy label attribute type values
+const (
+	// AttrTypeEntropyLabelCapability is the Entropy Label Capability
+	// attribute (ELCA) of RFC6790, deprecated by RFC7447 as it does not
+	// identify the next hop able to process entropy labels. Received ELCAs
+	// should be ignored, and none sent.
+	AttrTypeEntropyLabelCapability uint8 = 28
+	// AttrTypeNextHopCharacteristics is the Next-Hop Dependent
+	// Characteristics attribute (NHC) replacing it, of
+	// draft-ietf-idr-next-hop-capability.
+	AttrTypeNextHopCharacteristics uint8 = 39
+)
+
+// Next-hop characteristic code values
+const (
+	// NHCCodeEntropyLabel is the Entropy Label Capability (ELCv3) of
+	// draft-ietf-idr-entropy-label, indicating the next hop can process
+	// entropy labels (RFC6790). It has no value.
+	NHCCodeEntropyLabel uint16 = 2
+)
+
+// NHCharacteristic is a characteristic TLV of a Next-Hop Dependent
+// Characteristics attribute.
+type NHCharacteristic struct {
+	Code  uint16
+	Value []byte
+}
+
+// NextHopCharacteristics is the value of a Next-Hop Dependent
+// Characteristics attribute, describing characteristics of NextHop, such as
+// its ability to process entropy labels. It is experimental and subject to
+// change along with the draft.
+//
+// A speaker setting a route's next hop to another address than NextHop, e.g.
+// to itself, must not propagate the attribute unchanged: it either removes
+// it, or replaces it describing the new next hop. Receivers ignore an
+// attribute whose NextHop is not that of the route.
+// https://tools.ietf.org/html/draft-ietf-idr-next-hop-capability
+type NextHopCharacteristics struct {
+	NextHop         net.IP
+	Characteristics []NHCharacteristic
+}
+
+// NewEntropyLabelNextHopCharacteristics returns NextHopCharacteristics
+// advertising that nextHop can process entropy labels, e.g. for labeled
+// unicast routes whose next hop is set to the local speaker.
+func NewEntropyLabelNextHopCharacteristics(
+	nextHop net.IP) *NextHopCharacteristics {
+	return &NextHopCharacteristics{
+		NextHop: nextHop,
+		Characteristics: []NHCharacteristic{{
+			Code: NHCCodeEntropyLabel,
+		}},
+	}
+}
+
+// Characteristic returns the first characteristic of code.
+func (n *NextHopCharacteristics) Characteristic(
+	code uint16) (NHCharacteristic, bool) {
+	for _, c := range n.Characteristics {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return NHCharacteristic{}, false
+}
+
+// EntropyLabelCapable returns true if n advertises that nextHop, the next
+// hop of the route carrying n, can process entropy labels. It returns false
+// if NextHop is not nextHop, as the attribute then describes a previous next
+// hop.
+func (n *NextHopCharacteristics) EntropyLabelCapable(nextHop net.IP) bool {
+	if !n.NextHop.Equal(nextHop) {
+		return false
+	}
+	_, ok := n.Characteristic(NHCCodeEntropyLabel)
+	return ok
+}
+
+var errMalformedNHC = errors.New("malformed next-hop dependent " +
+	"characteristics attribute")
+
+// Decode decodes a Next-Hop Dependent Characteristics attribute value.
+// Values are copied from b.
+func (n *NextHopCharacteristics) Decode(b []byte) error {
+	if len(b) < 3 {
+		return errMalformedNHC
+	}
+	afi, nhLen := binary.BigEndian.Uint16(b), int(b[2])
+	b = b[3:]
+	switch {
+	case afi == AFIIPv4 && nhLen == net.IPv4len,
+		afi == AFIIPv6 && nhLen == net.IPv6len:
+	default:
+		return fmt.Errorf("invalid next-hop dependent characteristics "+
+			"next hop: afi %d length %d", afi, nhLen)
+	}
+	if len(b) < nhLen {
+		return errMalformedNHC
+	}
+	n.NextHop = append(net.IP(nil), b[:nhLen]...)
+	b = b[nhLen:]
+	n.Characteristics = nil
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return errMalformedNHC
+		}
+		valueLen := int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < 4+valueLen {
+			return errMalformedNHC
+		}
+		n.Characteristics = append(n.Characteristics, NHCharacteristic{
+			Code:  binary.BigEndian.Uint16(b),
+			Value: append([]byte(nil), b[4:4+valueLen]...),
+		})
+		b = b[4+valueLen:]
+	}
+	return nil
+}
+
+// Encode encodes the Next-Hop Dependent Characteristics attribute value.
+func (n *NextHopCharacteristics) Encode() ([]byte, error) {
+	afi, nextHop := AFIIPv4, n.NextHop.To4()
+	if nextHop == nil {
+		afi, nextHop = AFIIPv6, n.NextHop.To16()
+	}
+	if nextHop == nil {
+		return nil, fmt.Errorf("invalid next hop: %s", n.NextHop)
+	}
+	b := []byte{uint8(afi >> 8), uint8(afi), uint8(len(nextHop))}
+	b = append(b, nextHop...)
+	for _, c := range n.Characteristics {
+		if len(c.Value) > math.MaxUint16 {
+			return nil, fmt.Errorf("next-hop characteristic %d too long",
+				c.Code)
+		}
+		b = append(b, uint8(c.Code>>8), uint8(c.Code),
+			uint8(len(c.Value)>>8), uint8(len(c.Value)))
+		b = append(b, c.Value...)
+	}
+	return b, nil
+}
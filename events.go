@@ -0,0 +1,273 @@
+package corebgp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is a Server event delivered to subscribers. It is one of
+// *PeerAddedEvent, *PeerDeletedEvent, *StateChangeEvent,
+// *EstablishmentFailedEvent, *OpenReceivedEvent, *NotificationReceivedEvent,
+// *NotificationSentEvent, *UpdateRateAlarmEvent, *InboundRateLimitEvent,
+// *CapabilitiesDroppedEvent, *DialFailedEvent, *EstablishmentAttemptEvent,
+// *TimerRescheduledEvent or *UpdateReceivedEvent.
+type Event interface {
+	// EventTime returns the time at which the event occurred.
+	EventTime() time.Time
+	// EventPeer returns the IP address of the peer the event relates to.
+	EventPeer() net.IP
+	// EventPeerKey returns the key of the peer the event relates to, which
+	// tells apart peers sharing an IP address.
+	EventPeerKey() PeerKey
+}
+
+type eventBase struct {
+	Time time.Time
+	Peer net.IP
+	Key  PeerKey
+}
+
+func newEventBase(p *peer) eventBase {
+	return eventBase{
+		Time: time.Now(),
+		Peer: p.config.IP,
+		Key:  p.peerKey(),
+	}
+}
+
+func (e *eventBase) EventTime() time.Time {
+	return e.Time
+}
+
+func (e *eventBase) EventPeer() net.IP {
+	return e.Peer
+}
+
+func (e *eventBase) EventPeerKey() PeerKey {
+	return e.Key
+}
+
+// PeerAddedEvent is published when a peer is added to the Server.
+type PeerAddedEvent struct {
+	eventBase
+}
+
+// PeerDeletedEvent is published when a peer is deleted from the Server.
+type PeerDeletedEvent struct {
+	eventBase
+}
+
+// StateChangeEvent is published when one of a peer's FSMs changes state.
+type StateChangeEvent struct {
+	eventBase
+	// Inbound is true if the FSM is handling a connection accepted from the
+	// peer.
+	Inbound bool
+	From    FSMState
+	To      FSMState
+}
+
+// EstablishmentFailedEvent is published when an error occurs in one of a
+// peer's FSMs prior to reaching the Established state.
+type EstablishmentFailedEvent struct {
+	eventBase
+	Inbound bool
+	// State is the state of the FSM at the time of the error.
+	State FSMState
+	Err   error
+}
+
+// OpenReceivedEvent is published when an OPEN message is received from a
+// peer, prior to its validation.
+type OpenReceivedEvent struct {
+	eventBase
+	Inbound bool
+	Open    *OpenMessage
+}
+
+// NotificationReceivedEvent is published when a NOTIFICATION message is
+// received from a peer.
+type NotificationReceivedEvent struct {
+	eventBase
+	Notification *Notification
+}
+
+// NotificationSentEvent is published when a NOTIFICATION message is sent to
+// a peer due to an error, e.g. one returned by an UpdateMessageHandler.
+type NotificationSentEvent struct {
+	eventBase
+	Notification *Notification
+}
+
+// UpdateRateAlarmEvent is published at most once per second when the rate of
+// UPDATE messages received from a peer exceeds the threshold set via the
+// UpdateRateAlarm PeerOption.
+type UpdateRateAlarmEvent struct {
+	eventBase
+	// Rate is the number of UPDATE messages received within the current one
+	// second window when the threshold was exceeded.
+	Rate      int
+	Threshold int
+}
+
+// InboundRateLimitEvent is published when a peer exceeds the limit set via
+// the InboundRateLimit PeerOption with an Action of RateLimitWarn, at most
+// once per second, or RateLimitTeardown.
+type InboundRateLimitEvent struct {
+	eventBase
+	Action RateLimitAction
+}
+
+// CapabilitiesDroppedEvent is published when optional capabilities are
+// dropped from OPEN messages sent to a peer as it reported them unsupported.
+// See OptionalCapabilities.
+type CapabilitiesDroppedEvent struct {
+	eventBase
+	Capabilities []*Capability
+}
+
+// EstablishmentAttemptEvent is published when an attempt to establish a
+// session with a peer ends, successfully or not.
+type EstablishmentAttemptEvent struct {
+	eventBase
+	Timeline EstablishmentTimeline
+}
+
+// DialFailedEvent is published when an outbound connection attempt to a peer
+// fails.
+type DialFailedEvent struct {
+	eventBase
+	Cause DialFailureCause
+	Err   error
+	// NextAttempt is the time of the next scheduled attempt.
+	NextAttempt time.Time
+}
+
+// UpdateReceivedEvent is published when an UPDATE message is received from a
+// peer. It is only delivered to subscriptions created by
+// Server.SubscribeUpdates().
+type UpdateReceivedEvent struct {
+	eventBase
+	// FourOctetAS is true if four-octet AS numbers were negotiated for the
+	// session, which determines the encoding of AS_PATH.
+	FourOctetAS bool
+	// Codec contains the negotiated parameters of the session determining
+	// the encoding of the update.
+	Codec *Codec
+	// Update is the UPDATE message body. It is shared with the peer's plugin
+	// and must not be modified.
+	Update []byte
+}
+
+// Subscription is a subscription to Server events created by
+// Server.Subscribe().
+type Subscription struct {
+	ch      chan Event
+	bus     *eventBus
+	dropped uint64
+	once    sync.Once
+	// true if the subscription receives UpdateReceivedEvent exclusively
+	updates bool
+}
+
+// C returns the channel on which events are delivered. It is closed by
+// Close().
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the subscription's
+// buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close ends the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.unsubscribe(s)
+		close(s.ch)
+	})
+}
+
+// eventBus fans out events to subscriptions without blocking the publisher.
+type eventBus struct {
+	updateSubs int32
+	mu         sync.RWMutex
+	subs       map[*Subscription]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *eventBus) subscribe(bufferSize int, updates bool) *Subscription {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	s := &Subscription{
+		ch:      make(chan Event, bufferSize),
+		bus:     b,
+		updates: updates,
+	}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	if updates {
+		atomic.AddInt32(&b.updateSubs, 1)
+	}
+	return s
+}
+
+func (b *eventBus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+	if s.updates {
+		atomic.AddInt32(&b.updateSubs, -1)
+	}
+}
+
+// wantsUpdates returns true if there are any update subscriptions, allowing
+// publishers to skip constructing an UpdateReceivedEvent.
+func (b *eventBus) wantsUpdates() bool {
+	return b != nil && atomic.LoadInt32(&b.updateSubs) > 0
+}
+
+func (b *eventBus) publish(e Event) {
+	if b == nil {
+		return
+	}
+	_, isUpdate := e.(*UpdateReceivedEvent)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if s.updates != isUpdate {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Subscribe returns a Subscription delivering Server events other than
+// UpdateReceivedEvent. Up to bufferSize events are buffered; events published
+// while the buffer is full are dropped and counted by Subscription.Dropped().
+// The Subscription should be closed when no longer needed.
+func (s *Server) Subscribe(bufferSize int) *Subscription {
+	return s.events.subscribe(bufferSize, false)
+}
+
+// SubscribeUpdates returns a Subscription delivering an UpdateReceivedEvent
+// for every UPDATE message received from any peer. Buffering and dropping
+// behave as with Subscribe.
+func (s *Server) SubscribeUpdates(bufferSize int) *Subscription {
+	return s.events.subscribe(bufferSize, true)
+}
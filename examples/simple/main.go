@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/binary"
 	"flag"
 	"log"
 	"net"
@@ -75,23 +74,15 @@ func main() {
 type plugin struct {
 }
 
-func newMPCap(afi uint16, safi uint8) *corebgp.Capability {
-	mpData := make([]byte, 4)
-	binary.BigEndian.PutUint16(mpData, afi)
-	mpData[3] = safi
-	return &corebgp.Capability{
-		Code:  1,
-		Value: mpData,
-	}
-}
-
 func (p *plugin) GetCapabilities(c *corebgp.PeerConfig) []*corebgp.Capability {
 	caps := make([]*corebgp.Capability, 0)
 	if *ipv4 {
-		caps = append(caps, newMPCap(1, 1))
+		caps = append(caps, corebgp.NewMPExtensionsCap(corebgp.AFIIPv4,
+			corebgp.SAFIUnicast))
 	}
 	if *ipv6 {
-		caps = append(caps, newMPCap(2, 1))
+		caps = append(caps, corebgp.NewMPExtensionsCap(corebgp.AFIIPv6,
+			corebgp.SAFIUnicast))
 	}
 	return caps
 }
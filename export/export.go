@@ -0,0 +1,413 @@
+//go:build go1.18
+// +build go1.18
+
+// Package export publishes UPDATE messages and session events received by a
+// corebgp.Server to a message bus such as Kafka or NATS.
+//
+// An Exporter subscribes to a Server, encodes events via an Encoder, buffers
+// and batches the resulting messages, and hands batches to a Sink. A Kafka
+// Sink using the Kafka REST Proxy API is provided by package kafkarest. A
+// NATS Sink is provided by the export/nats module, which is separate so that
+// corebgp remains free of dependencies. Encoders produce JSON, see
+// NewJSONEncoder, or protobuf, see the grpcapi module.
+//
+// The Server never blocks on subscribers. When the Exporter cannot keep up,
+// messages are dropped according to its OverflowPolicy and counted by
+// Exporter.Stats().
+package export
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/updatejson"
+)
+
+// Message is a message published by a Sink.
+type Message struct {
+	// Topic is the Kafka topic or NATS subject of the message. If empty the
+	// Exporter sets it according to the UpdateTopic and EventTopic options.
+	Topic string
+	// Key is the peer's corebgp.PeerKey in its string form, e.g.
+	// "192.0.2.1/65001", which may be used by the Sink for partitioning.
+	Key   []byte
+	Value []byte
+}
+
+// Encoder encodes Server events as Messages. Events producing no messages
+// are skipped.
+type Encoder interface {
+	Encode(e corebgp.Event) ([]Message, error)
+}
+
+// Sink publishes batches of Messages.
+type Sink interface {
+	// Publish publishes batch. The Exporter does not retry a failed
+	// Publish; Sinks are expected to retry transient errors internally.
+	Publish(ctx context.Context, batch []Message) error
+	Close() error
+}
+
+// OverflowPolicy determines the handling of messages when an Exporter's
+// buffer is full.
+type OverflowPolicy uint8
+
+// OverflowPolicy values
+const (
+	// OverflowDropNewest drops the messages being buffered.
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered messages to make room.
+	OverflowDropOldest
+	// OverflowBlock stops reading events until there is room in the buffer.
+	// Events published by the Server in the meantime are dropped by its
+	// subscriptions.
+	OverflowBlock
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// Default Exporter option values
+const (
+	DefaultUpdateTopic   = "corebgp.updates"
+	DefaultEventTopic    = "corebgp.events"
+	DefaultBatchSize     = 1000
+	DefaultBatchInterval = time.Second
+	DefaultBufferSize    = 100000
+)
+
+type options struct {
+	updateTopic   string
+	eventTopic    string
+	batchSize     int
+	batchInterval time.Duration
+	bufferSize    int
+	overflow      OverflowPolicy
+	errorHandler  func(error)
+}
+
+func (o *options) setDefaults() {
+	o.updateTopic = DefaultUpdateTopic
+	o.eventTopic = DefaultEventTopic
+	o.batchSize = DefaultBatchSize
+	o.batchInterval = DefaultBatchInterval
+	o.bufferSize = DefaultBufferSize
+	o.overflow = OverflowDropNewest
+	o.errorHandler = func(error) {}
+}
+
+// Option is an option for an Exporter.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// UpdateTopic sets the topic of messages encoded from UPDATE messages.
+func UpdateTopic(topic string) Option {
+	return newFuncOption(func(o *options) {
+		o.updateTopic = topic
+	})
+}
+
+// EventTopic sets the topic of messages encoded from session events.
+func EventTopic(topic string) Option {
+	return newFuncOption(func(o *options) {
+		o.eventTopic = topic
+	})
+}
+
+// BatchSize sets the maximum number of messages passed to Sink.Publish.
+func BatchSize(n int) Option {
+	return newFuncOption(func(o *options) {
+		if n > 0 {
+			o.batchSize = n
+		}
+	})
+}
+
+// BatchInterval sets the maximum duration a message is held waiting for a
+// batch to fill.
+func BatchInterval(d time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		if d > 0 {
+			o.batchInterval = d
+		}
+	})
+}
+
+// BufferSize sets the maximum number of messages buffered awaiting
+// publishing.
+func BufferSize(n int) Option {
+	return newFuncOption(func(o *options) {
+		if n > 0 {
+			o.bufferSize = n
+		}
+	})
+}
+
+// Overflow sets the OverflowPolicy applied when the buffer is full.
+func Overflow(p OverflowPolicy) Option {
+	return newFuncOption(func(o *options) {
+		o.overflow = p
+	})
+}
+
+// ErrorHandler sets a function called with encoding and publishing errors.
+// It must not block.
+func ErrorHandler(fn func(error)) Option {
+	return newFuncOption(func(o *options) {
+		o.errorHandler = fn
+	})
+}
+
+// Stats are counters of an Exporter.
+type Stats struct {
+	// Published is the number of messages successfully published.
+	Published uint64
+	// Dropped is the number of messages dropped due to overflow.
+	Dropped uint64
+	// EventsDropped is the number of events dropped by the Exporter's Server
+	// subscriptions.
+	EventsDropped uint64
+	// EncodeErrors is the number of events that failed to encode.
+	EncodeErrors uint64
+	// PublishErrors is the number of failed calls to Sink.Publish.
+	PublishErrors uint64
+}
+
+// Exporter publishes events of a Server to a Sink.
+type Exporter struct {
+	server  *corebgp.Server
+	sink    Sink
+	encoder Encoder
+	options options
+
+	mu     sync.Mutex
+	buf    []Message
+	cond   *sync.Cond
+	done   bool
+	subs   []*corebgp.Subscription
+	subsMu sync.Mutex
+
+	published     uint64
+	dropped       uint64
+	encodeErrors  uint64
+	publishErrors uint64
+}
+
+// NewExporter returns an Exporter publishing events of server encoded by
+// encoder to sink.
+func NewExporter(server *corebgp.Server, sink Sink, encoder Encoder,
+	opts ...Option) *Exporter {
+	e := &Exporter{
+		server:  server,
+		sink:    sink,
+		encoder: encoder,
+	}
+	e.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&e.options)
+	}
+	e.cond = sync.NewCond(&e.mu)
+	return e
+}
+
+// Stats returns the counters of the Exporter.
+func (e *Exporter) Stats() Stats {
+	s := Stats{
+		Published:     atomic.LoadUint64(&e.published),
+		Dropped:       atomic.LoadUint64(&e.dropped),
+		EncodeErrors:  atomic.LoadUint64(&e.encodeErrors),
+		PublishErrors: atomic.LoadUint64(&e.publishErrors),
+	}
+	e.subsMu.Lock()
+	for _, sub := range e.subs {
+		s.EventsDropped += sub.Dropped()
+	}
+	e.subsMu.Unlock()
+	return s
+}
+
+// enqueue buffers messages according to the OverflowPolicy.
+func (e *Exporter) enqueue(messages []Message) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, m := range messages {
+		if e.options.overflow == OverflowBlock {
+			for len(e.buf) >= e.options.bufferSize && !e.done {
+				e.cond.Wait()
+			}
+		}
+		if len(e.buf) >= e.options.bufferSize {
+			atomic.AddUint64(&e.dropped, 1)
+			if e.options.overflow == OverflowDropNewest {
+				continue
+			}
+			e.buf = e.buf[1:]
+		}
+		e.buf = append(e.buf, m)
+	}
+	e.cond.Broadcast()
+}
+
+// dequeue returns up to batchSize buffered messages, waiting until a full
+// batch is buffered, the batch interval has elapsed or the Exporter is done.
+func (e *Exporter) dequeue(deadline time.Time) ([]Message, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for len(e.buf) < e.options.batchSize && !e.done &&
+		time.Now().Before(deadline) {
+		e.cond.Wait()
+	}
+	n := len(e.buf)
+	if n > e.options.batchSize {
+		n = e.options.batchSize
+	}
+	batch := make([]Message, n)
+	copy(batch, e.buf)
+	e.buf = e.buf[n:]
+	e.cond.Broadcast()
+	return batch, e.done && len(e.buf) == 0
+}
+
+func (e *Exporter) encode(ev corebgp.Event) {
+	messages, err := e.encoder.Encode(ev)
+	if err != nil {
+		atomic.AddUint64(&e.encodeErrors, 1)
+		e.options.errorHandler(err)
+		return
+	}
+	topic := e.options.eventTopic
+	if _, ok := ev.(*corebgp.UpdateReceivedEvent); ok {
+		topic = e.options.updateTopic
+	}
+	for i := range messages {
+		if messages[i].Topic == "" {
+			messages[i].Topic = topic
+		}
+		if messages[i].Key == nil {
+			messages[i].Key = []byte(ev.EventPeerKey().String())
+		}
+	}
+	e.enqueue(messages)
+}
+
+func (e *Exporter) read(ctx context.Context, sub *corebgp.Subscription) {
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-sub.C():
+			e.encode(ev)
+		}
+	}
+}
+
+// Run exports events until ctx is done, after which buffered messages are
+// published and the Sink is closed. Run may only be called once.
+func (e *Exporter) Run(ctx context.Context) error {
+	e.subsMu.Lock()
+	e.subs = []*corebgp.Subscription{
+		e.server.Subscribe(e.options.bufferSize),
+		e.server.SubscribeUpdates(e.options.bufferSize),
+	}
+	e.subsMu.Unlock()
+	var wg sync.WaitGroup
+	for _, sub := range e.subs {
+		wg.Add(1)
+		go func(sub *corebgp.Subscription) {
+			defer wg.Done()
+			e.read(ctx, sub)
+		}(sub)
+	}
+
+	// wake dequeue at the batch interval, and on ctx done
+	ticker := time.NewTicker(e.options.batchInterval)
+	defer ticker.Stop()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				e.mu.Lock()
+				e.done = true
+				e.cond.Broadcast()
+				e.mu.Unlock()
+				return
+			case <-ticker.C:
+				e.mu.Lock()
+				e.cond.Broadcast()
+				e.mu.Unlock()
+			}
+		}
+	}()
+
+	for {
+		batch, done := e.dequeue(time.Now().Add(e.options.batchInterval))
+		if len(batch) > 0 {
+			// buffered messages are flushed after ctx is done
+			err := e.sink.Publish(context.Background(), batch)
+			if err != nil {
+				atomic.AddUint64(&e.publishErrors, 1)
+				e.options.errorHandler(err)
+			} else {
+				atomic.AddUint64(&e.published, uint64(len(batch)))
+			}
+		}
+		if done {
+			return e.sink.Close()
+		}
+	}
+}
+
+type jsonEncoder struct {
+	serializer *updatejson.Serializer
+}
+
+// NewJSONEncoder returns an Encoder producing the RIS Live or OpenBMP JSON
+// messages of serializer.
+func NewJSONEncoder(serializer *updatejson.Serializer) Encoder {
+	return &jsonEncoder{
+		serializer: serializer,
+	}
+}
+
+func (j *jsonEncoder) Encode(e corebgp.Event) ([]Message, error) {
+	values, err := j.serializer.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, len(values))
+	for _, v := range values {
+		messages = append(messages, Message{
+			Value: v,
+		})
+	}
+	return messages, nil
+}
@@ -0,0 +1,162 @@
+//go:build go1.18
+// +build go1.18
+
+// Package kafkarest provides an export.Sink publishing to Kafka via the REST
+// Proxy API v2, as implemented by the Confluent REST Proxy and Redpanda's
+// HTTP Proxy. It requires no dependencies beyond the standard library.
+//
+// Applications preferring the native Kafka protocol can implement
+// export.Sink around a Kafka client, e.g. for github.com/segmentio/kafka-go:
+//
+//	func (s *sink) Publish(ctx context.Context, batch []export.Message) error {
+//		messages := make([]kafka.Message, 0, len(batch))
+//		for _, m := range batch {
+//			messages = append(messages, kafka.Message{
+//				Topic: m.Topic,
+//				Key:   m.Key,
+//				Value: m.Value,
+//			})
+//		}
+//		return s.writer.WriteMessages(ctx, messages...)
+//	}
+package kafkarest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jwhited/corebgp/export"
+)
+
+const (
+	contentType = "application/vnd.kafka.binary.v2+json"
+	accept      = "application/vnd.kafka.v2+json"
+)
+
+// Sink is an export.Sink publishing messages to Kafka topics via a REST
+// proxy. Messages are partitioned by key, i.e. by peer, by the proxy.
+type Sink struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewSink returns a Sink publishing via the REST proxy at baseURL, e.g.
+// "http://localhost:8082". client is used for requests, or
+// http.DefaultClient if nil.
+func NewSink(baseURL string, client *http.Client) *Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Sink{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+type record struct {
+	Key   []byte `json:"key,omitempty"`
+	Value []byte `json:"value"`
+}
+
+type produceRequest struct {
+	Records []record `json:"records"`
+}
+
+type produceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (s *Sink) produce(ctx context.Context, topic string,
+	records []record) error {
+	body, err := json.Marshal(&produceRequest{Records: records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.baseURL+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", accept)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.Unmarshal(b, &e) == nil && e.Message != "" {
+			return fmt.Errorf("error producing to topic %s: %s (%d)", topic,
+				e.Message, e.ErrorCode)
+		}
+		return fmt.Errorf("error producing to topic %s: %s", topic,
+			resp.Status)
+	}
+	var r produceResponse
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	var firstErr string
+	for _, o := range r.Offsets {
+		if o.ErrorCode != nil {
+			if failed == 0 {
+				firstErr = o.Error
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("error producing %d of %d records to topic %s: %s",
+			failed, len(records), topic, firstErr)
+	}
+	return nil
+}
+
+// Publish publishes batch, issuing a request per topic.
+func (s *Sink) Publish(ctx context.Context, batch []export.Message) error {
+	topics := make([]string, 0)
+	byTopic := make(map[string][]record)
+	for _, m := range batch {
+		if _, ok := byTopic[m.Topic]; !ok {
+			topics = append(topics, m.Topic)
+		}
+		byTopic[m.Topic] = append(byTopic[m.Topic], record{
+			Key:   m.Key,
+			Value: m.Value,
+		})
+	}
+	for _, topic := range topics {
+		err := s.produce(ctx, topic, byTopic[topic])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op, the http.Client is not owned by the Sink.
+func (s *Sink) Close() error {
+	return nil
+}
@@ -0,0 +1,49 @@
+// Package nats provides an export.Sink publishing to NATS.
+package nats
+
+import (
+	"context"
+
+	"github.com/jwhited/corebgp/export"
+	natsgo "github.com/nats-io/nats.go"
+)
+
+// Sink is an export.Sink publishing messages to NATS subjects. The topic of a
+// message is used as its subject. Keys are not used.
+type Sink struct {
+	conn *natsgo.Conn
+}
+
+// NewSink returns a Sink publishing via conn. conn is closed by Sink.Close.
+func NewSink(conn *natsgo.Conn) *Sink {
+	return &Sink{
+		conn: conn,
+	}
+}
+
+// Connect connects to the NATS server(s) at url and returns a Sink
+// publishing via the connection.
+func Connect(url string, options ...natsgo.Option) (*Sink, error) {
+	conn, err := natsgo.Connect(url, options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSink(conn), nil
+}
+
+// Publish publishes batch and waits for the NATS server to acknowledge it,
+// applying backpressure to the Exporter.
+func (s *Sink) Publish(ctx context.Context, batch []export.Message) error {
+	for _, m := range batch {
+		err := s.conn.Publish(m.Topic, m.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return s.conn.FlushWithContext(ctx)
+}
+
+// Close drains and closes the connection.
+func (s *Sink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,100 @@
+// Package expvarmetrics publishes the aggregate metrics of a corebgp.Server
+// via expvar, for environments without a dedicated metrics system.
+//
+// Publish("corebgp", srv) publishes the following variables, which are
+// evaluated each time expvar is read:
+//
+//	corebgp_sessions           number of peers by most advanced FSM state
+//	corebgp_messages_received  number of messages received by message type
+//	corebgp_messages_sent      number of messages sent by message type
+//	corebgp_connect_attempts   number of outbound connection attempts
+//	corebgp_decode_errors      number of received messages that could not be
+//	                           framed or decoded
+//	corebgp_memory_bytes       bytes of messages buffered for peers by buffer
+//
+// The keys of corebgp_sessions are "idle", "connect", "active", "openSent",
+// "openConfirm", "established" and "disabled", all of which are always
+// present. The keys of the message maps are "open", "update",
+// "notification", "keepalive", "route_refresh", "capability" or "type_N"
+// for other message types N, and are present once a message of the type was
+// counted. The keys of corebgp_memory_bytes are "inbound_queue",
+// "outbound_queue" and "message_history".
+package expvarmetrics
+
+import (
+	"expvar"
+	"strconv"
+
+	"github.com/jwhited/corebgp"
+)
+
+var states = []corebgp.FSMState{
+	corebgp.DisabledState,
+	corebgp.IdleState,
+	corebgp.ConnectState,
+	corebgp.ActiveState,
+	corebgp.OpenSentState,
+	corebgp.OpenConfirmState,
+	corebgp.EstablishedState,
+}
+
+var messageTypeNames = map[uint8]string{
+	corebgp.OpenMessageType:         "open",
+	corebgp.UpdateMessageType:       "update",
+	corebgp.NotificationMessageType: "notification",
+	corebgp.KeepAliveMessageType:    "keepalive",
+	corebgp.RouteRefreshMessageType: "route_refresh",
+	corebgp.CapabilityMessageType:   "capability",
+}
+
+func messageTypeName(t uint8) string {
+	name, ok := messageTypeNames[t]
+	if ok {
+		return name
+	}
+	return "type_" + strconv.Itoa(int(t))
+}
+
+func messageCounts(counts map[uint8]uint64) map[string]uint64 {
+	m := make(map[string]uint64, len(counts))
+	for t, n := range counts {
+		m[messageTypeName(t)] = n
+	}
+	return m
+}
+
+// Publish publishes the metrics of server as expvar variables named with
+// prefix followed by an underscore. Like expvar.Publish it panics if a name
+// is already in use, so it should be called once per prefix.
+func Publish(prefix string, server *corebgp.Server) {
+	expvar.Publish(prefix+"_sessions", expvar.Func(func() interface{} {
+		byState := server.Metrics().PeersByState
+		m := make(map[string]int, len(states))
+		for _, s := range states {
+			m[s.String()] = byState[s]
+		}
+		return m
+	}))
+	expvar.Publish(prefix+"_messages_received", expvar.Func(
+		func() interface{} {
+			return messageCounts(server.Metrics().MessagesReceived)
+		}))
+	expvar.Publish(prefix+"_messages_sent", expvar.Func(func() interface{} {
+		return messageCounts(server.Metrics().MessagesSent)
+	}))
+	expvar.Publish(prefix+"_connect_attempts", expvar.Func(
+		func() interface{} {
+			return server.Metrics().ConnectAttempts
+		}))
+	expvar.Publish(prefix+"_decode_errors", expvar.Func(func() interface{} {
+		return server.Metrics().DecodeErrors
+	}))
+	expvar.Publish(prefix+"_memory_bytes", expvar.Func(func() interface{} {
+		m := server.Metrics().Memory
+		return map[string]int64{
+			"inbound_queue":   m.InboundQueue,
+			"outbound_queue":  m.OutboundQueue,
+			"message_history": m.MessageHistory,
+		}
+	}))
+}
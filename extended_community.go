@@ -0,0 +1,198 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ExtendedCommunity is a BGP Extended Community (RFC4360).
+type ExtendedCommunity [8]byte
+
+// Extended community type high-order octet values
+// https://www.iana.org/assignments/bgp-extended-communities/bgp-extended-communities.xhtml
+const (
+	ExtCommunityTypeTwoOctetAS               uint8 = 0x00
+	ExtCommunityTypeIPv4Address              uint8 = 0x01
+	ExtCommunityTypeFourOctetAS              uint8 = 0x02
+	ExtCommunityTypeOpaque                   uint8 = 0x03
+	ExtCommunityTypeNonTransitiveTwoOctetAS  uint8 = 0x40
+	ExtCommunityTypeNonTransitiveIPv4Address uint8 = 0x41
+	ExtCommunityTypeNonTransitiveFourOctetAS uint8 = 0x42
+	ExtCommunityTypeNonTransitiveOpaque      uint8 = 0x43
+)
+
+// Extended community sub-type values
+const (
+	ExtCommunitySubtypeLinkBandwidth uint8 = 0x04
+	ExtCommunitySubtypeColor         uint8 = 0x0b
+)
+
+const (
+	extCommunityNonTransitiveBit = 0x40
+)
+
+// Type returns the type high-order octet of the community.
+func (e ExtendedCommunity) Type() uint8 {
+	return e[0]
+}
+
+// Subtype returns the sub-type octet of the community.
+func (e ExtendedCommunity) Subtype() uint8 {
+	return e[1]
+}
+
+// Transitive returns true if the community is transitive across ASes.
+func (e ExtendedCommunity) Transitive() bool {
+	return e[0]&extCommunityNonTransitiveBit == 0
+}
+
+// String returns the community as type:subtype:value in hex.
+func (e ExtendedCommunity) String() string {
+	return fmt.Sprintf("0x%02x:0x%02x:0x%x", e[0], e[1], e[2:])
+}
+
+// DecodeExtendedCommunities decodes the value of an EXTENDED_COMMUNITIES path
+// attribute, which must contain at least one community.
+// https://tools.ietf.org/html/rfc7606#section-7.14
+func DecodeExtendedCommunities(b []byte) ([]ExtendedCommunity, error) {
+	if len(b) == 0 || len(b)%8 != 0 {
+		return nil, fmt.Errorf("invalid extended communities length: %d",
+			len(b))
+	}
+	communities := make([]ExtendedCommunity, len(b)/8)
+	for i := range communities {
+		copy(communities[i][:], b[i*8:])
+	}
+	return communities, nil
+}
+
+// EncodeExtendedCommunities encodes communities as the value of an
+// EXTENDED_COMMUNITIES path attribute.
+func EncodeExtendedCommunities(communities ...ExtendedCommunity) []byte {
+	b := make([]byte, 0, len(communities)*8)
+	for _, c := range communities {
+		b = append(b, c[:]...)
+	}
+	return b
+}
+
+// NewLinkBandwidthExtCommunity returns a Link Bandwidth extended community
+// (draft-ietf-idr-link-bandwidth). The bandwidth is encoded as an IEEE
+// floating point number in units of bytes per second, not bits per second.
+// The draft specifies a non-transitive community, however some
+// implementations send or expect the transitive type.
+func NewLinkBandwidthExtCommunity(asn uint16, bytesPerSecond float32,
+	transitive bool) ExtendedCommunity {
+	var e ExtendedCommunity
+	e[0] = ExtCommunityTypeNonTransitiveTwoOctetAS
+	if transitive {
+		e[0] = ExtCommunityTypeTwoOctetAS
+	}
+	e[1] = ExtCommunitySubtypeLinkBandwidth
+	binary.BigEndian.PutUint16(e[2:], asn)
+	binary.BigEndian.PutUint32(e[4:], math.Float32bits(bytesPerSecond))
+	return e
+}
+
+// DecodeLinkBandwidthExtCommunity decodes a Link Bandwidth extended
+// community of either the transitive or non-transitive type, returning the
+// AS number and the bandwidth in bytes per second.
+func DecodeLinkBandwidthExtCommunity(e ExtendedCommunity) (uint16, float32,
+	error) {
+	if (e[0] != ExtCommunityTypeTwoOctetAS &&
+		e[0] != ExtCommunityTypeNonTransitiveTwoOctetAS) ||
+		e[1] != ExtCommunitySubtypeLinkBandwidth {
+		return 0, 0, errors.New("not a link bandwidth extended community")
+	}
+	bw := math.Float32frombits(binary.BigEndian.Uint32(e[4:]))
+	if math.IsNaN(float64(bw)) || math.IsInf(float64(bw), 0) || bw < 0 {
+		return 0, 0, fmt.Errorf("invalid link bandwidth: %v", bw)
+	}
+	return binary.BigEndian.Uint16(e[2:]), bw, nil
+}
+
+// LinkBandwidthFromBitsPerSecond converts a bandwidth in bits per second to
+// the bytes per second unit used by the Link Bandwidth extended community.
+func LinkBandwidthFromBitsPerSecond(bitsPerSecond float64) float32 {
+	return float32(bitsPerSecond / 8)
+}
+
+// LinkBandwidthFromCommunities returns the bandwidth in bytes per second of
+// the first Link Bandwidth extended community in communities. ok is false if
+// none is present.
+func LinkBandwidthFromCommunities(communities []ExtendedCommunity) (
+	bytesPerSecond float32, ok bool) {
+	for _, c := range communities {
+		_, bw, err := DecodeLinkBandwidthExtCommunity(c)
+		if err == nil {
+			return bw, true
+		}
+	}
+	return 0, false
+}
+
+// AggregateLinkBandwidth returns the sum of bandwidths, e.g. for advertising
+// the aggregate bandwidth of a multipath set.
+func AggregateLinkBandwidth(bandwidths ...float32) float32 {
+	var sum float64
+	for _, bw := range bandwidths {
+		sum += float64(bw)
+	}
+	if sum > math.MaxFloat32 {
+		return math.MaxFloat32
+	}
+	return float32(sum)
+}
+
+// LinkBandwidthWeights returns weights for weighted ECMP proportional to
+// bandwidths. The weights sum to 1. If the sum of bandwidths is zero the
+// weights are equal.
+func LinkBandwidthWeights(bandwidths ...float32) []float64 {
+	weights := make([]float64, len(bandwidths))
+	var sum float64
+	for _, bw := range bandwidths {
+		sum += float64(bw)
+	}
+	for i, bw := range bandwidths {
+		if sum == 0 {
+			weights[i] = 1 / float64(len(bandwidths))
+		} else {
+			weights[i] = float64(bw) / sum
+		}
+	}
+	return weights
+}
+
+// ColorOnly is the Color-Only (CO) bits of a Color extended community.
+// https://tools.ietf.org/html/rfc9256#section-8.8.1
+type ColorOnly uint8
+
+// ColorOnly values
+const (
+	ColorOnlyNone         ColorOnly = 0
+	ColorOnlyNullEndpoint ColorOnly = 1
+	ColorOnlyAnyEndpoint  ColorOnly = 2
+	colorOnlyReserved     ColorOnly = 3
+)
+
+// NewColorExtCommunity returns a Color extended community (RFC9012) with the
+// Color-Only bits set to co.
+func NewColorExtCommunity(color uint32, co ColorOnly) ExtendedCommunity {
+	var e ExtendedCommunity
+	e[0] = ExtCommunityTypeOpaque
+	e[1] = ExtCommunitySubtypeColor
+	e[2] = uint8(co&colorOnlyReserved) << 6
+	binary.BigEndian.PutUint32(e[4:], color)
+	return e
+}
+
+// DecodeColorExtCommunity decodes a Color extended community, returning the
+// color and Color-Only bits.
+func DecodeColorExtCommunity(e ExtendedCommunity) (uint32, ColorOnly, error) {
+	if e[0] != ExtCommunityTypeOpaque || e[1] != ExtCommunitySubtypeColor {
+		return 0, 0, errors.New("not a color extended community")
+	}
+	return binary.BigEndian.Uint32(e[4:]), ColorOnly(e[2] >> 6), nil
+}
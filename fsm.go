@@ -9,17 +9,54 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type fsm struct {
 	peer *peer
+	// the index of the fsm in peer.fsms, out or in
+	index int
+	// true if the fsm was created for a connection accepted from the peer
+	inbound bool
+	// the state the fsm is in
+	state FSMState
+	// the timeline of the establishment attempt in progress, if any
+	attempt *EstablishmentTimeline
 
 	// the bgp ID received in the latest open message
 	remoteID uint32
+	// the AS of the peer received in the latest open message
+	remoteAS uint32
+	// the capabilities received in the latest open message
+	remoteCapabilities []*Capability
+	// the latest open messages received and sent
+	receivedOpen *OpenMessage
+	sentOpen     *OpenMessage
+	// true if dynamic capability was negotiated in the latest open messages
+	dynamicCapability bool
+	// the capability codes the peer may change via CAPABILITY messages, as
+	// listed in its Dynamic Capability capability
+	dynamicCapabilityCodes []uint8
+	// true if four-octet AS was negotiated in the latest open messages
+	fourOctetAS bool
+	// true if extended messages were negotiated in the latest open messages
+	extendedMessage bool
+	// the Codec of the session negotiated in the latest open messages
+	codec *Codec
+	// the maximum length of messages other than OPEN and KEEPALIVE accepted
+	// by the reader, accessed atomically
+	maxMessageLength int32
+	// 1 if the reader accepts CAPABILITY messages, i.e. dynamic capability
+	// was negotiated, accessed atomically
+	capabilityMessages int32
+	// the Cease subcode sent to the peer when the fsm is stopped
+	ceaseSubcode uint8
 
 	// conn-related fields
-	conn         net.Conn
+	conn net.Conn
+	// true if conn was dialed to the DualStack alternate address
+	dialedAlt    bool
 	dialResultCh chan *dialResult
 	cancelDialFn context.CancelFunc
 
@@ -37,64 +74,89 @@ type fsm struct {
 
 	// timers
 	connectRetryTimer *time.Timer
-	holdTimer         *time.Timer
-	holdTime          time.Duration
+	// the expiry of the connectRetryTimer and idleHoldTimer, for reporting
+	// the next dial via DialStatus
+	connectRetryDeadline time.Time
+	idleHoldDeadline     time.Time
+	holdTimer            *time.Timer
+	holdTime             time.Duration
+	// the capabilities sent in the latest open message
+	sentCapabilities []*Capability
+	// true if the hold timer was extended since it was last reset
+	holdTimerExtended bool
 	keepAliveTimer    *time.Timer
 	keepAliveInterval time.Duration
 	idleHoldTimer     *time.Timer
+
+	// the time the latest open message was sent, and the time from then
+	// until the peer's keepalive was received in openConfirm
+	openSentAt time.Time
+	openRTT    time.Duration
 }
 
-func newFSM(peer *peer, conn net.Conn) *fsm {
+func newFSM(peer *peer, index int, conn net.Conn) *fsm {
 	f := &fsm{
 		peer:    peer,
+		index:   index,
+		inbound: conn != nil,
 		conn:    conn,
 		closeCh: make(chan struct{}),
 		doneCh:  make(chan struct{}),
 		// we do not hold down the first time entering idle state
 		idleHoldTimer: time.NewTimer(0),
 	}
+	if conn != nil {
+		f.startAttempt()
+		f.attempt.Connected = f.attempt.Started
+	}
 	return f
 }
 
-type fsmState uint8
+// FSMState is the state of a peer's FSM.
+type FSMState uint8
 
-func (f fsmState) String() string {
+// String returns the name of the state.
+func (f FSMState) String() string {
 	switch f {
-	case disabledState:
+	case DisabledState:
 		return "disabled"
-	case idleState:
+	case IdleState:
 		return "idle"
-	case connectState:
+	case ConnectState:
 		return "connect"
-	case activeState:
+	case ActiveState:
 		return "active"
-	case openSentState:
+	case OpenSentState:
 		return "openSent"
-	case openConfirmState:
+	case OpenConfirmState:
 		return "openConfirm"
-	case establishedState:
+	case EstablishedState:
 		return "established"
 	default:
 		return "unknown"
 	}
 }
 
+// FSMState values. DisabledState indicates that the FSM is not running.
 const (
-	disabledState fsmState = iota
-	idleState
-	connectState
-	activeState
-	openSentState
-	openConfirmState
-	establishedState
+	DisabledState FSMState = iota
+	IdleState
+	ConnectState
+	ActiveState
+	OpenSentState
+	OpenConfirmState
+	EstablishedState
 )
 
 func (f *fsm) cleanup() {
 	if f.cancelDialFn != nil {
-		f.cancelDialFn()
-		<-f.dialResultCh
+		f.abandonDial()
 	}
 	f.cleanupConnAndReader()
+	f.failAttempt(errFSMStopped)
+	if !f.inbound {
+		f.peer.dialScheduled(time.Time{})
+	}
 	for _, t := range []*time.Timer{f.connectRetryTimer, f.holdTimer,
 		f.keepAliveTimer, f.idleHoldTimer} {
 		if t != nil {
@@ -114,9 +176,9 @@ func (f *fsm) run() {
 		// if we start up with a non-nil conn we should enter into the active
 		// state in order to skip connect and send an open message to the remote
 		// peer.
-		t = newStateTransition(disabledState, activeState)
+		t = newStateTransition(DisabledState, ActiveState)
 	} else {
-		t = newStateTransition(disabledState, idleState)
+		t = newStateTransition(DisabledState, IdleState)
 	}
 
 	for {
@@ -129,48 +191,60 @@ func (f *fsm) run() {
 		case f.peer.getFSMTransitionCh(f) <- t:
 			select {
 			case <-f.closeCh:
-				t = newStateTransition(t.from, disabledState)
+				t = newStateTransition(t.from, DisabledState)
 			case t = <-f.peer.getFSMTransitionCh(f):
 			}
 		case <-f.closeCh:
-			t = newStateTransition(t.from, disabledState)
+			t = newStateTransition(t.from, DisabledState)
 		}
 
-		if t.to != toBefore && t.to == disabledState && f.conn != nil &&
-			t.from > activeState {
+		if t.to != toBefore && t.to == DisabledState && f.conn != nil &&
+			t.from > ActiveState {
 			// we were disabled while transitioning to a target state with an
 			// active connection
-			f.sendNotification(newNotification(NotifCodeCease, 0, nil))
+			f.sendNotification(f.ceaseNotification())
 		}
 
 		var (
-			desired fsmState
+			desired FSMState
 			err     error
 		)
+		f.state = t.to
 		switch t.to {
-		case disabledState:
+		case DisabledState:
 			return
-		case idleState:
+		case IdleState:
 			desired = f.idle()
-		case connectState:
+		case ConnectState:
 			desired = f.connect()
-		case activeState:
+		case ActiveState:
 			desired = f.active()
-		case openSentState:
+		case OpenSentState:
 			desired, err = f.openSent()
-		case openConfirmState:
+		case OpenConfirmState:
 			desired, err = f.openConfirm()
-		case establishedState:
-			desired, err = f.established()
+		case EstablishedState:
+			f.establishAttempt(t.session)
+			desired, err = f.established(t.session)
 		}
 
 		if err != nil {
+			f.failAttempt(err)
+			if t.to == OpenSentState && !f.inbound &&
+				f.peer.options.dualStackAddress != nil {
+				// prefer the other address if the session failed on this one
+				f.peer.preferAlt = !f.dialedAlt
+			}
 			// if an error occurred we signal it to the peer
 			select {
 			case <-f.closeCh:
-				t = newStateTransition(t.to, disabledState)
+				t = newStateTransition(t.to, DisabledState)
 			case f.peer.getFSMErrorCh(f) <- err:
 				t = newStateTransition(t.to, desired)
+				if desired == IdleState && !f.inbound {
+					f.peer.timerRescheduled(TimerIdleHold,
+						f.nextIdleDial(), err)
+				}
 			}
 		} else {
 			t = newStateTransition(t.to, desired)
@@ -189,12 +263,20 @@ func (f *fsm) stop() {
 	<-f.doneCh
 }
 
+// ceaseNotification returns the Cease Notification sent to the peer when the
+// fsm is stopped.
+func (f *fsm) ceaseNotification() *Notification {
+	return newNotification(NotifCodeCease, f.ceaseSubcode, nil)
+}
+
 type stateTransition struct {
-	from fsmState
-	to   fsmState
+	from FSMState
+	to   FSMState
+	// session is set by the peer on transitions to the Established state
+	session *SessionInfo
 }
 
-func newStateTransition(from fsmState, to fsmState) stateTransition {
+func newStateTransition(from FSMState, to FSMState) stateTransition {
 	return stateTransition{
 		from: from,
 		to:   to,
@@ -210,33 +292,103 @@ const (
 
 type dialResult struct {
 	conn net.Conn
-	err  error
+	// alt is true if conn is to the DualStack alternate address
+	alt bool
+	err error
 }
 
-const (
-	defaultPort = 179
-)
+// connectRetryTime returns the ConnectRetryTimer value, which is extended to
+// the peer's dial timeout.
+func (f *fsm) connectRetryTime() time.Duration {
+	return f.peer.options.connectRetryTime()
+}
+
+func (f *fsm) startConnectRetryTimer() {
+	d := f.connectRetryTime()
+	f.connectRetryTimer = time.NewTimer(d)
+	f.connectRetryDeadline = time.Now().Add(d)
+}
+
+// nextIdleDial returns the time at which the idle state dials the peer.
+func (f *fsm) nextIdleDial() time.Time {
+	now := time.Now()
+	if f.idleHoldDeadline.Before(now) {
+		return now
+	}
+	return f.idleHoldDeadline
+}
 
 func (f *fsm) dialPeer() {
-	ctx, cancel := context.WithCancel(context.Background())
+	f.peer.dialStarted()
+	f.startAttempt()
+	ctx, cancel := context.WithTimeout(f.peer.ctx,
+		f.peer.options.dialTimeout)
 	dialResultCh := make(chan *dialResult)
 	f.dialResultCh = dialResultCh
 	f.cancelDialFn = cancel
+	o := f.peer.options
+	ips := []net.IP{f.peer.config.IP}
+	if o.dualStackAddress != nil {
+		ips = append(ips, o.dualStackAddress)
+		if f.peer.preferAlt {
+			ips[0], ips[1] = ips[1], ips[0]
+		}
+	}
+	addrs := make([]string, 0, len(ips))
+	dialers := make([]ContextDialer, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(),
+			strconv.Itoa(o.port)))
+		dialers = append(dialers, f.dialer(ip))
+	}
 	go func() {
-		defer close(f.dialResultCh)
-		dialer := &net.Dialer{}
-		conn, err := dialer.DialContext(ctx, "tcp",
-			net.JoinHostPort(f.peer.config.IP.String(),
-				strconv.Itoa(defaultPort)))
-		dialResultCh <- &dialResult{
-			conn: conn,
-			err:  err,
+		defer close(dialResultCh)
+		dr := &dialResult{}
+		if len(addrs) == 1 {
+			dr.conn, dr.err = dialers[0].DialContext(ctx, "tcp", addrs[0])
+		} else {
+			var i int
+			dr.conn, i, dr.err = raceDial(ctx, dialers, addrs,
+				o.dualStackDelay)
+			dr.alt = ips[i].Equal(o.dualStackAddress)
 		}
+		dialResultCh <- dr
 	}()
 }
 
+// dialer returns the ContextDialer for dialing ip.
+func (f *fsm) dialer(ip net.IP) ContextDialer {
+	if f.peer.options.dialer != nil {
+		return f.peer.options.dialer
+	}
+	d := &net.Dialer{
+		Control: f.peer.options.control(true),
+	}
+	localAddress := f.peer.options.localAddress
+	if localAddress != nil && (f.peer.options.dualStackAddress == nil ||
+		(localAddress.To4() == nil) == (ip.To4() == nil)) {
+		d.LocalAddr = &net.TCPAddr{
+			IP: localAddress,
+		}
+	}
+	return d
+}
+
+// abandonDial cancels the dial in progress, if any, without waiting for the
+// dialer to return. A connection established regardless is closed.
+func (f *fsm) abandonDial() {
+	f.cancelDialFn()
+	go func(dialResultCh chan *dialResult) {
+		dr, ok := <-dialResultCh
+		if ok && dr.conn != nil {
+			dr.conn.Close()
+		}
+	}(f.dialResultCh)
+	f.cancelDialFn = nil
+}
+
 // https://tools.ietf.org/html/rfc4271#section-8.2.2
-func (f *fsm) idle() fsmState {
+func (f *fsm) idle() FSMState {
 	/*
 		In this state, BGP FSM refuses all incoming BGP connections for
 		this peer.  No resources are allocated to the peer.  In response
@@ -254,14 +406,16 @@ func (f *fsm) idle() fsmState {
 		The ManualStop event (Event 2) and AutomaticStop (Event 8) event
 		are ignored in the Idle state.
 	*/
+	f.peer.dialScheduled(f.nextIdleDial())
 	select {
 	case <-f.closeCh:
-		return disabledState
+		return DisabledState
 	case <-f.idleHoldTimer.C:
-		f.connectRetryTimer = time.NewTimer(connectRetryTime)
+		f.startConnectRetryTimer()
 		f.dialPeer()
 		f.idleHoldTimer.Reset(f.peer.options.idleHoldTime)
-		return connectState
+		f.idleHoldDeadline = time.Now().Add(f.peer.options.idleHoldTime)
+		return ConnectState
 	}
 }
 
@@ -271,38 +425,76 @@ const (
 	longHoldTime = time.Minute * 4
 )
 
-func (f *fsm) sendOpenAndSetHoldTimer() fsmState {
-	capabilities := f.peer.plugin.GetCapabilities(f.peer.config)
+// hasCapability returns true if caps contains a capability with code.
+func hasCapability(caps []*Capability, code uint8) bool {
+	for _, c := range caps {
+		if c.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fsm) getCapabilities() []*Capability {
+	p, ok := f.peer.plugin.(ConnCapabilitiesPlugin)
+	if !ok {
+		return f.peer.plugin.GetCapabilities(f.peer.config)
+	}
+	return p.GetConnCapabilities(f.peer.config, ConnInfo{
+		Inbound:    f.inbound,
+		LocalAddr:  f.conn.LocalAddr(),
+		RemoteAddr: f.conn.RemoteAddr(),
+	})
+}
+
+func (f *fsm) sendOpenAndSetHoldTimer() FSMState {
+	capabilities := f.getCapabilities()
+	if f.peer.options.dynamicCapability {
+		capabilities = append(capabilities[:len(capabilities):len(capabilities)],
+			NewDynamicCapabilityCap(f.peer.options.dynamicCapabilityCodes...))
+	}
+	capabilities = f.peer.withoutDroppedCapabilities(capabilities)
+	f.sentCapabilities = capabilities
 	o, err := newOpenMessage(f.peer.config.LocalAS, f.peer.options.holdTime,
 		f.peer.id, capabilities)
 	if err != nil {
 		f.conn.Close()
-		return idleState
+		f.failAttempt(fmt.Errorf("error creating open message: %w", err))
+		return IdleState
 	}
-	b, err := o.encode()
+	b, err := o.Encode()
 	if err != nil {
 		f.conn.Close()
-		return idleState
+		f.failAttempt(fmt.Errorf("error encoding open message: %w", err))
+		return IdleState
 	}
 	_, err = f.conn.Write(b)
 	if err != nil {
 		f.conn.Close()
-		return idleState
+		f.failAttempt(fmt.Errorf("error sending open message: %w", err))
+		return IdleState
 	}
-	f.holdTimer = time.NewTimer(longHoldTime)
+	if f.attempt != nil {
+		f.attempt.OpenSent = time.Now()
+	}
+	f.sentOpen = o
+	f.openSentAt = time.Now()
+	f.openRTT = 0
+	f.peer.counters.outgoing(OpenMessageType)
+	f.peer.messages.record(true, b)
+	f.holdTimer = time.NewTimer(f.peer.options.openSentHoldTime)
 	f.startReading()
-	return openSentState
+	return OpenSentState
 }
 
 // https://tools.ietf.org/html/rfc4271#page-54
-func (f *fsm) connect() fsmState {
+func (f *fsm) connect() FSMState {
 	for {
 		select {
 		case <-f.closeCh:
-			f.cancelDialFn()
-			<-f.dialResultCh
+			f.abandonDial()
 			f.connectRetryTimer.Stop()
-			return disabledState
+			return DisabledState
 		case dr := <-f.dialResultCh:
 			if dr.err != nil {
 				/*
@@ -320,7 +512,10 @@ func (f *fsm) connect() fsmState {
 				*/
 				f.connectRetryTimer.Stop()
 				f.cancelDialFn()
-				return idleState
+				f.peer.dialFailed(dr.err, dialFailureCause(dr.err),
+					f.nextIdleDial())
+				f.failAttempt(dr.err)
+				return IdleState
 			}
 
 			/*
@@ -341,6 +536,8 @@ func (f *fsm) connect() fsmState {
 				A HoldTimer value of 4 minutes is suggested.
 			*/
 			f.conn = dr.conn
+			f.dialedAlt = dr.alt
+			f.connected()
 			f.connectRetryTimer.Stop()
 			return f.sendOpenAndSetHoldTimer()
 		case <-f.connectRetryTimer.C:
@@ -360,20 +557,26 @@ func (f *fsm) connect() fsmState {
 			f.cancelDialFn()
 			dr := <-f.dialResultCh
 			if dr.err != nil {
-				f.connectRetryTimer = time.NewTimer(connectRetryTime)
+				err := fmt.Errorf("dial timed out after %s",
+					f.connectRetryTime())
+				f.peer.dialFailed(err, DialFailureTimeout, time.Now())
+				f.failAttempt(err)
+				f.startConnectRetryTimer()
 				f.dialPeer()
 				continue
 			}
 			// if dr.err == nil we ended up with an established connection
 			// during the race between connectRetryTimer and the dialer
 			f.conn = dr.conn
+			f.dialedAlt = dr.alt
+			f.connected()
 			return f.sendOpenAndSetHoldTimer()
 		}
 	}
 }
 
 // https://tools.ietf.org/html/rfc4271#page-59
-func (f *fsm) active() fsmState {
+func (f *fsm) active() FSMState {
 	// if conn is non-nil we were started up with a valid connection as part
 	// of handling an incoming connection. If conn is nil we are an "outgoing"
 	// connection FSM
@@ -392,20 +595,17 @@ func (f *fsm) active() fsmState {
 			  by a remote BGP peer, and
 			- changes its state to Connect.
 	*/
+	f.peer.dialScheduled(f.connectRetryDeadline)
 	select {
 	case <-f.connectRetryTimer.C:
-		f.connectRetryTimer = time.NewTimer(connectRetryTime)
+		f.startConnectRetryTimer()
 		f.dialPeer()
-		return connectState
+		return ConnectState
 	case <-f.closeCh:
-		return disabledState
+		return DisabledState
 	}
 }
 
-const (
-	maxMessageLength = 4096
-)
-
 func (f *fsm) startReading() {
 	f.closeReaderCh = make(chan struct{})
 	f.closeReaderOnce = sync.Once{}
@@ -434,10 +634,45 @@ func (f *fsm) cleanupConnAndReader() {
 func (f *fsm) read() {
 	defer close(f.readerDoneCh)
 
+	// messages are framed up to the largest length possibly negotiated, and
+	// checked against the negotiated maximum below
+	var r io.Reader = f.conn
+	if b := f.peer.options.readBatcher; b != nil {
+		if br, release, ok := b.reader(f.conn, f.closeReaderCh); ok {
+			defer release()
+			r = br
+			atomic.AddInt64(&f.peer.memory.readBuffer, int64(b.bufferSize))
+			defer atomic.AddInt64(&f.peer.memory.readBuffer,
+				-int64(b.bufferSize))
+		}
+	}
+	reader := NewMessageReader(r)
+	reader.SetMaxMessageLength(ExtendedMaxMessageLength)
+	atomic.StoreInt32(&f.maxMessageLength, MaxMessageLength)
+	atomic.StoreInt32(&f.capabilityMessages, 0)
+	if f.peer.options.markerValidation == MarkerValidationPermissive {
+		var logged bool
+		reader.SetMarkerValidation(MarkerValidationPermissive,
+			func(marker []byte) {
+				atomic.AddUint64(&f.peer.counters.invalidMarkers, 1)
+				if !logged {
+					logged = true
+					logf("[%s] accepting message with invalid marker %x",
+						f.peer.config.IP, marker)
+				}
+			})
+	}
+	var limiter *rateLimiter
+	if f.peer.options.inboundRateLimit != nil {
+		limiter = newRateLimiter(*f.peer.options.inboundRateLimit)
+	}
 	for {
-		header := make([]byte, headerLength)
-		_, err := io.ReadFull(f.conn, header)
+		msgType, body, err := reader.ReadMessage()
 		if err != nil {
+			var nerr *notificationError
+			if errors.As(err, &nerr) {
+				f.peer.counters.decodeError()
+			}
 			select {
 			case <-f.closeReaderCh:
 				return
@@ -446,47 +681,33 @@ func (f *fsm) read() {
 			}
 		}
 
-		for i := 0; i < 16; i++ {
-			if header[i] != 0xFF {
-				n := newNotification(NotifCodeMessageHeaderErr,
-					NotifSubcodeConnNotSync, nil)
-				select {
-				case <-f.closeReaderCh:
-					return
-				case f.readerErrCh <- newNotificationError(n, true):
-					return
-				}
-			}
-		}
-
-		// length is inclusive of header
-		bodyLen := int(binary.BigEndian.Uint16(header[16:18])) - headerLength
-		if bodyLen < 0 || bodyLen+headerLength > maxMessageLength {
-			n := newNotification(NotifCodeMessageHeaderErr,
-				NotifSubcodeBadLength, nil)
+		if err := f.checkMessageLength(msgType, body); err != nil {
 			select {
 			case <-f.closeReaderCh:
 				return
-			case f.readerErrCh <- newNotificationError(n, true):
+			case f.readerErrCh <- err:
 				return
 			}
 		}
-
-		body := make([]byte, bodyLen)
-		if bodyLen > 0 {
-			_, err = io.ReadFull(f.conn, body)
-			if err != nil {
-				select {
-				case <-f.closeReaderCh:
-					return
-				case f.readerErrCh <- err:
-					return
-				}
-			}
+		f.peer.counters.incoming(msgType)
+		if f.peer.messages != nil {
+			f.peer.messages.record(false, prependHeader(body, msgType))
+		}
+		if limiter != nil && msgType == UpdateMessageType &&
+			!f.rateLimit(limiter, HeaderLength+len(body)) {
+			return
+		}
+		var m message
+		if msgType == CapabilityMessageType &&
+			atomic.LoadInt32(&f.capabilityMessages) == 0 {
+			// a CAPABILITY message is of an unsupported type unless dynamic
+			// capability was negotiated, its body is not decoded
+			err = newBadMessageTypeErr(msgType)
+		} else {
+			m, err = messageFromBytes(body, msgType)
 		}
-
-		m, err := messageFromBytes(body, header[18])
 		if err != nil {
+			f.peer.counters.decodeError()
 			select {
 			case <-f.closeReaderCh:
 				return
@@ -502,12 +723,39 @@ func (f *fsm) read() {
 	}
 }
 
+// checkMessageLength returns an error wrapping a Bad Message Length
+// NOTIFICATION if a message exceeds the maximum length negotiated with the
+// peer.
+func (f *fsm) checkMessageLength(msgType uint8, body []byte) error {
+	length := HeaderLength + len(body)
+	max := MaxMessageLength
+	if msgType != OpenMessageType && msgType != KeepAliveMessageType {
+		max = int(atomic.LoadInt32(&f.maxMessageLength))
+	}
+	if length <= max {
+		return nil
+	}
+	atomic.AddUint64(&f.peer.counters.oversizedMessages, 1)
+	f.peer.counters.decodeError()
+	logf("[%s] message type %d length %d exceeds maximum of %d",
+		f.peer.config.IP, msgType, length, max)
+	badLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(badLen, uint16(length))
+	n := newNotification(NotifCodeMessageHeaderErr, NotifSubcodeBadLength,
+		badLen)
+	return newNotificationError(n, true)
+}
+
 func (f *fsm) sendNotification(n *Notification) error {
 	b, err := n.encode()
 	if err != nil {
 		return err
 	}
 	_, err = f.conn.Write(b)
+	if err == nil {
+		f.peer.counters.outgoing(NotificationMessageType)
+		f.peer.messages.record(true, b)
+	}
 	return err
 }
 
@@ -518,21 +766,52 @@ func (f *fsm) sendKeepAlive() error {
 		return err
 	}
 	_, err = f.conn.Write(b)
+	if err == nil {
+		f.peer.counters.outgoing(KeepAliveMessageType)
+		f.peer.messages.record(true, b)
+	}
 	return err
 }
 
+// newStoppedTimer returns a timer that never fires unless it is reset.
+func newStoppedTimer() *time.Timer {
+	t := time.NewTimer(time.Hour)
+	t.Stop()
+	return t
+}
+
 func (f *fsm) drainAndResetHoldTimer() {
 	if !f.holdTimer.Stop() {
 		<-f.holdTimer.C
 	}
 	f.holdTimer.Reset(f.holdTime)
+	f.holdTimerExtended = false
+}
+
+// extendHoldTimer is called upon hold timer expiry, returning true if the
+// hold timer was extended via the HoldTimerGrace PeerOption. The hold timer
+// is extended at most once until it is reset by a message from the peer.
+func (f *fsm) extendHoldTimer() bool {
+	fn := f.peer.options.holdTimerGrace
+	if fn == nil || f.holdTimerExtended {
+		return false
+	}
+	d := fn(f.peer.config)
+	if d <= 0 {
+		return false
+	}
+	f.holdTimerExtended = true
+	f.holdTimer.Reset(d)
+	atomic.AddUint64(&f.peer.counters.holdTimerExtensions, 1)
+	logf("[%s] hold timer expired, extended by %s", f.peer.config.IP, d)
+	return true
 }
 
 func (f *fsm) drainAndResetKeepAliveTimer() {
 	if !f.keepAliveTimer.Stop() {
 		<-f.keepAliveTimer.C
 	}
-	f.keepAliveTimer.Reset(f.keepAliveInterval)
+	f.keepAliveTimer.Reset(f.keepAliveDelay())
 }
 
 // handleNotificationInErr checks if the error unwraps to a notificationError.
@@ -549,13 +828,13 @@ func (f *fsm) handleNotificationInErr(err error) bool {
 }
 
 // https://tools.ietf.org/html/rfc4271#page-63
-func (f *fsm) openSent() (fsmState, error) {
-	openSent := func() (fsmState, error) {
+func (f *fsm) openSent() (FSMState, error) {
+	openSent := func() (FSMState, error) {
 		select {
 		case <-f.closeCh:
-			n := newNotification(NotifCodeCease, 0, nil)
+			n := f.ceaseNotification()
 			f.sendNotification(n)
-			return disabledState, newNotificationError(n, true)
+			return DisabledState, newNotificationError(n, true)
 		case <-f.holdTimer.C:
 			/*
 				https://tools.ietf.org/html/rfc4271#page-64
@@ -573,13 +852,13 @@ func (f *fsm) openSent() (fsmState, error) {
 			*/
 			n := newNotification(NotifCodeHoldTimerExpired, 0, nil)
 			f.sendNotification(n)
-			return idleState, newNotificationError(n, true)
+			return IdleState, newNotificationError(n, true)
 		case err := <-f.readerErrCh:
 			f.handleNotificationInErr(err)
 
 			var nerr *notificationError
 			if errors.As(err, &nerr) {
-				return idleState, fmt.Errorf("reader error: %w", nerr)
+				return IdleState, fmt.Errorf("reader error: %w", nerr)
 			}
 			// if it's not a notificationError, it's connection-related
 
@@ -595,13 +874,13 @@ func (f *fsm) openSent() (fsmState, error) {
 				   - changes its state to Active.
 
 			*/
-			f.connectRetryTimer = time.NewTimer(connectRetryTime)
-			return activeState, fmt.Errorf("reader error: %w", err)
+			f.startConnectRetryTimer()
+			return ActiveState, fmt.Errorf("reader error: %w", err)
 		case m := <-f.readerMsgCh:
 			switch m := m.(type) {
 			case *Notification:
-				return idleState, newNotificationError(m, false)
-			case *openMessage:
+				return IdleState, newNotificationError(m, false)
+			case *OpenMessage:
 				/*
 					https://tools.ietf.org/html/rfc4271#page-65
 					When an OPEN message is received, all fields are checked for
@@ -616,26 +895,83 @@ func (f *fsm) openSent() (fsmState, error) {
 						  Section 4.2),
 						- changes its state to OpenConfirm.
 				*/
-				err := m.validate(f.peer.id, f.peer.config.LocalAS,
-					f.peer.config.RemoteAS)
+				if f.attempt != nil {
+					f.attempt.OpenReceived = time.Now()
+				}
+				f.peer.events.publish(&OpenReceivedEvent{
+					eventBase: newEventBase(f.peer),
+					Inbound:   f.inbound,
+					Open:      m,
+				})
+				err := f.peer.openValidationPolicy().validate(m,
+					f.peer.config, f.peer.id, f.peer.acceptsRemoteAS)
+				if err != nil {
+					f.handleNotificationInErr(err)
+					return IdleState, fmt.Errorf("error validating open message: %w", err)
+				}
+				skipped, err := m.checkUnknownOptionalParams(
+					f.peer.options.optionalParamPolicy)
+				if err != nil {
+					f.handleNotificationInErr(err)
+					return IdleState, fmt.Errorf("error validating open message: %w", err)
+				}
+				for _, p := range skipped {
+					logf("[%s] skipping open message optional parameter type %d",
+						f.peer.config.IP, p.ParamType)
+				}
+				err = m.checkRequiredCapabilities(
+					f.peer.options.requiredCapabilities)
 				if err != nil {
 					f.handleNotificationInErr(err)
-					return idleState, fmt.Errorf("error validating open message: %w", err)
+					return IdleState, fmt.Errorf("error validating open message: %w", err)
+				}
+				f.remoteID = m.BGPID
+				f.remoteAS = m.peerAS()
+				f.remoteCapabilities = m.Capabilities()
+				f.receivedOpen = m
+				f.dynamicCapability = false
+				f.dynamicCapabilityCodes = nil
+				f.fourOctetAS = false
+				f.extendedMessage = false
+				for _, c := range m.Capabilities() {
+					switch c.Code {
+					case CapCodeDynamicCapability:
+						f.dynamicCapability = f.peer.options.dynamicCapability
+						codes, _ := DecodeDynamicCapabilityCap(c)
+						f.dynamicCapabilityCodes = append(
+							f.dynamicCapabilityCodes, codes...)
+					case CapCodeFourOctetAS:
+						// we always advertise four-octet AS support
+						f.fourOctetAS = true
+					case CapCodeExtendedMessage:
+						f.extendedMessage = hasCapability(f.sentCapabilities,
+							CapCodeExtendedMessage)
+					}
+				}
+				f.codec = newCodec(f.fourOctetAS, f.extendedMessage,
+					f.sentCapabilities, f.remoteCapabilities)
+				// the peer may send extended messages once it receives our
+				// KEEPALIVE
+				if f.extendedMessage {
+					atomic.StoreInt32(&f.maxMessageLength,
+						int32(f.peer.options.maxMessageLength))
+				}
+				if f.dynamicCapability {
+					atomic.StoreInt32(&f.capabilityMessages, 1)
 				}
-				f.remoteID = m.bgpID
 
-				n := f.peer.plugin.OnOpenMessage(f.peer.config, m.getCapabilities())
+				n := f.peer.plugin.OnOpenMessage(f.peer.config, m.Capabilities())
 				if n != nil {
 					f.sendNotification(n)
-					return idleState, newNotificationError(n, true)
+					return IdleState, newNotificationError(n, true)
 				}
 
 				err = f.sendKeepAlive()
 				if err != nil {
-					return idleState, fmt.Errorf("error sending keepAlive: %w", err)
+					return IdleState, fmt.Errorf("error sending keepAlive: %w", err)
 				}
 
-				f.holdTime = time.Duration(m.holdTime) * time.Second
+				f.holdTime = time.Duration(m.HoldTime) * time.Second
 				if f.peer.options.holdTime < f.holdTime {
 					f.holdTime = f.peer.options.holdTime
 				}
@@ -644,11 +980,22 @@ func (f *fsm) openSent() (fsmState, error) {
 					// A reasonable maximum time between KEEPALIVE messages would be one
 					// third of the Hold Time interval.
 					f.keepAliveInterval = f.holdTime / 3
-					f.keepAliveTimer = time.NewTimer(f.keepAliveInterval)
+					f.keepAliveTimer = time.NewTimer(f.keepAliveDelay())
 					f.drainAndResetHoldTimer()
+				} else {
+					/*
+						https://tools.ietf.org/html/rfc4271#page-67
+						If the negotiated hold time value is zero, then the HoldTimer and
+						KeepaliveTimer are not started.
+					*/
+					if !f.holdTimer.Stop() {
+						<-f.holdTimer.C
+					}
+					f.keepAliveInterval = 0
+					f.keepAliveTimer = newStoppedTimer()
 				}
 
-				return openConfirmState, nil
+				return OpenConfirmState, nil
 			default:
 				/*
 					https://tools.ietf.org/html/rfc4271#page-66
@@ -677,13 +1024,13 @@ func (f *fsm) openSent() (fsmState, error) {
 					NotifSubcodeUnexpectedMessageOpenSent,
 					[]byte{m.messageType()})
 				f.sendNotification(n)
-				return idleState, newNotificationError(n, true)
+				return IdleState, newNotificationError(n, true)
 			}
 		}
 	}
 
 	to, err := openSent()
-	if to != openConfirmState {
+	if to != OpenConfirmState {
 		f.cleanupConnAndReader()
 		f.holdTimer.Stop()
 	}
@@ -691,30 +1038,33 @@ func (f *fsm) openSent() (fsmState, error) {
 }
 
 // https://tools.ietf.org/html/rfc4271#page-67
-func (f *fsm) openConfirm() (fsmState, error) {
-	openConfirm := func() (fsmState, error) {
+func (f *fsm) openConfirm() (FSMState, error) {
+	openConfirm := func() (FSMState, error) {
 		for {
 			select {
 			case <-f.closeCh:
-				n := newNotification(NotifCodeCease, 0, nil)
+				n := f.ceaseNotification()
 				f.sendNotification(n)
-				return disabledState, newNotificationError(n, true)
+				return DisabledState, newNotificationError(n, true)
 			case <-f.holdTimer.C:
+				if f.extendHoldTimer() {
+					continue
+				}
 				n := newNotification(NotifCodeHoldTimerExpired, 0, nil)
 				f.sendNotification(n)
-				return idleState, newNotificationError(n, true)
+				return IdleState, newNotificationError(n, true)
 			case <-f.keepAliveTimer.C:
 				err := f.sendKeepAlive()
 				if err != nil {
-					return idleState, fmt.Errorf("error sending keepAlive: %w", err)
+					return IdleState, fmt.Errorf("error sending keepAlive: %w", err)
 				}
-				f.keepAliveTimer.Reset(f.keepAliveInterval)
+				f.keepAliveTimer.Reset(f.keepAliveDelay())
 				continue
 			case err := <-f.readerErrCh:
 				// In OpenConfirm handling of a TCP connection fails event or
 				// message decoding error both result in transitioning to Idle.
 				f.handleNotificationInErr(err)
-				return idleState, fmt.Errorf("reader error: %w", err)
+				return IdleState, fmt.Errorf("reader error: %w", err)
 			case m := <-f.readerMsgCh:
 				switch m := m.(type) {
 				case *keepAliveMessage:
@@ -726,10 +1076,13 @@ func (f *fsm) openConfirm() (fsmState, error) {
 							- restarts the HoldTimer and
 							- changes its state to Established.
 					*/
-					f.drainAndResetHoldTimer()
-					return establishedState, nil
+					if f.holdTime != 0 {
+						f.drainAndResetHoldTimer()
+					}
+					f.openRTT = time.Since(f.openSentAt)
+					return EstablishedState, nil
 				case *Notification:
-					return idleState, newNotificationError(m, false)
+					return IdleState, newNotificationError(m, false)
 				default:
 					/*
 						https://tools.ietf.org/html/rfc4271#page-70
@@ -758,14 +1111,14 @@ func (f *fsm) openConfirm() (fsmState, error) {
 						NotifSubcodeUnexpectedMessageOpenConfirm,
 						[]byte{m.messageType()})
 					f.sendNotification(n)
-					return idleState, newNotificationError(n, true)
+					return IdleState, newNotificationError(n, true)
 				}
 			}
 		}
 	}
 
 	to, err := openConfirm()
-	if to != establishedState {
+	if to != EstablishedState {
 		f.cleanupConnAndReader()
 		f.holdTimer.Stop()
 		f.keepAliveTimer.Stop()
@@ -774,12 +1127,72 @@ func (f *fsm) openConfirm() (fsmState, error) {
 }
 
 type updateMessageWriter struct {
-	conn           net.Conn
-	resetKATimerCh chan struct{}
-	closeCh        chan struct{}
+	// lastWrite is the time of the latest message written in unix
+	// nanoseconds, maintained if suppressKeepAlives is true. It is first in
+	// the struct to ensure 64-bit alignment for atomic access.
+	lastWrite          int64
+	suppressKeepAlives bool
+	writer             *MessageWriter
+	resetKATimerCh     chan struct{}
+	closeCh            chan struct{}
+	dynamicCapability  bool
+	counters           *peerCounters
+	// policy is applied to updates before they are written, if non-nil
+	policy func([]byte) ([]byte, error)
+	// transform is applied to updates before they are written, if non-nil
+	transform func([]byte) ([]byte, error)
+	// lintCodec is the Codec updates are checked with, if non-nil
+	lintCodec *Codec
+	// queue is the queue of the peer's WriteScheduler, if any
+	queue *writeQueue
+	// dedup suppresses repeated updates if DeduplicateUpdates was set
+	dedup *updateDedup
+}
+
+// wrote records a written message if suppressKeepAlives is true.
+func (u *updateMessageWriter) wrote() {
+	if u.suppressKeepAlives {
+		atomic.StoreInt64(&u.lastWrite, time.Now().UnixNano())
+	}
 }
 
 func (u *updateMessageWriter) WriteUpdate(b []byte) error {
+	if u.policy != nil {
+		var err error
+		b, err = u.policy(b)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			// dropped by policy
+			return nil
+		}
+	}
+	if u.transform != nil {
+		var err error
+		b, err = u.transform(b)
+		if err != nil {
+			return err
+		}
+	}
+	if u.lintCodec != nil {
+		if err := LintUpdate(b, u.lintCodec); err != nil {
+			return fmt.Errorf("invalid update: %w", err)
+		}
+	}
+	if u.dedup != nil && u.dedup.duplicate(b) {
+		u.counters.updateDeduplicated()
+		return nil
+	}
+	if u.queue != nil {
+		return u.queue.enqueue(b)
+	}
+	return u.writeUpdate(b)
+}
+
+// writeUpdate writes b, an UPDATE message body the policy, transform and lint
+// were applied to.
+func (u *updateMessageWriter) writeUpdate(b []byte) error {
 	/*
 		https://tools.ietf.org/html/rfc4271#page-72
 		Each time the local system sends a KEEPALIVE or UPDATE message, it
@@ -790,8 +1203,14 @@ func (u *updateMessageWriter) WriteUpdate(b []byte) error {
 	case <-u.closeCh:
 		return io.ErrClosedPipe
 	default:
-		_, err := u.conn.Write(prependHeader(b, updateMessageType))
+		err := u.writer.WriteMessage(UpdateMessageType, b)
 		if err == nil {
+			u.counters.outgoing(UpdateMessageType)
+			if u.suppressKeepAlives {
+				// the keepalive timer is reset upon expiry instead
+				u.wrote()
+				return nil
+			}
 			select {
 			case <-u.closeCh:
 			case u.resetKATimerCh <- struct{}{}:
@@ -801,8 +1220,48 @@ func (u *updateMessageWriter) WriteUpdate(b []byte) error {
 	}
 }
 
+func (u *updateMessageWriter) WriteRouteRefresh(r *RouteRefresh) error {
+	b, err := r.Encode()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-u.closeCh:
+		return io.ErrClosedPipe
+	default:
+		err = u.writer.WriteMessage(RouteRefreshMessageType, b[HeaderLength:])
+		if err == nil {
+			u.counters.outgoing(RouteRefreshMessageType)
+			u.wrote()
+		}
+		return err
+	}
+}
+
+func (u *updateMessageWriter) WriteCapability(
+	changes ...DynamicCapabilityChange) error {
+	if !u.dynamicCapability {
+		return errDynamicCapabilityNotNegotiated
+	}
+	b, err := capabilityMessage(changes).encode()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-u.closeCh:
+		return io.ErrClosedPipe
+	default:
+		err = u.writer.WriteMessage(CapabilityMessageType, b)
+		if err == nil {
+			u.counters.outgoing(CapabilityMessageType)
+			u.wrote()
+		}
+		return err
+	}
+}
+
 // https://tools.ietf.org/html/rfc4271#page-71
-func (f *fsm) established() (fsmState, error) {
+func (f *fsm) established(session *SessionInfo) (FSMState, error) {
 	// A separate goroutine is used for resetting the keepAlive timer to
 	// allow both our main select{} in the established() func below and the
 	// updateMessageWriter to reset it without synchronizing all input and
@@ -819,43 +1278,134 @@ func (f *fsm) established() (fsmState, error) {
 				return
 			case <-resetKATimerCh:
 				if f.holdTime != 0 {
-					f.keepAliveTimer.Reset(f.keepAliveInterval)
+					f.keepAliveTimer.Reset(f.keepAliveDelay())
 				}
 			}
 		}
 	}()
 
-	established := func() (fsmState, error) {
+	established := func() (FSMState, error) {
+		var w io.Writer = f.conn
+		if f.peer.messages != nil {
+			w = &historyWriter{w: f.conn, h: f.peer.messages}
+		}
+		messageWriter := NewMessageWriter(w)
+		if f.extendedMessage {
+			messageWriter.SetMaxMessageLength(ExtendedMaxMessageLength)
+		}
 		writer := &updateMessageWriter{
-			conn:           f.conn,
-			resetKATimerCh: resetKATimerCh,
-			closeCh:        make(chan struct{}),
+			writer:             messageWriter,
+			resetKATimerCh:     resetKATimerCh,
+			closeCh:            make(chan struct{}),
+			dynamicCapability:  f.dynamicCapability,
+			counters:           f.peer.counters,
+			suppressKeepAlives: f.peer.options.suppressKeepAlives,
+		}
+		if f.peer.options.lintUpdates {
+			writer.lintCodec = f.codec
+		}
+		if f.peer.options.outboundPolicy != nil {
+			writer.policy = f.outboundPolicy(f.peer.options.outboundPolicy)
+		}
+		if f.peer.options.asOverride && f.remoteAS != f.peer.config.LocalAS {
+			writer.transform = func(b []byte) ([]byte, error) {
+				return asOverride(b, f.fourOctetAS, f.remoteAS,
+					f.peer.config.LocalAS)
+			}
+		}
+		f.peer.options.tcpAuth.register(f.conn)
+		defer f.peer.options.tcpAuth.unregister(f.conn)
+		if f.peer.options.deduplicateUpdates {
+			writer.dedup = newUpdateDedup(f.codec)
+		}
+		if s := f.peer.options.writeScheduler; s != nil {
+			writer.queue = s.newQueue(f.peer, writer)
 		}
 		defer func() {
+			if writer.queue != nil {
+				writer.queue.close()
+			}
 			close(closeKAManagerCh)
 			close(writer.closeCh)
 		}()
-		handler := f.peer.plugin.OnEstablished(f.peer.config, writer)
+		var handler UpdateMessageHandler
+		if p, ok := f.peer.plugin.(DecodedUpdatePlugin); ok && session != nil {
+			handler = f.decodingHandler(p.OnEstablishedDecoded(f.peer.config,
+				session, writer))
+		} else if p, ok := f.peer.plugin.(SessionPlugin); ok && session != nil {
+			handler = f.inboundPolicyHandler(p.OnEstablishedSession(
+				f.peer.config, session, writer))
+		} else {
+			handler = f.inboundPolicyHandler(f.peer.plugin.OnEstablished(
+				f.peer.config, writer))
+		}
+		var (
+			queue        *inboundQueue
+			queueNotifCh chan *Notification
+		)
+		if handler != nil && f.peer.options.inboundQueueCapacity > 0 {
+			queue = f.startInboundQueue(handler)
+			queueNotifCh = queue.notifCh
+			defer queue.stop()
+		}
+		var outboundOverflowCh chan struct{}
+		if writer.queue != nil {
+			outboundOverflowCh = writer.queue.overflowCh
+		}
+
+		f.peer.keepAlive.reset(f.openRTT)
+		var tcpStatsCh <-chan time.Time
+		if d := f.peer.options.tcpStatsInterval; d > 0 {
+			f.sampleTCPStats()
+			ticker := time.NewTicker(d)
+			defer ticker.Stop()
+			tcpStatsCh = ticker.C
+		}
+
+		// update rate tracking for UpdateRateAlarm
+		var (
+			updateCount       int
+			updateWindowStart = time.Now()
+			alarmed           bool
+		)
 
 		for {
 			select {
 			case <-f.closeCh:
-				n := newNotification(NotifCodeCease, 0, nil)
+				n := f.ceaseNotification()
 				f.sendNotification(n)
-				return disabledState, newNotificationError(n, true)
+				return DisabledState, newNotificationError(n, true)
 			case <-f.holdTimer.C:
+				if f.extendHoldTimer() {
+					continue
+				}
 				n := newNotification(NotifCodeHoldTimerExpired, 0, nil)
 				f.sendNotification(n)
-				return idleState, newNotificationError(n, true)
+				return IdleState, newNotificationError(n, true)
+			case <-tcpStatsCh:
+				f.sampleTCPStats()
 			case <-f.keepAliveTimer.C:
+				if f.suppressKeepAlive(writer) {
+					continue
+				}
 				err := f.sendKeepAlive()
 				if err != nil {
-					return idleState, fmt.Errorf("error sending keepAlive: %w", err)
+					return IdleState, fmt.Errorf("error sending keepAlive: %w", err)
 				}
 				resetKATimerCh <- struct{}{}
+			case n := <-queueNotifCh:
+				f.sendNotification(n)
+				return IdleState, newNotificationError(n, true)
+			case <-outboundOverflowCh:
+				logf("[%s] outbound queue memory budget exceeded, closing "+
+					"session", f.peer.config.IP)
+				n := newNotification(NotifCodeCease,
+					NotifSubcodeOutOfResources, nil)
+				f.sendNotification(n)
+				return IdleState, newNotificationError(n, true)
 			case err := <-f.readerErrCh:
 				f.handleNotificationInErr(err)
-				return idleState, fmt.Errorf("error from reader: %w", err)
+				return IdleState, fmt.Errorf("error from reader: %w", err)
 			case m := <-f.readerMsgCh:
 				switch m := m.(type) {
 				case *Notification:
@@ -872,7 +1422,7 @@ func (f *fsm) established() (fsmState, error) {
 							- increments the ConnectRetryCounter by 1,
 							- changes its state to Idle.
 					*/
-					return idleState, newNotificationError(m, false)
+					return IdleState, newNotificationError(m, false)
 				case *keepAliveMessage:
 					/*
 						https://tools.ietf.org/html/rfc4271#page-74
@@ -886,6 +1436,8 @@ func (f *fsm) established() (fsmState, error) {
 					if f.holdTime != 0 {
 						f.drainAndResetHoldTimer()
 					}
+					f.peer.keepAlive.received(time.Now(),
+						connTCPStats(f.conn))
 					continue
 				case updateMessage:
 					/*
@@ -897,11 +1449,111 @@ func (f *fsm) established() (fsmState, error) {
 							  non-zero, and
 							- remains in the Established state.
 					*/
+					if f.peer.options.updateRateAlarm > 0 {
+						now := time.Now()
+						if now.Sub(updateWindowStart) >= time.Second {
+							updateWindowStart = now
+							updateCount = 0
+							alarmed = false
+						}
+						updateCount++
+						if !alarmed &&
+							updateCount > f.peer.options.updateRateAlarm {
+							alarmed = true
+							f.peer.events.publish(&UpdateRateAlarmEvent{
+								eventBase: newEventBase(f.peer),
+								Rate:      updateCount,
+								Threshold: f.peer.options.updateRateAlarm,
+							})
+						}
+					}
+					if f.peer.events.wantsUpdates() {
+						f.peer.events.publish(&UpdateReceivedEvent{
+							eventBase:   newEventBase(f.peer),
+							FourOctetAS: f.fourOctetAS,
+							Codec:       f.codec,
+							Update:      m,
+						})
+					}
+					updates := []updateMessage{m}
+					if f.peer.options.asLoopCheck {
+						checked, malformed, err := asLoopCheck(m,
+							f.fourOctetAS, f.peer.config.LocalAS,
+							f.peer.options.allowASIn)
+						if err != nil {
+							n := newNotification(NotifCodeUpdateMessageErr,
+								NotifSubcodeMalformedAttr, nil)
+							f.sendNotification(n)
+							return IdleState, newNotificationError(n, true)
+						}
+						if malformed && len(checked) > 0 {
+							atomic.AddUint64(
+								&f.peer.counters.updatesTreatedAsWithdraw, 1)
+							logf("[%s] treating update as withdraw due to "+
+								"malformed AS_PATH", f.peer.config.IP)
+						}
+						updates = updates[:0]
+						for _, u := range checked {
+							updates = append(updates, u)
+						}
+					}
 					if handler != nil {
-						n := handler(f.peer.config, m)
+						for _, u := range updates {
+							if queue != nil {
+								ok, to, err := f.enqueue(queue, u)
+								if !ok {
+									return to, err
+								}
+								continue
+							}
+							n := f.handleUpdate(handler, u)
+							if n != nil {
+								f.sendNotification(n)
+								return IdleState, newNotificationError(n, true)
+							}
+						}
+					}
+					if f.holdTime != 0 {
+						f.drainAndResetHoldTimer()
+					}
+					continue
+				case *RouteRefresh:
+					// https://tools.ietf.org/html/rfc2918#section-4
+					negotiated := hasCapability(f.sentCapabilities,
+						CapCodeRouteRefresh) &&
+						hasCapability(f.remoteCapabilities,
+							CapCodeRouteRefresh)
+					p, ok := f.peer.plugin.(RouteRefreshPlugin)
+					if !negotiated {
+						logf("[%s] ignoring route refresh, capability not "+
+							"negotiated", f.peer.config.IP)
+					} else if ok {
+						removeUnnegotiatedORFs(m, f.sentCapabilities,
+							f.remoteCapabilities)
+						n := p.OnRouteRefresh(f.peer.config, m)
+						if n != nil {
+							f.sendNotification(n)
+							return IdleState, newNotificationError(n, true)
+						}
+					}
+					if f.holdTime != 0 {
+						f.drainAndResetHoldTimer()
+					}
+					continue
+				case *capabilityMessage:
+					// the reader only passes CAPABILITY messages if dynamic
+					// capability was negotiated
+					err := m.checkCapabilityCodes(f.dynamicCapabilityCodes)
+					if err != nil {
+						f.handleNotificationInErr(err)
+						return IdleState, err
+					}
+					p, ok := f.peer.plugin.(DynamicCapabilityPlugin)
+					if ok {
+						n := p.OnCapabilityMessage(f.peer.config, *m)
 						if n != nil {
 							f.sendNotification(n)
-							return idleState, newNotificationError(n, true)
+							return IdleState, newNotificationError(n, true)
 						}
 					}
 					if f.holdTime != 0 {
@@ -937,7 +1589,7 @@ func (f *fsm) established() (fsmState, error) {
 						NotifSubcodeUnexpectedMessageEstablished,
 						[]byte{m.messageType()})
 					f.sendNotification(n)
-					return idleState, newNotificationError(n, true)
+					return IdleState, newNotificationError(n, true)
 				}
 			}
 		}
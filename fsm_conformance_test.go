@@ -0,0 +1,590 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// This file contains a conformance suite for the mandatory FSM events
+// described in RFC4271 section 8 and the unexpected message handling defined
+// in RFC6608. Each case drives an exported Server from the "remote" side of a
+// real TCP connection.
+
+const (
+	testLocalAS  = 65001
+	testRemoteAS = 65002
+	testLocalID  = "192.0.2.1"
+
+	// the minimum non-zero hold time accepted by corebgp
+	testHoldTime = 3
+	// the OpenSent hold timer, shortened so that its expiry can be tested
+	testOpenSentHoldTime = time.Second * 2
+)
+
+var (
+	// remote router IDs on either side of testLocalID
+	testDominantRemoteID    = net.ParseIP("203.0.113.1")
+	testSubordinateRemoteID = net.ParseIP("10.0.0.1")
+)
+
+type testPlugin struct {
+	openCh        chan []*Capability
+	establishedCh chan UpdateMessageWriter
+	updateCh      chan []byte
+	closeCh       chan struct{}
+}
+
+func newTestPlugin() *testPlugin {
+	return &testPlugin{
+		openCh:        make(chan []*Capability, 8),
+		establishedCh: make(chan UpdateMessageWriter, 8),
+		updateCh:      make(chan []byte, 8),
+		closeCh:       make(chan struct{}, 8),
+	}
+}
+
+func (p *testPlugin) GetCapabilities(peer *PeerConfig) []*Capability {
+	return nil
+}
+
+func (p *testPlugin) OnOpenMessage(peer *PeerConfig,
+	capabilities []*Capability) *Notification {
+	p.openCh <- capabilities
+	return nil
+}
+
+func (p *testPlugin) OnEstablished(peer *PeerConfig,
+	writer UpdateMessageWriter) UpdateMessageHandler {
+	p.establishedCh <- writer
+	return func(peer *PeerConfig, u []byte) *Notification {
+		p.updateCh <- u
+		return nil
+	}
+}
+
+func (p *testPlugin) OnClose(peer *PeerConfig) {
+	p.closeCh <- struct{}{}
+}
+
+// testEnv is a Server with a single peer along with the remote side of that
+// peer.
+type testEnv struct {
+	t *testing.T
+	// remoteLis accepts connections dialed by the Server
+	remoteLis net.Listener
+	// serverAddr is the address of the Server's listener
+	serverAddr string
+	server     *Server
+	plugin     *testPlugin
+	serveErrCh chan error
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	remoteLis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error creating remote listener: %v", err)
+	}
+	serverLis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error creating server listener: %v", err)
+	}
+	s, err := NewServer(net.ParseIP(testLocalID))
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+	e := &testEnv{
+		t:          t,
+		remoteLis:  remoteLis,
+		serverAddr: serverLis.Addr().String(),
+		server:     s,
+		plugin:     newTestPlugin(),
+		serveErrCh: make(chan error, 1),
+	}
+	err = s.AddPeer(&PeerConfig{
+		IP:       net.ParseIP("127.0.0.1"),
+		LocalAS:  testLocalAS,
+		RemoteAS: testRemoteAS,
+	}, e.plugin, Port(remoteLis.Addr().(*net.TCPAddr).Port),
+		openSentHoldTime(testOpenSentHoldTime))
+	if err != nil {
+		t.Fatalf("error adding peer: %v", err)
+	}
+	go func() {
+		e.serveErrCh <- s.Serve(serverLis)
+	}()
+	return e
+}
+
+func (e *testEnv) close() {
+	e.server.Close()
+	if err := <-e.serveErrCh; err != ErrServerClosed {
+		e.t.Errorf("unexpected serve error: %v", err)
+	}
+	e.remoteLis.Close()
+}
+
+// accept accepts the connection dialed by the Server.
+func (e *testEnv) accept() net.Conn {
+	e.remoteLis.(*net.TCPListener).SetDeadline(time.Now().Add(time.Second * 5))
+	conn, err := e.remoteLis.Accept()
+	if err != nil {
+		e.t.Fatalf("error accepting connection from server: %v", err)
+	}
+	return conn
+}
+
+// dial opens a connection towards the Server's listener.
+func (e *testEnv) dial() net.Conn {
+	conn, err := net.DialTimeout("tcp", e.serverAddr, time.Second*5)
+	if err != nil {
+		e.t.Fatalf("error dialing server: %v", err)
+	}
+	return conn
+}
+
+func (e *testEnv) expectEstablished() UpdateMessageWriter {
+	select {
+	case w := <-e.plugin.establishedCh:
+		return w
+	case <-time.After(time.Second * 5):
+		e.t.Fatal("timed out waiting for OnEstablished")
+	}
+	return nil
+}
+
+func (e *testEnv) expectClose() {
+	select {
+	case <-e.plugin.closeCh:
+	case <-time.After(time.Second * 5):
+		e.t.Fatal("timed out waiting for OnClose")
+	}
+}
+
+type testMessage struct {
+	msgType uint8
+	body    []byte
+}
+
+func readTestMessage(t *testing.T, conn net.Conn,
+	timeout time.Duration) (*testMessage, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	header := make([]byte, HeaderLength)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 16; i++ {
+		if header[i] != 0xFF {
+			t.Fatalf("invalid marker in message header: %x", header[:16])
+		}
+	}
+	l := int(binary.BigEndian.Uint16(header[16:18]))
+	if l < HeaderLength || l > MaxMessageLength {
+		t.Fatalf("invalid message length: %d", l)
+	}
+	body := make([]byte, l-HeaderLength)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, err
+	}
+	return &testMessage{msgType: header[18], body: body}, nil
+}
+
+// expectMessage reads messages until one of the provided type is found.
+// Keepalives are skipped when looking for other message types.
+func expectMessage(t *testing.T, conn net.Conn, msgType uint8,
+	timeout time.Duration) *testMessage {
+	deadline := time.Now().Add(timeout)
+	for {
+		m, err := readTestMessage(t, conn, time.Until(deadline))
+		if err != nil {
+			t.Fatalf("error reading message of type %d: %v", msgType, err)
+		}
+		if m.msgType == msgType {
+			return m
+		}
+		if m.msgType != KeepAliveMessageType {
+			t.Fatalf("expected message of type %d, got type %d", msgType,
+				m.msgType)
+		}
+	}
+}
+
+func expectNotification(t *testing.T, conn net.Conn, code, subcode uint8,
+	data []byte, timeout time.Duration) {
+	m := expectMessage(t, conn, NotificationMessageType, timeout)
+	n := &Notification{}
+	if err := n.Decode(m.body); err != nil {
+		t.Fatalf("error decoding notification: %v", err)
+	}
+	if n.Code != code || n.Subcode != subcode {
+		t.Fatalf("expected notification %d/%d, got %d/%d", code, subcode,
+			n.Code, n.Subcode)
+	}
+	if data != nil && string(n.Data) != string(data) {
+		t.Fatalf("expected notification data %v, got %v", data, n.Data)
+	}
+}
+
+func expectConnClosed(t *testing.T, conn net.Conn) {
+	for {
+		_, err := readTestMessage(t, conn, time.Second*5)
+		if err == nil {
+			continue
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			t.Fatal("timed out waiting for connection to close")
+		}
+		return
+	}
+}
+
+func expectOpen(t *testing.T, conn net.Conn) *OpenMessage {
+	m := expectMessage(t, conn, OpenMessageType, time.Second*5)
+	o := &OpenMessage{}
+	if err := o.Decode(m.body); err != nil {
+		t.Fatalf("error decoding open message: %v", err)
+	}
+	return o
+}
+
+func writeTestMessage(t *testing.T, conn net.Conn, b []byte) {
+	if _, err := conn.Write(b); err != nil {
+		t.Fatalf("error writing message: %v", err)
+	}
+}
+
+func writeOpen(t *testing.T, conn net.Conn, id net.IP, holdTime uint16) {
+	o, err := newOpenMessage(testRemoteAS, time.Duration(holdTime)*time.Second,
+		binary.BigEndian.Uint32(id.To4()), nil)
+	if err != nil {
+		t.Fatalf("error creating open message: %v", err)
+	}
+	b, err := o.Encode()
+	if err != nil {
+		t.Fatalf("error encoding open message: %v", err)
+	}
+	writeTestMessage(t, conn, b)
+}
+
+func writeKeepAlive(t *testing.T, conn net.Conn) {
+	b, _ := keepAliveMessage{}.encode()
+	writeTestMessage(t, conn, b)
+}
+
+func writeUpdate(t *testing.T, conn net.Conn) {
+	writeTestMessage(t, conn, prependHeader([]byte{0, 0, 0, 0},
+		UpdateMessageType))
+}
+
+// toOpenConfirm exchanges OPEN messages over conn, leaving the Server's FSM
+// in the OpenConfirm state.
+func toOpenConfirm(t *testing.T, conn net.Conn, id net.IP, holdTime uint16) {
+	expectOpen(t, conn)
+	writeOpen(t, conn, id, holdTime)
+	expectMessage(t, conn, KeepAliveMessageType, time.Second*5)
+}
+
+// toEstablished exchanges OPEN and KEEPALIVE messages over conn, leaving the
+// Server's FSM in the Established state.
+func toEstablished(t *testing.T, e *testEnv, conn net.Conn, holdTime uint16) {
+	toOpenConfirm(t, conn, testDominantRemoteID, holdTime)
+	writeKeepAlive(t, conn)
+	e.expectEstablished()
+}
+
+func TestFSMConformance(t *testing.T) {
+	holdTimerSlack := time.Second * 2
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, e *testEnv)
+	}{
+		{
+			// Event 1 - the Server dials the peer and sends an OPEN
+			name: "ManualStart",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				o := expectOpen(t, conn)
+				if o.Version != 4 {
+					t.Errorf("expected version 4, got %d", o.Version)
+				}
+				if o.ASN != testLocalAS {
+					t.Errorf("expected AS %d, got %d", testLocalAS, o.ASN)
+				}
+				if o.HoldTime != uint16(DefaultHoldTime.Seconds()) {
+					t.Errorf("expected hold time %s, got %d", DefaultHoldTime,
+						o.HoldTime)
+				}
+				id := binary.BigEndian.Uint32(net.ParseIP(testLocalID).To4())
+				if o.BGPID != id {
+					t.Errorf("expected BGP ID %d, got %d", id, o.BGPID)
+				}
+				var fourOctetAS bool
+				for _, c := range o.Capabilities() {
+					if c.Code == CapCodeFourOctetAS {
+						fourOctetAS = true
+					}
+				}
+				if !fourOctetAS {
+					t.Error("four octet AS capability missing")
+				}
+			},
+		},
+		{
+			// Event 10 in OpenSent
+			name: "HoldTimerExpiresOpenSent",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				expectOpen(t, conn)
+				expectNotification(t, conn, NotifCodeHoldTimerExpired, 0, nil,
+					testOpenSentHoldTime+holdTimerSlack)
+			},
+		},
+		{
+			// Event 10 in OpenConfirm
+			name: "HoldTimerExpiresOpenConfirm",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toOpenConfirm(t, conn, testDominantRemoteID, testHoldTime)
+				expectNotification(t, conn, NotifCodeHoldTimerExpired, 0, nil,
+					testHoldTime*time.Second+holdTimerSlack)
+			},
+		},
+		{
+			// Event 10 in Established
+			name: "HoldTimerExpiresEstablished",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				expectNotification(t, conn, NotifCodeHoldTimerExpired, 0, nil,
+					testHoldTime*time.Second+holdTimerSlack)
+				e.expectClose()
+			},
+		},
+		{
+			// Event 26 in Established restarts the hold timer
+			name: "KeepAliveRestartsHoldTimer",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				end := time.Now().Add(testHoldTime * time.Second * 2)
+				for time.Now().Before(end) {
+					writeKeepAlive(t, conn)
+					m, err := readTestMessage(t, conn, time.Second)
+					if err != nil {
+						if ne, ok := err.(net.Error); ok && ne.Timeout() {
+							continue
+						}
+						t.Fatalf("error reading message: %v", err)
+					}
+					if m.msgType != KeepAliveMessageType {
+						t.Fatalf("expected keepalive, got type %d", m.msgType)
+					}
+				}
+			},
+		},
+		{
+			// Event 27 in Established is passed to the plugin
+			name: "UpdateEstablished",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				writeUpdate(t, conn)
+				select {
+				case u := <-e.plugin.updateCh:
+					if len(u) != 4 {
+						t.Fatalf("unexpected update: %v", u)
+					}
+				case <-time.After(time.Second * 5):
+					t.Fatal("timed out waiting for update")
+				}
+			},
+		},
+		{
+			// Event 24 in Established
+			name: "NotificationEstablished",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				n, _ := newNotification(NotifCodeCease, 0, nil).encode()
+				writeTestMessage(t, conn, n)
+				e.expectClose()
+				expectConnClosed(t, conn)
+			},
+		},
+		{
+			// CAPABILITY messages are of an unsupported type unless dynamic
+			// capability was negotiated, whether or not they are malformed
+			name: "CapabilityWithoutDynamicCapability",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				writeTestMessage(t, conn,
+					prependHeader([]byte{0}, CapabilityMessageType))
+				expectNotification(t, conn, NotifCodeMessageHeaderErr,
+					NotifSubcodeBadType, []byte{CapabilityMessageType},
+					time.Second*5)
+			},
+		},
+		{
+			name: "UnexpectedKeepAliveOpenSent",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				expectOpen(t, conn)
+				writeKeepAlive(t, conn)
+				expectNotification(t, conn, NotifCodeFSMErr,
+					NotifSubcodeUnexpectedMessageOpenSent,
+					[]byte{KeepAliveMessageType}, time.Second*5)
+			},
+		},
+		{
+			name: "UnexpectedUpdateOpenSent",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				expectOpen(t, conn)
+				writeUpdate(t, conn)
+				expectNotification(t, conn, NotifCodeFSMErr,
+					NotifSubcodeUnexpectedMessageOpenSent,
+					[]byte{UpdateMessageType}, time.Second*5)
+			},
+		},
+		{
+			name: "UnexpectedOpenOpenConfirm",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toOpenConfirm(t, conn, testDominantRemoteID, testHoldTime)
+				writeOpen(t, conn, testDominantRemoteID, testHoldTime)
+				expectNotification(t, conn, NotifCodeFSMErr,
+					NotifSubcodeUnexpectedMessageOpenConfirm,
+					[]byte{OpenMessageType}, time.Second*5)
+			},
+		},
+		{
+			name: "UnexpectedUpdateOpenConfirm",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toOpenConfirm(t, conn, testDominantRemoteID, testHoldTime)
+				writeUpdate(t, conn)
+				expectNotification(t, conn, NotifCodeFSMErr,
+					NotifSubcodeUnexpectedMessageOpenConfirm,
+					[]byte{UpdateMessageType}, time.Second*5)
+			},
+		},
+		{
+			name: "UnexpectedOpenEstablished",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				writeOpen(t, conn, testDominantRemoteID, testHoldTime)
+				expectNotification(t, conn, NotifCodeFSMErr,
+					NotifSubcodeUnexpectedMessageEstablished,
+					[]byte{OpenMessageType}, time.Second*5)
+				e.expectClose()
+			},
+		},
+		{
+			// a message with an unknown type is a header error
+			name: "BadMessageType",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				expectOpen(t, conn)
+				writeTestMessage(t, conn, prependHeader(nil, 255))
+				expectNotification(t, conn, NotifCodeMessageHeaderErr,
+					NotifSubcodeBadType, []byte{255}, time.Second*5)
+			},
+		},
+		{
+			// both connections reach OpenConfirm and the remote has the
+			// higher BGP ID; the connection it initiated must be kept
+			name: "CollisionRemoteDominant",
+			fn: func(t *testing.T, e *testEnv) {
+				outConn := e.accept()
+				defer outConn.Close()
+				toOpenConfirm(t, outConn, testDominantRemoteID, testHoldTime)
+				inConn := e.dial()
+				defer inConn.Close()
+				expectOpen(t, inConn)
+				writeOpen(t, inConn, testDominantRemoteID, testHoldTime)
+				expectNotification(t, outConn, NotifCodeCease, 0, nil,
+					time.Second*5)
+				expectMessage(t, inConn, KeepAliveMessageType, time.Second*5)
+				writeKeepAlive(t, inConn)
+				e.expectEstablished()
+			},
+		},
+		{
+			// both connections reach OpenConfirm and the local system has
+			// the higher BGP ID; the connection it initiated must be kept
+			name: "CollisionLocalDominant",
+			fn: func(t *testing.T, e *testEnv) {
+				outConn := e.accept()
+				defer outConn.Close()
+				toOpenConfirm(t, outConn, testSubordinateRemoteID, testHoldTime)
+				inConn := e.dial()
+				defer inConn.Close()
+				expectOpen(t, inConn)
+				writeOpen(t, inConn, testSubordinateRemoteID, testHoldTime)
+				expectNotification(t, inConn, NotifCodeCease, 0, nil,
+					time.Second*5)
+				writeKeepAlive(t, outConn)
+				e.expectEstablished()
+			},
+		},
+		{
+			// a connection colliding with an Established session is closed
+			name: "CollisionEstablished",
+			fn: func(t *testing.T, e *testEnv) {
+				outConn := e.accept()
+				defer outConn.Close()
+				toEstablished(t, e, outConn, testHoldTime*10)
+				inConn := e.dial()
+				defer inConn.Close()
+				expectConnClosed(t, inConn)
+				writeKeepAlive(t, outConn)
+				expectMessage(t, outConn, KeepAliveMessageType,
+					testHoldTime*10*time.Second)
+			},
+		},
+		{
+			// Event 2 in Established
+			name: "ManualStopEstablished",
+			fn: func(t *testing.T, e *testEnv) {
+				conn := e.accept()
+				defer conn.Close()
+				toEstablished(t, e, conn, testHoldTime)
+				err := e.server.DeletePeer(net.ParseIP("127.0.0.1"))
+				if err != nil {
+					t.Fatalf("error deleting peer: %v", err)
+				}
+				expectNotification(t, conn, NotifCodeCease, 0, nil,
+					time.Second*5)
+				e.expectClose()
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			e := newTestEnv(t)
+			defer e.close()
+			c.fn(t, e)
+		})
+	}
+}
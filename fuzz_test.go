@@ -0,0 +1,75 @@
+//go:build go1.18
+// +build go1.18
+
+package corebgp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// This file contains fuzz targets for the wire parsing layer, checking that
+// decoding does not panic and that decoded messages are consistent with their
+// input. Differential targets checking OPEN, UPDATE and NOTIFICATION decoding
+// against gobgp are in the difffuzz module, which keeps corebgp itself free of
+// dependencies.
+
+func FuzzDecodeNotification(f *testing.F) {
+	f.Add([]byte{6, 0})
+	f.Add([]byte{5, 1, 4})
+	f.Add([]byte{2, 7, 1, 4, 0, 1, 0, 1})
+	f.Add([]byte{1})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		n := &Notification{}
+		if err := n.Decode(b); err != nil {
+			return
+		}
+		encoded, err := n.encode()
+		if err != nil {
+			t.Fatalf("error encoding decoded notification: %v", err)
+		}
+		if !bytes.Equal(encoded[HeaderLength:], b) {
+			t.Fatalf("round trip mismatch: in: %x out: %x", b,
+				encoded[HeaderLength:])
+		}
+	})
+}
+
+func FuzzMessageFromBytes(f *testing.F) {
+	f.Add(uint8(OpenMessageType), []byte{4, 0xFD, 0xE9, 0, 90, 192, 0, 2, 1, 0})
+	f.Add(uint8(UpdateMessageType), []byte{0, 0, 0, 0})
+	f.Add(uint8(NotificationMessageType), []byte{6, 0})
+	f.Add(uint8(KeepAliveMessageType), []byte{})
+	f.Add(uint8(5), []byte{0, 1, 0, 1})
+	f.Fuzz(func(t *testing.T, msgType uint8, b []byte) {
+		m, err := messageFromBytes(b, msgType)
+		if err != nil {
+			if msgType == NotificationMessageType {
+				// errors in a NOTIFICATION can't be reported to the peer
+				return
+			}
+			var nerr *notificationError
+			if !errors.As(err, &nerr) || !nerr.out {
+				t.Fatalf("decode error is not an outbound notification: %v",
+					err)
+			}
+			return
+		}
+		if m.messageType() != msgType {
+			t.Fatalf("message type mismatch: got %d want %d", m.messageType(),
+				msgType)
+		}
+		if msgType == KeepAliveMessageType && len(b) > 0 {
+			t.Fatal("keepalive message with non-empty body accepted")
+		}
+		if u, ok := m.(updateMessage); ok {
+			if !bytes.Equal(u, b) {
+				t.Fatal("update message does not match input")
+			}
+			if len(b) > 0 && &u[0] == &b[0] {
+				t.Fatal("update message aliases the read buffer")
+			}
+		}
+	})
+}
@@ -0,0 +1,589 @@
+// Package gracefulrestart implements the restarting speaker side of BGP
+// Graceful Restart (RFC4724) for applications built on corebgp.
+//
+// A Speaker persists the peers and address families of sessions negotiated
+// with Graceful Restart to a state file. When the application restarts
+// within the advertised restart time, the Speaker advertises the Restart
+// State bit, along with the Forwarding State bit for address families whose
+// forwarding state the application preserved, so that peers retain their
+// routes while sessions are re-established. Route selection is deferred
+// until the End-of-RIB marker was received from every previous peer, or the
+// selection deferral time expired:
+//
+//	s, err := gracefulrestart.New("/var/lib/app/gracefulrestart.json")
+//	if err != nil {
+//		...
+//	}
+//	err = srv.AddPeer(config, s.Plugin(plugin))
+//	...
+//	// in the plugin's OnEstablished
+//	go func() {
+//		if s.Wait(ctx) == nil {
+//			// the RIB is rebuilt, advertise routes followed by End-of-RIB
+//			writer.WriteUpdate(corebgp.NewEndOfRIB(corebgp.AFIIPv4,
+//				corebgp.SAFIUnicast))
+//		}
+//	}()
+//	...
+//	// on shutdown
+//	s.Save()
+package gracefulrestart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Default option values
+const (
+	DefaultRestartTime       = 120 * time.Second
+	DefaultSelectionDeferral = 360 * time.Second
+)
+
+// Family is an AFI/SAFI.
+type Family struct {
+	AFI  uint16
+	SAFI uint8
+}
+
+// PeerState is the persisted state of a peer.
+type PeerState struct {
+	Peer net.IP
+	// Families are the address families negotiated with the peer, for which
+	// End-of-RIB markers are awaited after a restart.
+	Families []Family
+}
+
+// State is the state persisted across restarts by a Speaker.
+type State struct {
+	// Saved is the time the State was saved. A restart is graceful if it
+	// completes within RestartTime of Saved.
+	Saved       time.Time
+	RestartTime time.Duration
+	// Forwarding are the address families whose forwarding state is
+	// preserved across restarts.
+	Forwarding []Family
+	Peers      []PeerState
+}
+
+type options struct {
+	families          []Family
+	forwarding        []Family
+	restartTime       time.Duration
+	selectionDeferral time.Duration
+	endOfRIBHandler   func(peer net.IP, family Family)
+	errorHandler      func(error)
+}
+
+func (o *options) setDefaults() {
+	o.families = []Family{{AFI: corebgp.AFIIPv4, SAFI: corebgp.SAFIUnicast}}
+	o.restartTime = DefaultRestartTime
+	o.selectionDeferral = DefaultSelectionDeferral
+	o.endOfRIBHandler = func(net.IP, Family) {}
+	o.errorHandler = func(error) {}
+}
+
+// Option is an option for a Speaker.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// Families sets the address families advertised in the Graceful Restart
+// capability, which defaults to IPv4 unicast. They should match the
+// Multiprotocol Extensions capabilities advertised by the wrapped Plugins.
+func Families(families ...Family) Option {
+	return newFuncOption(func(o *options) {
+		o.families = families
+	})
+}
+
+// PreserveForwarding declares address families whose forwarding state the
+// application preserves across restarts, e.g. because routes it installed in
+// the kernel are not flushed on exit. After a restart the Forwarding State bit
+// is advertised for the families declared both before and after the restart.
+func PreserveForwarding(families ...Family) Option {
+	return newFuncOption(func(o *options) {
+		o.forwarding = families
+	})
+}
+
+// RestartTime sets the restart time advertised in the Graceful Restart
+// capability, which defaults to DefaultRestartTime. It is truncated to whole
+// seconds and must not exceed 4095 seconds.
+func RestartTime(d time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		if d > 0 {
+			o.restartTime = d
+		}
+	})
+}
+
+// SelectionDeferral sets the maximum time to defer route selection after a
+// restart while awaiting End-of-RIB markers, which defaults to
+// DefaultSelectionDeferral.
+func SelectionDeferral(d time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		if d > 0 {
+			o.selectionDeferral = d
+		}
+	})
+}
+
+// EndOfRIBHandler sets a function called when an End-of-RIB marker is
+// received from a peer, e.g. to remove stale routes of the peer. It must not
+// block.
+func EndOfRIBHandler(fn func(peer net.IP, family Family)) Option {
+	return newFuncOption(func(o *options) {
+		o.endOfRIBHandler = fn
+	})
+}
+
+// ErrorHandler sets a function called with errors saving the state file. It
+// must not block.
+func ErrorHandler(fn func(error)) Option {
+	return newFuncOption(func(o *options) {
+		o.errorHandler = fn
+	})
+}
+
+// Speaker is the restarting speaker side of Graceful Restart. It is safe for
+// concurrent use.
+type Speaker struct {
+	path    string
+	options options
+
+	// forwarding are the families whose forwarding state was preserved
+	// across the restart, if restarting
+	forwarding map[Family]bool
+
+	mu         sync.Mutex
+	restarting bool
+	// pending are the families of each peer whose End-of-RIB marker is
+	// awaited, keyed by peer IP string
+	pending map[string]map[Family]bool
+	peers   map[string]PeerState
+	timer   *time.Timer
+	done    chan struct{}
+}
+
+// New returns a Speaker persisting its state to the file at path. If the file
+// holds the state of a previous instance saved within its restart time, the
+// Speaker is restarting until End-of-RIB markers were received from all
+// peers of the previous instance or the selection deferral time expired.
+func New(path string, opts ...Option) (*Speaker, error) {
+	s := &Speaker{
+		path:       path,
+		forwarding: make(map[Family]bool),
+		pending:    make(map[string]map[Family]bool),
+		peers:      make(map[string]PeerState),
+		done:       make(chan struct{}),
+	}
+	s.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&s.options)
+	}
+	state, err := Load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil || time.Since(state.Saved) >= state.RestartTime {
+		// first start or the restart took too long for peers to retain
+		// routes, there is nothing to wait for
+		close(s.done)
+		return s, nil
+	}
+	s.restarting = true
+	preserving := make(map[Family]bool)
+	for _, f := range s.options.forwarding {
+		preserving[f] = true
+	}
+	for _, f := range state.Forwarding {
+		if preserving[f] {
+			s.forwarding[f] = true
+		}
+	}
+	for _, p := range state.Peers {
+		key := p.Peer.String()
+		s.peers[key] = p
+		families := make(map[Family]bool)
+		for _, f := range p.Families {
+			families[f] = true
+		}
+		if len(families) > 0 {
+			s.pending[key] = families
+		}
+	}
+	if len(s.pending) == 0 {
+		s.finishLocked()
+		return s, nil
+	}
+	s.mu.Lock()
+	s.timer = time.AfterFunc(s.options.selectionDeferral, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.finishLocked()
+	})
+	s.mu.Unlock()
+	return s, nil
+}
+
+// Load reads the State saved at path.
+func Load(path string) (*State, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	err = json.Unmarshal(b, state)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding state file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Restarting returns true if the Speaker restarted gracefully and route
+// selection is still deferred.
+func (s *Speaker) Restarting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarting
+}
+
+// Done returns a channel that is closed once route selection is no longer
+// deferred. It is closed from the start if the Speaker is not restarting.
+func (s *Speaker) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until route selection is no longer deferred or ctx is done, in
+// which case ctx.Err() is returned.
+func (s *Speaker) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return nil
+	}
+}
+
+// finishLocked ends the deferral of route selection. s.mu must be held.
+func (s *Speaker) finishLocked() {
+	if !s.restarting {
+		return
+	}
+	s.restarting = false
+	s.pending = make(map[string]map[Family]bool)
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	close(s.done)
+}
+
+// Save persists the state of the Speaker, stamped with the current time. The
+// state is saved whenever a session is established, and should be saved on
+// shutdown so that the restart time is measured from then.
+func (s *Speaker) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *Speaker) saveLocked() error {
+	state := &State{
+		Saved:       time.Now(),
+		RestartTime: s.options.restartTime,
+		Forwarding:  s.options.forwarding,
+		Peers:       make([]PeerState, 0, len(s.peers)),
+	}
+	for _, p := range s.peers {
+		state.Peers = append(state.Peers, p)
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so that the state file is replaced
+	// atomically
+	tmp := s.path + ".tmp"
+	err = ioutil.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// capability returns the Graceful Restart capability to advertise.
+func (s *Speaker) capability() *corebgp.Capability {
+	s.mu.Lock()
+	restarting := s.restarting
+	s.mu.Unlock()
+	gr := &corebgp.GracefulRestart{
+		Restarting:  restarting,
+		RestartTime: uint16(s.options.restartTime / time.Second),
+	}
+	for _, f := range s.options.families {
+		gr.Tuples = append(gr.Tuples, corebgp.GracefulRestartTuple{
+			AFI:                 f.AFI,
+			SAFI:                f.SAFI,
+			ForwardingPreserved: restarting && s.forwarding[f],
+		})
+	}
+	return corebgp.NewGracefulRestartCap(gr)
+}
+
+// onOpen stops awaiting End-of-RIB markers from a peer that does not support
+// Graceful Restart or restarted itself.
+// https://tools.ietf.org/html/rfc4724#section-4.1
+func (s *Speaker) onOpen(peer net.IP, capabilities []*corebgp.Capability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := peer.String()
+	if s.pending[key] == nil {
+		return
+	}
+	if gr := decodeGracefulRestart(capabilities); gr == nil || gr.Restarting {
+		delete(s.pending, key)
+		if len(s.pending) == 0 {
+			s.finishLocked()
+		}
+	}
+}
+
+// onEstablished persists the families negotiated with peer if it supports
+// Graceful Restart.
+func (s *Speaker) onEstablished(peer net.IP,
+	capabilities []*corebgp.Capability) {
+	if decodeGracefulRestart(capabilities) == nil {
+		return
+	}
+	received := make(map[Family]bool)
+	for _, c := range capabilities {
+		if c.Code != corebgp.CapCodeMPExtensions {
+			continue
+		}
+		afi, safi, err := corebgp.DecodeMPExtensionsCap(c)
+		if err == nil {
+			received[Family{AFI: afi, SAFI: safi}] = true
+		}
+	}
+	if len(received) == 0 {
+		// https://tools.ietf.org/html/rfc4760#section-8
+		received[Family{AFI: corebgp.AFIIPv4, SAFI: corebgp.SAFIUnicast}] = true
+	}
+	p := PeerState{
+		Peer: peer,
+	}
+	for _, f := range s.options.families {
+		if received[f] {
+			p.Families = append(p.Families, f)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers[peer.String()] = p
+	if err := s.saveLocked(); err != nil {
+		s.options.errorHandler(fmt.Errorf("error saving state: %w", err))
+	}
+}
+
+// onEndOfRIB records the End-of-RIB marker received from peer for family.
+func (s *Speaker) onEndOfRIB(peer net.IP, family Family) {
+	s.options.endOfRIBHandler(peer, family)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := peer.String()
+	families := s.pending[key]
+	if families == nil {
+		return
+	}
+	delete(families, family)
+	if len(families) == 0 {
+		delete(s.pending, key)
+	}
+	if len(s.pending) == 0 {
+		s.finishLocked()
+	}
+}
+
+func decodeGracefulRestart(
+	capabilities []*corebgp.Capability) *corebgp.GracefulRestart {
+	for _, c := range capabilities {
+		if c.Code != corebgp.CapCodeGracefulRestart {
+			continue
+		}
+		gr, err := corebgp.DecodeGracefulRestartCap(c)
+		if err == nil {
+			return gr
+		}
+	}
+	return nil
+}
+
+// Plugin returns a corebgp.Plugin wrapping p, adding the Graceful Restart
+// capability to the capabilities of p and tracking sessions and End-of-RIB
+// markers received. p must not advertise a Graceful Restart capability
+// itself. The optional interfaces ConnCapabilitiesPlugin, SessionPlugin,
+// DecodedUpdatePlugin, RouteRefreshPlugin and DynamicCapabilityPlugin of p are
+// preserved. End-of-RIB markers are not tracked for sessions for which p
+// returns a nil handler, as UPDATE messages are then discarded.
+func (s *Speaker) Plugin(p corebgp.Plugin) corebgp.Plugin {
+	w := &plugin{
+		s: s,
+		p: p,
+	}
+	rr, isRR := p.(corebgp.RouteRefreshPlugin)
+	dc, isDC := p.(corebgp.DynamicCapabilityPlugin)
+	if _, ok := p.(corebgp.DecodedUpdatePlugin); ok {
+		d := &decodedPlugin{w}
+		switch {
+		case isRR && isDC:
+			return &struct {
+				*decodedPlugin
+				corebgp.RouteRefreshPlugin
+				corebgp.DynamicCapabilityPlugin
+			}{d, rr, dc}
+		case isRR:
+			return &struct {
+				*decodedPlugin
+				corebgp.RouteRefreshPlugin
+			}{d, rr}
+		case isDC:
+			return &struct {
+				*decodedPlugin
+				corebgp.DynamicCapabilityPlugin
+			}{d, dc}
+		}
+		return d
+	}
+	switch {
+	case isRR && isDC:
+		return &struct {
+			*plugin
+			corebgp.RouteRefreshPlugin
+			corebgp.DynamicCapabilityPlugin
+		}{w, rr, dc}
+	case isRR:
+		return &struct {
+			*plugin
+			corebgp.RouteRefreshPlugin
+		}{w, rr}
+	case isDC:
+		return &struct {
+			*plugin
+			corebgp.DynamicCapabilityPlugin
+		}{w, dc}
+	}
+	return w
+}
+
+type plugin struct {
+	s *Speaker
+	p corebgp.Plugin
+}
+
+func (w *plugin) GetCapabilities(
+	peer *corebgp.PeerConfig) []*corebgp.Capability {
+	return w.withCapability(w.p.GetCapabilities(peer))
+}
+
+func (w *plugin) GetConnCapabilities(peer *corebgp.PeerConfig,
+	conn corebgp.ConnInfo) []*corebgp.Capability {
+	if cp, ok := w.p.(corebgp.ConnCapabilitiesPlugin); ok {
+		return w.withCapability(cp.GetConnCapabilities(peer, conn))
+	}
+	return w.GetCapabilities(peer)
+}
+
+func (w *plugin) withCapability(
+	caps []*corebgp.Capability) []*corebgp.Capability {
+	return append(caps[:len(caps):len(caps)], w.s.capability())
+}
+
+func (w *plugin) OnOpenMessage(peer *corebgp.PeerConfig,
+	capabilities []*corebgp.Capability) *corebgp.Notification {
+	n := w.p.OnOpenMessage(peer, capabilities)
+	if n == nil {
+		w.s.onOpen(peer.IP, capabilities)
+	}
+	return n
+}
+
+func (w *plugin) OnEstablished(peer *corebgp.PeerConfig,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	// not called as plugin implements corebgp.SessionPlugin
+	return w.OnEstablishedSession(peer, &corebgp.SessionInfo{
+		Peer:     peer.IP,
+		RemoteAS: peer.RemoteAS,
+	}, writer)
+}
+
+func (w *plugin) OnEstablishedSession(peer *corebgp.PeerConfig,
+	session *corebgp.SessionInfo,
+	writer corebgp.UpdateMessageWriter) corebgp.UpdateMessageHandler {
+	w.s.onEstablished(peer.IP, session.Capabilities)
+	var handler corebgp.UpdateMessageHandler
+	if sp, ok := w.p.(corebgp.SessionPlugin); ok {
+		handler = sp.OnEstablishedSession(peer, session, writer)
+	} else {
+		handler = w.p.OnEstablished(peer, writer)
+	}
+	if handler == nil {
+		return nil
+	}
+	return func(peer *corebgp.PeerConfig, u []byte) *corebgp.Notification {
+		if afi, safi, ok := corebgp.DecodeEndOfRIB(u); ok {
+			w.s.onEndOfRIB(peer.IP, Family{AFI: afi, SAFI: safi})
+		}
+		return handler(peer, u)
+	}
+}
+
+func (w *plugin) OnClose(peer *corebgp.PeerConfig) {
+	w.p.OnClose(peer)
+}
+
+// decodedPlugin wraps a corebgp.DecodedUpdatePlugin.
+type decodedPlugin struct {
+	*plugin
+}
+
+func (w *decodedPlugin) OnEstablishedDecoded(peer *corebgp.PeerConfig,
+	session *corebgp.SessionInfo,
+	writer corebgp.UpdateMessageWriter) corebgp.DecodedUpdateHandler {
+	w.s.onEstablished(peer.IP, session.Capabilities)
+	handler := w.p.(corebgp.DecodedUpdatePlugin).OnEstablishedDecoded(peer,
+		session, writer)
+	if handler == nil {
+		return nil
+	}
+	return func(peer *corebgp.PeerConfig,
+		u *corebgp.DecodedUpdate) *corebgp.Notification {
+		if afi, safi, ok := u.EndOfRIB(); ok {
+			w.s.onEndOfRIB(peer.IP, Family{AFI: afi, SAFI: safi})
+		}
+		return handler(peer, u)
+	}
+}
@@ -0,0 +1,296 @@
+package grpcapi
+
+import (
+	"net"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/grpcapi/corebgppb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func peerOptionsFromProto(o *corebgppb.PeerOptions) ([]corebgp.PeerOption,
+	error) {
+	opts := make([]corebgp.PeerOption, 0)
+	if o == nil {
+		return opts, nil
+	}
+	if o.GetIdleHoldTimeSeconds() > 0 {
+		opts = append(opts, corebgp.IdleHoldTime(
+			time.Duration(o.GetIdleHoldTimeSeconds())*time.Second))
+	}
+	if o.GetPassive() {
+		opts = append(opts, corebgp.Passive())
+	}
+	if o.GetActiveOnly() {
+		opts = append(opts, corebgp.ActiveOnly())
+	}
+	if o.GetLocalAddress() != "" {
+		ip := net.ParseIP(o.GetLocalAddress())
+		if ip == nil {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid local address: %q", o.GetLocalAddress())
+		}
+		opts = append(opts, corebgp.LocalAddress(ip))
+	}
+	if o.GetPort() > 0 {
+		opts = append(opts, corebgp.Port(int(o.GetPort())))
+	}
+	if o.GetVrf() != "" {
+		opts = append(opts, corebgp.VRF(o.GetVrf()))
+	}
+	if o.GetDynamicCapability() {
+		opts = append(opts, corebgp.DynamicCapability())
+	}
+	if o.AllowAsIn != nil {
+		opts = append(opts, corebgp.AllowASIn(int(o.GetAllowAsIn())))
+	}
+	if o.GetAsOverride() {
+		opts = append(opts, corebgp.ASOverride())
+	}
+	if o.GetUpdateRateAlarm() > 0 {
+		opts = append(opts, corebgp.UpdateRateAlarm(int(o.GetUpdateRateAlarm())))
+	}
+	return opts, nil
+}
+
+func peerOptionsToProto(o corebgp.PeerOptionsSummary) *corebgppb.PeerOptions {
+	p := &corebgppb.PeerOptions{
+		IdleHoldTimeSeconds: seconds(o.IdleHoldTime),
+		Passive:             o.Passive,
+		ActiveOnly:          o.ActiveOnly,
+		Port:                uint32(o.Port),
+		Vrf:                 o.VRF,
+		DynamicCapability:   o.DynamicCapability,
+		AsOverride:          o.ASOverride,
+		UpdateRateAlarm:     uint32(o.UpdateRateAlarm),
+	}
+	if o.LocalAddress != nil {
+		p.LocalAddress = o.LocalAddress.String()
+	}
+	if o.ASLoopCheck {
+		allowASIn := uint32(o.AllowASIn)
+		p.AllowAsIn = &allowASIn
+	}
+	return p
+}
+
+func stateToProto(s corebgp.FSMState) corebgppb.SessionState {
+	switch s {
+	case corebgp.DisabledState:
+		return corebgppb.SessionState_SESSION_STATE_DISABLED
+	case corebgp.IdleState:
+		return corebgppb.SessionState_SESSION_STATE_IDLE
+	case corebgp.ConnectState:
+		return corebgppb.SessionState_SESSION_STATE_CONNECT
+	case corebgp.ActiveState:
+		return corebgppb.SessionState_SESSION_STATE_ACTIVE
+	case corebgp.OpenSentState:
+		return corebgppb.SessionState_SESSION_STATE_OPEN_SENT
+	case corebgp.OpenConfirmState:
+		return corebgppb.SessionState_SESSION_STATE_OPEN_CONFIRM
+	case corebgp.EstablishedState:
+		return corebgppb.SessionState_SESSION_STATE_ESTABLISHED
+	}
+	return corebgppb.SessionState_SESSION_STATE_UNSPECIFIED
+}
+
+func dialFailureCauseToProto(
+	c corebgp.DialFailureCause) corebgppb.DialFailureCause {
+	switch c {
+	case corebgp.DialFailureOther:
+		return corebgppb.DialFailureCause_DIAL_FAILURE_CAUSE_OTHER
+	case corebgp.DialFailureRefused:
+		return corebgppb.DialFailureCause_DIAL_FAILURE_CAUSE_REFUSED
+	case corebgp.DialFailureUnreachable:
+		return corebgppb.DialFailureCause_DIAL_FAILURE_CAUSE_UNREACHABLE
+	case corebgp.DialFailureTimeout:
+		return corebgppb.DialFailureCause_DIAL_FAILURE_CAUSE_TIMEOUT
+	}
+	return corebgppb.DialFailureCause_DIAL_FAILURE_CAUSE_UNSPECIFIED
+}
+
+// unixNano returns t in nanoseconds since the Unix epoch, or zero if t is
+// zero.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func dialStatusToProto(s corebgp.DialStatus) *corebgppb.DialStatus {
+	p := &corebgppb.DialStatus{
+		Attempts:            s.Attempts,
+		Failures:            s.Failures,
+		LastAttemptUnixNano: unixNano(s.LastAttempt),
+		NextAttemptUnixNano: unixNano(s.NextAttempt),
+	}
+	if s.LastFailure != nil {
+		p.LastFailure = &corebgppb.DialFailure{
+			TimeUnixNano: s.LastFailure.Time.UnixNano(),
+			Cause:        dialFailureCauseToProto(s.LastFailure.Cause),
+			Error:        s.LastFailure.Err,
+		}
+	}
+	return p
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func sessionToProto(s *corebgp.SessionInfo) *corebgppb.SessionInfo {
+	if s == nil {
+		return nil
+	}
+	p := &corebgppb.SessionInfo{
+		Inbound:               s.Inbound,
+		LocalAddress:          addrString(s.LocalAddr),
+		RemoteAddress:         addrString(s.RemoteAddr),
+		RemoteId:              s.RemoteID.String(),
+		HoldTimeSeconds:       seconds(s.HoldTime),
+		FourOctetAs:           s.FourOctetAS,
+		Capabilities:          make([]*corebgppb.Capability, 0, len(s.Capabilities)),
+		EstablishedAtUnixNano: s.EstablishedAt.UnixNano(),
+		Interface:             s.Interface,
+		Mss:                   uint32(s.MSS),
+	}
+	for _, c := range s.Capabilities {
+		p.Capabilities = append(p.Capabilities, &corebgppb.Capability{
+			Code:  uint32(c.Code),
+			Value: c.Value,
+		})
+	}
+	return p
+}
+
+func peerStatusToProto(s corebgp.PeerStatus) *corebgppb.PeerStatus {
+	return &corebgppb.PeerStatus{
+		Config: &corebgppb.PeerConfig{
+			Address:  s.Config.IP.String(),
+			LocalAs:  s.Config.LocalAS,
+			RemoteAs: s.Config.RemoteAS,
+		},
+		Options:       peerOptionsToProto(s.Options),
+		State:         stateToProto(s.State),
+		AdminDisabled: s.AdminDisabled,
+		UptimeSeconds: uint64(s.Uptime / time.Second),
+		Session:       sessionToProto(s.Session),
+		Counters: &corebgppb.Counters{
+			MessagesReceived:         s.Counters.MessagesReceived,
+			MessagesSent:             s.Counters.MessagesSent,
+			UpdatesReceived:          s.Counters.UpdatesReceived,
+			UpdatesSent:              s.Counters.UpdatesSent,
+			NotificationsReceived:    s.Counters.NotificationsReceived,
+			NotificationsSent:        s.Counters.NotificationsSent,
+			EstablishedTransitions:   s.Counters.EstablishedTransitions,
+			UpdatesRateLimited:       s.Counters.UpdatesRateLimited,
+			UpdatesDropped:           s.Counters.UpdatesDropped,
+			HoldTimerExtensions:      s.Counters.HoldTimerExtensions,
+			InvalidMarkers:           s.Counters.InvalidMarkers,
+			UpdateErrorsIgnored:      s.Counters.UpdateErrorsIgnored,
+			UpdatesTreatedAsWithdraw: s.Counters.UpdatesTreatedAsWithdraw,
+			OversizedMessages:        s.Counters.OversizedMessages,
+			UpdatesFiltered:          s.Counters.UpdatesFiltered,
+			MemoryBudgetExceeded:     s.Counters.MemoryBudgetExceeded,
+			UpdatesDeduplicated:      s.Counters.UpdatesDeduplicated,
+		},
+		Dial: dialStatusToProto(s.Dial),
+		Key:  s.Key().String(),
+	}
+}
+
+// eventToProto converts a corebgp.Event to an Event, returning nil for events
+// not represented in the API.
+func eventToProto(e corebgp.Event) *corebgppb.Event {
+	p := &corebgppb.Event{
+		TimeUnixNano: e.EventTime().UnixNano(),
+		Address:      e.EventPeer().String(),
+		Key:          e.EventPeerKey().String(),
+	}
+	switch e := e.(type) {
+	case *corebgp.PeerAddedEvent:
+		p.Event = &corebgppb.Event_PeerAdded_{
+			PeerAdded: &corebgppb.Event_PeerAdded{},
+		}
+	case *corebgp.PeerDeletedEvent:
+		p.Event = &corebgppb.Event_PeerDeleted_{
+			PeerDeleted: &corebgppb.Event_PeerDeleted{},
+		}
+	case *corebgp.StateChangeEvent:
+		p.Event = &corebgppb.Event_StateChange_{
+			StateChange: &corebgppb.Event_StateChange{
+				Inbound: e.Inbound,
+				From:    stateToProto(e.From),
+				To:      stateToProto(e.To),
+			},
+		}
+	case *corebgp.EstablishmentFailedEvent:
+		p.Event = &corebgppb.Event_EstablishmentFailed_{
+			EstablishmentFailed: &corebgppb.Event_EstablishmentFailed{
+				Inbound: e.Inbound,
+				State:   stateToProto(e.State),
+				Error:   e.Err.Error(),
+			},
+		}
+	case *corebgp.NotificationReceivedEvent:
+		p.Event = &corebgppb.Event_NotificationReceived_{
+			NotificationReceived: &corebgppb.Event_NotificationReceived{
+				Notification: &corebgppb.Notification{
+					Code:    uint32(e.Notification.Code),
+					Subcode: uint32(e.Notification.Subcode),
+					Data:    e.Notification.Data,
+				},
+			},
+		}
+	case *corebgp.UpdateRateAlarmEvent:
+		p.Event = &corebgppb.Event_UpdateRateAlarm_{
+			UpdateRateAlarm: &corebgppb.Event_UpdateRateAlarm{
+				Rate:      uint32(e.Rate),
+				Threshold: uint32(e.Threshold),
+			},
+		}
+	case *corebgp.DialFailedEvent:
+		p.Event = &corebgppb.Event_DialFailed_{
+			DialFailed: &corebgppb.Event_DialFailed{
+				Cause:               dialFailureCauseToProto(e.Cause),
+				Error:               e.Err.Error(),
+				NextAttemptUnixNano: unixNano(e.NextAttempt),
+			},
+		}
+	case *corebgp.EstablishmentAttemptEvent:
+		t := e.Timeline
+		a := &corebgppb.Event_EstablishmentAttempt{
+			Inbound:              t.Inbound,
+			StartedUnixNano:      unixNano(t.Started),
+			ConnectedUnixNano:    unixNano(t.Connected),
+			OpenSentUnixNano:     unixNano(t.OpenSent),
+			OpenReceivedUnixNano: unixNano(t.OpenReceived),
+			EstablishedUnixNano:  unixNano(t.Established),
+		}
+		if t.Err != nil {
+			a.FailedState = stateToProto(t.Err.State)
+			a.Error = t.Err.Err
+		}
+		p.Event = &corebgppb.Event_EstablishmentAttempt_{
+			EstablishmentAttempt: a,
+		}
+	case *corebgp.TimerRescheduledEvent:
+		p.Event = &corebgppb.Event_TimerRescheduled_{
+			TimerRescheduled: &corebgppb.Event_TimerRescheduled{
+				Timer:          e.Timer.String(),
+				DelayNanos:     int64(e.Delay),
+				ExpiryUnixNano: unixNano(e.Expiry),
+				Error:          e.Err.Error(),
+			},
+		}
+	default:
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,5 @@
+// Package corebgppb contains the protobuf and gRPC definitions of the
+// corebgp management API.
+package corebgppb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative corebgp.proto
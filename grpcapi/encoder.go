@@ -0,0 +1,39 @@
+package grpcapi
+
+import (
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/export"
+	"google.golang.org/protobuf/proto"
+)
+
+type protoEncoder struct {
+	includeRaw bool
+}
+
+// NewProtoEncoder returns an export.Encoder encoding UPDATE messages as
+// corebgppb.Update and other events as corebgppb.Event, as streamed by the
+// WatchUpdates and WatchEvents RPCs. includeRaw includes the UPDATE message
+// body in each Update.
+func NewProtoEncoder(includeRaw bool) export.Encoder {
+	return &protoEncoder{
+		includeRaw: includeRaw,
+	}
+}
+
+func (p *protoEncoder) Encode(e corebgp.Event) ([]export.Message, error) {
+	var m proto.Message
+	if u, ok := e.(*corebgp.UpdateReceivedEvent); ok {
+		m = updateToProto(u, p.includeRaw)
+	} else {
+		pe := eventToProto(e)
+		if pe == nil {
+			return nil, nil
+		}
+		m = pe
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return []export.Message{{Value: b}}, nil
+}
@@ -0,0 +1,134 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/grpcapi/corebgppb"
+	"github.com/jwhited/corebgp/lookingglass"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LookingGlassService implements corebgppb.LookingGlassServer for a
+// lookingglass.RIB.
+type LookingGlassService struct {
+	corebgppb.UnimplementedLookingGlassServer
+
+	rib lookingglass.RIB
+}
+
+// NewLookingGlassService returns a LookingGlassService answering queries
+// against rib.
+func NewLookingGlassService(rib lookingglass.RIB) *LookingGlassService {
+	return &LookingGlassService{
+		rib: rib,
+	}
+}
+
+// Register registers the LookingGlassService with g.
+func (s *LookingGlassService) Register(g *grpc.Server) {
+	corebgppb.RegisterLookingGlassServer(g, s)
+}
+
+func matchFromProto(m corebgppb.Match) (lookingglass.Match, error) {
+	switch m {
+	case corebgppb.Match_MATCH_LONGEST:
+		return lookingglass.MatchLongest, nil
+	case corebgppb.Match_MATCH_EXACT:
+		return lookingglass.MatchExact, nil
+	case corebgppb.Match_MATCH_LONGER:
+		return lookingglass.MatchLonger, nil
+	}
+	return 0, status.Errorf(codes.InvalidArgument, "invalid match: %v", m)
+}
+
+func routeToProto(r lookingglass.Route) *corebgppb.Route {
+	pr := &corebgppb.Route{
+		Peer:             r.Peer.String(),
+		Prefix:           r.Prefix.String(),
+		Attributes:       r.Attributes,
+		ReceivedUnixNano: r.Received.UnixNano(),
+		Best:             r.Best,
+	}
+	if r.NextHop != nil {
+		pr.NextHop = r.NextHop.String()
+	}
+	if r.PeerKey.IP != nil {
+		pr.PeerKey = r.PeerKey.String()
+	}
+	for _, seg := range r.ASPath {
+		pr.AsPath = append(pr.AsPath, &corebgppb.ASPathSegment{
+			Type: uint32(seg.Type),
+			Asns: seg.ASNs,
+		})
+	}
+	return pr
+}
+
+func routesToProto(routes []lookingglass.Route, err error) ([]*corebgppb.Route,
+	error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, lookingglass.ErrPeerNotExist):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, corebgp.ErrPeerAmbiguous):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	lookingglass.SortRoutes(routes)
+	pr := make([]*corebgppb.Route, 0, len(routes))
+	for _, r := range routes {
+		pr = append(pr, routeToProto(r))
+	}
+	return pr, nil
+}
+
+func (s *LookingGlassService) LookupRoutes(ctx context.Context,
+	req *corebgppb.LookupRoutesRequest) (*corebgppb.LookupRoutesResponse,
+	error) {
+	prefix, err := lookingglass.ParsePrefix(req.GetPrefix())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	match, err := matchFromProto(req.GetMatch())
+	if err != nil {
+		return nil, err
+	}
+	routes, err := routesToProto(s.rib.LookupRoutes(prefix, match))
+	if err != nil {
+		return nil, err
+	}
+	return &corebgppb.LookupRoutesResponse{
+		Routes: routes,
+	}, nil
+}
+
+func (s *LookingGlassService) ListReceivedRoutes(ctx context.Context,
+	req *corebgppb.ListReceivedRoutesRequest) (
+	*corebgppb.ListReceivedRoutesResponse, error) {
+	ref, err := parsePeerRef(req.GetAddress(), req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	var routes []*corebgppb.Route
+	if ref.byKey {
+		keyed, ok := s.rib.(lookingglass.KeyedRIB)
+		if !ok {
+			return nil, status.Error(codes.Unimplemented,
+				"rib does not identify peers by key")
+		}
+		routes, err = routesToProto(keyed.ReceivedRoutesByKey(ref.key))
+	} else {
+		routes, err = routesToProto(s.rib.ReceivedRoutes(ref.key.IP))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &corebgppb.ListReceivedRoutesResponse{
+		Routes: routes,
+	}, nil
+}
@@ -0,0 +1,332 @@
+// Package grpcapi provides a gRPC management service for a corebgp.Server,
+// defined in corebgppb/corebgp.proto.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/grpcapi/corebgppb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PluginFactory returns the Plugin for a peer added via the AddPeer RPC.
+type PluginFactory func(config *corebgp.PeerConfig) corebgp.Plugin
+
+// Service implements corebgppb.CoreBGPServer for a corebgp.Server.
+type Service struct {
+	corebgppb.UnimplementedCoreBGPServer
+
+	server    *corebgp.Server
+	newPlugin PluginFactory
+}
+
+// NewService returns a Service managing server. newPlugin provides the Plugin
+// for peers added via the AddPeer RPC, which is unimplemented if newPlugin is
+// nil.
+func NewService(server *corebgp.Server, newPlugin PluginFactory) *Service {
+	return &Service{
+		server:    server,
+		newPlugin: newPlugin,
+	}
+}
+
+// Register registers the Service with g.
+func (s *Service) Register(g *grpc.Server) {
+	corebgppb.RegisterCoreBGPServer(g, s)
+}
+
+const (
+	// the buffer size of subscriptions backing streaming RPCs
+	streamBufferSize = 1024
+)
+
+func parseAddress(address string) (net.IP, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid address: %q", address)
+	}
+	return ip, nil
+}
+
+// peerRef is the peer a request refers to, by its key if byKey is true or
+// otherwise by the IP address of key alone.
+type peerRef struct {
+	key   corebgp.PeerKey
+	byKey bool
+}
+
+func peerKeyFromProto(k *corebgppb.PeerKey) (corebgp.PeerKey, error) {
+	ip, err := parseAddress(k.GetAddress())
+	if err != nil {
+		return corebgp.PeerKey{}, err
+	}
+	if k.GetPort() > 65535 {
+		return corebgp.PeerKey{}, status.Errorf(codes.InvalidArgument,
+			"invalid port: %d", k.GetPort())
+	}
+	return corebgp.PeerKey{
+		VRF:      k.GetVrf(),
+		IP:       ip,
+		Port:     int(k.GetPort()),
+		RemoteAS: k.GetRemoteAs(),
+	}, nil
+}
+
+// parsePeerRef returns the peerRef of the address and key fields of a
+// request, key taking precedence if set.
+func parsePeerRef(address string, key *corebgppb.PeerKey) (peerRef, error) {
+	if key != nil {
+		k, err := peerKeyFromProto(key)
+		return peerRef{key: k, byKey: true}, err
+	}
+	ip, err := parseAddress(address)
+	return peerRef{key: corebgp.PeerKey{IP: ip}}, err
+}
+
+// peerError converts an error returned by a corebgp.Server peer method to a
+// gRPC status error.
+func peerError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, corebgp.ErrPeerNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, corebgp.ErrPeerExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, corebgp.ErrServerClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func (s *Service) AddPeer(ctx context.Context,
+	req *corebgppb.AddPeerRequest) (*corebgppb.AddPeerResponse, error) {
+	if s.newPlugin == nil {
+		return nil, status.Error(codes.Unimplemented,
+			"service has no plugin factory")
+	}
+	if req.GetConfig() == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing config")
+	}
+	ip, err := parseAddress(req.GetConfig().GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	config := &corebgp.PeerConfig{
+		IP:       ip,
+		LocalAS:  req.GetConfig().GetLocalAs(),
+		RemoteAS: req.GetConfig().GetRemoteAs(),
+	}
+	opts, err := peerOptionsFromProto(req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+	err = s.server.AddPeer(config, s.newPlugin(config), opts...)
+	if err != nil {
+		return nil, peerError(err)
+	}
+	return &corebgppb.AddPeerResponse{}, nil
+}
+
+func (s *Service) DeletePeer(ctx context.Context,
+	req *corebgppb.DeletePeerRequest) (*corebgppb.DeletePeerResponse, error) {
+	ref, err := parsePeerRef(req.GetAddress(), req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if ref.byKey {
+		err = s.server.DeletePeerByKey(ref.key)
+	} else {
+		err = s.server.DeletePeer(ref.key.IP)
+	}
+	if err != nil {
+		return nil, peerError(err)
+	}
+	return &corebgppb.DeletePeerResponse{}, nil
+}
+
+func (s *Service) GetPeer(ctx context.Context,
+	req *corebgppb.GetPeerRequest) (*corebgppb.GetPeerResponse, error) {
+	ref, err := parsePeerRef(req.GetAddress(), req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	var p corebgp.PeerStatus
+	if ref.byKey {
+		p, err = s.server.GetPeerByKey(ref.key)
+	} else {
+		p, err = s.server.GetPeer(ref.key.IP)
+	}
+	if err != nil {
+		return nil, peerError(err)
+	}
+	return &corebgppb.GetPeerResponse{
+		Peer: peerStatusToProto(p),
+	}, nil
+}
+
+func (s *Service) ListPeers(ctx context.Context,
+	req *corebgppb.ListPeersRequest) (*corebgppb.ListPeersResponse, error) {
+	peers := s.server.ListPeers()
+	resp := &corebgppb.ListPeersResponse{
+		Peers: make([]*corebgppb.PeerStatus, 0, len(peers)),
+	}
+	for _, p := range peers {
+		resp.Peers = append(resp.Peers, peerStatusToProto(p))
+	}
+	return resp, nil
+}
+
+func (s *Service) ResetPeer(ctx context.Context,
+	req *corebgppb.ResetPeerRequest) (*corebgppb.ResetPeerResponse, error) {
+	ref, err := parsePeerRef(req.GetAddress(), req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if ref.byKey {
+		err = s.server.ResetPeerByKey(ref.key)
+	} else {
+		err = s.server.ResetPeer(ref.key.IP)
+	}
+	if err != nil {
+		return nil, peerError(err)
+	}
+	return &corebgppb.ResetPeerResponse{}, nil
+}
+
+func (s *Service) DisablePeer(ctx context.Context,
+	req *corebgppb.DisablePeerRequest) (*corebgppb.DisablePeerResponse, error) {
+	ref, err := parsePeerRef(req.GetAddress(), req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if ref.byKey {
+		err = s.server.DisablePeerByKey(ref.key)
+	} else {
+		err = s.server.DisablePeer(ref.key.IP)
+	}
+	if err != nil {
+		return nil, peerError(err)
+	}
+	return &corebgppb.DisablePeerResponse{}, nil
+}
+
+func (s *Service) EnablePeer(ctx context.Context,
+	req *corebgppb.EnablePeerRequest) (*corebgppb.EnablePeerResponse, error) {
+	ref, err := parsePeerRef(req.GetAddress(), req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if ref.byKey {
+		err = s.server.EnablePeerByKey(ref.key)
+	} else {
+		err = s.server.EnablePeer(ref.key.IP)
+	}
+	if err != nil {
+		return nil, peerError(err)
+	}
+	return &corebgppb.EnablePeerResponse{}, nil
+}
+
+// peerFilter returns a function reporting whether events for the peer of a
+// key should be streamed given the addresses and keys of a Watch request.
+func peerFilter(addresses []string,
+	keys []*corebgppb.PeerKey) (func(corebgp.PeerKey) bool, error) {
+	if len(addresses) == 0 && len(keys) == 0 {
+		return func(corebgp.PeerKey) bool { return true }, nil
+	}
+	set := make(map[string]struct{}, len(addresses))
+	for _, a := range addresses {
+		ip, err := parseAddress(a)
+		if err != nil {
+			return nil, err
+		}
+		set[ip.String()] = struct{}{}
+	}
+	matches := make([]corebgp.PeerKey, 0, len(keys))
+	for _, pk := range keys {
+		k, err := peerKeyFromProto(pk)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, k)
+	}
+	return func(k corebgp.PeerKey) bool {
+		if _, ok := set[k.IP.String()]; ok {
+			return true
+		}
+		for _, m := range matches {
+			if m.IP.Equal(k.IP) && m.VRF == k.VRF &&
+				(m.Port == 0 || m.Port == k.Port) &&
+				(m.RemoteAS == 0 || m.RemoteAS == k.RemoteAS) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// watch sends events received on sub to send until ctx is done or send
+// returns an error.
+func watch(ctx context.Context, sub *corebgp.Subscription,
+	include func(corebgp.PeerKey) bool, send func(corebgp.Event) error) error {
+	defer sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-sub.C():
+			if !include(e.EventPeerKey()) {
+				continue
+			}
+			err := send(e)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Service) WatchEvents(req *corebgppb.WatchEventsRequest,
+	stream corebgppb.CoreBGP_WatchEventsServer) error {
+	include, err := peerFilter(req.GetAddresses(), req.GetKeys())
+	if err != nil {
+		return err
+	}
+	return watch(stream.Context(), s.server.Subscribe(streamBufferSize),
+		include, func(e corebgp.Event) error {
+			pe := eventToProto(e)
+			if pe == nil {
+				return nil
+			}
+			return stream.Send(pe)
+		})
+}
+
+func (s *Service) WatchUpdates(req *corebgppb.WatchUpdatesRequest,
+	stream corebgppb.CoreBGP_WatchUpdatesServer) error {
+	include, err := peerFilter(req.GetAddresses(), req.GetKeys())
+	if err != nil {
+		return err
+	}
+	return watch(stream.Context(), s.server.SubscribeUpdates(streamBufferSize),
+		include, func(e corebgp.Event) error {
+			u, ok := e.(*corebgp.UpdateReceivedEvent)
+			if !ok {
+				return nil
+			}
+			return stream.Send(updateToProto(u, req.GetIncludeRaw()))
+		})
+}
+
+func seconds(d time.Duration) uint32 {
+	return uint32(d / time.Second)
+}
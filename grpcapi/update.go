@@ -0,0 +1,224 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/grpcapi/corebgppb"
+)
+
+var (
+	errMalformedUpdate = errors.New("malformed update message")
+)
+
+// decodePrefixes decodes a sequence of prefixes of the given AFI encoded as
+// length, prefix tuples via corebgp.NLRICodec, which rejects prefixes with
+// bits set beyond their length.
+// https://tools.ietf.org/html/rfc4271#section-4.3
+func decodePrefixes(b []byte, afi uint16) ([]string, error) {
+	nlri, err := corebgp.NLRICodec{AFI: afi}.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]string, 0, len(nlri))
+	for _, n := range nlri {
+		prefixes = append(prefixes, n.Prefix.String())
+	}
+	return prefixes, nil
+}
+
+// hasPrefixNLRI returns true if NLRI of the given AFI/SAFI are encoded as
+// plain prefixes.
+func hasPrefixNLRI(afi uint16, safi uint8) bool {
+	return (afi == corebgp.AFIIPv4 || afi == corebgp.AFIIPv6) &&
+		(safi == corebgp.SAFIUnicast || safi == corebgp.SAFIMulticast)
+}
+
+// https://tools.ietf.org/html/rfc4760#section-3
+func parseMPReach(b []byte) (*corebgppb.MPReachNLRI, error) {
+	if len(b) < 5 || len(b) < 5+int(b[3]) {
+		return nil, errMalformedUpdate
+	}
+	afi, safi := binary.BigEndian.Uint16(b), b[2]
+	r := &corebgppb.MPReachNLRI{
+		Afi:  uint32(afi),
+		Safi: uint32(safi),
+	}
+	nh := b[4 : 4+int(b[3])]
+	switch len(nh) {
+	case net.IPv4len, net.IPv6len:
+		r.NextHops = []string{net.IP(nh).String()}
+	case 2 * net.IPv6len:
+		// global and link-local
+		r.NextHops = []string{net.IP(nh[:net.IPv6len]).String(),
+			net.IP(nh[net.IPv6len:]).String()}
+	}
+	if hasPrefixNLRI(afi, safi) {
+		nlri, err := decodePrefixes(b[5+int(b[3]):], afi)
+		if err != nil {
+			return nil, err
+		}
+		r.Nlri = nlri
+	}
+	return r, nil
+}
+
+// https://tools.ietf.org/html/rfc4760#section-4
+func parseMPUnreach(b []byte) (*corebgppb.MPUnreachNLRI, error) {
+	if len(b) < 3 {
+		return nil, errMalformedUpdate
+	}
+	afi, safi := binary.BigEndian.Uint16(b), b[2]
+	u := &corebgppb.MPUnreachNLRI{
+		Afi:  uint32(afi),
+		Safi: uint32(safi),
+	}
+	if hasPrefixNLRI(afi, safi) {
+		withdrawn, err := decodePrefixes(b[3:], afi)
+		if err != nil {
+			return nil, err
+		}
+		u.Withdrawn = withdrawn
+	}
+	return u, nil
+}
+
+func parseAttr(u *corebgppb.Update, attrType uint8, value []byte,
+	fourOctetAS bool) error {
+	switch attrType {
+	case corebgp.AttrTypeOrigin:
+		if len(value) != 1 {
+			return errMalformedUpdate
+		}
+		origin := uint32(value[0])
+		u.Origin = &origin
+	case corebgp.AttrTypeASPath:
+		path, err := corebgp.DecodeASPath(value, fourOctetAS)
+		if err != nil {
+			return err
+		}
+		for _, seg := range path {
+			u.AsPath = append(u.AsPath, &corebgppb.ASPathSegment{
+				Type: uint32(seg.Type),
+				Asns: seg.ASNs,
+			})
+		}
+	case corebgp.AttrTypeNextHop:
+		if len(value) != net.IPv4len {
+			return errMalformedUpdate
+		}
+		u.NextHop = net.IP(value).String()
+	case corebgp.AttrTypeMED, corebgp.AttrTypeLocalPref:
+		if len(value) != 4 {
+			return errMalformedUpdate
+		}
+		v := binary.BigEndian.Uint32(value)
+		if attrType == corebgp.AttrTypeMED {
+			u.Med = &v
+		} else {
+			u.LocalPref = &v
+		}
+	case corebgp.AttrTypeCommunities:
+		if len(value)%4 != 0 {
+			return errMalformedUpdate
+		}
+		for i := 0; i < len(value); i += 4 {
+			u.Communities = append(u.Communities,
+				binary.BigEndian.Uint32(value[i:]))
+		}
+	case corebgp.AttrTypeMPReachNLRI:
+		r, err := parseMPReach(value)
+		if err != nil {
+			return err
+		}
+		u.MpReach = r
+	case corebgp.AttrTypeMPUnreachNLRI:
+		r, err := parseMPUnreach(value)
+		if err != nil {
+			return err
+		}
+		u.MpUnreach = r
+	}
+	return nil
+}
+
+// parseUpdate parses an UPDATE message body. All path attributes are included
+// in the returned Update's attributes, with well-known ones also parsed into
+// their respective fields.
+// https://tools.ietf.org/html/rfc4271#section-4.3
+func parseUpdate(b []byte, fourOctetAS bool) (*corebgppb.Update, error) {
+	if len(b) < 4 {
+		return nil, errMalformedUpdate
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 4+withdrawnLen {
+		return nil, errMalformedUpdate
+	}
+	withdrawn, err := decodePrefixes(b[2:2+withdrawnLen], corebgp.AFIIPv4)
+	if err != nil {
+		return nil, err
+	}
+	b = b[2+withdrawnLen:]
+	attrsLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+attrsLen {
+		return nil, errMalformedUpdate
+	}
+	attrs := b[2 : 2+attrsLen]
+	nlri, err := decodePrefixes(b[2+attrsLen:], corebgp.AFIIPv4)
+	if err != nil {
+		return nil, err
+	}
+	u := &corebgppb.Update{
+		Withdrawn: withdrawn,
+		Nlri:      nlri,
+	}
+	for len(attrs) > 0 {
+		if len(attrs) < 3 {
+			return nil, errMalformedUpdate
+		}
+		flags, attrType := corebgp.AttrFlags(attrs[0]), attrs[1]
+		headerLen, valueLen := 3, int(attrs[2])
+		if flags.ExtendedLength() {
+			if len(attrs) < 4 {
+				return nil, errMalformedUpdate
+			}
+			headerLen, valueLen = 4, int(binary.BigEndian.Uint16(attrs[2:]))
+		}
+		if len(attrs) < headerLen+valueLen {
+			return nil, errMalformedUpdate
+		}
+		value := attrs[headerLen : headerLen+valueLen]
+		err = parseAttr(u, attrType, value, fourOctetAS)
+		if err != nil {
+			return nil, err
+		}
+		u.Attributes = append(u.Attributes, &corebgppb.PathAttribute{
+			Flags: uint32(flags),
+			Type:  uint32(attrType),
+			Value: value,
+		})
+		attrs = attrs[headerLen+valueLen:]
+	}
+	return u, nil
+}
+
+// updateToProto converts an UpdateReceivedEvent to an Update. If the UPDATE
+// message cannot be parsed only error and raw are set.
+func updateToProto(e *corebgp.UpdateReceivedEvent,
+	includeRaw bool) *corebgppb.Update {
+	u, err := parseUpdate(e.Update, e.FourOctetAS)
+	if err != nil {
+		u = &corebgppb.Update{
+			Raw:   e.Update,
+			Error: err.Error(),
+		}
+	} else if includeRaw {
+		u.Raw = e.Update
+	}
+	u.TimeUnixNano = e.Time.UnixNano()
+	u.Address = e.Peer.String()
+	u.Key = e.Key.String()
+	return u
+}
@@ -0,0 +1,115 @@
+package corebgp
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultHistorySize is the default number of sessions retained in a peer's
+// SessionHistory.
+const DefaultHistorySize = 16
+
+// HistorySize returns a PeerOption that sets the number of sessions, and of
+// establishment attempts, retained in the peer's PeerStatus.SessionHistory
+// and PeerStatus.EstablishmentAttempts, which defaults to DefaultHistorySize.
+// Zero disables session history.
+func HistorySize(n int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if n < 0 {
+			n = 0
+		}
+		o.historySize = n
+	})
+}
+
+// SessionRecord describes an established session with a peer.
+type SessionRecord struct {
+	EstablishedAt time.Time
+	// ClosedAt is zero if the session is established.
+	ClosedAt time.Time
+	Inbound  bool
+	// Err is the error that closed the session, if any.
+	Err *PeerError
+}
+
+// PeerError describes an error encountered by a peer's FSM, e.g. the reason a
+// session was closed or could not be established.
+type PeerError struct {
+	Time    time.Time
+	Inbound bool
+	// State is the state of the FSM when the error occurred.
+	State FSMState
+	Err   string
+	// Notification is non-nil if the error resulted from a NOTIFICATION
+	// message, in which case NotificationSent is true if it was sent to the
+	// peer rather than received.
+	Notification     *Notification
+	NotificationSent bool
+}
+
+func newPeerError(i int, state FSMState, err error) *PeerError {
+	e := &PeerError{
+		Time:    time.Now(),
+		Inbound: i == in,
+		State:   state,
+		Err:     err.Error(),
+	}
+	var nerr *notificationError
+	if errors.As(err, &nerr) {
+		e.Notification = nerr.notification
+		e.NotificationSent = nerr.out
+	}
+	return e
+}
+
+// recordEstablished appends a SessionRecord for session to the history,
+// p.statusMu must be held.
+func (p *peer) recordEstablished(session *SessionInfo) {
+	size := p.options.historySize
+	if size == 0 {
+		return
+	}
+	if len(p.history) >= size {
+		p.history = append(p.history[:0:0], p.history[len(p.history)-size+1:]...)
+	}
+	p.history = append(p.history, &SessionRecord{
+		EstablishedAt: session.EstablishedAt,
+		Inbound:       session.Inbound,
+	})
+}
+
+// recordError records err encountered by FSM i in the given state, p.statusMu
+// must be held.
+func (p *peer) recordError(i int, state FSMState, err error) {
+	p.lastError = newPeerError(i, state, err)
+	if state == EstablishedState && len(p.history) > 0 {
+		r := p.history[len(p.history)-1]
+		if r.ClosedAt.IsZero() && r.Err == nil {
+			r.Err = p.lastError
+		}
+	}
+}
+
+// recordClosed records the close of the latest session, p.statusMu must be
+// held.
+func (p *peer) recordClosed() {
+	if len(p.history) > 0 {
+		r := p.history[len(p.history)-1]
+		if r.ClosedAt.IsZero() {
+			r.ClosedAt = time.Now()
+		}
+	}
+}
+
+// sessionHistory returns a copy of the session history, p.statusMu must be
+// held.
+func (p *peer) sessionHistory() []SessionRecord {
+	if len(p.history) == 0 {
+		return nil
+	}
+	h := make([]SessionRecord, 0, len(p.history))
+	for _, r := range p.history {
+		h = append(h, *r)
+	}
+	return h
+}
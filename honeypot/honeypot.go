@@ -0,0 +1,236 @@
+// Package honeypot implements a BGP listener that completes the OPEN and
+// KEEPALIVE handshake with arbitrary connecting speakers and records what
+// they send, for research into BGP scanning and misconfigured peers.
+//
+// A Honeypot is a collector.Collector that advertises nothing beyond its
+// capabilities, combined with a Recorder receiving a Record for every
+// connection, OPEN message, established session, UPDATE message,
+// NOTIFICATION message, error and closed session:
+//
+//	f, err := os.Create("honeypot.json")
+//	if err != nil {
+//		...
+//	}
+//	h, err := honeypot.New(net.ParseIP("192.0.2.1"), 65000,
+//		honeypot.NewJSONRecorder(f))
+//	if err != nil {
+//		...
+//	}
+//	lis, err := net.Listen("tcp", ":179")
+//	if err != nil {
+//		...
+//	}
+//	go h.Serve(lis)
+//	defer h.Close()
+package honeypot
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/collector"
+)
+
+// RecordType is the type of a Record.
+type RecordType uint8
+
+// RecordType values
+const (
+	// RecordConnect is recorded when a connection is accepted.
+	RecordConnect RecordType = iota
+	// RecordOpen is recorded when an OPEN message is received, prior to its
+	// validation.
+	RecordOpen
+	// RecordEstablished is recorded when a session is established.
+	RecordEstablished
+	// RecordUpdate is recorded when an UPDATE message is received.
+	RecordUpdate
+	// RecordNotification is recorded when a NOTIFICATION message is
+	// received.
+	RecordNotification
+	// RecordError is recorded when a connection fails prior to establishing
+	// a session, e.g. due to an invalid OPEN message.
+	RecordError
+	// RecordClose is recorded when an established session is closed.
+	RecordClose
+)
+
+func (t RecordType) String() string {
+	switch t {
+	case RecordConnect:
+		return "connect"
+	case RecordOpen:
+		return "open"
+	case RecordEstablished:
+		return "established"
+	case RecordUpdate:
+		return "update"
+	case RecordNotification:
+		return "notification"
+	case RecordError:
+		return "error"
+	case RecordClose:
+		return "close"
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t RecordType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Record is an observation of a Honeypot. Fields irrelevant to the Type are
+// empty.
+type Record struct {
+	Time time.Time  `json:"time"`
+	Type RecordType `json:"type"`
+	Peer net.IP     `json:"peer"`
+	// Open is the OPEN message of a RecordOpen.
+	Open *corebgp.OpenMessage `json:"open,omitempty"`
+	// Session is the session of a RecordEstablished or RecordClose.
+	Session *corebgp.SessionInfo `json:"session,omitempty"`
+	// Update is the UPDATE message body of a RecordUpdate.
+	Update []byte `json:"update,omitempty"`
+	// Notification is the NOTIFICATION message of a RecordNotification.
+	Notification *corebgp.Notification `json:"notification,omitempty"`
+	// Error describes the error of a RecordError.
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder records Records. Record may be called concurrently, and Records
+// of different types may be recorded out of order with respect to their
+// Time.
+type Recorder interface {
+	Record(r *Record)
+}
+
+// RecorderFunc is an adapter allowing a function to be used as a Recorder.
+type RecorderFunc func(r *Record)
+
+func (f RecorderFunc) Record(r *Record) {
+	f(r)
+}
+
+type jsonRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONRecorder returns a Recorder writing Records to w as newline
+// delimited JSON. Write errors are ignored.
+func NewJSONRecorder(w io.Writer) Recorder {
+	return &jsonRecorder{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (j *jsonRecorder) Record(r *Record) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(r)
+}
+
+// Honeypot is a BGP listener recording the behavior of connecting speakers.
+type Honeypot struct {
+	collector *collector.Collector
+	recorder  Recorder
+	sub       *corebgp.Subscription
+	doneCh    chan struct{}
+}
+
+// New returns a Honeypot with BGP Identifier routerID in localAS, passing
+// Records to recorder. opts are applied to the underlying Collector, which
+// by default does not advertise Graceful Restart.
+func New(routerID net.IP, localAS uint32, recorder Recorder,
+	opts ...collector.Option) (*Honeypot, error) {
+	h := &Honeypot{
+		recorder: recorder,
+		doneCh:   make(chan struct{}),
+	}
+	tap := collector.Tap{
+		OnPeerUp: func(t time.Time, s *collector.Session) {
+			h.record(t, RecordEstablished, s.Peer, &Record{
+				Session: s.SessionInfo,
+			})
+		},
+		OnUpdate: func(t time.Time, s *collector.Session, update []byte) {
+			h.record(t, RecordUpdate, s.Peer, &Record{
+				Update: update,
+			})
+		},
+		OnPeerDown: func(t time.Time, s *collector.Session) {
+			h.record(t, RecordClose, s.Peer, &Record{
+				Session: s.SessionInfo,
+			})
+		},
+	}
+	opts = append([]collector.Option{
+		collector.RestartTime(0),
+		collector.Sinks(tap),
+	}, opts...)
+	c, err := collector.New(routerID, localAS, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.collector = c
+	h.sub = c.Server().Subscribe(1024)
+	go h.run()
+	return h, nil
+}
+
+func (h *Honeypot) record(t time.Time, recordType RecordType, peer net.IP,
+	r *Record) {
+	r.Time = t
+	r.Type = recordType
+	r.Peer = peer
+	h.recorder.Record(r)
+}
+
+func (h *Honeypot) run() {
+	defer close(h.doneCh)
+	for e := range h.sub.C() {
+		switch e := e.(type) {
+		case *corebgp.StateChangeEvent:
+			if e.Inbound && e.From == corebgp.DisabledState &&
+				e.To != corebgp.DisabledState {
+				h.record(e.Time, RecordConnect, e.Peer, &Record{})
+			}
+		case *corebgp.OpenReceivedEvent:
+			h.record(e.Time, RecordOpen, e.Peer, &Record{
+				Open: e.Open,
+			})
+		case *corebgp.NotificationReceivedEvent:
+			h.record(e.Time, RecordNotification, e.Peer, &Record{
+				Notification: e.Notification,
+			})
+		case *corebgp.EstablishmentFailedEvent:
+			h.record(e.Time, RecordError, e.Peer, &Record{
+				Error: e.Err.Error(),
+			})
+		}
+	}
+}
+
+// Server returns the underlying Server.
+func (h *Honeypot) Server() *corebgp.Server {
+	return h.collector.Server()
+}
+
+// Serve accepts connections on lis, blocking until Close is called or lis
+// fails.
+func (h *Honeypot) Serve(lis net.Listener) error {
+	return h.collector.Serve(lis)
+}
+
+// Close closes all sessions, recording any remaining Records.
+func (h *Honeypot) Close() error {
+	err := h.collector.Close()
+	h.sub.Close()
+	<-h.doneCh
+	return err
+}
@@ -0,0 +1,658 @@
+// Package httpapi provides an http.Handler exposing the status of a
+// corebgp.Server's peers as JSON along with endpoints to reset, disable and
+// enable them.
+//
+// The handler serves the following paths, relative to where it is mounted:
+//
+//	GET  /peers                    summaries of all peers
+//	GET  /peers/{address}          detail of a peer
+//	GET  /peers/{address}/messages message history of a peer
+//	POST /peers/{address}/reset    reset a peer
+//	POST /peers/{address}/disable  disable a peer
+//	POST /peers/{address}/enable   enable a disabled peer
+//
+// Peers sharing an address, e.g. in different VRFs or on different ports, are
+// selected by the vrf, port and as query parameters of the per-peer paths,
+// which identify the peer by its corebgp.PeerKey, e.g.
+//
+//	GET  /peers/192.0.2.1?vrf=blue&as=65001
+//
+// Without them, a path matches the peer with the address in any VRF, and
+// responds with 409 Conflict if several share it.
+//
+// Use http.StripPrefix to mount it below a path of an existing mux, e.g.
+//
+//	mux.Handle("/bgp/", http.StripPrefix("/bgp", httpapi.NewHandler(srv)))
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// PeerSummary is the JSON representation of a peer returned by GET /peers.
+type PeerSummary struct {
+	Key                    string `json:"key"`
+	Address                string `json:"address"`
+	VRF                    string `json:"vrf,omitempty"`
+	Port                   int    `json:"port,omitempty"`
+	LocalAS                uint32 `json:"local_as"`
+	RemoteAS               uint32 `json:"remote_as"`
+	State                  string `json:"state"`
+	AdminDisabled          bool   `json:"admin_disabled"`
+	UptimeSeconds          uint64 `json:"uptime_seconds"`
+	UpdatesReceived        uint64 `json:"updates_received"`
+	UpdatesSent            uint64 `json:"updates_sent"`
+	EstablishedTransitions uint64 `json:"established_transitions"`
+}
+
+// PeerOptions is the JSON representation of a peer's options.
+type PeerOptions struct {
+	HoldTimeSeconds     uint64 `json:"hold_time_seconds"`
+	IdleHoldTimeSeconds uint64 `json:"idle_hold_time_seconds"`
+	Passive             bool   `json:"passive"`
+	LocalAddress        string `json:"local_address,omitempty"`
+	Port                int    `json:"port"`
+	DynamicCapability   bool   `json:"dynamic_capability"`
+	AllowASIn           *int   `json:"allow_as_in,omitempty"`
+	ASOverride          bool   `json:"as_override"`
+	UpdateRateAlarm     int    `json:"update_rate_alarm,omitempty"`
+}
+
+// Capability is the JSON representation of a capability.
+type Capability struct {
+	Code  uint8  `json:"code"`
+	Value []byte `json:"value"`
+}
+
+// Session is the JSON representation of an established session.
+type Session struct {
+	Inbound         bool         `json:"inbound"`
+	LocalAddress    string       `json:"local_address"`
+	RemoteAddress   string       `json:"remote_address"`
+	Interface       string       `json:"interface,omitempty"`
+	MSS             int          `json:"mss,omitempty"`
+	RemoteID        string       `json:"remote_id"`
+	HoldTimeSeconds uint64       `json:"hold_time_seconds"`
+	FourOctetAS     bool         `json:"four_octet_as"`
+	Capabilities    []Capability `json:"capabilities"`
+	EstablishedAt   time.Time    `json:"established_at"`
+}
+
+// Counters is the JSON representation of a peer's message counters.
+type Counters struct {
+	MessagesReceived         uint64 `json:"messages_received"`
+	MessagesSent             uint64 `json:"messages_sent"`
+	UpdatesReceived          uint64 `json:"updates_received"`
+	UpdatesSent              uint64 `json:"updates_sent"`
+	NotificationsReceived    uint64 `json:"notifications_received"`
+	NotificationsSent        uint64 `json:"notifications_sent"`
+	EstablishedTransitions   uint64 `json:"established_transitions"`
+	UpdatesRateLimited       uint64 `json:"updates_rate_limited"`
+	UpdatesDropped           uint64 `json:"updates_dropped"`
+	HoldTimerExtensions      uint64 `json:"hold_timer_extensions"`
+	InvalidMarkers           uint64 `json:"invalid_markers"`
+	UpdateErrorsIgnored      uint64 `json:"update_errors_ignored"`
+	UpdatesTreatedAsWithdraw uint64 `json:"updates_treated_as_withdraw"`
+	OversizedMessages        uint64 `json:"oversized_messages"`
+	UpdatesFiltered          uint64 `json:"updates_filtered"`
+	MemoryBudgetExceeded     uint64 `json:"memory_budget_exceeded"`
+	UpdatesDeduplicated      uint64 `json:"updates_deduplicated"`
+}
+
+// PeerError is the JSON representation of an error encountered by a peer.
+type PeerError struct {
+	Time             time.Time             `json:"time"`
+	Inbound          bool                  `json:"inbound"`
+	State            string                `json:"state"`
+	Error            string                `json:"error"`
+	Notification     *corebgp.Notification `json:"notification,omitempty"`
+	NotificationSent bool                  `json:"notification_sent,omitempty"`
+}
+
+// DialFailure is the JSON representation of a failed outbound connection
+// attempt.
+type DialFailure struct {
+	Time  time.Time `json:"time"`
+	Cause string    `json:"cause"`
+	Error string    `json:"error"`
+}
+
+// Dial is the JSON representation of a peer's outbound connection attempts.
+type Dial struct {
+	Attempts    uint64       `json:"attempts"`
+	Failures    uint64       `json:"failures"`
+	LastAttempt *time.Time   `json:"last_attempt,omitempty"`
+	LastFailure *DialFailure `json:"last_failure,omitempty"`
+	NextAttempt *time.Time   `json:"next_attempt,omitempty"`
+}
+
+// Timers is the JSON representation of a peer's effective timer values.
+type Timers struct {
+	HoldTimeSeconds           uint64     `json:"hold_time_seconds"`
+	NegotiatedHoldTimeSeconds uint64     `json:"negotiated_hold_time_seconds"`
+	KeepAliveIntervalSeconds  uint64     `json:"keepalive_interval_seconds"`
+	IdleHoldTimeSeconds       uint64     `json:"idle_hold_time_seconds"`
+	ConnectRetryTimeSeconds   uint64     `json:"connect_retry_time_seconds"`
+	DampingDelaySeconds       uint64     `json:"damping_delay_seconds"`
+	DampedUntil               *time.Time `json:"damped_until,omitempty"`
+}
+
+// Memory is the JSON representation of the bytes of messages buffered for a
+// peer.
+type Memory struct {
+	InboundQueueBytes   int64 `json:"inbound_queue_bytes"`
+	OutboundQueueBytes  int64 `json:"outbound_queue_bytes"`
+	MessageHistoryBytes int64 `json:"message_history_bytes"`
+	ReadBufferBytes     int64 `json:"read_buffer_bytes"`
+}
+
+// KeepAlive is the JSON representation of the KEEPALIVE messages received
+// from a peer and its round-trip time. Durations are in microseconds.
+type KeepAlive struct {
+	Received         uint64     `json:"received"`
+	LastReceived     *time.Time `json:"last_received,omitempty"`
+	IntervalUS       int64      `json:"interval_us"`
+	IntervalJitterUS int64      `json:"interval_jitter_us"`
+	OpenRTTUS        int64      `json:"open_rtt_us"`
+	RTTUS            int64      `json:"rtt_us"`
+	RTTVarUS         int64      `json:"rtt_var_us"`
+	MinRTTUS         int64      `json:"min_rtt_us"`
+}
+
+// TCPStats is the JSON representation of the statistics of a peer's TCP
+// connection. Durations are in microseconds.
+type TCPStats struct {
+	Time               time.Time `json:"time"`
+	RTTUS              int64     `json:"rtt_us"`
+	RTTVarUS           int64     `json:"rtt_var_us"`
+	MinRTTUS           int64     `json:"min_rtt_us"`
+	RTOUS              int64     `json:"rto_us"`
+	CongestionWindow   uint32    `json:"congestion_window"`
+	SlowStartThreshold uint32    `json:"slow_start_threshold"`
+	MSS                uint32    `json:"mss"`
+	Unacked            uint32    `json:"unacked"`
+	Lost               uint32    `json:"lost"`
+	Retransmits        uint32    `json:"retransmits"`
+	SendQueueBytes     int       `json:"send_queue_bytes"`
+	ReceiveQueueBytes  int       `json:"receive_queue_bytes"`
+}
+
+// SessionRecord is the JSON representation of a session in a peer's history.
+type SessionRecord struct {
+	EstablishedAt time.Time  `json:"established_at"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+	Inbound       bool       `json:"inbound"`
+	Error         *PeerError `json:"error,omitempty"`
+}
+
+// EstablishmentAttempt is the JSON representation of the timeline of an
+// attempt to establish a session with a peer. Durations are in milliseconds.
+type EstablishmentAttempt struct {
+	Inbound           bool       `json:"inbound"`
+	Started           time.Time  `json:"started"`
+	Connected         *time.Time `json:"connected,omitempty"`
+	OpenSent          *time.Time `json:"open_sent,omitempty"`
+	OpenReceived      *time.Time `json:"open_received,omitempty"`
+	Established       *time.Time `json:"established,omitempty"`
+	Error             *PeerError `json:"error,omitempty"`
+	DurationMS        int64      `json:"duration_ms"`
+	ConnectDurationMS int64      `json:"connect_duration_ms"`
+	OpenDurationMS    int64      `json:"open_duration_ms"`
+}
+
+// Message is the JSON representation of a message in a peer's message
+// history returned by GET /peers/{address}/messages.
+type Message struct {
+	Time    time.Time `json:"time"`
+	Sent    bool      `json:"sent"`
+	Type    uint8     `json:"type"`
+	Message []byte    `json:"message"`
+}
+
+// PeerDetail is the JSON representation of a peer returned by
+// GET /peers/{address}.
+type PeerDetail struct {
+	Key            string          `json:"key"`
+	Address        string          `json:"address"`
+	VRF            string          `json:"vrf,omitempty"`
+	Port           int             `json:"port,omitempty"`
+	LocalAS        uint32          `json:"local_as"`
+	RemoteAS       uint32          `json:"remote_as"`
+	Options        PeerOptions     `json:"options"`
+	State          string          `json:"state"`
+	AdminDisabled  bool            `json:"admin_disabled"`
+	UptimeSeconds  uint64          `json:"uptime_seconds"`
+	Session        *Session        `json:"session,omitempty"`
+	Counters       Counters        `json:"counters"`
+	SessionHistory []SessionRecord `json:"session_history"`
+	LastError      *PeerError      `json:"last_error,omitempty"`
+	Dial           Dial            `json:"dial"`
+
+	EstablishmentAttempts []EstablishmentAttempt `json:"establishment_attempts"`
+	Timers                Timers                 `json:"timers"`
+	Memory                Memory                 `json:"memory"`
+	KeepAlive             KeepAlive              `json:"keepalive"`
+	TCP                   *TCPStats              `json:"tcp,omitempty"`
+}
+
+func newPeerSummary(s corebgp.PeerStatus) PeerSummary {
+	return PeerSummary{
+		Key:                    s.Key().String(),
+		Address:                s.Config.IP.String(),
+		VRF:                    s.Options.VRF,
+		Port:                   s.Options.Port,
+		LocalAS:                s.Config.LocalAS,
+		RemoteAS:               s.Config.RemoteAS,
+		State:                  s.State.String(),
+		AdminDisabled:          s.AdminDisabled,
+		UptimeSeconds:          uint64(s.Uptime / time.Second),
+		UpdatesReceived:        s.Counters.UpdatesReceived,
+		UpdatesSent:            s.Counters.UpdatesSent,
+		EstablishedTransitions: s.Counters.EstablishedTransitions,
+	}
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func newPeerError(e *corebgp.PeerError) *PeerError {
+	if e == nil {
+		return nil
+	}
+	return &PeerError{
+		Time:             e.Time,
+		Inbound:          e.Inbound,
+		State:            e.State.String(),
+		Error:            e.Err,
+		Notification:     e.Notification,
+		NotificationSent: e.NotificationSent,
+	}
+}
+
+func newDial(s corebgp.DialStatus) Dial {
+	d := Dial{
+		Attempts: s.Attempts,
+		Failures: s.Failures,
+	}
+	if !s.LastAttempt.IsZero() {
+		lastAttempt := s.LastAttempt
+		d.LastAttempt = &lastAttempt
+	}
+	if s.LastFailure != nil {
+		d.LastFailure = &DialFailure{
+			Time:  s.LastFailure.Time,
+			Cause: s.LastFailure.Cause.String(),
+			Error: s.LastFailure.Err,
+		}
+	}
+	if !s.NextAttempt.IsZero() {
+		nextAttempt := s.NextAttempt
+		d.NextAttempt = &nextAttempt
+	}
+	return d
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func newEstablishmentAttempt(
+	t corebgp.EstablishmentTimeline) EstablishmentAttempt {
+	return EstablishmentAttempt{
+		Inbound:           t.Inbound,
+		Started:           t.Started,
+		Connected:         optionalTime(t.Connected),
+		OpenSent:          optionalTime(t.OpenSent),
+		OpenReceived:      optionalTime(t.OpenReceived),
+		Established:       optionalTime(t.Established),
+		Error:             newPeerError(t.Err),
+		DurationMS:        t.Duration().Milliseconds(),
+		ConnectDurationMS: t.ConnectDuration().Milliseconds(),
+		OpenDurationMS:    t.OpenDuration().Milliseconds(),
+	}
+}
+
+func newTimers(s corebgp.TimerStatus) Timers {
+	return Timers{
+		HoldTimeSeconds:           uint64(s.HoldTime / time.Second),
+		NegotiatedHoldTimeSeconds: uint64(s.NegotiatedHoldTime / time.Second),
+		KeepAliveIntervalSeconds:  uint64(s.KeepAliveInterval / time.Second),
+		IdleHoldTimeSeconds:       uint64(s.IdleHoldTime / time.Second),
+		ConnectRetryTimeSeconds:   uint64(s.ConnectRetryTime / time.Second),
+		DampingDelaySeconds:       uint64(s.DampingDelay / time.Second),
+		DampedUntil:               optionalTime(s.DampedUntil),
+	}
+}
+
+func newKeepAlive(s corebgp.KeepAliveStats) KeepAlive {
+	return KeepAlive{
+		Received:         s.Received,
+		LastReceived:     optionalTime(s.LastReceived),
+		IntervalUS:       s.Interval.Microseconds(),
+		IntervalJitterUS: s.IntervalJitter.Microseconds(),
+		OpenRTTUS:        s.OpenRTT.Microseconds(),
+		RTTUS:            s.RTT.Microseconds(),
+		RTTVarUS:         s.RTTVar.Microseconds(),
+		MinRTTUS:         s.MinRTT.Microseconds(),
+	}
+}
+
+func newTCPStats(s *corebgp.TCPStats) *TCPStats {
+	if s == nil {
+		return nil
+	}
+	return &TCPStats{
+		Time:               s.Time,
+		RTTUS:              s.RTT.Microseconds(),
+		RTTVarUS:           s.RTTVar.Microseconds(),
+		MinRTTUS:           s.MinRTT.Microseconds(),
+		RTOUS:              s.RTO.Microseconds(),
+		CongestionWindow:   s.CongestionWindow,
+		SlowStartThreshold: s.SlowStartThreshold,
+		MSS:                s.MSS,
+		Unacked:            s.Unacked,
+		Lost:               s.Lost,
+		Retransmits:        s.Retransmits,
+		SendQueueBytes:     s.SendQueue,
+		ReceiveQueueBytes:  s.ReceiveQueue,
+	}
+}
+
+func newPeerDetail(s corebgp.PeerStatus) PeerDetail {
+	d := PeerDetail{
+		Key:      s.Key().String(),
+		Address:  s.Config.IP.String(),
+		VRF:      s.Options.VRF,
+		Port:     s.Options.Port,
+		LocalAS:  s.Config.LocalAS,
+		RemoteAS: s.Config.RemoteAS,
+		Options: PeerOptions{
+			HoldTimeSeconds:     uint64(s.Options.HoldTime / time.Second),
+			IdleHoldTimeSeconds: uint64(s.Options.IdleHoldTime / time.Second),
+			Passive:             s.Options.Passive,
+			Port:                s.Options.Port,
+			DynamicCapability:   s.Options.DynamicCapability,
+			ASOverride:          s.Options.ASOverride,
+			UpdateRateAlarm:     s.Options.UpdateRateAlarm,
+		},
+		State:          s.State.String(),
+		AdminDisabled:  s.AdminDisabled,
+		UptimeSeconds:  uint64(s.Uptime / time.Second),
+		Counters:       Counters(s.Counters),
+		SessionHistory: make([]SessionRecord, 0, len(s.SessionHistory)),
+		LastError:      newPeerError(s.LastError),
+		Dial:           newDial(s.Dial),
+
+		EstablishmentAttempts: make([]EstablishmentAttempt, 0,
+			len(s.EstablishmentAttempts)),
+		Timers: newTimers(s.Timers),
+		Memory: Memory{
+			InboundQueueBytes:   s.Memory.InboundQueue,
+			OutboundQueueBytes:  s.Memory.OutboundQueue,
+			MessageHistoryBytes: s.Memory.MessageHistory,
+			ReadBufferBytes:     s.Memory.ReadBuffer,
+		},
+		KeepAlive: newKeepAlive(s.KeepAlive),
+		TCP:       newTCPStats(s.TCP),
+	}
+	for _, t := range s.EstablishmentAttempts {
+		d.EstablishmentAttempts = append(d.EstablishmentAttempts,
+			newEstablishmentAttempt(t))
+	}
+	for _, r := range s.SessionHistory {
+		record := SessionRecord{
+			EstablishedAt: r.EstablishedAt,
+			Inbound:       r.Inbound,
+			Error:         newPeerError(r.Err),
+		}
+		if !r.ClosedAt.IsZero() {
+			closedAt := r.ClosedAt
+			record.ClosedAt = &closedAt
+		}
+		d.SessionHistory = append(d.SessionHistory, record)
+	}
+	if s.Options.LocalAddress != nil {
+		d.Options.LocalAddress = s.Options.LocalAddress.String()
+	}
+	if s.Options.ASLoopCheck {
+		allowASIn := s.Options.AllowASIn
+		d.Options.AllowASIn = &allowASIn
+	}
+	if s.Session != nil {
+		d.Session = &Session{
+			Inbound:         s.Session.Inbound,
+			LocalAddress:    addrString(s.Session.LocalAddr),
+			RemoteAddress:   addrString(s.Session.RemoteAddr),
+			Interface:       s.Session.Interface,
+			MSS:             s.Session.MSS,
+			RemoteID:        s.Session.RemoteID.String(),
+			HoldTimeSeconds: uint64(s.Session.HoldTime / time.Second),
+			FourOctetAS:     s.Session.FourOctetAS,
+			Capabilities:    make([]Capability, 0, len(s.Session.Capabilities)),
+			EstablishedAt:   s.Session.EstablishedAt,
+		}
+		for _, c := range s.Session.Capabilities {
+			d.Session.Capabilities = append(d.Session.Capabilities, Capability{
+				Code:  c.Code,
+				Value: c.Value,
+			})
+		}
+	}
+	return d
+}
+
+type handler struct {
+	server *corebgp.Server
+}
+
+// NewHandler returns an http.Handler serving the status of server's peers and
+// handling requests to reset, disable and enable them.
+func NewHandler(server *corebgp.Server) http.Handler {
+	return &handler{
+		server: server,
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &errorResponse{Error: err.Error()})
+}
+
+func checkMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed,
+			errors.New("method not allowed"))
+		return false
+	}
+	return true
+}
+
+// peerRef is the peer a request refers to, by its key if byKey is true or
+// otherwise by the IP address of key alone.
+type peerRef struct {
+	key   corebgp.PeerKey
+	byKey bool
+}
+
+// parsePeerRef parses the address of a per-peer path and the query
+// parameters of r selecting among peers sharing it.
+func parsePeerRef(address string, r *http.Request) (peerRef, error) {
+	ref := peerRef{
+		key: corebgp.PeerKey{
+			IP: net.ParseIP(address),
+		},
+	}
+	if ref.key.IP == nil {
+		return ref, errors.New("invalid address")
+	}
+	q := r.URL.Query()
+	if vrf, ok := q["vrf"]; ok {
+		ref.key.VRF = vrf[0]
+		ref.byKey = true
+	}
+	if port := q.Get("port"); port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return ref, errors.New("invalid port")
+		}
+		ref.key.Port = int(n)
+		ref.byKey = true
+	}
+	if as := q.Get("as"); as != "" {
+		n, err := strconv.ParseUint(as, 10, 32)
+		if err != nil {
+			return ref, errors.New("invalid as")
+		}
+		ref.key.RemoteAS = uint32(n)
+		ref.byKey = true
+	}
+	return ref, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "peers" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		if checkMethod(w, r, http.MethodGet) {
+			h.listPeers(w)
+		}
+		return
+	}
+	ref, err := parsePeerRef(parts[1], r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(parts) == 2 {
+		if checkMethod(w, r, http.MethodGet) {
+			h.getPeer(w, ref)
+		}
+		return
+	}
+	if parts[2] == "messages" {
+		if checkMethod(w, r, http.MethodGet) {
+			h.getMessages(w, ref)
+		}
+		return
+	}
+	var (
+		action      func(net.IP) error
+		actionByKey func(corebgp.PeerKey) error
+	)
+	switch parts[2] {
+	case "reset":
+		action, actionByKey = h.server.ResetPeer, h.server.ResetPeerByKey
+	case "disable":
+		action, actionByKey = h.server.DisablePeer, h.server.DisablePeerByKey
+	case "enable":
+		action, actionByKey = h.server.EnablePeer, h.server.EnablePeerByKey
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+	if ref.byKey {
+		err = actionByKey(ref.key)
+	} else {
+		err = action(ref.key.IP)
+	}
+	if err != nil {
+		writePeerError(w, err)
+		return
+	}
+	h.getPeer(w, ref)
+}
+
+func writePeerError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, corebgp.ErrPeerNotExist):
+		code = http.StatusNotFound
+	case errors.Is(err, corebgp.ErrPeerAmbiguous):
+		code = http.StatusConflict
+	}
+	writeError(w, code, err)
+}
+
+func (h *handler) listPeers(w http.ResponseWriter) {
+	peers := h.server.ListPeers()
+	summaries := make([]PeerSummary, 0, len(peers))
+	for _, p := range peers {
+		summaries = append(summaries, newPeerSummary(p))
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (h *handler) getPeer(w http.ResponseWriter, ref peerRef) {
+	var (
+		p   corebgp.PeerStatus
+		err error
+	)
+	if ref.byKey {
+		p, err = h.server.GetPeerByKey(ref.key)
+	} else {
+		p, err = h.server.GetPeer(ref.key.IP)
+	}
+	if err != nil {
+		writePeerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPeerDetail(p))
+}
+
+func (h *handler) getMessages(w http.ResponseWriter, ref peerRef) {
+	var (
+		history []corebgp.RecordedMessage
+		err     error
+	)
+	if ref.byKey {
+		history, err = h.server.PeerMessageHistoryByKey(ref.key)
+	} else {
+		history, err = h.server.PeerMessageHistory(ref.key.IP)
+	}
+	if err != nil {
+		writePeerError(w, err)
+		return
+	}
+	messages := make([]Message, 0, len(history))
+	for _, m := range history {
+		messages = append(messages, Message{
+			Time:    m.Time,
+			Sent:    m.Sent,
+			Type:    m.Type,
+			Message: m.Message,
+		})
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
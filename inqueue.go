@@ -0,0 +1,177 @@
+package corebgp
+
+import (
+	"sync/atomic"
+)
+
+// QueueOverflowPolicy determines the handling of UPDATE messages received
+// while a peer's inbound queue is full.
+type QueueOverflowPolicy uint8
+
+// QueueOverflowPolicy values
+const (
+	// QueueBlock stops processing messages from the peer until there is room
+	// in the queue, applying TCP backpressure to the peer.
+	QueueBlock QueueOverflowPolicy = iota
+	// QueueDropOldest drops the oldest queued UPDATE message to make room.
+	// Dropped messages are counted by PeerCounters.UpdatesDropped.
+	QueueDropOldest
+	// QueueTeardown closes the session with a Cease NOTIFICATION with the Out
+	// of Resources subcode.
+	QueueTeardown
+)
+
+func (p QueueOverflowPolicy) String() string {
+	switch p {
+	case QueueBlock:
+		return "block"
+	case QueueDropOldest:
+		return "drop-oldest"
+	case QueueTeardown:
+		return "teardown"
+	}
+	return "unknown"
+}
+
+// InboundQueue returns a PeerOption that queues UPDATE messages received
+// from a peer for up to capacity messages, passing them to the
+// UpdateMessageHandler from a separate goroutine. KEEPALIVE and other
+// messages continue to be processed while the handler is busy, and may
+// therefore be handled ahead of queued UPDATE messages. policy determines
+// the handling of UPDATE messages received while the queue is full.
+func InboundQueue(capacity int, policy QueueOverflowPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if capacity < 1 {
+			capacity = 1
+		}
+		o.inboundQueueCapacity = capacity
+		o.inboundQueuePolicy = policy
+	})
+}
+
+// inboundQueue passes UPDATE messages to an UpdateMessageHandler from a
+// separate goroutine for the lifetime of an Established state.
+type inboundQueue struct {
+	ch      chan updateMessage
+	notifCh chan *Notification
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	// freedCh is signaled when a message is taken from ch
+	freedCh chan struct{}
+	// usage is the bytes queued, updated atomically
+	usage *int64
+}
+
+func (f *fsm) startInboundQueue(handler UpdateMessageHandler) *inboundQueue {
+	q := &inboundQueue{
+		ch:      make(chan updateMessage, f.peer.options.inboundQueueCapacity),
+		notifCh: make(chan *Notification),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+		freedCh: make(chan struct{}, 1),
+		usage:   &f.peer.memory.inboundQueue,
+	}
+	go func() {
+		defer close(q.doneCh)
+		for {
+			select {
+			case <-q.stopCh:
+				return
+			case u := <-q.ch:
+				q.taken(u)
+				n := f.handleUpdate(handler, u)
+				if n != nil {
+					select {
+					case <-q.stopCh:
+					case q.notifCh <- n:
+					}
+					return
+				}
+			}
+		}
+	}()
+	return q
+}
+
+// stop stops the queue, waiting for any running handler to return. Queued
+// messages are discarded.
+func (q *inboundQueue) stop() {
+	close(q.stopCh)
+	<-q.doneCh
+	for {
+		select {
+		case u := <-q.ch:
+			q.taken(u)
+		default:
+			return
+		}
+	}
+}
+
+// taken accounts for u being taken from the queue.
+func (q *inboundQueue) taken(u updateMessage) {
+	atomic.AddInt64(q.usage, -int64(len(u)))
+	select {
+	case q.freedCh <- struct{}{}:
+	default:
+	}
+}
+
+// put queues u if the queue has room, returning true if it did.
+func (q *inboundQueue) put(u updateMessage, budget int) bool {
+	if !fitsBudget(atomic.LoadInt64(q.usage), len(u), budget) {
+		return false
+	}
+	// the usage is accounted for before u may be taken
+	atomic.AddInt64(q.usage, int64(len(u)))
+	select {
+	case q.ch <- u:
+		return true
+	default:
+		atomic.AddInt64(q.usage, -int64(len(u)))
+		return false
+	}
+}
+
+// enqueue queues u according to the overflow policy. If ok is false the FSM
+// must transition to the returned state.
+func (f *fsm) enqueue(q *inboundQueue, u updateMessage) (bool, FSMState,
+	error) {
+	budget := f.peer.options.memoryBudget.InboundQueue
+	if q.put(u, budget) {
+		return true, 0, nil
+	}
+	if !fitsBudget(atomic.LoadInt64(q.usage), len(u), budget) {
+		f.peer.counters.budgetExceeded()
+	}
+	switch f.peer.options.inboundQueuePolicy {
+	case QueueBlock:
+		for !q.put(u, budget) {
+			select {
+			case <-q.freedCh:
+			case <-f.closeCh:
+				n := f.ceaseNotification()
+				f.sendNotification(n)
+				return false, DisabledState, newNotificationError(n, true)
+			case n := <-q.notifCh:
+				f.sendNotification(n)
+				return false, IdleState, newNotificationError(n, true)
+			}
+		}
+		return true, 0, nil
+	case QueueDropOldest:
+		for !q.put(u, budget) {
+			select {
+			case dropped := <-q.ch:
+				q.taken(dropped)
+				atomic.AddUint64(&f.peer.counters.updatesDropped, 1)
+			default:
+			}
+		}
+		return true, 0, nil
+	}
+	logf("[%s] inbound queue full, closing session", f.peer.config.IP)
+	n := newNotification(NotifCodeCease, NotifSubcodeOutOfResources, nil)
+	f.sendNotification(n)
+	return false, IdleState, newNotificationError(n, true)
+}
@@ -0,0 +1,60 @@
+package corebgp
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// KeepAliveJitter returns a PeerOption that randomly shortens each keepalive
+// interval by up to fraction of its length, avoiding synchronized KEEPALIVE
+// transmission across many sessions. fraction is clamped to [0, 0.5].
+// RFC4271 suggests a fraction of 0.25.
+func KeepAliveJitter(fraction float64) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if fraction < 0 {
+			fraction = 0
+		}
+		if fraction > 0.5 {
+			fraction = 0.5
+		}
+		o.keepAliveJitter = fraction
+	})
+}
+
+// SuppressKeepAlives returns a PeerOption that suppresses KEEPALIVE messages
+// in the Established state while any other message has been sent to the peer
+// within the keepalive interval, as any message resets the peer's hold timer.
+// Without this option only UPDATE messages restart the keepalive interval.
+func SuppressKeepAlives() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.suppressKeepAlives = true
+	})
+}
+
+// keepAliveDelay returns the duration until the next KEEPALIVE message,
+// applying any jitter.
+func (f *fsm) keepAliveDelay() time.Duration {
+	d := f.keepAliveInterval
+	if j := f.peer.options.keepAliveJitter; j > 0 {
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	return d
+}
+
+// suppressKeepAlive is called upon keepalive timer expiry in the Established
+// state, returning true if a message was written via w within the keepalive
+// interval, in which case the keepalive timer is reset for the remainder of
+// the interval.
+func (f *fsm) suppressKeepAlive(w *updateMessageWriter) bool {
+	if !f.peer.options.suppressKeepAlives {
+		return false
+	}
+	since := time.Since(time.Unix(0, atomic.LoadInt64(&w.lastWrite)))
+	d := f.keepAliveDelay() - since
+	if d <= 0 {
+		return false
+	}
+	f.keepAliveTimer.Reset(d)
+	return true
+}
@@ -0,0 +1,115 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LintUpdate checks the UPDATE message body b, to be sent to a peer whose
+// session was negotiated with codec, for errors that would cause the peer to
+// reset the session or treat the update as a withdrawal (RFC7606). It checks
+// the length fields, prefixes, attribute flags and values of attributes
+// known to corebgp or registered via RegisterPathAttr, duplicate attributes,
+// the ascending order of attributes, and the presence of mandatory
+// attributes. A nil codec is equivalent to a session negotiating four-octet
+// AS numbers without ADD-PATH.
+func LintUpdate(b []byte, codec *Codec) error {
+	if codec == nil {
+		codec = &Codec{FourOctetAS: true}
+	}
+	d := newUpdateDecoder(codec, AddPathSend)
+
+	withdrawn, attrs, nlri, err := splitUpdate(b)
+	if err != nil {
+		return err
+	}
+	addPathIPv4 := d.addPath[addPathKey(AFIIPv4, SAFIUnicast)]
+	if _, err = decodeNLRI(withdrawn, AFIIPv4, addPathIPv4, nil); err != nil {
+		return fmt.Errorf("withdrawn routes: %v", err)
+	}
+	if _, err = decodeNLRI(nlri, AFIIPv4, addPathIPv4, nil); err != nil {
+		return fmt.Errorf("nlri: %v", err)
+	}
+
+	var (
+		seen     = make(map[uint8]bool)
+		prev     uint8
+		mpReach  bool
+		lintErr  error
+		attrErrf = func(attrType uint8, format string, a ...interface{}) {
+			lintErr = fmt.Errorf("attribute type %d: %s", attrType,
+				fmt.Sprintf(format, a...))
+		}
+	)
+	err = rangePathAttrs(attrs, func(flags AttrFlags, attrType uint8,
+		value, raw []byte) bool {
+		if seen[attrType] {
+			attrErrf(attrType, "duplicate attribute")
+			return false
+		}
+		// https://tools.ietf.org/html/rfc4271#section-5
+		if attrType < prev {
+			attrErrf(attrType, "not in ascending order after type %d", prev)
+			return false
+		}
+		seen[attrType] = true
+		prev = attrType
+		if t, ok := lookupPathAttrType(attrType); ok {
+			if _, err := d.decodeRegisteredAttr(t, flags, attrType,
+				value); err != nil {
+				attrErrf(attrType, "%v", err)
+				return false
+			}
+			return true
+		}
+		if err := ValidateAttrFlags(attrType, flags); err != nil {
+			lintErr = err
+			return false
+		}
+		switch attrType {
+		case AttrTypeMPReachNLRI, AttrTypeMPUnreachNLRI:
+			reach := attrType == AttrTypeMPReachNLRI
+			if _, err := d.decodeMPNLRI(value, reach); err != nil {
+				attrErrf(attrType, "%v", err)
+				return false
+			}
+			mpReach = mpReach || reach
+		default:
+			if d.checkAttr(attrType, value) != attrOK {
+				attrErrf(attrType, "malformed value")
+				return false
+			}
+		}
+		return true
+	})
+	if lintErr != nil {
+		return lintErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// https://tools.ietf.org/html/rfc4271#section-5.1
+	if len(nlri) > 0 || mpReach {
+		if !seen[AttrTypeOrigin] {
+			return errors.New("missing mandatory ORIGIN attribute")
+		}
+		if !seen[AttrTypeASPath] && !seen[AttrTypeBGPsecPath] {
+			return errors.New("missing mandatory AS_PATH attribute")
+		}
+		if len(nlri) > 0 && !seen[AttrTypeNextHop] {
+			return errors.New("missing mandatory NEXT_HOP attribute")
+		}
+	}
+	return nil
+}
+
+// LintUpdates returns a PeerOption that checks updates written to a peer via
+// LintUpdate, returning its error from UpdateMessageWriter.WriteUpdate
+// instead of sending a malformed update. Updates are checked after any
+// ASOverride is applied.
+func LintUpdates() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.lintUpdates = true
+	})
+}
@@ -0,0 +1,171 @@
+package lookingglass
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// JSONRoute is the JSON representation of a Route.
+type JSONRoute struct {
+	Peer       string    `json:"peer"`
+	PeerKey    string    `json:"peer_key,omitempty"`
+	Prefix     string    `json:"prefix"`
+	NextHop    string    `json:"next_hop,omitempty"`
+	ASPath     string    `json:"as_path"`
+	Attributes []byte    `json:"attributes,omitempty"`
+	Received   time.Time `json:"received"`
+	Best       bool      `json:"best"`
+}
+
+func newJSONRoute(r Route) JSONRoute {
+	j := JSONRoute{
+		Peer:       r.Peer.String(),
+		Prefix:     r.Prefix.String(),
+		ASPath:     r.ASPath.String(),
+		Attributes: r.Attributes,
+		Received:   r.Received,
+		Best:       r.Best,
+	}
+	if r.NextHop != nil {
+		j.NextHop = r.NextHop.String()
+	}
+	if r.PeerKey.IP != nil {
+		j.PeerKey = r.PeerKey.String()
+	}
+	return j
+}
+
+type handler struct {
+	rib RIB
+}
+
+// NewHandler returns an http.Handler answering looking glass queries against
+// rib.
+func NewHandler(rib RIB) http.Handler {
+	return &handler{
+		rib: rib,
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &errorResponse{Error: err.Error()})
+}
+
+func writeRoutes(w http.ResponseWriter, routes []Route, err error) {
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrPeerNotExist):
+			code = http.StatusNotFound
+		case errors.Is(err, corebgp.ErrPeerAmbiguous):
+			code = http.StatusConflict
+		}
+		writeError(w, code, err)
+		return
+	}
+	SortRoutes(routes)
+	j := make([]JSONRoute, 0, len(routes))
+	for _, r := range routes {
+		j = append(j, newJSONRoute(r))
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "routes":
+	case len(parts) == 3 && parts[0] == "peers" && parts[2] == "routes":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed,
+			errors.New("method not allowed"))
+		return
+	}
+	if len(parts) == 3 {
+		h.receivedRoutes(w, r, parts[1])
+		return
+	}
+	query := r.URL.Query()
+	prefix, err := ParsePrefix(query.Get("prefix"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	match := MatchLongest
+	if m := query.Get("match"); m != "" {
+		match, err = ParseMatch(m)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	routes, err := h.rib.LookupRoutes(prefix, match)
+	writeRoutes(w, routes, err)
+}
+
+// receivedRoutes writes the routes received from the peer of address,
+// selected by its key if r has any of the vrf, port and as query parameters.
+func (h *handler) receivedRoutes(w http.ResponseWriter, r *http.Request,
+	address string) {
+	k := corebgp.PeerKey{
+		IP: net.ParseIP(address),
+	}
+	if k.IP == nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid address"))
+		return
+	}
+	query := r.URL.Query()
+	_, byKey := query["vrf"]
+	k.VRF = query.Get("vrf")
+	if port := query.Get("port"); port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid port"))
+			return
+		}
+		k.Port, byKey = int(n), true
+	}
+	if as := query.Get("as"); as != "" {
+		n, err := strconv.ParseUint(as, 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid as"))
+			return
+		}
+		k.RemoteAS, byKey = uint32(n), true
+	}
+	if !byKey {
+		routes, err := h.rib.ReceivedRoutes(k.IP)
+		writeRoutes(w, routes, err)
+		return
+	}
+	keyed, ok := h.rib.(KeyedRIB)
+	if !ok {
+		writeError(w, http.StatusNotImplemented,
+			errors.New("rib does not identify peers by key"))
+		return
+	}
+	routes, err := keyed.ReceivedRoutesByKey(k)
+	writeRoutes(w, routes, err)
+}
@@ -0,0 +1,190 @@
+// Package lookingglass answers looking glass queries against a RIB provided
+// by the application. corebgp does not maintain a RIB itself; applications
+// implement the RIB interface over whatever route storage they keep and
+// expose it via the http.Handler returned by NewHandler, or via the gRPC
+// service in the grpcapi module.
+//
+// The handler serves the following paths, relative to where it is mounted:
+//
+//	GET /routes?prefix={prefix}[&match={match}] routes for a prefix
+//	GET /peers/{address}/routes                 routes received from a peer
+//
+// prefix may be a prefix in CIDR notation or an address, which is treated as
+// a host prefix. match is one of "exact", "longest" (default) or "longer".
+// Peers sharing an address are selected by the vrf, port and as query
+// parameters of the peer path, which identify the peer by its
+// corebgp.PeerKey if the RIB implements KeyedRIB.
+package lookingglass
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+var (
+	// ErrPeerNotExist is returned by a RIB when queried for routes of a peer
+	// it has no knowledge of.
+	ErrPeerNotExist = errors.New("peer does not exist")
+)
+
+// Match determines how routes are matched against a queried prefix.
+type Match uint8
+
+// Match values
+const (
+	// MatchLongest matches routes for the longest prefix containing the
+	// queried prefix, i.e. a routing table lookup.
+	MatchLongest Match = iota
+	// MatchExact matches routes for the queried prefix only.
+	MatchExact
+	// MatchLonger matches routes for the queried prefix and any more
+	// specific prefixes.
+	MatchLonger
+)
+
+func (m Match) String() string {
+	switch m {
+	case MatchLongest:
+		return "longest"
+	case MatchExact:
+		return "exact"
+	case MatchLonger:
+		return "longer"
+	}
+	return "unknown"
+}
+
+// ParseMatch parses the string representation of a Match.
+func ParseMatch(s string) (Match, error) {
+	for _, m := range []Match{MatchLongest, MatchExact, MatchLonger} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid match: %q", s)
+}
+
+// Route is a route held in a RIB.
+type Route struct {
+	// Peer is the address of the peer the route was received from, and
+	// PeerKey its key if the RIB tells apart peers sharing an address.
+	Peer    net.IP
+	PeerKey corebgp.PeerKey
+	Prefix  *net.IPNet
+	NextHop net.IP
+	ASPath  corebgp.ASPath
+	// Attributes are the encoded path attributes of the route. Applications
+	// may omit attributes represented by other fields.
+	Attributes []byte
+	Received   time.Time
+	// Best is true if the route was selected as best by the application.
+	Best bool
+}
+
+// RIB is implemented by applications to answer looking glass queries.
+// Implementations must be safe for concurrent use.
+type RIB interface {
+	// LookupRoutes returns the routes matching prefix according to match.
+	LookupRoutes(prefix *net.IPNet, match Match) ([]Route, error)
+	// ReceivedRoutes returns the routes received from peer. ErrPeerNotExist
+	// should be returned if peer is unknown.
+	ReceivedRoutes(peer net.IP) ([]Route, error)
+}
+
+// KeyedRIB is implemented by RIBs telling apart peers sharing an address,
+// e.g. in different VRFs, see corebgp.PeerKey.
+type KeyedRIB interface {
+	RIB
+	// ReceivedRoutesByKey returns the routes received from the peer matching
+	// k. ErrPeerNotExist should be returned if it is unknown, and
+	// corebgp.ErrPeerAmbiguous if several peers match.
+	ReceivedRoutesByKey(k corebgp.PeerKey) ([]Route, error)
+}
+
+// ParsePrefix parses s as a prefix in CIDR notation or as an address, which
+// is returned as a host prefix.
+func ParsePrefix(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := net.IPv6len * 8
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, net.IPv4len*8
+		}
+		return &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}, nil
+	}
+	_, prefix, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix: %q", s)
+	}
+	return prefix, nil
+}
+
+// contains returns true if a contains b.
+func contains(a, b *net.IPNet) bool {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	return aBits == bBits && aOnes <= bOnes && a.Contains(b.IP)
+}
+
+func prefixEqual(a, b *net.IPNet) bool {
+	return a.IP.Equal(b.IP) && bytes.Equal(a.Mask, b.Mask)
+}
+
+// Select returns the routes matching prefix according to match. It is
+// intended for RIB implementations that do not index routes by prefix.
+func Select(routes []Route, prefix *net.IPNet, match Match) []Route {
+	selected := make([]Route, 0)
+	longest := -1
+	for _, r := range routes {
+		if r.Prefix == nil {
+			continue
+		}
+		switch match {
+		case MatchExact:
+			if prefixEqual(r.Prefix, prefix) {
+				selected = append(selected, r)
+			}
+		case MatchLonger:
+			if contains(prefix, r.Prefix) {
+				selected = append(selected, r)
+			}
+		case MatchLongest:
+			if !contains(r.Prefix, prefix) {
+				continue
+			}
+			ones, _ := r.Prefix.Mask.Size()
+			if ones > longest {
+				longest = ones
+				selected = selected[:0]
+			}
+			if ones == longest {
+				selected = append(selected, r)
+			}
+		}
+	}
+	return selected
+}
+
+// SortRoutes sorts routes by prefix and then by peer.
+func SortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if c := bytes.Compare(a.Prefix.IP.To16(), b.Prefix.IP.To16()); c != 0 {
+			return c < 0
+		}
+		aOnes, _ := a.Prefix.Mask.Size()
+		bOnes, _ := b.Prefix.Mask.Size()
+		if aOnes != bOnes {
+			return aOnes < bOnes
+		}
+		return bytes.Compare(a.Peer.To16(), b.Peer.To16()) < 0
+	})
+}
@@ -0,0 +1,97 @@
+package corebgp
+
+import (
+	"sync/atomic"
+)
+
+// PeerMemoryBudget limits the bytes of messages buffered for a peer, see
+// MemoryBudget. A zero limit is unlimited. A single message exceeding a limit
+// is buffered if nothing else is, so that a peer can always make progress.
+// The fixed size buffer of a ReadBatcher is not limited, as it is allocated
+// once per connection, but is included in MemoryUsage.
+type PeerMemoryBudget struct {
+	// InboundQueue limits the UPDATE messages queued by InboundQueue. A queue
+	// exceeding it is handled as a full queue, according to the queue's
+	// QueueOverflowPolicy.
+	InboundQueue int
+	// OutboundQueue limits the UPDATE messages queued for the peer by a
+	// SharedWriteScheduler. A queue exceeding it is handled according to
+	// OutboundPolicy.
+	OutboundQueue int
+	// OutboundPolicy determines the handling of UPDATE messages written to
+	// the peer while its outbound queue exceeds OutboundQueue.
+	// QueueDropOldest is treated as QueueBlock, as dropping UPDATE messages
+	// would leave the peer with stale routes. QueueTeardown closes the
+	// session with a Cease NOTIFICATION with the Out of Resources subcode.
+	OutboundPolicy QueueOverflowPolicy
+	// MessageHistory limits the messages retained by MessageHistory. The
+	// oldest messages are evicted to make room.
+	MessageHistory int
+}
+
+// MemoryBudget returns a PeerOption that limits the bytes of messages
+// buffered for a peer, e.g. to keep a collector with thousands of peers
+// within predictable memory bounds. Enforcement of a limit is counted by
+// PeerCounters.MemoryBudgetExceeded. Bytes buffered are reported by
+// PeerStatus.Memory, and for all peers by ServerMetrics.Memory, regardless
+// of a budget.
+func MemoryBudget(budget PeerMemoryBudget) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.memoryBudget = budget
+	})
+}
+
+// MemoryUsage is the number of bytes of messages buffered for a peer, or for
+// all peers of a Server.
+type MemoryUsage struct {
+	// InboundQueue is the bytes of UPDATE messages queued by InboundQueue.
+	InboundQueue int64
+	// OutboundQueue is the bytes of UPDATE messages queued by a
+	// SharedWriteScheduler.
+	OutboundQueue int64
+	// MessageHistory is the bytes of messages retained by MessageHistory.
+	MessageHistory int64
+	// ReadBuffer is the bytes of the buffers of a ReadBatcher allocated for
+	// open connections.
+	ReadBuffer int64
+}
+
+// Total returns the total bytes buffered.
+func (u MemoryUsage) Total() int64 {
+	return u.InboundQueue + u.OutboundQueue + u.MessageHistory + u.ReadBuffer
+}
+
+func (u *MemoryUsage) add(o MemoryUsage) {
+	u.InboundQueue += o.InboundQueue
+	u.OutboundQueue += o.OutboundQueue
+	u.MessageHistory += o.MessageHistory
+	u.ReadBuffer += o.ReadBuffer
+}
+
+// memoryUsage is updated atomically by a peer's FSMs.
+type memoryUsage struct {
+	inboundQueue   int64
+	outboundQueue  int64
+	messageHistory int64
+	readBuffer     int64
+}
+
+func (m *memoryUsage) snapshot() MemoryUsage {
+	return MemoryUsage{
+		InboundQueue:   atomic.LoadInt64(&m.inboundQueue),
+		OutboundQueue:  atomic.LoadInt64(&m.outboundQueue),
+		MessageHistory: atomic.LoadInt64(&m.messageHistory),
+		ReadBuffer:     atomic.LoadInt64(&m.readBuffer),
+	}
+}
+
+// fitsBudget returns true if n bytes may be added to the used bytes of a
+// buffer with the given budget.
+func fitsBudget(used int64, n, budget int) bool {
+	return budget == 0 || used == 0 || used+int64(n) <= int64(budget)
+}
+
+// budgetExceeded counts the enforcement of a memory budget.
+func (c *peerCounters) budgetExceeded() {
+	atomic.AddUint64(&c.memoryBudgetExceeded, 1)
+}
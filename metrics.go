@@ -0,0 +1,63 @@
+package corebgp
+
+import (
+	"sync/atomic"
+)
+
+// serverMetrics is updated atomically by the FSMs of a Server's peers.
+type serverMetrics struct {
+	messagesIn      [256]uint64
+	messagesOut     [256]uint64
+	connectAttempts uint64
+	decodeErrors    uint64
+}
+
+// ServerMetrics are aggregate metrics of a Server. Counters include those of
+// peers that have since been deleted.
+type ServerMetrics struct {
+	// PeersByState is the number of peers by their most advanced FSM state.
+	PeersByState map[FSMState]int
+	// MessagesReceived is the number of messages received by message type.
+	MessagesReceived map[uint8]uint64
+	// MessagesSent is the number of messages sent by message type.
+	MessagesSent map[uint8]uint64
+	// ConnectAttempts is the number of outbound connection attempts.
+	ConnectAttempts uint64
+	// DecodeErrors is the number of received messages that could not be
+	// framed or decoded.
+	DecodeErrors uint64
+	// Memory is the bytes of messages buffered for the current peers.
+	Memory MemoryUsage
+}
+
+// Metrics returns the aggregate metrics of the Server.
+func (s *Server) Metrics() ServerMetrics {
+	m := ServerMetrics{
+		PeersByState:     make(map[FSMState]int),
+		MessagesReceived: make(map[uint8]uint64),
+		MessagesSent:     make(map[uint8]uint64),
+		ConnectAttempts:  atomic.LoadUint64(&s.metrics.connectAttempts),
+		DecodeErrors:     atomic.LoadUint64(&s.metrics.decodeErrors),
+	}
+	s.mu.Lock()
+	for _, p := range s.peers {
+		p.statusMu.Lock()
+		state := p.state[out]
+		if p.state[in] > state {
+			state = p.state[in]
+		}
+		p.statusMu.Unlock()
+		m.PeersByState[state]++
+		m.Memory.add(p.memory.snapshot())
+	}
+	s.mu.Unlock()
+	for i := range s.metrics.messagesIn {
+		if n := atomic.LoadUint64(&s.metrics.messagesIn[i]); n > 0 {
+			m.MessagesReceived[uint8(i)] = n
+		}
+		if n := atomic.LoadUint64(&s.metrics.messagesOut[i]); n > 0 {
+			m.MessagesSent[uint8(i)] = n
+		}
+	}
+	return m
+}
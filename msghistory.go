@@ -0,0 +1,134 @@
+package corebgp
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RecordedMessage is a message retained in a peer's message history.
+type RecordedMessage struct {
+	Time time.Time
+	// Sent is true if the message was sent to the peer rather than received.
+	Sent bool
+	Type uint8
+	// Message is the message including its header. The header of a received
+	// message is reconstructed from its type and length.
+	Message []byte
+}
+
+// MessageHistory returns a PeerOption that retains the last size messages
+// sent to and received from a peer, for debugging interoperability problems
+// after the fact. The messages may be retrieved via
+// Server.PeerMessageHistory. If onFailure is non-nil it is called with the
+// retained messages, oldest first, whenever an error closes a connection with
+// the peer. onFailure is called from the peer's goroutine and should not
+// block.
+func MessageHistory(size int, onFailure func(peer *PeerConfig,
+	messages []RecordedMessage)) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.messageHistorySize = size
+		o.onMessageHistoryFailure = onFailure
+	})
+}
+
+// messageHistory retains up to size RecordedMessages, and up to budget bytes
+// of them if budget is non-zero.
+type messageHistory struct {
+	mu       sync.Mutex
+	messages []RecordedMessage
+	size     int
+	budget   int
+	// usage is the bytes retained, updated atomically
+	usage    *int64
+	counters *peerCounters
+}
+
+func newMessageHistory(size, budget int, usage *int64,
+	counters *peerCounters) *messageHistory {
+	if size < 1 {
+		return nil
+	}
+	return &messageHistory{
+		messages: make([]RecordedMessage, 0, size),
+		size:     size,
+		budget:   budget,
+		usage:    usage,
+		counters: counters,
+	}
+}
+
+// record records message b, which must not be modified by the caller
+// afterwards. It is safe to call on a nil messageHistory.
+func (h *messageHistory) record(sent bool, b []byte) {
+	if h == nil || len(b) < HeaderLength {
+		return
+	}
+	m := RecordedMessage{
+		Time:    time.Now(),
+		Sent:    sent,
+		Type:    b[18],
+		Message: b,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for len(h.messages) > 0 && (len(h.messages) >= h.size ||
+		!fitsBudget(atomic.LoadInt64(h.usage), len(b), h.budget)) {
+		if len(h.messages) < h.size {
+			h.counters.budgetExceeded()
+		}
+		atomic.AddInt64(h.usage, -int64(len(h.messages[0].Message)))
+		h.messages[0] = RecordedMessage{}
+		h.messages = h.messages[1:]
+	}
+	h.messages = append(h.messages, m)
+	atomic.AddInt64(h.usage, int64(len(b)))
+}
+
+// snapshot returns the retained messages, oldest first.
+func (h *messageHistory) snapshot() []RecordedMessage {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]RecordedMessage(nil), h.messages...)
+}
+
+// historyWriter records messages written to w in a messageHistory. Each call
+// to Write must contain a single message.
+type historyWriter struct {
+	w io.Writer
+	h *messageHistory
+}
+
+func (w *historyWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	if err == nil {
+		w.h.record(true, append([]byte(nil), b...))
+	}
+	return n, err
+}
+
+// PeerMessageHistory returns the messages retained for the peer with IP
+// address ip via the MessageHistory PeerOption, oldest first.
+func (s *Server) PeerMessageHistory(ip net.IP) ([]RecordedMessage, error) {
+	p, err := s.peer(ip)
+	if err != nil {
+		return nil, err
+	}
+	return p.messages.snapshot(), nil
+}
+
+// PeerMessageHistoryByKey returns the messages retained for the peer matching
+// k, see PeerMessageHistory.
+func (s *Server) PeerMessageHistoryByKey(k PeerKey) ([]RecordedMessage,
+	error) {
+	p, err := s.peerByKey(k, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.messages.snapshot(), nil
+}
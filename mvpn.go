@@ -0,0 +1,319 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// RouteDistinguisher is a Route Distinguisher (RFC4364).
+type RouteDistinguisher [8]byte
+
+// Route Distinguisher type values
+// https://tools.ietf.org/html/rfc4364#section-4.2
+const (
+	RDTypeTwoOctetAS  uint16 = 0
+	RDTypeIPv4Address uint16 = 1
+	RDTypeFourOctetAS uint16 = 2
+)
+
+// Type returns the type field of the Route Distinguisher.
+func (rd RouteDistinguisher) Type() uint16 {
+	return binary.BigEndian.Uint16(rd[:])
+}
+
+// String returns the Route Distinguisher as administrator:assigned number,
+// or its type and value in hex for unknown types.
+func (rd RouteDistinguisher) String() string {
+	switch rd.Type() {
+	case RDTypeTwoOctetAS:
+		return fmt.Sprintf("%d:%d", binary.BigEndian.Uint16(rd[2:]),
+			binary.BigEndian.Uint32(rd[4:]))
+	case RDTypeIPv4Address:
+		return fmt.Sprintf("%s:%d", net.IP(rd[2:6]),
+			binary.BigEndian.Uint16(rd[6:]))
+	case RDTypeFourOctetAS:
+		return fmt.Sprintf("%d:%d", binary.BigEndian.Uint32(rd[2:]),
+			binary.BigEndian.Uint16(rd[6:]))
+	}
+	return fmt.Sprintf("%d:0x%x", rd.Type(), rd[2:])
+}
+
+// MCAST-VPN route type values
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#mcast-vpn
+const (
+	MVPNRouteTypeIntraASIPMSIAD uint8 = 1
+	MVPNRouteTypeInterASIPMSIAD uint8 = 2
+	MVPNRouteTypeSPMSIAD        uint8 = 3
+	MVPNRouteTypeLeafAD         uint8 = 4
+	MVPNRouteTypeSourceActiveAD uint8 = 5
+	MVPNRouteTypeSharedTreeJoin uint8 = 6
+	MVPNRouteTypeSourceTreeJoin uint8 = 7
+)
+
+const (
+	mvpnRouteTypeMax          = MVPNRouteTypeSourceTreeJoin
+	mvpnRouteHeaderLen        = 2
+	mvpnRouteDistinguisherLen = 8
+	mvpnMaxRouteLength        = 0xff
+)
+
+// MVPNRoute is an MCAST-VPN NLRI (RFC6514), carried by the MP_REACH_NLRI
+// and MP_UNREACH_NLRI attributes of AFI IPv4 or IPv6 and SAFI
+// SAFIMulticastVPN, whose NLRI a DecodedUpdate leaves in MPNLRI.RawNLRI.
+// The fields used depend on Type:
+//
+//	Intra-AS I-PMSI A-D:  RD, OriginatingRouter
+//	Inter-AS I-PMSI A-D:  RD, SourceAS
+//	S-PMSI A-D:           RD, Source, Group, OriginatingRouter
+//	Leaf A-D:             RouteKey, OriginatingRouter
+//	Source Active A-D:    RD, Source, Group
+//	Shared Tree Join:     RD, SourceAS, Source (the C-RP), Group
+//	Source Tree Join:     RD, SourceAS, Source, Group
+//
+// A nil Source or Group is a wildcard (RFC6625). Routes of unknown types
+// carry their value in Value.
+// https://tools.ietf.org/html/rfc6514#section-4
+type MVPNRoute struct {
+	Type              uint8
+	RD                RouteDistinguisher
+	OriginatingRouter net.IP
+	SourceAS          uint32
+	Source            net.IP
+	Group             net.IP
+	// RouteKey is the encoded NLRI a Leaf A-D route responds to, e.g. an
+	// S-PMSI A-D route, see LeafADRouteKey.
+	RouteKey []byte
+	Value    []byte
+}
+
+func (r *MVPNRoute) String() string {
+	switch r.Type {
+	case MVPNRouteTypeIntraASIPMSIAD:
+		return fmt.Sprintf("[1][%s][%s]", r.RD, r.OriginatingRouter)
+	case MVPNRouteTypeInterASIPMSIAD:
+		return fmt.Sprintf("[2][%s][%d]", r.RD, r.SourceAS)
+	case MVPNRouteTypeSPMSIAD:
+		return fmt.Sprintf("[3][%s][%s][%s][%s]", r.RD, mvpnAddr(r.Source),
+			mvpnAddr(r.Group), r.OriginatingRouter)
+	case MVPNRouteTypeLeafAD:
+		return fmt.Sprintf("[4][0x%x][%s]", r.RouteKey, r.OriginatingRouter)
+	case MVPNRouteTypeSourceActiveAD:
+		return fmt.Sprintf("[5][%s][%s][%s]", r.RD, mvpnAddr(r.Source),
+			mvpnAddr(r.Group))
+	case MVPNRouteTypeSharedTreeJoin, MVPNRouteTypeSourceTreeJoin:
+		return fmt.Sprintf("[%d][%s][%d][%s][%s]", r.Type, r.RD, r.SourceAS,
+			mvpnAddr(r.Source), mvpnAddr(r.Group))
+	}
+	return fmt.Sprintf("[%d][0x%x]", r.Type, r.Value)
+}
+
+func mvpnAddr(ip net.IP) string {
+	if ip == nil {
+		return "*"
+	}
+	return ip.String()
+}
+
+var errMalformedMVPNRoute = errors.New("malformed MCAST-VPN NLRI")
+
+// DecodeMVPNRoutes decodes the MCAST-VPN NLRI in b, e.g. MPNLRI.RawNLRI.
+// Values are copied from b.
+func DecodeMVPNRoutes(b []byte) ([]MVPNRoute, error) {
+	var routes []MVPNRoute
+	for len(b) > 0 {
+		if len(b) < mvpnRouteHeaderLen ||
+			len(b) < mvpnRouteHeaderLen+int(b[1]) {
+			return nil, errMalformedMVPNRoute
+		}
+		n := mvpnRouteHeaderLen + int(b[1])
+		var r MVPNRoute
+		if err := r.decode(b[0], b[mvpnRouteHeaderLen:n]); err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+		b = b[n:]
+	}
+	return routes, nil
+}
+
+// decodeMVPNAddr decodes a length in bits followed by an IPv4 or IPv6
+// address, or no address for a length of zero, returning the remainder of b.
+func decodeMVPNAddr(b []byte) (net.IP, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, errMalformedMVPNRoute
+	}
+	bits := int(b[0])
+	b = b[1:]
+	switch bits {
+	case 0:
+		return nil, b, nil
+	case 8 * net.IPv4len, 8 * net.IPv6len:
+	default:
+		return nil, nil, fmt.Errorf("invalid MCAST-VPN address length: %d",
+			bits)
+	}
+	if len(b) < bits/8 {
+		return nil, nil, errMalformedMVPNRoute
+	}
+	return append(net.IP(nil), b[:bits/8]...), b[bits/8:], nil
+}
+
+// decodeMVPNRouter decodes an Originating Router's IP Address field
+// spanning b.
+func decodeMVPNRouter(b []byte) (net.IP, error) {
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil, fmt.Errorf("invalid MCAST-VPN originating router "+
+			"length: %d", len(b))
+	}
+	return append(net.IP(nil), b...), nil
+}
+
+func (r *MVPNRoute) decode(routeType uint8, b []byte) error {
+	r.Type = routeType
+	if routeType == 0 || routeType > mvpnRouteTypeMax {
+		r.Value = append([]byte(nil), b...)
+		return nil
+	}
+	var err error
+	if routeType == MVPNRouteTypeLeafAD {
+		if len(b) < mvpnRouteHeaderLen ||
+			len(b) < mvpnRouteHeaderLen+int(b[1]) {
+			return errMalformedMVPNRoute
+		}
+		n := mvpnRouteHeaderLen + int(b[1])
+		r.RouteKey = append([]byte(nil), b[:n]...)
+		r.OriginatingRouter, err = decodeMVPNRouter(b[n:])
+		return err
+	}
+	if len(b) < mvpnRouteDistinguisherLen {
+		return errMalformedMVPNRoute
+	}
+	copy(r.RD[:], b)
+	b = b[mvpnRouteDistinguisherLen:]
+	switch routeType {
+	case MVPNRouteTypeIntraASIPMSIAD:
+		r.OriginatingRouter, err = decodeMVPNRouter(b)
+		return err
+	case MVPNRouteTypeInterASIPMSIAD:
+		if len(b) != 4 {
+			return errMalformedMVPNRoute
+		}
+		r.SourceAS = binary.BigEndian.Uint32(b)
+		return nil
+	case MVPNRouteTypeSharedTreeJoin, MVPNRouteTypeSourceTreeJoin:
+		if len(b) < 4 {
+			return errMalformedMVPNRoute
+		}
+		r.SourceAS = binary.BigEndian.Uint32(b)
+		b = b[4:]
+	}
+	r.Source, b, err = decodeMVPNAddr(b)
+	if err != nil {
+		return err
+	}
+	r.Group, b, err = decodeMVPNAddr(b)
+	if err != nil {
+		return err
+	}
+	if routeType == MVPNRouteTypeSPMSIAD {
+		r.OriginatingRouter, err = decodeMVPNRouter(b)
+		return err
+	}
+	if len(b) != 0 {
+		return errMalformedMVPNRoute
+	}
+	return nil
+}
+
+// LeafADRouteKey decodes the RouteKey of a Leaf A-D route as an MCAST-VPN
+// NLRI.
+func (r *MVPNRoute) LeafADRouteKey() (MVPNRoute, error) {
+	if r.Type != MVPNRouteTypeLeafAD {
+		return MVPNRoute{}, fmt.Errorf("mvpn route type %d is not leaf a-d",
+			r.Type)
+	}
+	routes, err := DecodeMVPNRoutes(r.RouteKey)
+	if err != nil {
+		return MVPNRoute{}, err
+	}
+	if len(routes) != 1 {
+		return MVPNRoute{}, errMalformedMVPNRoute
+	}
+	return routes[0], nil
+}
+
+func appendMVPNAddr(b []byte, ip net.IP) ([]byte, error) {
+	if ip == nil {
+		return append(b, 0), nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("invalid MCAST-VPN address: %s", ip)
+	}
+	b = append(b, uint8(8*len(ip)))
+	return append(b, ip...), nil
+}
+
+func appendMVPNRouter(b []byte, ip net.IP) ([]byte, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return append(b, ip4...), nil
+	}
+	if len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("invalid MCAST-VPN originating router: %s",
+			ip)
+	}
+	return append(b, ip...), nil
+}
+
+// Encode returns the MCAST-VPN NLRI of r, including its route type and
+// length, for the NLRI of an MP_REACH_NLRI or MP_UNREACH_NLRI attribute.
+// IPv4-mapped IPv6 addresses are encoded as IPv4 addresses.
+func (r *MVPNRoute) Encode() ([]byte, error) {
+	b := []byte{r.Type, 0}
+	var err error
+	switch r.Type {
+	case MVPNRouteTypeLeafAD:
+		if len(r.RouteKey) == 0 {
+			return nil, errors.New("leaf a-d route without route key")
+		}
+		b = append(b, r.RouteKey...)
+		b, err = appendMVPNRouter(b, r.OriginatingRouter)
+	case MVPNRouteTypeIntraASIPMSIAD:
+		b = append(b, r.RD[:]...)
+		b, err = appendMVPNRouter(b, r.OriginatingRouter)
+	case MVPNRouteTypeInterASIPMSIAD:
+		b = append(b, r.RD[:]...)
+		b = append(b, uint8(r.SourceAS>>24), uint8(r.SourceAS>>16),
+			uint8(r.SourceAS>>8), uint8(r.SourceAS))
+	case MVPNRouteTypeSPMSIAD, MVPNRouteTypeSourceActiveAD,
+		MVPNRouteTypeSharedTreeJoin, MVPNRouteTypeSourceTreeJoin:
+		b = append(b, r.RD[:]...)
+		if r.Type == MVPNRouteTypeSharedTreeJoin ||
+			r.Type == MVPNRouteTypeSourceTreeJoin {
+			b = append(b, uint8(r.SourceAS>>24), uint8(r.SourceAS>>16),
+				uint8(r.SourceAS>>8), uint8(r.SourceAS))
+		}
+		if b, err = appendMVPNAddr(b, r.Source); err != nil {
+			return nil, err
+		}
+		if b, err = appendMVPNAddr(b, r.Group); err != nil {
+			return nil, err
+		}
+		if r.Type == MVPNRouteTypeSPMSIAD {
+			b, err = appendMVPNRouter(b, r.OriginatingRouter)
+		}
+	default:
+		b = append(b, r.Value...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b)-mvpnRouteHeaderLen > mvpnMaxRouteLength {
+		return nil, errors.New("MCAST-VPN NLRI too long")
+	}
+	b[1] = uint8(len(b) - mvpnRouteHeaderLen)
+	return b, nil
+}
@@ -0,0 +1,217 @@
+//go:build go1.18
+// +build go1.18
+
+package corebgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// PrefixNLRI is a prefix of an NLRI field along with its path identifier
+// and labels, if any.
+type PrefixNLRI struct {
+	// PathID is the ADD-PATH (RFC7911) path identifier of the prefix. It is
+	// only encoded and decoded if NLRICodec.AddPath is true.
+	PathID uint32
+	// Labels are the 20 bit MPLS label values (RFC8277) of the prefix, top
+	// of the stack first. They are only encoded and decoded if
+	// NLRICodec.SAFI is SAFIMPLSLabel. The label field of withdrawals may
+	// consist of the single label WithdrawLabel.
+	Labels []uint32
+	Prefix netip.Prefix
+}
+
+// WithdrawLabel is the label of the label field 0x800000, which carries no
+// bottom of stack bit and is used in withdrawals of labeled prefixes for
+// compatibility with RFC3107.
+// https://tools.ietf.org/html/rfc8277#section-2.4
+const WithdrawLabel uint32 = 0x80000
+
+// maxLabel is the largest 20 bit MPLS label value.
+const maxLabel = 1<<20 - 1
+
+// NLRICodec encodes and decodes the prefixes of the NLRI and Withdrawn Routes
+// fields of an UPDATE message, and of the NLRI fields of MP_REACH_NLRI and
+// MP_UNREACH_NLRI attributes. The zero value with AFI set to AFIIPv4 is the
+// codec of the classic IPv4 unicast fields.
+//
+// Decoding is strict: a prefix length exceeding the address length of the
+// AFI, or bits set beyond the prefix length, result in an error.
+type NLRICodec struct {
+	// AFI is AFIIPv4 or AFIIPv6.
+	AFI uint16
+	// SAFI is SAFIUnicast, SAFIMulticast or SAFIMPLSLabel. The zero value is
+	// treated as SAFIUnicast.
+	SAFI uint8
+	// AddPath is true if each prefix is preceded by a path identifier, i.e.
+	// ADD-PATH was negotiated for the direction and AFI/SAFI.
+	AddPath bool
+}
+
+func (c NLRICodec) addrBits() (int, error) {
+	switch c.AFI {
+	case AFIIPv4:
+		return 32, nil
+	case AFIIPv6:
+		return 128, nil
+	}
+	return 0, fmt.Errorf("unsupported AFI: %d", c.AFI)
+}
+
+func (c NLRICodec) labeled() (bool, error) {
+	switch c.SAFI {
+	case 0, SAFIUnicast, SAFIMulticast:
+		return false, nil
+	case SAFIMPLSLabel:
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported SAFI: %d", c.SAFI)
+}
+
+// Decode decodes the prefixes of b.
+// https://tools.ietf.org/html/rfc4271#section-4.3
+// https://tools.ietf.org/html/rfc4760#section-5
+// https://tools.ietf.org/html/rfc7911#section-3
+// https://tools.ietf.org/html/rfc8277#section-2
+func (c NLRICodec) Decode(b []byte) ([]PrefixNLRI, error) {
+	addrBits, err := c.addrBits()
+	if err != nil {
+		return nil, err
+	}
+	labeled, err := c.labeled()
+	if err != nil {
+		return nil, err
+	}
+	var nlri []PrefixNLRI
+	for len(b) > 0 {
+		var n PrefixNLRI
+		if c.AddPath {
+			if len(b) < 4 {
+				return nil, fmt.Errorf("%w: truncated path identifier",
+					errMalformedNLRI)
+			}
+			n.PathID = binary.BigEndian.Uint32(b)
+			b = b[4:]
+		}
+		if len(b) < 1 {
+			return nil, fmt.Errorf("%w: missing prefix length",
+				errMalformedNLRI)
+		}
+		bits := int(b[0])
+		b = b[1:]
+		if labeled {
+			for {
+				if bits < 24 || len(b) < 3 {
+					return nil, fmt.Errorf("%w: truncated label stack",
+						errMalformedNLRI)
+				}
+				v := uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+				n.Labels = append(n.Labels, v>>4)
+				bits -= 24
+				b = b[3:]
+				if v&1 != 0 || v == WithdrawLabel<<4 {
+					break
+				}
+			}
+		}
+		n.Prefix, b, err = decodePrefix(b, bits, addrBits)
+		if err != nil {
+			return nil, err
+		}
+		nlri = append(nlri, n)
+	}
+	return nlri, nil
+}
+
+// decodePrefix decodes a prefix of length bits from the start of b, returning
+// the remainder of b.
+func decodePrefix(b []byte, bits, addrBits int) (netip.Prefix, []byte,
+	error) {
+	if bits > addrBits {
+		return netip.Prefix{}, nil, fmt.Errorf(
+			"%w: prefix length %d exceeds %d", errMalformedNLRI, bits,
+			addrBits)
+	}
+	octets := (bits + 7) / 8
+	if len(b) < octets {
+		return netip.Prefix{}, nil, fmt.Errorf("%w: truncated prefix",
+			errMalformedNLRI)
+	}
+	if bits%8 != 0 && b[octets-1]&(0xff>>uint(bits%8)) != 0 {
+		return netip.Prefix{}, nil, fmt.Errorf(
+			"%w: bits set beyond prefix length %d", errMalformedNLRI, bits)
+	}
+	var addr netip.Addr
+	if addrBits == 32 {
+		var a [4]byte
+		copy(a[:], b[:octets])
+		addr = netip.AddrFrom4(a)
+	} else {
+		var a [16]byte
+		copy(a[:], b[:octets])
+		addr = netip.AddrFrom16(a)
+	}
+	return netip.PrefixFrom(addr, bits), b[octets:], nil
+}
+
+// Append appends the encoding of nlri to b. An error is returned if a prefix
+// is invalid, not of the AFI, or has bits set beyond its length, or if a
+// label exceeds 20 bits or a labeled prefix lacks labels. WithdrawLabel is
+// encoded without bottom of stack bit, and must be the only label.
+func (c NLRICodec) Append(b []byte, nlri ...PrefixNLRI) ([]byte, error) {
+	addrBits, err := c.addrBits()
+	if err != nil {
+		return nil, err
+	}
+	labeled, err := c.labeled()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nlri {
+		p := n.Prefix
+		if !p.IsValid() || p.Addr().BitLen() != addrBits ||
+			p.Addr().Zone() != "" {
+			return nil, fmt.Errorf("invalid prefix for AFI %d: %s", c.AFI, p)
+		}
+		if p.Masked() != p {
+			return nil, fmt.Errorf("prefix %s has bits set beyond its length",
+				p)
+		}
+		if c.AddPath {
+			b = append(b, uint8(n.PathID>>24), uint8(n.PathID>>16),
+				uint8(n.PathID>>8), uint8(n.PathID))
+		}
+		bits := p.Bits()
+		if !labeled {
+			b = append(b, uint8(bits))
+		} else {
+			if len(n.Labels) == 0 {
+				return nil, fmt.Errorf("labeled prefix %s without labels", p)
+			}
+			bits += 24 * len(n.Labels)
+			if bits > 255 {
+				return nil, fmt.Errorf("too many labels for prefix %s", p)
+			}
+			b = append(b, uint8(bits))
+			for i, l := range n.Labels {
+				if l > maxLabel || l == WithdrawLabel && len(n.Labels) > 1 {
+					return nil, fmt.Errorf("invalid label: %d", l)
+				}
+				v := l << 4
+				if i == len(n.Labels)-1 && l != WithdrawLabel {
+					v |= 1 // bottom of stack
+				}
+				b = append(b, uint8(v>>16), uint8(v>>8), uint8(v))
+			}
+		}
+		b = append(b, p.Addr().AsSlice()[:(p.Bits()+7)/8]...)
+	}
+	return b, nil
+}
+
+// Encode returns the encoding of nlri.
+func (c NLRICodec) Encode(nlri ...PrefixNLRI) ([]byte, error) {
+	return c.Append(nil, nlri...)
+}
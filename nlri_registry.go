@@ -0,0 +1,59 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// NLRIType describes the NLRI codec of an AFI/SAFI registered via
+// RegisterNLRI, e.g. of a draft or vendor SAFI.
+type NLRIType struct {
+	// Name is the name of the AFI/SAFI, e.g. for logging.
+	Name string
+	// Decode decodes the NLRI field of an MP_REACH_NLRI or MP_UNREACH_NLRI
+	// attribute of the AFI/SAFI exchanged with a peer whose session was
+	// negotiated with codec, returning an error if it is malformed. addPath
+	// is true if the NLRI are preceded by path identifiers (RFC7911). b must
+	// not be retained unless copied.
+	Decode func(b []byte, addPath bool, codec *Codec) (interface{}, error)
+	// Encode encodes a value returned by Decode as an NLRI field. It may be
+	// nil, in which case MPNLRI.RawNLRI is encoded.
+	Encode func(nlri interface{}, addPath bool) ([]byte, error)
+}
+
+var (
+	nlriRegistryMu sync.RWMutex
+	nlriRegistry   = make(map[uint32]NLRIType)
+)
+
+// RegisterNLRI registers the NLRI type of afi/safi, so that the MPNLRI of a
+// DecodedUpdate carries its decoded NLRI in MPNLRI.Decoded. An NLRI field
+// Decode fails on is handled as a malformed MP_REACH_NLRI or MP_UNREACH_NLRI
+// attribute, resetting the session (RFC7606 section 7.11). The IPv4 and IPv6
+// unicast and multicast SAFIs decoded by corebgp itself, and AFI/SAFIs
+// already registered, cannot be registered. It is typically called from an
+// init function, e.g. registering DecodeMVPNRoutes for the MCAST-VPN SAFI.
+func RegisterNLRI(afi uint16, safi uint8, t NLRIType) error {
+	if t.Decode == nil {
+		return errors.New("nlri decoder must not be nil")
+	}
+	if isPrefixSAFI(afi, safi) {
+		return fmt.Errorf("afi %d safi %d is decoded by corebgp", afi, safi)
+	}
+	nlriRegistryMu.Lock()
+	defer nlriRegistryMu.Unlock()
+	key := addPathKey(afi, safi)
+	if _, exists := nlriRegistry[key]; exists {
+		return fmt.Errorf("afi %d safi %d already registered", afi, safi)
+	}
+	nlriRegistry[key] = t
+	return nil
+}
+
+func lookupNLRIType(afi uint16, safi uint8) (NLRIType, bool) {
+	nlriRegistryMu.RLock()
+	defer nlriRegistryMu.RUnlock()
+	t, ok := nlriRegistry[addPathKey(afi, safi)]
+	return t, ok
+}
@@ -0,0 +1,200 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// The methods in this file decode the Data field of a Notification for
+// diagnostic purposes. Each returns an error if the Notification code and
+// subcode do not match, or if the data is malformed.
+
+func (n *Notification) checkCode(code uint8, subcodes ...uint8) error {
+	if n.Code == code {
+		for _, s := range subcodes {
+			if n.Subcode == s {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unexpected notification %s (%d/%d)",
+		lookupNotifCodeName(n.Code), n.Code, n.Subcode)
+}
+
+func (n *Notification) checkDataLen(length int) error {
+	if len(n.Data) != length {
+		return fmt.Errorf("invalid %s notification data length: %d",
+			lookupNotifDesc(n.Code, n.Subcode), len(n.Data))
+	}
+	return nil
+}
+
+// BadMessageLength returns the erroneous length field carried by a Message
+// Header Error/Bad Message Length Notification.
+// https://tools.ietf.org/html/rfc4271#section-6.1
+func (n *Notification) BadMessageLength() (uint16, error) {
+	err := n.checkCode(NotifCodeMessageHeaderErr, NotifSubcodeBadLength)
+	if err != nil {
+		return 0, err
+	}
+	err = n.checkDataLen(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(n.Data), nil
+}
+
+// BadMessageType returns the erroneous type field carried by a Message
+// Header Error/Bad Message Type Notification.
+// https://tools.ietf.org/html/rfc4271#section-6.1
+func (n *Notification) BadMessageType() (uint8, error) {
+	err := n.checkCode(NotifCodeMessageHeaderErr, NotifSubcodeBadType)
+	if err != nil {
+		return 0, err
+	}
+	err = n.checkDataLen(1)
+	if err != nil {
+		return 0, err
+	}
+	return n.Data[0], nil
+}
+
+// SupportedVersion returns the largest locally supported version number
+// carried by an OPEN Message Error/Unsupported Version Number Notification.
+// https://tools.ietf.org/html/rfc4271#section-6.2
+func (n *Notification) SupportedVersion() (uint16, error) {
+	err := n.checkCode(NotifCodeOpenMessageErr,
+		NotifSubcodeUnsupportedVersionNumber)
+	if err != nil {
+		return 0, err
+	}
+	err = n.checkDataLen(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(n.Data), nil
+}
+
+// UnsupportedCapabilities returns the capabilities carried by an OPEN Message
+// Error/Unsupported Capability Notification.
+// https://tools.ietf.org/html/rfc5492#section-5
+func (n *Notification) UnsupportedCapabilities() ([]*Capability, error) {
+	err := n.checkCode(NotifCodeOpenMessageErr,
+		NotifSubcodeUnsupportedCapability)
+	if err != nil {
+		return nil, err
+	}
+	var caps []*Capability
+	b := n.Data
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, errors.New("malformed unsupported capability data")
+		}
+		caps = append(caps, &Capability{
+			Code:  b[0],
+			Value: b[2 : 2+int(b[1])],
+		})
+		b = b[2+int(b[1]):]
+	}
+	return caps, nil
+}
+
+// ErroneousAttribute returns the flags, type and value of the attribute
+// carried by an UPDATE Message Error Notification whose subcode identifies
+// a specific attribute.
+// https://tools.ietf.org/html/rfc4271#section-6.3
+func (n *Notification) ErroneousAttribute() (AttrFlags, uint8, []byte,
+	error) {
+	err := n.checkCode(NotifCodeUpdateMessageErr,
+		NotifSubcodeMalformedAttr,
+		NotifSubcodeUnrecognizedWellKnownAttr,
+		NotifSubcodeAttrFlagsError,
+		NotifSubcodeAttrLenError,
+		NotifSubcodeInvalidOrigin,
+		NotifSubcodeInvalidNextHop,
+		NotifSubcodeOptionalAttrError)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(n.Data) < 3 {
+		return 0, 0, nil, errors.New("malformed attribute data")
+	}
+	flags := AttrFlags(n.Data[0])
+	attrType := n.Data[1]
+	b := n.Data[2:]
+	var attrLen int
+	if flags.ExtendedLength() {
+		if len(b) < 2 {
+			return 0, 0, nil, errors.New("malformed attribute data")
+		}
+		attrLen = int(binary.BigEndian.Uint16(b))
+		b = b[2:]
+	} else {
+		attrLen = int(b[0])
+		b = b[1:]
+	}
+	// the attribute may have been truncated or carry an incorrect length
+	// (Attribute Length Error), so the value is whatever follows the header
+	if attrLen < len(b) {
+		b = b[:attrLen]
+	}
+	return flags, attrType, b, nil
+}
+
+// MissingAttributeType returns the attribute type code carried by an UPDATE
+// Message Error/Missing Well-known Attribute Notification.
+// https://tools.ietf.org/html/rfc4271#section-6.3
+func (n *Notification) MissingAttributeType() (uint8, error) {
+	err := n.checkCode(NotifCodeUpdateMessageErr,
+		NotifSubcodeMissingWellKnownAttr)
+	if err != nil {
+		return 0, err
+	}
+	err = n.checkDataLen(1)
+	if err != nil {
+		return 0, err
+	}
+	return n.Data[0], nil
+}
+
+// MaxPrefixes returns the AFI, SAFI and prefix upper bound carried by a
+// Cease/Maximum Number of Prefixes Reached Notification.
+// https://tools.ietf.org/html/rfc4486#section-4
+func (n *Notification) MaxPrefixes() (uint16, uint8, uint32, error) {
+	err := n.checkCode(NotifCodeCease, NotifSubcodeMaxPrefixes)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	err = n.checkDataLen(7)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return binary.BigEndian.Uint16(n.Data), n.Data[2],
+		binary.BigEndian.Uint32(n.Data[3:]), nil
+}
+
+// ShutdownCommunication returns the Shutdown Communication carried by a
+// Cease/Administrative Shutdown or Cease/Administrative Reset Notification.
+// An empty string is returned without error if no data is present.
+// https://tools.ietf.org/html/rfc9003#section-2
+func (n *Notification) ShutdownCommunication() (string, error) {
+	err := n.checkCode(NotifCodeCease, NotifSubcodeAdminShutdown,
+		NotifSubcodeAdminReset)
+	if err != nil {
+		return "", err
+	}
+	if len(n.Data) == 0 {
+		return "", nil
+	}
+	msgLen := int(n.Data[0])
+	if len(n.Data) < msgLen+1 {
+		return "", errors.New("malformed shutdown communication")
+	}
+	msg := n.Data[1 : 1+msgLen]
+	if !utf8.Valid(msg) {
+		return "", errors.New("shutdown communication is not valid UTF-8")
+	}
+	return string(msg), nil
+}
@@ -14,6 +14,11 @@ func newNotificationError(n *Notification, out bool) *notificationError {
 	}
 }
 
+// Unwrap returns the underlying Notification.
+func (n *notificationError) Unwrap() error {
+	return n.notification
+}
+
 func (n *notificationError) dampPeer() bool {
 	return n.notification.Code != NotifCodeCease
 }
@@ -23,9 +28,16 @@ func (n *notificationError) Error() string {
 	if n.out {
 		direction = "sent"
 	}
-	desc := lookupNotifDesc(n.notification.Code, n.notification.Subcode)
-	return fmt.Sprintf("notification %s '%s' code: %d subcode: %d",
-		direction, desc, n.notification.Code, n.notification.Subcode)
+	return fmt.Sprintf("notification %s %s", direction,
+		n.notification.String())
+}
+
+func lookupNotifCodeName(code uint8) string {
+	name, ok := notifCodeNames[code]
+	if !ok {
+		return "Unknown code"
+	}
+	return name
 }
 
 func lookupNotifDesc(code, subcode uint8) string {
@@ -38,6 +50,18 @@ func lookupNotifDesc(code, subcode uint8) string {
 }
 
 var (
+	// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#bgp-parameters-3
+	notifCodeNames = map[uint8]string{
+		NotifCodeMessageHeaderErr:       "Message Header Error",
+		NotifCodeOpenMessageErr:         "OPEN Message Error",
+		NotifCodeUpdateMessageErr:       "UPDATE Message Error",
+		NotifCodeHoldTimerExpired:       "Hold Timer Expired",
+		NotifCodeFSMErr:                 "Finite State Machine Error",
+		NotifCodeCease:                  "Cease",
+		NotifCodeRouteRefreshMessageErr: "ROUTE-REFRESH Message Error",
+		NotifCodeCapabilityMessageErr:   "CAPABILITY Message Error",
+	}
+
 	// most descriptions come from https://tools.ietf.org/html/rfc4271#section-4.5
 	notifDescs = []struct {
 		code        uint8
@@ -56,7 +80,9 @@ var (
 		{2, 4, "Unsupported optional parameter"},
 		{2, 6, "Unacceptable hold time"},
 		// https://tools.ietf.org/html/rfc5492#section-5
-		{2, 7, "Required capability missing"},
+		{2, 7, "Unsupported capability"},
+		// https://tools.ietf.org/html/rfc9234#section-4.2
+		{2, 11, "Role mismatch"},
 
 		{3, 0, "Invalid UPDATE message"},
 		{3, 1, "Malformed attribute list"},
@@ -88,9 +114,20 @@ var (
 		{6, 6, "Other configuration change"},
 		{6, 7, "Connection collision resolution"},
 		{6, 8, "Out of Resources"},
+		// https://tools.ietf.org/html/rfc8538#section-5.1
+		{6, 9, "Hard reset"},
+		// https://tools.ietf.org/html/rfc9384#section-2
+		{6, 10, "BFD down"},
 
 		// https://tools.ietf.org/html/rfc7313#section-5
 		{7, 0, "Invalid ROUTE-REFRESH message"},
 		{7, 1, "Invalid ROUTE-REFRESH message length"},
+
+		// https://tools.ietf.org/html/draft-ietf-idr-dynamic-cap
+		{9, 0, "Invalid CAPABILITY message"},
+		{9, 1, "Invalid action value"},
+		{9, 2, "Invalid capability length"},
+		{9, 3, "Malformed capability value"},
+		{9, 4, "Unsupported capability code"},
 	}
 )
@@ -0,0 +1,504 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"time"
+)
+
+// OpenMessage is an OPEN message as defined by RFC4271.
+type OpenMessage struct {
+	Version  uint8
+	ASN      uint16
+	HoldTime uint16
+	BGPID    uint32
+	// OptionalParams are the optional parameters of the message. When
+	// decoding, capabilities optional parameters are decoded as
+	// *CapabilityOptionalParam and all others as *UnknownOptionalParam.
+	OptionalParams []OptionalParam
+}
+
+func (o *OpenMessage) messageType() uint8 {
+	return OpenMessageType
+}
+
+// peerAS returns the AS of the sender, i.e. the AS of the four-octet AS
+// capability if present and valid, otherwise the My Autonomous System field.
+func (o *OpenMessage) peerAS() uint32 {
+	for _, c := range o.Capabilities() {
+		if c.Code == CapCodeFourOctetAS && len(c.Value) == 4 {
+			return binary.BigEndian.Uint32(c.Value)
+		}
+	}
+	return uint32(o.ASN)
+}
+
+// Capabilities returns the capabilities found in all capabilities optional
+// parameters of the message.
+func (o *OpenMessage) Capabilities() []*Capability {
+	caps := make([]*Capability, 0)
+	for _, param := range o.OptionalParams {
+		p, isCap := param.(*CapabilityOptionalParam)
+		if isCap {
+			caps = append(caps, p.Capabilities...)
+		}
+	}
+	return caps
+}
+
+// Decode decodes an OPEN message body, i.e. the message excluding the header.
+// Optional parameters may use the extended encoding defined by RFC9072.
+// Errors wrap the *Notification that should be sent to the remote peer.
+func (o *OpenMessage) Decode(b []byte) error {
+	if len(b) < 10 {
+		n := newNotification(NotifCodeMessageHeaderErr, NotifSubcodeBadLength,
+			b)
+		return newNotificationError(n, true)
+	}
+	o.Version = b[0]
+	o.ASN = binary.BigEndian.Uint16(b[1:3])
+	o.HoldTime = binary.BigEndian.Uint16(b[3:5])
+	o.BGPID = binary.BigEndian.Uint32(b[5:9])
+	o.OptionalParams = nil
+	optionalParamsLen := int(b[9])
+	params := b[10:]
+	extended := false
+	// https://tools.ietf.org/html/rfc9072#section-2
+	if optionalParamsLen == extendedOptionalParamsMarker && len(params) > 0 &&
+		params[0] == extendedOptionalParamsMarker {
+		if len(params) < 3 {
+			n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+			return newNotificationError(n, true)
+		}
+		extended = true
+		optionalParamsLen = int(binary.BigEndian.Uint16(params[1:3]))
+		params = params[3:]
+	}
+	if optionalParamsLen != len(params) {
+		n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+		return newNotificationError(n, true)
+	}
+	if optionalParamsLen == 0 {
+		return nil
+	}
+	optionalParams, err := decodeOptionalParams(params, extended)
+	if err != nil {
+		return err
+	}
+	o.OptionalParams = optionalParams
+	return nil
+}
+
+func decodeOptionalParams(b []byte, extended bool) ([]OptionalParam, error) {
+	// each parameter has a 1 byte type and a 1 byte length, or 2 byte length
+	// when using the extended encoding
+	headerLen := 2
+	if extended {
+		headerLen = 3
+	}
+	params := make([]OptionalParam, 0)
+	for {
+		if len(b) < headerLen {
+			n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+			return nil, newNotificationError(n, true)
+		}
+		paramCode := b[0]
+		paramLen := int(b[1])
+		if extended {
+			paramLen = int(binary.BigEndian.Uint16(b[1:3]))
+		}
+		if len(b) < paramLen+headerLen {
+			n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+			return nil, newNotificationError(n, true)
+		}
+		paramToDecode := make([]byte, 0)
+		if paramLen > 0 {
+			paramToDecode = b[headerLen : paramLen+headerLen]
+		}
+		nextParam := headerLen + paramLen
+		b = b[nextParam:]
+		switch paramCode {
+		case CapabilityOptionalParamType:
+			cap := &CapabilityOptionalParam{}
+			err := cap.decode(paramToDecode)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, cap)
+		default:
+			params = append(params, &UnknownOptionalParam{
+				ParamType: paramCode,
+				Value:     paramToDecode,
+			})
+		}
+		if len(b) == 0 {
+			break
+		}
+	}
+	return params, nil
+}
+
+// Encode encodes the OPEN message, including the header. The extended
+// optional parameters encoding defined by RFC9072 is used if the optional
+// parameters do not fit in the RFC4271 encoding.
+func (o *OpenMessage) Encode() ([]byte, error) {
+	b := make([]byte, 9)
+	b[0] = o.Version
+	binary.BigEndian.PutUint16(b[1:3], o.ASN)
+	binary.BigEndian.PutUint16(b[3:5], o.HoldTime)
+	binary.BigEndian.PutUint32(b[5:9], o.BGPID)
+	params, err := encodeOptionalParams(o.OptionalParams)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, params...)
+	return prependHeader(b, OpenMessageType), nil
+}
+
+const (
+	// https://tools.ietf.org/html/rfc9072#section-2
+	extendedOptionalParamsMarker = 255
+)
+
+// encodeOptionalParams encodes params including the preceding Optional
+// Parameters Length field. The extended encoding defined by RFC9072 is used
+// if, and only if, the parameters do not fit in the RFC4271 encoding.
+func encodeOptionalParams(params []OptionalParam) ([]byte, error) {
+	types := make([]uint8, 0, len(params))
+	values := make([][]byte, 0, len(params))
+	extended := false
+	nonExtLen := 0
+	for _, param := range params {
+		var value []byte
+		if p, ok := param.(optionalParamValueEncoder); ok {
+			// values may exceed 255 bytes when using the extended encoding
+			v, err := p.encodeValue()
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		} else {
+			p, err := param.Encode()
+			if err != nil {
+				return nil, err
+			}
+			if len(p) < 2 {
+				return nil, errors.New("invalid optional parameter encoding")
+			}
+			value = p[2:]
+		}
+		if len(value) > math.MaxUint16 {
+			return nil, errors.New("optional parameter too long")
+		}
+		if len(value) > math.MaxUint8 {
+			extended = true
+		}
+		nonExtLen += 2 + len(value)
+		types = append(types, param.Type())
+		values = append(values, value)
+	}
+	// avoid a length of 255 so the encoding cannot be mistaken for the
+	// extended encoding marker
+	if nonExtLen >= extendedOptionalParamsMarker {
+		extended = true
+	}
+	if !extended {
+		b := make([]byte, 0, 1+nonExtLen)
+		b = append(b, uint8(nonExtLen))
+		for i := range values {
+			b = append(b, types[i], uint8(len(values[i])))
+			b = append(b, values[i]...)
+		}
+		return b, nil
+	}
+	extLen := nonExtLen + len(values)
+	if extLen > math.MaxUint16 {
+		return nil, errors.New("optional parameters too long")
+	}
+	b := make([]byte, 0, 4+extLen)
+	b = append(b, extendedOptionalParamsMarker, extendedOptionalParamsMarker,
+		uint8(extLen>>8), uint8(extLen))
+	for i := range values {
+		b = append(b, types[i], uint8(len(values[i])>>8),
+			uint8(len(values[i])))
+		b = append(b, values[i]...)
+	}
+	return b, nil
+}
+
+const (
+	asTrans uint16 = 23456
+)
+
+// OpenMessageBuilder builds an OpenMessage. The zero value is not usable, use
+// NewOpenMessageBuilder().
+type OpenMessageBuilder struct {
+	msg    OpenMessage
+	asn    uint32
+	caps   []*Capability
+	params []OptionalParam
+	err    error
+}
+
+// NewOpenMessageBuilder returns an OpenMessageBuilder for a version 4 OPEN
+// message.
+func NewOpenMessageBuilder() *OpenMessageBuilder {
+	return &OpenMessageBuilder{
+		msg: OpenMessage{
+			Version: 4,
+		},
+	}
+}
+
+// Version sets the BGP version number.
+func (b *OpenMessageBuilder) Version(version uint8) *OpenMessageBuilder {
+	b.msg.Version = version
+	return b
+}
+
+// ASN sets the autonomous system number of the sender. A four-octet AS number
+// capability is always included in the built message, and the My Autonomous
+// System field is set to AS_TRANS for ASNs that do not fit in two octets
+// (RFC6793).
+func (b *OpenMessageBuilder) ASN(asn uint32) *OpenMessageBuilder {
+	b.asn = asn
+	return b
+}
+
+// HoldTime sets the proposed hold time, truncated to seconds.
+func (b *OpenMessageBuilder) HoldTime(holdTime time.Duration) *OpenMessageBuilder {
+	if holdTime < 0 || holdTime.Seconds() > math.MaxUint16 {
+		b.err = errors.New("invalid hold time")
+		return b
+	}
+	b.msg.HoldTime = uint16(holdTime.Truncate(time.Second).Seconds())
+	return b
+}
+
+// BGPID sets the BGP identifier of the sender. id must be an IPv4 address.
+func (b *OpenMessageBuilder) BGPID(id net.IP) *OpenMessageBuilder {
+	v4 := id.To4()
+	if v4 == nil {
+		b.err = errors.New("invalid BGP identifier")
+		return b
+	}
+	b.msg.BGPID = binary.BigEndian.Uint32(v4)
+	return b
+}
+
+// Capabilities adds capabilities to the message. All capabilities are
+// advertised in a single capabilities optional parameter. A four-octet AS
+// number capability is ignored as it is derived from ASN().
+func (b *OpenMessageBuilder) Capabilities(caps ...*Capability) *OpenMessageBuilder {
+	b.caps = append(b.caps, caps...)
+	return b
+}
+
+// OptionalParams adds optional parameters to the message. They are encoded
+// after the capabilities optional parameter.
+func (b *OpenMessageBuilder) OptionalParams(params ...OptionalParam) *OpenMessageBuilder {
+	b.params = append(b.params, params...)
+	return b
+}
+
+// Build returns the built OpenMessage or the first error encountered while
+// building.
+func (b *OpenMessageBuilder) Build() (*OpenMessage, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.asn == 0 {
+		return nil, errors.New("ASN must be > 0")
+	}
+	allCaps := make([]*Capability, 0, len(b.caps)+1)
+	allCaps = append(allCaps, NewFourOctetASCap(b.asn))
+	for _, cap := range b.caps {
+		// ignore four octet as capability as we include this implicitly above
+		if cap.Code != CapCodeFourOctetAS {
+			allCaps = append(allCaps, cap)
+		}
+	}
+	o := b.msg
+	if b.asn > math.MaxUint16 {
+		o.ASN = asTrans
+	} else {
+		o.ASN = uint16(b.asn)
+	}
+	o.OptionalParams = make([]OptionalParam, 0, len(b.params)+1)
+	o.OptionalParams = append(o.OptionalParams, &CapabilityOptionalParam{
+		Capabilities: allCaps,
+	})
+	o.OptionalParams = append(o.OptionalParams, b.params...)
+	return &o, nil
+}
+
+func newOpenMessage(asn uint32, holdTime time.Duration, bgpID uint32,
+	caps []*Capability) (*OpenMessage, error) {
+	id := make(net.IP, 4)
+	binary.BigEndian.PutUint32(id, bgpID)
+	return NewOpenMessageBuilder().
+		ASN(asn).
+		HoldTime(holdTime).
+		BGPID(id).
+		Capabilities(caps...).
+		Build()
+}
+
+// Optional parameter type values
+// https://tools.ietf.org/html/rfc5492#section-4
+const (
+	// AuthOptionalParamType is the deprecated authentication optional
+	// parameter type (RFC1771).
+	AuthOptionalParamType uint8 = 1
+	// CapabilityOptionalParamType is the optional parameter type of a
+	// capabilities optional parameter (RFC5492).
+	CapabilityOptionalParamType uint8 = 2
+)
+
+// OptionalParam is an OPEN message optional parameter.
+type OptionalParam interface {
+	// Type returns the parameter type.
+	Type() uint8
+	// Encode returns the encoded parameter, including its type and length.
+	Encode() ([]byte, error)
+}
+
+// optionalParamValueEncoder is implemented by OptionalParams that can encode
+// their value separately from the type and length.
+type optionalParamValueEncoder interface {
+	encodeValue() ([]byte, error)
+}
+
+// CapabilityOptionalParam is a capabilities optional parameter as defined by
+// RFC5492.
+type CapabilityOptionalParam struct {
+	Capabilities []*Capability
+}
+
+// Type returns CapabilityOptionalParamType.
+func (c *CapabilityOptionalParam) Type() uint8 {
+	return CapabilityOptionalParamType
+}
+
+func (c *CapabilityOptionalParam) decode(b []byte) error {
+	for {
+		if len(b) < 2 {
+			n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+			return newNotificationError(n, true)
+		}
+		capCode := b[0]
+		capLen := int(b[1])
+		if len(b) < capLen+2 {
+			n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+			return newNotificationError(n, true)
+		}
+		capValue := make([]byte, 0)
+		if capLen > 0 {
+			capValue = b[2 : capLen+2]
+		}
+		cap := &Capability{
+			Code:  capCode,
+			Value: capValue,
+		}
+		c.Capabilities = append(c.Capabilities, cap)
+		nextCap := 2 + capLen
+		b = b[nextCap:]
+		if len(b) == 0 {
+			return nil
+		}
+	}
+}
+
+// Encode encodes the parameter, including its type and length.
+func (c *CapabilityOptionalParam) Encode() ([]byte, error) {
+	caps, err := c.encodeValue()
+	if err != nil {
+		return nil, err
+	}
+	if len(caps) > math.MaxUint8 {
+		return nil, errors.New("capabilities optional param too long")
+	}
+	b := make([]byte, 0, 2+len(caps))
+	b = append(b, CapabilityOptionalParamType)
+	b = append(b, uint8(len(caps)))
+	b = append(b, caps...)
+	return b, nil
+}
+
+func (c *CapabilityOptionalParam) encodeValue() ([]byte, error) {
+	if len(c.Capabilities) == 0 {
+		return nil, errors.New("empty capabilities in capability optional param")
+	}
+	caps := make([]byte, 0)
+	for _, cap := range c.Capabilities {
+		if len(cap.Value) > math.MaxUint8 {
+			return nil, errors.New("capability value too long")
+		}
+		caps = append(caps, cap.Code)
+		caps = append(caps, uint8(len(cap.Value)))
+		caps = append(caps, cap.Value...)
+	}
+	return caps, nil
+}
+
+// UnknownOptionalParam is an optional parameter of a type that is not
+// otherwise decoded, e.g. the deprecated authentication parameter.
+type UnknownOptionalParam struct {
+	ParamType uint8
+	Value     []byte
+}
+
+// Type returns the parameter type.
+func (u *UnknownOptionalParam) Type() uint8 {
+	return u.ParamType
+}
+
+// Encode encodes the parameter, including its type and length.
+func (u *UnknownOptionalParam) Encode() ([]byte, error) {
+	if len(u.Value) > math.MaxUint8 {
+		return nil, errors.New("optional param too long")
+	}
+	b := make([]byte, 0, 2+len(u.Value))
+	b = append(b, u.ParamType, uint8(len(u.Value)))
+	b = append(b, u.Value...)
+	return b, nil
+}
+
+func (u *UnknownOptionalParam) encodeValue() ([]byte, error) {
+	return u.Value, nil
+}
+
+// OptionalParamPolicy determines how an unknown optional parameter in a
+// received OPEN message is handled.
+type OptionalParamPolicy int
+
+const (
+	// OptionalParamReject rejects the OPEN message with an Unsupported
+	// Optional Parameter NOTIFICATION.
+	OptionalParamReject OptionalParamPolicy = iota
+	// OptionalParamSkip ignores the parameter.
+	OptionalParamSkip
+)
+
+// checkUnknownOptionalParams applies policy to each UnknownOptionalParam in
+// the message, returning the parameters that were skipped.
+func (o *OpenMessage) checkUnknownOptionalParams(
+	policy func(*UnknownOptionalParam) OptionalParamPolicy) (
+	[]*UnknownOptionalParam, error) {
+	var skipped []*UnknownOptionalParam
+	for _, param := range o.OptionalParams {
+		u, ok := param.(*UnknownOptionalParam)
+		if !ok {
+			continue
+		}
+		if policy(u) != OptionalParamSkip {
+			n := newNotification(NotifCodeOpenMessageErr,
+				NotifSubcodeUnsupportedOptionalParam, nil)
+			return nil, newNotificationError(n, true)
+		}
+		skipped = append(skipped, u)
+	}
+	return skipped, nil
+}
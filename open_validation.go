@@ -0,0 +1,124 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// OpenValidationPolicy determines the checks applied to OPEN messages
+// received from a peer, see OpenValidation. The zero value applies the checks
+// of RFC4271, RFC6286 and RFC6793.
+type OpenValidationPolicy struct {
+	// MinHoldTime is the minimum non-zero hold time accepted, rounded up to
+	// a second. Values below the 3 seconds required by RFC4271 are ignored.
+	MinHoldTime time.Duration
+	// RejectZeroHoldTime rejects a hold time of zero, i.e. sessions without
+	// KEEPALIVE messages.
+	RejectZeroHoldTime bool
+	// AnyBGPID accepts any non-zero BGP identifier (RFC6286) rather than
+	// only global unicast IPv4 addresses.
+	AnyBGPID bool
+	// DistinctBGPID rejects a BGP identifier equal to the local one from
+	// external peers as well as internal ones.
+	DistinctBGPID bool
+	// RemoteAS, if non-nil, returns true if the AS of a peer is acceptable,
+	// replacing matching it against PeerConfig.RemoteAS, AnyRemoteAS and
+	// RemoteASRanges.
+	RemoteAS func(asn uint32) bool
+	// Validate, if non-nil, is called once the message passed all other
+	// checks. A non-nil Notification it returns rejects the message, and is
+	// sent to the peer.
+	Validate func(peer PeerConfig, o *OpenMessage) *Notification
+}
+
+// OpenValidation returns a PeerOption that sets the policy applied to OPEN
+// messages received from the peer, e.g. to accept a peer using a BGP
+// identifier that is not a unicast address, or to require a minimum hold
+// time.
+func OpenValidation(policy OpenValidationPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.openValidation = policy
+	})
+}
+
+// minimum non-zero hold time of RFC4271
+const minHoldTime = 3
+
+func badPeerASError() error {
+	n := newNotification(NotifCodeOpenMessageErr, NotifSubcodeBadPeerAS, nil)
+	return newNotificationError(n, true)
+}
+
+// validate validates o received from peer, whose AS must be accepted by
+// acceptAS.
+// https://tools.ietf.org/html/rfc4271#section-6.2
+func (p *OpenValidationPolicy) validate(o *OpenMessage, peer *PeerConfig,
+	localID uint32, acceptAS func(uint32) bool) error {
+	if o.Version != 4 {
+		version := make([]byte, 2)
+		binary.BigEndian.PutUint16(version, uint16(4))
+		n := newNotification(NotifCodeOpenMessageErr,
+			NotifSubcodeUnsupportedVersionNumber, version)
+		return newNotificationError(n, true)
+	}
+	peerAS := uint32(o.ASN)
+	fourOctetAS := o.ASN == asTrans
+	var fourOctetASFound bool
+	for _, c := range o.Capabilities() {
+		if c.Code == CapCodeFourOctetAS {
+			fourOctetASFound = true
+			if len(c.Value) != 4 {
+				n := newNotification(NotifCodeOpenMessageErr, 0, nil)
+				return newNotificationError(n, true)
+			}
+			asn := binary.BigEndian.Uint32(c.Value)
+			if !fourOctetAS && asn != peerAS {
+				return badPeerASError()
+			}
+			peerAS = asn
+		}
+	}
+	if fourOctetAS && !fourOctetASFound {
+		return badPeerASError()
+	}
+	// https://tools.ietf.org/html/rfc7607
+	if peerAS == 0 || !acceptAS(peerAS) {
+		return badPeerASError()
+	}
+	if o.HoldTime == 0 && p.RejectZeroHoldTime ||
+		o.HoldTime != 0 && o.HoldTime < p.minHoldTime() {
+		n := newNotification(NotifCodeOpenMessageErr,
+			NotifSubcodeUnacceptableHoldTime, nil)
+		return newNotificationError(n, true)
+	}
+	id := net.IP(make([]byte, 4))
+	binary.BigEndian.PutUint32(id, o.BGPID)
+	if o.BGPID == 0 || !p.AnyBGPID && !id.IsGlobalUnicast() {
+		n := newNotification(NotifCodeOpenMessageErr, NotifSubcodeBadBgpID, nil)
+		return newNotificationError(n, true)
+	}
+	// https://tools.ietf.org/html/rfc6286#section-2.2
+	if o.BGPID == localID && (peerAS == peer.LocalAS || p.DistinctBGPID) {
+		n := newNotification(NotifCodeOpenMessageErr, NotifSubcodeBadBgpID, nil)
+		return newNotificationError(n, true)
+	}
+	if p.Validate != nil {
+		if n := p.Validate(*peer, o); n != nil {
+			return newNotificationError(n, true)
+		}
+	}
+	return nil
+}
+
+// minHoldTime returns the minimum non-zero hold time in seconds.
+func (p *OpenValidationPolicy) minHoldTime() uint16 {
+	seconds := (p.MinHoldTime + time.Second - 1) / time.Second
+	switch {
+	case seconds < minHoldTime:
+		return minHoldTime
+	case seconds > 0xffff:
+		return 0xffff
+	}
+	return uint16(seconds)
+}
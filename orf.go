@@ -0,0 +1,241 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ORF type values
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#bgp-parameters-9
+const (
+	ORFTypeAddressPrefix uint8 = 64
+)
+
+// ORFMode is the Send/Receive field of an Outbound Route Filtering
+// capability.
+type ORFMode uint8
+
+// ORFMode values
+const (
+	ORFModeReceive ORFMode = 1
+	ORFModeSend    ORFMode = 2
+	ORFModeBoth    ORFMode = 3
+)
+
+// ORFTypeMode is an ORF type and the mode in which it is supported.
+type ORFTypeMode struct {
+	Type uint8
+	Mode ORFMode
+}
+
+// ORFCapEntry is an AFI/SAFI and the ORF types supported for it.
+type ORFCapEntry struct {
+	AFI   uint16
+	SAFI  uint8
+	Types []ORFTypeMode
+}
+
+// NewORFCap returns an Outbound Route Filtering capability (RFC5291).
+func NewORFCap(entries ...ORFCapEntry) *Capability {
+	value := make([]byte, 0)
+	for _, e := range entries {
+		value = append(value, uint8(e.AFI>>8), uint8(e.AFI), 0, e.SAFI,
+			uint8(len(e.Types)))
+		for _, t := range e.Types {
+			value = append(value, t.Type, uint8(t.Mode))
+		}
+	}
+	return &Capability{
+		Code:  CapCodeOutboundRouteFiltering,
+		Value: value,
+	}
+}
+
+// DecodeORFCap decodes an Outbound Route Filtering capability.
+func DecodeORFCap(c *Capability) ([]ORFCapEntry, error) {
+	err := checkCapCode(c, CapCodeOutboundRouteFiltering)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ORFCapEntry, 0)
+	b := c.Value
+	for len(b) > 0 {
+		if len(b) < 5 || len(b) < 5+int(b[4])*2 {
+			return nil, fmt.Errorf("invalid %s capability length: %d",
+				lookupCapName(c.Code), len(c.Value))
+		}
+		e := ORFCapEntry{
+			AFI:   binary.BigEndian.Uint16(b),
+			SAFI:  b[3],
+			Types: make([]ORFTypeMode, 0, b[4]),
+		}
+		for i := 0; i < int(b[4]); i++ {
+			e.Types = append(e.Types, ORFTypeMode{
+				Type: b[5+i*2],
+				Mode: ORFMode(b[6+i*2]),
+			})
+		}
+		entries = append(entries, e)
+		b = b[5+int(b[4])*2:]
+	}
+	return entries, nil
+}
+
+// ORFAction is the action of an ORF entry.
+type ORFAction uint8
+
+// ORFAction values
+const (
+	ORFActionAdd       ORFAction = 0
+	ORFActionRemove    ORFAction = 1
+	ORFActionRemoveAll ORFAction = 2
+)
+
+// ORFMatch is the match field of an ORF entry.
+type ORFMatch uint8
+
+// ORFMatch values
+const (
+	ORFMatchPermit ORFMatch = 0
+	ORFMatchDeny   ORFMatch = 1
+)
+
+// AddressPrefixORFEntry is an Address Prefix ORF entry (RFC5292). Only
+// Action and Match are significant for ORFActionRemoveAll.
+type AddressPrefixORFEntry struct {
+	Action   ORFAction
+	Match    ORFMatch
+	Sequence uint32
+	MinLen   uint8
+	MaxLen   uint8
+	Prefix   net.IP
+	// PrefixLen is the length of Prefix in bits.
+	PrefixLen uint8
+}
+
+// EncodeAddressPrefixORF returns an ORF of type ORFTypeAddressPrefix
+// containing entries.
+func EncodeAddressPrefixORF(entries ...AddressPrefixORFEntry) (*ORF, error) {
+	b := make([]byte, 0)
+	for _, e := range entries {
+		if e.Action > ORFActionRemoveAll || e.Match > ORFMatchDeny {
+			return nil, errors.New("invalid orf entry action or match")
+		}
+		b = append(b, uint8(e.Action)<<6|uint8(e.Match)<<5)
+		if e.Action == ORFActionRemoveAll {
+			continue
+		}
+		prefix := e.Prefix.To4()
+		if prefix == nil {
+			prefix = e.Prefix.To16()
+		}
+		if prefix == nil || int(e.PrefixLen) > len(prefix)*8 {
+			return nil, errors.New("invalid orf entry prefix")
+		}
+		b = append(b, uint8(e.Sequence>>24), uint8(e.Sequence>>16),
+			uint8(e.Sequence>>8), uint8(e.Sequence), e.MinLen, e.MaxLen,
+			e.PrefixLen)
+		b = append(b, prefix[:(e.PrefixLen+7)/8]...)
+	}
+	return &ORF{
+		Type:    ORFTypeAddressPrefix,
+		Entries: b,
+	}, nil
+}
+
+// DecodeAddressPrefixORF decodes the entries of an ORF of type
+// ORFTypeAddressPrefix. afi determines the address family of the prefixes.
+func DecodeAddressPrefixORF(afi uint16, o *ORF) ([]AddressPrefixORFEntry,
+	error) {
+	if o.Type != ORFTypeAddressPrefix {
+		return nil, fmt.Errorf("orf type %d is not address prefix", o.Type)
+	}
+	var addrLen int
+	switch afi {
+	case AFIIPv4:
+		addrLen = net.IPv4len
+	case AFIIPv6:
+		addrLen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unsupported address prefix orf afi: %d", afi)
+	}
+	entries := make([]AddressPrefixORFEntry, 0)
+	b := o.Entries
+	for len(b) > 0 {
+		e := AddressPrefixORFEntry{
+			Action: ORFAction(b[0] >> 6),
+			Match:  ORFMatch(b[0] >> 5 & 1),
+		}
+		b = b[1:]
+		if e.Action == ORFActionRemoveAll {
+			entries = append(entries, e)
+			continue
+		}
+		if len(b) < 7 {
+			return nil, errors.New("malformed address prefix orf entry")
+		}
+		e.Sequence = binary.BigEndian.Uint32(b)
+		e.MinLen = b[4]
+		e.MaxLen = b[5]
+		e.PrefixLen = b[6]
+		prefixBytes := (int(e.PrefixLen) + 7) / 8
+		if int(e.PrefixLen) > addrLen*8 || len(b) < 7+prefixBytes {
+			return nil, errors.New("malformed address prefix orf entry")
+		}
+		e.Prefix = make(net.IP, addrLen)
+		copy(e.Prefix, b[7:7+prefixBytes])
+		entries = append(entries, e)
+		b = b[7+prefixBytes:]
+	}
+	return entries, nil
+}
+
+// orfMode returns the mode in which caps, a set of capabilities, support
+// ORF type orfType for afi/safi, or zero if it is not supported.
+func orfMode(caps []*Capability, afi uint16, safi, orfType uint8) ORFMode {
+	var mode ORFMode
+	for _, c := range caps {
+		if c.Code != CapCodeOutboundRouteFiltering {
+			continue
+		}
+		entries, err := DecodeORFCap(c)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.AFI != afi || e.SAFI != safi {
+				continue
+			}
+			for _, t := range e.Types {
+				if t.Type == orfType {
+					mode |= t.Mode
+				}
+			}
+		}
+	}
+	return mode
+}
+
+// removeUnnegotiatedORFs removes the ORFs of r whose type was not negotiated
+// for its AFI/SAFI, i.e. advertised in sent with the receive mode and in
+// received with the send mode. ORF entries of other types must be ignored.
+// https://tools.ietf.org/html/rfc5291#section-5
+func removeUnnegotiatedORFs(r *RouteRefresh, sent, received []*Capability) {
+	if len(r.ORFs) == 0 {
+		return
+	}
+	orfs := r.ORFs[:0]
+	for _, o := range r.ORFs {
+		if orfMode(sent, r.AFI, r.SAFI, o.Type)&ORFModeReceive != 0 &&
+			orfMode(received, r.AFI, r.SAFI, o.Type)&ORFModeSend != 0 {
+			orfs = append(orfs, o)
+		}
+	}
+	if len(orfs) == 0 {
+		r.When = 0
+		orfs = nil
+	}
+	r.ORFs = orfs
+}
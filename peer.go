@@ -1,9 +1,12 @@
 package corebgp
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,13 +22,16 @@ const (
 
 // peer manages the FSMs for a peer.
 type peer struct {
+	// ctx is the context of the Server, bounding dials
+	ctx     context.Context
 	config  *PeerConfig
 	id      uint32
 	plugin  Plugin
 	options *peerOptions
+	events  *eventBus
 
 	fsms         [2]*fsm
-	fsmState     [2]fsmState
+	fsmState     [2]FSMState
 	transitionCh [2]chan stateTransition
 	errorCh      [2]chan error
 
@@ -33,8 +39,52 @@ type peer struct {
 	startupDelay      time.Duration
 	startupDelayTimer *time.Timer
 	inHoldDown        bool
+	// true while the peer is administratively disabled
+	adminDisabled bool
+	adminCh       chan adminRequest
+
+	// state mirrors fsmState and session is non-nil while an FSM is in the
+	// Established state, both are guarded by statusMu
+	statusMu sync.Mutex
+	state    [2]FSMState
+	session  *SessionInfo
+	disabled bool
+	counters *peerCounters
+	memory   *memoryUsage
+	// keepAlive is maintained by the established FSM
+	keepAlive *keepAliveStats
+	// messages is nil unless the MessageHistory option was set
+	messages *messageHistory
+	// optional capabilities dropped due to an Unsupported Capability
+	// notification, guarded by statusMu
+	droppedCaps []*Capability
+	// history of established sessions and the latest error, guarded by
+	// statusMu
+	history   []*SessionRecord
+	lastError *PeerError
+	// outbound connection attempts, guarded by statusMu
+	dial DialStatus
+	// the latest damping delay and the end of the damping, guarded by
+	// statusMu
+	damping     time.Duration
+	dampedUntil time.Time
+	// timelines of the latest establishment attempts, guarded by statusMu
+	attempts []EstablishmentTimeline
+	// the latest TCPStats of the established session, guarded by statusMu
+	tcpStats *TCPStats
+	// true if the DualStack alternate address is preferred when dialing, only
+	// accessed by the outbound FSM
+	preferAlt bool
+	// listenOnly is true if the peer was added to a ListenOnly Server
+	listenOnly bool
+
+	// dynamic peers were added via Server.AcceptDynamicPeers, onIdle is
+	// called once no FSM remains after their connection closes
+	dynamic bool
+	onIdle  func()
 
 	inConnCh  chan net.Conn
+	started   bool
 	closeOnce sync.Once
 	closeCh   chan struct{}
 	doneCh    chan struct{}
@@ -45,12 +95,24 @@ const (
 	in  = 1
 )
 
-func newPeer(config *PeerConfig, id uint32, plugin Plugin, options *peerOptions) *peer {
+func newPeer(ctx context.Context, config *PeerConfig, id uint32, plugin Plugin,
+	options *peerOptions, events *eventBus, metrics *serverMetrics) *peer {
+	counters := &peerCounters{server: metrics}
+	memory := &memoryUsage{}
+	messages := newMessageHistory(options.messageHistorySize,
+		options.memoryBudget.MessageHistory, &memory.messageHistory, counters)
 	p := &peer{
+		ctx:               ctx,
 		config:            config,
 		id:                id,
 		plugin:            plugin,
 		options:           options,
+		events:            events,
+		counters:          counters,
+		memory:            memory,
+		keepAlive:         &keepAliveStats{},
+		messages:          messages,
+		adminCh:           make(chan adminRequest),
 		inConnCh:          make(chan net.Conn),
 		closeCh:           make(chan struct{}),
 		doneCh:            make(chan struct{}),
@@ -58,7 +120,7 @@ func newPeer(config *PeerConfig, id uint32, plugin Plugin, options *peerOptions)
 	}
 	<-p.startupDelayTimer.C
 	for i := 0; i < 2; i++ {
-		p.fsmState[i] = disabledState
+		p.fsmState[i] = DisabledState
 		p.transitionCh[i] = make(chan stateTransition)
 		p.errorCh[i] = make(chan error)
 	}
@@ -67,18 +129,12 @@ func newPeer(config *PeerConfig, id uint32, plugin Plugin, options *peerOptions)
 
 // getFSMTransitionCh returns the stateTransition channel for the provided FSM.
 func (p *peer) getFSMTransitionCh(f *fsm) chan stateTransition {
-	if f == p.fsms[out] {
-		return p.transitionCh[out]
-	}
-	return p.transitionCh[in]
+	return p.transitionCh[f.index]
 }
 
 // getFSMErrorCh returns the error channel for the provided FSM.
 func (p *peer) getFSMErrorCh(f *fsm) chan error {
-	if f == p.fsms[out] {
-		return p.errorCh[out]
-	}
-	return p.errorCh[in]
+	return p.errorCh[f.index]
 }
 
 func other(i int) int {
@@ -88,45 +144,108 @@ func other(i int) int {
 	return out
 }
 
-func (p *peer) logTransition(i int, from, to fsmState) {
+func (p *peer) logTransition(i int, from, to FSMState) {
 	logf("[%s] FSM-%s transition %s => %s", p.config.IP,
 		direction(i), from, to)
+	p.statusMu.Lock()
+	p.state[i] = to
+	if from == EstablishedState && to != EstablishedState {
+		p.session = nil
+		p.tcpStats = nil
+		p.recordClosed()
+	}
+	p.statusMu.Unlock()
+	p.events.publish(&StateChangeEvent{
+		eventBase: newEventBase(p),
+		Inbound:   i == in,
+		From:      from,
+		To:        to,
+	})
+}
+
+// newSessionInfo returns a SessionInfo for FSM i, which must be waiting on a
+// transition to the Established state.
+func (p *peer) newSessionInfo(i int) *SessionInfo {
+	f := p.fsms[i]
+	remoteID := make(net.IP, 4)
+	binary.BigEndian.PutUint32(remoteID, f.remoteID)
+	return &SessionInfo{
+		Peer:              p.config.IP,
+		Inbound:           i == in,
+		LocalAddr:         f.conn.LocalAddr(),
+		RemoteAddr:        f.conn.RemoteAddr(),
+		Interface:         connInterface(f.conn),
+		MSS:               connMSS(f.conn),
+		RemoteID:          remoteID,
+		RemoteAS:          f.remoteAS,
+		HoldTime:          f.holdTime,
+		KeepAliveInterval: f.keepAliveInterval,
+		FourOctetAS:       f.fourOctetAS,
+		Codec:             f.codec,
+		Capabilities:      f.remoteCapabilities,
+		SentOpen:          f.sentOpen,
+		ReceivedOpen:      f.receivedOpen,
+		EstablishedAt:     time.Now(),
+	}
+}
+
+func (p *peer) setSession(session *SessionInfo) {
+	p.statusMu.Lock()
+	p.session = session
+	p.recordEstablished(session)
+	p.statusMu.Unlock()
+}
+
+func (p *peer) sessionInfo() *SessionInfo {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+	return p.session
 }
 
 func (p *peer) disableFSM(i int) {
 	if p.fsms[i] == nil {
 		return
 	}
-	p.logTransition(i, p.fsmState[i], disabledState)
+	p.logTransition(i, p.fsmState[i], DisabledState)
 	p.fsms[i].stop()
 	p.fsms[i] = nil
-	p.fsmState[i] = disabledState
+	p.fsmState[i] = DisabledState
 }
 
 func (p *peer) sendTransitionToFSM(i int, t stateTransition) {
+	var session *SessionInfo
+	if t.to == EstablishedState {
+		// the FSM is blocked until it receives the transition
+		session = p.newSessionInfo(i)
+	}
+	t.session = session
 	select {
 	case <-p.closeCh:
 		return
 	case p.transitionCh[i] <- t:
+		if session != nil {
+			p.setSession(session)
+			atomic.AddUint64(&p.counters.establishedTransitions, 1)
+		}
 		p.logTransition(i, t.from, t.to)
 		p.fsmState[i] = t.to
 	}
 }
 
 func (p *peer) enableFSM(i int, conn net.Conn) {
-	if i == out && p.options.passive {
+	if i == out && (p.options.passive || p.listenOnly) {
 		return
 	}
 	if p.fsms[i] == nil {
-		p.fsms[i] = newFSM(p, conn)
-		p.fsmState[i] = disabledState
+		p.fsms[i] = newFSM(p, i, conn)
+		p.fsmState[i] = DisabledState
 		p.fsms[i].start()
 	}
 }
 
 func (p *peer) handleStateTransition(i int, t stateTransition) {
 	switch {
-	case t.to == establishedState:
+	case t.to == EstablishedState:
 		// disable the other fsm
 		p.disableFSM(other(i))
 		p.sendTransitionToFSM(i, t)
@@ -134,17 +253,17 @@ func (p *peer) handleStateTransition(i int, t stateTransition) {
 		// in going down, disable it and make sure out is enabled
 		p.disableFSM(i)
 		p.enableFSM(out, nil)
-	case t.to == openConfirmState:
+	case t.to == OpenConfirmState:
 		// https://tools.ietf.org/html/rfc4271#section-6.8
 		switch p.fsmState[other(i)] {
-		case establishedState:
+		case EstablishedState:
 			/*
 				Unless allowed via configuration, a connection collision with an
 				existing BGP connection that is in the Established state causes
 				closing of the newly created connection.
 			*/
 			p.disableFSM(i)
-		case openConfirmState:
+		case OpenConfirmState:
 			// https://github.com/BIRD/bird/blob/v2.0.2/proto/bgp/packets.c#L666
 			/*
 				Description of collision detection rules in RFC 4271 is confusing and
@@ -158,8 +277,9 @@ func (p *peer) handleStateTransition(i int, t stateTransition) {
 			remoteID := p.fsms[i].remoteID
 			localID := p.id
 			dominant := localID > remoteID ||
-				(localID == remoteID) && (p.config.LocalAS > p.config.RemoteAS)
-			if dominant && i == out {
+				(localID == remoteID) && (p.config.LocalAS > p.fsms[i].remoteAS)
+			if dominant == (i == out) {
+				// this FSM's connection was initiated by the dominant router,
 				// attempt to disable other FSM
 				select {
 				case <-p.closeCh:
@@ -171,7 +291,7 @@ func (p *peer) handleStateTransition(i int, t stateTransition) {
 					p.sendTransitionToFSM(i, t)
 				case otherT := <-p.transitionCh[other(i)]:
 					// other FSM transitioned before we could disable it
-					if otherT.to == establishedState {
+					if otherT.to == EstablishedState {
 						// other FSM entered established state before we could
 						// disable it. disable this FSM and then handle the
 						// transition from the other FSM.
@@ -215,13 +335,43 @@ func direction(i int) string {
 func (p *peer) handleError(i int, err error) {
 	logf("[%s] FSM-%s %s error: %v",
 		p.config.IP, direction(i), p.fsmState[i], err)
+	p.statusMu.Lock()
+	p.recordError(i, p.fsmState[i], err)
+	p.statusMu.Unlock()
+	if fn := p.options.onMessageHistoryFailure; fn != nil && p.messages != nil {
+		fn(p.config, p.messages.snapshot())
+	}
+	if p.fsmState[i] > DisabledState && p.fsmState[i] < EstablishedState {
+		p.events.publish(&EstablishmentFailedEvent{
+			eventBase: newEventBase(p),
+			Inbound:   i == in,
+			State:     p.fsmState[i],
+			Err:       err,
+		})
+	}
 	var nerr *notificationError
 	if errors.As(err, &nerr) {
+		if nerr.out {
+			p.events.publish(&NotificationSentEvent{
+				eventBase:    newEventBase(p),
+				Notification: nerr.notification,
+			})
+		} else {
+			p.events.publish(&NotificationReceivedEvent{
+				eventBase:    newEventBase(p),
+				Notification: nerr.notification,
+			})
+		}
+		if !nerr.out && p.dropUnsupportedCapabilities(i, nerr.notification) {
+			// retry without the dropped capabilities rather than damping
+			return
+		}
 		if nerr.dampPeer() {
 			p.disableFSM(in)
 			p.disableFSM(out)
 			p.updateStartupDelay()
 			p.inHoldDown = true
+			p.timerRescheduled(TimerDamping, p.dampedUntil, err)
 		}
 	}
 }
@@ -247,6 +397,10 @@ func (p *peer) updateStartupDelay() {
 
 	p.startupDelayTimer.Stop()
 	p.startupDelayTimer = time.NewTimer(p.startupDelay)
+	p.statusMu.Lock()
+	p.damping = p.startupDelay
+	p.dampedUntil = lastProtoError.Add(p.startupDelay)
+	p.statusMu.Unlock()
 	logf("[%s] damping peer for %s", p.config.IP, p.startupDelay)
 }
 
@@ -264,10 +418,18 @@ func (p *peer) run() {
 		case <-p.closeCh:
 			return
 		case <-p.startupDelayTimer.C:
+			p.inHoldDown = false
+			p.statusMu.Lock()
+			p.dampedUntil = time.Time{}
+			p.statusMu.Unlock()
+			if p.adminDisabled {
+				continue
+			}
 			logf("[%s] startup delay timer expired, enabling peer",
 				p.config.IP)
 			p.enableFSM(out, nil)
-			p.inHoldDown = false
+		case r := <-p.adminCh:
+			p.handleAdminRequest(r)
 		case err := <-p.errorCh[in]:
 			p.handleError(in, err)
 		case err := <-p.errorCh[out]:
@@ -277,30 +439,131 @@ func (p *peer) run() {
 		case t := <-p.transitionCh[out]:
 			p.handleStateTransition(out, t)
 		case conn := <-p.inConnCh:
-			if p.inHoldDown {
+			if p.inHoldDown || p.adminDisabled {
+				conn.Close()
+				continue
+			}
+			if p.options.activeOnly {
+				logf("[%s] closing incoming connection from active-only peer",
+					p.config.IP)
 				conn.Close()
 				continue
 			}
 
 			// https://github.com/BIRD/bird/blob/v2.0.2/proto/bgp/bgp.c#L1036
-			if p.fsms[in] != nil || p.fsmState[out] == establishedState {
+			if p.fsms[in] != nil || p.fsmState[out] == EstablishedState {
+				conn.Close()
+				continue
+			}
+			if err := p.controlConn(conn); err != nil {
+				logf("[%s] socket control of incoming connection failed: %v",
+					p.config.IP, err)
 				conn.Close()
 				continue
-			} else {
-				p.enableFSM(in, conn)
 			}
+			p.enableFSM(in, conn)
+		}
+		if p.dynamic && p.onIdle != nil && p.fsms[in] == nil &&
+			p.fsms[out] == nil {
+			p.onIdle()
+			p.onIdle = nil
+		}
+	}
+}
+
+type adminAction uint8
+
+const (
+	adminReset adminAction = iota
+	adminDisable
+	adminEnable
+)
+
+type adminRequest struct {
+	action adminAction
+	doneCh chan struct{}
+}
+
+// adminStopFSMs stops both FSMs, sending a Cease Notification with subcode to
+// the peer for any FSM with an open connection.
+func (p *peer) adminStopFSMs(subcode uint8) {
+	for _, i := range []int{out, in} {
+		if p.fsms[i] != nil {
+			p.fsms[i].ceaseSubcode = subcode
+		}
+		p.disableFSM(i)
+	}
+}
+
+func (p *peer) handleAdminRequest(r adminRequest) {
+	defer close(r.doneCh)
+	switch r.action {
+	case adminReset:
+		if p.adminDisabled {
+			return
+		}
+		logf("[%s] resetting peer", p.config.IP)
+		p.adminStopFSMs(NotifSubcodeAdminReset)
+		p.clearDroppedCapabilities()
+		if !p.inHoldDown {
+			p.enableFSM(out, nil)
+		}
+	case adminDisable:
+		if p.adminDisabled {
+			return
+		}
+		logf("[%s] disabling peer", p.config.IP)
+		p.setAdminDisabled(true)
+		p.adminStopFSMs(NotifSubcodeAdminShutdown)
+	case adminEnable:
+		if !p.adminDisabled {
+			return
+		}
+		logf("[%s] enabling peer", p.config.IP)
+		p.setAdminDisabled(false)
+		if !p.inHoldDown {
+			p.enableFSM(out, nil)
 		}
 	}
 }
 
+func (p *peer) setAdminDisabled(disabled bool) {
+	p.adminDisabled = disabled
+	p.statusMu.Lock()
+	p.disabled = disabled
+	p.statusMu.Unlock()
+}
+
+// admin sends an administrative request to the peer's run loop and waits for
+// it to be handled.
+func (p *peer) admin(action adminAction) {
+	r := adminRequest{
+		action: action,
+		doneCh: make(chan struct{}),
+	}
+	select {
+	case <-p.closeCh:
+		return
+	case p.adminCh <- r:
+	}
+	<-r.doneCh
+}
+
 func (p *peer) start() {
-	p.enableFSM(out, nil)
+	if !p.adminDisabled {
+		p.enableFSM(out, nil)
+	}
+	p.started = true
 	go p.run()
 }
 
 func (p *peer) stop() {
 	p.closeOnce.Do(func() {
 		close(p.closeCh)
+		if !p.started {
+			// run() will never close doneCh
+			close(p.doneCh)
+		}
 	})
 	<-p.doneCh
 }
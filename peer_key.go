@@ -0,0 +1,273 @@
+package corebgp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// PeerKey identifies a peer among peers sharing an IP address. Peers are
+// identified by their VRF, IP address, port and PeerConfig.RemoteAS, so that
+// the same address may be configured as several peers, e.g. in different VRFs,
+// on different ports (see Port), or of different ASes.
+type PeerKey struct {
+	// VRF is the VRF of the peer, empty for the default VRF. See VRF.
+	VRF string
+	IP  net.IP
+	// Port and RemoteAS match any port and AS if zero.
+	Port     int
+	RemoteAS uint32
+}
+
+// String returns k in the form "vrf/ip:port/as", omitting empty fields.
+func (k PeerKey) String() string {
+	s := k.IP.String()
+	if k.Port != 0 {
+		s = net.JoinHostPort(s, strconv.Itoa(k.Port))
+	}
+	if k.VRF != "" {
+		s = k.VRF + "/" + s
+	}
+	if k.RemoteAS != 0 {
+		s += "/" + strconv.FormatUint(uint64(k.RemoteAS), 10)
+	}
+	return s
+}
+
+// matches returns true if k matches p. VRF is ignored if anyVRF is true.
+func (k PeerKey) matches(p *peer, anyVRF bool) bool {
+	return p.config.IP.Equal(k.IP) &&
+		(anyVRF || p.options.vrf == k.VRF) &&
+		(k.Port == 0 || p.options.port == k.Port) &&
+		(k.RemoteAS == 0 || p.config.RemoteAS == k.RemoteAS)
+}
+
+// VRF returns a PeerOption that places a peer in the VRF (or L3 master
+// device) of the given name, binding the sockets dialed to it to the device
+// (SO_BINDTODEVICE). Accepted connections are handled by the peer if their
+// socket is bound to the device, e.g. as accepted by a listener in the
+// default VRF with net.ipv4.tcp_l3mdev_accept enabled, or by a listener bound
+// to the device. It requires Linux.
+func VRF(name string) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.vrf = name
+	})
+}
+
+// peerKey returns the PeerKey of the peer.
+func (p *peer) peerKey() PeerKey {
+	return PeerKey{
+		VRF:      p.options.vrf,
+		IP:       p.config.IP,
+		Port:     p.options.port,
+		RemoteAS: p.config.RemoteAS,
+	}
+}
+
+// key returns the key of the peer in Server.peers.
+func (p *peer) key() string {
+	return p.peerKey().String()
+}
+
+// Key returns the PeerKey of the peer of s.
+func (s PeerStatus) Key() PeerKey {
+	return PeerKey{
+		VRF:      s.Options.VRF,
+		IP:       s.Config.IP,
+		Port:     s.Options.Port,
+		RemoteAS: s.Config.RemoteAS,
+	}
+}
+
+// lookupPeer returns the only peer matching k, in any VRF if anyVRF is true.
+// s.mu must be held.
+func (s *Server) lookupPeer(k PeerKey, anyVRF bool) (*peer, error) {
+	var found *peer
+	for _, p := range s.peers {
+		if !k.matches(p, anyVRF) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrPeerAmbiguous
+		}
+		found = p
+	}
+	if found == nil {
+		return nil, ErrPeerNotExist
+	}
+	return found, nil
+}
+
+// errOpenRequired is returned by matchPeer if the peer handling a connection
+// can only be determined by the AS of the OPEN message received on it.
+var errOpenRequired = errors.New("open message required")
+
+// filterPeers returns the peers of candidates fn returns true for.
+func filterPeers(candidates []*peer, fn func(p *peer) bool) []*peer {
+	var peers []*peer
+	for _, p := range candidates {
+		if fn(p) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// matchPeer returns the peer handling conn from ip, nil if there is none.
+// Among several peers with IP address ip in the VRF of conn it is the one
+// whose port is the local port of conn, otherwise the only one that is not
+// active-only, otherwise the only one accepting the AS of open, the OPEN
+// message received on conn. errOpenRequired is returned if open is needed
+// but nil. s.mu must be held.
+func (s *Server) matchPeer(conn net.Conn, ip net.IP,
+	open *OpenMessage) (*peer, error) {
+	k := PeerKey{IP: ip, VRF: connVRF(conn)}
+	var candidates []*peer
+	for _, p := range s.peers {
+		if k.matches(p, false) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) < 2 {
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+		return candidates[0], nil
+	}
+	if a, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		byPort := filterPeers(candidates, func(p *peer) bool {
+			return p.options.port == a.Port
+		})
+		if len(byPort) > 0 {
+			candidates = byPort
+		}
+	}
+	candidates = filterPeers(candidates, func(p *peer) bool {
+		return !p.options.activeOnly
+	})
+	if len(candidates) > 1 {
+		if open == nil {
+			return nil, errOpenRequired
+		}
+		candidates = filterPeers(candidates, func(p *peer) bool {
+			return p.acceptsRemoteAS(open.peerAS())
+		})
+	}
+	if len(candidates) != 1 {
+		return nil, ErrPeerAmbiguous
+	}
+	return candidates[0], nil
+}
+
+// openPeekTimeout bounds reading the OPEN message of a connection matching
+// several peers.
+const openPeekTimeout = time.Second * 10
+
+// handleConn hands conn from ip to the peer handling it, or closes it. open
+// is the OPEN message already read from conn, if any.
+func (s *Server) handleConn(conn net.Conn, ip net.IP, open *OpenMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.matchPeer(conn, ip, open)
+	switch {
+	case err == errOpenRequired:
+		go s.handleConnOpen(conn, ip)
+		return
+	case err != nil:
+		logf("[%s] rejecting connection to %s matching several peers", ip,
+			conn.LocalAddr())
+		conn.Close()
+		return
+	case p == nil:
+		p = s.acceptDynamicPeer(ip)
+		if p == nil {
+			conn.Close()
+			return
+		}
+	}
+	p.incomingConnection(conn)
+}
+
+// handleConnOpen reads the OPEN message of conn from ip matching several
+// peers, and hands conn to the peer accepting its AS. The message is read
+// again by the peer.
+func (s *Server) handleConnOpen(conn net.Conn, ip net.IP) {
+	var buf bytes.Buffer
+	conn.SetReadDeadline(time.Now().Add(openPeekTimeout))
+	msgType, body, err := NewMessageReader(io.TeeReader(conn,
+		&buf)).ReadMessage()
+	conn.SetReadDeadline(time.Time{})
+	open := &OpenMessage{}
+	if err == nil && msgType != OpenMessageType {
+		err = errors.New("first message is not an open message")
+	}
+	if err == nil {
+		err = open.Decode(body)
+	}
+	if err != nil {
+		logf("[%s] error reading open message to match connection: %v", ip,
+			err)
+		conn.Close()
+		return
+	}
+	s.handleConn(&replayConn{
+		Conn: conn,
+		buf:  &buf,
+	}, ip, open)
+}
+
+// replayConn is a net.Conn replaying data already read from Conn.
+type replayConn struct {
+	net.Conn
+	// buf holds the data read from Conn not yet replayed
+	buf *bytes.Buffer
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	if c.buf.Len() > 0 {
+		return c.buf.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// SyscallConn returns the raw connection of Conn, if it has one.
+func (c *replayConn) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := c.Conn.(syscall.Conn)
+	if !ok {
+		return nil, errors.New("connection does not expose its socket")
+	}
+	return sc.SyscallConn()
+}
+
+// GetPeerByKey returns the status of the peer matching k.
+func (s *Server) GetPeerByKey(k PeerKey) (PeerStatus, error) {
+	p, err := s.peerByKey(k, false)
+	if err != nil {
+		return PeerStatus{}, err
+	}
+	return p.status(), nil
+}
+
+// DeletePeerByKey deletes the peer matching k from the Server.
+func (s *Server) DeletePeerByKey(k PeerKey) error {
+	return s.deletePeer(k, false)
+}
+
+// ResetPeerByKey resets the peer matching k, see ResetPeer.
+func (s *Server) ResetPeerByKey(k PeerKey) error {
+	return s.adminPeer(k, false, adminReset)
+}
+
+// DisablePeerByKey disables the peer matching k, see DisablePeer.
+func (s *Server) DisablePeerByKey(k PeerKey) error {
+	return s.adminPeer(k, false, adminDisable)
+}
+
+// EnablePeerByKey enables the peer matching k, see EnablePeer.
+func (s *Server) EnablePeerByKey(k PeerKey) error {
+	return s.adminPeer(k, false, adminEnable)
+}
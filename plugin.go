@@ -1,10 +1,17 @@
 package corebgp
 
+import (
+	"net"
+)
+
 // Plugin is a BGP peer plugin.
 type Plugin interface {
 	// GetCapabilities is fired when a peer's FSM is in the Connect state prior
 	// to sending an Open message. The returned capabilities are included in the
 	// Open message sent to the peer.
+	//
+	// If the Plugin also implements ConnCapabilitiesPlugin GetCapabilities is
+	// not called.
 	GetCapabilities(peer *PeerConfig) []*Capability
 
 	// OnOpenMessage is fired when an Open message is received from a peer
@@ -30,9 +37,58 @@ type Plugin interface {
 	OnClose(peer *PeerConfig)
 }
 
-// UpdateMessageHandler handles Update messages. If a non-nil Notification is
-// returned it will be sent to the peer and the FSM will transition out of the
-// Established state.
+// ConnInfo describes the TCP connection of a peer's FSM.
+type ConnInfo struct {
+	// Inbound is true if the connection was accepted from the peer, and false
+	// if it was dialed.
+	Inbound    bool
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+}
+
+// ConnCapabilitiesPlugin may optionally be implemented by a Plugin in order to
+// advertise different capabilities depending on the connection, e.g. for
+// asymmetric setups like passive collectors.
+type ConnCapabilitiesPlugin interface {
+	// GetConnCapabilities is fired in place of GetCapabilities prior to
+	// sending an Open message on the connection described by conn. The
+	// returned capabilities are included in the Open message sent to the
+	// peer.
+	GetConnCapabilities(peer *PeerConfig, conn ConnInfo) []*Capability
+}
+
+// SessionPlugin may optionally be implemented by a Plugin in order to receive
+// the details of an established session, e.g. the AS of a peer added with
+// AnyRemoteAS.
+type SessionPlugin interface {
+	// OnEstablishedSession is fired in place of OnEstablished when a peer's
+	// FSM transitions to the Established state. session describes the
+	// established session and must not be modified.
+	OnEstablishedSession(peer *PeerConfig, session *SessionInfo,
+		writer UpdateMessageWriter) UpdateMessageHandler
+}
+
+// DecodedUpdatePlugin may optionally be implemented by a Plugin in order to
+// receive UPDATE messages decoded by corebgp rather than raw bytes. Decoding
+// takes ADD-PATH path identifiers into account for the AFI/SAFIs negotiated
+// for receiving them, and applies the error handling of RFC7606: erroneous
+// attributes are discarded, updates are treated as a withdrawal
+// (DecodedUpdate.TreatAsWithdraw), or the session is reset. Notifications
+// resulting from decoding are subject to UpdateErrorPolicy.
+type DecodedUpdatePlugin interface {
+	// OnEstablishedDecoded is fired in place of OnEstablished and
+	// OnEstablishedSession when a peer's FSM transitions to the Established
+	// state. session describes the established session and must not be
+	// modified.
+	OnEstablishedDecoded(peer *PeerConfig, session *SessionInfo,
+		writer UpdateMessageWriter) DecodedUpdateHandler
+}
+
+// UpdateMessageHandler handles Update messages. updateMessage is the message
+// body excluding the header, whose fields may be accessed via UpdateMessage.
+// If a non-nil Notification is returned it will be sent to the peer and the
+// FSM will transition out of the Established state, unless classified
+// otherwise via UpdateErrorPolicy.
 type UpdateMessageHandler func(peer *PeerConfig, updateMessage []byte) *Notification
 
 type UpdateMessageWriter interface {
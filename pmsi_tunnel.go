@@ -0,0 +1,96 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// PMSI tunnel type values
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#pmsi-tunnel-types
+const (
+	PMSITunnelTypeNoTunnelInfo        uint8 = 0
+	PMSITunnelTypeRSVPTEP2MP          uint8 = 1
+	PMSITunnelTypeMLDPP2MP            uint8 = 2
+	PMSITunnelTypePIMSSM              uint8 = 3
+	PMSITunnelTypePIMSM               uint8 = 4
+	PMSITunnelTypeBIDIRPIM            uint8 = 5
+	PMSITunnelTypeIngressReplication  uint8 = 6
+	PMSITunnelTypeMLDPMP2MP           uint8 = 7
+	PMSITunnelTypeTransportTunnel     uint8 = 8
+	PMSITunnelTypeAssistedReplication uint8 = 10
+	PMSITunnelTypeBIER                uint8 = 11
+)
+
+// PMSI tunnel flag values
+const (
+	PMSITunnelFlagLeafInfoRequired uint8 = 0x01
+)
+
+// PMSITunnel is the value of a PMSI_TUNNEL path attribute.
+// https://tools.ietf.org/html/rfc6514#section-5
+type PMSITunnel struct {
+	Flags      uint8
+	TunnelType uint8
+	// Label is the 3 octet MPLS Label field. For MPLS encapsulation it
+	// carries a 20 bit label in the high-order bits, see MPLSLabel(). For
+	// VXLAN encapsulation (RFC8365) it carries a 24 bit VNI.
+	Label uint32
+	// TunnelID is the Tunnel Identifier, whose format depends on TunnelType.
+	TunnelID []byte
+}
+
+// MPLSLabel returns the 20 bit MPLS label carried in the Label field.
+func (p *PMSITunnel) MPLSLabel() uint32 {
+	return p.Label >> 4
+}
+
+// SetMPLSLabel sets the Label field to carry a 20 bit MPLS label.
+func (p *PMSITunnel) SetMPLSLabel(label uint32) {
+	p.Label = (label & 0xFFFFF) << 4
+}
+
+// IngressReplicationEndpoint returns the tunnel endpoint address of an
+// Ingress Replication tunnel.
+func (p *PMSITunnel) IngressReplicationEndpoint() (net.IP, error) {
+	if p.TunnelType != PMSITunnelTypeIngressReplication {
+		return nil, fmt.Errorf("pmsi tunnel type %d is not ingress replication",
+			p.TunnelType)
+	}
+	if len(p.TunnelID) != net.IPv4len && len(p.TunnelID) != net.IPv6len {
+		return nil, fmt.Errorf("invalid ingress replication tunnel id "+
+			"length: %d", len(p.TunnelID))
+	}
+	ip := make(net.IP, len(p.TunnelID))
+	copy(ip, p.TunnelID)
+	return ip, nil
+}
+
+// Decode decodes a PMSI_TUNNEL attribute value.
+func (p *PMSITunnel) Decode(b []byte) error {
+	if len(b) < 5 {
+		return fmt.Errorf("invalid pmsi tunnel length: %d", len(b))
+	}
+	p.Flags = b[0]
+	p.TunnelType = b[1]
+	p.Label = uint32(b[2])<<16 | uint32(b[3])<<8 | uint32(b[4])
+	p.TunnelID = make([]byte, len(b)-5)
+	copy(p.TunnelID, b[5:])
+	return nil
+}
+
+// Encode encodes the PMSI_TUNNEL attribute value.
+func (p *PMSITunnel) Encode() ([]byte, error) {
+	if p.Label > 0xFFFFFF {
+		return nil, errors.New("pmsi tunnel label exceeds 3 octets")
+	}
+	if len(p.TunnelID)+5 > math.MaxUint16 {
+		return nil, errors.New("pmsi tunnel id too long")
+	}
+	b := make([]byte, 0, 5+len(p.TunnelID))
+	b = append(b, p.Flags, p.TunnelType, uint8(p.Label>>16),
+		uint8(p.Label>>8), uint8(p.Label))
+	b = append(b, p.TunnelID...)
+	return b, nil
+}
@@ -0,0 +1,97 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// OutboundPolicyFunc is applied to updates written to a peer via
+// UpdateMessageWriter.WriteUpdate, see OutboundPolicy. It returns the update
+// to send, which may be u modified in place, or nil to drop it, in which case
+// WriteUpdate returns nil. A non-nil error is returned by WriteUpdate, and
+// the update is not sent.
+//
+// It is called in the goroutine calling WriteUpdate, and may block in order
+// to delay the update. Should the session close in the meantime, WriteUpdate
+// returns an error once it returns.
+type OutboundPolicyFunc func(peer *PeerConfig,
+	u *DecodedUpdate) (*DecodedUpdate, error)
+
+// OutboundPolicy returns a PeerOption that applies fn to each update written
+// to a peer, allowing policy to be applied to the updates of writer-based
+// Plugins. Updates are decoded with the session's Codec, and re-encoded via
+// DecodedUpdate.Encode unless dropped. An update that fails to decode, or
+// would be treated as a withdrawal or have attributes discarded by the peer
+// (RFC7606), is not passed to fn, instead an error is returned by
+// WriteUpdate. The policy is applied before any ASOverride.
+func OutboundPolicy(fn OutboundPolicyFunc) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.outboundPolicy = fn
+	})
+}
+
+// outboundPolicy returns a function applying fn to encoded updates written
+// to the peer of f, returning nil if fn drops an update.
+func (f *fsm) outboundPolicy(
+	fn OutboundPolicyFunc) func([]byte) ([]byte, error) {
+	d := newUpdateDecoder(f.codec, AddPathSend)
+	return func(b []byte) ([]byte, error) {
+		u, n := d.decode(b)
+		if n != nil {
+			return nil, fmt.Errorf("invalid update: %s", n)
+		}
+		if u.TreatAsWithdraw || len(u.DiscardedAttrs) > 0 {
+			return nil, errors.New("invalid update: malformed path " +
+				"attributes")
+		}
+		u, err := fn(f.peer.config, u)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, nil
+		}
+		return d.encode(u)
+	}
+}
+
+// InboundPolicyFunc is applied to updates received from a peer, see
+// InboundPolicy. It returns the update to deliver to the Plugin, which may be
+// u modified in place, or nil to drop it.
+type InboundPolicyFunc func(peer *PeerConfig, u *DecodedUpdate) *DecodedUpdate
+
+// InboundPolicy returns a PeerOption that applies fn to each update received
+// from a peer before it is handled by the Plugin, allowing import policy to
+// be applied independently of the Plugin's RIB. Updates are decoded with the
+// session's Codec, applying the error handling of DecodedUpdatePlugin, and
+// passed to fn after any RFC7606 treat-as-withdraw. Unless the Plugin is a
+// DecodedUpdatePlugin, updates returned by fn are re-encoded via the
+// encoding of DecodedUpdate.Encode for the receive direction before they are
+// handled. Dropped updates are counted in PeerCounters.UpdatesFiltered.
+func InboundPolicy(fn InboundPolicyFunc) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.inboundPolicy = fn
+	})
+}
+
+// inboundPolicyHandler returns an UpdateMessageHandler applying the peer's
+// InboundPolicy, if any, to updates before they are handled by handler.
+func (f *fsm) inboundPolicyHandler(
+	handler UpdateMessageHandler) UpdateMessageHandler {
+	if handler == nil || f.peer.options.inboundPolicy == nil {
+		return handler
+	}
+	d := newUpdateDecoder(f.codec, AddPathReceive)
+	return f.decodingHandler(func(peer *PeerConfig,
+		u *DecodedUpdate) *Notification {
+		b, err := d.encode(u)
+		if err != nil {
+			logf("[%s] dropping update modified by inbound policy: %v",
+				peer.IP, err)
+			atomic.AddUint64(&f.peer.counters.updatesFiltered, 1)
+			return nil
+		}
+		return handler(peer, b)
+	})
+}
@@ -0,0 +1,349 @@
+// Package prefixtrie provides a path-compressed binary trie of IPv4 and IPv6
+// prefixes supporting longest-prefix match, covering and covered prefix
+// lookups, and ordered iteration. Nodes exist only for stored prefixes and
+// the branching points between them, keeping memory proportional to the
+// number of prefixes for BGP-scale tables.
+//
+// A Trie is not safe for concurrent use; callers must synchronize access.
+package prefixtrie
+
+import (
+	"errors"
+	"net"
+)
+
+var errInvalidPrefix = errors.New("invalid prefix")
+
+// key is an address left-aligned in 16 bytes, of which only the first 4 are
+// used for IPv4.
+type key [net.IPv6len]byte
+
+// bit returns bit i of k, counting from the most significant bit.
+func (k *key) bit(i int) int {
+	return int(k[i/8]>>(7-uint(i%8))) & 1
+}
+
+// mask returns k with all bits from bits onwards cleared.
+func (k key) mask(bits int) key {
+	for i := bits; i < len(k)*8; {
+		if i%8 == 0 {
+			k[i/8] = 0
+			i += 8
+			continue
+		}
+		k[i/8] &^= 1 << (7 - uint(i%8))
+		i++
+	}
+	return k
+}
+
+// commonBits returns the number of leading bits shared by a and b, up to max.
+func commonBits(a, b *key, max int) int {
+	n := 0
+	for i := 0; i < len(a) && n < max; i++ {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			n += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			n++
+			x <<= 1
+		}
+		break
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+type node struct {
+	key      key
+	bits     int
+	hasValue bool
+	value    interface{}
+	child    [2]*node
+}
+
+// Trie is a prefix trie mapping IPv4 and IPv6 prefixes to values. The zero
+// value is an empty Trie ready for use.
+type Trie struct {
+	v4, v6 *node
+	len    int
+}
+
+// New returns an empty Trie.
+func New() *Trie {
+	return &Trie{}
+}
+
+// Len returns the number of prefixes stored in the Trie.
+func (t *Trie) Len() int {
+	return t.len
+}
+
+// root returns the root of the address family of ip along with its address
+// length in bits, or nil if ip is invalid.
+func (t *Trie) root(ip net.IP) (**node, key, int) {
+	var k key
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(k[:], ip4)
+		return &t.v4, k, 32
+	}
+	if len(ip) == net.IPv6len {
+		copy(k[:], ip)
+		return &t.v6, k, 128
+	}
+	return nil, k, 0
+}
+
+// lookup returns the root, masked key and length of prefix.
+func (t *Trie) lookup(prefix *net.IPNet) (**node, key, int, error) {
+	if prefix == nil {
+		return nil, key{}, 0, errInvalidPrefix
+	}
+	root, k, addrBits := t.root(prefix.IP)
+	ones, bits := prefix.Mask.Size()
+	if root == nil || bits != addrBits && !(addrBits == 32 && bits == 128 &&
+		ones >= 96) {
+		return nil, key{}, 0, errInvalidPrefix
+	}
+	if bits == 128 && addrBits == 32 {
+		// IPv4 prefix with a 16 byte mask
+		ones -= 96
+	}
+	return root, k.mask(ones), ones, nil
+}
+
+func (n *node) prefix(addrBits int) *net.IPNet {
+	ip := make(net.IP, addrBits/8)
+	copy(ip, n.key[:])
+	return &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(n.bits, addrBits),
+	}
+}
+
+// Insert stores value for prefix, replacing any existing value. An error is
+// returned if prefix is not a valid IPv4 or IPv6 prefix.
+func (t *Trie) Insert(prefix *net.IPNet, value interface{}) error {
+	link, k, bits, err := t.lookup(prefix)
+	if err != nil {
+		return err
+	}
+	for {
+		n := *link
+		if n == nil {
+			*link = &node{key: k, bits: bits, hasValue: true, value: value}
+			t.len++
+			return nil
+		}
+		common := commonBits(&n.key, &k, min(n.bits, bits))
+		switch {
+		case common == n.bits && common == bits:
+			if !n.hasValue {
+				t.len++
+			}
+			n.hasValue = true
+			n.value = value
+			return nil
+		case common == n.bits:
+			// n covers prefix
+			link = &n.child[k.bit(n.bits)]
+			continue
+		case common == bits:
+			// prefix covers n
+			nn := &node{key: k, bits: bits, hasValue: true, value: value}
+			nn.child[n.key.bit(bits)] = n
+			*link = nn
+		default:
+			// prefix and n diverge, join them below a branching node
+			b := &node{key: k.mask(common), bits: common}
+			b.child[k.bit(common)] = &node{key: k, bits: bits,
+				hasValue: true, value: value}
+			b.child[n.key.bit(common)] = n
+			*link = b
+		}
+		t.len++
+		return nil
+	}
+}
+
+// Delete removes prefix from the Trie, returning false if it was not present.
+func (t *Trie) Delete(prefix *net.IPNet) bool {
+	link, k, bits, err := t.lookup(prefix)
+	if err != nil {
+		return false
+	}
+	n, ok := del(*link, &k, bits)
+	*link = n
+	if ok {
+		t.len--
+	}
+	return ok
+}
+
+func del(n *node, k *key, bits int) (*node, bool) {
+	if n == nil || n.bits > bits || commonBits(&n.key, k, n.bits) < n.bits {
+		return n, false
+	}
+	if n.bits == bits {
+		if !n.hasValue {
+			return n, false
+		}
+		n.hasValue = false
+		n.value = nil
+		return n.compact(), true
+	}
+	i := k.bit(n.bits)
+	c, ok := del(n.child[i], k, bits)
+	n.child[i] = c
+	if !ok {
+		return n, false
+	}
+	return n.compact(), true
+}
+
+// compact returns the node replacing n once n holds no value.
+func (n *node) compact() *node {
+	if n.hasValue || n.child[0] != nil && n.child[1] != nil {
+		return n
+	}
+	if n.child[0] != nil {
+		return n.child[0]
+	}
+	return n.child[1]
+}
+
+// Get returns the value stored for prefix.
+func (t *Trie) Get(prefix *net.IPNet) (interface{}, bool) {
+	link, k, bits, err := t.lookup(prefix)
+	if err != nil {
+		return nil, false
+	}
+	for n := *link; n != nil && n.bits <= bits; n = n.child[k.bit(n.bits)] {
+		if commonBits(&n.key, &k, n.bits) < n.bits {
+			break
+		}
+		if n.bits == bits {
+			return n.value, n.hasValue
+		}
+	}
+	return nil, false
+}
+
+// Covering calls fn for each stored prefix covering prefix, including prefix
+// itself, from the shortest to the longest, stopping if fn returns false.
+func (t *Trie) Covering(prefix *net.IPNet,
+	fn func(prefix *net.IPNet, value interface{}) bool) {
+	link, k, bits, err := t.lookup(prefix)
+	if err != nil {
+		return
+	}
+	addrBits := addrBits(link, t)
+	for n := *link; n != nil && n.bits <= bits; {
+		if commonBits(&n.key, &k, n.bits) < n.bits {
+			break
+		}
+		if n.hasValue && !fn(n.prefix(addrBits), n.value) {
+			return
+		}
+		if n.bits == bits {
+			break
+		}
+		n = n.child[k.bit(n.bits)]
+	}
+}
+
+// LongestMatch returns the longest stored prefix covering prefix, including
+// prefix itself, along with its value.
+func (t *Trie) LongestMatch(prefix *net.IPNet) (*net.IPNet, interface{},
+	bool) {
+	var (
+		match *net.IPNet
+		value interface{}
+	)
+	t.Covering(prefix, func(p *net.IPNet, v interface{}) bool {
+		match, value = p, v
+		return true
+	})
+	return match, value, match != nil
+}
+
+// Contains returns true if a stored prefix contains ip.
+func (t *Trie) Contains(ip net.IP) bool {
+	link, k, addrBits := t.root(ip)
+	if link == nil {
+		return false
+	}
+	for n := *link; n != nil; n = n.child[k.bit(n.bits)] {
+		if commonBits(&n.key, &k, n.bits) < n.bits {
+			return false
+		}
+		if n.hasValue {
+			return true
+		}
+		if n.bits == addrBits {
+			return false
+		}
+	}
+	return false
+}
+
+// Covered calls fn for each stored prefix covered by prefix, including prefix
+// itself, in address order with shorter prefixes first, stopping if fn
+// returns false.
+func (t *Trie) Covered(prefix *net.IPNet,
+	fn func(prefix *net.IPNet, value interface{}) bool) {
+	link, k, bits, err := t.lookup(prefix)
+	if err != nil {
+		return
+	}
+	addrBits := addrBits(link, t)
+	n := *link
+	for n != nil && n.bits < bits {
+		if commonBits(&n.key, &k, n.bits) < n.bits {
+			return
+		}
+		n = n.child[k.bit(n.bits)]
+	}
+	if n == nil || commonBits(&n.key, &k, bits) < bits {
+		return
+	}
+	walk(n, addrBits, fn)
+}
+
+// Walk calls fn for each stored prefix, IPv4 before IPv6, in address order
+// with shorter prefixes first, stopping if fn returns false.
+func (t *Trie) Walk(fn func(prefix *net.IPNet, value interface{}) bool) {
+	if walk(t.v4, 32, fn) {
+		walk(t.v6, 128, fn)
+	}
+}
+
+// walk walks the subtree of n in order, returning false if fn did.
+func walk(n *node, addrBits int,
+	fn func(prefix *net.IPNet, value interface{}) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.hasValue && !fn(n.prefix(addrBits), n.value) {
+		return false
+	}
+	return walk(n.child[0], addrBits, fn) && walk(n.child[1], addrBits, fn)
+}
+
+func addrBits(root **node, t *Trie) int {
+	if root == &t.v4 {
+		return 32
+	}
+	return 128
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
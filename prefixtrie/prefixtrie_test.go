@@ -0,0 +1,338 @@
+package prefixtrie
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+// mustPrefix parses s, failing t on error.
+func mustPrefix(t testing.TB, s string) *net.IPNet {
+	t.Helper()
+	_, p, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTrie(t *testing.T) {
+	cases := []struct {
+		name    string
+		inserts []string
+		deletes []string
+		// lookups maps a prefix looked up to its longest match, or "" if
+		// there is none
+		lookups map[string]string
+	}{
+		{
+			name:    "empty",
+			lookups: map[string]string{"10.0.0.0/8": "", "::/0": ""},
+		},
+		{
+			name:    "v4 default route",
+			inserts: []string{"0.0.0.0/0"},
+			lookups: map[string]string{
+				"0.0.0.0/0":          "0.0.0.0/0",
+				"192.0.2.1/32":       "0.0.0.0/0",
+				"255.255.255.255/32": "0.0.0.0/0",
+				"::/0":               "",
+			},
+		},
+		{
+			name:    "v6 default route",
+			inserts: []string{"::/0"},
+			lookups: map[string]string{
+				"::/0":            "::/0",
+				"2001:db8::/32":   "::/0",
+				"2001:db8::1/128": "::/0",
+				"0.0.0.0/0":       "",
+			},
+		},
+		{
+			name:    "v4 host routes",
+			inserts: []string{"192.0.2.1/32", "192.0.2.2/32"},
+			lookups: map[string]string{
+				"192.0.2.1/32": "192.0.2.1/32",
+				"192.0.2.2/32": "192.0.2.2/32",
+				"192.0.2.3/32": "",
+				"192.0.2.0/24": "",
+			},
+		},
+		{
+			name:    "v6 host routes",
+			inserts: []string{"2001:db8::1/128", "2001:db8::2/128"},
+			lookups: map[string]string{
+				"2001:db8::1/128": "2001:db8::1/128",
+				"2001:db8::2/128": "2001:db8::2/128",
+				"2001:db8::3/128": "",
+				"2001:db8::/64":   "",
+			},
+		},
+		{
+			name: "v4 overlapping",
+			inserts: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24",
+				"10.1.2.3/32", "10.128.0.0/9"},
+			lookups: map[string]string{
+				"10.1.2.3/32":   "10.1.2.3/32",
+				"10.1.2.4/32":   "10.1.2.0/24",
+				"10.1.3.0/24":   "10.1.0.0/16",
+				"10.2.0.0/16":   "10.0.0.0/8",
+				"10.200.0.1/32": "10.128.0.0/9",
+				"10.0.0.0/7":    "",
+				"11.0.0.0/8":    "",
+			},
+		},
+		{
+			name: "v6 overlapping",
+			inserts: []string{"2001:db8::/32", "2001:db8:1::/48",
+				"2001:db8:1:2::/64", "2001:db8:1:2::1/128"},
+			lookups: map[string]string{
+				"2001:db8:1:2::1/128": "2001:db8:1:2::1/128",
+				"2001:db8:1:2::2/128": "2001:db8:1:2::/64",
+				"2001:db8:1:3::/64":   "2001:db8:1::/48",
+				"2001:db8:2::/48":     "2001:db8::/32",
+				"2001:db9::/32":       "",
+			},
+		},
+		{
+			name: "v4 delete covering prefix",
+			inserts: []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16",
+				"10.1.2.0/24"},
+			deletes: []string{"10.1.0.0/16", "0.0.0.0/0"},
+			lookups: map[string]string{
+				"10.1.2.1/32": "10.1.2.0/24",
+				"10.1.3.1/32": "10.0.0.0/8",
+				"11.0.0.0/8":  "",
+			},
+		},
+		{
+			name: "v6 delete covered prefix",
+			inserts: []string{"::/0", "2001:db8::/32", "2001:db8::1/128",
+				"2001:db8::2/128"},
+			deletes: []string{"2001:db8::1/128", "::/0"},
+			lookups: map[string]string{
+				"2001:db8::1/128": "2001:db8::/32",
+				"2001:db8::2/128": "2001:db8::2/128",
+				"2001:db9::/32":   "",
+			},
+		},
+		{
+			name:    "delete absent prefixes",
+			inserts: []string{"10.0.0.0/8", "2001:db8::/32"},
+			deletes: []string{"10.0.0.0/9", "10.0.0.0/7", "0.0.0.0/0",
+				"2001:db8::/33", "::/0"},
+			lookups: map[string]string{
+				"10.0.0.0/9":    "10.0.0.0/8",
+				"2001:db8::/48": "2001:db8::/32",
+			},
+		},
+		{
+			name:    "delete everything",
+			inserts: []string{"0.0.0.0/0", "10.0.0.0/8", "::/0", "::1/128"},
+			deletes: []string{"10.0.0.0/8", "0.0.0.0/0", "::1/128", "::/0"},
+			lookups: map[string]string{
+				"10.0.0.0/8": "",
+				"::1/128":    "",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			trie := New()
+			stored := make(map[string]bool)
+			for _, s := range c.inserts {
+				if err := trie.Insert(mustPrefix(t, s), s); err != nil {
+					t.Fatalf("Insert(%s): %v", s, err)
+				}
+				stored[s] = true
+			}
+			for _, s := range c.deletes {
+				if trie.Delete(mustPrefix(t, s)) != stored[s] {
+					t.Fatalf("Delete(%s) = %v, want %v", s, !stored[s],
+						stored[s])
+				}
+				delete(stored, s)
+			}
+			if trie.Len() != len(stored) {
+				t.Fatalf("Len() = %d, want %d", trie.Len(), len(stored))
+			}
+			for s := range stored {
+				v, ok := trie.Get(mustPrefix(t, s))
+				if !ok || v != s {
+					t.Errorf("Get(%s) = %v, %v", s, v, ok)
+				}
+			}
+			for s, want := range c.lookups {
+				match, v, ok := trie.LongestMatch(mustPrefix(t, s))
+				if want == "" {
+					if ok {
+						t.Errorf("LongestMatch(%s) = %s, want no match", s,
+							match)
+					}
+					continue
+				}
+				if !ok || match.String() != want || v != want {
+					t.Errorf("LongestMatch(%s) = %v %v, want %s", s, match,
+						v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrieInsertReplaces(t *testing.T) {
+	trie := New()
+	p := mustPrefix(t, "192.0.2.0/24")
+	trie.Insert(p, 1)
+	trie.Insert(p, 2)
+	if trie.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", trie.Len())
+	}
+	if v, _ := trie.Get(p); v != 2 {
+		t.Fatalf("Get() = %v, want 2", v)
+	}
+}
+
+func TestTrieInvalidPrefix(t *testing.T) {
+	trie := New()
+	if err := trie.Insert(nil, 1); err == nil {
+		t.Fatal("inserted a nil prefix")
+	}
+	p := &net.IPNet{IP: net.IP{192, 0, 2}, Mask: net.CIDRMask(24, 32)}
+	if err := trie.Insert(p, 1); err == nil {
+		t.Fatal("inserted a prefix with a 3 byte address")
+	}
+	p = &net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(24, 32)}
+	if err := trie.Insert(p, 1); err == nil {
+		t.Fatal("inserted an IPv6 prefix with an IPv4 mask")
+	}
+}
+
+// TestTrieRandom compares the trie with a linear scan of the stored prefixes
+// after random inserts and deletes.
+func TestTrieRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	trie := New()
+	stored := make(map[string]*net.IPNet)
+	random := func() *net.IPNet {
+		// few distinct leading bits so that prefixes overlap
+		ip := net.IP{10, byte(r.Intn(4)), byte(r.Intn(4)), byte(r.Intn(4))}
+		ones := r.Intn(33)
+		return &net.IPNet{IP: ip.Mask(net.CIDRMask(ones, 32)),
+			Mask: net.CIDRMask(ones, 32)}
+	}
+	for i := 0; i < 5000; i++ {
+		p := random()
+		if r.Intn(3) == 0 {
+			_, want := stored[p.String()]
+			if trie.Delete(p) != want {
+				t.Fatalf("Delete(%s) = %v", p, !want)
+			}
+			delete(stored, p.String())
+		} else {
+			if err := trie.Insert(p, p.String()); err != nil {
+				t.Fatal(err)
+			}
+			stored[p.String()] = p
+		}
+		if trie.Len() != len(stored) {
+			t.Fatalf("Len() = %d, want %d", trie.Len(), len(stored))
+		}
+
+		lookup := random()
+		var want *net.IPNet
+		for _, s := range stored {
+			if prefixLen(s) <= prefixLen(lookup) && s.Contains(lookup.IP) &&
+				(want == nil || prefixLen(s) > prefixLen(want)) {
+				want = s
+			}
+		}
+		match, _, ok := trie.LongestMatch(lookup)
+		switch {
+		case want == nil && ok:
+			t.Fatalf("LongestMatch(%s) = %s, want no match", lookup, match)
+		case want != nil && (!ok || match.String() != want.String()):
+			t.Fatalf("LongestMatch(%s) = %v, want %s", lookup, match, want)
+		}
+	}
+}
+
+func prefixLen(p *net.IPNet) int {
+	ones, _ := p.Mask.Size()
+	return ones
+}
+
+// fullTable returns n random IPv4 prefixes with a length distribution
+// resembling the global routing table, where most prefixes are /24s.
+func fullTable(n int) []*net.IPNet {
+	r := rand.New(rand.NewSource(1))
+	prefixes := make([]*net.IPNet, n)
+	for i := range prefixes {
+		ones := 24
+		switch x := r.Intn(100); {
+		case x < 10:
+			ones = 16 + r.Intn(4)
+		case x < 40:
+			ones = 20 + r.Intn(4)
+		}
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, r.Uint32())
+		mask := net.CIDRMask(ones, 32)
+		prefixes[i] = &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	}
+	return prefixes
+}
+
+// fullTableSize is about the number of IPv4 prefixes of a full table.
+const fullTableSize = 1000000
+
+func BenchmarkInsert(b *testing.B) {
+	prefixes := fullTable(fullTableSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie := New()
+		for _, p := range prefixes {
+			trie.Insert(p, nil)
+		}
+	}
+}
+
+func BenchmarkLongestMatch(b *testing.B) {
+	trie := New()
+	for _, p := range fullTable(fullTableSize) {
+		trie.Insert(p, nil)
+	}
+	r := rand.New(rand.NewSource(2))
+	hosts := make([]*net.IPNet, 4096)
+	for i := range hosts {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, r.Uint32())
+		hosts[i] = &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.LongestMatch(hosts[i%len(hosts)])
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	trie := New()
+	for _, p := range fullTable(fullTableSize) {
+		trie.Insert(p, nil)
+	}
+	r := rand.New(rand.NewSource(2))
+	hosts := make([]net.IP, 4096)
+	for i := range hosts {
+		hosts[i] = make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(hosts[i], r.Uint32())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Contains(hosts[i%len(hosts)])
+	}
+}
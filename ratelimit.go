@@ -0,0 +1,186 @@
+package corebgp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitAction is the action taken when a peer exceeds its
+// InboundRateLimit.
+type RateLimitAction uint8
+
+// RateLimitAction values
+const (
+	// RateLimitDelay delays reading from the peer's connection until the
+	// rate conforms to the limit, applying TCP backpressure to the peer. As
+	// KEEPALIVE messages are not read while delayed, delays should be short
+	// relative to the hold time.
+	RateLimitDelay RateLimitAction = iota
+	// RateLimitWarn logs and publishes an InboundRateLimitEvent at most once
+	// per second while the limit is exceeded.
+	RateLimitWarn
+	// RateLimitTeardown closes the session with a Cease NOTIFICATION with the
+	// Out of Resources subcode, and publishes an InboundRateLimitEvent.
+	RateLimitTeardown
+)
+
+func (a RateLimitAction) String() string {
+	switch a {
+	case RateLimitDelay:
+		return "delay"
+	case RateLimitWarn:
+		return "warn"
+	case RateLimitTeardown:
+		return "teardown"
+	}
+	return "unknown"
+}
+
+// RateLimit is a limit on the rate of UPDATE messages received from a peer.
+type RateLimit struct {
+	// UpdatesPerSecond is the maximum rate of UPDATE messages. Zero is
+	// unlimited.
+	UpdatesPerSecond int
+	// BytesPerSecond is the maximum rate of UPDATE message bytes, including
+	// headers. Zero is unlimited.
+	BytesPerSecond int
+	// Burst is the duration of traffic at the maximum rates that may be
+	// received at once, which defaults to one second.
+	Burst  time.Duration
+	Action RateLimitAction
+}
+
+// InboundRateLimit returns a PeerOption that limits the rate of UPDATE
+// messages received from a peer, protecting the Plugin from a misbehaving
+// peer monopolizing processing. UPDATE messages exceeding the limit are
+// counted by PeerCounters.UpdatesRateLimited.
+func InboundRateLimit(limit RateLimit) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if limit.Burst <= 0 {
+			limit.Burst = time.Second
+		}
+		o.inboundRateLimit = &limit
+	})
+}
+
+// tokenBucket is a token bucket permitting debt.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int, burst time.Duration, now time.Time) *tokenBucket {
+	b := &tokenBucket{
+		rate:  float64(rate),
+		burst: float64(rate) * burst.Seconds(),
+		last:  now,
+	}
+	b.tokens = b.burst
+	return b
+}
+
+// take takes n tokens, returning the duration until the bucket is out of
+// debt.
+func (b *tokenBucket) take(now time.Time, n int) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter applies a RateLimit to the UPDATE messages of a connection.
+type rateLimiter struct {
+	limit    RateLimit
+	updates  *tokenBucket
+	bytes    *tokenBucket
+	lastWarn time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	now := time.Now()
+	r := &rateLimiter{
+		limit: limit,
+	}
+	if limit.UpdatesPerSecond > 0 {
+		r.updates = newTokenBucket(limit.UpdatesPerSecond, limit.Burst, now)
+	}
+	if limit.BytesPerSecond > 0 {
+		r.bytes = newTokenBucket(limit.BytesPerSecond, limit.Burst, now)
+	}
+	return r
+}
+
+// take accounts for an UPDATE message of length n, returning the duration
+// for which it exceeds the limit.
+func (r *rateLimiter) take(now time.Time, n int) time.Duration {
+	var d time.Duration
+	if r.updates != nil {
+		d = r.updates.take(now, 1)
+	}
+	if r.bytes != nil {
+		if bd := r.bytes.take(now, n); bd > d {
+			d = bd
+		}
+	}
+	if d > 0 && r.limit.Action != RateLimitDelay {
+		// only delays pay off debt, otherwise the limit is measured afresh
+		for _, b := range []*tokenBucket{r.updates, r.bytes} {
+			if b != nil && b.tokens < 0 {
+				b.tokens = 0
+			}
+		}
+	}
+	return d
+}
+
+// rateLimit applies l to an UPDATE message of length n read from the
+// connection, returning false if the reader should stop.
+func (f *fsm) rateLimit(l *rateLimiter, n int) bool {
+	now := time.Now()
+	d := l.take(now, n)
+	if d == 0 {
+		return true
+	}
+	atomic.AddUint64(&f.peer.counters.updatesRateLimited, 1)
+	switch l.limit.Action {
+	case RateLimitDelay:
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-f.closeReaderCh:
+			return false
+		case <-t.C:
+			return true
+		}
+	case RateLimitWarn:
+		if now.Sub(l.lastWarn) >= time.Second {
+			l.lastWarn = now
+			logf("[%s] inbound rate limit exceeded", f.peer.config.IP)
+			f.peer.events.publish(&InboundRateLimitEvent{
+				eventBase: newEventBase(f.peer),
+				Action:    l.limit.Action,
+			})
+		}
+		return true
+	}
+	logf("[%s] inbound rate limit exceeded, closing session",
+		f.peer.config.IP)
+	f.peer.events.publish(&InboundRateLimitEvent{
+		eventBase: newEventBase(f.peer),
+		Action:    l.limit.Action,
+	})
+	notif := newNotification(NotifCodeCease, NotifSubcodeOutOfResources, nil)
+	select {
+	case <-f.closeReaderCh:
+	case f.readerErrCh <- newNotificationError(notif, true):
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package corebgp
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// DefaultReadBatchBufferSize is the size of the per-connection buffer of a
+// ReadBatcher if none is given, holding several messages of MaxMessageLength.
+const DefaultReadBatchBufferSize = 64 << 10
+
+var (
+	errReadBatcherClosed = errors.New("read batcher closed")
+	errBatchedReadClosed = errors.New("batched reader closed")
+)
+
+// ReadBatcher reads messages from the connections of the peers sharing it,
+// see SharedReadBatcher. It is experimental, and aimed at large collectors
+// where the syscall overhead of reading many sessions dominates.
+//
+// On Linux a single goroutine waits for any of the connections to become
+// readable via epoll, and reads as much as is available for a connection into
+// its ring buffer with one readv call, which the peer's FSM then decodes
+// messages from. A peer sending many small UPDATE messages is read with a
+// fraction of the syscalls of reading each message header and body
+// separately, and thousands of idle sessions are served by a single epoll
+// wait. io_uring is not used. On other platforms, and for connections that are
+// not TCP connections, e.g. those dialed via a SOCKS5 proxy, connections are
+// read directly by their FSM as without a ReadBatcher.
+//
+// See BenchmarkReadBatcher for a comparison with reading directly.
+type ReadBatcher struct {
+	bufferSize int
+	p          *batchPoller
+}
+
+// NewReadBatcher returns a ReadBatcher with a ring buffer of bufferSize bytes
+// per connection, or DefaultReadBatchBufferSize if bufferSize is not positive.
+// The buffers are reported by MemoryUsage.ReadBuffer. The ReadBatcher's
+// goroutine runs until Close is called.
+func NewReadBatcher(bufferSize int) (*ReadBatcher, error) {
+	if bufferSize <= 0 {
+		bufferSize = DefaultReadBatchBufferSize
+	}
+	p, err := newBatchPoller()
+	if err != nil {
+		return nil, err
+	}
+	return &ReadBatcher{
+		bufferSize: bufferSize,
+		p:          p,
+	}, nil
+}
+
+// Close stops the ReadBatcher. Sessions being read via it are closed, and
+// connections established afterwards are read directly.
+func (b *ReadBatcher) Close() error {
+	return b.p.close()
+}
+
+// SharedReadBatcher returns a PeerOption that reads messages from the peer's
+// connections via b, shared with other peers.
+func SharedReadBatcher(b *ReadBatcher) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.readBatcher = b
+	})
+}
+
+// reader returns a reader reading from conn via b, until done is closed.
+// release must be called once reading is done. ok is false if conn is not
+// batched and must be read directly.
+func (b *ReadBatcher) reader(conn net.Conn,
+	done <-chan struct{}) (r io.Reader, release func(), ok bool) {
+	var pending io.Reader
+	if rc, isReplay := conn.(*replayConn); isReplay {
+		pending = rc.buf
+		conn = rc.Conn
+	}
+	tc, isTCP := conn.(*net.TCPConn)
+	if !isTCP {
+		return nil, nil, false
+	}
+	r, release, ok = b.p.register(tc, b.bufferSize, done)
+	if ok && pending != nil {
+		r = io.MultiReader(pending, r)
+	}
+	return r, release, ok
+}
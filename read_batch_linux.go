@@ -0,0 +1,341 @@
+//go:build linux
+// +build linux
+
+package corebgp
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// batchEvents are the epoll events connections are registered for. A
+// connection is disarmed once an event is returned for it, and rearmed once
+// its buffer has room, so that a peer not being read is not polled.
+const batchEvents = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT
+
+// batchPoller reads the connections registered with it from a single
+// goroutine via epoll and readv.
+type batchPoller struct {
+	epfd int
+	// wake is a pipe written to by close to interrupt epoll_wait. Its read end
+	// is registered with id 0.
+	wake [2]int
+
+	// mu protects the fields below, and is held while epfd is used so that it
+	// is not closed concurrently
+	mu     sync.RWMutex
+	conns  map[int32]*batchedConn
+	nextID int32
+	closed bool
+}
+
+func newBatchPoller() (*batchPoller, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("epoll_create1", err)
+	}
+	p := &batchPoller{
+		epfd:  epfd,
+		conns: make(map[int32]*batchedConn),
+	}
+	err = syscall.Pipe2(p.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC)
+	if err != nil {
+		syscall.Close(epfd)
+		return nil, os.NewSyscallError("pipe2", err)
+	}
+	err = syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, p.wake[0],
+		&syscall.EpollEvent{Events: syscall.EPOLLIN})
+	if err != nil {
+		p.closeFDs()
+		return nil, os.NewSyscallError("epoll_ctl", err)
+	}
+	go p.poll()
+	return p, nil
+}
+
+func (p *batchPoller) closeFDs() {
+	syscall.Close(p.epfd)
+	syscall.Close(p.wake[0])
+	syscall.Close(p.wake[1])
+}
+
+func (p *batchPoller) close() error {
+	if !p.shutdown(errReadBatcherClosed) {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, err := syscall.Write(p.wake[1], []byte{0})
+	if err != nil {
+		return os.NewSyscallError("write", err)
+	}
+	return nil
+}
+
+// shutdown fails the connections registered with p with err. It returns false
+// if p was already shut down.
+func (p *batchPoller) shutdown(err error) bool {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return false
+	}
+	p.closed = true
+	conns := p.conns
+	p.conns = nil
+	p.mu.Unlock()
+	for _, c := range conns {
+		c.fail(err)
+	}
+	return true
+}
+
+// poll fills the buffers of readable connections until p is closed.
+func (p *batchPoller) poll() {
+	defer func() {
+		p.mu.Lock()
+		p.closeFDs()
+		p.mu.Unlock()
+	}()
+	events := make([]syscall.EpollEvent, 256)
+	for {
+		n, err := syscall.EpollWait(p.epfd, events, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			p.shutdown(os.NewSyscallError("epoll_wait", err))
+			return
+		}
+		for _, ev := range events[:n] {
+			if ev.Fd == 0 {
+				return
+			}
+			p.mu.RLock()
+			c := p.conns[ev.Fd]
+			p.mu.RUnlock()
+			if c != nil {
+				c.fill()
+			}
+		}
+	}
+}
+
+func (p *batchPoller) register(conn *net.TCPConn, size int,
+	done <-chan struct{}) (io.Reader, func(), bool) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, nil, false
+	}
+	c := &batchedConn{
+		p:     p,
+		raw:   raw,
+		done:  done,
+		ready: make(chan struct{}, 1),
+		buf:   make([]byte, size),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, nil, false
+	}
+	for {
+		p.nextID++
+		if p.nextID <= 0 {
+			p.nextID = 1
+		}
+		if _, used := p.conns[p.nextID]; !used {
+			break
+		}
+	}
+	c.id = p.nextID
+	if err := p.ctl(syscall.EPOLL_CTL_ADD, c); err != nil {
+		return nil, nil, false
+	}
+	p.conns[c.id] = c
+	return c, c.release, true
+}
+
+// ctl registers, rearms or removes c. p.mu must be held.
+func (p *batchPoller) ctl(op int, c *batchedConn) error {
+	var errno error
+	err := c.raw.Control(func(fd uintptr) {
+		errno = syscall.EpollCtl(p.epfd, op, int(fd),
+			&syscall.EpollEvent{Events: batchEvents, Fd: c.id})
+	})
+	if err != nil {
+		return err
+	}
+	if errno != nil {
+		return os.NewSyscallError("epoll_ctl", errno)
+	}
+	return nil
+}
+
+// batchedConn is a connection read by a batchPoller into a ring buffer, and
+// read from the buffer by the peer's FSM.
+type batchedConn struct {
+	p    *batchPoller
+	id   int32
+	raw  syscall.RawConn
+	done <-chan struct{}
+	// ready is signaled when data is added to buf or err is set
+	ready chan struct{}
+
+	// mu protects the fields below. buf is only written by the poller, to the
+	// free space following the n bytes from head.
+	mu   sync.Mutex
+	buf  []byte
+	head int
+	n    int
+	// paused is true if c is disarmed as buf is full
+	paused bool
+	err    error
+}
+
+func (c *batchedConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.n > 0 {
+			end := c.head + c.n
+			if end > len(c.buf) {
+				end = len(c.buf)
+			}
+			n := copy(b, c.buf[c.head:end])
+			if n < len(b) && n < c.n {
+				// the data wraps around
+				n += copy(b[n:], c.buf[:c.n-n])
+			}
+			c.head = (c.head + n) % len(c.buf)
+			c.n -= n
+			rearm := c.paused
+			c.paused = false
+			c.mu.Unlock()
+			if rearm {
+				c.arm()
+			}
+			return n, nil
+		}
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		select {
+		case <-c.ready:
+		case <-c.done:
+			return 0, errBatchedReadClosed
+		}
+	}
+}
+
+// fill reads the data available from c into its buffer with a single readv.
+func (c *batchedConn) fill() {
+	c.mu.Lock()
+	if c.n == 0 {
+		c.head = 0
+	}
+	var iov [2][]byte
+	if tail := c.head + c.n; tail < len(c.buf) {
+		iov[0] = c.buf[tail:]
+		iov[1] = c.buf[:c.head]
+	} else {
+		iov[0] = c.buf[tail-len(c.buf) : c.head]
+	}
+	c.mu.Unlock()
+
+	var n int
+	var errno error
+	err := c.raw.Read(func(fd uintptr) bool {
+		n, errno = readv(fd, iov[:])
+		return true
+	})
+
+	c.mu.Lock()
+	switch {
+	case err != nil:
+		c.err = err
+	case errno == syscall.EAGAIN || errno == syscall.EINTR:
+	case errno != nil:
+		c.err = os.NewSyscallError("readv", errno)
+	case n == 0:
+		c.err = io.EOF
+	default:
+		c.n += n
+	}
+	rearm := c.err == nil && c.n < len(c.buf)
+	c.paused = c.err == nil && !rearm
+	c.mu.Unlock()
+	c.signal()
+	if rearm {
+		c.arm()
+	}
+}
+
+// arm rearms c for the next event.
+func (c *batchedConn) arm() {
+	c.p.mu.RLock()
+	var err error
+	if !c.p.closed {
+		err = c.p.ctl(syscall.EPOLL_CTL_MOD, c)
+	}
+	c.p.mu.RUnlock()
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+func (c *batchedConn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *batchedConn) signal() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// release removes c from its poller.
+func (c *batchedConn) release() {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	if c.p.conns[c.id] != c {
+		return
+	}
+	delete(c.p.conns, c.id)
+	// the connection is removed from the epoll set regardless once closed
+	c.p.ctl(syscall.EPOLL_CTL_DEL, c)
+}
+
+// readv reads from fd into the non-empty buffers of iov.
+func readv(fd uintptr, iov [][]byte) (int, error) {
+	var vecs [2]syscall.Iovec
+	cnt := 0
+	for _, b := range iov {
+		if len(b) == 0 {
+			continue
+		}
+		vecs[cnt].Base = &b[0]
+		vecs[cnt].SetLen(len(b))
+		cnt++
+	}
+	if cnt == 0 {
+		return 0, syscall.EAGAIN
+	}
+	n, _, errno := syscall.Syscall(syscall.SYS_READV, fd,
+		uintptr(unsafe.Pointer(&vecs[0])), uintptr(cnt))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+import (
+	"io"
+	"net"
+)
+
+// batchPoller reads nothing, connections are read directly.
+type batchPoller struct{}
+
+func newBatchPoller() (*batchPoller, error) {
+	return &batchPoller{}, nil
+}
+
+func (p *batchPoller) close() error {
+	return nil
+}
+
+func (p *batchPoller) register(conn *net.TCPConn, size int,
+	done <-chan struct{}) (io.Reader, func(), bool) {
+	return nil, nil, false
+}
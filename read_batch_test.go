@@ -0,0 +1,230 @@
+package corebgp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// tcpPair returns the ends of a loopback TCP connection.
+func tcpPair(tb testing.TB) (client, server net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer ln.Close()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	server, err = ln.Accept()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return client, server
+}
+
+func TestReadBatcher(t *testing.T) {
+	// a buffer smaller than a message exercises wrapping around and pausing
+	// while the buffer is full
+	b, err := NewReadBatcher(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	const sessions = 8
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		client, server := tcpPair(t)
+		defer client.Close()
+		done := make(chan struct{})
+		defer close(done)
+		var msgs [][]byte
+		for j := 0; j < 64; j++ {
+			body := bytes.Repeat([]byte{byte(i), byte(j)},
+				2+(i*31+j*17)%900)
+			msgs = append(msgs, body)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer server.Close()
+			for _, body := range msgs {
+				_, err := server.Write(prependHeader(body, UpdateMessageType))
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		// a message peeked by the server is replayed before the data
+		// read via b
+		replay := &replayConn{Conn: client, buf: bytes.NewBuffer(
+			prependHeader(nil, KeepAliveMessageType))}
+		r, release, ok := b.reader(replay, done)
+		if !ok {
+			if runtime.GOOS != "linux" {
+				t.Skip("reads are only batched on linux")
+			}
+			t.Fatal("connection is not batched")
+		}
+		defer release()
+		reader := NewMessageReader(r)
+		msgType, body, err := reader.ReadMessage()
+		if err != nil || msgType != KeepAliveMessageType || len(body) != 0 {
+			t.Fatalf("replayed message: %d %x %v", msgType, body, err)
+		}
+		for j, want := range msgs {
+			msgType, body, err := reader.ReadMessage()
+			if err != nil {
+				t.Fatalf("session %d message %d: %v", i, j, err)
+			}
+			if msgType != UpdateMessageType || !bytes.Equal(body, want) {
+				t.Fatalf("session %d message %d: got %d %x, want %x", i, j,
+					msgType, body, want)
+			}
+		}
+		if _, _, err := reader.ReadMessage(); err != io.EOF {
+			t.Fatalf("session %d: got %v after the last message, want EOF",
+				i, err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestReadBatcherClose(t *testing.T) {
+	b, err := NewReadBatcher(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+	done := make(chan struct{})
+	r, release, ok := b.reader(client, done)
+	if !ok {
+		if runtime.GOOS != "linux" {
+			t.Skip("reads are only batched on linux")
+		}
+		t.Fatal("connection is not batched")
+	}
+	defer release()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		errCh <- err
+	}()
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != errReadBatcherClosed {
+		t.Fatalf("got %v reading from a closed batcher, want %v", err,
+			errReadBatcherClosed)
+	}
+	if _, _, ok := b.reader(client, done); ok {
+		t.Fatal("connection batched by a closed batcher")
+	}
+
+	close(done)
+	if _, err := r.Read(make([]byte, 1)); err == nil {
+		t.Fatal("read succeeded once done")
+	}
+}
+
+// benchmarkReadSessions reads b.N UPDATE messages of bodyLen bytes spread
+// over sessions loopback TCP connections, each read by its own goroutine via
+// a MessageReader reading from the reader wrap returns.
+func benchmarkReadSessions(b *testing.B, sessions, bodyLen int,
+	wrap func(net.Conn, <-chan struct{}) io.Reader) {
+	done := make(chan struct{})
+	defer close(done)
+	readers := make([]*MessageReader, sessions)
+	for i := range readers {
+		client, server := tcpPair(b)
+		defer client.Close()
+		n := b.N / sessions
+		if i < b.N%sessions {
+			n++
+		}
+		go func() {
+			defer server.Close()
+			msg := prependHeader(make([]byte, bodyLen), UpdateMessageType)
+			batch := make([]byte, 0, 64<<10)
+			for len(batch)+len(msg) <= cap(batch) {
+				batch = append(batch, msg...)
+			}
+			perBatch := len(batch) / len(msg)
+			for ; n > 0; n -= perBatch {
+				if n < perBatch {
+					batch = batch[:n*len(msg)]
+				}
+				if _, err := server.Write(batch); err != nil {
+					return
+				}
+			}
+		}()
+		readers[i] = NewMessageReader(wrap(client, done))
+	}
+	b.SetBytes(int64(HeaderLength + bodyLen))
+	b.ReportAllocs()
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for i, reader := range readers {
+		n := b.N / sessions
+		if i < b.N%sessions {
+			n++
+		}
+		wg.Add(1)
+		go func(reader *MessageReader, n int) {
+			defer wg.Done()
+			for ; n > 0; n-- {
+				if _, _, err := reader.ReadMessage(); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(reader, n)
+	}
+	wg.Wait()
+}
+
+// BenchmarkReadBatcher compares reading sessions via a ReadBatcher with
+// reading each session directly from its FSM's goroutine.
+func BenchmarkReadBatcher(b *testing.B) {
+	batcher, err := NewReadBatcher(0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer batcher.Close()
+	direct := func(conn net.Conn, done <-chan struct{}) io.Reader {
+		return conn
+	}
+	batched := func(conn net.Conn, done <-chan struct{}) io.Reader {
+		if r, _, ok := batcher.reader(conn, done); ok {
+			return r
+		}
+		return conn
+	}
+	for _, c := range []struct {
+		name     string
+		sessions int
+		bodyLen  int
+		wrap     func(net.Conn, <-chan struct{}) io.Reader
+	}{
+		{"small/1/direct", 1, 64, direct},
+		{"small/1/batched", 1, 64, batched},
+		{"small/256/direct", 256, 64, direct},
+		{"small/256/batched", 256, 64, batched},
+		{"large/256/direct", 256, MaxMessageLength - HeaderLength, direct},
+		{"large/256/batched", 256, MaxMessageLength - HeaderLength, batched},
+	} {
+		c := c
+		b.Run(c.name, func(b *testing.B) {
+			benchmarkReadSessions(b, c.sessions, c.bodyLen, c.wrap)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package corebgp
+
+import (
+	"strconv"
+)
+
+// ASRange is an inclusive range of AS numbers.
+type ASRange struct {
+	First uint32
+	Last  uint32
+}
+
+// Contains returns true if asn is within r.
+func (r ASRange) Contains(asn uint32) bool {
+	return asn >= r.First && asn <= r.Last
+}
+
+// String returns r in the form "first-last", or "first" if r contains a
+// single AS.
+func (r ASRange) String() string {
+	if r.First == r.Last {
+		return strconv.FormatUint(uint64(r.First), 10)
+	}
+	return strconv.FormatUint(uint64(r.First), 10) + "-" +
+		strconv.FormatUint(uint64(r.Last), 10)
+}
+
+// RemoteASRanges returns a PeerOption that accepts OPEN messages from a peer
+// whose AS is within any of ranges, e.g. the ASes of the members of an IXP
+// route server, or the member ASes of a confederation. PeerConfig.RemoteAS
+// may be zero, otherwise it is accepted as well. The AS learned from the
+// peer's OPEN message is available via SessionInfo.RemoteAS.
+//
+// If ranges contain PeerConfig.LocalAS, e.g. listing the member ASes of the
+// local confederation, the BGP identifier of the peer must differ from the
+// local one whatever its AS, as identifiers are unique within a
+// confederation (RFC5065).
+func RemoteASRanges(ranges ...ASRange) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.remoteASRanges = append(o.remoteASRanges, ranges...)
+	})
+}
+
+// RemoteASSet returns a PeerOption that accepts OPEN messages from a peer
+// whose AS is any of asns. See RemoteASRanges.
+func RemoteASSet(asns ...uint32) PeerOption {
+	ranges := make([]ASRange, 0, len(asns))
+	for _, asn := range asns {
+		ranges = append(ranges, ASRange{First: asn, Last: asn})
+	}
+	return RemoteASRanges(ranges...)
+}
+
+// inRemoteASRanges returns true if asn is within the RemoteASRanges of the
+// peer.
+func (p *peer) inRemoteASRanges(asn uint32) bool {
+	for _, r := range p.options.remoteASRanges {
+		if r.Contains(asn) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsRemoteAS returns true if asn is an acceptable AS of the peer.
+func (p *peer) acceptsRemoteAS(asn uint32) bool {
+	if fn := p.options.openValidation.RemoteAS; fn != nil {
+		return fn(asn)
+	}
+	return p.options.anyRemoteAS || asn == p.config.RemoteAS ||
+		p.inRemoteASRanges(asn)
+}
+
+// openValidationPolicy returns the policy OPEN messages received from the
+// peer are validated with.
+func (p *peer) openValidationPolicy() *OpenValidationPolicy {
+	policy := p.options.openValidation
+	if p.inRemoteASRanges(p.config.LocalAS) {
+		policy.DistinctBGPID = true
+	}
+	return &policy
+}
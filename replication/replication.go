@@ -0,0 +1,437 @@
+//go:build go1.18
+// +build go1.18
+
+// Package replication streams the peer session state and Adj-RIB-In of a
+// primary corebgp instance to a warm standby, enabling fast failover of
+// collectors and route servers.
+//
+// The primary serves the replication stream via the http.Handler returned by
+// NewHandler, which should be protected from untrusted clients:
+//
+//	mux.Handle("/replication", replication.NewHandler(srv, r))
+//
+// The standby maintains a copy of the primary's rib.RIB along with the state
+// of its peers:
+//
+//	s := replication.NewStandby("http://primary:8179/replication", r)
+//	go s.Run(ctx)
+//
+// On failover the standby stops Run and starts its own Server with the peers
+// of Peers, advertising the Graceful Restart capability with the Restart
+// State bit set so that peers retain their routes while sessions are
+// re-established. The replicated RIB continues to serve routes in the
+// meantime.
+package replication
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/rib"
+)
+
+// Default option values
+const (
+	DefaultHeartbeatInterval = 10 * time.Second
+	DefaultRetryInterval     = 5 * time.Second
+)
+
+// PeerState is the replicated state of a peer of the primary.
+type PeerState struct {
+	Peer  net.IP
+	State corebgp.FSMState
+	// The following fields are set if the peer is in the Established state.
+	RemoteAS      uint32
+	RemoteID      net.IP
+	FourOctetAS   bool
+	Capabilities  []*corebgp.Capability
+	EstablishedAt time.Time
+}
+
+func peerStates(server *corebgp.Server) []PeerState {
+	statuses := server.ListPeers()
+	states := make([]PeerState, 0, len(statuses))
+	for _, s := range statuses {
+		p := PeerState{
+			Peer:  s.Config.IP,
+			State: s.State,
+		}
+		if s.Session != nil {
+			p.RemoteAS = s.Session.RemoteAS
+			p.RemoteID = s.Session.RemoteID
+			p.FourOctetAS = s.Session.FourOctetAS
+			p.Capabilities = s.Session.Capabilities
+			p.EstablishedAt = s.Session.EstablishedAt
+		}
+		states = append(states, p)
+	}
+	return states
+}
+
+// message is an element of the replication stream. A stream starts with a
+// message carrying the Epoch and Peers, along with a Snapshot unless the
+// standby's sequence number can be continued from the journal.
+type message struct {
+	// Epoch identifies the primary's RIB, whose sequence numbers may not be
+	// continued from those of another epoch.
+	Epoch    int64
+	Snapshot *rib.Snapshot
+	Changes  []rib.Change
+	Peers    []PeerState
+}
+
+type options struct {
+	heartbeatInterval time.Duration
+	retryInterval     time.Duration
+	client            *http.Client
+	errorHandler      func(error)
+}
+
+func (o *options) setDefaults() {
+	o.heartbeatInterval = DefaultHeartbeatInterval
+	o.retryInterval = DefaultRetryInterval
+	o.client = http.DefaultClient
+	o.errorHandler = func(error) {}
+}
+
+// Option is an option for a Handler or Standby.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// HeartbeatInterval sets the interval at which the primary sends empty
+// messages on an idle stream, which defaults to DefaultHeartbeatInterval. A
+// Standby considers the stream dead after three intervals without a message,
+// so both sides should use the same value.
+func HeartbeatInterval(d time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		if d > 0 {
+			o.heartbeatInterval = d
+		}
+	})
+}
+
+// RetryInterval sets the interval at which a Standby reconnects to the
+// primary after the stream failed, which defaults to DefaultRetryInterval.
+func RetryInterval(d time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		if d > 0 {
+			o.retryInterval = d
+		}
+	})
+}
+
+// Client sets the http.Client used by a Standby, which defaults to
+// http.DefaultClient. Its Timeout must be zero as the stream is long-lived.
+func Client(client *http.Client) Option {
+	return newFuncOption(func(o *options) {
+		if client != nil {
+			o.client = client
+		}
+	})
+}
+
+// ErrorHandler sets a function called with stream errors. It must not block.
+func ErrorHandler(fn func(error)) Option {
+	return newFuncOption(func(o *options) {
+		o.errorHandler = fn
+	})
+}
+
+type handler struct {
+	server  *corebgp.Server
+	rib     *rib.RIB
+	epoch   int64
+	options options
+}
+
+// NewHandler returns an http.Handler serving the replication stream of
+// server and r to standbys. The stream is served for GET requests with
+// optional "epoch" and "since" query parameters, the epoch and sequence
+// number of the standby's RIB as of its previous stream.
+func NewHandler(server *corebgp.Server, r *rib.RIB,
+	opts ...Option) http.Handler {
+	h := &handler{
+		server: server,
+		rib:    r,
+		epoch:  time.Now().UnixNano(),
+	}
+	h.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&h.options)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var since uint64
+	query := req.URL.Query()
+	if query.Get("epoch") == strconv.FormatInt(h.epoch, 10) {
+		var err error
+		since, err = strconv.ParseUint(query.Get("since"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// subscribe before reading state so that no change is missed
+	sub := h.server.Subscribe(64)
+	defer sub.Close()
+	ctx := req.Context()
+	ribCh := make(chan struct{}, 1)
+	go func() {
+		seq := h.rib.Seq()
+		for h.rib.Wait(ctx, seq) == nil {
+			seq = h.rib.Seq()
+			select {
+			case ribCh <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	enc := gob.NewEncoder(w)
+	send := func(m *message) bool {
+		if err := enc.Encode(m); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+	// a standby of another epoch, or without state, requires a snapshot
+	seq, snapshot := since, since == 0
+	catchUp := func(m *message) {
+		changes, err := h.rib.Changes(seq)
+		if err != nil || snapshot {
+			m.Snapshot = h.rib.Snapshot()
+			seq = m.Snapshot.Seq
+			snapshot = false
+			return
+		}
+		if len(changes) > 0 {
+			m.Changes = changes
+			seq = changes[len(changes)-1].Seq
+		}
+	}
+	m := &message{
+		Epoch: h.epoch,
+		Peers: peerStates(h.server),
+	}
+	catchUp(m)
+	if !send(m) {
+		return
+	}
+	heartbeat := time.NewTicker(h.options.heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		m := &message{}
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+		case e := <-sub.C():
+			if _, ok := e.(*corebgp.StateChangeEvent); !ok {
+				continue
+			}
+			m.Peers = peerStates(h.server)
+		case <-ribCh:
+			catchUp(m)
+		}
+		if !send(m) {
+			return
+		}
+	}
+}
+
+// Status is the status of a Standby.
+type Status struct {
+	// Connected is true if the stream from the primary is established.
+	Connected bool
+	// Seq is the sequence number of the replicated RIB.
+	Seq uint64
+	// LastMessage is the time the latest message was received from the
+	// primary.
+	LastMessage time.Time
+	// Err is the error that ended the latest stream, if any.
+	Err error
+}
+
+// Standby replicates the state of a primary into a rib.RIB.
+type Standby struct {
+	url     string
+	rib     *rib.RIB
+	options options
+
+	mu     sync.Mutex
+	epoch  int64
+	peers  []PeerState
+	status Status
+}
+
+// NewStandby returns a Standby replicating the stream served at url by the
+// primary's NewHandler into r, which should not be changed otherwise.
+func NewStandby(url string, r *rib.RIB, opts ...Option) *Standby {
+	s := &Standby{
+		url: url,
+		rib: r,
+	}
+	s.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&s.options)
+	}
+	return s
+}
+
+// Peers returns the state of the primary's peers as of the latest message.
+func (s *Standby) Peers() []PeerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PeerState(nil), s.peers...)
+}
+
+// Status returns the status of the Standby.
+func (s *Standby) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status
+	st.Seq = s.rib.Seq()
+	return st
+}
+
+// Run replicates the primary, reconnecting after failures, until ctx is done.
+func (s *Standby) Run(ctx context.Context) error {
+	for {
+		err := s.stream(ctx)
+		s.mu.Lock()
+		s.status.Connected = false
+		s.status.Err = err
+		s.mu.Unlock()
+		if ctx.Err() != nil {
+			return nil
+		}
+		s.options.errorHandler(err)
+		t := time.NewTimer(s.options.retryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Standby) stream(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	epoch := s.epoch
+	s.mu.Unlock()
+	url := s.url + "?epoch=" + strconv.FormatInt(epoch, 10) + "&since=" +
+		strconv.FormatUint(s.rib.Seq(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.options.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("replication stream %s responded %s", s.url,
+			resp.Status)
+	}
+
+	// cancel the stream if no message arrives within three heartbeats
+	received := make(chan struct{}, 1)
+	go func() {
+		timeout := 3 * s.options.heartbeatInterval
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-received:
+				if !t.Stop() {
+					<-t.C
+				}
+				t.Reset(timeout)
+			case <-t.C:
+				cancel()
+				return
+			}
+		}
+	}()
+
+	dec := gob.NewDecoder(resp.Body)
+	for {
+		m := &message{}
+		err = dec.Decode(m)
+		if err != nil {
+			if ctx.Err() != nil {
+				// the parent ctx is checked by Run
+				return errors.New("replication stream timed out")
+			}
+			return err
+		}
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		if m.Snapshot != nil {
+			err = s.rib.Restore(m.Snapshot)
+			if err != nil {
+				return err
+			}
+		}
+		if len(m.Changes) > 0 {
+			err = s.rib.Apply(m.Changes)
+			if err != nil {
+				return err
+			}
+		}
+		s.mu.Lock()
+		if m.Epoch != 0 {
+			s.epoch = m.Epoch
+		}
+		if m.Peers != nil {
+			s.peers = m.Peers
+		}
+		s.status.Connected = true
+		s.status.LastMessage = time.Now()
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,420 @@
+//go:build go1.18
+// +build go1.18
+
+package rib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/lookingglass"
+)
+
+// DefaultLocalPref is the LOCAL_PREF assumed by the decision process for
+// routes without the attribute, e.g. those received from external peers.
+const DefaultLocalPref = 100
+
+// AlwaysCompareMED compares the MED of routes regardless of their neighbor
+// AS. By default MEDs are only compared among routes from the same neighbor
+// AS, as required by RFC4271.
+func AlwaysCompareMED() Option {
+	return newFuncOption(func(o *options) {
+		o.alwaysCompareMED = true
+	})
+}
+
+// DeterministicMED groups routes by neighbor AS, selects the best route of
+// each group, and then the best among those, as configured on routers whose
+// default compares routes pairwise in the order they were received. The
+// decision process of RFC4271 section 9.1.2.2 followed by the RIB compares
+// MEDs among all routes from the same neighbor AS at once and is independent
+// of the order of routes, so it always selects the same route as
+// DeterministicMED. The option is accepted for parity with such
+// configurations.
+func DeterministicMED() Option {
+	return newFuncOption(func(o *options) {})
+}
+
+// MissingMEDAsWorst treats a missing MED as the highest possible value
+// rather than zero, i.e. the least preferred.
+// https://tools.ietf.org/html/rfc4271#section-9.1.2.2
+func MissingMEDAsWorst() Option {
+	return newFuncOption(func(o *options) {
+		o.missingMEDWorst = true
+	})
+}
+
+// Peer describes a peer to the decision process, see RIB.SetPeer.
+type Peer struct {
+	AS       uint32
+	RouterID net.IP
+	// Internal is true for IBGP peers.
+	Internal bool
+}
+
+// SetPeer sets the description of peer used by the decision process. Routes
+// of peers without a description are considered received from external
+// peers whose router ID is their address.
+func (r *RIB) SetPeer(peer net.IP, p Peer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peerInfo[peer.String()] = p
+}
+
+// SelectionMode determines the routes of a prefix selected for advertisement
+// to a peer, see RIB.Select.
+type SelectionMode uint8
+
+// SelectionMode values
+const (
+	// SelectBest selects the best route.
+	SelectBest SelectionMode = iota
+	// SelectBestExternal selects the best route received from an external
+	// peer, falling back to the best route if there is none. It allows IBGP
+	// peers to learn an alternative to an internal best route.
+	SelectBestExternal
+	// SelectAll selects all routes, for peers that negotiated ADD-PATH.
+	SelectAll
+	// SelectDiverse selects up to Selection.Paths routes with distinct next
+	// hops, for peers that negotiated ADD-PATH.
+	SelectDiverse
+)
+
+func (m SelectionMode) String() string {
+	switch m {
+	case SelectBest:
+		return "best"
+	case SelectBestExternal:
+		return "best-external"
+	case SelectAll:
+		return "all"
+	case SelectDiverse:
+		return "diverse"
+	}
+	return "unknown"
+}
+
+// Selection is the selection of routes advertised to a peer.
+type Selection struct {
+	Mode SelectionMode
+	// Paths is the maximum number of routes selected by SelectDiverse.
+	Paths int
+}
+
+type selectionKey struct {
+	peer string
+	afi  uint16
+	safi uint8
+}
+
+// SetSelection sets the Selection of routes of afi/safi advertised to peer,
+// which defaults to SelectBest.
+func (r *RIB) SetSelection(peer net.IP, afi uint16, safi uint8,
+	s Selection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selections[selectionKey{peer.String(), afi, safi}] = s
+}
+
+// candidate is a route along with the attributes considered by the decision
+// process.
+type candidate struct {
+	route      *lookingglass.Route
+	localPref  uint32
+	pathLen    int
+	origin     uint8
+	med        uint32
+	neighborAS uint32
+	external   bool
+	igpCost    uint32
+	routerID   net.IP
+	clusterLen int
+}
+
+func (r *RIB) newCandidate(rt *lookingglass.Route) *candidate {
+	c := &candidate{
+		route:     rt,
+		localPref: DefaultLocalPref,
+		pathLen:   rt.ASPath.Length(),
+		external:  true,
+		routerID:  rt.Peer.To16(),
+	}
+	if p, ok := r.peerInfo[rt.Peer.String()]; ok {
+		c.external = !p.Internal
+		c.routerID = p.RouterID.To16()
+	}
+	if len(rt.ASPath) > 0 &&
+		rt.ASPath[0].Type == corebgp.ASPathSegmentSequence {
+		c.neighborAS = rt.ASPath[0].ASNs[0]
+	}
+	if r.options.missingMEDWorst {
+		c.med = math.MaxUint32
+	}
+	if r.options.alwaysCompareMED {
+		// routes are grouped by neighbor AS for MED comparison
+		c.neighborAS = 0
+	}
+	rangeAttrs(rt.Attributes, func(attrType uint8, value []byte) {
+		switch attrType {
+		case corebgp.AttrTypeOrigin:
+			if len(value) == 1 {
+				c.origin = value[0]
+			}
+		case corebgp.AttrTypeMED:
+			if len(value) == 4 {
+				c.med = binary.BigEndian.Uint32(value)
+			}
+		case corebgp.AttrTypeLocalPref:
+			if len(value) == 4 && !c.external {
+				c.localPref = binary.BigEndian.Uint32(value)
+			}
+		case corebgp.AttrTypeOriginatorID:
+			if len(value) == 4 {
+				c.routerID = net.IP(value).To16()
+			}
+		case corebgp.AttrTypeClusterList:
+			c.clusterLen = len(value) / 4
+		}
+	})
+	return c
+}
+
+// rangeAttrs calls fn for each path attribute of b.
+func rangeAttrs(b []byte, fn func(attrType uint8, value []byte)) {
+	for len(b) >= 3 {
+		flags, attrType := corebgp.AttrFlags(b[0]), b[1]
+		headerLen, valueLen := 3, int(b[2])
+		if flags.ExtendedLength() {
+			if len(b) < 4 {
+				return
+			}
+			headerLen, valueLen = 4, int(binary.BigEndian.Uint16(b[2:]))
+		}
+		if len(b) < headerLen+valueLen {
+			return
+		}
+		fn(attrType, b[headerLen:headerLen+valueLen])
+		b = b[headerLen+valueLen:]
+	}
+}
+
+// filter returns the candidates for which keep returns true.
+func filter(cands []*candidate, keep func(c *candidate) bool) []*candidate {
+	kept := cands[:0:0]
+	for _, c := range cands {
+		if keep(c) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// best returns the most preferred of cands.
+// https://tools.ietf.org/html/rfc4271#section-9.1.2.2
+// https://tools.ietf.org/html/rfc4456#section-9
+func best(cands []*candidate) *candidate {
+	// highest LOCAL_PREF, i.e. degree of preference (section 9.1.1)
+	var localPref uint32
+	for _, c := range cands {
+		if c.localPref > localPref {
+			localPref = c.localPref
+		}
+	}
+	cands = filter(cands, func(c *candidate) bool {
+		return c.localPref == localPref
+	})
+	// a) shortest AS_PATH
+	pathLen := cands[0].pathLen
+	for _, c := range cands {
+		if c.pathLen < pathLen {
+			pathLen = c.pathLen
+		}
+	}
+	cands = filter(cands, func(c *candidate) bool {
+		return c.pathLen == pathLen
+	})
+	// b) lowest ORIGIN
+	origin := cands[0].origin
+	for _, c := range cands {
+		if c.origin < origin {
+			origin = c.origin
+		}
+	}
+	cands = filter(cands, func(c *candidate) bool {
+		return c.origin == origin
+	})
+	// c) lowest MED among routes from the same neighbor AS
+	med := make(map[uint32]uint32)
+	for _, c := range cands {
+		if m, ok := med[c.neighborAS]; !ok || c.med < m {
+			med[c.neighborAS] = c.med
+		}
+	}
+	cands = filter(cands, func(c *candidate) bool {
+		return c.med == med[c.neighborAS]
+	})
+	// d) routes from external peers
+	for _, c := range cands {
+		if c.external {
+			cands = filter(cands, func(c *candidate) bool {
+				return c.external
+			})
+			break
+		}
+	}
+	// e) lowest IGP cost to the next hop
+	cost := cands[0].igpCost
+	for _, c := range cands {
+		if c.igpCost < cost {
+			cost = c.igpCost
+		}
+	}
+	cands = filter(cands, func(c *candidate) bool {
+		return c.igpCost == cost
+	})
+	// f) lowest router ID or ORIGINATOR_ID, shortest CLUSTER_LIST (RFC4456),
+	// g) lowest peer address
+	b := cands[0]
+	for _, c := range cands[1:] {
+		if cmp := bytes.Compare(c.routerID, b.routerID); cmp != 0 {
+			if cmp < 0 {
+				b = c
+			}
+			continue
+		}
+		if c.clusterLen != b.clusterLen {
+			if c.clusterLen < b.clusterLen {
+				b = c
+			}
+			continue
+		}
+		if bytes.Compare(c.route.Peer.To16(), b.route.Peer.To16()) < 0 {
+			b = c
+		}
+	}
+	return b
+}
+
+// rank orders cands by preference, the best first.
+func rank(cands []*candidate) []*candidate {
+	ranked := make([]*candidate, 0, len(cands))
+	for len(cands) > 0 {
+		b := best(cands)
+		ranked = append(ranked, b)
+		cands = filter(cands, func(c *candidate) bool {
+			return c != b
+		})
+	}
+	return ranked
+}
+
+// ranked returns the routes of all peers for prefix in order of preference,
+// excluding those with an unreachable next hop. r.mu must be held.
+func (r *RIB) ranked(prefix *net.IPNet) []*candidate {
+	var cands []*candidate
+	for _, in := range r.peers {
+		v, ok := in.Get(prefix)
+		if !ok {
+			continue
+		}
+		c := r.newCandidate(v.(*lookingglass.Route))
+		if r.options.nextHops != nil {
+			var reachable bool
+			c.igpCost, reachable = r.options.nextHops.Resolve(c.route.NextHop)
+			if !reachable {
+				continue
+			}
+		}
+		cands = append(cands, c)
+	}
+	return rank(cands)
+}
+
+// Best returns the best route for prefix among those of all peers, selected
+// by the decision process of RFC4271 section 9.1.2.2. Routes are compared
+// by LOCAL_PREF, AS_PATH length, ORIGIN, MED among routes from the same
+// neighbor AS subject to the MED options of the RIB, preferring routes from
+// external peers, by the IGP cost to the next hop if a NextHopResolver was
+// set, then by router ID or ORIGINATOR_ID, CLUSTER_LIST length and peer
+// address. Routes whose next hop is unreachable are not selected. Peers are
+// described via SetPeer.
+func (r *RIB) Best(prefix *net.IPNet) (lookingglass.Route, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ranked := r.ranked(prefix)
+	if len(ranked) == 0 {
+		return lookingglass.Route{}, false
+	}
+	rt := *ranked[0].route
+	rt.Best = true
+	return rt, true
+}
+
+// Select returns the routes for prefix to advertise to peer according to
+// the Selection set for it via SetSelection, in order of preference. The
+// best route is marked as Best. Routes received from peer are never
+// selected, so that nothing is selected if peer's route is the best and the
+// mode is SelectBest.
+func (r *RIB) Select(prefix *net.IPNet, peer net.IP) []lookingglass.Route {
+	afi := corebgp.AFIIPv6
+	if prefix.IP.To4() != nil {
+		afi = corebgp.AFIIPv4
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := r.selections[selectionKey{peer.String(), afi, corebgp.SAFIUnicast}]
+	ranked := r.ranked(prefix)
+	var selected []*candidate
+	switch s.Mode {
+	case SelectBest:
+		selected = ranked[:min(1, len(ranked))]
+	case SelectBestExternal:
+		for _, c := range ranked {
+			if c.external {
+				selected = []*candidate{c}
+				break
+			}
+		}
+		if selected == nil {
+			selected = ranked[:min(1, len(ranked))]
+		}
+	case SelectAll:
+		selected = ranked
+	case SelectDiverse:
+		for _, c := range ranked {
+			if len(selected) >= s.Paths {
+				break
+			}
+			diverse := true
+			for _, sel := range selected {
+				if sel.route.NextHop.Equal(c.route.NextHop) {
+					diverse = false
+					break
+				}
+			}
+			if diverse {
+				selected = append(selected, c)
+			}
+		}
+	}
+	routes := make([]lookingglass.Route, 0, len(selected))
+	for _, c := range selected {
+		if c.route.Peer.Equal(peer) {
+			continue
+		}
+		rt := *c.route
+		rt.Best = c == ranked[0]
+		routes = append(routes, rt)
+	}
+	return routes
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,593 @@
+//go:build go1.18
+// +build go1.18
+
+package rib
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/jwhited/corebgp"
+)
+
+// testPrefix is the prefix of the routes of testRoute.
+var testPrefix = &net.IPNet{
+	IP:   net.IP{192, 0, 2, 0},
+	Mask: net.CIDRMask(24, 32),
+}
+
+// testRoute is a route for testPrefix received from peer.
+type testRoute struct {
+	peer string
+	// internal and routerID describe peer via RIB.SetPeer if either is set
+	internal bool
+	routerID string
+
+	localPref    uint32
+	path         []uint32
+	origin       uint8
+	med          *uint32
+	nextHop      string
+	originatorID string
+	clusterLen   int
+}
+
+func u32(v uint32) *uint32 {
+	return &v
+}
+
+// testAttr returns an encoded path attribute, failing t on error. MED,
+// ORIGINATOR_ID and CLUSTER_LIST are optional, the others well-known.
+func testAttr(t *testing.T, attrType uint8, value []byte) []byte {
+	t.Helper()
+	flags := corebgp.AttrFlagTransitive
+	switch attrType {
+	case corebgp.AttrTypeMED, corebgp.AttrTypeOriginatorID,
+		corebgp.AttrTypeClusterList:
+		flags = corebgp.AttrFlagOptional
+	}
+	b, err := corebgp.AppendPathAttr(nil, flags, attrType, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// update returns the body of an UPDATE message for rt with four-octet AS
+// numbers.
+func (rt testRoute) update(t *testing.T) []byte {
+	t.Helper()
+	attrs := testAttr(t, corebgp.AttrTypeOrigin, []byte{rt.origin})
+	var path corebgp.ASPath
+	if len(rt.path) > 0 {
+		path = corebgp.ASPath{{
+			Type: corebgp.ASPathSegmentSequence,
+			ASNs: rt.path,
+		}}
+	}
+	value, err := path.Encode(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs = append(attrs, testAttr(t, corebgp.AttrTypeASPath, value)...)
+	nextHop := rt.nextHop
+	if nextHop == "" {
+		nextHop = "198.51.100.1"
+	}
+	attrs = append(attrs, testAttr(t, corebgp.AttrTypeNextHop,
+		net.ParseIP(nextHop).To4())...)
+	u32Attr := func(attrType uint8, v uint32) {
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, v)
+		attrs = append(attrs, testAttr(t, attrType, value)...)
+	}
+	if rt.med != nil {
+		u32Attr(corebgp.AttrTypeMED, *rt.med)
+	}
+	if rt.localPref != 0 {
+		u32Attr(corebgp.AttrTypeLocalPref, rt.localPref)
+	}
+	if rt.originatorID != "" {
+		attrs = append(attrs, testAttr(t, corebgp.AttrTypeOriginatorID,
+			net.ParseIP(rt.originatorID).To4())...)
+	}
+	if rt.clusterLen > 0 {
+		attrs = append(attrs, testAttr(t, corebgp.AttrTypeClusterList,
+			make([]byte, rt.clusterLen*4))...)
+	}
+	b := make([]byte, 4, 4+len(attrs)+4)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(attrs)))
+	b = append(b, attrs...)
+	return append(b, 24, 192, 0, 2)
+}
+
+// newTestRIB returns a RIB holding routes.
+func newTestRIB(t *testing.T, routes []testRoute, opts ...Option) *RIB {
+	t.Helper()
+	r := New(opts...)
+	for _, rt := range routes {
+		peer := net.ParseIP(rt.peer)
+		if rt.internal || rt.routerID != "" {
+			routerID := peer
+			if rt.routerID != "" {
+				routerID = net.ParseIP(rt.routerID)
+			}
+			r.SetPeer(peer, Peer{
+				AS:       65000,
+				RouterID: routerID,
+				Internal: rt.internal,
+			})
+		}
+		if err := r.Update(peer, true, rt.update(t)); err != nil {
+			t.Fatalf("error applying route from %s: %v", rt.peer, err)
+		}
+	}
+	return r
+}
+
+// bestCase is a case of the decision process selecting the route from peer
+// want among routes.
+type bestCase struct {
+	name   string
+	routes []testRoute
+	want   string
+}
+
+// testBest runs cases, with routes received in both the given and reverse
+// order as the decision process does not depend on it.
+func testBest(t *testing.T, cases []bestCase, opts ...Option) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reversed := make([]testRoute, len(c.routes))
+			for i, rt := range c.routes {
+				reversed[len(c.routes)-1-i] = rt
+			}
+			for _, routes := range [][]testRoute{c.routes, reversed} {
+				r := newTestRIB(t, routes, opts...)
+				got, ok := r.Best(testPrefix)
+				if !ok {
+					t.Fatal("no best route")
+				}
+				if !got.Peer.Equal(net.ParseIP(c.want)) || !got.Best {
+					t.Fatalf("best route from %s, want %s", got.Peer, c.want)
+				}
+			}
+		})
+	}
+}
+
+// staticCosts returns a NextHopResolver of the IGP cost of each next hop in
+// costs. Other next hops are unreachable.
+func staticCosts(costs map[string]uint32) NextHopResolver {
+	return NextHopResolverFunc(func(nextHop net.IP) (uint32, bool) {
+		cost, ok := costs[nextHop.String()]
+		return cost, ok
+	})
+}
+
+// TestBestTieBreaks checks each step of the decision process of RFC4271
+// section 9.1.2.2 in order. In each case the winning route is preferred by
+// the step under test, and would lose all following steps.
+func TestBestTieBreaks(t *testing.T) {
+	testBest(t, []bestCase{
+		{
+			name: "local pref",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001}},
+				{peer: "10.0.0.2", internal: true, routerID: "10.255.0.2",
+					localPref: 200, path: []uint32{65002, 65003, 65004}},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "local pref of external routes is ignored",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001}},
+				{peer: "10.0.0.2", localPref: 200,
+					path: []uint32{65002, 65003}},
+			},
+			want: "10.0.0.1",
+		},
+		{
+			name: "as path length",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001, 65003}},
+				{peer: "10.0.0.2", path: []uint32{65002}, origin: 2},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "origin",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001}, origin: 1,
+					med: u32(0)},
+				{peer: "10.0.0.2", path: []uint32{65001}, origin: 0,
+					med: u32(100)},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "med",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001}, med: u32(20)},
+				{peer: "10.0.0.2", internal: true, path: []uint32{65001},
+					med: u32(10)},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "external over internal",
+			routes: []testRoute{
+				{peer: "10.0.0.1", internal: true, path: []uint32{65001},
+					nextHop: "198.51.100.1"},
+				{peer: "10.0.0.2", path: []uint32{65001},
+					nextHop: "198.51.100.2"},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "igp cost",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001},
+					nextHop: "198.51.100.2"},
+				{peer: "10.0.0.2", path: []uint32{65001},
+					nextHop: "198.51.100.1"},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "router id",
+			routes: []testRoute{
+				{peer: "10.0.0.1", routerID: "10.255.0.2",
+					path: []uint32{65001}, nextHop: "198.51.100.3"},
+				{peer: "10.0.0.2", routerID: "10.255.0.1",
+					path: []uint32{65001}, nextHop: "198.51.100.3",
+					clusterLen: 2},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "originator id",
+			routes: []testRoute{
+				{peer: "10.0.0.1", internal: true, routerID: "10.255.0.2",
+					path: []uint32{65001}, nextHop: "198.51.100.3"},
+				{peer: "10.0.0.2", internal: true, routerID: "10.255.0.3",
+					path: []uint32{65001}, nextHop: "198.51.100.3",
+					originatorID: "10.255.0.1", clusterLen: 1},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "cluster list length",
+			routes: []testRoute{
+				{peer: "10.0.0.1", internal: true, path: []uint32{65001},
+					nextHop: "198.51.100.3", originatorID: "10.255.0.1",
+					clusterLen: 2},
+				{peer: "10.0.0.2", internal: true, path: []uint32{65001},
+					nextHop: "198.51.100.3", originatorID: "10.255.0.1",
+					clusterLen: 1},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "peer address",
+			routes: []testRoute{
+				{peer: "10.0.0.2", routerID: "10.255.0.1",
+					path: []uint32{65001}, nextHop: "198.51.100.3"},
+				{peer: "10.0.0.1", routerID: "10.255.0.1",
+					path: []uint32{65001}, nextHop: "198.51.100.3"},
+			},
+			want: "10.0.0.1",
+		},
+	}, NextHops(staticCosts(map[string]uint32{
+		"198.51.100.1": 10,
+		"198.51.100.2": 20,
+		"198.51.100.3": 5,
+	})))
+}
+
+func TestSelect(t *testing.T) {
+	// ranked by preference: 10.0.0.1 by LOCAL_PREF, 10.0.0.2 as external,
+	// 10.0.0.3 by AS_PATH length, then 10.0.0.4
+	routes := []testRoute{
+		{peer: "10.0.0.4", path: []uint32{65001, 65002},
+			nextHop: "198.51.100.2"},
+		{peer: "10.0.0.3", internal: true, path: []uint32{65003},
+			nextHop: "198.51.100.3"},
+		{peer: "10.0.0.2", path: []uint32{65001}, nextHop: "198.51.100.2"},
+		{peer: "10.0.0.1", internal: true, localPref: 200,
+			path: []uint32{65003}, nextHop: "198.51.100.1"},
+	}
+	cases := []struct {
+		name      string
+		routes    []testRoute
+		peer      string
+		selection Selection
+		// want are the peers of the selected routes, the first of which is
+		// the best route if wantBest
+		want     []string
+		wantBest bool
+	}{
+		{
+			name:      "best",
+			routes:    routes,
+			peer:      "10.0.0.9",
+			selection: Selection{Mode: SelectBest},
+			want:      []string{"10.0.0.1"},
+			wantBest:  true,
+		},
+		{
+			name:      "best received from peer",
+			routes:    routes,
+			peer:      "10.0.0.1",
+			selection: Selection{Mode: SelectBest},
+		},
+		{
+			name:      "best external",
+			routes:    routes,
+			peer:      "10.0.0.9",
+			selection: Selection{Mode: SelectBestExternal},
+			want:      []string{"10.0.0.2"},
+		},
+		{
+			name:      "best external without external routes",
+			routes:    []testRoute{routes[1], routes[3]},
+			peer:      "10.0.0.9",
+			selection: Selection{Mode: SelectBestExternal},
+			want:      []string{"10.0.0.1"},
+			wantBest:  true,
+		},
+		{
+			name:      "all",
+			routes:    routes,
+			peer:      "10.0.0.9",
+			selection: Selection{Mode: SelectAll},
+			want:      []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+			wantBest:  true,
+		},
+		{
+			name:      "all except those received from peer",
+			routes:    routes,
+			peer:      "10.0.0.2",
+			selection: Selection{Mode: SelectAll},
+			want:      []string{"10.0.0.1", "10.0.0.3", "10.0.0.4"},
+			wantBest:  true,
+		},
+		{
+			name:      "diverse",
+			routes:    routes,
+			peer:      "10.0.0.9",
+			selection: Selection{Mode: SelectDiverse, Paths: 2},
+			want:      []string{"10.0.0.1", "10.0.0.2"},
+			wantBest:  true,
+		},
+		{
+			name:      "diverse skips repeated next hops",
+			routes:    routes,
+			peer:      "10.0.0.9",
+			selection: Selection{Mode: SelectDiverse, Paths: 4},
+			want:      []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			wantBest:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := newTestRIB(t, c.routes)
+			peer := net.ParseIP(c.peer)
+			r.SetSelection(peer, corebgp.AFIIPv4, corebgp.SAFIUnicast,
+				c.selection)
+			got := r.Select(testPrefix, peer)
+			if len(got) != len(c.want) {
+				t.Fatalf("selected %d routes, want %v", len(got), c.want)
+			}
+			for i, rt := range got {
+				if !rt.Peer.Equal(net.ParseIP(c.want[i])) {
+					t.Errorf("route %d from %s, want %s", i, rt.Peer,
+						c.want[i])
+				}
+				if wantBest := i == 0 && c.wantBest; rt.Best != wantBest {
+					t.Errorf("route %d from %s marked best %v, want %v", i,
+						rt.Peer, rt.Best, wantBest)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectionDefaultsToBest(t *testing.T) {
+	r := newTestRIB(t, []testRoute{
+		{peer: "10.0.0.1", path: []uint32{65001}},
+		{peer: "10.0.0.2", path: []uint32{65001, 65002}},
+	})
+	got := r.Select(testPrefix, net.ParseIP("10.0.0.9"))
+	if len(got) != 1 || !got[0].Peer.Equal(net.ParseIP("10.0.0.1")) ||
+		!got[0].Best {
+		t.Fatalf("selected %v, want the best route from 10.0.0.1", got)
+	}
+}
+
+// TestBestMED checks the MED options of the decision process, each of which
+// selects a different route than the default in some case.
+func TestBestMED(t *testing.T) {
+	var (
+		// MEDs are not comparable by default
+		differentAS = []testRoute{
+			{peer: "10.0.0.1", path: []uint32{65001}, med: u32(20)},
+			{peer: "10.0.0.2", path: []uint32{65002}, med: u32(10)},
+		}
+		missing = []testRoute{
+			{peer: "10.0.0.1", path: []uint32{65001}},
+			{peer: "10.0.0.2", path: []uint32{65001}, med: u32(10)},
+		}
+		missingDifferentAS = []testRoute{
+			{peer: "10.0.0.1", path: []uint32{65001}},
+			{peer: "10.0.0.2", path: []uint32{65002}, med: u32(10)},
+		}
+		// compared pairwise in the order received, 10.0.0.3 beats 10.0.0.1
+		// by MED, 10.0.0.2 beats 10.0.0.3 as external, and 10.0.0.1 beats
+		// 10.0.0.2 by peer address
+		pairwise = []testRoute{
+			{peer: "10.0.0.3", internal: true, path: []uint32{65001},
+				med: u32(10)},
+			{peer: "10.0.0.1", path: []uint32{65001}, med: u32(20)},
+			{peer: "10.0.0.2", path: []uint32{65002}, med: u32(30)},
+		}
+	)
+	for _, c := range []struct {
+		name  string
+		opts  []Option
+		cases []bestCase
+	}{
+		{
+			name: "default",
+			cases: []bestCase{
+				{"different neighbor as", differentAS, "10.0.0.1"},
+				{"missing med", missing, "10.0.0.1"},
+				{"missing med different neighbor as", missingDifferentAS,
+					"10.0.0.1"},
+				{"pairwise", pairwise, "10.0.0.2"},
+			},
+		},
+		{
+			name: "always compare med",
+			opts: []Option{AlwaysCompareMED()},
+			cases: []bestCase{
+				{"different neighbor as", differentAS, "10.0.0.2"},
+				{"missing med", missing, "10.0.0.1"},
+				{"missing med different neighbor as", missingDifferentAS,
+					"10.0.0.1"},
+				{"pairwise", pairwise, "10.0.0.3"},
+			},
+		},
+		{
+			name: "missing med as worst",
+			opts: []Option{MissingMEDAsWorst()},
+			cases: []bestCase{
+				{"different neighbor as", differentAS, "10.0.0.1"},
+				{"missing med", missing, "10.0.0.2"},
+				{"missing med different neighbor as", missingDifferentAS,
+					"10.0.0.1"},
+				{"pairwise", pairwise, "10.0.0.2"},
+			},
+		},
+		{
+			name: "always compare missing med as worst",
+			opts: []Option{AlwaysCompareMED(), MissingMEDAsWorst()},
+			cases: []bestCase{
+				{"different neighbor as", differentAS, "10.0.0.2"},
+				{"missing med", missing, "10.0.0.2"},
+				{"missing med different neighbor as", missingDifferentAS,
+					"10.0.0.2"},
+				{"pairwise", pairwise, "10.0.0.3"},
+			},
+		},
+		{
+			// the default process is independent of the order of routes
+			name: "deterministic med",
+			opts: []Option{DeterministicMED()},
+			cases: []bestCase{
+				{"different neighbor as", differentAS, "10.0.0.1"},
+				{"missing med", missing, "10.0.0.1"},
+				{"missing med different neighbor as", missingDifferentAS,
+					"10.0.0.1"},
+				{"pairwise", pairwise, "10.0.0.2"},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testBest(t, c.cases, c.opts...)
+		})
+	}
+}
+
+func TestBestNextHops(t *testing.T) {
+	costs := map[string]uint32{
+		"198.51.100.1": 20,
+		"198.51.100.2": 10,
+	}
+	testBest(t, []bestCase{
+		{
+			name: "lower igp cost",
+			routes: []testRoute{
+				{peer: "10.0.0.1", path: []uint32{65001},
+					nextHop: "198.51.100.1"},
+				{peer: "10.0.0.2", path: []uint32{65001},
+					nextHop: "198.51.100.2"},
+			},
+			want: "10.0.0.2",
+		},
+		{
+			name: "unreachable next hop",
+			routes: []testRoute{
+				{peer: "10.0.0.1", internal: true, localPref: 200,
+					path: []uint32{65001}, nextHop: "198.51.100.3"},
+				{peer: "10.0.0.2", path: []uint32{65001, 65002},
+					nextHop: "198.51.100.1"},
+			},
+			want: "10.0.0.2",
+		},
+	}, NextHops(staticCosts(costs)))
+
+	r := newTestRIB(t, []testRoute{
+		{peer: "10.0.0.1", path: []uint32{65001}, nextHop: "198.51.100.1"},
+		{peer: "10.0.0.2", path: []uint32{65001}, nextHop: "198.51.100.2"},
+	}, NextHops(staticCosts(costs)))
+	costs["198.51.100.1"] = 5
+	if rt, _ := r.Best(testPrefix); !rt.Peer.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("best route from %s after its cost decreased, want "+
+			"10.0.0.1", rt.Peer)
+	}
+	delete(costs, "198.51.100.1")
+	delete(costs, "198.51.100.2")
+	if rt, ok := r.Best(testPrefix); ok {
+		t.Fatalf("best route from %s with unreachable next hops", rt.Peer)
+	}
+	routes := r.Select(testPrefix, net.ParseIP("10.0.0.9"))
+	if len(routes) > 0 {
+		t.Fatalf("selected %d routes with unreachable next hops", len(routes))
+	}
+}
+
+func TestStaticNextHops(t *testing.T) {
+	s := NewStaticNextHops()
+	for prefix, cost := range map[string]uint32{
+		"198.51.100.0/24": 10,
+		"198.51.100.0/28": 5,
+		"2001:db8::/32":   30,
+	} {
+		_, p, err := net.ParseCIDR(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Set(p, cost); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		nextHop   string
+		cost      uint32
+		reachable bool
+	}{
+		{"198.51.100.1", 5, true},
+		{"198.51.100.100", 10, true},
+		{"203.0.113.1", 0, false},
+		{"2001:db8::1", 30, true},
+		{"2001:db9::1", 0, false},
+		{"", 0, false},
+	} {
+		cost, reachable := s.Resolve(net.ParseIP(c.nextHop))
+		if cost != c.cost || reachable != c.reachable {
+			t.Errorf("Resolve(%s) = %d, %v, want %d, %v", c.nextHop, cost,
+				reachable, c.cost, c.reachable)
+		}
+	}
+	_, p, _ := net.ParseCIDR("198.51.100.0/28")
+	if !s.Delete(p) {
+		t.Fatal("prefix not deleted")
+	}
+	if cost, _ := s.Resolve(net.ParseIP("198.51.100.1")); cost != 10 {
+		t.Fatalf("resolved cost %d via a deleted prefix, want 10", cost)
+	}
+}
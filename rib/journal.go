@@ -0,0 +1,202 @@
+//go:build go1.18
+// +build go1.18
+
+package rib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jwhited/corebgp/lookingglass"
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+var (
+	// ErrJournalTruncated is returned by RIB.Changes when changes following
+	// the requested sequence number are no longer retained in the journal.
+	// The consumer must resynchronize from a Snapshot.
+	ErrJournalTruncated = errors.New("changes no longer retained in journal")
+)
+
+// ChangeType is the type of a Change.
+type ChangeType uint8
+
+// ChangeType values
+const (
+	// ChangeAdd adds or replaces a route.
+	ChangeAdd ChangeType = iota
+	// ChangeWithdraw withdraws the route for a prefix received from a peer.
+	ChangeWithdraw
+	// ChangeClear removes all routes received from a peer.
+	ChangeClear
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdd:
+		return "add"
+	case ChangeWithdraw:
+		return "withdraw"
+	case ChangeClear:
+		return "clear"
+	}
+	return "unknown"
+}
+
+// Change is a change to a RIB.
+type Change struct {
+	// Seq is the sequence number of the change. Sequence numbers of a RIB
+	// start at 1 and increase by one with each change.
+	Seq  uint64
+	Type ChangeType
+	Peer net.IP
+	// Route is the route added by a ChangeAdd. It must not be modified.
+	Route *lookingglass.Route
+	// Prefix is the prefix withdrawn by a ChangeWithdraw.
+	Prefix *net.IPNet
+}
+
+// journal retains the latest changes of a RIB in a ring buffer. It is guarded
+// by RIB.mu.
+type journal struct {
+	seq     uint64
+	size    int
+	changes []Change
+	next    int
+	// changed is closed on the next change, it is nil if nobody is waiting
+	changed chan struct{}
+}
+
+func newJournal(size int) *journal {
+	return &journal{
+		size: size,
+	}
+}
+
+// record assigns the next sequence number to c and retains it.
+func (j *journal) record(c Change) {
+	j.seq++
+	c.Seq = j.seq
+	j.retain(c)
+	j.notify()
+}
+
+func (j *journal) retain(c Change) {
+	switch {
+	case j.size == 0:
+	case len(j.changes) < j.size:
+		j.changes = append(j.changes, c)
+	default:
+		j.changes[j.next] = c
+		j.next = (j.next + 1) % len(j.changes)
+	}
+}
+
+func (j *journal) notify() {
+	if j.changed != nil {
+		close(j.changed)
+		j.changed = nil
+	}
+}
+
+// reset discards the retained changes and continues numbering at seq.
+func (j *journal) reset(seq uint64) {
+	j.seq = seq
+	j.changes = j.changes[:0]
+	j.next = 0
+	j.notify()
+}
+
+// since returns the retained changes following sequence number seq.
+func (j *journal) since(seq uint64) ([]Change, error) {
+	if seq >= j.seq {
+		return nil, nil
+	}
+	n := j.seq - seq
+	if n > uint64(len(j.changes)) {
+		return nil, ErrJournalTruncated
+	}
+	changes := make([]Change, 0, n)
+	changes = append(changes, j.changes[j.next:]...)
+	changes = append(changes, j.changes[:j.next]...)
+	return changes[uint64(len(changes))-n:], nil
+}
+
+// Seq returns the sequence number of the latest change to the RIB, or zero if
+// it was never changed.
+func (r *RIB) Seq() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.journal.seq
+}
+
+// Changes returns the changes following sequence number seq, oldest first.
+// ErrJournalTruncated is returned if they are no longer retained.
+func (r *RIB) Changes(seq uint64) ([]Change, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.journal.since(seq)
+}
+
+// Wait blocks until the sequence number of the RIB exceeds seq or ctx is
+// done, in which case ctx.Err() is returned.
+func (r *RIB) Wait(ctx context.Context, seq uint64) error {
+	for {
+		r.mu.Lock()
+		if r.journal.seq > seq {
+			r.mu.Unlock()
+			return nil
+		}
+		if r.journal.changed == nil {
+			r.journal.changed = make(chan struct{})
+		}
+		changed := r.journal.changed
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Apply applies changes obtained from the journal of another RIB, e.g. to
+// replicate it to a standby instance. The first change must directly follow
+// the latest change of r, and applied changes retain their sequence numbers.
+func (r *RIB) Apply(changes []Change) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range changes {
+		if c.Seq != r.journal.seq+1 {
+			return fmt.Errorf("change sequence number %d does not follow %d",
+				c.Seq, r.journal.seq)
+		}
+		switch c.Type {
+		case ChangeAdd:
+			if c.Route == nil || c.Route.Prefix == nil {
+				return errors.New("add change without route")
+			}
+			in, ok := r.peers[c.Peer.String()]
+			if !ok {
+				in = prefixtrie.New()
+				r.peers[c.Peer.String()] = in
+			}
+			err := in.Insert(c.Route.Prefix, c.Route)
+			if err != nil {
+				return err
+			}
+		case ChangeWithdraw:
+			if in, ok := r.peers[c.Peer.String()]; ok {
+				in.Delete(c.Prefix)
+			}
+		case ChangeClear:
+			delete(r.peers, c.Peer.String())
+		default:
+			return fmt.Errorf("unknown change type: %d", c.Type)
+		}
+		r.journal.record(c)
+	}
+	return nil
+}
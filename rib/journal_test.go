@@ -0,0 +1,287 @@
+//go:build go1.18
+// +build go1.18
+
+package rib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/lookingglass"
+)
+
+// testUpdate returns the body of an UPDATE message withdrawing and
+// announcing the /24s of 10.0.x.0 for x in withdrawn and nlri, with a
+// next hop of 198.51.100.nextHop.
+func testUpdate(t *testing.T, withdrawn, nlri []byte, nextHop byte) []byte {
+	t.Helper()
+	prefixes := func(x []byte) []byte {
+		var b []byte
+		for _, x := range x {
+			b = append(b, 24, 10, 0, x)
+		}
+		return b
+	}
+	var attrs []byte
+	if len(nlri) > 0 {
+		path, err := corebgp.ASPath{{
+			Type: corebgp.ASPathSegmentSequence,
+			ASNs: []uint32{65001},
+		}}.Encode(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attrs = bytes.Join([][]byte{
+			testAttr(t, corebgp.AttrTypeOrigin, []byte{0}),
+			testAttr(t, corebgp.AttrTypeASPath, path),
+			testAttr(t, corebgp.AttrTypeNextHop,
+				[]byte{198, 51, 100, nextHop}),
+		}, nil)
+	}
+	w := prefixes(withdrawn)
+	b := make([]byte, 2, 4+len(w)+len(attrs)+len(nlri)*4)
+	binary.BigEndian.PutUint16(b, uint16(len(w)))
+	b = append(b, w...)
+	b = append(b, byte(len(attrs)>>8), byte(len(attrs)))
+	b = append(b, attrs...)
+	return append(b, prefixes(nlri)...)
+}
+
+// routeSet returns the routes keyed by peer and prefix, with their next hop
+// and attributes as values.
+func routeSet(routes []lookingglass.Route) map[string]string {
+	set := make(map[string]string, len(routes))
+	for _, rt := range routes {
+		set[rt.Peer.String()+" "+rt.Prefix.String()] = fmt.Sprintf(
+			"%s %x %v", rt.NextHop, rt.Attributes, rt.ASPath)
+	}
+	return set
+}
+
+// compareRIBs fails t if the contents or sequence numbers of got and want
+// differ.
+func compareRIBs(t *testing.T, got, want *RIB) {
+	t.Helper()
+	gotSnap, wantSnap := got.Snapshot(), want.Snapshot()
+	if gotSnap.Seq != wantSnap.Seq {
+		t.Fatalf("seq %d, want %d", gotSnap.Seq, wantSnap.Seq)
+	}
+	gotSet, wantSet := routeSet(gotSnap.Routes), routeSet(wantSnap.Routes)
+	if len(gotSet) != len(wantSet) {
+		t.Fatalf("%d routes, want %d", len(gotSet), len(wantSet))
+	}
+	for k, v := range wantSet {
+		if gotSet[k] != v {
+			t.Fatalf("route %s is %q, want %q", k, gotSet[k], v)
+		}
+	}
+}
+
+// randomChanges applies n random UPDATE messages and clears from a few peers
+// to r, with announcements, replacements and withdrawals interleaved.
+func randomChanges(t *testing.T, r *RIB, rnd *rand.Rand, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		peer := net.IP{10, 255, 0, byte(1 + rnd.Intn(3))}
+		if rnd.Intn(20) == 0 {
+			r.Clear(peer)
+			continue
+		}
+		var withdrawn, nlri []byte
+		for j := rnd.Intn(4); j > 0; j-- {
+			withdrawn = append(withdrawn, byte(rnd.Intn(8)))
+		}
+		for j := rnd.Intn(4); j > 0; j-- {
+			nlri = append(nlri, byte(rnd.Intn(8)))
+		}
+		err := r.Update(peer, true, testUpdate(t, withdrawn, nlri,
+			byte(rnd.Intn(4))))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	r := New()
+	randomChanges(t, r, rnd, 200)
+	snap := r.Snapshot()
+	randomChanges(t, r, rnd, 200)
+
+	// a replica restored from the snapshot, applying changes following it
+	replica := New()
+	if err := replica.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := r.Changes(snap.Seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Apply(changes); err != nil {
+		t.Fatal(err)
+	}
+	compareRIBs(t, replica, r)
+
+	// a replica applying all changes
+	replica = New()
+	changes, err = r.Changes(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Apply(changes); err != nil {
+		t.Fatal(err)
+	}
+	compareRIBs(t, replica, r)
+
+	// changes are applied in turns as they are made
+	randomChanges(t, r, rnd, 50)
+	changes, err = r.Changes(replica.Seq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Apply(changes); err != nil {
+		t.Fatal(err)
+	}
+	compareRIBs(t, replica, r)
+}
+
+func TestJournalChanges(t *testing.T) {
+	r := New()
+	peer := net.IP{10, 255, 0, 1}
+	for _, u := range [][]byte{
+		testUpdate(t, nil, []byte{1, 2}, 1),
+		// withdrawing 3 is not a change as it was not announced
+		testUpdate(t, []byte{1, 3}, []byte{2}, 2),
+	} {
+		if err := r.Update(peer, true, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Clear(peer)
+	// clearing a peer without routes is not a change
+	r.Clear(net.IP{10, 255, 0, 2})
+
+	changes, err := r.Changes(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		typ    ChangeType
+		prefix string
+	}{
+		{ChangeAdd, "10.0.1.0/24"},
+		{ChangeAdd, "10.0.2.0/24"},
+		{ChangeWithdraw, "10.0.1.0/24"},
+		{ChangeAdd, "10.0.2.0/24"},
+		{ChangeClear, ""},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("%d changes, want %d", len(changes), len(want))
+	}
+	for i, c := range changes {
+		var prefix string
+		switch {
+		case c.Route != nil:
+			prefix = c.Route.Prefix.String()
+		case c.Prefix != nil:
+			prefix = c.Prefix.String()
+		}
+		if c.Seq != uint64(i+1) || c.Type != want[i].typ ||
+			prefix != want[i].prefix || !c.Peer.Equal(peer) {
+			t.Errorf("change %d is %d %s %s %s, want %d %s %s", i, c.Seq,
+				c.Type, c.Peer, prefix, i+1, want[i].typ, want[i].prefix)
+		}
+	}
+	if r.Seq() != uint64(len(want)) {
+		t.Fatalf("seq %d, want %d", r.Seq(), len(want))
+	}
+	if changes, err := r.Changes(r.Seq()); err != nil || len(changes) > 0 {
+		t.Fatalf("%d changes following the latest, err: %v", len(changes),
+			err)
+	}
+}
+
+func TestJournalTruncated(t *testing.T) {
+	r := New(JournalSize(4))
+	rnd := rand.New(rand.NewSource(1))
+	randomChanges(t, r, rnd, 20)
+	if _, err := r.Changes(r.Seq() - 5); err != ErrJournalTruncated {
+		t.Fatalf("got %v for changes no longer retained, want %v", err,
+			ErrJournalTruncated)
+	}
+	changes, err := r.Changes(r.Seq() - 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range changes {
+		if want := r.Seq() - 3 + uint64(i); c.Seq != want {
+			t.Fatalf("change %d has seq %d, want %d", i, c.Seq, want)
+		}
+	}
+}
+
+func TestApplyOutOfSequence(t *testing.T) {
+	r := New()
+	randomChanges(t, r, rand.New(rand.NewSource(1)), 10)
+	changes, err := r.Changes(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica := New()
+	if err := replica.Apply(changes[1:]); err == nil {
+		t.Fatal("applied changes not following the latest change")
+	}
+	if replica.Seq() != 0 {
+		t.Fatalf("seq %d after failing to apply changes", replica.Seq())
+	}
+}
+
+func TestSnapshotEncode(t *testing.T) {
+	r := New()
+	randomChanges(t, r, rand.New(rand.NewSource(1)), 100)
+	var buf bytes.Buffer
+	if err := r.Snapshot().Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := DecodeSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := restored.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	compareRIBs(t, restored, r)
+	// numbering continues from the snapshot, with an empty journal
+	if _, err := restored.Changes(0); err != ErrJournalTruncated {
+		t.Fatalf("got %v for changes preceding the snapshot, want %v", err,
+			ErrJournalTruncated)
+	}
+}
+
+func TestWait(t *testing.T) {
+	r := New()
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Millisecond*10)
+	defer cancel()
+	if err := r.Wait(ctx, 0); err != context.DeadlineExceeded {
+		t.Fatalf("got %v waiting without changes, want %v", err,
+			context.DeadlineExceeded)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Wait(context.Background(), 0)
+	}()
+	r.Update(net.IP{10, 255, 0, 1}, true, testUpdate(t, nil, []byte{1}, 1))
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+//go:build go1.18
+// +build go1.18
+
+package rib
+
+import (
+	"net"
+	"sync"
+
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+// NextHopResolver resolves the next hops of routes for the decision process,
+// e.g. backed by the kernel routing table via netlink, an IGP
+// implementation, or static configuration. It is consulted each time routes
+// are selected, so a change in IGP costs or reachability takes effect with
+// the next call to RIB.Best or RIB.Select. Implementations must be safe for
+// concurrent use.
+type NextHopResolver interface {
+	// Resolve returns the IGP cost to reach nextHop. reachable is false if
+	// nextHop can not be resolved, in which case routes via it are not
+	// selected. nextHop is nil for routes without a usable next hop.
+	// https://tools.ietf.org/html/rfc4271#section-9.1.2.1
+	Resolve(nextHop net.IP) (cost uint32, reachable bool)
+}
+
+// NextHopResolverFunc is an adapter allowing a function to be used as a
+// NextHopResolver.
+type NextHopResolverFunc func(nextHop net.IP) (cost uint32, reachable bool)
+
+// Resolve calls fn(nextHop).
+func (fn NextHopResolverFunc) Resolve(nextHop net.IP) (uint32, bool) {
+	return fn(nextHop)
+}
+
+// NextHops sets the NextHopResolver used by the decision process to compare
+// the IGP cost of routes and exclude those with unreachable next hops. By
+// default all next hops are considered reachable at equal cost.
+func NextHops(r NextHopResolver) Option {
+	return newFuncOption(func(o *options) {
+		o.nextHops = r
+	})
+}
+
+// StaticNextHops is a NextHopResolver of statically configured prefixes.
+// A next hop is resolved via the longest prefix covering it.
+type StaticNextHops struct {
+	mu   sync.RWMutex
+	trie *prefixtrie.Trie
+}
+
+// NewStaticNextHops returns a StaticNextHops without prefixes, resolving no
+// next hops.
+func NewStaticNextHops() *StaticNextHops {
+	return &StaticNextHops{
+		trie: prefixtrie.New(),
+	}
+}
+
+// Set sets the IGP cost of next hops covered by prefix.
+func (s *StaticNextHops) Set(prefix *net.IPNet, cost uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trie.Insert(prefix, cost)
+}
+
+// Delete deletes prefix. It returns false if prefix was not set.
+func (s *StaticNextHops) Delete(prefix *net.IPNet) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.trie.Delete(prefix)
+}
+
+// Resolve returns the cost of the longest prefix covering nextHop.
+func (s *StaticNextHops) Resolve(nextHop net.IP) (uint32, bool) {
+	if nextHop == nil {
+		return 0, false
+	}
+	bits := net.IPv6len * 8
+	if nextHop.To4() != nil {
+		bits = net.IPv4len * 8
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, v, ok := s.trie.LongestMatch(&net.IPNet{
+		IP:   nextHop,
+		Mask: net.CIDRMask(bits, bits),
+	})
+	if !ok {
+		return 0, false
+	}
+	return v.(uint32), true
+}
@@ -0,0 +1,355 @@
+//go:build go1.18
+// +build go1.18
+
+// Package rib provides an Adj-RIB-In holding the IPv4 and IPv6 unicast routes
+// received from each peer, implementing lookingglass.RIB. Applications feed
+// it UPDATE messages from their corebgp.UpdateMessageHandler and clear a
+// peer's routes once its session closes.
+//
+// Changes to the RIB are sequence-numbered and retained in a journal, and
+// the RIB may be snapshotted and restored, allowing applications to
+// implement warm restart, replication to standby instances, or change feeds
+// to external systems:
+//
+//	snap := r.Snapshot()
+//	// ... transfer snap, then stream changes following it
+//	changes, err := r.Changes(snap.Seq)
+//
+// The routes of all peers for a prefix form the Loc-RIB, from which Best
+// selects the best route. Select selects the routes to advertise to a peer
+// according to its Selection, e.g. the best external route, or multiple
+// routes for peers that negotiated ADD-PATH.
+package rib
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/lookingglass"
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+var (
+	errMalformedUpdate = errors.New("malformed update message")
+)
+
+// DefaultJournalSize is the default number of changes retained in the
+// journal of a RIB.
+const DefaultJournalSize = 65536
+
+type options struct {
+	journalSize      int
+	alwaysCompareMED bool
+	missingMEDWorst  bool
+	nextHops         NextHopResolver
+}
+
+func (o *options) setDefaults() {
+	o.journalSize = DefaultJournalSize
+}
+
+// Option is an option for a RIB.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// JournalSize sets the number of changes retained in the journal, which
+// defaults to DefaultJournalSize. Zero disables the journal, in which case
+// changes are still sequence-numbered.
+func JournalSize(n int) Option {
+	return newFuncOption(func(o *options) {
+		if n >= 0 {
+			o.journalSize = n
+		}
+	})
+}
+
+// RIB holds the Adj-RIB-In of each established peer. IPv4 and IPv6 unicast
+// routes are supported. It is safe for concurrent use.
+type RIB struct {
+	mu sync.RWMutex
+	// peers holds the routes received from each peer, keyed by prefix with
+	// *lookingglass.Route values
+	peers   map[string]*prefixtrie.Trie
+	journal *journal
+	// peerInfo and selections are keyed by peer address
+	peerInfo   map[string]Peer
+	selections map[selectionKey]Selection
+	options    options
+}
+
+// New returns an empty RIB.
+func New(opts ...Option) *RIB {
+	var o options
+	o.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &RIB{
+		peers:      make(map[string]*prefixtrie.Trie),
+		journal:    newJournal(o.journalSize),
+		peerInfo:   make(map[string]Peer),
+		selections: make(map[selectionKey]Selection),
+		options:    o,
+	}
+}
+
+// Clear removes the routes received from peer, e.g. once its session
+// closed.
+func (r *RIB) Clear(peer net.IP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.peers[peer.String()]; !ok {
+		return
+	}
+	delete(r.peers, peer.String())
+	r.journal.record(Change{
+		Type: ChangeClear,
+		Peer: peer,
+	})
+}
+
+// ReceivedRoutes returns the routes received from peer.
+func (r *RIB) ReceivedRoutes(peer net.IP) ([]lookingglass.Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	in, ok := r.peers[peer.String()]
+	if !ok {
+		return []lookingglass.Route{}, nil
+	}
+	routes := make([]lookingglass.Route, 0, in.Len())
+	in.Walk(func(_ *net.IPNet, v interface{}) bool {
+		routes = append(routes, *v.(*lookingglass.Route))
+		return true
+	})
+	return routes, nil
+}
+
+// LookupRoutes returns the routes of all peers matching prefix.
+func (r *RIB) LookupRoutes(prefix *net.IPNet,
+	match lookingglass.Match) ([]lookingglass.Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	routes := make([]lookingglass.Route, 0)
+	add := func(_ *net.IPNet, v interface{}) bool {
+		routes = append(routes, *v.(*lookingglass.Route))
+		return true
+	}
+	for _, in := range r.peers {
+		switch match {
+		case lookingglass.MatchExact:
+			if v, ok := in.Get(prefix); ok {
+				add(prefix, v)
+			}
+		case lookingglass.MatchLonger:
+			in.Covered(prefix, add)
+		case lookingglass.MatchLongest:
+			if p, v, ok := in.LongestMatch(prefix); ok {
+				add(p, v)
+			}
+		}
+	}
+	// select the longest match across peers
+	return lookingglass.Select(routes, prefix, match), nil
+}
+
+// Counts returns the number of routes received from each peer, keyed by the
+// peer's address.
+func (r *RIB) Counts() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := make(map[string]int, len(r.peers))
+	for peer, in := range r.peers {
+		counts[peer] = in.Len()
+	}
+	return counts
+}
+
+// decodePrefixes decodes the IPv4 or IPv6 unicast prefixes of an NLRI field
+// via corebgp.NLRICodec. An update carrying a prefix with bits set beyond its
+// length is rejected instead of being stored under the masked prefix.
+func decodePrefixes(b []byte, afi uint16) ([]*net.IPNet, error) {
+	nlri, err := corebgp.NLRICodec{AFI: afi}.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]*net.IPNet, 0, len(nlri))
+	for _, n := range nlri {
+		addr := n.Prefix.Addr()
+		prefixes = append(prefixes, &net.IPNet{
+			IP:   addr.AsSlice(),
+			Mask: net.CIDRMask(n.Prefix.Bits(), addr.BitLen()),
+		})
+	}
+	return prefixes, nil
+}
+
+// ipv6Unicast returns the NLRI of an MP_REACH_NLRI or MP_UNREACH_NLRI value
+// if its AFI/SAFI is IPv6 unicast. The next hop is returned for MP_REACH_NLRI.
+// https://tools.ietf.org/html/rfc4760#section-3
+func ipv6Unicast(value []byte, reach bool) ([]byte, net.IP, error) {
+	if len(value) < 3 {
+		return nil, nil, errMalformedUpdate
+	}
+	if binary.BigEndian.Uint16(value) != corebgp.AFIIPv6 ||
+		value[2] != corebgp.SAFIUnicast {
+		return nil, nil, nil
+	}
+	if !reach {
+		return value[3:], nil, nil
+	}
+	if len(value) < 5 || len(value) < 5+int(value[3]) {
+		return nil, nil, errMalformedUpdate
+	}
+	var nh net.IP
+	if value[3] >= net.IPv6len {
+		nh = net.IP(value[4 : 4+net.IPv6len])
+	}
+	return value[5+int(value[3]):], nh, nil
+}
+
+// Update applies the UPDATE message body b received from peer. fourOctetAS
+// should be true if four-octet AS numbers were negotiated for the session.
+func (r *RIB) Update(peer net.IP, fourOctetAS bool, b []byte) error {
+	if len(b) < 4 {
+		return errMalformedUpdate
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 4+withdrawnLen {
+		return errMalformedUpdate
+	}
+	withdrawn, err := decodePrefixes(b[2:2+withdrawnLen], corebgp.AFIIPv4)
+	if err != nil {
+		return err
+	}
+	b = b[2+withdrawnLen:]
+	attrsLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+attrsLen {
+		return errMalformedUpdate
+	}
+	attrs := b[2 : 2+attrsLen]
+	nlri, err := decodePrefixes(b[2+attrsLen:], corebgp.AFIIPv4)
+	if err != nil {
+		return err
+	}
+
+	var (
+		nextHop, nextHop6 net.IP
+		path              corebgp.ASPath
+		nlri6             []*net.IPNet
+		kept              = make([]byte, 0, len(attrs))
+	)
+	for len(attrs) > 0 {
+		if len(attrs) < 3 {
+			return errMalformedUpdate
+		}
+		flags, attrType := corebgp.AttrFlags(attrs[0]), attrs[1]
+		headerLen, valueLen := 3, int(attrs[2])
+		if flags.ExtendedLength() {
+			if len(attrs) < 4 {
+				return errMalformedUpdate
+			}
+			headerLen, valueLen = 4, int(binary.BigEndian.Uint16(attrs[2:]))
+		}
+		if len(attrs) < headerLen+valueLen {
+			return errMalformedUpdate
+		}
+		raw := attrs[:headerLen+valueLen]
+		value := raw[headerLen:]
+		attrs = attrs[headerLen+valueLen:]
+		switch attrType {
+		case corebgp.AttrTypeNextHop:
+			if len(value) == net.IPv4len {
+				nextHop = net.IP(value)
+			}
+		case corebgp.AttrTypeASPath:
+			path, err = corebgp.DecodeASPath(value, fourOctetAS)
+			if err != nil {
+				return err
+			}
+		case corebgp.AttrTypeBGPsecPath:
+			// BGPsec update messages carry no AS_PATH
+			var p corebgp.BGPsecPath
+			if err = p.Decode(value); err != nil {
+				return err
+			}
+			if path == nil {
+				path = p.ASPath()
+			}
+		case corebgp.AttrTypeMPReachNLRI, corebgp.AttrTypeMPUnreachNLRI:
+			reach := attrType == corebgp.AttrTypeMPReachNLRI
+			prefixes, nh, err := ipv6Unicast(value, reach)
+			if err != nil {
+				return err
+			}
+			decoded, err := decodePrefixes(prefixes, corebgp.AFIIPv6)
+			if err != nil {
+				return err
+			}
+			if reach {
+				nlri6, nextHop6 = decoded, nh
+			} else {
+				withdrawn = append(withdrawn, decoded...)
+			}
+			continue
+		}
+		kept = append(kept, raw...)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in, ok := r.peers[peer.String()]
+	if !ok {
+		in = prefixtrie.New()
+		r.peers[peer.String()] = in
+	}
+	for _, p := range withdrawn {
+		if in.Delete(p) {
+			r.journal.record(Change{
+				Type:   ChangeWithdraw,
+				Peer:   peer,
+				Prefix: p,
+			})
+		}
+	}
+	now := time.Now()
+	add := func(prefixes []*net.IPNet, nh net.IP) {
+		for _, p := range prefixes {
+			rt := &lookingglass.Route{
+				Peer:       peer,
+				Prefix:     p,
+				NextHop:    nh,
+				ASPath:     path,
+				Attributes: kept,
+				Received:   now,
+			}
+			in.Insert(p, rt)
+			r.journal.record(Change{
+				Type:  ChangeAdd,
+				Peer:  peer,
+				Route: rt,
+			})
+		}
+	}
+	add(nlri, nextHop)
+	add(nlri6, nextHop6)
+	return nil
+}
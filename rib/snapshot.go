@@ -0,0 +1,76 @@
+//go:build go1.18
+// +build go1.18
+
+package rib
+
+import (
+	"encoding/gob"
+	"io"
+	"net"
+
+	"github.com/jwhited/corebgp/lookingglass"
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+// Snapshot is the content of a RIB as of a sequence number.
+type Snapshot struct {
+	// Seq is the sequence number of the latest change included in the
+	// Snapshot.
+	Seq    uint64
+	Routes []lookingglass.Route
+}
+
+// Snapshot returns a consistent Snapshot of the RIB.
+func (r *RIB) Snapshot() *Snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := &Snapshot{
+		Seq: r.journal.seq,
+	}
+	for _, in := range r.peers {
+		in.Walk(func(_ *net.IPNet, v interface{}) bool {
+			s.Routes = append(s.Routes, *v.(*lookingglass.Route))
+			return true
+		})
+	}
+	return s
+}
+
+// Restore replaces the content of the RIB with s, e.g. on warm restart or
+// when a replica resynchronizes. The journal is discarded and sequence
+// numbering continues at s.Seq.
+func (r *RIB) Restore(s *Snapshot) error {
+	peers := make(map[string]*prefixtrie.Trie)
+	for i := range s.Routes {
+		rt := s.Routes[i]
+		in, ok := peers[rt.Peer.String()]
+		if !ok {
+			in = prefixtrie.New()
+			peers[rt.Peer.String()] = in
+		}
+		err := in.Insert(rt.Prefix, &rt)
+		if err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers = peers
+	r.journal.reset(s.Seq)
+	return nil
+}
+
+// Encode writes s to w in a binary format read by DecodeSnapshot.
+func (s *Snapshot) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// DecodeSnapshot reads a Snapshot written by Snapshot.Encode from r.
+func DecodeSnapshot(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	err := gob.NewDecoder(r).Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
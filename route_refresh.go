@@ -0,0 +1,138 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// RouteRefresh is a ROUTE-REFRESH message as defined by RFC2918, optionally
+// carrying Outbound Route Filtering entries as defined by RFC5291.
+type RouteRefresh struct {
+	AFI uint16
+	// Subtype is the message subtype (RFC7313). It is zero for a normal
+	// route refresh request.
+	Subtype uint8
+	SAFI    uint8
+	// When is the When-to-refresh field. It is only present if ORFs is
+	// non-empty.
+	When ORFWhen
+	ORFs []*ORF
+}
+
+// ORF is a set of Outbound Route Filtering entries of a single ORF type
+// carried in a ROUTE-REFRESH message. Entries are encoded according to the
+// ORF type, see EncodeAddressPrefixORF() and DecodeAddressPrefixORF().
+type ORF struct {
+	Type    uint8
+	Entries []byte
+}
+
+// ORFWhen is the When-to-refresh field of a ROUTE-REFRESH message.
+type ORFWhen uint8
+
+// ORFWhen values
+const (
+	ORFWhenImmediate ORFWhen = 1
+	ORFWhenDefer     ORFWhen = 2
+)
+
+func (r *RouteRefresh) messageType() uint8 {
+	return RouteRefreshMessageType
+}
+
+func newRouteRefreshLengthErr(b []byte) error {
+	// https://tools.ietf.org/html/rfc7313#section-5
+	// The Data field of the NOTIFICATION message MUST contain the complete
+	// ROUTE-REFRESH message.
+	n := newNotification(NotifCodeRouteRefreshMessageErr,
+		NotifSubcodeInvalidMessageLength,
+		prependHeader(b, RouteRefreshMessageType))
+	return newNotificationError(n, true)
+}
+
+// Decode decodes a ROUTE-REFRESH message body, i.e. the message excluding the
+// header. Errors wrap the *Notification that should be sent to the remote
+// peer.
+func (r *RouteRefresh) Decode(b []byte) error {
+	if len(b) < 4 {
+		return newRouteRefreshLengthErr(b)
+	}
+	r.AFI = binary.BigEndian.Uint16(b)
+	r.Subtype = b[2]
+	r.SAFI = b[3]
+	r.When = 0
+	r.ORFs = nil
+	if len(b) == 4 {
+		return nil
+	}
+	if r.Subtype != 0 {
+		return newRouteRefreshLengthErr(b)
+	}
+	r.When = ORFWhen(b[4])
+	orfs := b[5:]
+	for len(orfs) > 0 {
+		if len(orfs) < 3 {
+			return newRouteRefreshLengthErr(b)
+		}
+		orfLen := int(binary.BigEndian.Uint16(orfs[1:3]))
+		if len(orfs) < 3+orfLen {
+			return newRouteRefreshLengthErr(b)
+		}
+		entries := make([]byte, orfLen)
+		copy(entries, orfs[3:3+orfLen])
+		r.ORFs = append(r.ORFs, &ORF{
+			Type:    orfs[0],
+			Entries: entries,
+		})
+		orfs = orfs[3+orfLen:]
+	}
+	return nil
+}
+
+// Encode encodes the ROUTE-REFRESH message, including the header.
+func (r *RouteRefresh) Encode() ([]byte, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, r.AFI)
+	b[2] = r.Subtype
+	b[3] = r.SAFI
+	if len(r.ORFs) > 0 {
+		if r.Subtype != 0 {
+			return nil, errors.New("orf entries in route refresh with " +
+				"non-zero subtype")
+		}
+		b = append(b, uint8(r.When))
+		for _, orf := range r.ORFs {
+			if len(orf.Entries) > math.MaxUint16 {
+				return nil, errors.New("orf entries too long")
+			}
+			b = append(b, orf.Type, uint8(len(orf.Entries)>>8),
+				uint8(len(orf.Entries)))
+			b = append(b, orf.Entries...)
+		}
+	}
+	return prependHeader(b, RouteRefreshMessageType), nil
+}
+
+// RouteRefreshPlugin may optionally be implemented by a Plugin in order to
+// receive ROUTE-REFRESH messages. ROUTE-REFRESH messages are otherwise
+// ignored, as are those received on a session for which the Route Refresh
+// capability was not negotiated.
+type RouteRefreshPlugin interface {
+	// OnRouteRefresh is fired when a ROUTE-REFRESH message is received from a
+	// peer in the Established state. ORFs of types not negotiated for the
+	// AFI/SAFI are removed beforehand. Any ORF entries should be installed
+	// before re-advertising routes to the peer, unless When is
+	// ORFWhenDefer. Returning a non-nil Notification will cause it to be
+	// sent to the peer and the FSM will transition to the Idle state.
+	OnRouteRefresh(peer *PeerConfig, r *RouteRefresh) *Notification
+}
+
+// RouteRefreshWriter is implemented by the UpdateMessageWriter passed to
+// Plugin.OnEstablished().
+type RouteRefreshWriter interface {
+	// WriteRouteRefresh sends a ROUTE-REFRESH message to the remote peer. An
+	// error is returned if the write fails and/or the FSM is no longer in an
+	// established state.
+	WriteRouteRefresh(r *RouteRefresh) error
+}
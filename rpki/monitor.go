@@ -0,0 +1,116 @@
+package rpki
+
+import (
+	"net"
+
+	"github.com/jwhited/corebgp/lookingglass"
+)
+
+// Event is a change of the validation state of a route, see Monitor.
+type Event struct {
+	Route lookingglass.Route
+	// Previous is the state of the route before the VRPs changed.
+	Previous State
+	State    State
+}
+
+// Monitor revalidates the routes of a RIB as the VRPs of a Table change,
+// e.g. the rib package's RIB holding the routes received from peers prior to
+// policy. A Policy only validates routes as they are received, so routes
+// accepted earlier remain in place once they become Invalid. Such events
+// allow the application to act upon them, for example by withdrawing the
+// routes it advertised for them, or resetting the peer via Server.ResetPeer
+// in order to have policy applied anew.
+type Monitor struct {
+	table   *Table
+	rib     lookingglass.RIB
+	localAS uint32
+	handler func(Event)
+}
+
+// NewMonitor returns a Monitor calling handler for each route of rib whose
+// validation state changes with the VRPs of table. localAS is the origin of
+// routes with an empty AS_PATH. handler is called in the goroutine changing
+// table, once for each route of the affected prefixes whose state changed.
+func NewMonitor(table *Table, rib lookingglass.RIB, localAS uint32,
+	handler func(Event)) *Monitor {
+	m := &Monitor{
+		table:   table,
+		rib:     rib,
+		localAS: localAS,
+		handler: handler,
+	}
+	table.OnChange(m.revalidate)
+	return m
+}
+
+// revalidate revalidates the routes covered by the added and removed VRPs.
+func (m *Monitor) revalidate(added, removed []VRP) {
+	seen := make(map[string]bool)
+	for _, changed := range [][]VRP{added, removed} {
+		for _, v := range changed {
+			routes, err := m.rib.LookupRoutes(v.Prefix,
+				lookingglass.MatchLonger)
+			if err != nil {
+				continue
+			}
+			for _, r := range routes {
+				key := r.Peer.String() + " " + r.Prefix.String() + " " +
+					r.ASPath.String()
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				m.revalidateRoute(r, added, removed)
+			}
+		}
+	}
+}
+
+func (m *Monitor) revalidateRoute(r lookingglass.Route, added,
+	removed []VRP) {
+	origin := m.localAS
+	if len(r.ASPath) > 0 {
+		origin, _ = r.ASPath.OriginASN()
+	}
+	vrps := m.table.Covering(r.Prefix)
+	state := validate(r.Prefix, origin, vrps)
+
+	// the VRPs covering the route before the change
+	previous := make([]VRP, 0, len(vrps)+len(removed))
+	for _, v := range vrps {
+		if !containsVRP(added, v) {
+			previous = append(previous, v)
+		}
+	}
+	for _, v := range removed {
+		if covers(v.Prefix, r.Prefix) {
+			previous = append(previous, v)
+		}
+	}
+	e := Event{
+		Route:    r,
+		Previous: validate(r.Prefix, origin, previous),
+		State:    state,
+	}
+	if e.Previous != e.State {
+		m.handler(e)
+	}
+}
+
+func containsVRP(vrps []VRP, v VRP) bool {
+	for _, w := range vrps {
+		if w.ASN == v.ASN && w.MaxLength == v.MaxLength &&
+			w.Prefix.String() == v.Prefix.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns true if a covers b, including if they are equal.
+func covers(a, b *net.IPNet) bool {
+	aBits, aSize := a.Mask.Size()
+	bBits, bSize := b.Mask.Size()
+	return aSize == bSize && aBits <= bBits && a.Contains(b.IP)
+}
@@ -0,0 +1,176 @@
+package rpki
+
+import (
+	"net"
+
+	"github.com/jwhited/corebgp"
+)
+
+// Action is the action of a Term.
+type Action uint8
+
+// Action values
+const (
+	Accept Action = iota
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Accept:
+		return "accept"
+	case Reject:
+		return "reject"
+	}
+	return "unknown"
+}
+
+// Term matches routes by validation state.
+type Term struct {
+	// States contains the validation states matched by the term.
+	States []State
+	Action Action
+}
+
+func (t Term) matches(s State) bool {
+	for _, state := range t.States {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RejectInvalid is a Term rejecting Invalid routes, as recommended by
+// RFC8893 section 5 for routes not permitted by any VRP.
+var RejectInvalid = Term{States: []State{Invalid}, Action: Reject}
+
+// Policy applies origin validation to the routes received from peers.
+type Policy struct {
+	table *Table
+	terms []Term
+}
+
+// NewPolicy returns a Policy validating routes against table and applying
+// the Action of the first of terms matching their validation state. Routes
+// matched by no term are accepted.
+func NewPolicy(table *Table, terms ...Term) *Policy {
+	return &Policy{
+		table: table,
+		terms: terms,
+	}
+}
+
+// Action returns the action applied to prefix originated by origin.
+func (p *Policy) Action(prefix *net.IPNet, origin uint32) Action {
+	state := p.table.ValidateOrigin(prefix, origin)
+	for _, t := range p.terms {
+		if t.matches(state) {
+			return t.Action
+		}
+	}
+	return Accept
+}
+
+// Origin returns the origin AS of the routes of u received from peer, or
+// zero (NONE) if it has no AS_PATH or it ends with an AS_SET. If the session
+// did not negotiate four-octet AS numbers the origin is taken from any valid
+// AS4_PATH attribute. The origin of an empty AS_PATH is peer.LocalAS, as the
+// route is originated within the local AS.
+// https://tools.ietf.org/html/rfc6811#section-2
+func Origin(peer *corebgp.PeerConfig, u *corebgp.DecodedUpdate) uint32 {
+	a, ok := u.Attr(corebgp.AttrTypeASPath)
+	if !ok {
+		return 0
+	}
+	path, err := u.Codec.DecodeASPath(a.Value)
+	if err != nil {
+		return 0
+	}
+	if len(path) == 0 {
+		return peer.LocalAS
+	}
+	if !u.Codec.FourOctetAS {
+		if a, ok := u.Attr(corebgp.AttrTypeAS4Path); ok {
+			as4Path, err := corebgp.DecodeASPath(a.Value, true)
+			if err == nil && len(as4Path) > 0 {
+				path = as4Path
+			}
+		}
+	}
+	origin, _ := path.OriginASN()
+	return origin
+}
+
+// InboundPolicy returns a corebgp.InboundPolicyFunc applying p, for use with
+// the corebgp.InboundPolicy peer option. Rejected prefixes of the NLRI field
+// and the MP_REACH_NLRI attribute are moved to the withdrawn routes field and
+// the MP_UNREACH_NLRI attribute respectively, withdrawing routes of the same
+// prefix previously accepted. Should the update carry an MP_UNREACH_NLRI
+// attribute of a different AFI/SAFI, rejected prefixes of the MP_REACH_NLRI
+// attribute are dropped instead. Prefixes of SAFIs other than unicast and
+// multicast are accepted.
+func (p *Policy) InboundPolicy() corebgp.InboundPolicyFunc {
+	return func(peer *corebgp.PeerConfig,
+		u *corebgp.DecodedUpdate) *corebgp.DecodedUpdate {
+		if len(u.NLRI) == 0 && (u.MPReach == nil || len(u.MPReach.NLRI) == 0) {
+			return u
+		}
+		origin := Origin(peer, u)
+		var rejected []corebgp.NLRI
+		u.NLRI, rejected = p.filter(u.NLRI, origin)
+		u.Withdrawn = append(u.Withdrawn, rejected...)
+		if u.MPReach != nil && len(u.MPReach.NLRI) > 0 {
+			u.MPReach.NLRI, rejected = p.filter(u.MPReach.NLRI, origin)
+			if len(rejected) > 0 {
+				p.withdraw(u, rejected)
+			}
+			if len(u.MPReach.NLRI) == 0 {
+				u.MPReach = nil
+			}
+		}
+		if len(u.NLRI) == 0 && u.MPReach == nil {
+			u.Attrs = nil
+			if len(u.Withdrawn) == 0 && len(u.MPUnreach) == 0 {
+				// nothing left that would not be mistaken for an End-of-RIB
+				// marker
+				return nil
+			}
+		}
+		return u
+	}
+}
+
+// filter splits nlri into accepted and rejected prefixes.
+func (p *Policy) filter(nlri []corebgp.NLRI,
+	origin uint32) (accepted, rejected []corebgp.NLRI) {
+	accepted = nlri[:0:0]
+	for _, n := range nlri {
+		if p.Action(n.Prefix, origin) == Reject {
+			rejected = append(rejected, n)
+			continue
+		}
+		accepted = append(accepted, n)
+	}
+	return accepted, rejected
+}
+
+// withdraw adds the rejected prefixes of u.MPReach to its MP_UNREACH_NLRI
+// attribute.
+func (p *Policy) withdraw(u *corebgp.DecodedUpdate,
+	rejected []corebgp.NLRI) {
+	reach := u.MPReach
+	switch len(u.MPUnreach) {
+	case 0:
+		u.MPUnreach = []*corebgp.MPNLRI{{
+			AFI:  reach.AFI,
+			SAFI: reach.SAFI,
+			NLRI: rejected,
+		}}
+	case 1:
+		m := u.MPUnreach[0]
+		if m.AFI == reach.AFI && m.SAFI == reach.SAFI {
+			m.NLRI = append(m.NLRI[:len(m.NLRI):len(m.NLRI)], rejected...)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Package rpki implements BGP prefix origin validation (RFC6811) against a
+// table of Validated ROA Payloads (VRPs), and policy acting on the resulting
+// validation state.
+//
+// corebgp does not include an RTR (RFC8210) client. Applications feed the
+// VRPs obtained from an RTR cache or a validator's export into a Table:
+//
+//	t := rpki.NewTable()
+//	err := t.Replace(vrps) // on cache reset, Add and Remove on serial notify
+//
+// Routes are validated by a Policy applied via the corebgp.InboundPolicy peer
+// option, while a Monitor revalidates the routes of a RIB as VRPs change.
+package rpki
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/jwhited/corebgp"
+	"github.com/jwhited/corebgp/prefixtrie"
+)
+
+// State is an origin validation state.
+// https://tools.ietf.org/html/rfc6811#section-2
+type State uint8
+
+// State values
+const (
+	// NotFound indicates no VRP covers the prefix.
+	NotFound State = iota
+	// Valid indicates a VRP covering the prefix matches its origin AS and
+	// length.
+	Valid
+	// Invalid indicates VRPs cover the prefix, none of which matches its
+	// origin AS and length.
+	Invalid
+)
+
+func (s State) String() string {
+	switch s {
+	case NotFound:
+		return "not-found"
+	case Valid:
+		return "valid"
+	case Invalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// VRP is a Validated ROA Payload.
+type VRP struct {
+	Prefix    *net.IPNet
+	MaxLength int
+	// ASN is the AS authorized to originate Prefix and its more specifics up
+	// to MaxLength. AS 0 authorizes no origin (RFC6483).
+	ASN uint32
+}
+
+func (v VRP) String() string {
+	return fmt.Sprintf("%s-%d AS%d", v.Prefix, v.MaxLength, v.ASN)
+}
+
+func (v VRP) validate() error {
+	if v.Prefix == nil {
+		return errors.New("vrp without prefix")
+	}
+	bits, size := v.Prefix.Mask.Size()
+	if size == 0 || v.MaxLength < bits || v.MaxLength > size {
+		return fmt.Errorf("invalid vrp max length: %s", v)
+	}
+	return nil
+}
+
+type vrpKey struct {
+	maxLength int
+	asn       uint32
+}
+
+// Table holds VRPs. It is safe for concurrent use.
+type Table struct {
+	mu sync.RWMutex
+	// trie holds map[vrpKey]bool values keyed by VRP prefix
+	trie     *prefixtrie.Trie
+	len      int
+	handlers []func(added, removed []VRP)
+}
+
+// NewTable returns an empty Table.
+func NewTable() *Table {
+	return &Table{
+		trie: prefixtrie.New(),
+	}
+}
+
+// OnChange registers fn to be called with the VRPs added and removed by each
+// change of the table. It is called after the change is applied, in the
+// goroutine changing the table.
+func (t *Table) OnChange(fn func(added, removed []VRP)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, fn)
+}
+
+func (t *Table) notify(added, removed []VRP) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	t.mu.RLock()
+	handlers := t.handlers
+	t.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(added, removed)
+	}
+}
+
+// add adds v, returning false if it is already present. t.mu must be held.
+func (t *Table) add(v VRP) bool {
+	set := make(map[vrpKey]bool)
+	if s, ok := t.trie.Get(v.Prefix); ok {
+		set = s.(map[vrpKey]bool)
+	}
+	key := vrpKey{v.MaxLength, v.ASN}
+	if set[key] {
+		return false
+	}
+	set[key] = true
+	t.trie.Insert(v.Prefix, set)
+	t.len++
+	return true
+}
+
+// remove removes v, returning false if it is not present. t.mu must be held.
+func (t *Table) remove(v VRP) bool {
+	s, ok := t.trie.Get(v.Prefix)
+	if !ok {
+		return false
+	}
+	set := s.(map[vrpKey]bool)
+	key := vrpKey{v.MaxLength, v.ASN}
+	if !set[key] {
+		return false
+	}
+	delete(set, key)
+	if len(set) == 0 {
+		t.trie.Delete(v.Prefix)
+	}
+	t.len--
+	return true
+}
+
+// Add adds vrps to the table, e.g. announced by an RTR cache.
+func (t *Table) Add(vrps ...VRP) error {
+	for _, v := range vrps {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	var added []VRP
+	t.mu.Lock()
+	for _, v := range vrps {
+		if t.add(v) {
+			added = append(added, v)
+		}
+	}
+	t.mu.Unlock()
+	t.notify(added, nil)
+	return nil
+}
+
+// Remove removes vrps from the table, e.g. withdrawn by an RTR cache.
+func (t *Table) Remove(vrps ...VRP) {
+	var removed []VRP
+	t.mu.Lock()
+	for _, v := range vrps {
+		if v.Prefix != nil && t.remove(v) {
+			removed = append(removed, v)
+		}
+	}
+	t.mu.Unlock()
+	t.notify(nil, removed)
+}
+
+// Replace replaces the content of the table with vrps, e.g. following an RTR
+// cache reset.
+func (t *Table) Replace(vrps []VRP) error {
+	next := prefixtrie.New()
+	n := &Table{trie: next}
+	for _, v := range vrps {
+		if err := v.validate(); err != nil {
+			return err
+		}
+		n.add(v)
+	}
+	var added, removed []VRP
+	t.mu.Lock()
+	walkVRPs(t.trie, func(v VRP) {
+		if !n.contains(v) {
+			removed = append(removed, v)
+		}
+	})
+	walkVRPs(next, func(v VRP) {
+		if !t.contains(v) {
+			added = append(added, v)
+		}
+	})
+	t.trie, t.len = next, n.len
+	t.mu.Unlock()
+	t.notify(added, removed)
+	return nil
+}
+
+func (t *Table) contains(v VRP) bool {
+	s, ok := t.trie.Get(v.Prefix)
+	return ok && s.(map[vrpKey]bool)[vrpKey{v.MaxLength, v.ASN}]
+}
+
+func walkVRPs(trie *prefixtrie.Trie, fn func(v VRP)) {
+	trie.Walk(func(prefix *net.IPNet, s interface{}) bool {
+		for key := range s.(map[vrpKey]bool) {
+			fn(VRP{Prefix: prefix, MaxLength: key.maxLength, ASN: key.asn})
+		}
+		return true
+	})
+}
+
+// Len returns the number of VRPs in the table.
+func (t *Table) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.len
+}
+
+// ValidateOrigin returns the validation state of prefix originated by
+// origin. An origin of zero represents the NONE origin of a route whose
+// AS_PATH ends with an AS_SET, which matches no VRP.
+// https://tools.ietf.org/html/rfc6811#section-2
+func (t *Table) ValidateOrigin(prefix *net.IPNet, origin uint32) State {
+	return validate(prefix, origin, t.Covering(prefix))
+}
+
+// Covering returns the VRPs whose prefix covers prefix, i.e. those relevant
+// to its validation.
+func (t *Table) Covering(prefix *net.IPNet) []VRP {
+	var vrps []VRP
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.trie.Covering(prefix, func(p *net.IPNet, s interface{}) bool {
+		for key := range s.(map[vrpKey]bool) {
+			vrps = append(vrps, VRP{
+				Prefix:    p,
+				MaxLength: key.maxLength,
+				ASN:       key.asn,
+			})
+		}
+		return true
+	})
+	return vrps
+}
+
+// validate returns the validation state of prefix originated by origin
+// against vrps, which are those covering prefix.
+func validate(prefix *net.IPNet, origin uint32, vrps []VRP) State {
+	if len(vrps) == 0 {
+		return NotFound
+	}
+	bits, _ := prefix.Mask.Size()
+	for _, v := range vrps {
+		if origin != 0 && v.ASN == origin && bits <= v.MaxLength {
+			return Valid
+		}
+	}
+	return Invalid
+}
+
+// Validate returns the validation state of prefix with AS_PATH path. The
+// origin AS is that of corebgp.ASPath.OriginASN, which is NONE if path ends
+// with an AS_SET. Routes with an empty AS_PATH are originated by the local
+// AS, and should be validated via ValidateOrigin.
+func (t *Table) Validate(prefix *net.IPNet, path corebgp.ASPath) State {
+	origin, _ := path.OriginASN()
+	return t.ValidateOrigin(prefix, origin)
+}
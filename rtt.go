@@ -0,0 +1,97 @@
+package corebgp
+
+import (
+	"sync"
+	"time"
+)
+
+// KeepAliveStats describes the KEEPALIVE messages received from a peer and
+// the round-trip time (RTT) toward it during its latest established session,
+// e.g. for detecting path changes toward the peer as a change of RTT or
+// jitter.
+//
+// OpenRTT is measured once as the time from sending the OPEN message to
+// receiving the peer's KEEPALIVE message in the OpenConfirm state, which
+// includes the peer's processing of the OPEN message. On Linux, RTT, RTTVar
+// and MinRTT are the kernel's estimates for the session's TCP connection,
+// sampled upon each KEEPALIVE message received in the Established state.
+// They are zero on other platforms and for connections of a custom Dialer not
+// exposing their socket.
+type KeepAliveStats struct {
+	// Received is the number of KEEPALIVE messages received in the
+	// Established state, and LastReceived the time of the latest one.
+	Received     uint64
+	LastReceived time.Time
+	// Interval is the smoothed interval between KEEPALIVE messages received,
+	// and IntervalJitter the smoothed difference between consecutive
+	// intervals, similar to the interarrival jitter of RFC3550.
+	Interval       time.Duration
+	IntervalJitter time.Duration
+	OpenRTT        time.Duration
+	// RTT is the smoothed RTT (tcpi_rtt) and RTTVar its mean deviation
+	// (tcpi_rttvar), as computed by the kernel per RFC6298 for SRTT and
+	// RTTVAR. MinRTT is the lowest RTT observed over the connection
+	// (tcpi_min_rtt), zero if not reported by the kernel.
+	RTT    time.Duration
+	RTTVar time.Duration
+	MinRTT time.Duration
+}
+
+// keepAliveStats maintains the KeepAliveStats of a peer, updated by its
+// established FSM.
+type keepAliveStats struct {
+	mu    sync.Mutex
+	stats KeepAliveStats
+	// lastInterval is the latest interval between KEEPALIVE messages
+	lastInterval time.Duration
+}
+
+// reset resets the stats for a new session whose OPEN message RTT was
+// openRTT.
+func (k *keepAliveStats) reset(openRTT time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stats = KeepAliveStats{
+		OpenRTT: openRTT,
+	}
+	k.lastInterval = 0
+}
+
+// received records a KEEPALIVE message received at t, along with the RTT
+// estimates of tcp if it is non-nil.
+func (k *keepAliveStats) received(t time.Time, tcp *TCPStats) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s := &k.stats
+	if !s.LastReceived.IsZero() {
+		interval := t.Sub(s.LastReceived)
+		if k.lastInterval == 0 {
+			s.Interval = interval
+		} else {
+			s.Interval += (interval - s.Interval) / 8
+			d := absDuration(interval - k.lastInterval)
+			s.IntervalJitter += (d - s.IntervalJitter) / 16
+		}
+		k.lastInterval = interval
+	}
+	s.Received++
+	s.LastReceived = t
+	if tcp != nil {
+		s.RTT = tcp.RTT
+		s.RTTVar = tcp.RTTVar
+		s.MinRTT = tcp.MinRTT
+	}
+}
+
+func (k *keepAliveStats) snapshot() KeepAliveStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.stats
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
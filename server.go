@@ -1,11 +1,14 @@
 package corebgp
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,10 +21,22 @@ type Server struct {
 	doneServingCh chan struct{}
 	closeCh       chan struct{}
 	closeOnce     sync.Once
+	// ctx is canceled by Close, aborting in-flight dials
+	ctx           context.Context
+	cancelCtx     context.CancelFunc
+	events        *eventBus
+	metrics       *serverMetrics
+	dynamicPeerFn DynamicPeerFunc
+	options       serverOptions
+	// listeners passed to Serve, on which the keys of TCPAuth are installed
+	// and the ListenerSourceFilter is attached
+	listeners []net.Listener
+	// sourceFilter holds the *sourceFilter of ListenerSourceFilter
+	sourceFilter atomic.Value
 }
 
 // NewServer creates a new Server.
-func NewServer(routerID net.IP) (*Server, error) {
+func NewServer(routerID net.IP, opts ...ServerOption) (*Server, error) {
 	v4 := routerID.To4()
 	if v4 == nil {
 		return nil, errors.New("invalid router ID")
@@ -33,12 +48,122 @@ func NewServer(routerID net.IP) (*Server, error) {
 		peers:         make(map[string]*peer),
 		doneServingCh: make(chan struct{}),
 		closeCh:       make(chan struct{}),
+		events:        newEventBus(),
+		metrics:       &serverMetrics{},
 	}
+	for _, opt := range opts {
+		opt.apply(&s.options)
+	}
+	s.ctx, s.cancelCtx = context.WithCancel(context.Background())
 	return s, nil
 }
 
+type serverOptions struct {
+	listenOnly       bool
+	connMatch        ConnMatchFunc
+	strictIPv4Mapped bool
+	sourceFilter     bool
+	allowedSources   []*net.IPNet
+}
+
+// ServerOption is an option for a Server.
+type ServerOption interface {
+	apply(*serverOptions)
+}
+
+type funcServerOption struct {
+	fn func(*serverOptions)
+}
+
+func (f *funcServerOption) apply(o *serverOptions) {
+	f.fn(o)
+}
+
+func newFuncServerOption(f func(*serverOptions)) *funcServerOption {
+	return &funcServerOption{
+		fn: f,
+	}
+}
+
+// ListenOnly returns a ServerOption that sets a Server to listen-only mode. In
+// listen-only mode the Server never dials out, all peers are handled as if
+// they were Passive, and adding an ActiveOnly peer fails. Connection
+// collisions cannot occur as each peer has at most one connection.
+func ListenOnly() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) {
+		o.listenOnly = true
+	})
+}
+
+// ConnMatchFunc returns the address of the peer that handles an incoming
+// connection, which need not be the connection's remote address. If it is not
+// the address of a configured peer the connection is offered to the
+// DynamicPeerFunc, if any. A nil address closes the connection.
+type ConnMatchFunc func(conn net.Conn) net.IP
+
+// ConnMatcher returns a ServerOption that sets the function matching incoming
+// connections to peers, e.g. by source prefix for peers behind NAT, or by
+// local address or interface for unnumbered peers. By default a connection
+// is handled by the peer of its remote address. fn is called from the
+// listener's accept loop and should not block.
+func ConnMatcher(fn ConnMatchFunc) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) {
+		o.connMatch = fn
+	})
+}
+
+// StrictIPv4Mapped returns a ServerOption that rejects connections from
+// IPv4-mapped IPv6 addresses (::ffff:192.0.2.1), as accepted by dual-stack
+// listeners, rather than handling them by the peer of the IPv4 address
+// (192.0.2.1). It does not affect a ConnMatcher.
+func StrictIPv4Mapped() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) {
+		o.strictIPv4Mapped = true
+	})
+}
+
+// normalizeIP returns ip in its 4-byte form if it is an IPv4 or IPv4-mapped
+// IPv6 address, otherwise in its 16-byte form, so that peer addresses compare
+// equal regardless of the form they were configured or accepted in.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip.To16()
+}
+
+// matchConn returns the address of the peer handling conn, or nil.
+func (s *Server) matchConn(conn net.Conn) net.IP {
+	if s.options.connMatch != nil {
+		return normalizeIP(s.options.connMatch(conn))
+	}
+	var ip net.IP
+	if a, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		ip = a.IP
+	} else {
+		h, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			return nil
+		}
+		ip = net.ParseIP(h)
+	}
+	// addresses of IPv4 sockets are 4 bytes long, those of dual-stack IPv6
+	// sockets 16 bytes long
+	if s.options.strictIPv4Mapped && len(ip) == net.IPv6len &&
+		ip.To4() != nil {
+		logf("[%s] rejecting connection from IPv4-mapped address", ip)
+		return nil
+	}
+	return normalizeIP(ip)
+}
+
 var (
 	ErrServerClosed = errors.New("server closed")
+	ErrPeerExists   = errors.New("peer already exists")
+	ErrPeerNotExist = errors.New("peer does not exist")
+	// ErrPeerAmbiguous is returned when a peer is looked up by IP address
+	// while several peers share it, see PeerKey.
+	ErrPeerAmbiguous = errors.New("several peers share address")
 )
 
 // Serve starts all peers' FSMs, starts handling incoming connections if a
@@ -59,6 +184,9 @@ func (s *Server) Serve(lis net.Listener) error {
 
 	// set serving state and enable peers
 	s.serving = true
+	if lis != nil {
+		s.addListener(lis)
+	}
 	for _, peer := range s.peers {
 		peer.start()
 	}
@@ -67,6 +195,9 @@ func (s *Server) Serve(lis net.Listener) error {
 	defer func() {
 		// disable peers and set serving state before returning
 		s.mu.Lock()
+		if lis != nil {
+			s.removeListener(lis)
+		}
 		for _, peer := range s.peers {
 			peer.stop()
 		}
@@ -84,20 +215,16 @@ func (s *Server) Serve(lis net.Listener) error {
 					lisErrCh <- err
 					return
 				}
-				h, _, err := net.SplitHostPort(conn.RemoteAddr().String())
-				if err != nil {
+				if !s.acceptsSource(conn) {
 					conn.Close()
 					continue
 				}
-				s.mu.Lock()
-				p, exists := s.peers[h]
-				if !exists {
+				ip := s.matchConn(conn)
+				if ip == nil {
 					conn.Close()
-					s.mu.Unlock()
 					continue
 				}
-				p.incomingConnection(conn)
-				s.mu.Unlock()
+				s.handleConn(conn, ip, nil)
 			}
 		}()
 	}
@@ -119,6 +246,7 @@ func (s *Server) Close() {
 	s.mu.Lock()
 	s.closeOnce.Do(func() {
 		close(s.closeCh)
+		s.cancelCtx()
 	})
 	if !s.serving {
 		s.mu.Unlock()
@@ -130,14 +258,17 @@ func (s *Server) Close() {
 
 // PeerConfig is the required configuration for a Peer.
 type PeerConfig struct {
-	IP       net.IP
-	LocalAS  uint32
+	IP      net.IP
+	LocalAS uint32
+	// RemoteAS may be zero if the peer is added with AnyRemoteAS or
+	// RemoteASRanges.
 	RemoteAS uint32
 }
 
 const (
 	DefaultHoldTime     = time.Second * 90
 	DefaultIdleHoldTime = time.Second * 5
+	DefaultPort         = 179
 )
 
 func defaultPeerOptions() *peerOptions {
@@ -145,6 +276,12 @@ func defaultPeerOptions() *peerOptions {
 		holdTime:     DefaultHoldTime,
 		idleHoldTime: DefaultIdleHoldTime,
 		passive:      false,
+		port:         DefaultPort,
+		dialTimeout:  DefaultDialTimeout,
+		historySize:  DefaultHistorySize,
+
+		openSentHoldTime: longHoldTime,
+		maxMessageLength: ExtendedMaxMessageLength,
 	}
 }
 
@@ -174,6 +311,67 @@ func Passive() PeerOption {
 	})
 }
 
+// ActiveOnly returns a PeerOption that sets a Peer to active-only mode. In
+// active-only mode a peer only dials out, and incoming connections from it
+// are closed without being read from. Connection collisions cannot occur as
+// the peer has at most one connection. ActiveOnly and Passive are mutually
+// exclusive.
+func ActiveOnly() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.activeOnly = true
+	})
+}
+
+// HoldTime returns a PeerOption that sets the hold time offered to a peer,
+// truncated to seconds, which defaults to DefaultHoldTime. The negotiated hold
+// time is the lesser of the offered and received hold times. If it is zero
+// KEEPALIVE messages are not sent, and sessions are not closed due to silence
+// from the peer. Nonzero values less than three seconds are raised to three
+// seconds as required by RFC4271.
+func HoldTime(t time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		t = t.Truncate(time.Second)
+		switch {
+		case t < 0:
+			t = 0
+		case t > 0 && t < time.Second*3:
+			t = time.Second * 3
+		case t > time.Second*math.MaxUint16:
+			t = time.Second * math.MaxUint16
+		}
+		o.holdTime = t
+	})
+}
+
+// HeaderMarkerValidation returns a PeerOption that sets the handling of
+// messages received with an invalid header marker, which defaults to
+// MarkerValidationStrict. Messages accepted despite an invalid marker are
+// counted by PeerCounters.InvalidMarkers, and logged once per connection.
+func HeaderMarkerValidation(v MarkerValidation) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.markerValidation = v
+	})
+}
+
+// MaxExtendedMessageLength returns a PeerOption that sets the maximum length
+// of messages accepted from a peer once the Extended Message capability
+// (RFC8654) is negotiated, which defaults to ExtendedMaxMessageLength. Values
+// below MaxMessageLength, which applies until the capability is negotiated
+// and to OPEN and KEEPALIVE messages, are raised to it. Longer messages are
+// rejected with a Bad Message Length NOTIFICATION and counted by
+// PeerCounters.OversizedMessages.
+func MaxExtendedMessageLength(n int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		switch {
+		case n < MaxMessageLength:
+			n = MaxMessageLength
+		case n > ExtendedMaxMessageLength:
+			n = ExtendedMaxMessageLength
+		}
+		o.maxMessageLength = n
+	})
+}
+
 // IdleHoldTime returns a PeerOption that sets the idle hold time for a peer.
 // Idle hold time controls how quickly a peer can oscillate from idle to the
 // connect state.
@@ -183,20 +381,249 @@ func IdleHoldTime(t time.Duration) PeerOption {
 	})
 }
 
+// LocalAddress returns a PeerOption that sets the local address used when
+// dialing a peer. It is ignored if the peer is dialed via a custom Dialer.
+func LocalAddress(ip net.IP) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.localAddress = ip
+	})
+}
+
+// Port returns a PeerOption that sets the TCP port used when dialing a peer.
+// Several peers may share an address on different ports, e.g. BGP daemons
+// behind a NAT or a virtual IP, see PeerKey. An incoming connection from a
+// shared address is handled by the peer whose port is the connection's local
+// port, otherwise by the only one that is not ActiveOnly, otherwise by the
+// only one accepting the AS of the OPEN message received on it. It is
+// rejected if none or several qualify. Methods identifying a peer by address
+// alone return ErrPeerAmbiguous for a shared address, see the ByKey-suffixed
+// ones.
+func Port(port int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.port = port
+	})
+}
+
+// AuthOptionalParam returns a PeerOption that sets the handling of the
+// deprecated authentication optional parameter in OPEN messages received from
+// a peer. Some legacy implementations still send it. The default is
+// OptionalParamReject. Skipped parameters are logged.
+func AuthOptionalParam(policy OptionalParamPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.authOptionalParamPolicy = policy
+	})
+}
+
+// DynamicCapability returns a PeerOption that enables the Dynamic Capability
+// mechanism for a peer. The Dynamic Capability capability listing codes is
+// included in Open messages sent to the peer. If the peer also advertises
+// Dynamic Capability, CAPABILITY messages can be sent via the
+// CapabilityMessageWriter implemented by the UpdateMessageWriter, and are
+// received via DynamicCapabilityPlugin if implemented by the Plugin.
+// CAPABILITY messages changing capabilities whose codes the peer did not list
+// in its Dynamic Capability capability, or carrying malformed changes, are
+// rejected with a CAPABILITY Message Error NOTIFICATION. Otherwise CAPABILITY
+// messages received from the peer result in a Bad Message Type NOTIFICATION.
+func DynamicCapability(codes ...uint8) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.dynamicCapability = true
+		o.dynamicCapabilityCodes = codes
+	})
+}
+
+// AllowASIn returns a PeerOption that enables AS_PATH loop detection of
+// UPDATE messages received from a peer, permitting up to count occurrences of
+// the local AS. UPDATE messages exceeding count are treated as a withdrawal
+// of the NLRI they carry before being passed to the UpdateMessageHandler. A
+// count of 0 enables standard loop detection. Without this option AS_PATH
+// loop detection is left to the Plugin.
+func AllowASIn(count int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.asLoopCheck = true
+		o.allowASIn = count
+	})
+}
+
+// ASOverride returns a PeerOption that replaces occurrences of the peer's AS
+// with the local AS in the AS_PATH of UPDATE messages sent to an eBGP peer.
+func ASOverride() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.asOverride = true
+	})
+}
+
+// UpdateRateAlarm returns a PeerOption that publishes an UpdateRateAlarmEvent
+// when more than threshold UPDATE messages are received from a peer within a
+// second. See Server.Subscribe().
+func UpdateRateAlarm(threshold int) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.updateRateAlarm = threshold
+	})
+}
+
+// UnknownOptionalParamHandler returns a PeerOption that sets a function used
+// to determine the handling of optional parameters of an unknown type in OPEN
+// messages received from a peer. It is not called for authentication
+// optional parameters if AuthOptionalParam(OptionalParamSkip) is set. Without
+// a handler unknown optional parameters are rejected. Skipped parameters are
+// logged.
+func UnknownOptionalParamHandler(
+	fn func(*UnknownOptionalParam) OptionalParamPolicy) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.unknownOptionalParamHandler = fn
+	})
+}
+
+// AnyRemoteAS returns a PeerOption that accepts OPEN messages from a peer
+// regardless of the AS they carry, as is common for route collectors. The
+// peer's AS is learned from its OPEN message and is available via
+// SessionInfo. PeerConfig.RemoteAS may be zero, otherwise it is ignored when
+// validating OPEN messages.
+func AnyRemoteAS() PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.anyRemoteAS = true
+	})
+}
+
+// HoldTimerGrace returns a PeerOption that sets a function called before
+// closing a session in the OpenConfirm or Established state due to hold timer
+// expiry. A positive duration returned by fn extends the hold timer by that
+// duration instead, e.g. when the application knows it stalled the reader.
+// The hold timer is extended at most once until a message is received from
+// the peer. Extensions are counted by PeerCounters.HoldTimerExtensions.
+func HoldTimerGrace(fn func(peer *PeerConfig) time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.holdTimerGrace = fn
+	})
+}
+
+// openSentHoldTime returns a PeerOption that sets the hold timer started when
+// transitioning to OpenSent in place of longHoldTime.
+func openSentHoldTime(t time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.openSentHoldTime = t
+	})
+}
+
 type peerOptions struct {
 	holdTime     time.Duration
 	idleHoldTime time.Duration
 	passive      bool
+	activeOnly   bool
+	localAddress net.IP
+	port         int
+	anyRemoteAS  bool
+	dialTimeout  time.Duration
+	dialer       ContextDialer
+
+	// openSentHoldTime is the hold timer started when transitioning to
+	// OpenSent, longHoldTime unless shortened by tests
+	openSentHoldTime time.Duration
+
+	// remoteASRanges are the ASes accepted in addition to RemoteAS
+	remoteASRanges []ASRange
+	// vrf is the VRF device sockets are bound to, if any
+	vrf string
+
+	socketControl SocketControlFunc
+	fwMark        uint32
+	trafficClass  uint8
+	flowLabel     uint32
+
+	dualStackAddress net.IP
+	dualStackDelay   time.Duration
+
+	authOptionalParamPolicy     OptionalParamPolicy
+	unknownOptionalParamHandler func(*UnknownOptionalParam) OptionalParamPolicy
+	openValidation              OpenValidationPolicy
+
+	dynamicCapability      bool
+	dynamicCapabilityCodes []uint8
+
+	asLoopCheck bool
+	allowASIn   int
+	asOverride  bool
+
+	updateRateAlarm  int
+	inboundRateLimit *RateLimit
+
+	inboundQueueCapacity int
+	inboundQueuePolicy   QueueOverflowPolicy
+
+	// writeScheduler is the WriteScheduler UPDATE messages are written via,
+	// if any
+	writeScheduler *WriteScheduler
+
+	memoryBudget PeerMemoryBudget
+	decodeArena  bool
+	readBatcher  *ReadBatcher
+
+	tcpStatsInterval   time.Duration
+	deduplicateUpdates bool
+	tcpAuth            *tcpAuth
+
+	holdTimerGrace    func(*PeerConfig) time.Duration
+	updateErrorPolicy func(*PeerConfig, *Notification) UpdateErrorAction
+
+	keepAliveJitter    float64
+	suppressKeepAlives bool
+
+	lintUpdates    bool
+	outboundPolicy OutboundPolicyFunc
+	inboundPolicy  InboundPolicyFunc
+
+	markerValidation MarkerValidation
+	maxMessageLength int
+
+	optionalCapabilities map[uint8]bool
+	requiredCapabilities []*Capability
+
+	historySize int
+
+	messageHistorySize      int
+	onMessageHistoryFailure func(*PeerConfig, []RecordedMessage)
 }
 
-func (p *PeerConfig) validate() error {
+// optionalParamPolicy returns the OptionalParamPolicy for an unknown optional
+// parameter received from the peer.
+func (o *peerOptions) optionalParamPolicy(
+	p *UnknownOptionalParam) OptionalParamPolicy {
+	if p.ParamType == AuthOptionalParamType &&
+		o.authOptionalParamPolicy == OptionalParamSkip {
+		return OptionalParamSkip
+	}
+	if o.unknownOptionalParamHandler != nil {
+		return o.unknownOptionalParamHandler(p)
+	}
+	return OptionalParamReject
+}
+
+func (p *PeerConfig) validate(o *peerOptions) error {
 	if p.IP.To4() == nil && p.IP.To16() == nil {
 		return errors.New("invalid peer IP")
 	}
 	// https://tools.ietf.org/html/rfc7607
-	if p.LocalAS == 0 || p.RemoteAS == 0 {
+	if p.LocalAS == 0 || (p.RemoteAS == 0 && !o.anyRemoteAS &&
+		len(o.remoteASRanges) == 0) {
 		return errors.New("AS must be > 0")
 	}
+	if o.port < 1 || o.port > 65535 {
+		return errors.New("port must be within 1-65535")
+	}
+	if o.flowLabel > 0xfffff {
+		return errors.New("flow label exceeds 20 bits")
+	}
+	if o.passive && o.activeOnly {
+		return errors.New("passive and active-only are mutually exclusive")
+	}
+	if o.tcpAuth != nil {
+		if !tcpAuthSupported {
+			return errors.New("tcp auth is only supported on linux")
+		}
+		if err := o.tcpAuth.keys[0].validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -204,41 +631,200 @@ func (p *PeerConfig) validate() error {
 // PeerOptions.
 func (s *Server) AddPeer(config *PeerConfig, plugin Plugin,
 	opts ...PeerOption) error {
-	err := config.validate()
-	if err != nil {
-		return fmt.Errorf("peer config invalid: %v", err)
-	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, exists := s.peers[config.IP.String()]
-	if exists {
-		return errors.New("peer already exists")
-	}
+	_, err := s.addPeer(config, plugin, opts, false)
+	return err
+}
+
+// addPeer adds a peer, s.mu must be held.
+func (s *Server) addPeer(config *PeerConfig, plugin Plugin, opts []PeerOption,
+	dynamic bool) (*peer, error) {
 	o := defaultPeerOptions()
 	for _, opt := range opts {
 		opt.apply(o)
 	}
-	p := newPeer(config, s.id, plugin, o)
+	err := config.validate(o)
+	if err != nil {
+		return nil, fmt.Errorf("peer config invalid: %v", err)
+	}
+	c := *config
+	c.IP = normalizeIP(c.IP)
+	config = &c
+	_, exists := s.peers[PeerKey{
+		VRF:      o.vrf,
+		IP:       config.IP,
+		Port:     o.port,
+		RemoteAS: config.RemoteAS,
+	}.String()]
+	if exists {
+		return nil, ErrPeerExists
+	}
+	err = validateASPathOptions(config, o)
+	if err != nil {
+		return nil, fmt.Errorf("peer options invalid: %v", err)
+	}
+	if s.options.listenOnly && o.activeOnly {
+		return nil, errors.New("active-only peer on listen-only server")
+	}
+	p := newPeer(s.ctx, config, s.id, plugin, o, s.events, s.metrics)
+	p.listenOnly = s.options.listenOnly
+	if dynamic {
+		p.dynamic = true
+		p.onIdle = func() {
+			go s.deleteDynamicPeer(p)
+		}
+	}
+	if o.tcpAuth != nil && !dynamic {
+		for _, lis := range s.listeners {
+			if err := p.installListenerKeys(lis); err != nil {
+				return nil, fmt.Errorf("error installing tcp auth key on "+
+					"listener: %v", err)
+			}
+		}
+	}
 	if s.serving {
 		p.start()
 	}
-	s.peers[p.config.IP.String()] = p
-	return nil
+	s.peers[p.key()] = p
+	if !dynamic {
+		s.updateSourceFilter()
+	}
+	s.events.publish(&PeerAddedEvent{eventBase: newEventBase(p)})
+	return p, nil
+}
+
+// DynamicPeerFunc returns the configuration of a dynamic peer for an incoming
+// connection from ip. A nil config rejects the connection. config.IP is set
+// to ip.
+type DynamicPeerFunc func(ip net.IP) (config *PeerConfig, plugin Plugin,
+	opts []PeerOption)
+
+// AcceptDynamicPeers sets a function called for incoming connections from
+// addresses that are not configured peers. The peers it returns are added to
+// the Server as passive peers, and are deleted once their connection closes.
+// fn is called from the listener's accept loop and should not block.
+//
+// This is typically used by route collectors in combination with
+// AnyRemoteAS.
+func (s *Server) AcceptDynamicPeers(fn DynamicPeerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dynamicPeerFn = fn
+}
+
+// acceptDynamicPeer adds a dynamic peer for ip, returning nil if it is
+// rejected. s.mu must be held.
+func (s *Server) acceptDynamicPeer(ip net.IP) *peer {
+	fn := s.dynamicPeerFn
+	if fn == nil || ip == nil {
+		return nil
+	}
+	config, plugin, opts := fn(ip)
+	if config == nil {
+		return nil
+	}
+	c := *config
+	c.IP = ip
+	p, err := s.addPeer(&c, plugin, append(opts, Passive()), true)
+	if err != nil {
+		logf("[%s] error adding dynamic peer: %v", ip, err)
+		return nil
+	}
+	return p
+}
+
+// deleteDynamicPeer deletes dynamic peer p if it has not been deleted
+// already.
+func (s *Server) deleteDynamicPeer(p *peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.peers[p.key()] != p {
+		return
+	}
+	p.stop()
+	delete(s.peers, p.key())
+	s.events.publish(&PeerDeletedEvent{eventBase: newEventBase(p)})
 }
 
 // DeletePeer deletes a peer from the Server.
 func (s *Server) DeletePeer(ip net.IP) error {
+	return s.deletePeer(PeerKey{IP: ip}, true)
+}
+
+func (s *Server) deletePeer(k PeerKey, anyVRF bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	p, exists := s.peers[ip.String()]
-	if !exists {
-		return errors.New("peer does not exist")
+	p, err := s.lookupPeer(k, anyVRF)
+	if err != nil {
+		return err
 	}
 	p.stop()
-	delete(s.peers, ip.String())
+	if p.options.tcpAuth != nil && !p.dynamic {
+		for _, lis := range s.listeners {
+			p.removeListenerKeys(lis)
+		}
+	}
+	delete(s.peers, p.key())
+	if !p.dynamic {
+		s.updateSourceFilter()
+	}
+	s.events.publish(&PeerDeletedEvent{eventBase: newEventBase(p)})
+	return nil
+}
+
+// peer returns the peer with IP address ip.
+func (s *Server) peer(ip net.IP) (*peer, error) {
+	return s.peerByKey(PeerKey{IP: ip}, true)
+}
+
+// peerByKey returns the peer matching k, in any VRF if anyVRF is true.
+func (s *Server) peerByKey(k PeerKey, anyVRF bool) (*peer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookupPeer(k, anyVRF)
+}
+
+// adminPeer applies an administrative action to the peer matching k, in any
+// VRF if anyVRF is true.
+func (s *Server) adminPeer(k PeerKey, anyVRF bool,
+	action adminAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.lookupPeer(k, anyVRF)
+	if err != nil {
+		return err
+	}
+	if s.serving {
+		p.admin(action)
+		return nil
+	}
+	// the peer is not running, only its disabled state is affected
+	switch action {
+	case adminDisable:
+		p.setAdminDisabled(true)
+	case adminEnable:
+		p.setAdminDisabled(false)
+	}
 	return nil
 }
 
-// TODO: Get/ListPeer
+// ResetPeer closes any connections with the peer, sending a Cease
+// Notification with the Administrative Reset subcode, and restarts it. It has
+// no effect on a disabled peer.
+func (s *Server) ResetPeer(ip net.IP) error {
+	return s.adminPeer(PeerKey{IP: ip}, true, adminReset)
+}
+
+// DisablePeer closes any connections with the peer, sending a Cease
+// Notification with the Administrative Shutdown subcode. The peer remains
+// configured but does not connect or accept connections until EnablePeer is
+// called.
+func (s *Server) DisablePeer(ip net.IP) error {
+	return s.adminPeer(PeerKey{IP: ip}, true, adminDisable)
+}
 
-// no need for Enable/DisablePeer complexity, just use Add/DeletePeer.
+// EnablePeer enables a peer previously disabled with DisablePeer.
+func (s *Server) EnablePeer(ip net.IP) error {
+	return s.adminPeer(PeerKey{IP: ip}, true, adminEnable)
+}
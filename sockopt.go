@@ -0,0 +1,271 @@
+package corebgp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// SocketControlFunc sets socket options on the socket of a connection to a
+// peer. network is "tcp4" or "tcp6" and address is the remote address, as
+// passed to net.Dialer.Control.
+type SocketControlFunc func(network, address string, c syscall.RawConn) error
+
+// SocketControl returns a PeerOption that sets a function called with the raw
+// socket of connections to a peer, e.g. to set SO_MARK or TCP_MD5SIG. It is
+// called for dialed sockets before they connect, and for accepted sockets
+// before any message is exchanged. Options that must precede the TCP
+// handshake of accepted connections, such as TCP MD5 signatures, must be set
+// on the listener as well. A connection is closed if fn returns an error. fn
+// is called before the socket options of other PeerOptions are set, and is
+// not called for sockets dialed via a custom Dialer.
+func SocketControl(fn SocketControlFunc) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.socketControl = fn
+	})
+}
+
+// FwMark returns a PeerOption that sets the packet mark (SO_MARK) of sockets
+// connecting to a peer, e.g. to steer the session via policy routing. A zero
+// mark leaves sockets unmarked. Marking requires Linux and CAP_NET_ADMIN;
+// connections fail otherwise.
+func FwMark(mark uint32) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.fwMark = mark
+	})
+}
+
+// TrafficClass returns a PeerOption that sets the IPv6 traffic class
+// (IPV6_TCLASS) of sockets connecting to a peer via IPv6. A zero traffic class
+// leaves the system default. It requires Linux; connections fail otherwise.
+func TrafficClass(tc uint8) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.trafficClass = tc
+	})
+}
+
+// FlowLabel returns a PeerOption that sets the IPv6 flow label of connections
+// dialed to a peer via IPv6. Only the lower 20 bits may be set, and a zero
+// label leaves the system default. The label is leased for the process, and
+// does not apply to accepted connections. It requires Linux; connections fail
+// otherwise.
+func FlowLabel(label uint32) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.flowLabel = label
+	})
+}
+
+// sockopt sets an option on socket fd of a connection to address.
+type sockopt struct {
+	name string
+	fn   func(fd uintptr, address string) error
+	// ipv6 options apply to tcp6 sockets only
+	ipv6 bool
+}
+
+// control returns the function setting socket options of connections to the
+// peer, or nil if none are set. dial is true for sockets to be dialed.
+func (o *peerOptions) control(dial bool) SocketControlFunc {
+	opts := make([]sockopt, 0)
+	if o.vrf != "" && dial {
+		vrf := o.vrf
+		opts = append(opts, sockopt{
+			name: "vrf",
+			fn: func(fd uintptr, _ string) error {
+				return setBindToDevice(fd, vrf)
+			},
+		})
+	}
+	if o.fwMark != 0 {
+		mark := o.fwMark
+		opts = append(opts, sockopt{
+			name: "fwmark",
+			fn: func(fd uintptr, _ string) error {
+				return setFwMark(fd, mark)
+			},
+		})
+	}
+	if o.trafficClass != 0 {
+		tc := o.trafficClass
+		opts = append(opts, sockopt{
+			name: "traffic class",
+			fn: func(fd uintptr, _ string) error {
+				return setTrafficClass(fd, tc)
+			},
+			ipv6: true,
+		})
+	}
+	if o.tcpAuth != nil && dial {
+		auth := o.tcpAuth
+		opts = append(opts, sockopt{
+			name: "tcp auth",
+			fn: func(fd uintptr, address string) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if i := strings.IndexByte(host, '%'); i >= 0 {
+					host = host[:i]
+				}
+				ip := net.ParseIP(host)
+				if ip == nil {
+					return errors.New("invalid address: " + host)
+				}
+				return auth.installKeys(fd, ip)
+			},
+		})
+	}
+	if o.flowLabel != 0 && dial {
+		// this connects the socket, so it must be the last option
+		label := o.flowLabel
+		opts = append(opts, sockopt{
+			name: "flow label",
+			fn: func(fd uintptr, address string) error {
+				return connectFlowLabel(fd, address, label)
+			},
+			ipv6: true,
+		})
+	}
+	fn := o.socketControl
+	if len(opts) == 0 {
+		return fn
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		if fn != nil {
+			err := fn(network, address, c)
+			if err != nil {
+				return err
+			}
+		}
+		for _, opt := range opts {
+			if opt.ipv6 && network != "tcp6" {
+				continue
+			}
+			var serr error
+			err := c.Control(func(fd uintptr) {
+				serr = opt.fn(fd, address)
+			})
+			if err != nil {
+				return err
+			}
+			if serr != nil {
+				return fmt.Errorf("error setting %s: %v", opt.name, serr)
+			}
+		}
+		return nil
+	}
+}
+
+// controlConn sets the socket options of the peer on accepted connection
+// conn.
+func (p *peer) controlConn(conn net.Conn) error {
+	fn := p.options.control(false)
+	if fn == nil {
+		return nil
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("connection does not expose its socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	network := "tcp6"
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok &&
+		addr.IP.To4() != nil {
+		network = "tcp4"
+	}
+	return fn(network, conn.RemoteAddr().String(), raw)
+}
+
+// connInterface returns the name of the network interface with the local
+// address of conn, or an empty string if there is none.
+func connInterface(conn net.Conn) string {
+	addr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+	if addr.Zone != "" {
+		return addr.Zone
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(addr.IP) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// connVRF returns the name of the device the socket of conn is bound to,
+// e.g. its VRF, or an empty string if there is none.
+func connVRF(conn net.Conn) string {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return ""
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return ""
+	}
+	var name string
+	err = raw.Control(func(fd uintptr) {
+		name, err = getBindToDevice(fd)
+	})
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// connTCPStats returns the TCPStats of conn, or nil if unavailable.
+func connTCPStats(conn net.Conn) *TCPStats {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var stats *TCPStats
+	err = raw.Control(func(fd uintptr) {
+		stats, err = getTCPStats(fd)
+	})
+	if err != nil {
+		return nil
+	}
+	return stats
+}
+
+// connMSS returns the maximum segment size of conn, or zero if unavailable.
+func connMSS(conn net.Conn) int {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var mss int
+	err = raw.Control(func(fd uintptr) {
+		mss, err = getMSS(fd)
+	})
+	if err != nil {
+		return 0
+	}
+	return mss
+}
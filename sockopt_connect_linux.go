@@ -0,0 +1,31 @@
+//go:build !386
+// +build !386
+
+package corebgp
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// rawGetsockopt gets the socket option of level and name of fd into b,
+// returning the length of the value.
+func rawGetsockopt(fd uintptr, level, name int, b []byte) (int, error) {
+	n := uint32(len(b))
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, uintptr(level),
+		uintptr(name), uintptr(unsafe.Pointer(&b[0])),
+		uintptr(unsafe.Pointer(&n)), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func rawConnect(fd uintptr, sa *rawSockaddrInet6) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_CONNECT, fd,
+		uintptr(unsafe.Pointer(sa)), unsafe.Sizeof(*sa))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
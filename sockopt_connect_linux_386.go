@@ -0,0 +1,11 @@
+package corebgp
+
+import "errors"
+
+func rawGetsockopt(fd uintptr, level, name int, b []byte) (int, error) {
+	return 0, errors.New("getsockopt is not supported on linux/386")
+}
+
+func rawConnect(fd uintptr, sa *rawSockaddrInet6) error {
+	return errors.New("flow label is not supported on linux/386")
+}
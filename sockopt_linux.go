@@ -0,0 +1,199 @@
+package corebgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func setFwMark(fd uintptr, mark uint32) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK,
+		int(mark))
+}
+
+func setBindToDevice(fd uintptr, name string) error {
+	return syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET,
+		syscall.SO_BINDTODEVICE, name)
+}
+
+func getBindToDevice(fd uintptr) (string, error) {
+	name := make([]byte, syscall.IFNAMSIZ)
+	n, err := rawGetsockopt(fd, syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE,
+		name)
+	if err != nil {
+		return "", err
+	}
+	if i := bytes.IndexByte(name[:n], 0); i >= 0 {
+		n = i
+	}
+	return string(name[:n]), nil
+}
+
+func getMSS(fd uintptr) (int, error) {
+	return syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP,
+		syscall.TCP_MAXSEG)
+}
+
+// tcpInfoMinRTTOffset is the offset of tcpi_min_rtt in struct tcp_info of
+// linux/tcp.h, which extends syscall.TCPInfo.
+const tcpInfoMinRTTOffset = 148
+
+func getTCPStats(fd uintptr) (*TCPStats, error) {
+	var ext struct {
+		info   syscall.TCPInfo
+		_      [tcpInfoMinRTTOffset - syscall.SizeofTCPInfo]byte
+		minRTT uint32
+	}
+	info := &ext.info
+	b := (*[unsafe.Sizeof(ext)]byte)(unsafe.Pointer(&ext))[:]
+	n, err := rawGetsockopt(fd, syscall.IPPROTO_TCP, syscall.TCP_INFO, b)
+	if err != nil {
+		return nil, err
+	}
+	if n < syscall.SizeofTCPInfo {
+		return nil, errors.New("tcp info is not supported by the kernel")
+	}
+	sendQueue, err := ioctlInt(fd, syscall.TIOCOUTQ)
+	if err != nil {
+		return nil, err
+	}
+	receiveQueue, err := ioctlInt(fd, syscall.TIOCINQ)
+	if err != nil {
+		return nil, err
+	}
+	var minRTT time.Duration
+	if n == len(b) {
+		minRTT = time.Duration(ext.minRTT) * time.Microsecond
+	}
+	return &TCPStats{
+		Time:               time.Now(),
+		RTT:                time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:             time.Duration(info.Rttvar) * time.Microsecond,
+		MinRTT:             minRTT,
+		RTO:                time.Duration(info.Rto) * time.Microsecond,
+		CongestionWindow:   info.Snd_cwnd,
+		SlowStartThreshold: info.Snd_ssthresh,
+		MSS:                info.Snd_mss,
+		Unacked:            info.Unacked,
+		Lost:               info.Lost,
+		Retransmits:        info.Total_retrans,
+		SendQueue:          sendQueue,
+		ReceiveQueue:       receiveQueue,
+	}, nil
+}
+
+// ioctlInt returns the int value of ioctl request req of fd.
+func ioctlInt(fd uintptr, req uintptr) (int, error) {
+	var v int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req,
+		uintptr(unsafe.Pointer(&v)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(v), nil
+}
+
+func setTrafficClass(fd uintptr, tc uint8) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+		syscall.IPV6_TCLASS, int(tc))
+}
+
+// linux/in6.h
+const (
+	ipv6FlowLabelMgr  = 32
+	ipv6FlowInfoSend  = 33
+	ipv6FlowLabelGet  = 0
+	ipv6FlowLabelNew  = 1
+	ipv6FlowLabelProc = 2
+)
+
+// in6FlowLabelReq is struct in6_flowlabel_req of linux/in6.h.
+type in6FlowLabelReq struct {
+	dst     [16]byte
+	label   [4]byte
+	action  uint8
+	share   uint8
+	flags   uint16
+	expires uint16
+	linger  uint16
+	_       uint32
+}
+
+// rawSockaddrInet6 is struct sockaddr_in6, with the byte order of port and
+// flowinfo made explicit.
+type rawSockaddrInet6 struct {
+	family   uint16
+	port     [2]byte
+	flowinfo [4]byte
+	addr     [16]byte
+	scopeID  uint32
+}
+
+// connectFlowLabel leases label for the socket fd and starts connecting it to
+// address with the label set. The net package does not set the flow
+// information of the addresses it connects to, but completes the connection
+// of a socket already connecting.
+func connectFlowLabel(fd uintptr, address string, label uint32) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+	var scopeID uint32
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		zone := host[i+1:]
+		host = host[:i]
+		if ifi, err := net.InterfaceByName(zone); err == nil {
+			scopeID = uint32(ifi.Index)
+		} else if n, err := strconv.ParseUint(zone, 10, 32); err == nil {
+			scopeID = uint32(n)
+		} else {
+			return errors.New("invalid zone: " + zone)
+		}
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() != nil {
+		return errors.New("invalid ipv6 address: " + host)
+	}
+
+	req := in6FlowLabelReq{
+		action: ipv6FlowLabelGet,
+		share:  ipv6FlowLabelProc,
+		flags:  ipv6FlowLabelNew,
+	}
+	copy(req.dst[:], ip)
+	binary.BigEndian.PutUint32(req.label[:], label)
+	err = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IPV6,
+		ipv6FlowLabelMgr, string((*[unsafe.Sizeof(req)]byte)(
+			unsafe.Pointer(&req))[:]))
+	if err != nil {
+		return err
+	}
+	err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+		ipv6FlowInfoSend, 1)
+	if err != nil {
+		return err
+	}
+
+	sa := rawSockaddrInet6{
+		family:  syscall.AF_INET6,
+		scopeID: scopeID,
+	}
+	binary.BigEndian.PutUint16(sa.port[:], uint16(port))
+	binary.BigEndian.PutUint32(sa.flowinfo[:], label)
+	copy(sa.addr[:], ip)
+	err = rawConnect(fd, &sa)
+	if err != nil && err != syscall.EINPROGRESS {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+import "errors"
+
+func setFwMark(fd uintptr, mark uint32) error {
+	return errors.New("fwmark is only supported on linux")
+}
+
+func setBindToDevice(fd uintptr, name string) error {
+	return errors.New("vrf is only supported on linux")
+}
+
+func getBindToDevice(fd uintptr) (string, error) {
+	return "", errors.New("vrf is only supported on linux")
+}
+
+func getMSS(fd uintptr) (int, error) {
+	return 0, errors.New("mss is only supported on linux")
+}
+
+func getTCPStats(fd uintptr) (*TCPStats, error) {
+	return nil, errors.New("tcp stats are only supported on linux")
+}
+
+func setTrafficClass(fd uintptr, tc uint8) error {
+	return errors.New("traffic class is only supported on linux")
+}
+
+func connectFlowLabel(fd uintptr, address string, label uint32) error {
+	return errors.New("flow label is only supported on linux")
+}
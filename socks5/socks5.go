@@ -0,0 +1,323 @@
+// Package socks5 implements a SOCKS5 (RFC1928) client with optional
+// username/password authentication (RFC1929), for reaching peers through a
+// bastion host or service mesh proxy via the corebgp.Dialer PeerOption:
+//
+//	d := socks5.New("bastion.example.com:1080", socks5.Auth("user", "pass"))
+//	err := srv.AddPeer(config, plugin, corebgp.Dialer(d))
+package socks5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// https://tools.ietf.org/html/rfc1928
+const (
+	version = 0x05
+
+	methodNoAuth   = 0x00
+	methodUserPass = 0x02
+
+	cmdConnect = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+
+	// https://tools.ietf.org/html/rfc1929
+	userPassVersion = 0x01
+)
+
+var replies = map[uint8]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// ReplyError is returned by Dialer.DialContext when the proxy fails to
+// connect to the requested address.
+type ReplyError struct {
+	// Reply is the REP field of the proxy's reply.
+	Reply uint8
+}
+
+func (e *ReplyError) Error() string {
+	msg, ok := replies[e.Reply]
+	if !ok {
+		msg = "unknown reply " + strconv.Itoa(int(e.Reply))
+	}
+	return "socks5: " + msg
+}
+
+// DialFailureCause classifies e for corebgp.DialStatus.
+func (e *ReplyError) DialFailureCause() corebgp.DialFailureCause {
+	switch e.Reply {
+	case 0x03, 0x04:
+		return corebgp.DialFailureUnreachable
+	case 0x05:
+		return corebgp.DialFailureRefused
+	case 0x06:
+		return corebgp.DialFailureTimeout
+	}
+	return corebgp.DialFailureOther
+}
+
+type options struct {
+	username string
+	password string
+	forward  corebgp.ContextDialer
+}
+
+func (o *options) setDefaults() {
+	o.forward = &net.Dialer{}
+}
+
+// Option is an option for a Dialer.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// Auth sets the username and password to authenticate with. By default no
+// authentication is offered to the proxy.
+func Auth(username, password string) Option {
+	return newFuncOption(func(o *options) {
+		o.username = username
+		o.password = password
+	})
+}
+
+// Forward sets the ContextDialer used to connect to the proxy, which defaults
+// to a net.Dialer. It may be another Dialer in order to chain proxies.
+func Forward(d corebgp.ContextDialer) Option {
+	return newFuncOption(func(o *options) {
+		if d != nil {
+			o.forward = d
+		}
+	})
+}
+
+// Dialer dials TCP connections via a SOCKS5 proxy. It implements
+// corebgp.ContextDialer.
+type Dialer struct {
+	proxy   string
+	options options
+}
+
+// New returns a Dialer connecting via the SOCKS5 proxy at address.
+func New(address string, opts ...Option) *Dialer {
+	d := &Dialer{
+		proxy: address,
+	}
+	d.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&d.options)
+	}
+	return d
+}
+
+// FromURL returns a Dialer for a proxy URL of the form
+// socks5://[username:password@]host:port.
+func FromURL(rawurl string, opts ...Option) (*Dialer, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" {
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		return nil, errors.New("missing proxy port")
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		opts = append([]Option{Auth(u.User.Username(), password)}, opts...)
+	}
+	return New(u.Host, opts...), nil
+}
+
+// DialContext connects to address via the proxy. network must be "tcp",
+// "tcp4" or "tcp6". ctx bounds the connection to the proxy as well as the
+// SOCKS5 handshake.
+func (d *Dialer) DialContext(ctx context.Context, network,
+	address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: unsupported network: %s", network)
+	}
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: invalid port: %s", portStr)
+	}
+	conn, err := d.options.forward.DialContext(ctx, "tcp", d.proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	// interrupt the handshake once ctx is done
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	interrupted := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+			close(interrupted)
+		case <-done:
+		}
+	}()
+	err = d.handshake(conn, host, uint16(port))
+	close(done)
+	select {
+	case <-interrupted:
+		err = ctx.Err()
+	default:
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *Dialer) handshake(conn net.Conn, host string, port uint16) error {
+	auth := d.options.username != "" || d.options.password != ""
+	method := uint8(methodNoAuth)
+	if auth {
+		method = methodUserPass
+	}
+	_, err := conn.Write([]byte{version, 1, method})
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 2)
+	_, err = io.ReadFull(conn, b)
+	if err != nil {
+		return err
+	}
+	if b[0] != version {
+		return fmt.Errorf("socks5: unexpected version: %d", b[0])
+	}
+	if b[1] != method {
+		return errors.New("socks5: no acceptable authentication method")
+	}
+	if auth {
+		err = d.authenticate(conn)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := []byte{version, cmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, atypIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, atypIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("socks5: host name too long")
+		}
+		req = append(req, atypDomain, uint8(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, uint8(port>>8), uint8(port))
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	// VER, REP, RSV, ATYP, followed by BND.ADDR and BND.PORT
+	b = make([]byte, 4)
+	_, err = io.ReadFull(conn, b)
+	if err != nil {
+		return err
+	}
+	if b[0] != version {
+		return fmt.Errorf("socks5: unexpected version: %d", b[0])
+	}
+	if b[1] != 0 {
+		return &ReplyError{Reply: b[1]}
+	}
+	var addrLen int
+	switch b[3] {
+	case atypIPv4:
+		addrLen = net.IPv4len
+	case atypIPv6:
+		addrLen = net.IPv6len
+	case atypDomain:
+		l := make([]byte, 1)
+		_, err = io.ReadFull(conn, l)
+		if err != nil {
+			return err
+		}
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("socks5: unknown address type: %d", b[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
+
+// https://tools.ietf.org/html/rfc1929
+func (d *Dialer) authenticate(conn net.Conn) error {
+	username, password := d.options.username, d.options.password
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("socks5: username or password too long")
+	}
+	req := make([]byte, 0, 3+len(username)+len(password))
+	req = append(req, userPassVersion, uint8(len(username)))
+	req = append(req, username...)
+	req = append(req, uint8(len(password)))
+	req = append(req, password...)
+	_, err := conn.Write(req)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 2)
+	_, err = io.ReadFull(conn, b)
+	if err != nil {
+		return err
+	}
+	if b[1] != 0 {
+		return errors.New("socks5: authentication failed")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package corebgp
+
+import (
+	"errors"
+	"net"
+)
+
+// ListenerSourceFilter returns a ServerOption that drops connections to the
+// listeners passed to Serve from sources other than the addresses of
+// configured peers and the prefixes allowed, e.g. those from which dynamic
+// peers are accepted via AcceptDynamicPeers or matched by a ConnMatcher.
+//
+// On Linux the filter is attached to the listeners' sockets as a classic BPF
+// program (SO_ATTACH_FILTER), so that the TCP handshake of connections from
+// other sources never completes and no resources are spent on them. It is
+// updated as peers are added and deleted. Elsewhere, for listeners not
+// exposing their socket, or if the program exceeds the kernel's limit of
+// instructions (allowing about 2000 IPv4 or 450 IPv6 addresses), connections
+// from other sources are closed as soon as they are accepted, before being
+// matched to a peer or reading any message.
+func ListenerSourceFilter(allowed ...*net.IPNet) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) {
+		o.sourceFilter = true
+		o.allowedSources = append(o.allowedSources, allowed...)
+	})
+}
+
+// sourceFilter is the set of sources allowed by ListenerSourceFilter.
+type sourceFilter struct {
+	// addrs contains the 16-byte form of the addresses of configured peers
+	addrs    map[[net.IPv6len]byte]struct{}
+	prefixes []*net.IPNet
+}
+
+// allows returns true if connections from ip are allowed.
+func (f *sourceFilter) allows(ip net.IP) bool {
+	var k [net.IPv6len]byte
+	copy(k[:], ip.To16())
+	if _, ok := f.addrs[k]; ok {
+		return true
+	}
+	for _, p := range f.prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sources returns the allowed sources as prefixes, IPv4 sources in their
+// 4-byte form.
+func (f *sourceFilter) sources() []net.IPNet {
+	sources := make([]net.IPNet, 0, len(f.addrs)+len(f.prefixes))
+	for k := range f.addrs {
+		ip := normalizeIP(append(net.IP(nil), k[:]...))
+		bits := len(ip) * 8
+		sources = append(sources, net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		})
+	}
+	for _, p := range f.prefixes {
+		ones, bits := p.Mask.Size()
+		ip := p.IP.To4()
+		if ip != nil && bits == 8*net.IPv6len {
+			// an IPv4-mapped prefix, e.g. ::ffff:192.0.2.0/120
+			ones, bits = ones-8*(net.IPv6len-net.IPv4len), 8*net.IPv4len
+		} else if ip == nil {
+			ip = p.IP.To16()
+		}
+		if bits != 8*len(ip) || ones < 0 {
+			continue
+		}
+		mask := net.CIDRMask(ones, bits)
+		sources = append(sources, net.IPNet{IP: ip.Mask(mask), Mask: mask})
+	}
+	return sources
+}
+
+// errSourceFilterUnsupported is returned by attachSourceFilter on platforms
+// without socket filters, where the userland filter applies only.
+var errSourceFilterUnsupported = errors.New(
+	"listener source filter is only supported on linux")
+
+// updateSourceFilter rebuilds the source filter of s from its configured
+// peers, attaching it to its listeners. s.mu must be held.
+func (s *Server) updateSourceFilter() {
+	if !s.options.sourceFilter {
+		return
+	}
+	f := &sourceFilter{
+		addrs:    make(map[[net.IPv6len]byte]struct{}),
+		prefixes: s.options.allowedSources,
+	}
+	for _, p := range s.peers {
+		if p.dynamic {
+			continue
+		}
+		var k [net.IPv6len]byte
+		copy(k[:], p.config.IP.To16())
+		f.addrs[k] = struct{}{}
+	}
+	s.sourceFilter.Store(f)
+	for _, lis := range s.listeners {
+		err := rawControl(lis, func(fd uintptr) error {
+			return attachSourceFilter(fd, f)
+		})
+		if err != nil && err != errSourceFilterUnsupported {
+			logf("error attaching source filter to listener %s: %v",
+				lis.Addr(), err)
+		}
+	}
+}
+
+// acceptsSource returns false if conn is from a source not allowed by
+// ListenerSourceFilter.
+func (s *Server) acceptsSource(conn net.Conn) bool {
+	f, ok := s.sourceFilter.Load().(*sourceFilter)
+	if !ok {
+		return true
+	}
+	a, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	return f.allows(a.IP)
+}
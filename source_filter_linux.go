@@ -0,0 +1,139 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+const (
+	// bpfMaxInstructions is BPF_MAXINSNS of linux/bpf_common.h.
+	bpfMaxInstructions = 4096
+	// skfNetOff is SKF_NET_OFF of linux/filter.h, the offset of the network
+	// header for loads of socket filters, which otherwise load relative to
+	// the TCP header.
+	skfNetOff = -0x100000
+)
+
+// attachSourceFilter attaches f to listener socket fd as a classic BPF
+// program, detaching any previous one if the program would be too large.
+func attachSourceFilter(fd uintptr, f *sourceFilter) error {
+	prog := sourceFilterProgram(f.sources())
+	if len(prog) > bpfMaxInstructions {
+		syscall.DetachLsf(int(fd))
+		return fmt.Errorf("filter of %d instructions exceeds limit of %d, "+
+			"filtering accepted connections only", len(prog),
+			bpfMaxInstructions)
+	}
+	return syscall.AttachLsf(int(fd), prog)
+}
+
+// sourceFilterProgram returns a classic BPF program accepting IPv4 and IPv6
+// packets from sources only. Each source returns on a match so that jumps
+// stay within the 8-bit offsets of conditional jumps.
+func sourceFilterProgram(sources []net.IPNet) []syscall.SockFilter {
+	const (
+		accept = 0xffffffff
+		drop   = 0
+	)
+	// netOff returns the offset off of the network header
+	netOff := func(off int) uint32 {
+		return uint32(skfNetOff + off)
+	}
+	ld := func(off int) syscall.SockFilter {
+		return syscall.SockFilter{
+			Code: syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS,
+			K:    netOff(off),
+		}
+	}
+	and := func(k uint32) syscall.SockFilter {
+		return syscall.SockFilter{
+			Code: syscall.BPF_ALU | syscall.BPF_AND | syscall.BPF_K,
+			K:    k,
+		}
+	}
+	jeq := func(k uint32, jt, jf uint8) syscall.SockFilter {
+		return syscall.SockFilter{
+			Code: syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K,
+			Jt:   jt,
+			Jf:   jf,
+			K:    k,
+		}
+	}
+	ret := func(k uint32) syscall.SockFilter {
+		return syscall.SockFilter{
+			Code: syscall.BPF_RET | syscall.BPF_K,
+			K:    k,
+		}
+	}
+
+	// IPv4 sources compare the source address at offset 12 of the IPv4
+	// header, IPv6 sources the 4 words of the source address at offset 8 of
+	// the IPv6 header. Loads are shared by consecutive IPv4 addresses.
+	var v4, v6 []syscall.SockFilter
+	loaded := false
+	for _, s := range sources {
+		if len(s.IP) == net.IPv4len {
+			mask := binary.BigEndian.Uint32(s.Mask)
+			if !loaded || mask != 0xffffffff {
+				v4 = append(v4, ld(12))
+			}
+			loaded = mask == 0xffffffff
+			if !loaded {
+				v4 = append(v4, and(mask))
+			}
+			v4 = append(v4,
+				jeq(binary.BigEndian.Uint32(s.IP), 0, 1),
+				ret(accept),
+			)
+			continue
+		}
+		// words of the mask that are zero always match
+		var words []syscall.SockFilter
+		for i := 0; i < 4; i++ {
+			mask := binary.BigEndian.Uint32(s.Mask[i*4:])
+			if mask == 0 {
+				continue
+			}
+			words = append(words, ld(8+i*4))
+			if mask != 0xffffffff {
+				words = append(words, and(mask))
+			}
+			// the jump offset is set below
+			words = append(words, jeq(binary.BigEndian.Uint32(s.IP[i*4:]),
+				0, 0))
+		}
+		// a mismatch skips the remaining words and the return
+		for i := range words {
+			if words[i].Code == syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K {
+				words[i].Jf = uint8(len(words) - i)
+			}
+		}
+		v6 = append(v6, words...)
+		v6 = append(v6, ret(accept))
+	}
+	v4 = append(v4, ret(drop))
+
+	prog := []syscall.SockFilter{
+		// A = IP version
+		{
+			Code: syscall.BPF_LD | syscall.BPF_B | syscall.BPF_ABS,
+			K:    netOff(0),
+		},
+		{
+			Code: syscall.BPF_ALU | syscall.BPF_RSH | syscall.BPF_K,
+			K:    4,
+		},
+		jeq(4, 1, 0),
+		// jump over the IPv4 sources, which may exceed a conditional jump
+		{
+			Code: syscall.BPF_JMP | syscall.BPF_JA,
+			K:    uint32(len(v4)),
+		},
+	}
+	prog = append(prog, v4...)
+	prog = append(prog, jeq(6, 1, 0), ret(drop))
+	prog = append(prog, v6...)
+	return append(prog, ret(drop))
+}
@@ -0,0 +1,130 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// runSourceFilter interprets the classic BPF instructions generated by
+// sourceFilterProgram for the network header packet, returning the number of
+// bytes to accept. Out of bounds loads drop the packet, as in the kernel.
+func runSourceFilter(t *testing.T, prog []syscall.SockFilter,
+	packet []byte) uint32 {
+	t.Helper()
+	var a uint32
+	for pc := 0; pc < len(prog); pc++ {
+		ins := prog[pc]
+		off := int(int32(ins.K)) - skfNetOff
+		switch ins.Code {
+		case syscall.BPF_LD | syscall.BPF_B | syscall.BPF_ABS:
+			if off < 0 || off+1 > len(packet) {
+				return 0
+			}
+			a = uint32(packet[off])
+		case syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS:
+			if off < 0 || off+4 > len(packet) {
+				return 0
+			}
+			a = binary.BigEndian.Uint32(packet[off:])
+		case syscall.BPF_ALU | syscall.BPF_AND | syscall.BPF_K:
+			a &= ins.K
+		case syscall.BPF_ALU | syscall.BPF_RSH | syscall.BPF_K:
+			a >>= ins.K
+		case syscall.BPF_JMP | syscall.BPF_JA:
+			pc += int(ins.K)
+		case syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K:
+			if a == ins.K {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case syscall.BPF_RET | syscall.BPF_K:
+			return ins.K
+		default:
+			t.Fatalf("unexpected instruction %#x at %d", ins.Code, pc)
+		}
+	}
+	t.Fatal("program did not return")
+	return 0
+}
+
+// testIPHeader returns an IPv4 header with source address src, or an IPv6
+// header if src is in IPv6 notation, e.g. "::ffff:192.0.2.1".
+func testIPHeader(src string) []byte {
+	ip := net.ParseIP(src)
+	if !strings.Contains(src, ":") {
+		b := make([]byte, 20)
+		b[0] = 4<<4 | 5
+		copy(b[12:], ip.To4())
+		return b
+	}
+	b := make([]byte, 40)
+	b[0] = 6 << 4
+	copy(b[8:], ip)
+	return b
+}
+
+func TestSourceFilterProgram(t *testing.T) {
+	mustParsePrefix := func(s string) *net.IPNet {
+		_, p, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	f := &sourceFilter{addrs: make(map[[net.IPv6len]byte]struct{})}
+	for _, s := range []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"} {
+		var k [net.IPv6len]byte
+		copy(k[:], net.ParseIP(s).To16())
+		f.addrs[k] = struct{}{}
+	}
+	f.prefixes = []*net.IPNet{
+		mustParsePrefix("198.51.100.0/24"),
+		mustParsePrefix("2001:db8:1::/48"),
+		mustParsePrefix("::ffff:203.0.113.0/120"),
+	}
+	// many addresses exceed the offsets of conditional jumps
+	for i := 0; i < 300; i++ {
+		var k [net.IPv6len]byte
+		copy(k[:], net.IPv4(10, 0, byte(i>>8), byte(i)).To16())
+		f.addrs[k] = struct{}{}
+	}
+	prog := sourceFilterProgram(f.sources())
+
+	cases := []struct {
+		src    string
+		accept bool
+	}{
+		{"192.0.2.1", true},
+		{"192.0.2.2", true},
+		{"192.0.2.3", false},
+		{"198.51.100.77", true},
+		{"198.51.101.1", false},
+		{"203.0.113.9", true},
+		{"10.0.1.43", true},
+		{"10.0.2.0", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+		{"2001:db8:1:ff::5", true},
+		{"2001:db8:2::", false},
+		{"::ffff:192.0.2.1", false},
+	}
+	for _, c := range cases {
+		got := runSourceFilter(t, prog, testIPHeader(c.src)) != 0
+		if got != c.accept {
+			t.Errorf("source %s accepted = %v, want %v", c.src, got,
+				c.accept)
+		}
+	}
+
+	// packets other than IPv4 and IPv6, and truncated ones, are dropped
+	if runSourceFilter(t, prog, []byte{5 << 4}) != 0 {
+		t.Error("packet of unknown IP version accepted")
+	}
+	if runSourceFilter(t, prog, testIPHeader("192.0.2.1")[:14]) != 0 {
+		t.Error("truncated IPv4 header accepted")
+	}
+}
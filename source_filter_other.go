@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+func attachSourceFilter(fd uintptr, f *sourceFilter) error {
+	return errSourceFilterUnsupported
+}
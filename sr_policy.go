@@ -0,0 +1,88 @@
+package corebgp
+
+import (
+	"net"
+)
+
+// SRPolicyKey identifies an SR Policy by color and endpoint (RFC9256). A nil
+// or unspecified Endpoint is a null endpoint.
+type SRPolicyKey struct {
+	Color    uint32
+	Endpoint net.IP
+}
+
+func (k SRPolicyKey) nullEndpoint() bool {
+	return k.Endpoint == nil || k.Endpoint.IsUnspecified()
+}
+
+// sameFamily returns true if a and b are both IPv4 or both IPv6. A nil IP is
+// not of any family.
+func sameFamily(a, b net.IP) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return (a.To4() == nil) == (b.To4() == nil)
+}
+
+// MatchSRPolicy steers a route with the given next hop and extended
+// communities onto one of policies according to the Color extended
+// community and Color-Only bits, as described in RFC9256 section 8.
+//
+// If the route carries multiple Color extended communities, the one with the
+// highest color value for which a policy matches is used. ok is false if no
+// policy matches.
+func MatchSRPolicy(policies []SRPolicyKey, nextHop net.IP,
+	communities []ExtendedCommunity) (policy SRPolicyKey, ok bool) {
+	var (
+		found     bool
+		bestColor uint32
+		best      SRPolicyKey
+	)
+	for _, c := range communities {
+		color, co, err := DecodeColorExtCommunity(c)
+		if err != nil || (found && color <= bestColor) {
+			continue
+		}
+		p, ok := matchSRPolicyColor(policies, nextHop, color, co)
+		if ok {
+			found, bestColor, best = true, color, p
+		}
+	}
+	return best, found
+}
+
+func matchSRPolicyColor(policies []SRPolicyKey, nextHop net.IP, color uint32,
+	co ColorOnly) (SRPolicyKey, bool) {
+	// candidate match functions in order of preference
+	matchers := []func(p SRPolicyKey) bool{
+		func(p SRPolicyKey) bool {
+			return p.Endpoint.Equal(nextHop)
+		},
+	}
+	if co == ColorOnlyNullEndpoint || co == ColorOnlyAnyEndpoint {
+		matchers = append(matchers,
+			func(p SRPolicyKey) bool {
+				return p.nullEndpoint() && sameFamily(p.Endpoint, nextHop)
+			},
+			func(p SRPolicyKey) bool {
+				return p.nullEndpoint()
+			})
+	}
+	if co == ColorOnlyAnyEndpoint {
+		matchers = append(matchers,
+			func(p SRPolicyKey) bool {
+				return sameFamily(p.Endpoint, nextHop)
+			},
+			func(p SRPolicyKey) bool {
+				return true
+			})
+	}
+	for _, match := range matchers {
+		for _, p := range policies {
+			if p.Color == color && match(p) {
+				return p, true
+			}
+		}
+	}
+	return SRPolicyKey{}, false
+}
@@ -0,0 +1,414 @@
+package corebgp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// SessionInfo describes an established BGP session.
+type SessionInfo struct {
+	Peer net.IP
+	// Inbound is true if the session's connection was accepted from the peer.
+	Inbound    bool
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Interface is the name of the network interface with the local address
+	// of the session's connection, if any.
+	Interface string
+	// MSS is the maximum segment size of the session's TCP connection, or
+	// zero if unavailable, e.g. other than on Linux or for connections of a
+	// custom Dialer not exposing their socket. TCP MD5 signatures set via
+	// SocketControl cannot be read back from the socket, and are not
+	// reported.
+	MSS int
+	// RemoteID is the BGP Identifier of the peer.
+	RemoteID net.IP
+	// RemoteAS is the AS of the peer, as learned from its OPEN message for
+	// peers added with AnyRemoteAS or RemoteASRanges.
+	RemoteAS uint32
+	// HoldTime is the negotiated hold time.
+	HoldTime time.Duration
+	// KeepAliveInterval is the interval of KEEPALIVE messages sent to the
+	// peer, before any KeepAliveJitter, zero if the hold time is zero.
+	KeepAliveInterval time.Duration
+	// FourOctetAS is true if four-octet AS numbers were negotiated.
+	FourOctetAS bool
+	// Codec contains the negotiated parameters determining the encoding of
+	// UPDATE messages.
+	Codec *Codec
+	// Capabilities are the capabilities received from the peer.
+	Capabilities []*Capability
+	// SentOpen and ReceivedOpen are the OPEN messages sent to and received
+	// from the peer, see DiffCapabilities. They must not be modified.
+	SentOpen      *OpenMessage
+	ReceivedOpen  *OpenMessage
+	EstablishedAt time.Time
+}
+
+// WaitEstablished blocks until the peer with IP address ip is in the
+// Established state or ctx is done. It returns the SessionInfo of the
+// established session, or ctx.Err().
+func (s *Server) WaitEstablished(ctx context.Context,
+	ip net.IP) (*SessionInfo, error) {
+	return s.waitEstablished(ctx, PeerKey{IP: ip}, true)
+}
+
+// WaitEstablishedByKey is like WaitEstablished for the peer matching k.
+func (s *Server) WaitEstablishedByKey(ctx context.Context,
+	k PeerKey) (*SessionInfo, error) {
+	return s.waitEstablished(ctx, k, false)
+}
+
+func (s *Server) waitEstablished(ctx context.Context, k PeerKey,
+	anyVRF bool) (*SessionInfo, error) {
+	sub := s.Subscribe(16)
+	defer sub.Close()
+	for {
+		p, err := s.peerByKey(k, anyVRF)
+		if err != nil {
+			return nil, err
+		}
+		// the peer's session is set before the corresponding event is
+		// published, so it is re-checked after any event.
+		session := p.sessionInfo()
+		if session != nil {
+			return session, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-sub.C():
+		}
+	}
+}
+
+// PeerCounters are message counters for a peer, accumulated across sessions.
+type PeerCounters struct {
+	MessagesReceived       uint64
+	MessagesSent           uint64
+	UpdatesReceived        uint64
+	UpdatesSent            uint64
+	NotificationsReceived  uint64
+	NotificationsSent      uint64
+	EstablishedTransitions uint64
+	// UpdatesRateLimited is the number of UPDATE messages received exceeding
+	// the peer's InboundRateLimit.
+	UpdatesRateLimited uint64
+	// UpdatesDropped is the number of UPDATE messages dropped from the peer's
+	// InboundQueue.
+	UpdatesDropped uint64
+	// HoldTimerExtensions is the number of hold timer extensions granted via
+	// HoldTimerGrace.
+	HoldTimerExtensions uint64
+	// InvalidMarkers is the number of messages accepted despite an invalid
+	// header marker due to MarkerValidationPermissive.
+	InvalidMarkers uint64
+	// UpdateErrorsIgnored is the number of Notifications returned by the
+	// UpdateMessageHandler ignored due to UpdateErrorContinue.
+	UpdateErrorsIgnored uint64
+	// UpdatesTreatedAsWithdraw is the number of UPDATE messages treated as a
+	// withdrawal due to malformed path attributes (RFC7606) or
+	// UpdateErrorTreatAsWithdraw.
+	UpdatesTreatedAsWithdraw uint64
+	// OversizedMessages is the number of messages received exceeding the
+	// maximum length negotiated with the peer.
+	OversizedMessages uint64
+	// UpdatesFiltered is the number of UPDATE messages received dropped by
+	// the peer's InboundPolicy.
+	UpdatesFiltered uint64
+	// MemoryBudgetExceeded is the number of times a limit of the peer's
+	// MemoryBudget was enforced.
+	MemoryBudgetExceeded uint64
+	// UpdatesDeduplicated is the number of UPDATE messages written to the
+	// peer suppressed by DeduplicateUpdates.
+	UpdatesDeduplicated uint64
+}
+
+// peerCounters is updated atomically by a peer's FSMs.
+type peerCounters struct {
+	messagesIn               uint64
+	messagesOut              uint64
+	updatesIn                uint64
+	updatesOut               uint64
+	notificationsIn          uint64
+	notificationsOut         uint64
+	establishedTransitions   uint64
+	updatesRateLimited       uint64
+	updatesDropped           uint64
+	holdTimerExtensions      uint64
+	invalidMarkers           uint64
+	updateErrorsIgnored      uint64
+	updatesTreatedAsWithdraw uint64
+	oversizedMessages        uint64
+	updatesFiltered          uint64
+	memoryBudgetExceeded     uint64
+	updatesDeduplicated      uint64
+	// server aggregates counters across peers, it may be nil
+	server *serverMetrics
+}
+
+func (c *peerCounters) incoming(msgType uint8) {
+	atomic.AddUint64(&c.messagesIn, 1)
+	if c.server != nil {
+		atomic.AddUint64(&c.server.messagesIn[msgType], 1)
+	}
+	switch msgType {
+	case UpdateMessageType:
+		atomic.AddUint64(&c.updatesIn, 1)
+	case NotificationMessageType:
+		atomic.AddUint64(&c.notificationsIn, 1)
+	}
+}
+
+func (c *peerCounters) outgoing(msgType uint8) {
+	atomic.AddUint64(&c.messagesOut, 1)
+	if c.server != nil {
+		atomic.AddUint64(&c.server.messagesOut[msgType], 1)
+	}
+	switch msgType {
+	case UpdateMessageType:
+		atomic.AddUint64(&c.updatesOut, 1)
+	case NotificationMessageType:
+		atomic.AddUint64(&c.notificationsOut, 1)
+	}
+}
+
+func (c *peerCounters) decodeError() {
+	if c.server != nil {
+		atomic.AddUint64(&c.server.decodeErrors, 1)
+	}
+}
+
+func (c *peerCounters) connectAttempt() {
+	if c.server != nil {
+		atomic.AddUint64(&c.server.connectAttempts, 1)
+	}
+}
+
+func (c *peerCounters) snapshot() PeerCounters {
+	return PeerCounters{
+		MessagesReceived:       atomic.LoadUint64(&c.messagesIn),
+		MessagesSent:           atomic.LoadUint64(&c.messagesOut),
+		UpdatesReceived:        atomic.LoadUint64(&c.updatesIn),
+		UpdatesSent:            atomic.LoadUint64(&c.updatesOut),
+		NotificationsReceived:  atomic.LoadUint64(&c.notificationsIn),
+		NotificationsSent:      atomic.LoadUint64(&c.notificationsOut),
+		EstablishedTransitions: atomic.LoadUint64(&c.establishedTransitions),
+		UpdatesRateLimited:     atomic.LoadUint64(&c.updatesRateLimited),
+		UpdatesDropped:         atomic.LoadUint64(&c.updatesDropped),
+		HoldTimerExtensions:    atomic.LoadUint64(&c.holdTimerExtensions),
+		InvalidMarkers:         atomic.LoadUint64(&c.invalidMarkers),
+		UpdateErrorsIgnored:    atomic.LoadUint64(&c.updateErrorsIgnored),
+		UpdatesTreatedAsWithdraw: atomic.LoadUint64(
+			&c.updatesTreatedAsWithdraw),
+		OversizedMessages: atomic.LoadUint64(&c.oversizedMessages),
+		UpdatesFiltered:   atomic.LoadUint64(&c.updatesFiltered),
+
+		MemoryBudgetExceeded: atomic.LoadUint64(&c.memoryBudgetExceeded),
+		UpdatesDeduplicated:  atomic.LoadUint64(&c.updatesDeduplicated),
+	}
+}
+
+// PeerOptionsSummary summarizes the PeerOptions a peer was added with.
+type PeerOptionsSummary struct {
+	HoldTime     time.Duration
+	IdleHoldTime time.Duration
+	Passive      bool
+	ActiveOnly   bool
+	LocalAddress net.IP
+	Port         int
+	AnyRemoteAS  bool
+	DialTimeout  time.Duration
+	FwMark       uint32
+	TrafficClass uint8
+	FlowLabel    uint32
+	// DualStackAddress is the alternate address set via DualStack, if any.
+	DualStackAddress  net.IP
+	DynamicCapability bool
+	// ASLoopCheck is true if AllowASIn was set, in which case AllowASIn is its
+	// count.
+	ASLoopCheck     bool
+	AllowASIn       int
+	ASOverride      bool
+	UpdateRateAlarm int
+	// InboundRateLimit is non-nil if InboundRateLimit was set.
+	InboundRateLimit *RateLimit
+	// InboundQueueCapacity is non-zero if InboundQueue was set, in which case
+	// InboundQueuePolicy is its policy.
+	InboundQueueCapacity int
+	InboundQueuePolicy   QueueOverflowPolicy
+	KeepAliveJitter      float64
+	SuppressKeepAlives   bool
+	MarkerValidation     MarkerValidation
+	// MaxExtendedMessageLength is the maximum length of messages accepted
+	// once the Extended Message capability is negotiated.
+	MaxExtendedMessageLength int
+	LintUpdates              bool
+	// RemoteASRanges are the ranges set via RemoteASRanges and RemoteASSet.
+	RemoteASRanges []ASRange
+	VRF            string
+	MemoryBudget   PeerMemoryBudget
+	// ReadBufferSize is the per-connection buffer size of the ReadBatcher set
+	// via SharedReadBatcher, if any.
+	ReadBufferSize int
+}
+
+func (o *peerOptions) summary() PeerOptionsSummary {
+	var readBufferSize int
+	if o.readBatcher != nil {
+		readBufferSize = o.readBatcher.bufferSize
+	}
+	return PeerOptionsSummary{
+		HoldTime:                 o.holdTime,
+		IdleHoldTime:             o.idleHoldTime,
+		Passive:                  o.passive,
+		ActiveOnly:               o.activeOnly,
+		LocalAddress:             o.localAddress,
+		Port:                     o.port,
+		AnyRemoteAS:              o.anyRemoteAS,
+		FwMark:                   o.fwMark,
+		TrafficClass:             o.trafficClass,
+		FlowLabel:                o.flowLabel,
+		DialTimeout:              o.dialTimeout,
+		DualStackAddress:         o.dualStackAddress,
+		DynamicCapability:        o.dynamicCapability,
+		ASLoopCheck:              o.asLoopCheck,
+		AllowASIn:                o.allowASIn,
+		ASOverride:               o.asOverride,
+		UpdateRateAlarm:          o.updateRateAlarm,
+		InboundRateLimit:         o.inboundRateLimit,
+		InboundQueueCapacity:     o.inboundQueueCapacity,
+		InboundQueuePolicy:       o.inboundQueuePolicy,
+		KeepAliveJitter:          o.keepAliveJitter,
+		SuppressKeepAlives:       o.suppressKeepAlives,
+		MarkerValidation:         o.markerValidation,
+		MaxExtendedMessageLength: o.maxMessageLength,
+		LintUpdates:              o.lintUpdates,
+
+		RemoteASRanges: o.remoteASRanges,
+		VRF:            o.vrf,
+		MemoryBudget:   o.memoryBudget,
+		ReadBufferSize: readBufferSize,
+	}
+}
+
+// PeerStatus is the status of a peer returned by Server.ListPeers().
+type PeerStatus struct {
+	Config  PeerConfig
+	Options PeerOptionsSummary
+	// State is the most advanced state of the peer's FSMs.
+	State FSMState
+	// AdminDisabled is true if the peer was disabled via Server.DisablePeer.
+	AdminDisabled bool
+	// Dynamic is true if the peer was added via Server.AcceptDynamicPeers.
+	Dynamic bool
+	// Uptime is the duration the peer has been in the Established state, or
+	// zero if it is not established.
+	Uptime time.Duration
+	// Session is non-nil if the peer is in the Established state.
+	Session  *SessionInfo
+	Counters PeerCounters
+	// DroppedCapabilities are the optional capabilities no longer advertised
+	// to the peer as it reported them unsupported. See OptionalCapabilities.
+	DroppedCapabilities []*Capability
+	// SessionHistory contains the most recently established sessions, oldest
+	// first. See HistorySize.
+	SessionHistory []SessionRecord
+	// LastError is the latest error encountered by the peer's FSMs, if any.
+	LastError *PeerError
+	// Dial describes the outbound connection attempts to the peer.
+	Dial DialStatus
+	// EstablishmentAttempts contains the timelines of the most recently ended
+	// attempts to establish a session, oldest first. See HistorySize.
+	EstablishmentAttempts []EstablishmentTimeline
+	// Timers contains the effective timer values of the peer.
+	Timers TimerStatus
+	// Memory is the bytes of messages buffered for the peer. See
+	// MemoryBudget.
+	Memory MemoryUsage
+	// KeepAlive describes the KEEPALIVE messages received and the RTT of the
+	// peer's latest established session.
+	KeepAlive KeepAliveStats
+	// TCP is the latest TCPStats of the established session, nil unless
+	// TCPStatsInterval was set and the statistics are available.
+	TCP *TCPStats
+}
+
+func (p *peer) status() PeerStatus {
+	p.statusMu.Lock()
+	state := p.state[out]
+	if p.state[in] > state {
+		state = p.state[in]
+	}
+	session := p.session
+	disabled := p.disabled
+	droppedCaps := p.droppedCaps
+	history := p.sessionHistory()
+	lastError := p.lastError
+	dial := p.dial
+	attempts := p.establishmentAttempts()
+	timers := p.timerStatus()
+	tcpStats := p.tcpStats
+	p.statusMu.Unlock()
+	s := PeerStatus{
+		Config:        *p.config,
+		Options:       p.options.summary(),
+		State:         state,
+		AdminDisabled: disabled,
+		Dynamic:       p.dynamic,
+		Session:       session,
+		Counters:      p.counters.snapshot(),
+
+		DroppedCapabilities: droppedCaps,
+		SessionHistory:      history,
+		LastError:           lastError,
+		Dial:                dial,
+
+		EstablishmentAttempts: attempts,
+		Timers:                timers,
+		Memory:                p.memory.snapshot(),
+		KeepAlive:             p.keepAlive.snapshot(),
+		TCP:                   tcpStats,
+	}
+	if session != nil {
+		s.Uptime = time.Since(session.EstablishedAt)
+	}
+	return s
+}
+
+// GetPeer returns the status of the peer with IP address ip.
+func (s *Server) GetPeer(ip net.IP) (PeerStatus, error) {
+	p, err := s.peer(ip)
+	if err != nil {
+		return PeerStatus{}, err
+	}
+	return p.status(), nil
+}
+
+// ListPeers returns the status of all peers, ordered by IP address and
+// PeerKey.
+func (s *Server) ListPeers() []PeerStatus {
+	s.mu.Lock()
+	peers := make([]*peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.mu.Unlock()
+	statuses := make([]PeerStatus, 0, len(peers))
+	for _, p := range peers {
+		statuses = append(statuses, p.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		c := bytes.Compare(statuses[i].Config.IP.To16(),
+			statuses[j].Config.IP.To16())
+		if c == 0 {
+			return statuses[i].Key().String() < statuses[j].Key().String()
+		}
+		return c < 0
+	})
+	return statuses
+}
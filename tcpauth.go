@@ -0,0 +1,450 @@
+package corebgp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// TCPAuthKey is a key authenticating the TCP segments of connections to a
+// peer, see TCPAuth.
+type TCPAuthKey struct {
+	// Secret is the secret shared with the peer, of up to 80 bytes.
+	Secret []byte
+	// Algorithm is the MAC algorithm of a TCP-AO (RFC5925) key as named by
+	// the Linux crypto API, e.g. "hmac(sha1)" or "cmac(aes128)". If empty,
+	// the key is a TCP MD5 signature (RFC2385) password.
+	Algorithm string
+	// SendID and RecvID are the KeyIDs of a TCP-AO key, sent in segments to
+	// the peer and expected in segments from the peer respectively.
+	SendID uint8
+	RecvID uint8
+}
+
+// tcpAuthMaxKeyLen is TCP_MD5SIG_MAXKEYLEN and TCP_AO_MAXKEYLEN of
+// linux/tcp.h.
+const tcpAuthMaxKeyLen = 80
+
+func (k TCPAuthKey) ao() bool {
+	return k.Algorithm != ""
+}
+
+func (k TCPAuthKey) equal(o TCPAuthKey) bool {
+	return bytes.Equal(k.Secret, o.Secret) && k.Algorithm == o.Algorithm &&
+		k.SendID == o.SendID && k.RecvID == o.RecvID
+}
+
+func (k TCPAuthKey) validate() error {
+	if len(k.Secret) == 0 || len(k.Secret) > tcpAuthMaxKeyLen {
+		return fmt.Errorf("tcp auth secret must be 1 to %d bytes",
+			tcpAuthMaxKeyLen)
+	}
+	return nil
+}
+
+// TCPAuth returns a PeerOption that authenticates the TCP connections to a
+// peer with key, via TCP MD5 signatures or TCP-AO. The key is set on dialed
+// sockets before they connect, and for the peer's address on the listeners
+// passed to Server.Serve, so that accepted connections are authenticated
+// from their handshake. A listener accepting connections from several
+// listeners may expose them via a Listeners() []net.Listener method for the
+// key to be installed on each. Keys may be rotated on live sessions via
+// Server.RotateTCPAuthKey.
+//
+// TCPAuth requires Linux, adding the peer fails otherwise. TCP-AO requires
+// Linux 6.7 or later built with TCP-AO support. Keys are not set on sockets
+// dialed via a custom Dialer, or on listeners for dynamic peers.
+func TCPAuth(key TCPAuthKey) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpAuth = &tcpAuth{
+			keys:  []TCPAuthKey{key},
+			conns: make(map[net.Conn]struct{}),
+		}
+	})
+}
+
+// tcpAuth holds the keys of a peer and the connections of its established
+// sessions, on which keys are rotated.
+type tcpAuth struct {
+	mu sync.Mutex
+	// keys are the keys installed on the peer's sockets, the key sent to the
+	// peer first, followed by the new key while a TCP-AO rotation is in
+	// progress
+	keys  []TCPAuthKey
+	conns map[net.Conn]struct{}
+}
+
+// register registers conn of an established session for key rotation,
+// requesting the new key from the peer if a rotation is in progress.
+func (a *tcpAuth) register(conn net.Conn) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.conns[conn] = struct{}{}
+	if len(a.keys) > 1 {
+		next := a.keys[1]
+		err := rawControl(conn, func(fd uintptr) error {
+			return setAORNext(fd, next.RecvID)
+		})
+		if err != nil {
+			logf("[%s] error requesting tcp-ao key %d: %v",
+				conn.RemoteAddr(), next.RecvID, err)
+		}
+	}
+}
+
+func (a *tcpAuth) unregister(conn net.Conn) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	delete(a.conns, conn)
+	a.mu.Unlock()
+}
+
+// installKeys installs the keys for ip on the socket fd to be dialed.
+func (a *tcpAuth) installKeys(fd uintptr, ip net.IP) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, key := range a.keys {
+		if err := installTCPAuthKey(fd, ip, key, i == 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installTCPAuthKey installs key for ip on socket fd, as the key sent if
+// current is true and the socket is not listening. A TCP MD5 key replaces
+// any key installed.
+func installTCPAuthKey(fd uintptr, ip net.IP, key TCPAuthKey,
+	current bool) error {
+	if !key.ao() {
+		return setMD5Key(fd, ip, key.Secret)
+	}
+	return addAOKey(fd, ip, key, current)
+}
+
+// removeTCPAuthKey removes key for ip from socket fd.
+func removeTCPAuthKey(fd uintptr, ip net.IP, key TCPAuthKey) error {
+	if !key.ao() {
+		return setMD5Key(fd, ip, nil)
+	}
+	return delAOKey(fd, ip, key)
+}
+
+// rawControl calls fn with the socket of c, a connection or listener.
+func rawControl(c interface{}, fn func(fd uintptr) error) error {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return errors.New("connection does not expose its socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	err = raw.Control(func(fd uintptr) {
+		ferr = fn(fd)
+	})
+	if err != nil {
+		return err
+	}
+	return ferr
+}
+
+// installListenerKeys installs the keys of p on lis.
+func (p *peer) installListenerKeys(lis net.Listener) error {
+	a := p.options.tcpAuth
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return rawControl(lis, func(fd uintptr) error {
+		for _, key := range a.keys {
+			err := installTCPAuthKey(fd, p.config.IP, key, false)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeListenerKeys removes the keys of p from lis.
+func (p *peer) removeListenerKeys(lis net.Listener) error {
+	a := p.options.tcpAuth
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return rawControl(lis, func(fd uintptr) error {
+		for _, key := range a.keys {
+			err := removeTCPAuthKey(fd, p.config.IP, key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// multiListener is implemented by listeners accepting connections from
+// several listeners, e.g. of several addresses, exposing them so that keys
+// are installed on each.
+type multiListener interface {
+	Listeners() []net.Listener
+}
+
+// socketListeners returns the listeners of lis owning a socket.
+func socketListeners(lis net.Listener) []net.Listener {
+	if m, ok := lis.(multiListener); ok {
+		var listeners []net.Listener
+		for _, l := range m.Listeners() {
+			listeners = append(listeners, socketListeners(l)...)
+		}
+		return listeners
+	}
+	return []net.Listener{lis}
+}
+
+// addListener installs the keys of peers and the source filter on lis, a
+// listener passed to Serve, s.mu must be held.
+func (s *Server) addListener(lis net.Listener) {
+	for _, l := range socketListeners(lis) {
+		s.listeners = append(s.listeners, l)
+		for _, p := range s.peers {
+			if p.options.tcpAuth == nil || p.dynamic {
+				continue
+			}
+			if err := p.installListenerKeys(l); err != nil {
+				logf("[%s] error installing tcp auth key on listener: %v",
+					p.config.IP, err)
+			}
+		}
+	}
+	s.updateSourceFilter()
+}
+
+// removeListener removes lis once Serve returns, s.mu must be held.
+func (s *Server) removeListener(lis net.Listener) {
+	remove := socketListeners(lis)
+	listeners := s.listeners[:0]
+	for _, l := range s.listeners {
+		removed := false
+		for _, r := range remove {
+			removed = removed || l == r
+		}
+		if !removed {
+			listeners = append(listeners, l)
+		}
+	}
+	s.listeners = listeners
+}
+
+// aoRotationPollInterval is the interval at which RotateTCPAuthKey checks
+// whether sessions switched to a new TCP-AO key.
+var aoRotationPollInterval = time.Millisecond * 100
+
+// RotateTCPAuthKey replaces the key authenticating the connections to the
+// peer with IP address ip, set via TCPAuth, on its established session and
+// the listeners passed to Serve, without resetting the session. The peer must
+// be reconfigured with the new key as well. Both keys must be TCP MD5 keys
+// or TCP-AO keys.
+//
+// A socket holds a single TCP MD5 key per peer, so a TCP MD5 key is replaced
+// at once: segments of the session are then dropped by either side until
+// both use the new key, and retransmitted by TCP once they do. The peer must
+// be reconfigured within the hold time, and ideally within a TCP
+// retransmission timeout.
+//
+// A TCP-AO key is rotated as of RFC5925 section 7.5.2, without dropping
+// segments: the new key is installed alongside the current key and requested
+// from the peer via the RNextKeyID, and the session switches to sending with
+// the new key once the peer requests it in turn. RotateTCPAuthKey then removes
+// the old key. If ctx is done before the session switched, the ctx error is
+// returned with both keys installed, and RotateTCPAuthKey may be called again
+// with the same key to complete the rotation. The new key must have other
+// KeyIDs than the current key.
+//
+// Connections being established during a rotation may fail, and are retried.
+func (s *Server) RotateTCPAuthKey(ctx context.Context, ip net.IP,
+	key TCPAuthKey) error {
+	return s.rotateTCPAuthKey(ctx, PeerKey{IP: ip}, true, key)
+}
+
+// RotateTCPAuthKeyByKey rotates the TCP authentication key of the peer
+// matching k, see RotateTCPAuthKey.
+func (s *Server) RotateTCPAuthKeyByKey(ctx context.Context, k PeerKey,
+	key TCPAuthKey) error {
+	return s.rotateTCPAuthKey(ctx, k, false, key)
+}
+
+func (s *Server) rotateTCPAuthKey(ctx context.Context, k PeerKey,
+	anyVRF bool, key TCPAuthKey) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	p, err := s.lookupPeer(k, anyVRF)
+	listeners := append([]net.Listener(nil), s.listeners...)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	a := p.options.tcpAuth
+	if a == nil {
+		return errors.New("peer has no tcp auth key")
+	}
+	if !key.ao() {
+		return a.rotateMD5(p.config.IP, key, listeners)
+	}
+	if err := a.startAORotation(p.config.IP, key, listeners); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(aoRotationPollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := a.finishAORotation(p.config.IP, key, listeners)
+		if done || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// rotateMD5 replaces the TCP MD5 key of the peer with address ip with key.
+func (a *tcpAuth) rotateMD5(ip net.IP, key TCPAuthKey,
+	listeners []net.Listener) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys[0].ao() {
+		return errors.New("cannot rotate a tcp-ao key to a tcp md5 key")
+	}
+	a.keys = []TCPAuthKey{key}
+	install := func(fd uintptr) error {
+		return setMD5Key(fd, ip, key.Secret)
+	}
+	// listeners are updated first, so that connections accepted meanwhile
+	// use the new key
+	var errs []error
+	for _, lis := range listeners {
+		if err := rawControl(lis, install); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for conn := range a.conns {
+		if err := rawControl(conn, install); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error installing tcp md5 key: %v", errs[0])
+	}
+	return nil
+}
+
+// startAORotation installs the TCP-AO key of the peer with address ip
+// alongside its current key, requesting it from the peer.
+func (a *tcpAuth) startAORotation(ip net.IP, key TCPAuthKey,
+	listeners []net.Listener) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	current := a.keys[0]
+	switch {
+	case !current.ao():
+		return errors.New("cannot rotate a tcp md5 key to a tcp-ao key")
+	case current.equal(key):
+		return nil
+	case current.SendID == key.SendID || current.RecvID == key.RecvID:
+		return errors.New("tcp-ao key ids must differ from the current key")
+	case len(a.keys) > 1 && !a.keys[1].equal(key):
+		return errors.New("another tcp-ao key rotation is in progress")
+	}
+	a.keys = []TCPAuthKey{current, key}
+	var errs []error
+	for _, lis := range listeners {
+		err := rawControl(lis, func(fd uintptr) error {
+			return ignoreExists(addAOKey(fd, ip, key, false))
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for conn := range a.conns {
+		err := rawControl(conn, func(fd uintptr) error {
+			if err := ignoreExists(addAOKey(fd, ip, key, false)); err != nil {
+				return err
+			}
+			return setAORNext(fd, key.RecvID)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error installing tcp-ao key: %v", errs[0])
+	}
+	return nil
+}
+
+// finishAORotation removes the previous TCP-AO key of the peer with address
+// ip once its established session sends with key, returning true if it did.
+func (a *tcpAuth) finishAORotation(ip net.IP, key TCPAuthKey,
+	listeners []net.Listener) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.keys) == 1 {
+		return a.keys[0].equal(key), nil
+	}
+	for conn := range a.conns {
+		var current uint8
+		err := rawControl(conn, func(fd uintptr) error {
+			var err error
+			current, err = getAOCurrent(fd)
+			return err
+		})
+		if err != nil {
+			return false, fmt.Errorf("error getting tcp-ao key: %v", err)
+		}
+		if current != key.SendID {
+			return false, nil
+		}
+	}
+	old := a.keys[0]
+	a.keys = []TCPAuthKey{key}
+	remove := func(fd uintptr) error {
+		return delAOKey(fd, ip, old)
+	}
+	var errs []error
+	for _, lis := range listeners {
+		if err := rawControl(lis, remove); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for conn := range a.conns {
+		if err := rawControl(conn, remove); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return true, fmt.Errorf("error removing tcp-ao key: %v", errs[0])
+	}
+	return true, nil
+}
+
+// ignoreExists returns nil if err is EEXIST, as returned when adding a
+// TCP-AO key again on resuming a rotation.
+func ignoreExists(err error) error {
+	if err == syscall.EEXIST {
+		return nil
+	}
+	return err
+}
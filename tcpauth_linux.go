@@ -0,0 +1,208 @@
+package corebgp
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+const tcpAuthSupported = true
+
+// linux/tcp.h
+const (
+	tcpMD5Sig    = 14
+	tcpAOAddKey  = 38
+	tcpAODelKey  = 39
+	tcpAOInfo    = 40
+	soDomain     = 39
+	sockaddrSize = 128
+)
+
+// tcpMD5SigOpt is struct tcp_md5sig of linux/tcp.h.
+type tcpMD5SigOpt struct {
+	addr      [sockaddrSize]byte
+	flags     uint8
+	prefixLen uint8
+	keyLen    uint16
+	ifindex   int32
+	key       [tcpAuthMaxKeyLen]byte
+}
+
+// tcpAOAdd is struct tcp_ao_add of linux/tcp.h.
+type tcpAOAdd struct {
+	addr     [sockaddrSize]byte
+	algName  [64]byte
+	ifindex  int32
+	flags    uint32
+	_        uint16
+	prefix   uint8
+	sndID    uint8
+	rcvID    uint8
+	macLen   uint8
+	keyFlags uint8
+	keyLen   uint8
+	key      [tcpAuthMaxKeyLen]byte
+}
+
+// tcpAODel is struct tcp_ao_del of linux/tcp.h.
+type tcpAODel struct {
+	addr       [sockaddrSize]byte
+	ifindex    int32
+	flags      uint32
+	_          uint16
+	prefix     uint8
+	sndID      uint8
+	rcvID      uint8
+	currentKey uint8
+	rnext      uint8
+	keyFlags   uint8
+}
+
+// tcpAOInfoOpt is struct tcp_ao_info_opt of linux/tcp.h.
+type tcpAOInfoOpt struct {
+	flags          uint32
+	_              uint16
+	currentKey     uint8
+	rnext          uint8
+	pktGood        uint64
+	pktBad         uint64
+	pktKeyNotFound uint64
+	pktAORequired  uint64
+	pktDroppedICMP uint64
+}
+
+// aoFlags returns the set_current and set_rnext bitfields of the structs of
+// TCP-AO, which are allocated from the most significant bit on big-endian
+// platforms.
+func aoFlags(setCurrent, setRNext bool) uint32 {
+	var flags uint32
+	if setCurrent {
+		flags |= 1
+	}
+	if setRNext {
+		flags |= 2
+	}
+	if !nativeLittleEndian() {
+		flags = flags&1<<31 | flags&2<<29
+	}
+	return flags
+}
+
+func nativeLittleEndian() bool {
+	v := uint16(1)
+	return *(*byte)(unsafe.Pointer(&v)) == 1
+}
+
+// tcpAuthAddr encodes the address ip of a key for socket fd as a struct
+// sockaddr of the socket's address family, returning its prefix length.
+func tcpAuthAddr(fd uintptr, ip net.IP) ([sockaddrSize]byte, uint8, error) {
+	var b [sockaddrSize]byte
+	family, err := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET,
+		soDomain)
+	if err != nil {
+		return b, 0, err
+	}
+	*(*uint16)(unsafe.Pointer(&b[0])) = uint16(family)
+	switch family {
+	case syscall.AF_INET:
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return b, 0, errors.New("ipv6 address on ipv4 socket")
+		}
+		// struct sockaddr_in
+		copy(b[4:8], ip4)
+		return b, 32, nil
+	case syscall.AF_INET6:
+		// struct sockaddr_in6
+		copy(b[8:24], ip.To16())
+		if ip.To4() != nil {
+			return b, 32, nil
+		}
+		return b, 128, nil
+	}
+	return b, 0, errors.New("socket is not an ip socket")
+}
+
+func setsockoptBytes(fd uintptr, opt int, b []byte) error {
+	return syscall.SetsockoptString(int(fd), syscall.IPPROTO_TCP, opt,
+		string(b))
+}
+
+// setMD5Key sets the TCP MD5 key of ip on socket fd to secret, removing it if
+// secret is empty.
+func setMD5Key(fd uintptr, ip net.IP, secret []byte) error {
+	addr, _, err := tcpAuthAddr(fd, ip)
+	if err != nil {
+		return err
+	}
+	opt := tcpMD5SigOpt{
+		addr:   addr,
+		keyLen: uint16(len(secret)),
+	}
+	copy(opt.key[:], secret)
+	return setsockoptBytes(fd, tcpMD5Sig,
+		(*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:])
+}
+
+// addAOKey adds the TCP-AO key of ip to socket fd, as its current key and
+// RNextKeyID if current is true.
+func addAOKey(fd uintptr, ip net.IP, key TCPAuthKey, current bool) error {
+	addr, prefix, err := tcpAuthAddr(fd, ip)
+	if err != nil {
+		return err
+	}
+	if len(key.Algorithm) >= 64 {
+		return errors.New("tcp-ao algorithm name too long")
+	}
+	opt := tcpAOAdd{
+		addr:   addr,
+		flags:  aoFlags(current, current),
+		prefix: prefix,
+		sndID:  key.SendID,
+		rcvID:  key.RecvID,
+		keyLen: uint8(len(key.Secret)),
+	}
+	copy(opt.algName[:], key.Algorithm)
+	copy(opt.key[:], key.Secret)
+	return setsockoptBytes(fd, tcpAOAddKey,
+		(*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:])
+}
+
+// delAOKey removes the TCP-AO key of ip from socket fd.
+func delAOKey(fd uintptr, ip net.IP, key TCPAuthKey) error {
+	addr, prefix, err := tcpAuthAddr(fd, ip)
+	if err != nil {
+		return err
+	}
+	opt := tcpAODel{
+		addr:   addr,
+		prefix: prefix,
+		sndID:  key.SendID,
+		rcvID:  key.RecvID,
+	}
+	return setsockoptBytes(fd, tcpAODelKey,
+		(*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:])
+}
+
+// setAORNext sets the RNextKeyID of socket fd, requesting the key with
+// RecvID id from the peer.
+func setAORNext(fd uintptr, id uint8) error {
+	opt := tcpAOInfoOpt{
+		flags: aoFlags(false, true),
+		rnext: id,
+	}
+	return setsockoptBytes(fd, tcpAOInfo,
+		(*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:])
+}
+
+// getAOCurrent returns the SendID of the current TCP-AO key of socket fd.
+func getAOCurrent(fd uintptr) (uint8, error) {
+	var opt tcpAOInfoOpt
+	b := (*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:]
+	if _, err := rawGetsockopt(fd, syscall.IPPROTO_TCP, tcpAOInfo,
+		b); err != nil {
+		return 0, err
+	}
+	return opt.currentKey, nil
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+package corebgp
+
+import (
+	"errors"
+	"net"
+)
+
+const tcpAuthSupported = false
+
+var errTCPAuthUnsupported = errors.New("tcp auth is only supported on linux")
+
+func setMD5Key(fd uintptr, ip net.IP, secret []byte) error {
+	return errTCPAuthUnsupported
+}
+
+func addAOKey(fd uintptr, ip net.IP, key TCPAuthKey, current bool) error {
+	return errTCPAuthUnsupported
+}
+
+func delAOKey(fd uintptr, ip net.IP, key TCPAuthKey) error {
+	return errTCPAuthUnsupported
+}
+
+func setAORNext(fd uintptr, id uint8) error {
+	return errTCPAuthUnsupported
+}
+
+func getAOCurrent(fd uintptr) (uint8, error) {
+	return 0, errTCPAuthUnsupported
+}
@@ -0,0 +1,55 @@
+package corebgp
+
+import (
+	"time"
+)
+
+// TCPStats are statistics of the TCP connection of a peer's established
+// session as reported by the kernel, e.g. to tell whether a slow table
+// transfer is due to the peer or to the transport. They are only available on
+// Linux, and not for connections of a custom Dialer not exposing their socket.
+type TCPStats struct {
+	// Time is the time the statistics were sampled.
+	Time time.Time
+	// RTT is the smoothed round-trip time of the connection, RTTVar its mean
+	// deviation and RTO the retransmission timeout. MinRTT is the lowest RTT
+	// observed, zero if not reported by the kernel (before Linux 4.6).
+	RTT    time.Duration
+	RTTVar time.Duration
+	MinRTT time.Duration
+	RTO    time.Duration
+	// CongestionWindow and SlowStartThreshold are the congestion window and
+	// slow start threshold of the sender in segments of MSS bytes.
+	CongestionWindow   uint32
+	SlowStartThreshold uint32
+	MSS                uint32
+	// Unacked and Lost are the number of segments sent not yet acknowledged
+	// and considered lost.
+	Unacked uint32
+	Lost    uint32
+	// Retransmits is the total number of segments retransmitted.
+	Retransmits uint32
+	// SendQueue is the bytes written to the socket not yet acknowledged by
+	// the peer, and ReceiveQueue the bytes received not yet read, e.g. due to
+	// a slow UpdateMessageHandler.
+	SendQueue    int
+	ReceiveQueue int
+}
+
+// TCPStatsInterval returns a PeerOption that samples the TCPStats of the
+// peer's established session upon establishment and every d thereafter, as
+// reported by PeerStatus.TCP.
+func TCPStatsInterval(d time.Duration) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.tcpStatsInterval = d
+	})
+}
+
+// sampleTCPStats samples the TCPStats of the connection of f, which must be in
+// the Established state.
+func (f *fsm) sampleTCPStats() {
+	stats := connTCPStats(f.conn)
+	f.peer.statusMu.Lock()
+	f.peer.tcpStats = stats
+	f.peer.statusMu.Unlock()
+}
@@ -0,0 +1,132 @@
+package corebgp
+
+import (
+	"errors"
+	"time"
+)
+
+// EstablishmentTimeline describes an attempt of a peer's FSM to establish a
+// session, for diagnosing slow or failing session establishment. An attempt
+// starts when a connection is dialed or accepted, and ends when the session
+// is established or the attempt fails. Times of steps not reached are zero.
+type EstablishmentTimeline struct {
+	// Inbound is true if the FSM is handling a connection accepted from the
+	// peer.
+	Inbound bool
+	// Started is the time the connection was dialed, i.e. the TCP SYN sent,
+	// or accepted.
+	Started time.Time
+	// Connected is the time the TCP connection was established, equal to
+	// Started for accepted connections.
+	Connected    time.Time
+	OpenSent     time.Time
+	OpenReceived time.Time
+	Established  time.Time
+	// Err is the error the attempt failed with, nil if the session was
+	// established. An attempt abandoned as the FSM was stopped, e.g. due to
+	// a connection collision, fails with an Err of "fsm stopped".
+	Err *PeerError
+}
+
+// Ended returns the time the attempt ended.
+func (t *EstablishmentTimeline) Ended() time.Time {
+	if t.Err != nil {
+		return t.Err.Time
+	}
+	return t.Established
+}
+
+// Duration returns the duration of the attempt.
+func (t *EstablishmentTimeline) Duration() time.Duration {
+	return between(t.Started, t.Ended())
+}
+
+// ConnectDuration returns the duration of the TCP handshake, zero if the
+// connection was accepted or not established.
+func (t *EstablishmentTimeline) ConnectDuration() time.Duration {
+	return between(t.Started, t.Connected)
+}
+
+// OpenDuration returns the duration between sending an OPEN message and
+// receiving one from the peer, zero if either did not happen.
+func (t *EstablishmentTimeline) OpenDuration() time.Duration {
+	return between(t.OpenSent, t.OpenReceived)
+}
+
+// between returns the duration from start to end, zero if either is zero.
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+var errFSMStopped = errors.New("fsm stopped")
+
+// startAttempt starts the timeline of an establishment attempt, abandoning
+// the one in progress, if any.
+func (f *fsm) startAttempt() {
+	f.attempt = &EstablishmentTimeline{
+		Inbound: f.inbound,
+		Started: time.Now(),
+	}
+}
+
+// failAttempt ends the attempt in progress, if any, with err.
+func (f *fsm) failAttempt(err error) {
+	if f.attempt == nil {
+		return
+	}
+	i := out
+	if f.inbound {
+		i = in
+	}
+	f.attempt.Err = newPeerError(i, f.state, err)
+	f.peer.recordAttempt(f.attempt)
+	f.attempt = nil
+}
+
+// establishAttempt ends the attempt in progress, if any, as session was
+// established.
+func (f *fsm) establishAttempt(session *SessionInfo) {
+	if f.attempt == nil {
+		return
+	}
+	f.attempt.Established = session.EstablishedAt
+	f.peer.recordAttempt(f.attempt)
+	f.attempt = nil
+}
+
+// recordAttempt appends the ended attempt t to the peer's
+// EstablishmentAttempts and publishes an EstablishmentAttemptEvent.
+func (p *peer) recordAttempt(t *EstablishmentTimeline) {
+	p.statusMu.Lock()
+	if size := p.options.historySize; size > 0 {
+		if len(p.attempts) >= size {
+			p.attempts = append(p.attempts[:0:0],
+				p.attempts[len(p.attempts)-size+1:]...)
+		}
+		p.attempts = append(p.attempts, *t)
+	}
+	p.statusMu.Unlock()
+	p.events.publish(&EstablishmentAttemptEvent{
+		eventBase: newEventBase(p),
+		Timeline:  *t,
+	})
+}
+
+// establishmentAttempts returns a copy of the recorded attempts, p.statusMu
+// must be held.
+func (p *peer) establishmentAttempts() []EstablishmentTimeline {
+	if len(p.attempts) == 0 {
+		return nil
+	}
+	return append([]EstablishmentTimeline(nil), p.attempts...)
+}
+
+// connected records the TCP connection of the attempt in progress, if any.
+func (f *fsm) connected() {
+	if f.attempt != nil {
+		f.attempt.Connected = time.Now()
+	}
+}
@@ -0,0 +1,110 @@
+package corebgp
+
+import (
+	"time"
+)
+
+// Timer identifies a timer delaying connection attempts to a peer.
+type Timer uint8
+
+// Timer values
+const (
+	// TimerIdleHold is the idle hold timer, delaying the next connection
+	// attempt once an attempt or session failed. See IdleHoldTime.
+	TimerIdleHold Timer = iota + 1
+	// TimerDamping is the timer damping a peer following a protocol error,
+	// starting at one minute and doubling on consecutive errors up to five
+	// minutes.
+	TimerDamping
+)
+
+// String returns the name of the timer.
+func (t Timer) String() string {
+	switch t {
+	case TimerIdleHold:
+		return "idleHold"
+	case TimerDamping:
+		return "damping"
+	default:
+		return "unknown"
+	}
+}
+
+// TimerRescheduledEvent is published when a timer delaying the next
+// connection attempt to a peer is rescheduled due to an error. Failed
+// outbound connection attempts are reported by DialFailedEvent instead.
+type TimerRescheduledEvent struct {
+	eventBase
+	Timer Timer
+	Delay time.Duration
+	// Expiry is the time the timer expires, i.e. the earliest time of the
+	// next connection attempt.
+	Expiry time.Time
+	Err    error
+}
+
+// TimerStatus describes the effective timer values of a peer, after defaults
+// and negotiation.
+type TimerStatus struct {
+	// HoldTime is the hold time advertised to the peer.
+	HoldTime time.Duration
+	// NegotiatedHoldTime and KeepAliveInterval are the values of the
+	// established session, zero if the peer is not established.
+	NegotiatedHoldTime time.Duration
+	KeepAliveInterval  time.Duration
+	IdleHoldTime       time.Duration
+	// ConnectRetryTime is the interval of outbound connection attempts while
+	// the peer does not respond, extended to the DialTimeout.
+	ConnectRetryTime time.Duration
+	// DampingDelay is the delay of the latest damping of the peer due to a
+	// protocol error, zero if it was not damped. DampedUntil is the time the
+	// damping ends, zero if the peer is not damped.
+	DampingDelay time.Duration
+	DampedUntil  time.Time
+}
+
+// connectRetryTime returns the ConnectRetryTimer value, which is extended to
+// the dial timeout.
+func (o *peerOptions) connectRetryTime() time.Duration {
+	if o.dialTimeout > connectRetryTime {
+		return o.dialTimeout
+	}
+	return connectRetryTime
+}
+
+// timerRescheduled publishes a TimerRescheduledEvent for timer t rescheduled
+// to expire at expiry due to err.
+func (p *peer) timerRescheduled(t Timer, expiry time.Time, err error) {
+	now := time.Now()
+	delay := expiry.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	p.events.publish(&TimerRescheduledEvent{
+		eventBase: eventBase{
+			Time: now,
+			Peer: p.config.IP,
+			Key:  p.peerKey(),
+		},
+		Timer:  t,
+		Delay:  delay,
+		Expiry: expiry,
+		Err:    err,
+	})
+}
+
+// timerStatus returns the TimerStatus of the peer, p.statusMu must be held.
+func (p *peer) timerStatus() TimerStatus {
+	s := TimerStatus{
+		HoldTime:         p.options.holdTime,
+		IdleHoldTime:     p.options.idleHoldTime,
+		ConnectRetryTime: p.options.connectRetryTime(),
+		DampingDelay:     p.damping,
+		DampedUntil:      p.dampedUntil,
+	}
+	if p.session != nil {
+		s.NegotiatedHoldTime = p.session.HoldTime
+		s.KeepAliveInterval = p.session.KeepAliveInterval
+	}
+	return s
+}
@@ -0,0 +1,162 @@
+package corebgp
+
+import (
+	"bytes"
+)
+
+// OptionalCapabilities returns a PeerOption that designates the capabilities
+// with the provided codes as optional. Capabilities are otherwise required.
+//
+// https://tools.ietf.org/html/rfc5492#section-5
+// If a peer responds to an OPEN message with an OPEN Message Error
+// NOTIFICATION with the Unsupported Capability subcode, the optional
+// capabilities listed in its data are dropped from subsequent OPEN messages
+// and the peer is not damped, so the OPEN is promptly retried without them.
+// A peer responding with the Unsupported Optional Parameter subcode, i.e. one
+// not supporting capabilities advertisement, has all optional capabilities
+// dropped. Dropped capabilities are reported by a CapabilitiesDroppedEvent and
+// PeerStatus.DroppedCapabilities, and are advertised again once the peer is
+// reset via Server.ResetPeer.
+func OptionalCapabilities(codes ...uint8) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		if o.optionalCapabilities == nil {
+			o.optionalCapabilities = make(map[uint8]bool)
+		}
+		for _, c := range codes {
+			o.optionalCapabilities[c] = true
+		}
+	})
+}
+
+// RequiredCapabilities returns a PeerOption that requires the peer's OPEN
+// message to contain each of caps, e.g. a Multiprotocol Extensions capability
+// for IPv6 unicast. A capability with an empty value matches any capability
+// with its code. If any are missing the OPEN message is rejected with an OPEN
+// Message Error NOTIFICATION with the Unsupported Capability subcode listing
+// them, and the peer is damped, rather than establishing a session lacking
+// them.
+func RequiredCapabilities(caps ...*Capability) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.requiredCapabilities = append(o.requiredCapabilities, caps...)
+	})
+}
+
+// checkRequiredCapabilities returns an error wrapping an Unsupported
+// Capability Notification if any of required are missing from the message.
+func (o *OpenMessage) checkRequiredCapabilities(required []*Capability) error {
+	if len(required) == 0 {
+		return nil
+	}
+	caps := o.Capabilities()
+	var data []byte
+outer:
+	for _, r := range required {
+		for _, c := range caps {
+			if r.Code == c.Code &&
+				(len(r.Value) == 0 || bytes.Equal(r.Value, c.Value)) {
+				continue outer
+			}
+		}
+		data = append(data, r.Code, uint8(len(r.Value)))
+		data = append(data, r.Value...)
+	}
+	if data == nil {
+		return nil
+	}
+	n := newNotification(NotifCodeOpenMessageErr,
+		NotifSubcodeUnsupportedCapability, data)
+	return newNotificationError(n, true)
+}
+
+func capabilityEqual(a, b *Capability) bool {
+	return a.Code == b.Code && bytes.Equal(a.Value, b.Value)
+}
+
+// withoutDroppedCapabilities returns caps excluding those previously dropped
+// for the peer.
+func (p *peer) withoutDroppedCapabilities(caps []*Capability) []*Capability {
+	p.statusMu.Lock()
+	dropped := p.droppedCaps
+	p.statusMu.Unlock()
+	if len(dropped) == 0 {
+		return caps
+	}
+	filtered := make([]*Capability, 0, len(caps))
+outer:
+	for _, c := range caps {
+		for _, d := range dropped {
+			if capabilityEqual(c, d) {
+				continue outer
+			}
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
+// dropUnsupportedCapabilities handles a NOTIFICATION received from the peer in
+// response to the OPEN message sent by FSM i, returning true if optional
+// capabilities were dropped as a result.
+func (p *peer) dropUnsupportedCapabilities(i int, n *Notification) bool {
+	optional := p.options.optionalCapabilities
+	if len(optional) == 0 || n.Code != NotifCodeOpenMessageErr {
+		return false
+	}
+	var unsupported []*Capability
+	switch n.Subcode {
+	case NotifSubcodeUnsupportedCapability:
+		var err error
+		unsupported, err = n.UnsupportedCapabilities()
+		if err != nil {
+			return false
+		}
+	case NotifSubcodeUnsupportedOptionalParam:
+	default:
+		return false
+	}
+	f := p.fsms[i]
+	if f == nil {
+		return false
+	}
+	var drop []*Capability
+	for _, c := range f.sentCapabilities {
+		if !optional[c.Code] {
+			continue
+		}
+		if n.Subcode == NotifSubcodeUnsupportedOptionalParam {
+			drop = append(drop, c)
+			continue
+		}
+		for _, u := range unsupported {
+			// the peer may omit the capability value
+			if u.Code == c.Code &&
+				(len(u.Value) == 0 || bytes.Equal(u.Value, c.Value)) {
+				drop = append(drop, c)
+				break
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return false
+	}
+	p.statusMu.Lock()
+	p.droppedCaps = append(p.droppedCaps[:len(p.droppedCaps):len(p.droppedCaps)],
+		drop...)
+	p.statusMu.Unlock()
+	for _, c := range drop {
+		logf("[%s] dropping unsupported optional capability %d",
+			p.config.IP, c.Code)
+	}
+	p.events.publish(&CapabilitiesDroppedEvent{
+		eventBase:    newEventBase(p),
+		Capabilities: drop,
+	})
+	return true
+}
+
+// clearDroppedCapabilities resumes advertising dropped capabilities.
+func (p *peer) clearDroppedCapabilities() {
+	p.statusMu.Lock()
+	p.droppedCaps = nil
+	p.statusMu.Unlock()
+}
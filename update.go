@@ -0,0 +1,261 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// splitUpdate splits an UPDATE message body into its withdrawn routes, path
+// attributes and NLRI fields. The returned slices reference b.
+// https://tools.ietf.org/html/rfc4271#section-4.3
+func splitUpdate(b []byte) (withdrawn, attrs, nlri []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, nil, errors.New("update message too short")
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 4+withdrawnLen {
+		return nil, nil, nil, errors.New("invalid withdrawn routes length")
+	}
+	withdrawn = b[2 : 2+withdrawnLen]
+	b = b[2+withdrawnLen:]
+	attrsLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+attrsLen {
+		return nil, nil, nil, errors.New("invalid total path attribute length")
+	}
+	return withdrawn, b[2 : 2+attrsLen], b[2+attrsLen:], nil
+}
+
+// joinUpdate returns an UPDATE message body consisting of withdrawn, attrs
+// and nlri.
+func joinUpdate(withdrawn, attrs, nlri []byte) []byte {
+	b := make([]byte, 0, 4+len(withdrawn)+len(attrs)+len(nlri))
+	b = append(b, uint8(len(withdrawn)>>8), uint8(len(withdrawn)))
+	b = append(b, withdrawn...)
+	b = append(b, uint8(len(attrs)>>8), uint8(len(attrs)))
+	b = append(b, attrs...)
+	return append(b, nlri...)
+}
+
+// UpdateMessage is an UPDATE message body, as passed to an
+// UpdateMessageHandler, providing access to its fields without copying:
+//
+//	func(peer *corebgp.PeerConfig, b []byte) *corebgp.Notification {
+//		u := corebgp.UpdateMessage(b)
+//		if err := u.Validate(); err != nil {
+//			...
+//		}
+//		it := u.Attributes()
+//		...
+//	}
+//
+// Fields are returned in their encoded form. Plugins requiring decoded
+// prefixes and attributes may implement DecodedUpdatePlugin instead.
+type UpdateMessage []byte
+
+// Validate returns an error if the lengths of the withdrawn routes and path
+// attributes fields are inconsistent with the length of u, in which case the
+// other accessors return empty fields.
+func (u UpdateMessage) Validate() error {
+	_, _, _, err := splitUpdate(u)
+	return err
+}
+
+// WithdrawnRoutes returns the encoded IPv4 prefixes of the Withdrawn Routes
+// field. It references u.
+func (u UpdateMessage) WithdrawnRoutes() []byte {
+	withdrawn, _, _, _ := splitUpdate(u)
+	return withdrawn
+}
+
+// Attributes returns a PathAttrIterator over the path attributes of u.
+func (u UpdateMessage) Attributes() PathAttrIterator {
+	return NewPathAttrIterator(u)
+}
+
+// Attr returns the flags and value of the first path attribute of type
+// attrType. ok is false if the attribute is not present.
+func (u UpdateMessage) Attr(attrType uint8) (flags AttrFlags, value []byte,
+	ok bool) {
+	flags, value, ok, _ = FindPathAttr(u, attrType)
+	return flags, value, ok
+}
+
+// NLRI returns the encoded IPv4 prefixes of the Network Layer Reachability
+// Information field. It references u.
+func (u UpdateMessage) NLRI() []byte {
+	_, _, nlri, _ := splitUpdate(u)
+	return nlri
+}
+
+// Raw returns the message body.
+func (u UpdateMessage) Raw() []byte {
+	return u
+}
+
+// EndOfRIB returns the AFI/SAFI of u if it is an End-of-RIB marker, see
+// DecodeEndOfRIB.
+func (u UpdateMessage) EndOfRIB() (afi uint16, safi uint8, ok bool) {
+	return DecodeEndOfRIB(u)
+}
+
+// PathAttrIterator iterates over the path attributes of a raw UPDATE message
+// without allocating, for callers interested in a few attributes of a high
+// volume of updates. Attribute values are not validated or decoded; callers
+// decode only the attributes they need, e.g. via DecodeASPath.
+//
+//	it := NewPathAttrIterator(update)
+//	for it.Next() {
+//		if it.Type() == AttrTypeASPath {
+//			path, err := DecodeASPath(it.Value(), fourOctetAS)
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type PathAttrIterator struct {
+	attrs []byte
+	raw   []byte
+	flags AttrFlags
+	typ   uint8
+	value []byte
+	err   error
+}
+
+// NewPathAttrIterator returns a PathAttrIterator over the path attributes of
+// the UPDATE message body update. The iterator references update, which must
+// not be modified during iteration.
+func NewPathAttrIterator(update []byte) PathAttrIterator {
+	_, attrs, _, err := splitUpdate(update)
+	return PathAttrIterator{
+		attrs: attrs,
+		err:   err,
+	}
+}
+
+// newPathAttrIterator returns a PathAttrIterator over the path attributes
+// field attrs of an UPDATE message.
+func newPathAttrIterator(attrs []byte) PathAttrIterator {
+	return PathAttrIterator{
+		attrs: attrs,
+	}
+}
+
+// Next advances the iterator to the next attribute, returning false when
+// there are no more attributes or an error occurred.
+func (it *PathAttrIterator) Next() bool {
+	if it.err != nil || len(it.attrs) == 0 {
+		return false
+	}
+	attrs := it.attrs
+	if len(attrs) < 3 {
+		it.err = errors.New("malformed path attribute header")
+		return false
+	}
+	flags := AttrFlags(attrs[0])
+	headerLen, valueLen := 3, int(attrs[2])
+	if flags.ExtendedLength() {
+		if len(attrs) < 4 {
+			it.err = errors.New("malformed path attribute header")
+			return false
+		}
+		headerLen = 4
+		valueLen = int(binary.BigEndian.Uint16(attrs[2:]))
+	}
+	if len(attrs) < headerLen+valueLen {
+		it.err = errors.New("malformed path attribute length")
+		return false
+	}
+	it.flags = flags
+	it.typ = attrs[1]
+	it.raw = attrs[:headerLen+valueLen]
+	it.value = it.raw[headerLen:]
+	it.attrs = attrs[headerLen+valueLen:]
+	return true
+}
+
+// Flags returns the flags of the current attribute.
+func (it *PathAttrIterator) Flags() AttrFlags {
+	return it.flags
+}
+
+// Type returns the type code of the current attribute.
+func (it *PathAttrIterator) Type() uint8 {
+	return it.typ
+}
+
+// Value returns the value of the current attribute. It references the UPDATE
+// message.
+func (it *PathAttrIterator) Value() []byte {
+	return it.value
+}
+
+// Raw returns the complete encoded current attribute including its header. It
+// references the UPDATE message.
+func (it *PathAttrIterator) Raw() []byte {
+	return it.raw
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PathAttrIterator) Err() error {
+	return it.err
+}
+
+// FindPathAttr returns the flags and value of the first path attribute of
+// type attrType in the UPDATE message body update. ok is false if the
+// attribute is not present.
+func FindPathAttr(update []byte, attrType uint8) (flags AttrFlags,
+	value []byte, ok bool, err error) {
+	it := NewPathAttrIterator(update)
+	for it.Next() {
+		if it.Type() == attrType {
+			return it.Flags(), it.Value(), true, nil
+		}
+	}
+	return 0, nil, false, it.Err()
+}
+
+// rangePathAttrs calls fn for each path attribute in attrs, stopping if fn
+// returns false. raw is the complete encoded attribute including its header.
+func rangePathAttrs(attrs []byte, fn func(flags AttrFlags, attrType uint8,
+	value, raw []byte) bool) error {
+	it := newPathAttrIterator(attrs)
+	for it.Next() {
+		if !fn(it.Flags(), it.Type(), it.Value(), it.Raw()) {
+			return nil
+		}
+	}
+	return it.Err()
+}
+
+// NewEndOfRIB returns an End-of-RIB marker UPDATE message body for afi/safi.
+// The marker for IPv4 unicast is an UPDATE without withdrawn routes, path
+// attributes or NLRI, while the marker for other address families carries
+// only an empty MP_UNREACH_NLRI attribute.
+// https://tools.ietf.org/html/rfc4724#section-2
+func NewEndOfRIB(afi uint16, safi uint8) []byte {
+	if afi == AFIIPv4 && safi == SAFIUnicast {
+		return []byte{0, 0, 0, 0}
+	}
+	return []byte{0, 0, 0, 6, uint8(AttrFlagOptional), AttrTypeMPUnreachNLRI,
+		3, uint8(afi >> 8), uint8(afi), safi}
+}
+
+// DecodeEndOfRIB returns the AFI/SAFI of the End-of-RIB marker update. ok is
+// false if update is not an End-of-RIB marker.
+func DecodeEndOfRIB(update []byte) (afi uint16, safi uint8, ok bool) {
+	withdrawn, attrs, nlri, err := splitUpdate(update)
+	if err != nil || len(withdrawn) > 0 || len(nlri) > 0 {
+		return 0, 0, false
+	}
+	if len(attrs) == 0 {
+		return AFIIPv4, SAFIUnicast, true
+	}
+	it := newPathAttrIterator(attrs)
+	if !it.Next() || it.Type() != AttrTypeMPUnreachNLRI ||
+		len(it.Value()) != 3 || it.Next() || it.Err() != nil {
+		return 0, 0, false
+	}
+	v := it.Value()
+	return binary.BigEndian.Uint16(v), v[2], true
+}
@@ -0,0 +1,170 @@
+package corebgp
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+)
+
+// UpdateErrorAction determines the handling of a Notification returned by an
+// UpdateMessageHandler.
+type UpdateErrorAction uint8
+
+// UpdateErrorAction values
+const (
+	// UpdateErrorTeardown sends the Notification to the peer and closes the
+	// session.
+	UpdateErrorTeardown UpdateErrorAction = iota
+	// UpdateErrorContinue logs the Notification and continues processing
+	// messages from the peer. Ignored errors are counted by
+	// PeerCounters.UpdateErrorsIgnored.
+	UpdateErrorContinue
+	// UpdateErrorTreatAsWithdraw logs the Notification and passes updates
+	// withdrawing the NLRI carried by the offending update (RFC7606) to the
+	// UpdateMessageHandler in its place. Such updates are counted by
+	// PeerCounters.UpdatesTreatedAsWithdraw. If the update carries no NLRI
+	// only the routes it withdraws are passed to the handler again, and if it
+	// withdraws nothing either the session is closed. The session is also
+	// closed if the handler returns a Notification for a withdrawal.
+	UpdateErrorTreatAsWithdraw
+)
+
+func (a UpdateErrorAction) String() string {
+	switch a {
+	case UpdateErrorTeardown:
+		return "teardown"
+	case UpdateErrorContinue:
+		return "continue"
+	case UpdateErrorTreatAsWithdraw:
+		return "treat-as-withdraw"
+	}
+	return "unknown"
+}
+
+// UpdateErrorPolicy returns a PeerOption that classifies Notifications
+// returned by the peer's UpdateMessageHandler via fn, rather than always
+// closing the session. fn may be called from a separate goroutine if
+// InboundQueue is set.
+func UpdateErrorPolicy(fn func(peer *PeerConfig,
+	n *Notification) UpdateErrorAction) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.updateErrorPolicy = fn
+	})
+}
+
+// handleUpdate passes u to handler, applying the peer's UpdateErrorPolicy to
+// a returned Notification. A non-nil Notification is returned if the session
+// must be closed.
+func (f *fsm) handleUpdate(handler UpdateMessageHandler,
+	u updateMessage) *Notification {
+	n := handler(f.peer.config, u)
+	if n == nil || f.peer.options.updateErrorPolicy == nil {
+		return n
+	}
+	switch f.peer.options.updateErrorPolicy(f.peer.config, n) {
+	case UpdateErrorContinue:
+		atomic.AddUint64(&f.peer.counters.updateErrorsIgnored, 1)
+		logf("[%s] ignoring update handler error: %s", f.peer.config.IP, n)
+		return nil
+	case UpdateErrorTreatAsWithdraw:
+		withdrawals, err := treatAsWithdraw(u)
+		if err != nil || len(withdrawals) == 0 {
+			return n
+		}
+		atomic.AddUint64(&f.peer.counters.updatesTreatedAsWithdraw, 1)
+		logf("[%s] treating update as withdraw due to handler error: %s",
+			f.peer.config.IP, n)
+		for _, w := range withdrawals {
+			wn := handler(f.peer.config, w)
+			if wn != nil {
+				return wn
+			}
+		}
+		return nil
+	}
+	return n
+}
+
+// treatAsWithdraw returns the updates withdrawing the NLRI carried by update
+// along with any routes it already withdraws (RFC7606), which may require more
+// than one update. If update carries no NLRI a single update with only its
+// withdrawals is returned. nil is returned if update neither carries NLRI nor
+// withdraws routes.
+func treatAsWithdraw(update []byte) ([][]byte, error) {
+	withdrawn, attrs, nlri, err := splitUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		mpReach, mpUnreach []byte
+		hasMPUnreach       bool
+	)
+	err = rangePathAttrs(attrs, func(flags AttrFlags, attrType uint8,
+		value, raw []byte) bool {
+		switch attrType {
+		case AttrTypeMPReachNLRI:
+			mpReach = value
+		case AttrTypeMPUnreachNLRI:
+			mpUnreach = value
+			hasMPUnreach = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// withdraw IPv4 unicast NLRI along with any already withdrawn routes
+	w := make([]byte, 0, len(withdrawn)+len(nlri))
+	w = append(w, withdrawn...)
+	w = append(w, nlri...)
+	var unreachAttrs []byte
+	if hasMPUnreach {
+		unreachAttrs, err = AppendPathAttr(nil, AttrFlagOptional,
+			AttrTypeMPUnreachNLRI, mpUnreach)
+		if err != nil {
+			return nil, err
+		}
+	}
+	updates := [][]byte{joinUpdate(w, unreachAttrs, nil)}
+	if mpReach == nil {
+		if len(w) == 0 && !hasMPUnreach {
+			return nil, nil
+		}
+		return updates, nil
+	}
+
+	// https://tools.ietf.org/html/rfc4760#section-3
+	if len(mpReach) < 5 || len(mpReach) < 5+int(mpReach[3]) {
+		return nil, errors.New("malformed MP_REACH_NLRI attribute")
+	}
+	afiSAFI := mpReach[:3]
+	reachNLRI := mpReach[5+int(mpReach[3]):]
+	if hasMPUnreach && len(mpUnreach) >= 3 &&
+		bytes.Equal(mpUnreach[:3], afiSAFI) {
+		// merge with the existing MP_UNREACH_NLRI of the same AFI/SAFI
+		value := make([]byte, 0, len(mpUnreach)+len(reachNLRI))
+		value = append(value, mpUnreach...)
+		value = append(value, reachNLRI...)
+		merged, err := AppendPathAttr(nil, AttrFlagOptional,
+			AttrTypeMPUnreachNLRI, value)
+		if err != nil {
+			return nil, err
+		}
+		updates[0] = joinUpdate(w, merged, nil)
+		return updates, nil
+	}
+	value := make([]byte, 0, 3+len(reachNLRI))
+	value = append(value, afiSAFI...)
+	value = append(value, reachNLRI...)
+	unreach, err := AppendPathAttr(nil, AttrFlagOptional,
+		AttrTypeMPUnreachNLRI, value)
+	if err != nil {
+		return nil, err
+	}
+	if !hasMPUnreach && len(w) == 0 {
+		// no other withdrawals, a single update suffices
+		return [][]byte{joinUpdate(nil, unreach, nil)}, nil
+	}
+	return append(updates, joinUpdate(nil, unreach, nil)), nil
+}
@@ -0,0 +1,200 @@
+package corebgp
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testAttr returns an encoded path attribute, failing t on error.
+func testAttr(t *testing.T, flags AttrFlags, attrType uint8,
+	value ...[]byte) []byte {
+	t.Helper()
+	b, err := AppendPathAttr(nil, flags, attrType, bytes.Join(value, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTreatAsWithdraw(t *testing.T) {
+	var (
+		// IPv4 unicast prefixes 10.0.0.0/8 and 192.0.2.0/24
+		withdrawn4 = []byte{8, 10}
+		nlri4      = []byte{24, 192, 0, 2}
+		// IPv6 prefixes 2001:db8::/32 and 2001:db8:1::/48
+		withdrawn6 = []byte{32, 0x20, 0x01, 0x0d, 0xb8}
+		nlri6      = []byte{48, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01}
+
+		ipv6Unicast   = []byte{0, 2, 1}
+		ipv6Multicast = []byte{0, 2, 2}
+		// a next hop of 2001:db8::1 followed by the reserved octet
+		nextHop6 = []byte{16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 1, 0}
+	)
+	origin := testAttr(t, AttrFlagTransitive, AttrTypeOrigin, []byte{0})
+	mpReach := testAttr(t, AttrFlagOptional, AttrTypeMPReachNLRI,
+		ipv6Unicast, nextHop6, nlri6)
+	mpUnreach := testAttr(t, AttrFlagOptional, AttrTypeMPUnreachNLRI,
+		ipv6Unicast, withdrawn6)
+	mpUnreachMulticast := testAttr(t, AttrFlagOptional,
+		AttrTypeMPUnreachNLRI, ipv6Multicast, withdrawn6)
+	withdrawMPReach := testAttr(t, AttrFlagOptional, AttrTypeMPUnreachNLRI,
+		ipv6Unicast, nlri6)
+	join := func(b ...[]byte) []byte {
+		return bytes.Join(b, nil)
+	}
+
+	cases := []struct {
+		name    string
+		update  []byte
+		want    [][]byte
+		wantErr bool
+	}{
+		{
+			name:   "ipv4 only",
+			update: joinUpdate(withdrawn4, origin, nlri4),
+			want: [][]byte{
+				joinUpdate(join(withdrawn4, nlri4), nil, nil),
+			},
+		},
+		{
+			name:   "mp reach only",
+			update: joinUpdate(nil, join(origin, mpReach), nil),
+			want: [][]byte{
+				joinUpdate(nil, withdrawMPReach, nil),
+			},
+		},
+		{
+			name: "mp reach with mp unreach of same afi/safi",
+			update: joinUpdate(nil, join(origin, mpReach, mpUnreach),
+				nil),
+			want: [][]byte{
+				joinUpdate(nil, testAttr(t, AttrFlagOptional,
+					AttrTypeMPUnreachNLRI, ipv6Unicast, withdrawn6, nlri6),
+					nil),
+			},
+		},
+		{
+			name: "mp reach with mp unreach of different afi/safi",
+			update: joinUpdate(nil,
+				join(origin, mpReach, mpUnreachMulticast), nil),
+			want: [][]byte{
+				joinUpdate(nil, mpUnreachMulticast, nil),
+				joinUpdate(nil, withdrawMPReach, nil),
+			},
+		},
+		{
+			name: "ipv4 and mp reach",
+			update: joinUpdate(withdrawn4, join(origin, mpReach),
+				nlri4),
+			want: [][]byte{
+				joinUpdate(join(withdrawn4, nlri4), nil, nil),
+				joinUpdate(nil, withdrawMPReach, nil),
+			},
+		},
+		{
+			name:   "withdrawals only",
+			update: joinUpdate(withdrawn4, join(origin, mpUnreach), nil),
+			want: [][]byte{
+				joinUpdate(withdrawn4, mpUnreach, nil),
+			},
+		},
+		{
+			name:   "no nlri or withdrawals",
+			update: joinUpdate(nil, origin, nil),
+			want:   nil,
+		},
+		{
+			name: "malformed mp reach",
+			update: joinUpdate(nil, testAttr(t, AttrFlagOptional,
+				AttrTypeMPReachNLRI, ipv6Unicast, []byte{16, 0x20}), nil),
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := treatAsWithdraw(c.update)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("got %d updates, want %d: %x", len(got),
+					len(c.want), got)
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], c.want[i]) {
+					t.Errorf("update %d = %x, want %x", i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeUpdateTreatAsWithdraw(t *testing.T) {
+	nlri := []byte{24, 192, 0, 2}
+	mandatory := bytes.Join([][]byte{
+		testAttr(t, AttrFlagTransitive, AttrTypeOrigin, []byte{0}),
+		testAttr(t, AttrFlagTransitive, AttrTypeASPath, nil),
+		testAttr(t, AttrFlagTransitive, AttrTypeNextHop,
+			[]byte{198, 51, 100, 1}),
+	}, nil)
+	optional := AttrFlagOptional | AttrFlagTransitive
+
+	cases := []struct {
+		name     string
+		attr     []byte
+		withdraw bool
+	}{
+		{
+			name: "communities",
+			attr: testAttr(t, optional, AttrTypeCommunities,
+				[]byte{0, 1, 0, 1}),
+		},
+		{
+			name:     "empty communities",
+			attr:     testAttr(t, optional, AttrTypeCommunities, nil),
+			withdraw: true,
+		},
+		{
+			name: "extended communities",
+			attr: testAttr(t, optional, AttrTypeExtendedCommunities,
+				[]byte{0, 2, 0, 1, 0, 0, 0, 1}),
+		},
+		{
+			name: "empty extended communities",
+			attr: testAttr(t, optional, AttrTypeExtendedCommunities,
+				nil),
+			withdraw: true,
+		},
+		{
+			name: "truncated extended communities",
+			attr: testAttr(t, optional, AttrTypeExtendedCommunities,
+				[]byte{0, 2, 0, 1}),
+			withdraw: true,
+		},
+		{
+			name:     "empty large communities",
+			attr:     testAttr(t, optional, AttrTypeLargeCommunities, nil),
+			withdraw: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u, err := DecodeUpdate(joinUpdate(nil,
+				bytes.Join([][]byte{mandatory, c.attr}, nil), nlri), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if u.TreatAsWithdraw != c.withdraw {
+				t.Fatalf("TreatAsWithdraw = %v, want %v",
+					u.TreatAsWithdraw, c.withdraw)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+//go:build go1.18
+// +build go1.18
+
+package updatejson
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jwhited/corebgp"
+)
+
+var (
+	errMalformedUpdate = errors.New("malformed update message")
+)
+
+// Announcement is a set of prefixes announced with the same next hop.
+type Announcement struct {
+	AFI      uint16
+	SAFI     uint8
+	NextHop  net.IP
+	Prefixes []*net.IPNet
+}
+
+// Aggregator is the value of an AGGREGATOR path attribute.
+type Aggregator struct {
+	ASN     uint32
+	Address net.IP
+}
+
+// Update is a decoded UPDATE message. Prefixes of the IPv4 and IPv6 unicast
+// and multicast SAFIs are decoded; MP_REACH_NLRI and MP_UNREACH_NLRI of other
+// SAFIs are ignored.
+type Update struct {
+	// Withdrawn contains the prefixes of the withdrawn routes field and of
+	// MP_UNREACH_NLRI.
+	Withdrawn     []*net.IPNet
+	Announcements []Announcement
+	// Origin is set if the ORIGIN attribute is present.
+	Origin *uint8
+	// ASPath is the AS path of the update, reconstructed from AS_PATH and
+	// AS4_PATH if four-octet AS numbers were not negotiated.
+	ASPath              corebgp.ASPath
+	MED                 *uint32
+	LocalPref           *uint32
+	AtomicAggregate     bool
+	Aggregator          *Aggregator
+	Communities         []uint32
+	ExtendedCommunities []corebgp.ExtendedCommunity
+	// LargeCommunities contains the global administrator, local data part 1
+	// and local data part 2 of each large community.
+	LargeCommunities [][3]uint32
+	OriginatorID     net.IP
+	ClusterList      []net.IP
+}
+
+func addrLen(afi uint16) int {
+	if afi == corebgp.AFIIPv6 {
+		return net.IPv6len
+	}
+	return net.IPv4len
+}
+
+// decodePrefixes decodes the prefixes of an NLRI field of the given AFI via
+// corebgp.NLRICodec, so that a prefix with bits set beyond its length is
+// rejected as it is by the codec rather than masked.
+func decodePrefixes(b []byte, afi uint16) ([]*net.IPNet, error) {
+	nlri, err := corebgp.NLRICodec{AFI: afi}.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]*net.IPNet, 0, len(nlri))
+	for _, n := range nlri {
+		addr := n.Prefix.Addr()
+		prefixes = append(prefixes, &net.IPNet{
+			IP:   addr.AsSlice(),
+			Mask: net.CIDRMask(n.Prefix.Bits(), addr.BitLen()),
+		})
+	}
+	return prefixes, nil
+}
+
+func isPrefixSAFI(afi uint16, safi uint8) bool {
+	return (afi == corebgp.AFIIPv4 || afi == corebgp.AFIIPv6) &&
+		(safi == corebgp.SAFIUnicast || safi == corebgp.SAFIMulticast)
+}
+
+func decodeIPs(b []byte) ([]net.IP, error) {
+	if len(b)%net.IPv4len != 0 {
+		return nil, errMalformedUpdate
+	}
+	ips := make([]net.IP, 0, len(b)/net.IPv4len)
+	for i := 0; i < len(b); i += net.IPv4len {
+		ips = append(ips, net.IP(b[i:i+net.IPv4len]))
+	}
+	return ips, nil
+}
+
+// mergeAS4Path reconstructs the AS path from AS_PATH and AS4_PATH.
+// https://tools.ietf.org/html/rfc6793#section-4.2.3
+func mergeAS4Path(path, as4Path corebgp.ASPath) corebgp.ASPath {
+	count := func(p corebgp.ASPath) int {
+		n := 0
+		for _, seg := range p {
+			n += len(seg.ASNs)
+		}
+		return n
+	}
+	keep := count(path) - count(as4Path)
+	if as4Path == nil || keep < 0 {
+		return path
+	}
+	merged := make(corebgp.ASPath, 0, len(path)+len(as4Path))
+	for _, seg := range path {
+		if keep == 0 {
+			break
+		}
+		n := len(seg.ASNs)
+		if n > keep {
+			n = keep
+		}
+		merged = append(merged, corebgp.ASPathSegment{
+			Type: seg.Type,
+			ASNs: seg.ASNs[:n],
+		})
+		keep -= n
+	}
+	return append(merged, as4Path...)
+}
+
+func (u *Update) decodeAttr(attrType uint8, value []byte,
+	fourOctetAS bool) error {
+	var err error
+	switch attrType {
+	case corebgp.AttrTypeOrigin:
+		if len(value) != 1 {
+			return errMalformedUpdate
+		}
+		origin := value[0]
+		u.Origin = &origin
+	case corebgp.AttrTypeASPath:
+		u.ASPath, err = corebgp.DecodeASPath(value, fourOctetAS)
+	case corebgp.AttrTypeBGPsecPath:
+		// BGPsec update messages carry no AS_PATH, report the equivalent
+		// AS_PATH of their secure path
+		var p corebgp.BGPsecPath
+		if err = p.Decode(value); err == nil && u.ASPath == nil {
+			u.ASPath = p.ASPath()
+		}
+	case corebgp.AttrTypeNextHop:
+		if len(value) != net.IPv4len {
+			return errMalformedUpdate
+		}
+		u.Announcements = append(u.Announcements, Announcement{
+			AFI:     corebgp.AFIIPv4,
+			SAFI:    corebgp.SAFIUnicast,
+			NextHop: net.IP(value),
+		})
+	case corebgp.AttrTypeMED, corebgp.AttrTypeLocalPref:
+		if len(value) != 4 {
+			return errMalformedUpdate
+		}
+		v := binary.BigEndian.Uint32(value)
+		if attrType == corebgp.AttrTypeMED {
+			u.MED = &v
+		} else {
+			u.LocalPref = &v
+		}
+	case corebgp.AttrTypeAtomicAggregate:
+		u.AtomicAggregate = true
+	case corebgp.AttrTypeAggregator:
+		asnLen := 2
+		if fourOctetAS {
+			asnLen = 4
+		}
+		if len(value) != asnLen+net.IPv4len {
+			return errMalformedUpdate
+		}
+		a := &Aggregator{
+			Address: net.IP(value[asnLen:]),
+		}
+		if fourOctetAS {
+			a.ASN = binary.BigEndian.Uint32(value)
+		} else {
+			a.ASN = uint32(binary.BigEndian.Uint16(value))
+		}
+		u.Aggregator = a
+	case corebgp.AttrTypeCommunities:
+		if len(value)%4 != 0 {
+			return errMalformedUpdate
+		}
+		for i := 0; i < len(value); i += 4 {
+			u.Communities = append(u.Communities,
+				binary.BigEndian.Uint32(value[i:]))
+		}
+	case corebgp.AttrTypeOriginatorID:
+		if len(value) != net.IPv4len {
+			return errMalformedUpdate
+		}
+		u.OriginatorID = net.IP(value)
+	case corebgp.AttrTypeClusterList:
+		u.ClusterList, err = decodeIPs(value)
+	case corebgp.AttrTypeExtendedCommunities:
+		u.ExtendedCommunities, err = corebgp.DecodeExtendedCommunities(value)
+	case corebgp.AttrTypeLargeCommunities:
+		if len(value)%12 != 0 {
+			return errMalformedUpdate
+		}
+		for i := 0; i < len(value); i += 12 {
+			u.LargeCommunities = append(u.LargeCommunities, [3]uint32{
+				binary.BigEndian.Uint32(value[i:]),
+				binary.BigEndian.Uint32(value[i+4:]),
+				binary.BigEndian.Uint32(value[i+8:]),
+			})
+		}
+	case corebgp.AttrTypeMPReachNLRI:
+		// https://tools.ietf.org/html/rfc4760#section-3
+		if len(value) < 5 || len(value) < 5+int(value[3]) {
+			return errMalformedUpdate
+		}
+		afi, safi := binary.BigEndian.Uint16(value), value[2]
+		if !isPrefixSAFI(afi, safi) {
+			return nil
+		}
+		nhLen := int(value[3])
+		a := Announcement{
+			AFI:  afi,
+			SAFI: safi,
+		}
+		if nhLen >= addrLen(afi) {
+			// a link-local IPv6 next hop may follow the global one
+			a.NextHop = net.IP(value[4 : 4+addrLen(afi)])
+		}
+		a.Prefixes, err = decodePrefixes(value[5+nhLen:], afi)
+		if err != nil {
+			return err
+		}
+		u.Announcements = append(u.Announcements, a)
+	case corebgp.AttrTypeMPUnreachNLRI:
+		// https://tools.ietf.org/html/rfc4760#section-4
+		if len(value) < 3 {
+			return errMalformedUpdate
+		}
+		afi, safi := binary.BigEndian.Uint16(value), value[2]
+		if !isPrefixSAFI(afi, safi) {
+			return nil
+		}
+		withdrawn, err := decodePrefixes(value[3:], afi)
+		if err != nil {
+			return err
+		}
+		u.Withdrawn = append(u.Withdrawn, withdrawn...)
+	}
+	return err
+}
+
+// Decode decodes an UPDATE message body. fourOctetAS should be true if
+// four-octet AS numbers were negotiated for the session.
+func Decode(b []byte, fourOctetAS bool) (*Update, error) {
+	update := b
+	if len(b) < 4 {
+		return nil, errMalformedUpdate
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 4+withdrawnLen {
+		return nil, errMalformedUpdate
+	}
+	withdrawn, err := decodePrefixes(b[2:2+withdrawnLen], corebgp.AFIIPv4)
+	if err != nil {
+		return nil, err
+	}
+	b = b[2+withdrawnLen:]
+	attrsLen := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+attrsLen {
+		return nil, errMalformedUpdate
+	}
+	nlri, err := decodePrefixes(b[2+attrsLen:], corebgp.AFIIPv4)
+	if err != nil {
+		return nil, err
+	}
+	u := &Update{
+		Withdrawn: withdrawn,
+	}
+	var as4Path corebgp.ASPath
+	it := corebgp.NewPathAttrIterator(update)
+	for it.Next() {
+		attrType, value := it.Type(), it.Value()
+		if attrType == corebgp.AttrTypeAS4Path && !fourOctetAS {
+			as4Path, err = corebgp.DecodeASPath(value, true)
+		} else {
+			err = u.decodeAttr(attrType, value, fourOctetAS)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding attribute type %d: %w",
+				attrType, err)
+		}
+	}
+	if it.Err() != nil {
+		return nil, errMalformedUpdate
+	}
+	u.ASPath = mergeAS4Path(u.ASPath, as4Path)
+	if len(nlri) > 0 {
+		found := false
+		for i, a := range u.Announcements {
+			if a.AFI == corebgp.AFIIPv4 && a.SAFI == corebgp.SAFIUnicast &&
+				a.Prefixes == nil {
+				u.Announcements[i].Prefixes = nlri
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("missing NEXT_HOP attribute")
+		}
+	}
+	// drop a NEXT_HOP carried without IPv4 unicast NLRI
+	announcements := u.Announcements[:0]
+	for _, a := range u.Announcements {
+		if len(a.Prefixes) > 0 {
+			announcements = append(announcements, a)
+		}
+	}
+	u.Announcements = announcements
+	return u, nil
+}
@@ -0,0 +1,235 @@
+//go:build go1.18
+// +build go1.18
+
+package updatejson
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenBMP actions
+const (
+	OpenBMPActionAdd  = "add"
+	OpenBMPActionDel  = "del"
+	OpenBMPActionUp   = "up"
+	OpenBMPActionDown = "down"
+)
+
+// OpenBMPRouter identifies the router, i.e. the corebgp Server, in OpenBMP
+// messages.
+type OpenBMPRouter struct {
+	Name    string
+	Address net.IP
+}
+
+func (r OpenBMPRouter) hash() string {
+	return md5Hex(r.Address.String())
+}
+
+func (r OpenBMPRouter) peerHash(peer PeerInfo) string {
+	return md5Hex(peer.Address.String(), r.hash())
+}
+
+func md5Hex(fields ...string) string {
+	sum := md5.Sum([]byte(strings.Join(fields, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// openBMPTimestamp formats t as in OpenBMP messages.
+func openBMPTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000000")
+}
+
+// OpenBMPUnicastPrefix is an OpenBMP parsed "unicast_prefix" message
+// describing the announcement or withdrawal of a prefix. Hashes are the
+// hex-encoded MD5 digest of the identifying fields of the corresponding
+// object, following the OpenBMP collector.
+type OpenBMPUnicastPrefix struct {
+	Action             string `json:"action"`
+	Sequence           uint64 `json:"seq"`
+	Hash               string `json:"hash"`
+	RouterHash         string `json:"router_hash"`
+	RouterIP           string `json:"router_ip"`
+	BaseAttrHash       string `json:"base_attr_hash"`
+	PeerHash           string `json:"peer_hash"`
+	PeerIP             string `json:"peer_ip"`
+	PeerASN            uint32 `json:"peer_asn"`
+	Timestamp          string `json:"timestamp"`
+	Prefix             string `json:"prefix"`
+	PrefixLen          int    `json:"prefix_len"`
+	IsIPv4             bool   `json:"is_ipv4"`
+	Origin             string `json:"origin"`
+	ASPath             string `json:"as_path"`
+	ASPathCount        int    `json:"as_path_count"`
+	OriginAS           uint32 `json:"origin_as"`
+	NextHop            string `json:"nexthop"`
+	MED                uint32 `json:"med"`
+	LocalPref          uint32 `json:"local_pref"`
+	Aggregator         string `json:"aggregator"`
+	CommunityList      string `json:"community_list"`
+	ExtCommunityList   string `json:"ext_community_list"`
+	ClusterList        string `json:"cluster_list"`
+	IsAtomicAgg        bool   `json:"isAtomicAgg"`
+	IsNextHopIPv4      bool   `json:"isNexthopIPv4"`
+	OriginatorID       string `json:"originator_id"`
+	PathID             uint32 `json:"path_id"`
+	Labels             string `json:"labels"`
+	IsPrePolicy        bool   `json:"isPrePolicy"`
+	IsAdjRIBIn         bool   `json:"isAdjRibIn"`
+	LargeCommunityList string `json:"large_community_list"`
+}
+
+// OpenBMPPeer is an OpenBMP parsed "peer" message describing a peer coming
+// up or going down.
+type OpenBMPPeer struct {
+	Action     string `json:"action"`
+	Sequence   uint64 `json:"seq"`
+	Hash       string `json:"hash"`
+	RouterHash string `json:"router_hash"`
+	Name       string `json:"name"`
+	RemoteIP   string `json:"remote_ip"`
+	RemoteASN  uint32 `json:"remote_asn"`
+	Timestamp  string `json:"timestamp"`
+	IsIPv4     bool   `json:"isIPv4"`
+}
+
+// NewOpenBMPPeer returns an OpenBMPPeer message for peer. action is one of
+// OpenBMPActionUp or OpenBMPActionDown.
+func NewOpenBMPPeer(router OpenBMPRouter, seq uint64, peer PeerInfo,
+	t time.Time, action string) *OpenBMPPeer {
+	return &OpenBMPPeer{
+		Action:     action,
+		Sequence:   seq,
+		Hash:       router.peerHash(peer),
+		RouterHash: router.hash(),
+		Name:       router.Name,
+		RemoteIP:   peer.Address.String(),
+		RemoteASN:  peer.ASN,
+		Timestamp:  openBMPTimestamp(t),
+		IsIPv4:     peer.Address.To4() != nil,
+	}
+}
+
+func joinStrings(n int, s func(i int) string) string {
+	fields := make([]string, n)
+	for i := range fields {
+		fields[i] = s(i)
+	}
+	return strings.Join(fields, " ")
+}
+
+// newOpenBMPBase returns an OpenBMPUnicastPrefix populated with the path
+// attributes of u.
+func newOpenBMPBase(router OpenBMPRouter, peer PeerInfo, t time.Time,
+	u *Update) OpenBMPUnicastPrefix {
+	b := OpenBMPUnicastPrefix{
+		RouterHash:  router.hash(),
+		RouterIP:    router.Address.String(),
+		PeerHash:    router.peerHash(peer),
+		PeerIP:      peer.Address.String(),
+		PeerASN:     peer.ASN,
+		Timestamp:   openBMPTimestamp(t),
+		ASPath:      u.ASPath.String(),
+		ASPathCount: u.ASPath.Length(),
+		IsAtomicAgg: u.AtomicAggregate,
+		IsPrePolicy: true,
+		IsAdjRIBIn:  true,
+	}
+	if u.Origin != nil {
+		b.Origin = strings.ToLower(originString(*u.Origin))
+	}
+	b.OriginAS, _ = u.ASPath.OriginASN()
+	if u.MED != nil {
+		b.MED = *u.MED
+	}
+	if u.LocalPref != nil {
+		b.LocalPref = *u.LocalPref
+	}
+	if u.Aggregator != nil {
+		b.Aggregator = fmt.Sprintf("%d %s", u.Aggregator.ASN,
+			u.Aggregator.Address)
+	}
+	b.CommunityList = joinStrings(len(u.Communities), func(i int) string {
+		c := u.Communities[i]
+		return fmt.Sprintf("%d:%d", c>>16, c&0xffff)
+	})
+	b.ExtCommunityList = joinStrings(len(u.ExtendedCommunities),
+		func(i int) string {
+			return u.ExtendedCommunities[i].String()
+		})
+	b.LargeCommunityList = joinStrings(len(u.LargeCommunities),
+		func(i int) string {
+			c := u.LargeCommunities[i]
+			return fmt.Sprintf("%d:%d:%d", c[0], c[1], c[2])
+		})
+	b.ClusterList = joinStrings(len(u.ClusterList), func(i int) string {
+		return u.ClusterList[i].String()
+	})
+	if u.OriginatorID != nil {
+		b.OriginatorID = u.OriginatorID.String()
+	}
+	return b
+}
+
+func (p *OpenBMPUnicastPrefix) setBaseAttrHash() {
+	p.BaseAttrHash = md5Hex(p.ASPath, p.NextHop, p.Aggregator, p.Origin,
+		strconv.FormatUint(uint64(p.MED), 10),
+		strconv.FormatUint(uint64(p.LocalPref), 10), p.CommunityList,
+		p.ExtCommunityList, p.PeerHash)
+}
+
+func (p *OpenBMPUnicastPrefix) setPrefix(prefix *net.IPNet) {
+	p.Prefix = prefix.IP.String()
+	p.PrefixLen, _ = prefix.Mask.Size()
+	p.IsIPv4 = prefix.IP.To4() != nil
+	p.Hash = md5Hex(p.Prefix, strconv.Itoa(p.PrefixLen), p.PeerHash)
+}
+
+// NewOpenBMPUnicastPrefixes returns an OpenBMPUnicastPrefix message for each
+// prefix announced or withdrawn by an UPDATE message received from peer.
+// Messages are numbered sequentially starting at seq.
+func NewOpenBMPUnicastPrefixes(router OpenBMPRouter, seq uint64,
+	peer PeerInfo, t time.Time, u *Update) []*OpenBMPUnicastPrefix {
+	base := newOpenBMPBase(router, peer, t, u)
+	messages := make([]*OpenBMPUnicastPrefix, 0)
+	for _, a := range u.Announcements {
+		attrs := base
+		if a.NextHop != nil {
+			attrs.NextHop = a.NextHop.String()
+			attrs.IsNextHopIPv4 = a.NextHop.To4() != nil
+		}
+		attrs.setBaseAttrHash()
+		for _, prefix := range a.Prefixes {
+			m := attrs
+			m.Action = OpenBMPActionAdd
+			m.Sequence = seq
+			seq++
+			m.setPrefix(prefix)
+			messages = append(messages, &m)
+		}
+	}
+	for _, prefix := range u.Withdrawn {
+		m := OpenBMPUnicastPrefix{
+			Action:      OpenBMPActionDel,
+			Sequence:    seq,
+			RouterHash:  base.RouterHash,
+			RouterIP:    base.RouterIP,
+			PeerHash:    base.PeerHash,
+			PeerIP:      base.PeerIP,
+			PeerASN:     base.PeerASN,
+			Timestamp:   base.Timestamp,
+			IsPrePolicy: true,
+			IsAdjRIBIn:  true,
+		}
+		seq++
+		m.setPrefix(prefix)
+		messages = append(messages, &m)
+	}
+	return messages
+}
@@ -0,0 +1,184 @@
+//go:build go1.18
+// +build go1.18
+
+package updatejson
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// RIS Live message types
+// https://ris-live.ripe.net/manual/
+const (
+	RISMessageTypeUpdate    = "UPDATE"
+	RISMessageTypePeerState = "RIS_PEER_STATE"
+)
+
+// RIS Live RIS_PEER_STATE states
+const (
+	RISPeerStateConnected = "connected"
+	RISPeerStateDown      = "down"
+)
+
+// RISMessage is a RIS Live "ris_message".
+type RISMessage struct {
+	Type string         `json:"type"`
+	Data RISMessageData `json:"data"`
+}
+
+// RISAnnouncement is an element of RISMessageData.Announcements.
+type RISAnnouncement struct {
+	NextHop  string   `json:"next_hop"`
+	Prefixes []string `json:"prefixes"`
+}
+
+// RISMessageData is the data of a RISMessage. Fields irrelevant to the
+// message type are omitted.
+type RISMessageData struct {
+	Timestamp float64 `json:"timestamp"`
+	Peer      string  `json:"peer"`
+	PeerASN   string  `json:"peer_asn"`
+	ID        string  `json:"id"`
+	Host      string  `json:"host"`
+	Type      string  `json:"type"`
+	// Path contains ASNs of AS_SEQUENCE segments and arrays of ASNs for
+	// AS_SET segments.
+	Path          []interface{}     `json:"path,omitempty"`
+	Community     [][2]uint32       `json:"community,omitempty"`
+	Origin        string            `json:"origin,omitempty"`
+	MED           *uint32           `json:"med,omitempty"`
+	Aggregator    string            `json:"aggregator,omitempty"`
+	Announcements []RISAnnouncement `json:"announcements,omitempty"`
+	Withdrawals   []string          `json:"withdrawals,omitempty"`
+	// Raw is the complete message, including header, in hex.
+	Raw   string `json:"raw,omitempty"`
+	State string `json:"state,omitempty"`
+}
+
+func risTimestamp(t time.Time) float64 {
+	return math.Round(float64(t.UnixNano())/1e4) / 1e5
+}
+
+func originString(origin uint8) string {
+	switch origin {
+	case 0:
+		return "IGP"
+	case 1:
+		return "EGP"
+	case 2:
+		return "INCOMPLETE"
+	}
+	return strconv.Itoa(int(origin))
+}
+
+func prefixStrings(prefixes []*net.IPNet) []string {
+	s := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+	return s
+}
+
+// risPath converts path to the RIS Live representation. Confederation
+// segments are not included.
+func risPath(path corebgp.ASPath) []interface{} {
+	p := make([]interface{}, 0)
+	for _, seg := range path {
+		switch seg.Type {
+		case corebgp.ASPathSegmentSequence:
+			for _, asn := range seg.ASNs {
+				p = append(p, asn)
+			}
+		case corebgp.ASPathSegmentSet:
+			p = append(p, seg.ASNs)
+		}
+	}
+	return p
+}
+
+// rawMessage returns the hex encoding of a complete BGP message of msgType
+// with body.
+func rawMessage(msgType uint8, body []byte) string {
+	length := 19 + len(body)
+	b := make([]byte, 0, length)
+	for i := 0; i < 16; i++ {
+		b = append(b, 0xff)
+	}
+	b = append(b, uint8(length>>8), uint8(length), msgType)
+	b = append(b, body...)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// NewRISUpdate returns a RISMessage for an UPDATE message received from peer.
+// raw is the UPDATE message body, which is included in the message if
+// non-nil.
+func NewRISUpdate(host, id string, peer PeerInfo, t time.Time, u *Update,
+	raw []byte) *RISMessage {
+	d := RISMessageData{
+		Timestamp: risTimestamp(t),
+		Peer:      peer.Address.String(),
+		PeerASN:   strconv.FormatUint(uint64(peer.ASN), 10),
+		ID:        id,
+		Host:      host,
+		Type:      RISMessageTypeUpdate,
+		MED:       u.MED,
+	}
+	if len(u.Announcements) > 0 {
+		d.Path = risPath(u.ASPath)
+		for _, c := range u.Communities {
+			d.Community = append(d.Community, [2]uint32{c >> 16, c & 0xffff})
+		}
+		if u.Origin != nil {
+			d.Origin = originString(*u.Origin)
+		}
+		if u.Aggregator != nil {
+			d.Aggregator = fmt.Sprintf("%d:%s", u.Aggregator.ASN,
+				u.Aggregator.Address)
+		}
+	}
+	for _, a := range u.Announcements {
+		ra := RISAnnouncement{
+			Prefixes: prefixStrings(a.Prefixes),
+		}
+		if a.NextHop != nil {
+			ra.NextHop = a.NextHop.String()
+		}
+		d.Announcements = append(d.Announcements, ra)
+	}
+	if len(u.Withdrawn) > 0 {
+		d.Withdrawals = prefixStrings(u.Withdrawn)
+	}
+	if raw != nil {
+		d.Raw = rawMessage(corebgp.UpdateMessageType, raw)
+	}
+	return &RISMessage{
+		Type: "ris_message",
+		Data: d,
+	}
+}
+
+// NewRISPeerState returns a RIS_PEER_STATE RISMessage for peer. state is
+// one of RISPeerStateConnected or RISPeerStateDown.
+func NewRISPeerState(host, id string, peer PeerInfo, t time.Time,
+	state string) *RISMessage {
+	return &RISMessage{
+		Type: "ris_message",
+		Data: RISMessageData{
+			Timestamp: risTimestamp(t),
+			Peer:      peer.Address.String(),
+			PeerASN:   strconv.FormatUint(uint64(peer.ASN), 10),
+			ID:        id,
+			Host:      host,
+			Type:      RISMessageTypePeerState,
+			State:     state,
+		},
+	}
+}
@@ -0,0 +1,188 @@
+//go:build go1.18
+// +build go1.18
+
+// Package updatejson serializes UPDATE messages received from peers, along
+// with peer state changes, into the JSON message formats of RIPE RIS Live
+// and OpenBMP so that corebgp based collectors can feed existing analysis
+// pipelines.
+//
+// A Serializer converts Server events obtained via Server.SubscribeUpdates()
+// and Server.Subscribe() into messages:
+//
+//	s := updatejson.NewRISLiveSerializer(srv, "corebgp01", false)
+//	sub := srv.SubscribeUpdates(1024)
+//	for e := range sub.C() {
+//		messages, err := s.Marshal(e)
+//		...
+//	}
+//
+// Lower level functions construct messages from an Update returned by
+// Decode.
+package updatejson
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/jwhited/corebgp"
+)
+
+// PeerInfo is the metadata of a peer included in messages.
+type PeerInfo struct {
+	Address net.IP
+	ASN     uint32
+}
+
+// Format is a JSON message format.
+type Format uint8
+
+// Format values
+const (
+	FormatRISLive Format = iota
+	FormatOpenBMP
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatRISLive:
+		return "ris-live"
+	case FormatOpenBMP:
+		return "openbmp"
+	}
+	return "unknown"
+}
+
+// Serializer converts Server events into JSON messages of a Format.
+type Serializer struct {
+	server     *corebgp.Server
+	format     Format
+	host       string
+	router     OpenBMPRouter
+	includeRaw bool
+	seq        uint64
+}
+
+// NewRISLiveSerializer returns a Serializer producing RIS Live messages with
+// host as the collector name. includeRaw includes the raw UPDATE message in
+// each message. Peer ASNs are looked up via server.
+func NewRISLiveSerializer(server *corebgp.Server, host string,
+	includeRaw bool) *Serializer {
+	return &Serializer{
+		server:     server,
+		format:     FormatRISLive,
+		host:       host,
+		includeRaw: includeRaw,
+	}
+}
+
+// NewOpenBMPSerializer returns a Serializer producing OpenBMP parsed
+// messages on behalf of router. Peer ASNs are looked up via server.
+func NewOpenBMPSerializer(server *corebgp.Server,
+	router OpenBMPRouter) *Serializer {
+	return &Serializer{
+		server: server,
+		format: FormatOpenBMP,
+		router: router,
+	}
+}
+
+// Format returns the Format of the Serializer.
+func (s *Serializer) Format() Format {
+	return s.format
+}
+
+func (s *Serializer) peerInfo(k corebgp.PeerKey) PeerInfo {
+	info := PeerInfo{
+		Address: k.IP,
+		ASN:     k.RemoteAS,
+	}
+	// a peer deleted since the event was published keeps its configured ASN
+	p, err := s.server.GetPeerByKey(k)
+	if err == nil && p.Session != nil {
+		// learned from the peer's OPEN message for AnyRemoteAS peers
+		info.ASN = p.Session.RemoteAS
+	}
+	return info
+}
+
+func (s *Serializer) nextID() string {
+	return fmt.Sprintf("%s-%d", s.host, atomic.AddUint64(&s.seq, 1))
+}
+
+// nextSeq reserves n sequence numbers, returning the first.
+func (s *Serializer) nextSeq(n int) uint64 {
+	return atomic.AddUint64(&s.seq, uint64(n)) - uint64(n)
+}
+
+func marshalAll(messages ...interface{}) ([][]byte, error) {
+	encoded := make([][]byte, 0, len(messages))
+	for _, m := range messages {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, b)
+	}
+	return encoded, nil
+}
+
+// Marshal returns the JSON messages for e. UpdateReceivedEvent and
+// StateChangeEvent into or out of the Established state produce messages;
+// other events produce none. An UPDATE message that cannot be decoded
+// results in an error.
+func (s *Serializer) Marshal(e corebgp.Event) ([][]byte, error) {
+	switch e := e.(type) {
+	case *corebgp.UpdateReceivedEvent:
+		u, err := Decode(e.Update, e.FourOctetAS)
+		if err != nil {
+			return nil, err
+		}
+		peer := s.peerInfo(e.Key)
+		if s.format == FormatRISLive {
+			var raw []byte
+			if s.includeRaw {
+				raw = e.Update
+			}
+			return marshalAll(NewRISUpdate(s.host, s.nextID(), peer, e.Time,
+				u, raw))
+		}
+		n := len(u.Withdrawn)
+		for _, a := range u.Announcements {
+			n += len(a.Prefixes)
+		}
+		prefixes := NewOpenBMPUnicastPrefixes(s.router, s.nextSeq(n), peer,
+			e.Time, u)
+		messages := make([]interface{}, 0, len(prefixes))
+		for _, m := range prefixes {
+			messages = append(messages, m)
+		}
+		return marshalAll(messages...)
+	case *corebgp.StateChangeEvent:
+		var up bool
+		switch {
+		case e.To == corebgp.EstablishedState:
+			up = true
+		case e.From == corebgp.EstablishedState:
+		default:
+			return nil, nil
+		}
+		peer := s.peerInfo(e.Key)
+		if s.format == FormatRISLive {
+			state := RISPeerStateDown
+			if up {
+				state = RISPeerStateConnected
+			}
+			return marshalAll(NewRISPeerState(s.host, s.nextID(), peer, e.Time,
+				state))
+		}
+		action := OpenBMPActionDown
+		if up {
+			action = OpenBMPActionUp
+		}
+		return marshalAll(NewOpenBMPPeer(s.router, s.nextSeq(1), peer, e.Time,
+			action))
+	}
+	return nil, nil
+}
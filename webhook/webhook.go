@@ -0,0 +1,417 @@
+// Package webhook POSTs JSON payloads describing session events of a
+// corebgp.Server to webhook URLs, providing alerting for small deployments
+// without a metrics stack.
+//
+// A Notifier subscribes to a Server and delivers a Payload for each event of
+// a configured EventType to every URL, retrying failed deliveries with
+// exponential backoff:
+//
+//	n := webhook.NewNotifier(srv, []string{"https://hooks.example.com/bgp"},
+//		webhook.Events(webhook.EventPeerDown))
+//	go n.Run(ctx)
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwhited/corebgp"
+)
+
+// EventType is the type of a Payload.
+type EventType uint8
+
+// EventType values
+const (
+	// EventPeerDown is delivered when an established session closes.
+	EventPeerDown EventType = iota
+	// EventPrefixLimitExceeded is delivered when a Cease NOTIFICATION with
+	// the Maximum Number of Prefixes Reached subcode is sent to or received
+	// from a peer.
+	EventPrefixLimitExceeded
+	// EventNotificationReceived is delivered when a NOTIFICATION message is
+	// received from a peer.
+	EventNotificationReceived
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPeerDown:
+		return "peer_down"
+	case EventPrefixLimitExceeded:
+		return "prefix_limit_exceeded"
+	case EventNotificationReceived:
+		return "notification_received"
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Payload is the JSON body POSTed to webhook URLs. Fields irrelevant to the
+// Type are empty.
+type Payload struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Peer net.IP    `json:"peer"`
+	// PeerKey identifies the peer among peers sharing its address, see
+	// corebgp.PeerKey.
+	PeerKey string `json:"peer_key"`
+	// State is the state the peer transitioned to of an EventPeerDown.
+	State string `json:"state,omitempty"`
+	// Error is the latest error of the peer of an EventPeerDown, if any.
+	Error string `json:"error,omitempty"`
+	// Notification is the NOTIFICATION message of an
+	// EventPrefixLimitExceeded or EventNotificationReceived, in which case
+	// NotificationSent is true if it was sent to the peer.
+	Notification     *corebgp.Notification `json:"notification,omitempty"`
+	NotificationSent bool                  `json:"notification_sent,omitempty"`
+}
+
+// Default Notifier option values
+const (
+	DefaultMaxRetries     = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultQueueSize      = 1000
+)
+
+type options struct {
+	events         map[EventType]bool
+	client         *http.Client
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	queueSize      int
+	errorHandler   func(error)
+}
+
+func (o *options) setDefaults() {
+	o.events = map[EventType]bool{
+		EventPeerDown:             true,
+		EventPrefixLimitExceeded:  true,
+		EventNotificationReceived: true,
+	}
+	o.client = http.DefaultClient
+	o.maxRetries = DefaultMaxRetries
+	o.initialBackoff = DefaultInitialBackoff
+	o.maxBackoff = DefaultMaxBackoff
+	o.queueSize = DefaultQueueSize
+	o.errorHandler = func(error) {}
+}
+
+// Option is an option for a Notifier.
+type Option interface {
+	apply(*options)
+}
+
+type funcOption struct {
+	fn func(*options)
+}
+
+func (f *funcOption) apply(o *options) {
+	f.fn(o)
+}
+
+func newFuncOption(f func(*options)) *funcOption {
+	return &funcOption{
+		fn: f,
+	}
+}
+
+// Events sets the EventTypes delivered, which defaults to all of them.
+func Events(types ...EventType) Option {
+	return newFuncOption(func(o *options) {
+		o.events = make(map[EventType]bool)
+		for _, t := range types {
+			o.events[t] = true
+		}
+	})
+}
+
+// Client sets the http.Client used for requests, which defaults to
+// http.DefaultClient.
+func Client(client *http.Client) Option {
+	return newFuncOption(func(o *options) {
+		if client != nil {
+			o.client = client
+		}
+	})
+}
+
+// MaxRetries sets the number of times a failed delivery is retried. A
+// delivery fails on a transport error or a response status other than 2xx.
+// Responses with a 4xx status other than 429 are not retried.
+func MaxRetries(n int) Option {
+	return newFuncOption(func(o *options) {
+		if n >= 0 {
+			o.maxRetries = n
+		}
+	})
+}
+
+// Backoff sets the delay before the first retry of a failed delivery, which
+// doubles with each subsequent retry up to max.
+func Backoff(initial, max time.Duration) Option {
+	return newFuncOption(func(o *options) {
+		if initial > 0 {
+			o.initialBackoff = initial
+		}
+		if max >= o.initialBackoff {
+			o.maxBackoff = max
+		}
+	})
+}
+
+// QueueSize sets the maximum number of payloads queued per URL awaiting
+// delivery. Payloads are dropped while the queue is full.
+func QueueSize(n int) Option {
+	return newFuncOption(func(o *options) {
+		if n > 0 {
+			o.queueSize = n
+		}
+	})
+}
+
+// ErrorHandler sets a function called with delivery errors. It must not
+// block.
+func ErrorHandler(fn func(error)) Option {
+	return newFuncOption(func(o *options) {
+		o.errorHandler = fn
+	})
+}
+
+// Stats are counters of a Notifier. Payloads are counted once per URL.
+type Stats struct {
+	// Delivered is the number of payloads successfully delivered.
+	Delivered uint64
+	// Failed is the number of payloads that could not be delivered within
+	// MaxRetries.
+	Failed uint64
+	// Dropped is the number of payloads dropped due to a full queue.
+	Dropped uint64
+	// EventsDropped is the number of events dropped by the Notifier's Server
+	// subscription.
+	EventsDropped uint64
+}
+
+// Notifier delivers Payloads for events of a Server to webhook URLs.
+type Notifier struct {
+	server  *corebgp.Server
+	urls    []string
+	options options
+
+	subMu sync.Mutex
+	sub   *corebgp.Subscription
+
+	delivered uint64
+	failed    uint64
+	dropped   uint64
+}
+
+// NewNotifier returns a Notifier delivering Payloads for events of server to
+// urls.
+func NewNotifier(server *corebgp.Server, urls []string,
+	opts ...Option) *Notifier {
+	n := &Notifier{
+		server: server,
+		urls:   urls,
+	}
+	n.options.setDefaults()
+	for _, opt := range opts {
+		opt.apply(&n.options)
+	}
+	return n
+}
+
+// Stats returns the counters of the Notifier.
+func (n *Notifier) Stats() Stats {
+	s := Stats{
+		Delivered: atomic.LoadUint64(&n.delivered),
+		Failed:    atomic.LoadUint64(&n.failed),
+		Dropped:   atomic.LoadUint64(&n.dropped),
+	}
+	n.subMu.Lock()
+	if n.sub != nil {
+		s.EventsDropped = n.sub.Dropped()
+	}
+	n.subMu.Unlock()
+	return s
+}
+
+// payload returns the Payload for e, or nil if e is not delivered.
+func (n *Notifier) payload(e corebgp.Event) *Payload {
+	p := &Payload{
+		Time:    e.EventTime(),
+		Peer:    e.EventPeer(),
+		PeerKey: e.EventPeerKey().String(),
+	}
+	switch e := e.(type) {
+	case *corebgp.StateChangeEvent:
+		if e.From != corebgp.EstablishedState ||
+			e.To == corebgp.EstablishedState {
+			return nil
+		}
+		p.Type = EventPeerDown
+		p.State = e.To.String()
+		status, err := n.server.GetPeerByKey(e.Key)
+		if err == nil && status.LastError != nil {
+			p.Error = status.LastError.Err
+		}
+	case *corebgp.NotificationReceivedEvent:
+		p.Type = EventNotificationReceived
+		if isPrefixLimit(e.Notification) {
+			p.Type = EventPrefixLimitExceeded
+		}
+		p.Notification = e.Notification
+	case *corebgp.NotificationSentEvent:
+		if !isPrefixLimit(e.Notification) {
+			return nil
+		}
+		p.Type = EventPrefixLimitExceeded
+		p.Notification = e.Notification
+		p.NotificationSent = true
+	default:
+		return nil
+	}
+	if !n.options.events[p.Type] {
+		return nil
+	}
+	return p
+}
+
+func isPrefixLimit(n *corebgp.Notification) bool {
+	return n.Code == corebgp.NotifCodeCease &&
+		n.Subcode == corebgp.NotifSubcodeMaxPrefixes
+}
+
+// retryableError is an error of a delivery that may be retried.
+type retryableError struct {
+	err error
+}
+
+func (r *retryableError) Error() string {
+	return r.err.Error()
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.options.client.Do(req)
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook %s responded %s", url, resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500 {
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+// deliver POSTs body to url, retrying with backoff.
+func (n *Notifier) deliver(ctx context.Context, url string, body []byte) {
+	backoff := n.options.initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := n.post(ctx, url, body)
+		if err == nil {
+			atomic.AddUint64(&n.delivered, 1)
+			return
+		}
+		_, retryable := err.(*retryableError)
+		if !retryable || attempt >= n.options.maxRetries ||
+			ctx.Err() != nil {
+			atomic.AddUint64(&n.failed, 1)
+			n.options.errorHandler(err)
+			return
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			atomic.AddUint64(&n.failed, 1)
+			n.options.errorHandler(err)
+			return
+		case <-t.C:
+		}
+		backoff *= 2
+		if backoff > n.options.maxBackoff {
+			backoff = n.options.maxBackoff
+		}
+	}
+}
+
+// Run delivers payloads, blocking until ctx is done, after which queued and
+// in-flight deliveries are abandoned. Run may only be called once.
+func (n *Notifier) Run(ctx context.Context) error {
+	sub := n.server.Subscribe(n.options.queueSize)
+	n.subMu.Lock()
+	n.sub = sub
+	n.subMu.Unlock()
+	defer sub.Close()
+
+	var wg sync.WaitGroup
+	queues := make([]chan []byte, 0, len(n.urls))
+	for _, url := range n.urls {
+		q := make(chan []byte, n.options.queueSize)
+		queues = append(queues, q)
+		wg.Add(1)
+		go func(url string, q chan []byte) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case body := <-q:
+					n.deliver(ctx, url, body)
+				}
+			}
+		}(url, q)
+	}
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-sub.C():
+			p := n.payload(e)
+			if p == nil {
+				continue
+			}
+			body, err := json.Marshal(p)
+			if err != nil {
+				n.options.errorHandler(err)
+				continue
+			}
+			for _, q := range queues {
+				select {
+				case q <- body:
+				default:
+					atomic.AddUint64(&n.dropped, 1)
+				}
+			}
+		}
+	}
+}
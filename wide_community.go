@@ -0,0 +1,263 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// AttrTypeWideCommunities is the temporary attribute type IANA assigned to
+// the BGP Community Container attribute carrying Wide BGP Communities
+// (draft-ietf-idr-wide-bgp-communities). Its decoding is experimental and
+// opt-in, see RegisterWideCommunities.
+const AttrTypeWideCommunities uint8 = 129
+
+// Community container type values
+const (
+	ContainerTypeWideCommunity uint16 = 1
+)
+
+// Wide community flag values
+const (
+	// WideCommunityFlagTransitive is the T bit, set if the community is
+	// transitive across ASes.
+	WideCommunityFlagTransitive uint8 = 0x80
+)
+
+// Registered wide community values
+const (
+	WideCommunityMatchASNRemoveASPath  uint32 = 0x80000001
+	WideCommunityMatchASNPrependASPath uint32 = 0x80000002
+	WideCommunityMatchASNNoExport      uint32 = 0x80000003
+	WideCommunityMatchASNNoAdvertise   uint32 = 0x80000004
+	WideCommunityMatchASNLocalPref     uint32 = 0x80000005
+	WideCommunityMatchASNMED           uint32 = 0x80000006
+	WideCommunityMatchASNBlackhole     uint32 = 0x80000007
+)
+
+// Wide community TLV type values
+const (
+	WideCommunityTLVTargets        uint8 = 1
+	WideCommunityTLVExcludeTargets uint8 = 2
+	WideCommunityTLVParameters     uint8 = 3
+)
+
+// Wide community atom type values
+const (
+	WideCommunityAtomASN           uint8 = 1
+	WideCommunityAtomIPv4Prefix    uint8 = 2
+	WideCommunityAtomIPv6Prefix    uint8 = 3
+	WideCommunityAtomInteger       uint8 = 4
+	WideCommunityAtomFloat         uint8 = 5
+	WideCommunityAtomNeighborClass uint8 = 6
+	WideCommunityAtomUserClass     uint8 = 7
+	WideCommunityAtomUTF8String    uint8 = 8
+)
+
+const (
+	wideCommunityHeaderLen          = 6
+	wideCommunityTLVHeaderLen       = 3
+	wideCommunityFixedLen           = 8
+	wideCommunityAtomUint32ValueLen = 4
+)
+
+// WideCommunityAtom is an atom of a wide community TLV, whose Value depends
+// on Type.
+type WideCommunityAtom struct {
+	Type  uint8
+	Value []byte
+}
+
+// Uint32 returns the value of an atom of type WideCommunityAtomASN,
+// WideCommunityAtomInteger, WideCommunityAtomNeighborClass or
+// WideCommunityAtomUserClass.
+func (a WideCommunityAtom) Uint32() (uint32, error) {
+	switch a.Type {
+	case WideCommunityAtomASN, WideCommunityAtomInteger,
+		WideCommunityAtomNeighborClass, WideCommunityAtomUserClass:
+	default:
+		return 0, fmt.Errorf("wide community atom type %d is not an "+
+			"integer", a.Type)
+	}
+	if len(a.Value) != wideCommunityAtomUint32ValueLen {
+		return 0, fmt.Errorf("invalid wide community atom length: %d",
+			len(a.Value))
+	}
+	return binary.BigEndian.Uint32(a.Value), nil
+}
+
+// NewUint32WideCommunityAtom returns an atom of atomType carrying v, e.g. of
+// type WideCommunityAtomASN.
+func NewUint32WideCommunityAtom(atomType uint8, v uint32) WideCommunityAtom {
+	value := make([]byte, wideCommunityAtomUint32ValueLen)
+	binary.BigEndian.PutUint32(value, v)
+	return WideCommunityAtom{
+		Type:  atomType,
+		Value: value,
+	}
+}
+
+// WideCommunityTLV is a Target(s), Exclude Target(s) or Parameter(s) TLV of a
+// wide community.
+type WideCommunityTLV struct {
+	Type  uint8
+	Atoms []WideCommunityAtom
+}
+
+// WideCommunity is a Wide BGP Community container. It is experimental and
+// subject to change along with the draft.
+// https://tools.ietf.org/html/draft-ietf-idr-wide-bgp-communities
+type WideCommunity struct {
+	Flags    uint8
+	HopCount uint8
+	// Community is the community value, e.g.
+	// WideCommunityMatchASNNoExport.
+	Community uint32
+	SourceAS  uint32
+	TLVs      []WideCommunityTLV
+}
+
+// TLV returns the first TLV of tlvType.
+func (w *WideCommunity) TLV(tlvType uint8) (WideCommunityTLV, bool) {
+	for _, t := range w.TLVs {
+		if t.Type == tlvType {
+			return t, true
+		}
+	}
+	return WideCommunityTLV{}, false
+}
+
+var errMalformedWideCommunity = errors.New("malformed wide community")
+
+func decodeWideCommunityAtoms(b []byte) ([]WideCommunityAtom, error) {
+	var atoms []WideCommunityAtom
+	for len(b) > 0 {
+		if len(b) < wideCommunityTLVHeaderLen {
+			return nil, errMalformedWideCommunity
+		}
+		n := wideCommunityTLVHeaderLen + int(binary.BigEndian.Uint16(b[1:]))
+		if len(b) < n {
+			return nil, errMalformedWideCommunity
+		}
+		atoms = append(atoms, WideCommunityAtom{
+			Type:  b[0],
+			Value: append([]byte(nil), b[wideCommunityTLVHeaderLen:n]...),
+		})
+		b = b[n:]
+	}
+	return atoms, nil
+}
+
+// DecodeWideCommunities decodes the value of a BGP Community Container
+// attribute. Containers of types other than ContainerTypeWideCommunity are
+// skipped. Values are copied from b.
+func DecodeWideCommunities(b []byte) ([]WideCommunity, error) {
+	var communities []WideCommunity
+	for len(b) > 0 {
+		if len(b) < wideCommunityHeaderLen {
+			return nil, errMalformedWideCommunity
+		}
+		n := wideCommunityHeaderLen + int(binary.BigEndian.Uint16(b[4:]))
+		if len(b) < n {
+			return nil, errMalformedWideCommunity
+		}
+		containerType := binary.BigEndian.Uint16(b)
+		value := b[wideCommunityHeaderLen:n]
+		w := WideCommunity{
+			Flags:    b[2],
+			HopCount: b[3],
+		}
+		b = b[n:]
+		if containerType != ContainerTypeWideCommunity {
+			continue
+		}
+		if len(value) < wideCommunityFixedLen {
+			return nil, errMalformedWideCommunity
+		}
+		w.Community = binary.BigEndian.Uint32(value)
+		w.SourceAS = binary.BigEndian.Uint32(value[4:])
+		value = value[wideCommunityFixedLen:]
+		for len(value) > 0 {
+			if len(value) < wideCommunityTLVHeaderLen {
+				return nil, errMalformedWideCommunity
+			}
+			tlvLen := int(binary.BigEndian.Uint16(value[1:]))
+			if len(value) < wideCommunityTLVHeaderLen+tlvLen {
+				return nil, errMalformedWideCommunity
+			}
+			atoms, err := decodeWideCommunityAtoms(
+				value[wideCommunityTLVHeaderLen : wideCommunityTLVHeaderLen+
+					tlvLen])
+			if err != nil {
+				return nil, err
+			}
+			w.TLVs = append(w.TLVs, WideCommunityTLV{
+				Type:  value[0],
+				Atoms: atoms,
+			})
+			value = value[wideCommunityTLVHeaderLen+tlvLen:]
+		}
+		communities = append(communities, w)
+	}
+	return communities, nil
+}
+
+// appendWideCommunityTLV appends a TLV or atom of tlvType and value to b.
+func appendWideCommunityTLV(b []byte, tlvType uint8,
+	value []byte) ([]byte, error) {
+	if len(value) > math.MaxUint16 {
+		return nil, errors.New("wide community tlv too long")
+	}
+	b = append(b, tlvType, uint8(len(value)>>8), uint8(len(value)))
+	return append(b, value...), nil
+}
+
+// EncodeWideCommunities encodes communities as the value of a BGP Community
+// Container attribute.
+func EncodeWideCommunities(communities ...WideCommunity) ([]byte, error) {
+	var b []byte
+	for _, w := range communities {
+		value := make([]byte, wideCommunityFixedLen)
+		binary.BigEndian.PutUint32(value, w.Community)
+		binary.BigEndian.PutUint32(value[4:], w.SourceAS)
+		for _, t := range w.TLVs {
+			var (
+				atoms []byte
+				err   error
+			)
+			for _, a := range t.Atoms {
+				atoms, err = appendWideCommunityTLV(atoms, a.Type, a.Value)
+				if err != nil {
+					return nil, err
+				}
+			}
+			value, err = appendWideCommunityTLV(value, t.Type, atoms)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(value) > math.MaxUint16 {
+			return nil, errors.New("wide community too long")
+		}
+		b = append(b, uint8(ContainerTypeWideCommunity>>8),
+			uint8(ContainerTypeWideCommunity), w.Flags, w.HopCount,
+			uint8(len(value)>>8), uint8(len(value)))
+		b = append(b, value...)
+	}
+	return b, nil
+}
+
+// RegisterWideCommunities opts in to decoding the BGP Community Container
+// attribute of type AttrTypeWideCommunities via RegisterPathAttr, so that
+// its PathAttr.Decoded is a []WideCommunity. Malformed attributes are
+// discarded. It should be called once, typically from an init function.
+func RegisterWideCommunities() error {
+	return RegisterPathAttr(AttrTypeWideCommunities, PathAttrType{
+		Flags: AttrFlagOptional | AttrFlagTransitive,
+		Decode: func(value []byte, _ *Codec) (interface{}, error) {
+			return DecodeWideCommunities(value)
+		},
+		ErrorHandling: AttrErrorDiscard,
+	})
+}
@@ -0,0 +1,192 @@
+package corebgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// BGP message type values
+const (
+	OpenMessageType         uint8 = 1
+	UpdateMessageType       uint8 = 2
+	NotificationMessageType uint8 = 3
+	KeepAliveMessageType    uint8 = 4
+	// https://tools.ietf.org/html/rfc2918#section-3
+	RouteRefreshMessageType uint8 = 5
+)
+
+const (
+	// HeaderLength is the length of the BGP message header, which consists of
+	// a 16 byte marker, 2 byte length and 1 byte type.
+	HeaderLength = 19
+	// MaxMessageLength is the maximum length of a BGP message including the
+	// header.
+	MaxMessageLength = 4096
+	// ExtendedMaxMessageLength is the maximum length of a BGP message
+	// including the header once the Extended Message capability is
+	// negotiated, except for OPEN and KEEPALIVE messages.
+	// https://tools.ietf.org/html/rfc8654
+	ExtendedMaxMessageLength = 65535
+)
+
+// https://tools.ietf.org/html/rfc4271#section-6.1
+// minimum message length (inclusive of header) by message type
+var minMessageLength = map[uint8]int{
+	OpenMessageType:         29,
+	UpdateMessageType:       23,
+	NotificationMessageType: 21,
+	KeepAliveMessageType:    HeaderLength,
+	RouteRefreshMessageType: 23,
+}
+
+// MarkerValidation determines the handling of messages with a header marker
+// that is not all ones.
+type MarkerValidation uint8
+
+// MarkerValidation values
+const (
+	// MarkerValidationStrict rejects messages with an invalid marker with a
+	// Message Header Error NOTIFICATION with the Connection Not Synchronized
+	// subcode, as required by RFC4271.
+	MarkerValidationStrict MarkerValidation = iota
+	// MarkerValidationPermissive accepts messages with an invalid marker, for
+	// interoperability with buggy middleboxes and embedded stacks. Framing
+	// relies on the length field alone.
+	MarkerValidationPermissive
+)
+
+func (v MarkerValidation) String() string {
+	switch v {
+	case MarkerValidationStrict:
+		return "strict"
+	case MarkerValidationPermissive:
+		return "permissive"
+	}
+	return "unknown"
+}
+
+// MessageReader reads and frames BGP messages from an io.Reader. It validates
+// the message header (marker and length) but does not interpret the message
+// body. A MessageReader is not safe for concurrent use.
+type MessageReader struct {
+	r                 io.Reader
+	maxLength         int
+	markerValidation  MarkerValidation
+	onInvalidMarkerFn func(marker []byte)
+	header            [HeaderLength]byte
+}
+
+// NewMessageReader returns a MessageReader reading from r.
+func NewMessageReader(r io.Reader) *MessageReader {
+	return &MessageReader{
+		r:         r,
+		maxLength: MaxMessageLength,
+	}
+}
+
+// SetMaxMessageLength sets the maximum accepted message length (inclusive of
+// the header). It defaults to MaxMessageLength.
+func (m *MessageReader) SetMaxMessageLength(n int) {
+	m.maxLength = n
+}
+
+// SetMarkerValidation sets the handling of messages with an invalid marker. It
+// defaults to MarkerValidationStrict. fn, if non-nil, is called with the
+// marker of each message accepted despite an invalid marker. The marker is
+// not retained by the MessageReader.
+func (m *MessageReader) SetMarkerValidation(v MarkerValidation,
+	fn func(marker []byte)) {
+	m.markerValidation = v
+	m.onInvalidMarkerFn = fn
+}
+
+// ReadMessage reads the next message, returning its type and body. The body
+// excludes the header and is not retained by the MessageReader.
+//
+// Header validation errors are returned as errors wrapping the *Notification
+// that should be sent to the remote peer, which can be retrieved via
+// errors.As(). Errors from the underlying io.Reader are returned unwrapped.
+// Message types are not validated.
+func (m *MessageReader) ReadMessage() (uint8, []byte, error) {
+	_, err := io.ReadFull(m.r, m.header[:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// https://tools.ietf.org/html/rfc4271#section-6.1
+	for i := 0; i < 16; i++ {
+		if m.header[i] != 0xFF {
+			if m.markerValidation == MarkerValidationPermissive {
+				if m.onInvalidMarkerFn != nil {
+					m.onInvalidMarkerFn(m.header[:16])
+				}
+				break
+			}
+			n := newNotification(NotifCodeMessageHeaderErr,
+				NotifSubcodeConnNotSync, nil)
+			return 0, nil, newNotificationError(n, true)
+		}
+	}
+
+	// length is inclusive of header
+	length := int(binary.BigEndian.Uint16(m.header[16:18]))
+	msgType := m.header[18]
+	minLength, known := minMessageLength[msgType]
+	if !known {
+		minLength = HeaderLength
+	}
+	if length < minLength || length > m.maxLength ||
+		(msgType == KeepAliveMessageType && length != HeaderLength) {
+		badLen := make([]byte, 2)
+		copy(badLen, m.header[16:18])
+		n := newNotification(NotifCodeMessageHeaderErr,
+			NotifSubcodeBadLength, badLen)
+		return 0, nil, newNotificationError(n, true)
+	}
+
+	body := make([]byte, length-HeaderLength)
+	if len(body) > 0 {
+		_, err = io.ReadFull(m.r, body)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+	}
+	return msgType, body, nil
+}
+
+// MessageWriter writes framed BGP messages to an io.Writer. Each message is
+// written with a single call to the underlying io.Writer, so a MessageWriter
+// is safe for concurrent use if the underlying io.Writer is.
+type MessageWriter struct {
+	w         io.Writer
+	maxLength int
+}
+
+// NewMessageWriter returns a MessageWriter writing to w.
+func NewMessageWriter(w io.Writer) *MessageWriter {
+	return &MessageWriter{
+		w:         w,
+		maxLength: MaxMessageLength,
+	}
+}
+
+// SetMaxMessageLength sets the maximum message length (inclusive of the
+// header) that may be written. It defaults to MaxMessageLength.
+func (m *MessageWriter) SetMaxMessageLength(n int) {
+	m.maxLength = n
+}
+
+// WriteMessage prepends a header to body and writes the resulting message.
+// body must not include the header.
+func (m *MessageWriter) WriteMessage(msgType uint8, body []byte) error {
+	if len(body)+HeaderLength > m.maxLength {
+		return fmt.Errorf("message length %d exceeds maximum of %d",
+			len(body)+HeaderLength, m.maxLength)
+	}
+	_, err := m.w.Write(prependHeader(body, msgType))
+	return err
+}
@@ -0,0 +1,84 @@
+//go:build go1.18
+// +build go1.18
+
+package corebgp
+
+// NewWithdrawals returns the UPDATE message bodies withdrawing prefixes of
+// afi/safi, packing as many prefixes into each message as its maximum length
+// permits. IPv4 unicast prefixes are carried by the Withdrawn Routes field,
+// those of other families by an MP_UNREACH_NLRI attribute. Labeled prefixes
+// without Labels are withdrawn with WithdrawLabel.
+//
+// codec is the Codec of the session the messages are sent on, which
+// determines whether path identifiers are sent, and the maximum message
+// length. A nil codec is treated as a session without ADD-PATH and extended
+// messages. No messages are returned if prefixes is empty.
+func NewWithdrawals(afi uint16, safi uint8, codec *Codec,
+	prefixes ...PrefixNLRI) ([][]byte, error) {
+	if codec == nil {
+		codec = &Codec{}
+	}
+	c := NLRICodec{
+		AFI:     afi,
+		SAFI:    safi,
+		AddPath: codec.AddPathSent(afi, safi),
+	}
+	// space available for prefixes excludes the header, the withdrawn routes
+	// and path attributes length fields, and for MP_UNREACH_NLRI its
+	// extended length attribute header, AFI and SAFI
+	space := MaxMessageLength - HeaderLength - 4
+	if codec.ExtendedMessage {
+		space = ExtendedMaxMessageLength - HeaderLength - 4
+	}
+	mp := afi != AFIIPv4 || safi != SAFIUnicast
+	if mp {
+		space -= 4 + 3
+	}
+
+	var (
+		messages [][]byte
+		nlri     []byte
+		err      error
+	)
+	flush := func() error {
+		if len(nlri) == 0 {
+			return nil
+		}
+		if !mp {
+			messages = append(messages, joinUpdate(nlri, nil, nil))
+		} else {
+			value := append([]byte{uint8(afi >> 8), uint8(afi), safi},
+				nlri...)
+			attrs, err := AppendPathAttr(nil, AttrFlagOptional,
+				AttrTypeMPUnreachNLRI, value)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, joinUpdate(nil, attrs, nil))
+		}
+		nlri = nil
+		return nil
+	}
+	for _, p := range prefixes {
+		if safi == SAFIMPLSLabel && len(p.Labels) == 0 {
+			p.Labels = []uint32{WithdrawLabel}
+		}
+		n := len(nlri)
+		nlri, err = c.Append(nlri, p)
+		if err != nil {
+			return nil, err
+		}
+		if len(nlri) > space {
+			prefix := append([]byte(nil), nlri[n:]...)
+			nlri = nlri[:n]
+			if err = flush(); err != nil {
+				return nil, err
+			}
+			nlri = prefix
+		}
+	}
+	if err = flush(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
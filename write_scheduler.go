@@ -0,0 +1,240 @@
+package corebgp
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// WriteScheduler schedules the UPDATE messages written to the peers sharing
+// it, see SharedWriteScheduler. UpdateMessageWriter.WriteUpdate queues a
+// message for its peer and returns, blocking only while the peer's queue is
+// full. A pool of goroutines writes queued messages, taking turns between
+// peers round-robin and writing up to a quota of messages per turn, so that a
+// peer with a slow TCP receiver delays only its own queue rather than all
+// peers written to by the same application, e.g. an announcement pipeline
+// advertising the same routes to many peers.
+//
+// An error writing to a peer is returned by later calls to WriteUpdate for
+// the peer, and messages queued for it are discarded. Messages queued when a
+// session is closed are discarded.
+type WriteScheduler struct {
+	quota       int
+	queueLength int
+
+	mu     sync.Mutex
+	work   *sync.Cond
+	ready  []*writeQueue
+	queues map[*writeQueue]struct{}
+	closed bool
+}
+
+var (
+	errWriteSchedulerClosed = errors.New("write scheduler closed")
+	errOutboundBudget       = errors.New("outbound queue memory budget " +
+		"exceeded")
+)
+
+// NewWriteScheduler returns a WriteScheduler writing with workers goroutines,
+// up to quota messages per peer per turn, and queueing up to queueLength
+// messages per peer. Values below 1 are treated as 1. The goroutines run until
+// Close is called.
+func NewWriteScheduler(workers, quota, queueLength int) *WriteScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	if quota < 1 {
+		quota = 1
+	}
+	if queueLength < 1 {
+		queueLength = 1
+	}
+	s := &WriteScheduler{
+		quota:       quota,
+		queueLength: queueLength,
+		queues:      make(map[*writeQueue]struct{}),
+	}
+	s.work = sync.NewCond(&s.mu)
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// Close stops the WriteScheduler, discarding queued messages. Messages being
+// written are not interrupted. WriteUpdate returns an error for peers using the
+// WriteScheduler once it is closed.
+func (s *WriteScheduler) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for q := range s.queues {
+		q.discard()
+		q.space.Broadcast()
+	}
+	s.ready = nil
+	s.work.Broadcast()
+}
+
+// SharedWriteScheduler returns a PeerOption that writes UPDATE messages to the
+// peer via s, shared with other peers. Messages passed to WriteUpdate are
+// copied as they are queued. ROUTE-REFRESH and CAPABILITY messages are
+// written directly.
+func SharedWriteScheduler(s *WriteScheduler) PeerOption {
+	return newFuncPeerOption(func(o *peerOptions) {
+		o.writeScheduler = s
+	})
+}
+
+// writeQueue holds the messages queued for a peer's Established state. Its
+// fields are protected by s.mu.
+type writeQueue struct {
+	s     *WriteScheduler
+	w     *updateMessageWriter
+	space *sync.Cond
+	msgs  [][]byte
+	// active is true if the queue is in s.ready or being written
+	active bool
+	closed bool
+	err    error
+
+	// usage is the bytes of msgs, updated atomically for PeerStatus
+	usage    *int64
+	budget   int
+	policy   QueueOverflowPolicy
+	counters *peerCounters
+	// overflowCh is closed once the budget is exceeded with QueueTeardown
+	overflowCh chan struct{}
+}
+
+// newQueue returns a queue writing messages of peer p via w.
+func (s *WriteScheduler) newQueue(p *peer,
+	w *updateMessageWriter) *writeQueue {
+	q := &writeQueue{
+		s:          s,
+		w:          w,
+		space:      sync.NewCond(&s.mu),
+		usage:      &p.memory.outboundQueue,
+		budget:     p.options.memoryBudget.OutboundQueue,
+		policy:     p.options.memoryBudget.OutboundPolicy,
+		counters:   p.counters,
+		overflowCh: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.queues[q] = struct{}{}
+	s.mu.Unlock()
+	return q
+}
+
+// enqueue queues a copy of b, waiting for room in the queue if it is full.
+func (q *writeQueue) enqueue(b []byte) error {
+	s := q.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var exceeded bool
+	for q.err == nil && !q.closed && !s.closed {
+		fits := fitsBudget(atomic.LoadInt64(q.usage), len(b), q.budget)
+		if fits && len(q.msgs) < s.queueLength {
+			break
+		}
+		if !fits && !exceeded {
+			exceeded = true
+			q.counters.budgetExceeded()
+			if q.policy == QueueTeardown {
+				q.err = errOutboundBudget
+				q.discard()
+				close(q.overflowCh)
+				break
+			}
+		}
+		q.space.Wait()
+	}
+	switch {
+	case q.err != nil:
+		return q.err
+	case q.closed:
+		return io.ErrClosedPipe
+	case s.closed:
+		return errWriteSchedulerClosed
+	}
+	q.msgs = append(q.msgs, append([]byte(nil), b...))
+	atomic.AddInt64(q.usage, int64(len(b)))
+	if !q.active {
+		q.active = true
+		s.ready = append(s.ready, q)
+		s.work.Signal()
+	}
+	return nil
+}
+
+// close discards the queued messages and removes the queue from s.
+func (q *writeQueue) close() {
+	s := q.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q.closed = true
+	q.discard()
+	q.space.Broadcast()
+	delete(s.queues, q)
+}
+
+// discard discards the queued messages.
+func (q *writeQueue) discard() {
+	for _, b := range q.msgs {
+		atomic.AddInt64(q.usage, -int64(len(b)))
+	}
+	q.msgs = nil
+}
+
+// worker writes the messages of ready queues, up to s.quota messages per
+// turn, until s is closed.
+func (s *WriteScheduler) worker() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.ready) == 0 && !s.closed {
+			s.work.Wait()
+		}
+		if s.closed {
+			return
+		}
+		q := s.ready[0]
+		s.ready[0] = nil
+		s.ready = s.ready[1:]
+		n := len(q.msgs)
+		if n > s.quota {
+			n = s.quota
+		}
+		batch := q.msgs[:n:n]
+		q.msgs = q.msgs[n:]
+		for _, b := range batch {
+			atomic.AddInt64(q.usage, -int64(len(b)))
+		}
+		q.space.Broadcast()
+
+		s.mu.Unlock()
+		var err error
+		for _, b := range batch {
+			if err = q.w.writeUpdate(b); err != nil {
+				break
+			}
+		}
+		s.mu.Lock()
+
+		if err != nil && !q.closed {
+			q.err = err
+			q.discard()
+			q.space.Broadcast()
+		}
+		if len(q.msgs) > 0 && !q.closed && !s.closed {
+			s.ready = append(s.ready, q)
+		} else {
+			q.discard()
+			q.active = false
+		}
+	}
+}